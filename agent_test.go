@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/health"
+	"tui-wireguard-vpn/internal/settings"
+)
+
+// Secrets planted in an installed config, in places a parser might mistake
+// for something else.
+const (
+	privateKey   = "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	presharedKey = "FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE="
+	pastedKey    = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+)
+
+func TestConfigRevisionsNeverCarryKeyMaterial(t *testing.T) {
+	for _, dir := range []string{"XDG_STATE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(dir, t.TempDir())
+	}
+	dir := t.TempDir()
+	saved := core.ConfigDir
+	core.SetConfigDir(dir)
+	t.Cleanup(func() { core.SetConfigDir(saved) })
+
+	malicious := strings.Join([]string{
+		"[Interface]",
+		"PrivateKey = " + privateKey,
+		"Address = 10.9.0.7/32",
+		"[Peer]",
+		"PublicKey = " + pastedKey,
+		"PresharedKey = " + presharedKey,
+		"AllowedIPs = 10.0.0.0/8, " + pastedKey + ", PrivateKey = " + privateKey,
+		"AllowedIPs=" + privateKey,
+		"Endpoint = 34.101.166.184:51820",
+	}, "\n")
+	if err := os.WriteFile(filepath.Join(dir, core.ProdConfig), []byte(malicious), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, core.ProdTemplate), []byte(malicious), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	appSettings := settings.Default()
+	h := health.ConfigsHandler(&health.ConfigsEndpoint{
+		Token:   "s3cret",
+		Collect: func(ctx context.Context) []health.ConfigRevision { return configRevisions(appSettings) },
+	})
+	req := httptest.NewRequest(http.MethodGet, "/v1/configs", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /v1/configs = %d %q", rec.Code, rec.Body.String())
+	}
+
+	body := rec.Body.String()
+	for _, secret := range []string{privateKey, presharedKey, pastedKey, "10.9.0.7", "34.101.166.184", dir} {
+		if strings.Contains(body, secret) {
+			t.Errorf("response carries %q:\n%s", secret, body)
+		}
+	}
+	var response health.ConfigsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	for _, revision := range response.Configs {
+		if revision.Environment != "prod" {
+			continue
+		}
+		// Only the one valid prefix is counted
+		if !revision.Installed || revision.Routes != 1 || len(revision.ConfigSHA256) != 64 {
+			t.Errorf("prod revision %+v", revision)
+		}
+		return
+	}
+	t.Errorf("no prod revision in %s", body)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+var rewrite = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// dashboard is the dashboard of a connected Production tunnel on a 100x60
+// terminal.
+func dashboard(t *testing.T) model {
+	t.Helper()
+	m := testModel(t)
+	m = update(m, tea.WindowSizeMsg{Width: 100, Height: 60})
+	return update(m, vpnStatusMsg{status: &vpn.ConnectionStatus{
+		Connected:   true,
+		Environment: vpn.Production,
+		Interface:   "julo-prod",
+		Endpoint:    "34.101.166.184:51820",
+		BytesRx:     1 << 20,
+		BytesTx:     2048,
+	}})
+}
+
+// checkGolden compares view with testdata/<name>.golden.
+func checkGolden(t *testing.T, name, view string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *rewrite {
+		if err := os.WriteFile(path, []byte(view), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view != string(want) {
+		t.Errorf("%s differs from %s (go test -update rewrites it):\n%s", name, path, view)
+	}
+}
+
+// TestDashboardGolden draws the dashboard in both glyph sets. The ASCII one
+// has to line up cell for cell with the Unicode one.
+func TestDashboardGolden(t *testing.T) {
+	m := dashboard(t)
+	unicode := m.View()
+	checkGolden(t, "Dashboard", unicode)
+
+	applyGlyphs("ascii")
+	t.Cleanup(func() { applyGlyphs("unicode") })
+	ascii := m.View()
+	checkGolden(t, "DashboardASCII", ascii)
+
+	unicodeLines, asciiLines := strings.Split(unicode, "\n"), strings.Split(ascii, "\n")
+	if len(unicodeLines) != len(asciiLines) {
+		t.Fatalf("%d lines in ASCII, %d in Unicode", len(asciiLines), len(unicodeLines))
+	}
+	for i, line := range asciiLines {
+		for _, r := range line {
+			if r > 0x7f {
+				t.Errorf("line %d: %q is not ASCII", i, r)
+				break
+			}
+		}
+		if w, want := ansi.StringWidth(line), ansi.StringWidth(unicodeLines[i]); w != want || w > m.terminalWidth {
+			t.Errorf("line %d is %d cells wide in ASCII, %d in Unicode, on a %d-column terminal", i, w, want, m.terminalWidth)
+		}
+	}
+}
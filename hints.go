@@ -0,0 +1,293 @@
+package main
+
+import (
+	"strings"
+
+	"tui-wireguard-vpn/internal/sudo"
+	"tui-wireguard-vpn/internal/ui/render"
+)
+
+// hintSource is a panel, view or dialog the controls panel describes. The
+// first source in hintSources that has the focus reports the hints; a modal
+// one takes every key, so the global keys are left out while it is up.
+type hintSource struct {
+	focused func(m model) bool
+	hints   func(m model) render.HintGroup
+	modal   bool
+}
+
+// hintSources are ordered like the key handling in Update: the dialogs that
+// intercept keys first, then the focused panel.
+var hintSources = []hintSource{
+	{
+		focused: func(m model) bool { return m.mfa != nil },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "One-time Code", Hints: []render.Hint{
+				{Keys: "0-9", Action: "Enter the code"},
+				{Keys: "Enter", Action: "Check"},
+				{Keys: "Esc", Action: "Cancel the Start"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.routeCheck != nil },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Route Check", Hints: []render.Hint{
+				{Action: "Type an IP or hostname"},
+				{Keys: "Enter", Action: "Check"},
+				{Keys: "Esc", Action: "Cancel"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.labelEdit != nil },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Route Label", Hints: []render.Hint{
+				{Action: "Type a short label"},
+				{Keys: "Enter", Action: "Save (empty removes it)"},
+				{Keys: "Esc", Action: "Cancel"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.switcher != nil },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Switch Environment", Hints: []render.Hint{
+				{Keys: "↑/↓", Action: "Choose"},
+				{Keys: "Enter", Action: "Switch"},
+				{Keys: "Esc/e", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.conflict != nil },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Interface Conflict", Hints: []render.Hint{
+				{Keys: "↑/↓", Action: "Choose the tunnel to keep"},
+				{Keys: "Enter", Action: "Keep it, stop the rest"},
+				{Keys: "Esc", Action: "Decide later (i)"},
+			}}
+		},
+	},
+	{
+		// Mirrors the key handling: a question running a command itself
+		// waits until nothing runs
+		focused: func(m model) bool { return m.confirm != nil && (m.confirm.op != nil || !m.loading) },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			group := render.HintGroup{Title: "Confirm"}
+			group.Add("y", "Yes")
+			if m.confirm.deferCmd != nil {
+				group.Add("d", "Defer until disconnect")
+			}
+			group.Add("Any other key", "No")
+			return group
+		},
+	},
+	{
+		focused: func(m model) bool { return m.review != nil && !m.loading },
+		modal:   true,
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Review Changes", Hints: []render.Hint{
+				{Keys: "↑/↓", Action: "Scroll"},
+				{Keys: "PgUp/PgDn", Action: "Page"},
+				{Keys: "y", Action: "Apply"},
+				{Keys: "Esc", Action: "Cancel"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.showInputPanel && m.generateModel != nil },
+		hints:   func(m model) render.HintGroup { return m.generateModel.Hints() },
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.showInputPanel && m.inputModel != nil },
+		hints:   func(m model) render.HintGroup { return m.inputModel.Hints() },
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.overviewOpen },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Network Overview", Hints: []render.Hint{
+				{Keys: "r", Action: "Probe again"},
+				{Keys: "Esc", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.connectionsOpen },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Active Connections", Hints: []render.Hint{
+				{Keys: "r", Action: "List again"},
+				{Keys: "Esc", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.routesOpen },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Routes", Hints: []render.Hint{
+				{Keys: "↑/↓", Action: "Select a range"},
+				{Keys: "a", Action: "Label it"},
+				{Keys: "r", Action: "Look again"},
+				{Keys: "Esc", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.preflightOpen },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Preflight", Hints: []render.Hint{
+				{Keys: "r", Action: "Check again"},
+				{Keys: "Esc", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.historyOpen },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Connection History", Hints: []render.Hint{
+				{Keys: "r", Action: "Reload"},
+				{Keys: "Esc", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 && m.configOpen },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Config", Hints: []render.Hint{
+				{Keys: "↑/↓", Action: "Scroll"},
+				{Keys: "PgUp/PgDn/Home/End", Action: "Page"},
+				{Keys: "Esc", Action: "Close"},
+			}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 1 },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Help Panel", Hints: []render.Hint{{Action: "Information only"}}}
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 0 },
+		hints: func(m model) render.HintGroup {
+			group := render.HintGroup{Title: "Menu + Status"}
+			if m.showInputPanel {
+				group.Add("", "Menu paused while a panel is open")
+				return group
+			}
+			group.Add("↑/↓", "Navigate menu")
+			if m.loading {
+				group.Add("Enter", "Queue Start/Stop")
+			} else {
+				group.Add("Enter", "Select option")
+			}
+			if m.reconnect != nil {
+				group.Add("Enter/Esc", "Reconnect / Dismiss")
+			}
+			return group
+		},
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 2 },
+		hints:   func(m model) render.HintGroup { return m.activityLog.Hints() },
+	},
+	{
+		focused: func(m model) bool { return m.activePanel == 3 },
+		hints: func(m model) render.HintGroup {
+			return render.HintGroup{Title: "Controls Panel", Hints: []render.Hint{{Action: "Keys for the focused panel"}}}
+		},
+	},
+}
+
+// controlHints are the hints of the focused source and, unless it is modal,
+// the global keys.
+func (m model) controlHints() []render.HintGroup {
+	for _, source := range hintSources {
+		if !source.focused(m) {
+			continue
+		}
+		if source.modal {
+			return []render.HintGroup{source.hints(m)}
+		}
+		return []render.HintGroup{source.hints(m), m.globalHints()}
+	}
+	return []render.HintGroup{m.globalHints()}
+}
+
+// globalHints are the keys working from any panel, as far as they apply
+// now.
+func (m model) globalHints() render.HintGroup {
+	group := render.HintGroup{Title: "Global"}
+	if m.showInputPanel {
+		group.Add("Tab", "Switch panels")
+		return group
+	}
+	if _, _, running := m.opQueue.Running(); running {
+		group.Add("c", "Cancel (queued first)")
+	} else if !m.loading {
+		group.Add("c", "Copy diagnostics")
+	}
+	if m.viewedConfig != "" {
+		group.Add("x", "Export device template")
+		group.Add("X", "Same, with a new keypair")
+	}
+	group.Add("e", "Switch environment")
+	if m.status != nil && len(m.status.ConflictingInterfaces) > 0 {
+		group.Add("i", "Resolve interface conflict")
+	}
+	if m.status != nil && m.status.Connected {
+		group.Add("a", "Active connections")
+		group.Add("o", "Routes")
+		group.Add("w", "Route check")
+	}
+	if m.gatewayMigration != nil && !m.readOnly {
+		group.Add("g", "Update the gateway endpoint")
+	}
+	if len(m.deferredConfigs) > 0 && !m.loading {
+		if !m.readOnly {
+			group.Add("A", "Install deferred configs")
+		}
+		group.Add("D", "Discard deferred configs")
+	}
+	if len(m.pendingUpdates) > 0 && !m.readOnly {
+		group.Add("U", "Apply the found updates")
+	}
+	if m.sudoKept {
+		group.Add("u", "Drop sudo credentials")
+	} else if !sudo.IsRoot() {
+		group.Add("u", "Keep sudo credentials")
+	}
+	if m.autoRefresh {
+		group.Add("p", "Pause auto-refresh")
+	} else {
+		group.Add("p", "Resume auto-refresh")
+	}
+	group.Add("m", "Mini mode")
+	if m.readOnly {
+		group.Add("s", "Run setup")
+	}
+	group.Add("q/Ctrl+C", "Quit")
+	group.Add("Tab", "Cycle panels")
+	return group
+}
+
+func (m model) buildControlsPanel(width, height int) string {
+	var content strings.Builder
+
+	content.WriteString("🎮 Controls\n")
+	content.WriteString("──────────────────────\n")
+	content.WriteString(render.RenderHints(m.controlHints()...))
+
+	panelStyle := controlsPanelStyle.Width(width).Height(height)
+	if m.activePanel == 3 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue when focused
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White when not focused
+	}
+	return panelStyle.Render(content.String())
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"tui-wireguard-vpn/internal/ui/render"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+func titles(groups []render.HintGroup) []string {
+	var names []string
+	for _, group := range groups {
+		names = append(names, group.Title)
+	}
+	return names
+}
+
+func keys(group render.HintGroup) []string {
+	var names []string
+	for _, hint := range group.Hints {
+		names = append(names, hint.Keys)
+	}
+	return names
+}
+
+func TestControlHintsSource(t *testing.T) {
+	noop := func() tea.Msg { return nil }
+	tests := []struct {
+		name  string
+		setup func(m *model)
+		want  []string
+	}{
+		{"menu", func(m *model) {}, []string{"Menu + Status", "Global"}},
+		{"activity log", func(m *model) { m.activePanel = 2 }, []string{"Activity Log", "Global"}},
+		{"controls", func(m *model) { m.activePanel = 3 }, []string{"Controls Panel", "Global"}},
+		{"help", func(m *model) { m.activePanel = 1 }, []string{"Help Panel", "Global"}},
+		{"view in the help panel", func(m *model) { m.activePanel, m.routesOpen = 1, true }, []string{"Routes", "Global"}},
+		// The views belong to the right panel: they don't speak for the menu
+		{"view with the menu focused", func(m *model) { m.routesOpen = true }, []string{"Menu + Status", "Global"}},
+		{"dialog", func(m *model) { m.switcher = &envSwitcher{} }, []string{"Switch Environment"}},
+		{"dialog over a focused view", func(m *model) {
+			m.activePanel, m.configOpen, m.routeCheck = 1, true, &routePrompt{}
+		}, []string{"Route Check"}},
+		// Ordered like Update: the code prompt takes the keys before a question
+		{"code prompt over a question", func(m *model) {
+			m.confirm, m.mfa = &confirmPrompt{cmd: noop}, &mfaPrompt{}
+		}, []string{"One-time Code"}},
+		{"question", func(m *model) { m.confirm = &confirmPrompt{cmd: noop} }, []string{"Confirm"}},
+		// A question running a command waits until nothing runs
+		{"question while busy", func(m *model) {
+			m.loading, m.confirm = true, &confirmPrompt{cmd: noop}
+		}, []string{"Menu + Status", "Global"}},
+		{"operation question while busy", func(m *model) {
+			m.loading, m.confirm = true, &confirmPrompt{op: &stopOp}
+		}, []string{"Confirm"}},
+		{"review", func(m *model) { m.review = &configReview{} }, []string{"Review Changes"}},
+		{"review while busy", func(m *model) { m.loading, m.review = true, &configReview{} }, []string{"Menu + Status", "Global"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testModel(t)
+			tt.setup(&m)
+			if got := titles(m.controlHints()); !slices.Equal(got, tt.want) {
+				t.Errorf("controlHints() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmHintsOfferDefer(t *testing.T) {
+	m := testModel(t)
+	noop := func() tea.Msg { return nil }
+	m.confirm = &confirmPrompt{cmd: noop}
+	if got := keys(m.controlHints()[0]); slices.Contains(got, "d") {
+		t.Errorf("keys %q offer to defer", got)
+	}
+	m.confirm.deferCmd = noop
+	if got := keys(m.controlHints()[0]); !slices.Contains(got, "d") {
+		t.Errorf("keys %q don't offer to defer", got)
+	}
+}
+
+func TestGlobalHints(t *testing.T) {
+	prodUp := &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production, Interface: "julo-prod"}
+	tests := []struct {
+		name    string
+		setup   func(m *model)
+		want    []string
+		notWant []string
+	}{
+		{"disconnected", func(m *model) {}, []string{"c", "e", "m", "q/Ctrl+C", "Tab"}, []string{"a", "o", "w", "i", "s", "U"}},
+		{"connected", func(m *model) { m.status = prodUp }, []string{"a", "o", "w"}, nil},
+		{"conflict", func(m *model) {
+			m.status = &vpn.ConnectionStatus{Connected: true, ConflictingInterfaces: []string{"julo-nonprod"}}
+		}, []string{"i"}, nil},
+		{"read-only", func(m *model) {
+			m.readOnly, m.gatewayMigration = true, &vpn.GatewayMigration{}
+		}, []string{"s"}, []string{"g"}},
+		{"gateway moved", func(m *model) { m.gatewayMigration = &vpn.GatewayMigration{} }, []string{"g"}, nil},
+		{"busy", func(m *model) { m.loading = true }, nil, []string{"c"}},
+		{"panel open", func(m *model) { m.showInputPanel, m.status = true, prodUp }, []string{"Tab"}, []string{"a", "q/Ctrl+C", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testModel(t)
+			tt.setup(&m)
+			got := keys(m.globalHints())
+			for _, key := range tt.want {
+				if !slices.Contains(got, key) {
+					t.Errorf("global keys %q lack %q", got, key)
+				}
+			}
+			for _, key := range tt.notWant {
+				if slices.Contains(got, key) {
+					t.Errorf("global keys %q offer %q", got, key)
+				}
+			}
+		})
+	}
+}
+
+// A running operation makes c cancel it, whatever else is going on.
+func TestGlobalHintsCancel(t *testing.T) {
+	m := running(t, testModel(t))
+	group := m.globalHints()
+	i := slices.Index(keys(group), "c")
+	if i < 0 || group.Hints[i].Action != "Cancel (queued first)" {
+		t.Errorf("global hints %+v don't offer to cancel", group.Hints)
+	}
+}
@@ -0,0 +1,182 @@
+// Package audit keeps an append-only record of privileged actions: bringing
+// tunnels up or down, anything that writes under /etc/wireguard, the
+// one-time code checks guarding Start, the agent's answers about the
+// installed configs and preflight runs. It is
+// separate from the in-app activity log and is never truncated; when it grows
+// large it is archived next to itself and a fresh file is started.
+//
+// Privileged code paths call Run, which performs the action and records its
+// outcome in one step, so an action can't happen without an entry.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/paths"
+)
+
+const (
+	auditFile = "audit.log"
+	// Size after which the log is archived as audit.log.<timestamp>
+	archiveSize = 5 << 20
+)
+
+// Action names a kind of privileged action.
+type Action string
+
+const (
+	ActionUp          Action = "up"
+	ActionDown        Action = "down"
+	ActionConfigWrite Action = "config_write"
+	ActionRollback    Action = "rollback"
+	ActionKeyRotation Action = "key_rotation"
+	ActionMFA         Action = "mfa" // a one-time code check before Start
+	// ActionScheduledSync is a sync from the server run by sync_schedule
+	ActionScheduledSync Action = "scheduled_sync"
+	// ActionConfigMetadata is a request to the agent for the installed
+	// config revisions (GET /v1/configs)
+	ActionConfigMetadata Action = "config_metadata"
+	// ActionPreflight is a preflight run; it changes nothing but is kept
+	// as a record of readiness
+	ActionPreflight Action = "preflight"
+)
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time        time.Time `json:"time"`
+	User        string    `json:"user"`
+	Action      Action    `json:"action"`
+	Environment string    `json:"environment,omitempty"`
+	Target      string    `json:"target,omitempty"`
+	Result      string    `json:"result"` // "ok" or "failed"
+	Error       string    `json:"error,omitempty"`
+}
+
+func (e Entry) String() string {
+	line := fmt.Sprintf("%s  %-8s %-12s %-7s %s", e.Time.Format(time.RFC3339), e.User, e.Action, e.Environment, e.Result)
+	if e.Target != "" {
+		line += "  " + e.Target
+	}
+	if e.Error != "" {
+		line += "  (" + e.Error + ")"
+	}
+	return line
+}
+
+var mu sync.Mutex
+
+// Path returns the location of the audit log.
+func Path() (string, error) {
+	return paths.File(paths.State, auditFile)
+}
+
+// Run performs a privileged action and records it with its result. The
+// action's own error is returned unchanged; failing to write the audit
+// entry is reported on stderr rather than masking the action's outcome.
+func Run(action Action, env, target string, fn func() error) error {
+	err := fn()
+	entry := Entry{
+		Time:        time.Now(),
+		User:        currentUser(),
+		Action:      action,
+		Environment: env,
+		Target:      target,
+		Result:      "ok",
+	}
+	if err != nil {
+		entry.Result = "failed"
+		entry.Error = err.Error()
+	}
+	if recordErr := record(entry); recordErr != nil {
+		fmt.Fprintf(os.Stderr, "audit: %v\n", recordErr)
+	}
+	return err
+}
+
+// currentUser names the person behind the action: the invoking user when
+// running under sudo, otherwise the process owner.
+func currentUser() string {
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && os.Geteuid() == 0 {
+		return sudoUser + " (sudo)"
+	}
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return fmt.Sprintf("uid %d", os.Getuid())
+}
+
+func record(entry Entry) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path, err := paths.EnsureFile(paths.State, auditFile)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() >= archiveSize {
+		archived := path + "." + time.Now().Format("2006-01-02T15-04-05")
+		if err := os.Rename(path, archived); err != nil {
+			return fmt.Errorf("failed to archive audit log: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
+
+// Recent returns the last n entries of the current audit log, oldest first.
+// A missing log yields no entries.
+func Recent(n int) ([]Entry, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // A torn line from a crash shouldn't hide the rest
+		}
+		entries = append(entries, entry)
+		if n > 0 && len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Print writes entries one per line.
+func Print(w io.Writer, entries []Entry) {
+	for _, entry := range entries {
+		fmt.Fprintln(w, entry)
+	}
+}
@@ -0,0 +1,227 @@
+// Package backup exports the installed templates and configs into
+// passphrase-encrypted archives in a user-chosen directory, so a reinstalled
+// machine can be restored without asking infra to reissue configs.
+//
+// The passphrase is never stored. Automatic backups read it from the
+// environment variable named in the settings and are skipped without it.
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+)
+
+const (
+	archivePrefix = "tui-wireguard-vpn-"
+	archiveExt    = ".twbak"
+	timeFormat    = "2006-01-02T15-04-05"
+	// maxFileSize is the largest file an archive may hold; configs are a
+	// few hundred bytes
+	maxFileSize = 1 << 20
+
+	// How often the weekly automatic backup runs
+	Interval = 7 * 24 * time.Hour
+)
+
+// Archive is a backup file found in the backup directory.
+type Archive struct {
+	Path    string
+	Created time.Time
+	Size    int64
+}
+
+// Create writes a new encrypted archive of every installed managed file to
+// dir and returns its path.
+func Create(dir, passphrase string) (string, error) {
+	if passphrase == "" {
+		return "", fmt.Errorf("a passphrase is required")
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	added := 0
+	for _, name := range config.InstalledFiles() {
+		content, err := config.ReadInstalled(name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		header := &tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), ModTime: time.Now()}
+		if err := tw.WriteHeader(header); err != nil {
+			return "", err
+		}
+		if _, err := tw.Write(content); err != nil {
+			return "", err
+		}
+		added++
+	}
+	if added == 0 {
+		return "", fmt.Errorf("nothing to back up: no configs installed in %s", core.ConfigDir)
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(passphrase, buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt backup: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %v", err)
+	}
+	path := filepath.Join(dir, archivePrefix+time.Now().Format(timeFormat)+archiveExt)
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		return "", fmt.Errorf("failed to write backup: %v", err)
+	}
+	return path, nil
+}
+
+// List returns the archives in dir, newest first. A missing directory has
+// no archives.
+func List(dir string) ([]Archive, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var archives []Archive
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveExt) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveExt)
+		created, err := time.ParseInLocation(timeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, Archive{Path: filepath.Join(dir, name), Created: created, Size: info.Size()})
+	}
+	sort.Slice(archives, func(i, j int) bool { return archives[i].Created.After(archives[j].Created) })
+	return archives, nil
+}
+
+// Due reports whether the weekly automatic backup should run, i.e. the
+// newest archive in dir is older than Interval (or there is none).
+func Due(dir string) bool {
+	archives, err := List(dir)
+	if err != nil || len(archives) == 0 {
+		return err == nil
+	}
+	return time.Since(archives[0].Created) >= Interval
+}
+
+// Read decrypts an archive and returns its files by name without writing
+// anything.
+func Read(path, passphrase string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(passphrase, data)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("corrupted archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corrupted archive: %v", err)
+		}
+		if header.Size > maxFileSize {
+			return nil, fmt.Errorf("archive file %s is larger than %d bytes", header.Name, maxFileSize)
+		}
+		content, err := io.ReadAll(io.LimitReader(tr, maxFileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("corrupted archive: %v", err)
+		}
+		if len(content) > maxFileSize {
+			return nil, fmt.Errorf("archive file %s is larger than %d bytes", header.Name, maxFileSize)
+		}
+		files[header.Name] = string(content)
+	}
+	return files, nil
+}
+
+// Restore decrypts an archive, validates every file in it, and only then
+// installs them into the config directory. It returns the restored names.
+func Restore(path, passphrase string) ([]string, error) {
+	files, err := Read(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// Templates first so restored configs sit next to matching templates
+	managed := config.InstalledFiles()
+	var names []string
+	for _, name := range managed {
+		if _, ok := files[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for name := range files {
+		if !contains(managed, name) {
+			return nil, fmt.Errorf("archive contains unexpected file %q", name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("archive is empty")
+	}
+
+	// Validate everything before the first write
+	processor := config.NewConfigProcessor()
+	for _, name := range names {
+		if err := config.ValidateInstalled(name, files[name]); err != nil {
+			return nil, err
+		}
+	}
+	for i, name := range names {
+		if err := processor.RestoreInstalled(name, files[name]); err != nil {
+			return names[:i], fmt.Errorf("failed to restore %s: %v", name, err)
+		}
+	}
+	return names, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+)
+
+// useConfigDir points core.ConfigDir at a fresh directory, and the audit
+// log restores write to at another.
+func useConfigDir(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	saved := core.ConfigDir
+	core.SetConfigDir(dir)
+	t.Cleanup(func() { core.SetConfigDir(saved) })
+	return dir
+}
+
+// issued turns a gateway's template into a config issued for it.
+func issued(env string) string {
+	for _, g := range config.Gateways() {
+		if g.Environment == env {
+			content := strings.Replace(g.Template(), strings.Repeat("x", 40), "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=", 1)
+			return strings.Replace(content, strings.Repeat("x", 40), "10.9.0.2/32", 1)
+		}
+	}
+	panic("no gateway for " + env)
+}
+
+func TestRoundTrip(t *testing.T) {
+	useConfigDir(t)
+	files := map[string]string{
+		core.ProdTemplate: config.Gateways()[0].Template(),
+		core.ProdConfig:   issued("prod"),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(core.InstalledPath(name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archive, err := Create(t.TempDir(), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Read(archive, "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("Read() with the wrong passphrase: %v, want ErrWrongPassphrase", err)
+	}
+	read, err := Read(archive, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != len(files) {
+		t.Errorf("Read() = %d files, want %d", len(read), len(files))
+	}
+	for name, content := range files {
+		if read[name] != content {
+			t.Errorf("%s read back as %q", name, read[name])
+		}
+	}
+
+	// Restored into a machine without them
+	useConfigDir(t)
+	restored, err := Restore(archive, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Templates first
+	if strings.Join(restored, " ") != core.ProdTemplate+" "+core.ProdConfig {
+		t.Errorf("Restore() = %v", restored)
+	}
+	for name, content := range files {
+		got, err := os.ReadFile(core.InstalledPath(name))
+		if err != nil || string(got) != content {
+			t.Errorf("%s restored as %q, %v", name, got, err)
+		}
+	}
+}
+
+// sealedArchive writes an archive of files as Create would.
+func sealedArchive(t *testing.T, passphrase string, files map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	sealed, err := seal(passphrase, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "archive"+archiveExt)
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRestoreRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"an oversized file", map[string]string{core.ProdConfig: strings.Repeat("#", maxFileSize+1)}, "larger than"},
+		{"an unexpected file", map[string]string{"passwd": "root:x:0:0"}, "unexpected file"},
+		{"a config for another environment", map[string]string{core.ProdConfig: issued("nonprod")}, "not prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useConfigDir(t)
+			archive := sealedArchive(t, "pass", tt.files)
+			_, err := Restore(archive, "pass")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Restore() error = %v, want %q", err, tt.wantErr)
+			}
+			// Nothing written
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+				t.Errorf("Restore() wrote %d file(s)", len(entries))
+			}
+		})
+	}
+}
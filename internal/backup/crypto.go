@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// magic identifies (and versions) an archive
+	magic = "TWVPN-BACKUP-1\n"
+	// PBKDF2-HMAC-SHA256 work factor for deriving the archive key
+	kdfIterations = 600000
+	saltSize      = 16
+	keySize       = 32
+)
+
+// ErrWrongPassphrase is returned when an archive can't be decrypted, which
+// is almost always a mistyped passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted archive")
+
+// seal encrypts plaintext with a key derived from passphrase. The output is
+// magic | salt | nonce | AES-256-GCM ciphertext, with the magic bound in as
+// additional data.
+func seal(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(magic), salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, []byte(magic)), nil
+}
+
+// open reverses seal.
+func open(passphrase string, data []byte) ([]byte, error) {
+	if len(data) < len(magic)+saltSize || string(data[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not a tui-wireguard-vpn backup archive")
+	}
+	data = data[len(magic):]
+	salt, data := data[:saltSize], data[saltSize:]
+
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(magic))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, kdfIterations, keySize, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
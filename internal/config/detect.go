@@ -0,0 +1,318 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+const (
+	// How long a hostname resolution (successful or not) is reused
+	resolveCacheTTL = 5 * time.Minute
+	// Upper bound for resolving an Endpoint hostname during detection
+	resolveTimeout = 3 * time.Second
+)
+
+// knownEndpoints maps the issued numeric endpoints to their environment.
+var knownEndpoints = map[string]string{
+	ProdEndpoint:    "prod",
+	NonProdEndpoint: "nonprod",
+}
+
+var (
+	hostsMu       sync.RWMutex
+	endpointHosts = map[string][]string{}
+)
+
+// RegisterEndpointHost declares hostname as a gateway name of env ("prod" or
+// "nonprod"). Configs whose Endpoint uses a registered hostname are
+// recognized without a DNS lookup.
+func RegisterEndpointHost(env, hostname string) {
+	hostname = normalizeHost(hostname)
+	if hostname == "" {
+		return
+	}
+	hostsMu.Lock()
+	defer hostsMu.Unlock()
+	for _, existing := range endpointHosts[env] {
+		if existing == hostname {
+			return
+		}
+	}
+	endpointHosts[env] = append(endpointHosts[env], hostname)
+}
+
+// RegisterEndpoint declares endpoint (host:port, as configs give it) as the
+// gateway of env, for profiles besides prod and nonprod, whose gateways the
+// app doesn't know on its own.
+func RegisterEndpoint(env, endpoint string) {
+	hostsMu.Lock()
+	defer hostsMu.Unlock()
+	knownEndpoints[strings.TrimSpace(endpoint)] = env
+}
+
+func knownEndpoint(endpoint string) (string, bool) {
+	hostsMu.RLock()
+	defer hostsMu.RUnlock()
+	env, ok := knownEndpoints[endpoint]
+	return env, ok
+}
+
+func registeredHosts(env string) []string {
+	hostsMu.RLock()
+	defer hostsMu.RUnlock()
+	return append([]string(nil), endpointHosts[env]...)
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// LookupHost resolves hostnames for endpoint detection. Replaceable for
+// offline use.
+var LookupHost = net.DefaultResolver.LookupHost
+
+type resolution struct {
+	addrs    []string
+	err      error
+	resolved time.Time
+}
+
+var (
+	resolveMu    sync.Mutex
+	resolveCache = map[string]resolution{}
+)
+
+// resolveHost looks host up through a small TTL cache so repeated status
+// refreshes don't hit DNS every time.
+func resolveHost(host string) ([]string, error) {
+	resolveMu.Lock()
+	cached, ok := resolveCache[host]
+	resolveMu.Unlock()
+	if ok && time.Since(cached.resolved) < resolveCacheTTL {
+		return cached.addrs, cached.err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	addrs, err := LookupHost(ctx, host)
+	if err != nil {
+		err = fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+
+	resolveMu.Lock()
+	resolveCache[host] = resolution{addrs: addrs, err: err, resolved: time.Now()}
+	resolveMu.Unlock()
+	return addrs, err
+}
+
+// KnownEnvironment is EnvironmentForEndpoint without the DNS lookup: it
+// matches the issued and registered endpoints and the registered hostnames
+// only, so it is cheap enough to run while a path is typed. ok is false
+// when only a lookup could tell.
+func KnownEnvironment(endpoint string) (env string, ok bool) {
+	if env, ok := knownEndpoint(endpoint); ok {
+		return env, true
+	}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return "", false
+	}
+	host = normalizeHost(host)
+	for _, env := range core.Environments() {
+		for _, known := range registeredHosts(string(env)) {
+			if known == host {
+				return string(env), true
+			}
+		}
+	}
+	return "", false
+}
+
+// EnvironmentForEndpoint maps a config's Endpoint (host:port) to its
+// environment ("prod", "nonprod", or a profile with registered gateways).
+// Numeric endpoints must match the issued or registered ones exactly.
+// Hostname endpoints match a registered hostname, or else are resolved and
+// accepted when any address lands on a known endpoint.
+func EnvironmentForEndpoint(endpoint string) (string, error) {
+	if env, ok := knownEndpoint(endpoint); ok {
+		return env, nil
+	}
+
+	host, port, err := net.SplitHostPort(endpoint)
+	if err != nil || net.ParseIP(host) != nil {
+		return "", fmt.Errorf("unknown endpoint %s", endpoint)
+	}
+
+	host = normalizeHost(host)
+	for _, env := range core.Environments() {
+		for _, known := range registeredHosts(string(env)) {
+			if known == host {
+				return string(env), nil
+			}
+		}
+	}
+
+	addrs, err := resolveHost(host)
+	if err != nil {
+		return "", fmt.Errorf("unknown endpoint %s (%v)", endpoint, err)
+	}
+	for _, addr := range addrs {
+		if env, ok := knownEndpoint(net.JoinHostPort(addr, port)); ok {
+			return env, nil
+		}
+	}
+	return "", fmt.Errorf("unknown endpoint %s (resolves to %s)", endpoint, strings.Join(addrs, ", "))
+}
+
+// EndpointHostname returns the gateway hostname behind a connected endpoint
+// (the ip:port reported by wg), or "" if none is known. Candidates are the
+// installed config's own Endpoint host and the registered hostnames of env;
+// one matches when it currently resolves to the endpoint's address. Lookup
+// failures simply yield "".
+func EndpointHostname(env, endpoint string) string {
+	ip, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return ""
+	}
+
+	candidates := registeredHosts(env)
+	if installed, err := NewConfigProcessor().InstalledEndpoint(env); err == nil {
+		if host, _, err := net.SplitHostPort(installed); err == nil && net.ParseIP(host) == nil {
+			candidates = append([]string{normalizeHost(host)}, candidates...)
+		}
+	}
+
+	for _, host := range candidates {
+		addrs, err := resolveHost(host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr == ip {
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+// gatewayKeys returns the PublicKeys of each environment's gateway: those
+// of the gateways in use and of the installed templates, which may name a
+// rotated key or a profile's gateway. Unreadable templates are skipped, and
+// so are JULO's keys once an organization's gateways replace JULO's: a JULO
+// template left installed would otherwise claim configs for them.
+func gatewayKeys() map[string][]string {
+	keys := map[string][]string{}
+	inUse := map[string]bool{}
+	add := func(env string, content []byte) {
+		for _, peer := range ParseWGConfig(string(content)).Peers() {
+			key := peer.Get("PublicKey")
+			if key == "" || slices.Contains(keys[env], key) {
+				continue
+			}
+			if juloKey(key) && !inUse[key] {
+				continue
+			}
+			keys[env] = append(keys[env], key)
+		}
+	}
+	for _, template := range builtinTemplates {
+		for _, peer := range ParseWGConfig(template.content).Peers() {
+			inUse[peer.Get("PublicKey")] = true
+		}
+		add(string(core.EnvironmentOf(template.name)), []byte(template.content))
+	}
+	for _, env := range core.Environments() {
+		if content, err := readIfInstalled(core.TemplateFile(env)); err == nil {
+			add(string(env), content)
+		}
+	}
+	return keys
+}
+
+// juloKey reports whether key is one of JULO's gateways' PublicKeys.
+func juloKey(key string) bool {
+	for _, content := range []string{prodTemplateContent, nonprodTemplateContent} {
+		for _, peer := range ParseWGConfig(content).Peers() {
+			if peer.Get("PublicKey") == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// EnvironmentForConfig tells which environment a config belongs to. A
+// peer's PublicKey identifies the gateway whatever its Endpoint is spelled
+// as; configs whose keys are all unknown are matched by Endpoint (see
+// EnvironmentForEndpoint) instead. The error lists what the config has and
+// what each environment expects.
+func EnvironmentForConfig(content string) (string, error) {
+	keys := gatewayKeys()
+	var found []string
+	for _, peer := range ParseWGConfig(content).Peers() {
+		key := peer.Get("PublicKey")
+		for _, env := range core.Environments() {
+			if key != "" && slices.Contains(keys[string(env)], key) {
+				return string(env), nil
+			}
+		}
+		found = append(found, fmt.Sprintf("PublicKey %s at %s", orNone(key), peerEndpoint(peer)))
+	}
+
+	endpoint, err := ParseEndpoint(content)
+	if err == nil {
+		var env string
+		if env, err = EnvironmentForEndpoint(endpoint); err == nil {
+			return env, nil
+		}
+	}
+	if len(found) == 0 {
+		found = append(found, "no [Peer]")
+	}
+
+	var expected []string
+	for _, env := range core.Environments() {
+		var want []string
+		if len(keys[string(env)]) > 0 {
+			want = append(want, "PublicKey "+strings.Join(keys[string(env)], " or "))
+		}
+		if endpoints := gatewayEndpoints(string(env)); len(endpoints) > 0 {
+			want = append(want, "Endpoint "+strings.Join(endpoints, " or "))
+		}
+		if len(want) > 0 {
+			expected = append(expected, fmt.Sprintf("%s (%s)", env, strings.Join(want, ", ")))
+		}
+	}
+	return "", fmt.Errorf("no peer matches a known gateway: found %s (%v); expected %s",
+		strings.Join(found, "; "), err, strings.Join(expected, " or "))
+}
+
+// gatewayEndpoints lists the endpoints and hostnames known for env.
+func gatewayEndpoints(env string) []string {
+	hostsMu.RLock()
+	var endpoints []string
+	for endpoint, known := range knownEndpoints {
+		if known == env {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	hostsMu.RUnlock()
+	sort.Strings(endpoints)
+	return append(endpoints, registeredHosts(env)...)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
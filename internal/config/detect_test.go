@@ -0,0 +1,209 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useResolver answers hostname lookups from hosts for the test, starting
+// from an empty cache.
+func useResolver(t *testing.T, hosts map[string][]string) {
+	t.Helper()
+	saved := LookupHost
+	LookupHost = func(_ context.Context, host string) ([]string, error) {
+		if addrs, ok := hosts[host]; ok {
+			return addrs, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	clearCache := func() {
+		resolveMu.Lock()
+		resolveCache = map[string]resolution{}
+		resolveMu.Unlock()
+	}
+	clearCache()
+	t.Cleanup(func() {
+		LookupHost = saved
+		clearCache()
+	})
+}
+
+// registerHost registers hostname as a gateway name of env for the test.
+func registerHost(t *testing.T, env, hostname string) {
+	t.Helper()
+	hostsMu.Lock()
+	saved := append([]string(nil), endpointHosts[env]...)
+	hostsMu.Unlock()
+	RegisterEndpointHost(env, hostname)
+	t.Cleanup(func() {
+		hostsMu.Lock()
+		endpointHosts[env] = saved
+		hostsMu.Unlock()
+	})
+}
+
+func TestEnvironmentForConfig(t *testing.T) {
+	useConfigDir(t)
+	useResolver(t, map[string][]string{
+		"vpn.julo.example":     {"34.101.166.184"},
+		"nonprod.julo.example": {"10.1.1.1", "34.128.85.147"},
+		"elsewhere.example":    {"198.51.100.1"},
+	})
+	registerHost(t, "prod", "Gateway-Prod.Julo.Example.")
+	prodKey, nonprodKey := Gateways()[0].PublicKey, Gateways()[1].PublicKey
+
+	tests := []struct {
+		name    string
+		config  string
+		want    string
+		wantErr []string
+	}{
+		{
+			name:   "prod key",
+			config: "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n\n[Peer]\nPublicKey = " + prodKey + "\nEndpoint = 34.101.166.184:51820\n",
+			want:   "prod",
+		},
+		{
+			name:   "key behind an unknown hostname",
+			config: "[Peer]\nPublicKey = " + nonprodKey + "\nEndpoint = elsewhere.example:51820\n",
+			want:   "nonprod",
+		},
+		{
+			name:   "key on a lowercase line with extra whitespace",
+			config: "[Peer]\n   publickey   =   " + nonprodKey + "   # rotated\n",
+			want:   "nonprod",
+		},
+		{
+			name:   "numeric endpoint with extra whitespace",
+			config: "[Peer]\nPublicKey = b3RoZXI=\n\tEndpoint\t=   34.128.85.147:51820  \n",
+			want:   "nonprod",
+		},
+		{
+			name:   "lowercase endpoint key",
+			config: "[Peer]\nPublicKey = b3RoZXI=\nendpoint = 34.101.166.184:51820\n",
+			want:   "prod",
+		},
+		{
+			name:   "registered hostname",
+			config: "[Peer]\nPublicKey = b3RoZXI=\nEndpoint = gateway-prod.julo.example:51820\n",
+			want:   "prod",
+		},
+		{
+			name:   "hostname resolving to a gateway",
+			config: "[Peer]\nPublicKey = b3RoZXI=\nEndpoint = nonprod.julo.example:51820\n",
+			want:   "nonprod",
+		},
+		{
+			name:    "hostname resolving elsewhere",
+			config:  "[Peer]\nPublicKey = b3RoZXI=\nEndpoint = elsewhere.example:51820\n",
+			wantErr: []string{"found PublicKey b3RoZXI= at elsewhere.example:51820", "resolves to 198.51.100.1", "expected prod (PublicKey " + prodKey, "Endpoint 34.101.166.184:51820 or gateway-prod.julo.example"},
+		},
+		{
+			name:    "unresolvable hostname",
+			config:  "[Peer]\nPublicKey = b3RoZXI=\nEndpoint = gone.example:51820\n",
+			wantErr: []string{"failed to resolve gone.example"},
+		},
+		{
+			name:    "no peer",
+			config:  "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n",
+			wantErr: []string{"found no [Peer]", "no Endpoint found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := EnvironmentForConfig(tt.config)
+			if tt.wantErr == nil {
+				if err != nil || env != tt.want {
+					t.Errorf("EnvironmentForConfig() = %q, %v; want %q", env, err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("EnvironmentForConfig() = %q, want an error", env)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not say %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+// KnownEnvironment never looks a hostname up.
+func TestKnownEnvironment(t *testing.T) {
+	useResolver(t, nil)
+	LookupHost = func(context.Context, string) ([]string, error) {
+		t.Fatal("looked a hostname up")
+		return nil, nil
+	}
+	registerHost(t, "nonprod", "gw-nonprod.julo.example")
+	tests := map[string]string{
+		"34.101.166.184:51820":          "prod",
+		"GW-NonProd.julo.example:51820": "nonprod",
+		"vpn.julo.example:51820":        "",
+		"not an endpoint":               "",
+	}
+	for endpoint, want := range tests {
+		if env, ok := KnownEnvironment(endpoint); env != want || ok != (want != "") {
+			t.Errorf("KnownEnvironment(%q) = %q, %v; want %q", endpoint, env, ok, want)
+		}
+	}
+}
+
+// useOrgGateways replaces JULO's gateways with gateways for the test.
+func useOrgGateways(t *testing.T, gateways []Gateway) {
+	t.Helper()
+	savedTemplates := builtinTemplates
+	hostsMu.Lock()
+	savedEndpoints := maps.Clone(knownEndpoints)
+	hostsMu.Unlock()
+	SetGateways(gateways)
+	t.Cleanup(func() {
+		builtinTemplates = savedTemplates
+		hostsMu.Lock()
+		knownEndpoints = savedEndpoints
+		hostsMu.Unlock()
+	})
+}
+
+// JULO's keys identify configs only while JULO's gateways are in use, even
+// with JULO's templates still installed.
+func TestEnvironmentForConfigOrgGateways(t *testing.T) {
+	dir := useConfigDir(t)
+	useResolver(t, nil)
+	juloProd := Gateways()[0]
+	for _, template := range builtinTemplates {
+		if err := os.WriteFile(filepath.Join(dir, template.name), []byte(template.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	config := "[Peer]\nPublicKey = " + juloProd.PublicKey + "\nEndpoint = 198.51.100.9:51820\n"
+	if env, err := EnvironmentForConfig(config); err != nil || env != "prod" {
+		t.Fatalf("EnvironmentForConfig() with JULO's gateways = %q, %v; want prod", env, err)
+	}
+
+	orgKey := "b3JnLXByb2QtZ2F0ZXdheS1wdWJsaWMta2V5LTAwMDA="
+	useOrgGateways(t, []Gateway{
+		{Environment: "prod", Label: "production", Endpoint: "203.0.113.10:51820", PublicKey: orgKey, AllowedIPs: []string{"10.50.0.0/16"}},
+		{Environment: "nonprod", Label: "non-production", Endpoint: "203.0.113.11:51820", PublicKey: "b3JnLW5vbnByb2QtZ2F0ZXdheS1wdWJsaWMta2V5LTA=", AllowedIPs: []string{"10.60.0.0/16"}},
+	})
+	if env, err := EnvironmentForConfig(config); err == nil {
+		t.Errorf("EnvironmentForConfig() of a JULO config under an org's gateways = %q", env)
+	}
+	org := "[Peer]\nPublicKey = " + orgKey + "\nEndpoint = 198.51.100.9:51820\n"
+	if env, err := EnvironmentForConfig(org); err != nil || env != "prod" {
+		t.Errorf("EnvironmentForConfig() of the org's config = %q, %v; want prod", env, err)
+	}
+
+	// An org config keeping JULO's gateway trusts its key
+	useOrgGateways(t, []Gateway{juloProd, Gateways()[1]})
+	if env, err := EnvironmentForConfig(config); err != nil || env != "prod" {
+		t.Errorf("EnvironmentForConfig() with JULO's gateway configured = %q, %v; want prod", env, err)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Hook is a wg-quick directive that runs a shell command on the host when
+// the tunnel goes up or down.
+type Hook struct {
+	Key     string // PreUp, PostUp, PreDown or PostDown
+	Command string
+}
+
+func (h Hook) String() string {
+	return h.Key + " = " + h.Command
+}
+
+// Directives are the wg-quick settings of a config that need attention
+// before it is installed.
+type Directives struct {
+	// SaveConfig makes wg-quick rewrite the config on shutdown, which would
+	// overwrite the managed file; it is always removed on merge.
+	SaveConfig bool
+	Hooks      []Hook
+}
+
+var hookKeys = []string{"PreUp", "PostUp", "PreDown", "PostDown"}
+
+// stripHooks is the policy for new ConfigProcessors; see SetStripHooks.
+var stripHooks bool
+
+// SetStripHooks sets whether configs merged from now on lose their hook
+// scripts instead of installing them, for machines where issued configs
+// must never run shell commands.
+func SetStripHooks(strip bool) {
+	stripHooks = strip
+}
+
+// directiveKey returns the key of a "Key = value" line in wg-quick's
+// spelling when it is SaveConfig or a hook, and "" otherwise. wg-quick
+// matches keys case-insensitively.
+func directiveKey(line string) (key, value string) {
+	k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+	if !ok {
+		return "", ""
+	}
+	k = strings.TrimSpace(k)
+	for _, known := range append([]string{"SaveConfig"}, hookKeys...) {
+		if strings.EqualFold(k, known) {
+			return known, strings.TrimSpace(v)
+		}
+	}
+	return "", ""
+}
+
+// ParseDirectives finds SaveConfig and the hook scripts in a config.
+func ParseDirectives(content string) Directives {
+	var d Directives
+	for _, line := range strings.Split(content, "\n") {
+		switch key, value := directiveKey(line); key {
+		case "":
+		case "SaveConfig":
+			d.SaveConfig = d.SaveConfig || strings.EqualFold(value, "true")
+		default:
+			d.Hooks = append(d.Hooks, Hook{Key: key, Command: value})
+		}
+	}
+	return d
+}
+
+// InspectConfig reads a user config and reports its directives, so callers
+// can show hook scripts for acknowledgment before installing it.
+func InspectConfig(path string) (Directives, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return Directives{}, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return ParseDirectives(string(content)), nil
+}
+
+// filterDirective decides whether a user config line is kept by the merge,
+// recording a warning for every line it drops.
+func (cp *ConfigProcessor) filterDirective(line string) bool {
+	key, value := directiveKey(line)
+	switch {
+	case key == "SaveConfig":
+		cp.Warnings = append(cp.Warnings, "removed SaveConfig: wg-quick would rewrite the managed config on shutdown")
+		return false
+	case key != "" && cp.StripHooks:
+		cp.Warnings = append(cp.Warnings, fmt.Sprintf("removed %s = %s (hook scripts are disabled by policy)", key, value))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+)
+
+const (
+	BackupDir = "backups"
+
+	backupTimeFormat = "2006-01-02T15-04-05"
+	// backupStampFormat names backups to the nanosecond, so two writes in
+	// the same second each keep their own. backupTimeFormat still parses
+	// it, fraction included, and the names of older backups.
+	backupStampFormat = backupTimeFormat + ".000000000"
+)
+
+// ParseEndpoint returns the Endpoint value (host:port) of a config. Comments
+// and other keys merely containing "Endpoint" are skipped, and a value that
+// is not a host with a valid port is an error rather than something to
+// connect to.
+func ParseEndpoint(content string) (string, error) {
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), "Endpoint") {
+			continue
+		}
+		endpoint := strings.TrimSpace(value)
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return "", fmt.Errorf("invalid Endpoint %q: %v", endpoint, err)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || host == "" {
+			return "", fmt.Errorf("invalid Endpoint %q: want host:port", endpoint)
+		}
+		return endpoint, nil
+	}
+	return "", fmt.Errorf("no Endpoint found in config file")
+}
+
+// InstalledEndpoint returns the Endpoint value (host:port) of the installed
+// config for the given environment.
+func (cp *ConfigProcessor) InstalledEndpoint(env string) (string, error) {
+	return cp.extractEndpoint(core.InstalledPath(core.ConfigFile(core.Environment(env))))
+}
+
+// UpdateEndpoint rewrites the Endpoint of the gateway's peer in the
+// installed config for the given environment, keeping a timestamped backup
+// of the previous file. Other peers keep theirs. The config is read and
+// written under one hold of its lock.
+func (cp *ConfigProcessor) UpdateEndpoint(env, endpoint string) error {
+	configPath := core.InstalledPath(core.ConfigFile(core.Environment(env)))
+	return privileged(audit.ActionConfigWrite, configPath, func() error {
+		return cp.updateEndpoint(env, configPath, endpoint)
+	})
+}
+
+func (cp *ConfigProcessor) updateEndpoint(env, configPath, endpoint string) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %v", configPath, err)
+	}
+
+	config := ParseWGConfig(string(content))
+	peer, err := gatewayPeer(config, env)
+	if err != nil {
+		return fmt.Errorf("%s: %v", configPath, err)
+	}
+	peer.Set("Endpoint", endpoint)
+
+	if _, err := cp.backupFile(configPath); err != nil {
+		return fmt.Errorf("failed to back up %s: %v", configPath, err)
+	}
+
+	return writeFile(configPath, config.Marshal())
+}
+
+// gatewayPeer returns the peer of config that is env's gateway: the one with
+// the gateway's PublicKey, or the only peer with an Endpoint when none has
+// it (an environment without a gateway, or a key the org config doesn't
+// know yet).
+func gatewayPeer(config *WGConfig, env string) (*WGSection, error) {
+	var withEndpoint []*WGSection
+	key := gatewayOf(env, "", builtinTemplateFor(env)).PublicKey
+	for _, peer := range config.Peers() {
+		if key != "" && peer.Get("PublicKey") == key {
+			return peer, nil
+		}
+		if peer.Get("Endpoint") != "" {
+			withEndpoint = append(withEndpoint, peer)
+		}
+	}
+	switch len(withEndpoint) {
+	case 0:
+		return nil, fmt.Errorf("no Endpoint found")
+	case 1:
+		return withEndpoint[0], nil
+	}
+	return nil, fmt.Errorf("none of the %d peers is the %s gateway", len(withEndpoint), core.Environment(env).DisplayName())
+}
+
+// backupFile copies path into the backups directory next to it, suffixed with
+// the current time, and returns the backup's path.
+func (cp *ConfigProcessor) backupFile(path string) (string, error) {
+	backupDir := filepath.Join(filepath.Dir(path), BackupDir)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+
+	source, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+
+	backupPath, target, err := createBackup(backupDir, filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		return "", err
+	}
+	if err := target.Close(); err != nil {
+		return "", err
+	}
+	pruneBackups(filepath.Base(path))
+	return backupPath, nil
+}
+
+// createBackup creates a new, empty backup of name in dir. An existing
+// backup is never opened: when the clock gives a time already taken, the
+// next nanosecond is tried.
+func createBackup(dir, name string) (string, *os.File, error) {
+	taken := time.Now()
+	for {
+		path := filepath.Join(dir, name+"."+taken.Format(backupStampFormat))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0600)
+		if !os.IsExist(err) {
+			return path, file, err
+		}
+		taken = taken.Add(time.Nanosecond)
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// useConfigDir points core.ConfigDir at a fresh directory for the test,
+// and the audit log privileged writes go to at another.
+func useConfigDir(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	saved := core.ConfigDir
+	core.SetConfigDir(dir)
+	t.Cleanup(func() { core.SetConfigDir(saved) })
+	return dir
+}
+
+func TestUpdateEndpointRewritesTheGatewayPeerOnly(t *testing.T) {
+	prodKey := Gateways()[0].PublicKey
+	tests := []struct {
+		name    string
+		config  string
+		want    []string // the peers' endpoints afterwards, in order
+		wantErr string
+	}{
+		{
+			name: "gateway after a backup peer",
+			config: "[Interface]\nPrivateKey = cHJpdmF0ZQ==\nAddress = 10.9.0.2/32\n\n" +
+				"[Peer]\n# backup gateway\nPublicKey = YmFja3Vw\nendpoint = 198.51.100.9:51820\n\n" +
+				"[Peer]\nPublicKey = " + prodKey + "\nEndpoint = 34.101.166.184:51820\n",
+			want: []string{"198.51.100.9:51820", "34.101.166.200:51820"},
+		},
+		{
+			name:   "single peer with a lowercase key",
+			config: "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n\n[Peer]\nPublicKey = b3RoZXI=\nendpoint = 34.101.166.184:51820\n",
+			want:   []string{"34.101.166.200:51820"},
+		},
+		{
+			name: "several peers, none the gateway",
+			config: "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n\n[Peer]\nPublicKey = YQ==\nEndpoint = 198.51.100.1:51820\n\n" +
+				"[Peer]\nPublicKey = Yg==\nEndpoint = 198.51.100.2:51820\n",
+			wantErr: "none of the 2 peers",
+		},
+		{
+			name:    "no endpoint",
+			config:  "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n\n[Peer]\nPublicKey = YQ==\n",
+			wantErr: "no Endpoint found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(useConfigDir(t), core.ProdConfig)
+			if err := os.WriteFile(path, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			err := NewConfigProcessor().updateEndpoint("prod", path, "34.101.166.200:51820")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("updateEndpoint() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, peer := range ParseWGConfig(string(content)).Peers() {
+				got = append(got, peer.Get("Endpoint"))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("endpoints = %v, want %v\n%s", got, tt.want, content)
+			}
+			if backups, _ := Backups(core.ProdConfig); len(backups) != 1 {
+				t.Errorf("%d backups, want 1", len(backups))
+			}
+		})
+	}
+}
+
+func FuzzParseEndpoint(f *testing.F) {
+	f.Fuzz(func(t *testing.T, content string) {
+		endpoint, err := ParseEndpoint(content)
+		if err != nil {
+			return
+		}
+		// What came out is a valid Endpoint by itself
+		host, port, err := net.SplitHostPort(endpoint)
+		if n, perr := strconv.Atoi(port); err != nil || host == "" || perr != nil || n < 1 || n > 65535 {
+			t.Errorf("ParseEndpoint(%q) = %q", content, endpoint)
+		}
+		if again, err := ParseEndpoint("Endpoint = " + endpoint); err != nil || again != endpoint {
+			t.Errorf("ParseEndpoint(%q) = %q, but reads back as %q, %v", content, endpoint, again, err)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/paths"
+)
+
+// placeholder marks a per-device value, the same way the embedded templates
+// do.
+const placeholder = "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx"
+
+// DeviceExport describes a device template written by ExportDeviceTemplate;
+// see core.DeviceExport.
+type DeviceExport = core.DeviceExport
+
+// ExportDeviceTemplate writes env's installed config for use on another
+// device, e.g. a tablet: infra allows one key per device, so PrivateKey,
+// Address and PresharedKey become placeholders and everything else is kept.
+// With newKey a fresh keypair fills in PrivateKey instead. The export goes
+// to the user's config directory; the installed config is only read.
+func (cp *ConfigProcessor) ExportDeviceTemplate(env string, newKey bool) (*DeviceExport, error) {
+	content, err := ReadInstalled(core.ConfigFile(core.Environment(env)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installed config: %v", err)
+	}
+
+	result := &DeviceExport{Environment: env}
+	privateKey := placeholder
+	if newKey {
+		if privateKey, result.PublicKey, err = GenerateKeyPair(); err != nil {
+			return nil, err
+		}
+	}
+
+	export := deviceTemplate(string(content), privateKey, result.PublicKey)
+	if findings := LintTemplate(export); len(findings) > 0 {
+		return nil, fmt.Errorf("export does not pass the linter: %s", findings[0])
+	}
+
+	name := fmt.Sprintf("%s-device-%s.conf", strings.TrimSuffix(core.ConfigFile(core.Environment(env)), ".conf"), time.Now().Format("20060102-150405"))
+	result.Path, err = paths.EnsureFile(paths.Config, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := cp.writePrivateFile(result.Path, export); err != nil {
+		return nil, fmt.Errorf("failed to write export: %v", err)
+	}
+	return result, nil
+}
+
+// deviceTemplate rebuilds config with the per-device values replaced and a
+// header saying what is left to fill in. Comments are dropped, as they may
+// be about this device, and key lines are written as "Key = value" so the
+// export lints clean.
+func deviceTemplate(config, privateKey, publicKey string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Device template exported by tui-wireguard-vpn on %s\n", time.Now().Format("2006-01-02"))
+	b.WriteString("# Values of xxxx... are placeholders to fill in for the new device:\n")
+	if publicKey != "" {
+		fmt.Fprintf(&b, "#   PrivateKey is already filled in; register its public key %s with infra\n", publicKey)
+	} else {
+		b.WriteString("#   PrivateKey   - generate one on the device (wg genkey) and register its public key with infra\n")
+	}
+	b.WriteString("#   Address      - the tunnel address infra assigns to the new device\n")
+	if strings.Contains(strings.ToLower(config), "presharedkey") {
+		b.WriteString("#   PresharedKey - the preshared key infra issues with that registration\n")
+	}
+	b.WriteString("# Keep this file private once the PrivateKey is filled in.\n")
+
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(config))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = line
+			b.WriteString("\n" + line + "\n")
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch {
+		case section == "[Interface]" && strings.EqualFold(key, "PrivateKey"):
+			value = privateKey
+		case section == "[Interface]" && strings.EqualFold(key, "Address"):
+			value = placeholder
+		case strings.EqualFold(key, "PresharedKey"):
+			value = placeholder
+		}
+		fmt.Fprintf(&b, "%s = %s\n", key, value)
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// Gateway is an environment's WireGuard server: what recognizes the configs
+// issued for it and what its template gives them.
+type Gateway struct {
+	Environment string // "prod", "nonprod" or a profile
+	Label       string // for messages, e.g. "production"
+	Endpoint    string // host:port
+	PublicKey   string
+	DNS         []string
+	AllowedIPs  []string
+	// MTU and PersistentKeepalive go into the template when set
+	MTU                 int
+	PersistentKeepalive int
+}
+
+// templatePlaceholder stands in for the values of the user's own config.
+var templatePlaceholder = strings.Repeat("x", 40)
+
+// Template renders the gateway's template in the layout of the built-in
+// ones: placeholder PrivateKey and Address, which the user's config has.
+func (g Gateway) Template() string {
+	var b strings.Builder
+	b.WriteString("[Interface]\n")
+	fmt.Fprintf(&b, "PrivateKey = %s\n", templatePlaceholder)
+	fmt.Fprintf(&b, "Address = %s\n", templatePlaceholder)
+	fmt.Fprintf(&b, "DNS = %s\n", strings.Join(g.DNS, ", "))
+	if g.MTU > 0 {
+		fmt.Fprintf(&b, "MTU = %d\n", g.MTU)
+	}
+	b.WriteString("\n[Peer]\n")
+	fmt.Fprintf(&b, "Endpoint = %s\n", g.Endpoint)
+	fmt.Fprintf(&b, "PublicKey = %s\n", g.PublicKey)
+	fmt.Fprintf(&b, "AllowedIPs = %s\n", strings.Join(g.AllowedIPs, ", "))
+	if g.PersistentKeepalive > 0 {
+		fmt.Fprintf(&b, "PersistentKeepalive = %d\n", g.PersistentKeepalive)
+	}
+	return b.String()
+}
+
+// gatewayOf reads a gateway back from a template.
+func gatewayOf(env, label, template string) Gateway {
+	g := Gateway{Environment: env, Label: label}
+	config := ParseWGConfig(template)
+	if iface := config.Interface(); iface != nil {
+		g.DNS = iface.List("DNS")
+		g.MTU, _ = strconv.Atoi(iface.Get("MTU"))
+	}
+	if peers := config.Peers(); len(peers) > 0 {
+		g.Endpoint = peers[0].Get("Endpoint")
+		g.PublicKey = peers[0].Get("PublicKey")
+		g.AllowedIPs = peers[0].List("AllowedIPs")
+		g.PersistentKeepalive, _ = strconv.Atoi(peers[0].Get("PersistentKeepalive"))
+	}
+	return g
+}
+
+// Gateways returns the gateways in use: an organization's when SetGateways
+// was called, JULO's otherwise.
+func Gateways() []Gateway {
+	var gateways []Gateway
+	for _, template := range builtinTemplates {
+		gateways = append(gateways, gatewayOf(string(core.EnvironmentOf(template.name)), template.label, template.content))
+	}
+	return gateways
+}
+
+// SetGateways replaces the built-in JULO gateways with an organization's:
+// configs are recognized by their endpoints and keys, and setup installs
+// templates made from them. It is called at startup, before the profiles'
+// endpoints are registered and anything is installed; environments besides
+// prod and nonprod must have been added already.
+func SetGateways(gateways []Gateway) {
+	hostsMu.Lock()
+	for _, g := range gateways {
+		for endpoint, env := range knownEndpoints {
+			if env == g.Environment {
+				delete(knownEndpoints, endpoint)
+			}
+		}
+	}
+	for _, g := range gateways {
+		knownEndpoints[g.Endpoint] = g.Environment
+	}
+	hostsMu.Unlock()
+
+	builtinTemplates = builtinTemplates[:0:0]
+	for _, g := range gateways {
+		if host, _, err := net.SplitHostPort(g.Endpoint); err == nil && net.ParseIP(host) == nil {
+			RegisterEndpointHost(g.Environment, host)
+		}
+		builtinTemplates = append(builtinTemplates, builtinTemplate{
+			name:    core.TemplateFile(core.Environment(g.Environment)),
+			label:   g.Label,
+			content: g.Template(),
+		})
+	}
+}
+
+// builtinTemplateFor returns the template setup installs for env, "" when it
+// has none.
+func builtinTemplateFor(env string) string {
+	for _, template := range builtinTemplates {
+		if template.name == core.TemplateFile(core.Environment(env)) {
+			return template.content
+		}
+	}
+	return ""
+}
+
+// SampleOrgConfig is a commented organization config describing the
+// gateways in use, for "generate-org-config".
+func SampleOrgConfig() string {
+	var b strings.Builder
+	b.WriteString(`# Organization config for tui-wireguard-vpn: the WireGuard gateways the
+# app manages configs for. Place it at /etc/tui-wireguard-vpn/org.toml for
+# every user of the machine, or at ~/.config/tui-wireguard-vpn/org.toml.
+#
+# Each [environments.<name>] section describes one gateway. prod and nonprod
+# are required; other names add profiles (up to 10 lowercase letters, digits
+# and dashes), installed as julo-<name>.conf.
+#
+# Configs are recognized by the gateway's public_key, or failing that by
+# endpoint. Setup installs a template per environment made from these
+# values, and every config installed gets its dns and allowed_ips.
+# Without this file the built-in JULO gateways below apply.
+`)
+	for _, g := range Gateways() {
+		fmt.Fprintf(&b, "\n[environments.%s]\n", g.Environment)
+		b.WriteString("# Name shown in the menu and status panel\n")
+		fmt.Fprintf(&b, "name = %q\n", core.Environment(g.Environment).DisplayName())
+		b.WriteString("# The gateway (host:port); a DNS name is fine\n")
+		fmt.Fprintf(&b, "endpoint = %q\n", g.Endpoint)
+		b.WriteString("# The gateway's WireGuard public key\n")
+		fmt.Fprintf(&b, "public_key = %q\n", g.PublicKey)
+		fmt.Fprintf(&b, "dns = %s\n", tomlList(g.DNS))
+		fmt.Fprintf(&b, "allowed_ips = %s\n", tomlList(g.AllowedIPs))
+		b.WriteString("# Optional\n")
+		fmt.Fprintf(&b, "mtu = %d\n", g.MTU)
+		fmt.Fprintf(&b, "persistent_keepalive = %d\n", g.PersistentKeepalive)
+	}
+	return b.String()
+}
+
+// tomlList renders items as an array, one item per line when there are
+// more than a few.
+func tomlList(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = strconv.Quote(item)
+	}
+	if len(items) <= 3 {
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return "[\n  " + strings.Join(quoted, ",\n  ") + ",\n]"
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+)
+
+// GeneratedConfig describes a client config created by
+// GenerateClientConfig; see core.GeneratedConfig.
+type GeneratedConfig = core.GeneratedConfig
+
+// GenerateKeyPair creates a WireGuard (Curve25519) keypair, base64 encoded
+// the same way as `wg genkey | wg pubkey`.
+func GenerateKeyPair() (privateKey, publicKey string, err error) {
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", "", fmt.Errorf("failed to generate private key: %v", err)
+	}
+	// Clamp like wg genkey does
+	raw[0] &= 248
+	raw[31] = (raw[31] & 127) | 64
+
+	key, err := ecdh.X25519().NewPrivateKey(raw[:])
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate private key: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(key.Bytes()),
+		base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+// ValidateAddress checks that address is an interface address in CIDR form
+// (for example 10.80.12.34/32).
+func ValidateAddress(address string) error {
+	for _, part := range strings.Split(address, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return fmt.Errorf("address is required")
+		}
+		if _, err := netip.ParsePrefix(part); err != nil {
+			return fmt.Errorf("%q is not a valid CIDR address (expected e.g. 10.80.12.34/32)", part)
+		}
+	}
+	return nil
+}
+
+// GenerateClientConfig writes a brand-new config for env using a freshly
+// generated keypair, the given Address, and the environment template's
+// peer, DNS and AllowedIPs settings. The tunnel only works once infra has
+// registered the returned public key.
+func (cp *ConfigProcessor) GenerateClientConfig(env, address string) (*GeneratedConfig, error) {
+	if err := ValidateAddress(address); err != nil {
+		return nil, err
+	}
+
+	template, err := cp.templateContent(env)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, publicKey, err := GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+
+	outputPath := core.InstalledPath(core.ConfigFile(core.Environment(env)))
+	result := &GeneratedConfig{
+		Environment: env,
+		Path:        outputPath,
+		PublicKey:   publicKey,
+	}
+
+	if _, err := os.Stat(outputPath); err == nil {
+		backupPath, err := cp.backupFile(outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to back up existing config: %v", err)
+		}
+		result.BackupPath = backupPath
+	}
+
+	content := buildClientConfig(template, privateKey, publicKey, address)
+	if err := privileged(audit.ActionKeyRotation, outputPath, func() error {
+		return cp.writePrivateFile(outputPath, content)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to write config (try running with sudo): %v", err)
+	}
+	return result, nil
+}
+
+// templateContent returns the installed template for env, falling back to
+// the built-in one (see SetGateways).
+func (cp *ConfigProcessor) templateContent(env string) (string, error) {
+	embedded := builtinTemplateFor(env)
+	if embedded == "" {
+		return "", fmt.Errorf("unknown environment %q", env)
+	}
+
+	content, err := os.ReadFile(core.InstalledPath(core.TemplateFile(core.Environment(env))))
+	if err != nil {
+		return embedded, nil
+	}
+	return string(content), nil
+}
+
+// buildClientConfig combines the template's settings with the new key and
+// address, dropping the template's placeholder values.
+func buildClientConfig(template, privateKey, publicKey, address string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Generated by tui-wireguard-vpn on %s\n", time.Now().Format("2006-01-02"))
+	fmt.Fprintf(&b, "# Client public key: %s\n", publicKey)
+	b.WriteString("# The tunnel will not work until infra has registered the public key.\n")
+
+	section := ""
+	scanner := bufio.NewScanner(strings.NewReader(template))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			section = line
+			b.WriteString("\n" + line + "\n")
+			if section == "[Interface]" {
+				fmt.Fprintf(&b, "PrivateKey = %s\n", privateKey)
+				fmt.Fprintf(&b, "Address = %s\n", address)
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if isPlaceholder(value) {
+			continue
+		}
+		if section == "[Interface]" && (key == "PrivateKey" || key == "Address") {
+			continue
+		}
+		fmt.Fprintf(&b, "%s = %s\n", key, value)
+	}
+	return b.String()
+}
+
+// isPlaceholder reports whether a template value is one of the "xxxx..."
+// placeholders the embedded templates use for per-user values.
+func isPlaceholder(value string) bool {
+	return len(value) > 0 && strings.Trim(value, "x") == ""
+}
+
+// writePrivateFile writes content to path readable by its owner only,
+// atomically; see writeFile.
+func (cp *ConfigProcessor) writePrivateFile(path, content string) error {
+	return writeFile(path, content)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+)
+
+// InstalledFiles lists the files this app manages under core.ConfigDir:
+// the template of every environment, then its config. Not all of them need
+// be installed.
+func InstalledFiles() []string {
+	var templates, configs []string
+	for _, env := range core.Environments() {
+		templates = append(templates, core.TemplateFile(env))
+		configs = append(configs, core.ConfigFile(env))
+	}
+	return append(templates, configs...)
+}
+
+// ReadInstalled returns the raw contents of a managed file, using sudo
+// (without prompting) when core.ConfigDir isn't readable directly.
+func ReadInstalled(name string) ([]byte, error) {
+	path := core.InstalledPath(name)
+	content, err := os.ReadFile(path)
+	if err == nil || !os.IsPermission(err) {
+		return content, err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sudo", "-n", "cat", path)
+	cmd.Stderr = &stderr
+	content, err = cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return content, nil
+}
+
+// InstalledRoutes returns the AllowedIPs entries of env's installed config.
+func InstalledRoutes(env string) ([]string, error) {
+	content, err := ReadInstalled(core.ConfigFile(core.Environment(env)))
+	if err != nil {
+		return nil, err
+	}
+	var routes []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if key, value, ok := strings.Cut(line, "="); ok && strings.TrimSpace(key) == "AllowedIPs" {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					routes = append(routes, item)
+				}
+			}
+		}
+	}
+	return routes, nil
+}
+
+// ValidateInstalled checks that content is fit to be installed as the
+// managed file name: a WireGuard config for the right environment, and for
+// client configs, one carrying a PrivateKey.
+func ValidateInstalled(name, content string) error {
+	env := core.EnvironmentOf(name)
+	if env == "" {
+		return fmt.Errorf("%s is not a file this app manages", name)
+	}
+	if err := validateConfigFor(string(env), content); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if name == core.ConfigFile(env) && !strings.Contains(content, "PrivateKey") {
+		return fmt.Errorf("%s: no PrivateKey", name)
+	}
+	return nil
+}
+
+// RestoreInstalled validates content as the managed file name and writes
+// it into core.ConfigDir, backing up the file it replaces.
+func (cp *ConfigProcessor) RestoreInstalled(name, content string) error {
+	if err := ValidateInstalled(name, content); err != nil {
+		return err
+	}
+
+	path := core.InstalledPath(name)
+	if _, err := os.Stat(path); err == nil {
+		if _, err := cp.backupFile(path); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", path, err)
+		}
+	}
+	return privileged(audit.ActionRollback, path, func() error {
+		return cp.writePrivateFile(path, content)
+	})
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+func TestInstalledFilesCoverAddedProfiles(t *testing.T) {
+	env, err := core.AddEnvironment("staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := InstalledFiles()
+	for _, want := range []string{core.ProdTemplate, core.NonProdTemplate, core.ProdConfig, core.NonProdConfig, core.TemplateFile(env), core.ConfigFile(env)} {
+		if !slices.Contains(files, want) {
+			t.Errorf("InstalledFiles() = %v, missing %s", files, want)
+		}
+	}
+	// Templates come first, so a restore installs them before the configs
+	if last := slices.Index(files, core.TemplateFile(env)); last > slices.Index(files, core.ProdConfig) {
+		t.Errorf("InstalledFiles() = %v, want every template before the configs", files)
+	}
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Finding is a problem LintTemplate found in a config or template.
+type Finding struct {
+	Line    int
+	Message string
+}
+
+func (f Finding) String() string {
+	if f.Line == 0 {
+		return f.Message
+	}
+	return fmt.Sprintf("line %d: %s", f.Line, f.Message)
+}
+
+var requiredKeys = map[string][]string{
+	"Interface": {"PrivateKey", "Address"},
+	"Peer":      {"PublicKey", "Endpoint", "AllowedIPs"},
+}
+
+// LintTemplate flags the known bad patterns in WireGuard templates: leading
+// whitespace before keys, whitespace anomalies around '=', duplicate keys,
+// missing required keys, AllowedIPs with host bits set, and duplicate
+// AllowedIPs entries.
+func LintTemplate(content string) []Finding {
+	var findings []Finding
+
+	section := ""
+	sectionLine := 0
+	seen := map[string]int{}
+
+	closeSection := func() {
+		if section == "" {
+			return
+		}
+		for _, key := range requiredKeys[section] {
+			if _, ok := seen[key]; !ok {
+				findings = append(findings, Finding{Line: sectionLine, Message: fmt.Sprintf("[%s] is missing required key %s", section, key)})
+			}
+		}
+	}
+
+	lineNo := 0
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		lineNo++
+		raw := scanner.Text()
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			closeSection()
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			sectionLine = lineNo
+			seen = map[string]int{}
+			continue
+		}
+
+		parts := strings.SplitN(raw, "=", 2)
+		if len(parts) != 2 {
+			findings = append(findings, Finding{Line: lineNo, Message: "line is not a key = value pair"})
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if strings.TrimLeft(raw, " \t") != raw {
+			findings = append(findings, Finding{Line: lineNo, Message: fmt.Sprintf("leading whitespace before key %s", key)})
+		}
+		if strings.TrimLeft(parts[0], " \t") != key+" " || parts[1] != " "+value {
+			findings = append(findings, Finding{Line: lineNo, Message: fmt.Sprintf("unusual whitespace around '=' for %s (expected \"%s = value\")", key, key)})
+		}
+
+		if first, ok := seen[key]; ok && key != "AllowedIPs" && key != "DNS" && key != "Address" {
+			findings = append(findings, Finding{Line: lineNo, Message: fmt.Sprintf("duplicate key %s (first set on line %d)", key, first)})
+		} else if !ok {
+			seen[key] = lineNo
+		}
+
+		if key == "AllowedIPs" {
+			findings = append(findings, lintAllowedIPs(lineNo, value)...)
+		}
+	}
+	closeSection()
+
+	for _, name := range []string{"Interface", "Peer"} {
+		if !strings.Contains(content, "["+name+"]") {
+			findings = append(findings, Finding{Message: fmt.Sprintf("missing [%s] section", name)})
+		}
+	}
+
+	return findings
+}
+
+func lintAllowedIPs(lineNo int, value string) []Finding {
+	var findings []Finding
+	seen := map[netip.Prefix]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			findings = append(findings, Finding{Line: lineNo, Message: fmt.Sprintf("invalid CIDR %s in AllowedIPs", entry)})
+			continue
+		}
+		masked := prefix.Masked()
+		if masked != prefix {
+			findings = append(findings, Finding{Line: lineNo, Message: fmt.Sprintf("CIDR %s has host bits set (should be %s)", entry, masked)})
+		}
+		if previous, ok := seen[masked]; ok {
+			findings = append(findings, Finding{Line: lineNo, Message: fmt.Sprintf("duplicate CIDR %s in AllowedIPs (same as %s)", entry, previous)})
+			continue
+		}
+		seen[masked] = entry
+	}
+	return findings
+}
+
+// NormalizeTemplate rewrites content into canonical form: no leading
+// whitespace, exactly "key = value", masked AllowedIPs with duplicates
+// removed. Comments, blank lines and key order are preserved.
+func NormalizeTemplate(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, raw := range lines {
+		trimmed := strings.TrimSpace(raw)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "[") {
+			lines[i] = trimmed
+			continue
+		}
+		parts := strings.SplitN(trimmed, "=", 2)
+		if len(parts) != 2 {
+			lines[i] = trimmed
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+		if key == "AllowedIPs" {
+			value = normalizeAllowedIPs(value)
+		}
+		lines[i] = key + " = " + value
+	}
+	return strings.Join(lines, "\n")
+}
+
+func normalizeAllowedIPs(value string) string {
+	var entries []string
+	seen := map[netip.Prefix]bool{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			// Leave anything unparseable for the user to fix
+			entries = append(entries, entry)
+			continue
+		}
+		masked := prefix.Masked()
+		if seen[masked] {
+			continue
+		}
+		seen[masked] = true
+		entries = append(entries, masked.String())
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockTimeout is how long a write waits for another process writing the
+// same file (the TUI and a cron-driven update-config, say) before giving up.
+var LockTimeout = 10 * time.Second
+
+// lockPoll is how often a waiting write tries the lock again.
+const lockPoll = 100 * time.Millisecond
+
+// LockedError is a write that gave up waiting for another one.
+type LockedError struct {
+	Path string
+	PID  int // holder of the lock, 0 if unknown
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("another update of %s is in progress", filepath.Base(e.Path))
+	}
+	return fmt.Sprintf("another update of %s is in progress (pid %d)", filepath.Base(e.Path), e.PID)
+}
+
+// lockPath is the lock file guarding path: a hidden file next to it.
+func lockPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".lock")
+}
+
+// lockFile takes the advisory lock on path, waiting up to LockTimeout, and
+// returns the function releasing it. The holder's pid is written into the
+// lock file so a write that gives up can say who it waited for. The lock
+// file itself stays behind; it is empty of meaning once unlocked.
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(lockPath(path), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	deadline := time.Now().Add(LockTimeout)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %v", path, err)
+		}
+		if locked {
+			break
+		}
+		if time.Now().After(deadline) {
+			content, _ := os.ReadFile(lockPath(path))
+			pid, _ := strconv.Atoi(strings.TrimSpace(string(content)))
+			file.Close()
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		time.Sleep(lockPoll)
+	}
+
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return func() {
+		file.Truncate(0)
+		unlock(file)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,151 @@
+//go:build !windows
+
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+)
+
+// The tests below run this test binary again as the competing process:
+// LOCK_TEST_WRITER appends lines to a file, LOCK_TEST_HOLDER holds its lock.
+func TestMain(m *testing.M) {
+	if path := os.Getenv("LOCK_TEST_WRITER"); path != "" {
+		os.Exit(appendLines(path, os.Getenv("LOCK_TEST_NAME"), 200))
+	}
+	if path := os.Getenv("LOCK_TEST_HOLDER"); path != "" {
+		unlock, err := lockFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("locked")
+		hold, _ := time.ParseDuration(os.Getenv("LOCK_TEST_HOLD"))
+		time.Sleep(hold)
+		unlock()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// appendLines adds n lines to path, each a read-modify-write of the whole
+// file, which loses lines unless the writes take turns.
+func appendLines(path, name string, n int) int {
+	for i := range n {
+		err := privileged(audit.ActionConfigWrite, path, func() error {
+			content, err := os.ReadFile(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return writeFile(path, fmt.Sprintf("%s%s %d\n", content, name, i))
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func competingProcess(t *testing.T, env ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+func TestConcurrentWritersTakeTurns(t *testing.T) {
+	path := useConfigDir(t) + "/julo-prod.conf"
+	var writers []*exec.Cmd
+	for _, name := range []string{"a", "b"} {
+		cmd := competingProcess(t, "LOCK_TEST_WRITER="+path, "LOCK_TEST_NAME="+name)
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		writers = append(writers, cmd)
+	}
+	for _, cmd := range writers {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("writer: %v", err)
+		}
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != 400 {
+		t.Errorf("%d lines, want 400: writes interleaved", len(lines))
+	}
+}
+
+// holdLock starts a process holding path's lock for hold and waits until
+// it has it.
+func holdLock(t *testing.T, path string, hold time.Duration) *exec.Cmd {
+	t.Helper()
+	cmd := competingProcess(t, "LOCK_TEST_HOLDER="+path, "LOCK_TEST_HOLD="+hold.String())
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		t.Fatalf("holder said %q", line)
+	}
+	return cmd
+}
+
+func useLockTimeout(t *testing.T, timeout time.Duration) {
+	saved := LockTimeout
+	LockTimeout = timeout
+	t.Cleanup(func() { LockTimeout = saved })
+}
+
+func TestLockTimeoutNamesTheHolder(t *testing.T) {
+	path := useConfigDir(t) + "/julo-prod.conf"
+	holder := holdLock(t, path, time.Minute)
+	useLockTimeout(t, 300*time.Millisecond)
+
+	err := writeFileWithLock(path)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("err = %v, want a LockedError", err)
+	}
+	if locked.PID != holder.Process.Pid {
+		t.Errorf("PID = %d, want the holder's %d", locked.PID, holder.Process.Pid)
+	}
+	if want := "another update of julo-prod.conf is in progress (pid " + strconv.Itoa(holder.Process.Pid) + ")"; err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("the file was written without the lock")
+	}
+}
+
+func TestLockWaitsForTheHolder(t *testing.T) {
+	path := useConfigDir(t) + "/julo-prod.conf"
+	holdLock(t, path, 300*time.Millisecond)
+	useLockTimeout(t, 10*time.Second)
+
+	if err := writeFileWithLock(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFileWithLock(path string) error {
+	return NewConfigProcessor().writeFileWithContent(path, "[Interface]\n")
+}
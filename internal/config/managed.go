@@ -0,0 +1,120 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// CustomTemplates is what InstallTemplates does with an installed template
+// that was edited since the app installed it, e.g. an MTU tweaked by hand.
+type CustomTemplates int
+
+const (
+	// RefuseCustomTemplates fails with ErrTemplateCustomized before any
+	// template is written
+	RefuseCustomTemplates CustomTemplates = iota
+	// ReplaceCustomTemplates saves the edited template as a .local backup,
+	// then installs the built-in one over it
+	ReplaceCustomTemplates
+	// KeepCustomTemplates leaves the edited template as it is
+	KeepCustomTemplates
+)
+
+// ErrTemplateCustomized is what InstallTemplates fails with when it would
+// overwrite local changes to a template.
+var ErrTemplateCustomized = errors.New("template changed since it was installed")
+
+// managedTemplatesFile records the SHA-256 of each template as the app last
+// installed it. It lives next to the templates rather than in the state
+// file: the setup writing them often runs as root through sudo, with a state
+// directory of its own.
+const managedTemplatesFile = ".managed-templates.json"
+
+// builtinTemplate is a template InstallTemplates installs.
+type builtinTemplate struct {
+	name, label, content string
+}
+
+// builtinTemplates are the templates InstallTemplates installs: JULO's, or
+// an organization's (see SetGateways).
+var builtinTemplates = []builtinTemplate{
+	{core.ProdTemplate, "production", prodTemplateContent},
+	{core.NonProdTemplate, "non-production", nonprodTemplateContent},
+}
+
+// CustomizedTemplates lists the installed built-in templates (their paths)
+// that were edited since the app installed them: they hold neither what
+// the app last wrote there nor the built-in template. A template installed
+// before the app kept track counts as edited when it isn't the built-in
+// one.
+func CustomizedTemplates() ([]string, error) {
+	managed, err := loadManagedTemplates()
+	if err != nil {
+		return nil, err
+	}
+	var customized []string
+	for _, template := range builtinTemplates {
+		installed, err := readIfInstalled(template.name)
+		if err != nil {
+			return nil, err
+		}
+		if installed == nil {
+			continue
+		}
+		hash := hashOf(installed)
+		if hash != managed[template.name] && hash != hashOf([]byte(NormalizeTemplate(template.content))) {
+			customized = append(customized, core.InstalledPath(template.name))
+		}
+	}
+	return customized, nil
+}
+
+func loadManagedTemplates() (map[string]string, error) {
+	managed := map[string]string{}
+	content, err := readIfInstalled(managedTemplatesFile)
+	if err != nil || content == nil {
+		return managed, err
+	}
+	if err := json.Unmarshal(content, &managed); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", core.InstalledPath(managedTemplatesFile), err)
+	}
+	return managed, nil
+}
+
+// recordManagedTemplate notes content as what the app installed as the
+// template name.
+func recordManagedTemplate(name, content string) error {
+	managed, err := loadManagedTemplates()
+	if err != nil {
+		// Started over: at worst a template is taken for edited once
+		managed = map[string]string{}
+	}
+	managed[name] = hashOf([]byte(content))
+	data, err := json.MarshalIndent(managed, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(core.InstalledPath(managedTemplatesFile), string(data)+"\n")
+}
+
+// saveLocalTemplate copies an edited template to the backups directory as
+// <name>.local.<timestamp>, which rollback and pruning leave alone, and
+// returns the copy's path.
+func (cp *ConfigProcessor) saveLocalTemplate(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	backupDir := filepath.Join(filepath.Dir(path), BackupDir)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+	backupPath := filepath.Join(backupDir, fmt.Sprintf("%s.local.%s", filepath.Base(path), time.Now().Format(backupTimeFormat)))
+	return backupPath, cp.writePrivateFile(backupPath, string(content))
+}
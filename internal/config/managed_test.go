@@ -0,0 +1,139 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// customize edits an installed template the way a power user would.
+func customize(t *testing.T, name string) string {
+	t.Helper()
+	path := core.InstalledPath(name)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := strings.Replace(string(content), "[Interface]\n", "[Interface]\nMTU = 1280\n", 1)
+	if err := os.WriteFile(path, []byte(edited), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return edited
+}
+
+func localBackups(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, BackupDir, "*.local.*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func TestInstallTemplatesFreshAndUnchanged(t *testing.T) {
+	dir := useConfigDir(t)
+	for _, run := range []string{"fresh install", "reinstall"} {
+		cp := NewConfigProcessor()
+		if err := cp.InstallTemplates(); err != nil {
+			t.Fatalf("%s: %v", run, err)
+		}
+		customized, err := CustomizedTemplates()
+		if err != nil || len(customized) != 0 {
+			t.Fatalf("%s: CustomizedTemplates() = %v, %v", run, customized, err)
+		}
+	}
+	managed, err := loadManagedTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{core.ProdTemplate, core.NonProdTemplate} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if managed[name] != hashOf(content) {
+			t.Errorf("%s: recorded %q, installed %q", name, managed[name], hashOf(content))
+		}
+	}
+	if backups := localBackups(t, dir); len(backups) != 0 {
+		t.Errorf("backups of unchanged templates: %v", backups)
+	}
+}
+
+func TestInstallTemplatesCustomized(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   CustomTemplates
+		err      error
+		kept     bool // the edited template is still installed
+		backedUp bool
+		warning  string
+	}{
+		{name: "refuse", policy: RefuseCustomTemplates, err: ErrTemplateCustomized, kept: true},
+		{name: "replace", policy: ReplaceCustomTemplates, backedUp: true, warning: "had local changes: saved them as"},
+		{name: "keep", policy: KeepCustomTemplates, kept: true, warning: "kept " + core.ProdTemplate + " as it is"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := useConfigDir(t)
+			if err := NewConfigProcessor().InstallTemplates(); err != nil {
+				t.Fatal(err)
+			}
+			edited := customize(t, core.ProdTemplate)
+			customized, err := CustomizedTemplates()
+			if err != nil || len(customized) != 1 || customized[0] != core.InstalledPath(core.ProdTemplate) {
+				t.Fatalf("CustomizedTemplates() = %v, %v", customized, err)
+			}
+
+			cp := NewConfigProcessor()
+			cp.CustomTemplates = tt.policy
+			if err := cp.InstallTemplates(); !errors.Is(err, tt.err) {
+				t.Fatalf("InstallTemplates() = %v, want %v", err, tt.err)
+			}
+			content, err := os.ReadFile(filepath.Join(dir, core.ProdTemplate))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept := string(content) == edited; kept != tt.kept {
+				t.Errorf("edited template kept: %v, want %v", kept, tt.kept)
+			}
+			backups := localBackups(t, dir)
+			if tt.backedUp {
+				if len(backups) != 1 {
+					t.Fatalf("backups %v, want one", backups)
+				}
+				if saved, _ := os.ReadFile(backups[0]); string(saved) != edited {
+					t.Errorf("the backup holds %q", saved)
+				}
+			} else if len(backups) != 0 {
+				t.Errorf("backups %v, want none", backups)
+			}
+			if tt.warning != "" && (len(cp.Warnings) == 0 || !strings.Contains(strings.Join(cp.Warnings, "\n"), tt.warning)) {
+				t.Errorf("warnings %q, want %q", cp.Warnings, tt.warning)
+			}
+		})
+	}
+}
+
+// A template installed before the hashes were kept counts as edited only
+// when it isn't the built-in one.
+func TestCustomizedTemplatesWithoutRecord(t *testing.T) {
+	dir := useConfigDir(t)
+	if err := NewConfigProcessor().InstallTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, managedTemplatesFile)); err != nil {
+		t.Fatal(err)
+	}
+	if customized, err := CustomizedTemplates(); err != nil || len(customized) != 0 {
+		t.Fatalf("CustomizedTemplates() = %v, %v", customized, err)
+	}
+	customize(t, core.NonProdTemplate)
+	if customized, err := CustomizedTemplates(); err != nil || len(customized) != 1 || filepath.Base(customized[0]) != core.NonProdTemplate {
+		t.Errorf("CustomizedTemplates() = %v, %v", customized, err)
+	}
+}
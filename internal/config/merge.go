@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// templatePeer is what the merge takes from one of a template's peers.
+type templatePeer struct {
+	publicKey  string
+	allowedIPs []string
+}
+
+// matchPeer finds the template peer with publicKey. A template with a single
+// peer that names no key applies to every peer, as templates did before
+// configs had more than one.
+func matchPeer(peers []templatePeer, publicKey string) (templatePeer, bool) {
+	if len(peers) == 1 && peers[0].publicKey == "" {
+		return peers[0], true
+	}
+	for _, peer := range peers {
+		if peer.publicKey != "" && peer.publicKey == publicKey {
+			return peer, true
+		}
+	}
+	return templatePeer{}, false
+}
+
+// mergeConfig merges a user config with its environment's template: DNS is
+// replaced in [Interface], and AllowedIPs in each [Peer] whose PublicKey
+// matches one of the template's peers, or added with a warning where the
+// config has none. Other peers, e.g. a backup gateway
+// the template doesn't know yet, are kept as issued with a warning. A config
+// none of whose peers matches is for another gateway and is refused. Both
+// are read as WGConfigs, so the values are replaced whatever their spelling
+// or spacing, and the rest of the user config (comments and keys this
+// doesn't know included) is installed as written.
+func (cp *ConfigProcessor) mergeConfig(user, template string) (string, error) {
+	templateConfig := ParseWGConfig(template)
+	var dns []string
+	if iface := templateConfig.Interface(); iface != nil {
+		dns = iface.List("DNS")
+	}
+	var peers []templatePeer
+	for _, peer := range templateConfig.Peers() {
+		peers = append(peers, templatePeer{publicKey: peer.Get("PublicKey"), allowedIPs: peer.List("AllowedIPs")})
+	}
+	if len(dns) == 0 {
+		return "", fmt.Errorf("failed to extract DNS from template: key DNS not found")
+	}
+	if len(peers) == 0 {
+		return "", fmt.Errorf("failed to extract AllowedIPs from template: no [Peer] section")
+	}
+
+	config := ParseWGConfig(user)
+	for _, section := range config.Sections {
+		// SaveConfig, and hook scripts stripped by policy
+		section.Filter(func(line WGLine) bool { return cp.filterDirective(line.Raw) })
+	}
+	// A config without DNS or AllowedIPs gets the template's, rather than
+	// installing without name resolution or routes
+	var added []string
+	if iface := config.Interface(); iface != nil {
+		if len(iface.List("DNS")) == 0 {
+			added = append(added, fmt.Sprintf("added the template's DNS (%s) to [Interface]: the config had none", strings.Join(dns, ", ")))
+		}
+		iface.Set("DNS", strings.Join(dns, ", "))
+	}
+	var unmatched []string
+	matched := false
+	for _, section := range config.Peers() {
+		peer, ok := matchPeer(peers, section.Get("PublicKey"))
+		if !ok {
+			unmatched = append(unmatched, fmt.Sprintf("kept the peer at %s as issued: the template has no peer with its PublicKey", peerEndpoint(section)))
+			continue
+		}
+		matched = true
+		if len(peer.allowedIPs) > 0 {
+			if len(section.List("AllowedIPs")) == 0 {
+				added = append(added, fmt.Sprintf("added the template's AllowedIPs (%d routes) to the peer at %s: the config had none", len(peer.allowedIPs), peerEndpoint(section)))
+			}
+			section.Set("AllowedIPs", strings.Join(peer.allowedIPs, ", "))
+		}
+	}
+	if !matched {
+		return "", fmt.Errorf("none of the config's peers uses the gateway PublicKey of the template; it is for another gateway or the template is outdated")
+	}
+	cp.Warnings = append(cp.Warnings, added...)
+	cp.Warnings = append(cp.Warnings, unmatched...)
+	merged := config.Marshal()
+	if !strings.HasSuffix(merged, "\n") {
+		merged += "\n"
+	}
+	return merged, nil
+}
+
+// peerEndpoint names a peer for warnings by its Endpoint.
+func peerEndpoint(peer *WGSection) string {
+	if endpoint := peer.Get("Endpoint"); endpoint != "" {
+		return endpoint
+	}
+	return "no Endpoint"
+}
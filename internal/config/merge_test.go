@@ -0,0 +1,70 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const mergeTemplate = `[Interface]
+PrivateKey = PLACEHOLDER
+DNS = 10.10.0.2, 10.10.0.3
+
+[Peer]
+PublicKey = Z2F0ZXdheQ==
+AllowedIPs = 10.10.0.0/16, 172.16.0.0/12
+`
+
+func TestMergeConfigAddsWhatIsMissing(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		warnings []string
+	}{
+		{
+			name:   "both present",
+			config: "[Interface]\nPrivateKey = cHJpdmF0ZQ==\nDNS = 1.1.1.1\n\n[Peer]\nPublicKey = Z2F0ZXdheQ==\nAllowedIPs = 0.0.0.0/0\nEndpoint = 34.101.166.184:51820\n",
+		},
+		{
+			name:     "no DNS",
+			config:   "[Interface]\nPrivateKey = cHJpdmF0ZQ==\nAddress = 10.9.0.2/32\n\n[Peer]\nPublicKey = Z2F0ZXdheQ==\nAllowedIPs = 0.0.0.0/0\nEndpoint = 34.101.166.184:51820\n",
+			warnings: []string{"added the template's DNS (10.10.0.2, 10.10.0.3) to [Interface]: the config had none"},
+		},
+		{
+			name:     "no AllowedIPs",
+			config:   "[Interface]\nPrivateKey = cHJpdmF0ZQ==\nDNS = 1.1.1.1\n\n[Peer]\nPublicKey = Z2F0ZXdheQ==\nEndpoint = 34.101.166.184:51820\n",
+			warnings: []string{"added the template's AllowedIPs (2 routes) to the peer at 34.101.166.184:51820: the config had none"},
+		},
+		{
+			name:   "neither",
+			config: "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n\n[Peer]\nPublicKey = Z2F0ZXdheQ==\n",
+			warnings: []string{
+				"added the template's DNS (10.10.0.2, 10.10.0.3) to [Interface]: the config had none",
+				"added the template's AllowedIPs (2 routes) to the peer at no Endpoint: the config had none",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := NewConfigProcessor()
+			merged, err := cp.mergeConfig(tt.config, mergeTemplate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := ParseWGConfig(merged)
+			if got := config.Interface().List("DNS"); !slices.Equal(got, []string{"10.10.0.2", "10.10.0.3"}) {
+				t.Errorf("DNS = %q", got)
+			}
+			if got := config.Peers()[0].List("AllowedIPs"); !slices.Equal(got, []string{"10.10.0.0/16", "172.16.0.0/12"}) {
+				t.Errorf("AllowedIPs = %q", got)
+			}
+			// Added within their own sections
+			if !strings.Contains(merged, "[Interface]\nPrivateKey = cHJpdmF0ZQ==\n") || strings.Index(merged, "DNS") > strings.Index(merged, "[Peer]") {
+				t.Errorf("merged:\n%s", merged)
+			}
+			if !slices.Equal(cp.Warnings, tt.warnings) {
+				t.Errorf("warnings %q, want %q", cp.Warnings, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/paths"
+)
+
+// StagedConfig is a config update merged and validated like
+// ProcessUserConfig would install it, but kept aside until its environment's
+// tunnel is down: installing it under a running tunnel changes nothing until
+// the next reconnect, and a reconnect mid-call is what deferring avoids.
+type StagedConfig struct {
+	Environment string
+	Path        string // the merged config, in the private state directory
+	SHA256      string // of the merged config, checked again when it is applied
+}
+
+// stagedFile names env's staged config in the state directory. A newer
+// update staged for env replaces it.
+func stagedFile(env string) string {
+	return "pending-" + env + ".conf"
+}
+
+// StageUserConfig merges userConfigPath with its environment's template and
+// keeps the result for ApplyStaged, installing nothing.
+func (cp *ConfigProcessor) StageUserConfig(userConfigPath string) (*StagedConfig, error) {
+	env, merged, err := cp.mergeUserConfig(userConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := paths.EnsureFile(paths.State, stagedFile(env))
+	if err != nil {
+		return nil, err
+	}
+	if err := cp.writePrivateFile(path, merged); err != nil {
+		return nil, fmt.Errorf("failed to stage config: %v", err)
+	}
+	sum := sha256.Sum256([]byte(merged))
+	return &StagedConfig{Environment: env, Path: path, SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// mergeUserConfig is userConfigPath merged and validated the way
+// ProcessUserConfig installs it, and the environment it belongs to.
+func (cp *ConfigProcessor) mergeUserConfig(userConfigPath string) (env, merged string, err error) {
+	user, err := os.ReadFile(userConfigPath)
+	if err != nil {
+		return "", "", fmt.Errorf("user config file not found: %s", userConfigPath)
+	}
+	if LooksLikeTemplate(string(user)) {
+		return "", "", fmt.Errorf("%s looks like a template (placeholder PrivateKey or Address), not a personal config; import it as a template instead", userConfigPath)
+	}
+	env, err = cp.DetectEnvironment(userConfigPath)
+	if err != nil {
+		return "", "", fmt.Errorf("the config you specify (%s) is not JULO's VPN config (%v).\nPlease check with Infra Team", userConfigPath, err)
+	}
+
+	template, err := os.ReadFile(core.InstalledPath(core.TemplateFile(core.Environment(env))))
+	switch {
+	case err == nil:
+		if merged, err = cp.mergeConfig(string(user), string(template)); err != nil {
+			return "", "", err
+		}
+	case os.IsNotExist(err) && env != string(core.Production) && env != string(core.NonProduction):
+		merged = cp.issuedContent(string(user))
+	default:
+		return "", "", fmt.Errorf("failed to read template: %v", err)
+	}
+	if err := ValidateInstalled(core.ConfigFile(core.Environment(env)), merged); err != nil {
+		return "", "", err
+	}
+	return env, merged, nil
+}
+
+// ApplyStaged installs env's staged config, backing up the one it replaces,
+// provided it still hashes to sum; a staged file changed since is refused.
+// The staged file is removed once installed.
+func (cp *ConfigProcessor) ApplyStaged(env, sum string) error {
+	path, err := paths.File(paths.State, stagedFile(env))
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("staged config is gone: %v", err)
+	}
+	if got := sha256.Sum256(content); hex.EncodeToString(got[:]) != sum {
+		return fmt.Errorf("staged config %s changed since it was staged; update again", path)
+	}
+	name := core.ConfigFile(core.Environment(env))
+	if err := ValidateInstalled(name, string(content)); err != nil {
+		return err
+	}
+
+	outputPath := core.InstalledPath(name)
+	if _, err := os.Stat(outputPath); err == nil {
+		if _, err := cp.backupFile(outputPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", outputPath, err)
+		}
+	}
+	if err := privileged(audit.ActionConfigWrite, outputPath, func() error {
+		return cp.writePrivateFile(outputPath, string(content))
+	}); err != nil {
+		return fmt.Errorf("failed to update config (try running with sudo): %v", err)
+	}
+	return DiscardStaged(env)
+}
+
+// DiscardStaged removes env's staged config, if any.
+func DiscardStaged(env string) error {
+	path, err := paths.File(paths.State, stagedFile(env))
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
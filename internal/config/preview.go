@@ -0,0 +1,192 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+// diffContext is how many unchanged lines a hunk shows around a change,
+// like diff -u.
+const diffContext = 3
+
+// ConfigPreview is what ProcessUserConfig would change, worked out without
+// writing anything.
+type ConfigPreview struct {
+	Environment string
+	Path        string     // the installed config it would replace
+	Exists      bool       // false when there is none yet: the diff is all additions
+	Diff        []DiffLine // unified diff of the installed config against the merged one
+	Added       int
+	Removed     int
+}
+
+// Unchanged reports whether installing would leave the config as it is.
+func (p *ConfigPreview) Unchanged() bool {
+	return p.Added == 0 && p.Removed == 0
+}
+
+// DiffLine is one line of a unified diff. Kind is ' ' for context, '+' and
+// '-' for added and removed lines, '@' for a hunk header and 'h' for the
+// file headers; Text is the line without its marker.
+type DiffLine struct {
+	Kind byte
+	Text string
+}
+
+// String is the line as diff -u prints it.
+func (l DiffLine) String() string {
+	switch l.Kind {
+	case '@', 'h':
+		return l.Text
+	}
+	return string(l.Kind) + l.Text
+}
+
+// PreviewUserConfig merges userConfigPath like ProcessUserConfig and diffs
+// the result against the installed config, installing nothing. Key material
+// shows as [HIDDEN] on both sides, as in GetConfig; a changed key still
+// shows as a removed and an added line.
+func (cp *ConfigProcessor) PreviewUserConfig(userConfigPath string) (*ConfigPreview, error) {
+	env, merged, err := cp.mergeUserConfig(userConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	preview := &ConfigPreview{
+		Environment: env,
+		Path:        core.InstalledPath(core.ConfigFile(core.Environment(env))),
+	}
+	oldName := "/dev/null"
+	installed, err := os.ReadFile(preview.Path)
+	switch {
+	case err == nil:
+		preview.Exists = true
+		oldName = preview.Path
+	case os.IsNotExist(err):
+	default:
+		return nil, fmt.Errorf("failed to read %s: %v", preview.Path, err)
+	}
+
+	preview.Diff = UnifiedDiff(oldName, preview.Path+" (new)", string(installed), merged)
+	for i, line := range preview.Diff {
+		switch line.Kind {
+		case '+':
+			preview.Added++
+		case '-':
+			preview.Removed++
+		}
+		preview.Diff[i].Text = wgvpn.Redact(line.Text)
+	}
+	return preview, nil
+}
+
+// UnifiedDiff compares two texts line by line and returns their unified
+// diff, with --- and +++ headers naming them; it is empty when they are
+// the same. An empty old text diffs as all additions.
+func UnifiedDiff(oldName, newName, oldText, newText string) []DiffLine {
+	a, b := splitLines(oldText), splitLines(newText)
+	edits := diffLines(a, b)
+
+	var out []DiffLine
+	for start := 0; start < len(edits); {
+		// Find the next change and the run of changes close enough to it
+		// to share a hunk
+		for start < len(edits) && edits[start].Kind == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		end := start
+		for i := start; i < len(edits); i++ {
+			if edits[i].Kind != ' ' {
+				end = i + 1
+			} else if i-end >= 2*diffContext {
+				break
+			}
+		}
+		from, to := max(start-diffContext, 0), min(end+diffContext, len(edits))
+
+		if out == nil {
+			out = append(out, DiffLine{'h', "--- " + oldName}, DiffLine{'h', "+++ " + newName})
+		}
+		oldStart, newStart := 1, 1
+		for _, e := range edits[:from] {
+			if e.Kind != '+' {
+				oldStart++
+			}
+			if e.Kind != '-' {
+				newStart++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, e := range edits[from:to] {
+			if e.Kind != '+' {
+				oldCount++
+			}
+			if e.Kind != '-' {
+				newCount++
+			}
+		}
+		out = append(out, DiffLine{'@', fmt.Sprintf("@@ -%s +%s @@", hunkRange(oldStart, oldCount), hunkRange(newStart, newCount))})
+		out = append(out, edits[from:to]...)
+		start = to
+	}
+	return out
+}
+
+// hunkRange is one side of a hunk header: "start,count", with the start
+// before the hunk when it has no lines on that side, as diff -u writes it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines is the shortest edit turning a into b, from their longest
+// common subsequence. Configs are a few dozen lines, so the quadratic table
+// costs nothing.
+func diffLines(a, b []string) []DiffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var edits []DiffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, DiffLine{' ', a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, DiffLine{'+', b[j]})
+			j++
+		default:
+			edits = append(edits, DiffLine{'-', a[i]})
+			i++
+		}
+	}
+	return edits
+}
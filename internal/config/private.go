@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+)
+
+// privateMode is the mode of every file the app writes under core.ConfigDir:
+// configs hold private keys.
+const privateMode = 0600
+
+// writeFile replaces path's content atomically: content goes to a temporary
+// file next to it, synced to disk and made private, which is then renamed
+// over path. A crash or failed write leaves path as it was, never
+// truncated. The caller holds path's lock.
+func writeFile(path, content string) error {
+	// Hidden, so nothing listing julo-*.conf takes it for a config
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Nothing left to remove once renamed
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(privateMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// installedFiles lists the paths of the installed templates and configs,
+// julo-*.conf. A directory that can't be read yields none.
+func installedFiles() []string {
+	entries, err := os.ReadDir(core.ConfigDir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, "julo-") && strings.HasSuffix(name, ".conf") {
+			files = append(files, core.InstalledPath(name))
+		}
+	}
+	return files
+}
+
+// LoosePermissions lists the installed templates and configs other users may
+// read or write, with their modes, e.g. "julo-prod.conf (0644)". Files that
+// can't be looked at without privileges are left out.
+func LoosePermissions() []string {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	var loose []string
+	for _, path := range installedFiles() {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().Perm()&^privateMode != 0 {
+			loose = append(loose, fmt.Sprintf("%s (%04o)", filepath.Base(path), info.Mode().Perm()))
+		}
+	}
+	return loose
+}
+
+// restrictPermissions makes the installed templates and configs private,
+// noting each one it changed as a warning.
+func (cp *ConfigProcessor) restrictPermissions() error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	for _, path := range installedFiles() {
+		info, err := os.Stat(path)
+		if err != nil || info.Mode().Perm() == privateMode {
+			continue
+		}
+		err = privileged(audit.ActionConfigWrite, path, func() error {
+			return os.Chmod(path, privateMode)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to restrict permissions on %s: %v", path, err)
+		}
+		cp.Warnings = append(cp.Warnings, fmt.Sprintf("%s was mode %04o: made it private (0600)", filepath.Base(path), info.Mode().Perm()))
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// leftovers lists what is in dir besides want.
+func leftovers(t *testing.T, dir string, want ...string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var extra []string
+	for _, entry := range entries {
+		if !slices.Contains(want, entry.Name()) {
+			extra = append(extra, entry.Name())
+		}
+	}
+	return extra
+}
+
+func TestWriteFileIsPrivate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, core.ProdConfig)
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, content := range []string{"[Interface]\nPrivateKey = cHJpdmF0ZQ==\n", ""} {
+		if err := writeFile(path, content); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("mode %04o, want 0600", info.Mode().Perm())
+		}
+		if got, _ := os.ReadFile(path); string(got) != content {
+			t.Errorf("content %q, want %q", got, content)
+		}
+	}
+	if extra := leftovers(t, dir, core.ProdConfig); len(extra) != 0 {
+		t.Errorf("left behind %v", extra)
+	}
+}
+
+func TestWriteFileFailureLeavesTheTarget(t *testing.T) {
+	dir := t.TempDir()
+
+	// The rename fails: the target is a directory with something in it
+	target := filepath.Join(dir, core.ProdConfig)
+	if err := os.MkdirAll(filepath.Join(target, "keep"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFile(target, "[Interface]\n"); err == nil {
+		t.Fatal("writeFile over a directory succeeded")
+	}
+	if info, err := os.Stat(filepath.Join(target, "keep")); err != nil || !info.IsDir() {
+		t.Errorf("the target changed: %v", err)
+	}
+	if extra := leftovers(t, dir, core.ProdConfig); len(extra) != 0 {
+		t.Errorf("left behind %v", extra)
+	}
+
+	// The temporary file can't be created: the directory is gone
+	missing := filepath.Join(dir, "gone", core.NonProdConfig)
+	if err := writeFile(missing, "[Interface]\n"); err == nil {
+		t.Fatal("writeFile into a missing directory succeeded")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("a partial target exists: %v", err)
+	}
+}
+
+func TestRestrictPermissions(t *testing.T) {
+	dir := useConfigDir(t)
+	modes := map[string]os.FileMode{
+		core.ProdConfig:      0644,
+		core.ProdTemplate:    0600,
+		core.NonProdConfig:   0660,
+		"julo-prod.conf.bak": 0644, // not an installed config
+	}
+	for name, mode := range modes {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("[Interface]\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, mode); err != nil { // past the umask
+			t.Fatal(err)
+		}
+	}
+
+	if got, want := LoosePermissions(), []string{"julo-nonprod.conf (0660)", "julo-prod.conf (0644)"}; !slices.Equal(got, want) {
+		t.Errorf("LoosePermissions() = %q, want %q", got, want)
+	}
+	cp := NewConfigProcessor()
+	if err := cp.restrictPermissions(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Warnings) != 2 {
+		t.Errorf("warnings %q, want one per changed file", cp.Warnings)
+	}
+	if loose := LoosePermissions(); len(loose) != 0 {
+		t.Errorf("still loose: %q", loose)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "julo-prod.conf.bak")); info.Mode().Perm() != 0644 {
+		t.Errorf("changed a file that isn't an installed config: %04o", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// RunProvisioning installs provisioned templates and issued configs in one
+// privileged run: the built-in templates, the provisioned templates over
+// them, then the configs merged like picked files. Empty paths are skipped.
+// Nobody is there to acknowledge hook scripts, so configs running them are
+// refused unless hooks are stripped.
+func RunProvisioning(prodTemplate, nonprodTemplate, prodConfig, nonprodConfig string) ([]string, error) {
+	cp := NewConfigProcessor()
+	// What is provisioned wins over local edits, which are kept as .local
+	// backups and reported
+	cp.CustomTemplates = ReplaceCustomTemplates
+	if err := cp.InstallTemplates(); err != nil {
+		return cp.Warnings, fmt.Errorf("failed to install templates: %v", err)
+	}
+
+	templates := []struct{ env, path string }{{"prod", prodTemplate}, {"nonprod", nonprodTemplate}}
+	for _, t := range templates {
+		if t.path == "" {
+			continue
+		}
+		content, err := os.ReadFile(t.path)
+		if err != nil {
+			return cp.Warnings, fmt.Errorf("failed to read %s template: %v", t.env, err)
+		}
+		if err := cp.installRemoteTemplate(t.env, string(content)); err != nil {
+			return cp.Warnings, fmt.Errorf("%s template %s: %v", t.env, t.path, err)
+		}
+	}
+
+	configs := []struct{ env, path string }{{"prod", prodConfig}, {"nonprod", nonprodConfig}}
+	for _, c := range configs {
+		if c.path == "" {
+			continue
+		}
+		content, err := os.ReadFile(c.path)
+		if err != nil {
+			return cp.Warnings, fmt.Errorf("failed to read %s config: %v", c.env, err)
+		}
+		if err := validateConfigFor(c.env, string(content)); err != nil {
+			return cp.Warnings, fmt.Errorf("%s config %s: %v", c.env, c.path, err)
+		}
+		if hooks := ParseDirectives(string(content)).Hooks; len(hooks) > 0 && !cp.StripHooks {
+			return cp.Warnings, fmt.Errorf("%s config %s runs shell commands (%s); set strip_hook_scripts or remove them", c.env, c.path, hooks[0])
+		}
+		if err := cp.ProcessUserConfigDirectly(c.path); err != nil {
+			return cp.Warnings, fmt.Errorf("failed to process %s config: %v", c.env, err)
+		}
+	}
+	return cp.Warnings, nil
+}
+
+// What ApplyProvisioning did with a file.
+const (
+	ItemInstalled = "installed" // nothing was installed there before
+	ItemUpdated   = "updated"   // it replaced a different file
+	ItemSkipped   = "skipped"   // the file in place is already the one provisioned
+	ItemFailed    = "failed"
+)
+
+// ProvisionItem is what ApplyProvisioning did with one provisioned file.
+type ProvisionItem struct {
+	Role   string // e.g. "prod template"
+	Source string // the provisioned file, "built-in" for a built-in template
+	Target string // the installed file
+	Action string
+	Reason string // why it was skipped or failed, or what else was done
+}
+
+// ApplyProvisioning is RunProvisioning for unattended runs. Every file is
+// tried and reported on, a failure included, and a file whose installed form
+// is already in place is left alone: applying the same files again changes
+// nothing. A provisioned template takes the place of the built-in one rather
+// than being installed over it. A config whose template failed isn't
+// installed.
+func ApplyProvisioning(prodTemplate, nonprodTemplate, prodConfig, nonprodConfig string) ([]ProvisionItem, []string) {
+	cp := NewConfigProcessor()
+	if err := os.MkdirAll(core.ConfigDir, 0755); err != nil {
+		reason := fmt.Sprintf("failed to create config directory: %v", err)
+		var items []ProvisionItem
+		for _, role := range []string{"prod template", "nonprod template", "prod config", "nonprod config"} {
+			items = append(items, ProvisionItem{Role: role, Action: ItemFailed, Reason: reason})
+		}
+		return items, nil
+	}
+
+	var items []ProvisionItem
+	failed := map[string]bool{}
+	provisioned := map[string]string{"prod": prodTemplate, "nonprod": nonprodTemplate}
+	for _, template := range builtinTemplates {
+		env := string(core.EnvironmentOf(template.name))
+		item := ProvisionItem{Role: env + " template", Source: "built-in", Target: core.InstalledPath(template.name)}
+		content := template.content
+		if path := provisioned[env]; path != "" {
+			item.Source = path
+			data, err := os.ReadFile(path)
+			if err == nil {
+				err = validateConfigFor(env, string(data))
+			}
+			if err != nil {
+				item.Action, item.Reason = ItemFailed, err.Error()
+				items = append(items, item)
+				failed[env] = true
+				continue
+			}
+			content = string(data)
+		}
+		if err := cp.provisionTemplate(&item, content); err != nil {
+			item.Action, item.Reason = ItemFailed, err.Error()
+			failed[env] = true
+		}
+		items = append(items, item)
+	}
+
+	configs := []struct{ env, path string }{{"prod", prodConfig}, {"nonprod", nonprodConfig}}
+	for _, c := range configs {
+		if c.path == "" {
+			continue
+		}
+		item := ProvisionItem{Role: c.env + " config", Source: c.path, Target: core.InstalledPath(core.ConfigFile(core.Environment(c.env)))}
+		if failed[c.env] {
+			item.Action, item.Reason = ItemFailed, fmt.Sprintf("not installed: the %s template failed", c.env)
+		} else if err := cp.provisionConfig(&item, c.env); err != nil {
+			item.Action, item.Reason = ItemFailed, err.Error()
+		}
+		items = append(items, item)
+	}
+
+	if err := cp.restrictPermissions(); err != nil {
+		cp.Warnings = append(cp.Warnings, err.Error())
+	}
+	return items, cp.Warnings
+}
+
+// provisionTemplate installs content as item's template unless it is there
+// already. A template edited by hand is kept as a .local backup first.
+func (cp *ConfigProcessor) provisionTemplate(item *ProvisionItem, content string) error {
+	name := filepath.Base(item.Target)
+	installed, err := readIfInstalled(name)
+	if err != nil {
+		return err
+	}
+	switch {
+	case installed == nil:
+		item.Action = ItemInstalled
+	case string(installed) == NormalizeTemplate(content):
+		item.Action, item.Reason = ItemSkipped, "already installed"
+		return nil
+	default:
+		item.Action = ItemUpdated
+		customized, err := CustomizedTemplates()
+		if err != nil {
+			return err
+		}
+		if slices.Contains(customized, item.Target) {
+			backupPath, err := cp.saveLocalTemplate(item.Target)
+			if err != nil {
+				return fmt.Errorf("failed to save the local changes: %v", err)
+			}
+			item.Reason = "had local changes, saved as " + backupPath
+		}
+	}
+	return cp.installTemplate(item.Target, content)
+}
+
+// provisionConfig merges item's config and installs it like a picked file,
+// unless the installed config is already the merged one.
+func (cp *ConfigProcessor) provisionConfig(item *ProvisionItem, env string) error {
+	content, err := os.ReadFile(item.Source)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	if err := validateConfigFor(env, string(content)); err != nil {
+		return err
+	}
+	if hooks := ParseDirectives(string(content)).Hooks; len(hooks) > 0 && !cp.StripHooks {
+		return fmt.Errorf("runs shell commands (%s); set strip_hook_scripts or remove them", hooks[0])
+	}
+	// Merged on the side, so its warnings aren't reported twice
+	preview := NewConfigProcessor()
+	_, merged, err := preview.mergeUserConfig(item.Source)
+	if err != nil {
+		return err
+	}
+	installed, err := readIfInstalled(filepath.Base(item.Target))
+	if err != nil {
+		return err
+	}
+	switch {
+	case installed == nil:
+		item.Action = ItemInstalled
+	case string(installed) == merged:
+		item.Action, item.Reason = ItemSkipped, "already up to date"
+		return nil
+	default:
+		item.Action = ItemUpdated
+	}
+	return cp.ProcessUserConfigDirectly(item.Source)
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// issuedConfig is an issued config for env, told apart by its Address.
+func issuedConfig(env, address string) string {
+	config := NormalizeTemplate(builtinTemplateFor(env))
+	config = strings.Replace(config, templatePlaceholder, "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=", 1)
+	return strings.Replace(config, templatePlaceholder, address, 1)
+}
+
+// provisioned writes the files of a provisioning into a directory of its
+// own and returns their paths by name.
+func provisioned(t *testing.T, files map[string]string) map[string]string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := map[string]string{}
+	for name, content := range files {
+		paths[name] = filepath.Join(dir, name)
+		if err := os.WriteFile(paths[name], []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+// actions is what ApplyProvisioning did with each item, e.g.
+// "prod template installed".
+func actions(items []ProvisionItem) string {
+	var done []string
+	for _, item := range items {
+		done = append(done, item.Role+" "+item.Action)
+	}
+	return strings.Join(done, ", ")
+}
+
+func TestApplyProvisioningIsIdempotent(t *testing.T) {
+	dir := useConfigDir(t)
+	files := provisioned(t, map[string]string{
+		"prod.conf":    issuedConfig("prod", "10.9.0.2/32"),
+		"nonprod.conf": issuedConfig("nonprod", "10.8.0.2/32"),
+	})
+	apply := func() string {
+		items, _ := ApplyProvisioning("", "", files["prod.conf"], files["nonprod.conf"])
+		return actions(items)
+	}
+
+	if got, want := apply(), "prod template installed, nonprod template installed, prod config installed, nonprod config installed"; got != want {
+		t.Fatalf("first run: %s, want %s", got, want)
+	}
+	if got, want := apply(), "prod template skipped, nonprod template skipped, prod config skipped, nonprod config skipped"; got != want {
+		t.Fatalf("second run: %s, want %s", got, want)
+	}
+
+	// A config issued again with another address replaces the installed one
+	if err := os.WriteFile(files["prod.conf"], []byte(issuedConfig("prod", "10.9.0.3/32")), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := apply(), "prod template skipped, nonprod template skipped, prod config updated, nonprod config skipped"; got != want {
+		t.Fatalf("after a reissue: %s, want %s", got, want)
+	}
+	if installed, _ := os.ReadFile(filepath.Join(dir, core.ProdConfig)); !strings.Contains(string(installed), "10.9.0.3/32") {
+		t.Errorf("installed prod config:\n%s", installed)
+	}
+}
+
+func TestApplyProvisioningFailures(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		// prod template, nonprod template, prod config, nonprod config
+		template, config [2]string
+		want             string
+		reason           string // of the first failed item
+	}{
+		{
+			name:   "config for the other environment",
+			files:  map[string]string{"prod.conf": issuedConfig("nonprod", "10.8.0.2/32"), "nonprod.conf": issuedConfig("nonprod", "10.8.0.2/32")},
+			config: [2]string{"prod.conf", "nonprod.conf"},
+			want:   "prod template installed, nonprod template installed, prod config failed, nonprod config installed",
+		},
+		{
+			name:     "broken template fails its config",
+			files:    map[string]string{"prod-template.conf": "[Interface]\nthis is not a template\n", "prod.conf": issuedConfig("prod", "10.9.0.2/32")},
+			template: [2]string{"prod-template.conf", ""},
+			config:   [2]string{"prod.conf", ""},
+			want:     "prod template failed, nonprod template installed, prod config failed",
+		},
+		{
+			name:   "missing config",
+			files:  map[string]string{},
+			config: [2]string{"", "gone.conf"},
+			want:   "prod template installed, nonprod template installed, nonprod config failed",
+			reason: "failed to read config",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfigDir(t)
+			files := provisioned(t, tt.files)
+			path := func(name string) string {
+				if name == "" {
+					return ""
+				}
+				if path, ok := files[name]; ok {
+					return path
+				}
+				return filepath.Join(t.TempDir(), name)
+			}
+			items, _ := ApplyProvisioning(path(tt.template[0]), path(tt.template[1]), path(tt.config[0]), path(tt.config[1]))
+			if got := actions(items); got != tt.want {
+				t.Fatalf("%s, want %s", got, tt.want)
+			}
+			for _, item := range items {
+				if item.Action != ItemFailed {
+					continue
+				}
+				if item.Reason == "" || !strings.Contains(item.Reason, tt.reason) {
+					t.Errorf("%s failed for %q, want %q", item.Role, item.Reason, tt.reason)
+				}
+				if _, err := os.Stat(item.Target); err == nil {
+					t.Errorf("%s was installed", item.Role)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/paths"
+)
+
+const (
+	remoteCacheFile = "remote.json"
+	// Issued configs and templates are a few KB; anything larger is not one
+	maxRemoteFileSize = 1 << 20
+	// Per-request timeout used when the caller's client has none
+	remoteTimeout = 15 * time.Second
+)
+
+// RemoteSource is where an environment's canonical files are hosted. Either
+// URL may be empty.
+type RemoteSource struct {
+	Env         string // "prod" or "nonprod"
+	TemplateURL string
+	ConfigURL   string
+	// AuthEnv names the environment variable holding the Authorization
+	// header value (e.g. "Bearer ..."), so secrets stay out of settings.
+	AuthEnv string
+}
+
+// SyncChange is the outcome for one remote file.
+type SyncChange struct {
+	Env     string
+	Kind    string // "template" or "config"
+	URL     string
+	Updated bool  // the installed file was rewritten
+	Err     error // download, validation or install failure
+}
+
+func (c SyncChange) String() string {
+	switch {
+	case c.Err != nil:
+		return fmt.Sprintf("%s %s: %v", c.Env, c.Kind, c.Err)
+	case c.Updated:
+		return fmt.Sprintf("%s %s: updated", c.Env, c.Kind)
+	}
+	return fmt.Sprintf("%s %s: unchanged", c.Env, c.Kind)
+}
+
+// SyncResult collects the per-file outcomes of SyncFromRemote.
+type SyncResult struct {
+	Changes []SyncChange
+}
+
+// Failed reports whether any file could not be synced.
+func (r *SyncResult) Failed() bool {
+	for _, change := range r.Changes {
+		if change.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteEntry is what is remembered about a downloaded URL.
+type remoteEntry struct {
+	ETag     string    `json:"etag,omitempty"`
+	SHA256   string    `json:"sha256"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+type remoteCache map[string]remoteEntry
+
+func loadRemoteCache() remoteCache {
+	cache := remoteCache{}
+	path, err := paths.File(paths.Cache, remoteCacheFile)
+	if err != nil {
+		return cache
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	// A corrupt cache only costs a full re-download
+	_ = json.Unmarshal(data, &cache)
+	return cache
+}
+
+func (c remoteCache) save() error {
+	path, err := paths.EnsureFile(paths.Cache, remoteCacheFile)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return NewConfigProcessor().writePrivateFile(path, string(data))
+}
+
+// LastRemoteSync returns when every configured remote file of sources was
+// last fetched successfully, i.e. the oldest of their sync times. The zero
+// time means at least one has never been synced.
+func LastRemoteSync(sources []RemoteSource) time.Time {
+	cache := loadRemoteCache()
+	var oldest time.Time
+	first := true
+	for _, source := range sources {
+		for _, url := range []string{source.TemplateURL, source.ConfigURL} {
+			if url == "" {
+				continue
+			}
+			entry, ok := cache[url]
+			if !ok {
+				return time.Time{}
+			}
+			if first || entry.SyncedAt.Before(oldest) {
+				oldest = entry.SyncedAt
+				first = false
+			}
+		}
+	}
+	return oldest
+}
+
+// SyncFromRemote downloads the templates and issued configs of sources,
+// validates them and installs the ones that changed. Templates go first so
+// configs are merged with the fresh template. Unchanged files (by ETag or
+// content hash) are not rewritten. Failures are reported per file; the
+// installed files stay in place so the app keeps working offline.
+func (cp *ConfigProcessor) SyncFromRemote(ctx context.Context, client *http.Client, sources []RemoteSource) *SyncResult {
+	if client == nil {
+		client = &http.Client{Timeout: remoteTimeout}
+	}
+	cache := loadRemoteCache()
+	result := &SyncResult{}
+
+	for _, source := range sources {
+		if source.TemplateURL == "" {
+			continue
+		}
+		change := SyncChange{Env: source.Env, Kind: "template", URL: source.TemplateURL}
+		change.Updated, change.Err = cp.syncFile(ctx, client, cache, source, source.TemplateURL, cp.installRemoteTemplate)
+		result.Changes = append(result.Changes, change)
+	}
+	for _, source := range sources {
+		if source.ConfigURL == "" {
+			continue
+		}
+		change := SyncChange{Env: source.Env, Kind: "config", URL: source.ConfigURL}
+		change.Updated, change.Err = cp.syncFile(ctx, client, cache, source, source.ConfigURL, cp.installRemoteConfig)
+		result.Changes = append(result.Changes, change)
+	}
+
+	if err := cache.save(); err != nil {
+		cp.Warnings = append(cp.Warnings, fmt.Sprintf("failed to save sync cache: %v", err))
+	}
+	return result
+}
+
+// CheckRemote is SyncFromRemote without installing anything: Updated marks
+// the files the server has a new version of. Validation is left to the sync
+// that installs them.
+func (cp *ConfigProcessor) CheckRemote(ctx context.Context, client *http.Client, sources []RemoteSource) *SyncResult {
+	if client == nil {
+		client = &http.Client{Timeout: remoteTimeout}
+	}
+	cache := loadRemoteCache()
+	result := &SyncResult{}
+	for _, source := range sources {
+		for _, file := range []struct{ kind, url string }{{"template", source.TemplateURL}, {"config", source.ConfigURL}} {
+			if file.url == "" {
+				continue
+			}
+			change := SyncChange{Env: source.Env, Kind: file.kind, URL: file.url}
+			change.Updated, change.Err = cp.syncFile(ctx, client, cache, source, file.url, nil)
+			result.Changes = append(result.Changes, change)
+		}
+	}
+	return result
+}
+
+// syncFile fetches url and hands new content to install. It reports whether
+// anything was installed; with a nil install, whether the content is new.
+func (cp *ConfigProcessor) syncFile(ctx context.Context, client *http.Client, cache remoteCache, source RemoteSource, url string, install func(env, content string) error) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("invalid URL: %v", err)
+	}
+	if source.AuthEnv != "" {
+		auth := os.Getenv(source.AuthEnv)
+		if auth == "" {
+			return false, fmt.Errorf("%s is not set", source.AuthEnv)
+		}
+		req.Header.Set("Authorization", auth)
+	}
+	cached, haveCached := cache[url]
+	if haveCached && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("download failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && haveCached:
+		if install != nil {
+			cached.SyncedAt = time.Now()
+			cache[url] = cached
+		}
+		return false, nil
+	case resp.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("server returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteFileSize+1))
+	if err != nil {
+		return false, fmt.Errorf("download failed: %v", err)
+	}
+	if len(body) > maxRemoteFileSize {
+		return false, fmt.Errorf("file is larger than %d bytes", maxRemoteFileSize)
+	}
+
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	entry := remoteEntry{ETag: resp.Header.Get("ETag"), SHA256: hash, SyncedAt: time.Now()}
+	if install == nil {
+		// Only checking: nothing is installed or remembered
+		return !haveCached || cached.SHA256 != hash, nil
+	}
+	if haveCached && cached.SHA256 == hash {
+		cache[url] = entry
+		return false, nil
+	}
+
+	if err := install(source.Env, string(body)); err != nil {
+		return false, err
+	}
+	cache[url] = entry
+	return true, nil
+}
+
+// validateConfigFor checks that content is a WireGuard config for env before
+// anything is written.
+func validateConfigFor(env, content string) error {
+	if !strings.Contains(content, "[Interface]") || !strings.Contains(content, "[Peer]") {
+		return fmt.Errorf("not a WireGuard config (missing [Interface] or [Peer])")
+	}
+	detected, err := EnvironmentForConfig(content)
+	if err != nil {
+		return err
+	}
+	if detected != env {
+		return fmt.Errorf("config is for %s, not %s", detected, env)
+	}
+	return nil
+}
+
+func (cp *ConfigProcessor) installRemoteTemplate(env, content string) error {
+	if err := validateConfigFor(env, content); err != nil {
+		return err
+	}
+	name := core.ProdTemplate
+	if env == "nonprod" {
+		name = core.NonProdTemplate
+	}
+	return cp.installTemplate(core.InstalledPath(name), content)
+}
+
+// installRemoteConfig keeps the downloaded config (it holds the private key)
+// in the private cache directory and merges it like a locally picked file.
+func (cp *ConfigProcessor) installRemoteConfig(env, content string) error {
+	if err := validateConfigFor(env, content); err != nil {
+		return err
+	}
+	if !strings.Contains(content, "PrivateKey") {
+		return fmt.Errorf("issued config has no PrivateKey")
+	}
+	// Scripts need a person to acknowledge them, which a sync can't ask for
+	if hooks := ParseDirectives(content).Hooks; len(hooks) > 0 && !cp.StripHooks {
+		return fmt.Errorf("issued config runs shell commands (%s); download it and review it with Update VPN Configuration", hooks[0])
+	}
+	path, err := paths.EnsureFile(paths.Cache, "remote-"+env+".conf")
+	if err != nil {
+		return err
+	}
+	if err := cp.writePrivateFile(path, content); err != nil {
+		return fmt.Errorf("failed to cache config: %v", err)
+	}
+	return cp.ProcessUserConfigDirectly(path)
+}
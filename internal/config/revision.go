@@ -0,0 +1,92 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// Revision identifies what is installed for an environment without giving
+// any of it away: hashes of the installed files, never their contents.
+type Revision struct {
+	Env            string
+	ConfigSHA256   string // "" when no config is installed
+	TemplateSHA256 string // "" when no template is installed
+	// Routes counts the config's AllowedIPs entries that are valid prefixes
+	Routes int
+	// TemplateSyncedAt and ConfigSyncedAt are when the files were last
+	// fetched from the server, zero when they never were
+	TemplateSyncedAt time.Time
+	ConfigSyncedAt   time.Time
+}
+
+// InstalledRevision describes env's installed template and config. A
+// missing file is left out; one that can't be read is an error.
+func InstalledRevision(env string, sources []RemoteSource) (*Revision, error) {
+	revision := &Revision{Env: env}
+	config, err := readIfInstalled(core.ConfigFile(core.Environment(env)))
+	if err != nil {
+		return nil, err
+	}
+	template, err := readIfInstalled(core.TemplateFile(core.Environment(env)))
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		revision.ConfigSHA256 = hashOf(config)
+		revision.Routes = countRoutes(string(config))
+	}
+	if template != nil {
+		revision.TemplateSHA256 = hashOf(template)
+	}
+
+	cache := loadRemoteCache()
+	for _, source := range sources {
+		if source.Env != env {
+			continue
+		}
+		if entry, ok := cache[source.TemplateURL]; ok && source.TemplateURL != "" {
+			revision.TemplateSyncedAt = entry.SyncedAt
+		}
+		if entry, ok := cache[source.ConfigURL]; ok && source.ConfigURL != "" {
+			revision.ConfigSyncedAt = entry.SyncedAt
+		}
+	}
+	return revision, nil
+}
+
+func readIfInstalled(name string) ([]byte, error) {
+	content, err := ReadInstalled(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return content, err
+}
+
+func hashOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// countRoutes counts the AllowedIPs entries that parse as prefixes, so an
+// entry holding anything else (a pasted key, say) is never reported on.
+func countRoutes(content string) int {
+	count := 0
+	for _, line := range strings.Split(content, "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "AllowedIPs" {
+			continue
+		}
+		for _, item := range strings.Split(value, ",") {
+			if _, err := netip.ParsePrefix(strings.TrimSpace(item)); err == nil {
+				count++
+			}
+		}
+	}
+	return count
+}
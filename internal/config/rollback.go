@@ -0,0 +1,125 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+)
+
+// backupsKept is how many backups of each file backupFile keeps; see
+// SetBackupsKept.
+var backupsKept = 10
+
+// SetBackupsKept sets how many backups of each installed file are kept, the
+// oldest pruned first as new ones are taken (0 keeps them all). It is called
+// once at startup, from the settings.
+func SetBackupsKept(n int) {
+	backupsKept = n
+}
+
+// ErrNoBackup is what Rollback fails with when there is nothing to go back
+// to.
+var ErrNoBackup = errors.New("no backup to roll back to")
+
+// ConfigBackup is a copy of an installed file taken before it was replaced.
+type ConfigBackup struct {
+	Path  string
+	Taken time.Time
+}
+
+// String names the backup for the log, e.g.
+// "julo-prod.conf.2024-05-10T14-22-31.123456789".
+func (b ConfigBackup) String() string {
+	return filepath.Base(b.Path)
+}
+
+// Backups lists the backups of the installed file name, newest first.
+func Backups(name string) ([]ConfigBackup, error) {
+	dir := filepath.Join(core.ConfigDir, BackupDir)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []ConfigBackup
+	for _, entry := range entries {
+		stamp, ok := strings.CutPrefix(entry.Name(), name+".")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		taken, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue // not one of ours
+		}
+		backups = append(backups, ConfigBackup{Path: filepath.Join(dir, entry.Name()), Taken: taken})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Taken.After(backups[j].Taken) })
+	return backups, nil
+}
+
+// pruneBackups removes the backups of name beyond the newest backupsKept.
+// Failing to is not worth failing the write that took the backup for.
+func pruneBackups(name string) {
+	if backupsKept <= 0 {
+		return
+	}
+	backups, err := Backups(name)
+	if err != nil || len(backups) <= backupsKept {
+		return
+	}
+	for _, backup := range backups[backupsKept:] {
+		os.Remove(backup.Path)
+	}
+}
+
+// LatestBackup is the newest backup of env's config, nil when there is
+// none.
+func LatestBackup(env string) (*ConfigBackup, error) {
+	backups, err := Backups(core.ConfigFile(core.Environment(env)))
+	if err != nil || len(backups) == 0 {
+		return nil, err
+	}
+	return &backups[0], nil
+}
+
+// Rollback puts env's newest config backup back in place and returns it.
+// The restored backup is used up, so rolling back again goes one update
+// further back; the config it replaces is not kept. The restore goes to the
+// audit log like other writes.
+func (cp *ConfigProcessor) Rollback(env string) (*ConfigBackup, error) {
+	backup, err := LatestBackup(env)
+	if err != nil {
+		return nil, err
+	}
+	name := core.ConfigFile(core.Environment(env))
+	if backup == nil {
+		return nil, fmt.Errorf("%w: %s has no backups in %s", ErrNoBackup, name, filepath.Join(core.ConfigDir, BackupDir))
+	}
+	content, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateInstalled(name, string(content)); err != nil {
+		return nil, fmt.Errorf("backup %s is not usable: %v", backup, err)
+	}
+
+	path := core.InstalledPath(name)
+	if err := privileged(audit.ActionRollback, path, func() error {
+		return cp.writePrivateFile(path, string(content))
+	}); err != nil {
+		return nil, fmt.Errorf("failed to restore %s (try running with sudo): %v", backup, err)
+	}
+	if err := os.Remove(backup.Path); err != nil {
+		return nil, fmt.Errorf("restored %s but could not remove it: %v", backup, err)
+	}
+	return backup, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// prodConfig is an issued prod config, told apart by its Address.
+func prodConfig(address string) string {
+	return issuedConfig("prod", address)
+}
+
+func TestBackupsWithinASecondAreAllKept(t *testing.T) {
+	useConfigDir(t)
+	path := core.InstalledPath(core.ProdConfig)
+	cp := NewConfigProcessor()
+
+	// Three versions written back to back, each backed up before the next
+	versions := []string{prodConfig("10.9.0.1/32"), prodConfig("10.9.0.2/32"), prodConfig("10.9.0.3/32")}
+	for i, content := range versions {
+		if i > 0 {
+			if _, err := cp.backupFile(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := writeFile(path, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := Backups(core.ProdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("%d backups, want 2: %v", len(backups), backups)
+	}
+
+	// Rolling back twice reaches the first version
+	for i := len(versions) - 2; i >= 0; i-- {
+		if _, err := cp.Rollback("prod"); err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != versions[i] {
+			t.Fatalf("after rolling back to version %d:\n%s", i+1, content)
+		}
+	}
+}
+
+func TestBackupsReadOlderNames(t *testing.T) {
+	dir := filepath.Join(useConfigDir(t), BackupDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{
+		"julo-prod.conf.2024-05-10T14-22-31",
+		"julo-prod.conf.2024-05-10T14-22-31.500000000",
+		"julo-prod.conf.local.2024-05-10T14-22-32",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := Backups(core.ProdConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 || backups[0].String() != "julo-prod.conf.2024-05-10T14-22-31.500000000" {
+		t.Errorf("Backups() = %v, want the two timestamped ones, newest first", backups)
+	}
+}
@@ -1,18 +1,16 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"os"
 	"os/exec"
-	"path/filepath"
+	"strings"
+
+	"tui-wireguard-vpn/internal/core"
 )
 
 const (
-	ConfigDir = "/etc/wireguard"
-	
-	ProdTemplate    = "julo-prod-template.conf"
-	NonProdTemplate = "julo-nonprod-template.conf"
-	ProdConfig      = "julo-prod.conf"
-	NonProdConfig   = "julo-nonprod.conf"
-	
 	ProdEndpoint    = "34.101.166.184:51820"
 	NonProdEndpoint = "34.128.85.147:51820"
 )
@@ -22,7 +20,22 @@ type SetupStatus struct {
 	HasTemplates     bool
 	HasProdConfig    bool
 	HasNonProdConfig bool
-	MissingFiles     []string
+	// Configs tells, for the environment of every gateway (see
+	// SetGateways), whether its config is installed
+	Configs      map[string]bool
+	MissingFiles []string
+}
+
+// Partial reports whether the templates are installed but only one of the
+// two user configs is, so just the missing one needs setting up.
+func (s *SetupStatus) Partial() bool {
+	return s.HasTemplates && s.HasProdConfig != s.HasNonProdConfig
+}
+
+// HasConfig reports whether the user config for env ("prod", "nonprod", or
+// another gateway's environment) is installed.
+func (s *SetupStatus) HasConfig(env string) bool {
+	return s.Configs[env]
 }
 
 func CheckSetupStatus() (*SetupStatus, error) {
@@ -31,62 +44,97 @@ func CheckSetupStatus() (*SetupStatus, error) {
 	}
 	
 	// Try to check files with sudo to handle permission issues
-	return checkSetupStatusWithSudo(status)
+	return checkSetupStatusWithSudo(status, sudoFileExists)
+}
+
+// ErrSudoPasswordRequired is returned by CheckSetupStatusNonInteractive when
+// the files can only be checked with sudo and sudo would prompt.
+var ErrSudoPasswordRequired = errors.New("sudo needs a password to check the WireGuard config directory")
+
+// CheckSetupStatusNonInteractive is CheckSetupStatus for use while a TUI owns
+// the terminal: files are checked directly where possible and sudo is only
+// used when it doesn't need to prompt.
+func CheckSetupStatusNonInteractive() (*SetupStatus, error) {
+	status := &SetupStatus{
+		MissingFiles: []string{},
+	}
+	return checkSetupStatusWithSudo(status, nonInteractiveFileExists)
+}
+
+func sudoFileExists(path string) (bool, error) {
+	cmd := exec.Command("sudo", "test", "-f", path)
+	return cmd.Run() == nil, nil
 }
 
-func checkSetupStatusWithSudo(status *SetupStatus) (*SetupStatus, error) {
-	// Check for template files using sudo ls
-	filesToCheck := []string{
-		ProdTemplate,
-		NonProdTemplate,
-		ProdConfig,
-		NonProdConfig,
+func nonInteractiveFileExists(path string) (bool, error) {
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return info.Mode().IsRegular(), nil
+	case os.IsNotExist(err):
+		return false, nil
+	case !os.IsPermission(err):
+		return false, err
 	}
+
+	// /etc/wireguard is usually root-only; fall back to sudo without a prompt
+	var stderr bytes.Buffer
+	cmd := exec.Command("sudo", "-n", "test", "-f", path)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if strings.Contains(stderr.String(), "password") {
+			return false, ErrSudoPasswordRequired
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func checkSetupStatusWithSudo(status *SetupStatus, fileExists func(path string) (bool, error)) (*SetupStatus, error) {
+	// The templates of every gateway (see SetGateways), then their configs
+	var filesToCheck, configs []string
+	templates := map[string]bool{}
+	for _, template := range builtinTemplates {
+		filesToCheck = append(filesToCheck, template.name)
+		templates[template.name] = true
+		configs = append(configs, core.ConfigFile(core.EnvironmentOf(template.name)))
+	}
+	filesToCheck = append(filesToCheck, configs...)
+	status.Configs = map[string]bool{}
 	
 	// Use sudo ls to check if files exist in /etc/wireguard/
+	status.HasTemplates = true
 	for _, filename := range filesToCheck {
-		filepath := filepath.Join(ConfigDir, filename)
+		filepath := core.InstalledPath(filename)
 		
 		// Use sudo test to check if file exists
-		cmd := exec.Command("sudo", "test", "-f", filepath)
-		if err := cmd.Run(); err != nil {
-			status.MissingFiles = append(status.MissingFiles, filename)
-		} else {
-			// File exists
-			switch filename {
-			case ProdTemplate:
-				status.HasTemplates = true
-			case NonProdTemplate:
-				if status.HasTemplates {
-					status.HasTemplates = true
-				} else {
-					status.HasTemplates = true
-				}
-			case ProdConfig:
-				status.HasProdConfig = true
-			case NonProdConfig:
-				status.HasNonProdConfig = true
-			}
+		exists, err := fileExists(filepath)
+		if err != nil {
+			return nil, err
 		}
-	}
-	
-	// Fix template status - we need both templates to exist
-	hasProdTemplate := true
-	hasNonprodTemplate := true
-	
-	for _, missing := range status.MissingFiles {
-		if missing == ProdTemplate {
-			hasProdTemplate = false
+		if templates[filename] {
+			if !exists {
+				status.MissingFiles = append(status.MissingFiles, filename)
+				// We need every template to exist
+				status.HasTemplates = false
+			}
+			continue
 		}
-		if missing == NonProdTemplate {
-			hasNonprodTemplate = false
+		if !exists {
+			status.MissingFiles = append(status.MissingFiles, filename)
 		}
+		status.Configs[string(core.EnvironmentOf(filename))] = exists
 	}
-	status.HasTemplates = hasProdTemplate && hasNonprodTemplate
+	status.HasProdConfig = status.Configs[string(core.Production)]
+	status.HasNonProdConfig = status.Configs[string(core.NonProduction)]
 	
 	// Determine if setup is needed
 	// Setup is needed if we don't have templates OR if we don't have at least one working config
-	status.NeedsSetup = !status.HasTemplates || (!status.HasProdConfig && !status.HasNonProdConfig)
+	installed := false
+	for _, exists := range status.Configs {
+		installed = installed || exists
+	}
+	status.NeedsSetup = !status.HasTemplates || !installed
 	
 	return status, nil
 }
\ No newline at end of file
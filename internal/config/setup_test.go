@@ -0,0 +1,86 @@
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"tui-wireguard-vpn/internal/core"
+)
+
+// withGateway adds a gateway for env to the built-in ones for the test.
+func withGateway(t *testing.T, env string) {
+	t.Helper()
+	if _, err := core.AddEnvironment(env); err != nil {
+		t.Fatal(err)
+	}
+	saved := builtinTemplates
+	gateway := Gateway{Environment: env, Label: env, Endpoint: "203.0.113.7:51820", PublicKey: "dGVzdHRlc3R0ZXN0dGVzdHRlc3R0ZXN0dGVzdHRlc3Q=", AllowedIPs: []string{"10.99.0.0/16"}}
+	SetGateways(append(Gateways(), gateway))
+	t.Cleanup(func() {
+		builtinTemplates = saved
+		hostsMu.Lock()
+		delete(knownEndpoints, gateway.Endpoint)
+		hostsMu.Unlock()
+	})
+}
+
+func TestSetupStatusCountsEveryGateway(t *testing.T) {
+	withGateway(t, "dr")
+	templates := []string{core.ProdTemplate, core.NonProdTemplate, "julo-dr-template.conf"}
+
+	tests := []struct {
+		name       string
+		installed  []string
+		needsSetup bool
+		configs    map[string]bool
+		missing    []string
+	}{
+		{
+			name:       "only the org environment's config",
+			installed:  append(slices.Clone(templates), "julo-dr.conf"),
+			needsSetup: false,
+			configs:    map[string]bool{"prod": false, "nonprod": false, "dr": true},
+			missing:    []string{core.ProdConfig, core.NonProdConfig},
+		},
+		{
+			name:       "no config",
+			installed:  templates,
+			needsSetup: true,
+			configs:    map[string]bool{"prod": false, "nonprod": false, "dr": false},
+			missing:    []string{core.ProdConfig, core.NonProdConfig, "julo-dr.conf"},
+		},
+		{
+			name:       "the org environment's template missing",
+			installed:  []string{core.ProdTemplate, core.NonProdTemplate, core.ProdConfig},
+			needsSetup: true,
+			configs:    map[string]bool{"prod": true, "nonprod": false, "dr": false},
+			missing:    []string{"julo-dr-template.conf", core.NonProdConfig, "julo-dr.conf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exists := func(path string) (bool, error) {
+				return slices.Contains(tt.installed, filepath.Base(path)), nil
+			}
+			status, err := checkSetupStatusWithSudo(&SetupStatus{}, exists)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.NeedsSetup != tt.needsSetup {
+				t.Errorf("NeedsSetup = %v, want %v", status.NeedsSetup, tt.needsSetup)
+			}
+			for env, want := range tt.configs {
+				if got := status.HasConfig(env); got != want {
+					t.Errorf("HasConfig(%q) = %v, want %v", env, got, want)
+				}
+			}
+			if status.HasProdConfig != tt.configs["prod"] || status.HasNonProdConfig != tt.configs["nonprod"] {
+				t.Errorf("HasProdConfig, HasNonProdConfig = %v, %v", status.HasProdConfig, status.HasNonProdConfig)
+			}
+			if !slices.Equal(status.MissingFiles, tt.missing) {
+				t.Errorf("MissingFiles = %v, want %v", status.MissingFiles, tt.missing)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import "tui-wireguard-vpn/internal/core"
+
+// Store is the installed configs as vpn sees them (vpn.Configs), which
+// vpn gets at startup rather than importing config. Every call works with a
+// processor of its own.
+type Store struct{}
+
+// InstallUserConfig merges a user config with its template and installs it,
+// returning what the merge warns about.
+func (Store) InstallUserConfig(userConfigPath string) ([]string, error) {
+	processor := NewConfigProcessor()
+	err := processor.ProcessUserConfigDirectly(userConfigPath)
+	return processor.Warnings, err
+}
+
+func (Store) InstalledEndpoint(env string) (string, error) {
+	return NewConfigProcessor().InstalledEndpoint(env)
+}
+
+func (Store) UpdateEndpoint(env, endpoint string) error {
+	return NewConfigProcessor().UpdateEndpoint(env, endpoint)
+}
+
+func (Store) EndpointHostname(env, endpoint string) string {
+	return EndpointHostname(env, endpoint)
+}
+
+func (Store) ReadInstalled(name string) ([]byte, error) {
+	return ReadInstalled(name)
+}
+
+func (Store) ValidateInstalled(name, content string) error {
+	return ValidateInstalled(name, content)
+}
+
+func (Store) GenerateClientConfig(env, address string) (*core.GeneratedConfig, error) {
+	return NewConfigProcessor().GenerateClientConfig(env, address)
+}
+
+func (Store) ExportDeviceTemplate(env string, newKey bool) (*core.DeviceExport, error) {
+	return NewConfigProcessor().ExportDeviceTemplate(env, newKey)
+}
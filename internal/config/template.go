@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+)
+
+// LooksLikeTemplate reports whether content is a template rather than a
+// personal config: its PrivateKey or Address is an "xxxx..." placeholder.
+func LooksLikeTemplate(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		key, value := keyValue(line)
+		if (key == "privatekey" || key == "address") && isPlaceholder(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrivateKey reports whether content carries a real PrivateKey.
+func hasPrivateKey(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if key, value := keyValue(line); key == "privatekey" && value != "" && !isPlaceholder(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyValue splits a "Key = value" line, lowercasing the key as wg-quick
+// matches keys case-insensitively.
+func keyValue(line string) (string, string) {
+	parsed := parseWGLine(line)
+	return strings.ToLower(parsed.Key), parsed.Value
+}
+
+// ImportTemplate installs the file at path as the template of the
+// environment its Endpoint belongs to, linted and normalized like the
+// built-in ones. A file with a real PrivateKey is a personal config and is
+// refused, so one user's key never ends up in everyone's template.
+func (cp *ConfigProcessor) ImportTemplate(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %v", err)
+	}
+	if hasPrivateKey(string(content)) || !LooksLikeTemplate(string(content)) {
+		return "", fmt.Errorf("%s has a real PrivateKey: it is a personal config, not a template", path)
+	}
+	env, err := cp.DetectEnvironment(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot tell which environment the template is for: %v", err)
+	}
+	if err := validateConfigFor(env, string(content)); err != nil {
+		return "", err
+	}
+	if err := cp.installTemplate(core.InstalledPath(core.TemplateFile(core.Environment(env))), string(content)); err != nil {
+		return "", fmt.Errorf("failed to install template: %v", err)
+	}
+	return env, nil
+}
+
+// RemergeInstalled merges env's installed config with its template again,
+// e.g. after a new template was imported, keeping a backup of the previous
+// config. The merge replaces DNS and AllowedIPs; a template none of whose
+// peers has the config's PublicKey is refused, as only a new personal config
+// can fix that.
+func (cp *ConfigProcessor) RemergeInstalled(env string) error {
+	configPath := core.InstalledPath(core.ConfigFile(core.Environment(env)))
+	templatePath := core.InstalledPath(core.TemplateFile(core.Environment(env)))
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read installed config: %v", err)
+	}
+
+	// The merge reads the user config while rewriting the output, so it
+	// works from a private copy
+	source, err := os.CreateTemp("", "julo-remerge-*.conf")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(source.Name())
+	if err := source.Chmod(0600); err != nil {
+		source.Close()
+		return err
+	}
+	_, err = source.Write(content)
+	if closeErr := source.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy installed config: %v", err)
+	}
+
+	if _, err := cp.backupFile(configPath); err != nil {
+		return fmt.Errorf("failed to back up config: %v", err)
+	}
+	if err := privileged(audit.ActionConfigWrite, configPath, func() error {
+		return cp.updateConfig(source.Name(), templatePath, configPath)
+	}); err != nil {
+		return fmt.Errorf("failed to merge config (ask infra for a new personal config): %v", err)
+	}
+	return nil
+}
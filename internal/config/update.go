@@ -1,13 +1,17 @@
 package config
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
+	"slices"
 	"strings"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/ops"
 )
 
 const (
@@ -41,33 +45,119 @@ PersistentKeepAlive = 10
 `
 )
 
-type ConfigProcessor struct{}
+type ConfigProcessor struct {
+	// Warnings collects non-fatal problems (such as template lint findings)
+	// for the caller to display.
+	Warnings []string
+	// StripHooks drops PreUp/PostUp/PreDown/PostDown lines from merged
+	// configs instead of installing them.
+	StripHooks bool
+	// Progress, when set, is told as each step of RunSetup starts and
+	// finishes; see SetupSteps.
+	Progress func(ops.StepEvent)
+	// CustomTemplates says what InstallTemplates does with templates
+	// edited since they were installed.
+	CustomTemplates CustomTemplates
+}
+
+// Steps of RunSetup, in order.
+const (
+	StepInstallTemplates = "Install templates"
+	StepProdConfig       = "Install production config"
+	StepNonProdConfig    = "Install non-production config"
+)
+
+// SetupSteps declares the steps RunSetup takes for the given configs.
+func SetupSteps(prodConfigPath, nonprodConfigPath string) *ops.Steps {
+	names := []string{StepInstallTemplates}
+	if prodConfigPath != "" {
+		names = append(names, StepProdConfig)
+	}
+	if nonprodConfigPath != "" {
+		names = append(names, StepNonProdConfig)
+	}
+	return ops.NewSteps("Setup", names...)
+}
+
+// step runs fn as the setup step name, reporting it to Progress.
+func (cp *ConfigProcessor) step(name string, fn func() error) error {
+	if cp.Progress == nil {
+		return fn()
+	}
+	cp.Progress(ops.StepEvent{Step: name, Status: ops.StepRunning})
+	err := fn()
+	status := ops.StepDone
+	if err != nil {
+		status = ops.StepFailed
+	}
+	cp.Progress(ops.StepEvent{Step: name, Status: status, Err: err})
+	return err
+}
 
 func NewConfigProcessor() *ConfigProcessor {
-	return &ConfigProcessor{}
+	return &ConfigProcessor{StripHooks: stripHooks}
 }
 
 // InstallTemplates replicates "make install" - installs template files to /etc/wireguard/
 func (cp *ConfigProcessor) InstallTemplates() error {
 	// Create /etc/wireguard directory if it doesn't exist
-	if err := os.MkdirAll(ConfigDir, 0755); err != nil {
+	if err := os.MkdirAll(core.ConfigDir, 0755); err != nil {
 		return fmt.Errorf("failed to create config directory: %v", err)
 	}
 
-	// Install production template
-	prodTemplatePath := filepath.Join(ConfigDir, ProdTemplate)
-	if err := cp.writeFileWithContent(prodTemplatePath, prodTemplateContent); err != nil {
-		return fmt.Errorf("failed to install production template: %v", err)
+	// Local edits are found before anything is written, so a refusal
+	// leaves every template as it was
+	customized, err := CustomizedTemplates()
+	if err != nil {
+		return fmt.Errorf("failed to check the installed templates: %v", err)
+	}
+	if len(customized) > 0 && cp.CustomTemplates == RefuseCustomTemplates {
+		return fmt.Errorf("%w: %s", ErrTemplateCustomized, strings.Join(customized, ", "))
 	}
 
-	// Install non-production template
-	nonprodTemplatePath := filepath.Join(ConfigDir, NonProdTemplate)
-	if err := cp.writeFileWithContent(nonprodTemplatePath, nonprodTemplateContent); err != nil {
-		return fmt.Errorf("failed to install non-production template: %v", err)
+	for _, template := range builtinTemplates {
+		path := core.InstalledPath(template.name)
+		if slices.Contains(customized, path) {
+			if cp.CustomTemplates == KeepCustomTemplates {
+				cp.Warnings = append(cp.Warnings, fmt.Sprintf("kept %s as it is: it has local changes", template.name))
+				continue
+			}
+			backupPath, err := cp.saveLocalTemplate(path)
+			if err != nil {
+				return fmt.Errorf("failed to save the local changes to %s: %v", path, err)
+			}
+			cp.Warnings = append(cp.Warnings, fmt.Sprintf("%s had local changes: saved them as %s and installed the built-in template", template.name, backupPath))
+		}
+		if err := cp.installTemplate(path, template.content); err != nil {
+			return fmt.Errorf("failed to install %s template: %v", template.label, err)
+		}
+	}
+
+	// Templates kept as they were and configs written by older versions
+	// may still be readable by others
+	if err := cp.restrictPermissions(); err != nil {
+		return err
 	}
 
 	// Don't print directly - let the TUI handle the output
-	// fmt.Printf("Installed templates to %s\n", ConfigDir)
+	// fmt.Printf("Installed templates to %s\n", core.ConfigDir)
+	return nil
+}
+
+// installTemplate lints a template, records the findings as warnings, and
+// writes its normalized form to path, noting it as the app's own so later
+// edits to it are found.
+func (cp *ConfigProcessor) installTemplate(path, content string) error {
+	for _, finding := range LintTemplate(content) {
+		cp.Warnings = append(cp.Warnings, fmt.Sprintf("%s %s (normalized on install)", filepath.Base(path), finding))
+	}
+	normalized := NormalizeTemplate(content)
+	if err := cp.writeFileWithContent(path, normalized); err != nil {
+		return err
+	}
+	if err := recordManagedTemplate(filepath.Base(path), normalized); err != nil {
+		return fmt.Errorf("installed %s but could not record it: %v", path, err)
+	}
 	return nil
 }
 
@@ -78,33 +168,35 @@ func (cp *ConfigProcessor) ProcessUserConfig(userConfigPath string) error {
 		return fmt.Errorf("user config file not found: %s", userConfigPath)
 	}
 
-	// Read user config to detect environment by endpoint
-	endpoint, err := cp.extractEndpoint(userConfigPath)
-	if err != nil {
-		return fmt.Errorf("failed to extract endpoint from config: %v", err)
+	// Merging a template would stamp its placeholder key into the config
+	if content, err := os.ReadFile(userConfigPath); err == nil && LooksLikeTemplate(string(content)) {
+		return fmt.Errorf("%s looks like a template (placeholder PrivateKey or Address), not a personal config; import it as a template instead", userConfigPath)
 	}
 
-	// Determine environment based on endpoint (exactly like bash script)
-	var templatePath, outputPath string
-
-	switch endpoint {
-	case ProdEndpoint:
-		templatePath = filepath.Join(ConfigDir, ProdTemplate)
-		outputPath = filepath.Join(ConfigDir, ProdConfig)
-	case NonProdEndpoint:
-		templatePath = filepath.Join(ConfigDir, NonProdTemplate)
-		outputPath = filepath.Join(ConfigDir, NonProdConfig)
-	default:
-		return fmt.Errorf("the config you specify (%s) is not JULO's VPN config.\nPlease check with Infra Team", userConfigPath)
+	// Determine environment by the gateway's PublicKey, or else by endpoint
+	// (numeric endpoints exactly like the bash script; hostnames by name or
+	// resolution)
+	env, err := cp.DetectEnvironment(userConfigPath)
+	if err != nil {
+		return fmt.Errorf("the config you specify (%s) is not JULO's VPN config (%v).\nPlease check with Infra Team", userConfigPath, err)
 	}
+	templatePath := core.InstalledPath(core.TemplateFile(core.Environment(env)))
+	outputPath := core.InstalledPath(core.ConfigFile(core.Environment(env)))
 
 	// Check if template exists
 	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		// Profiles besides prod and nonprod may have no template; their
+		// configs are installed as issued
+		if env != string(core.Production) && env != string(core.NonProduction) {
+			return cp.installAsIssued(userConfigPath, outputPath)
+		}
 		return fmt.Errorf("template file not found: %s", templatePath)
 	}
 
 	// Merge user config with template (replicating the awk script logic)
-	if err := cp.updateConfig(userConfigPath, templatePath, outputPath); err != nil {
+	if err := privileged(audit.ActionConfigWrite, outputPath, func() error {
+		return cp.updateConfig(userConfigPath, templatePath, outputPath)
+	}); err != nil {
 		return fmt.Errorf("failed to update config: %v", err)
 	}
 
@@ -113,110 +205,106 @@ func (cp *ConfigProcessor) ProcessUserConfig(userConfigPath string) error {
 	return nil
 }
 
-// updateConfig replicates the awk script in j1-vpn-update-config
-func (cp *ConfigProcessor) updateConfig(userConfigPath, templatePath, outputPath string) error {
-	// Extract DNS and AllowedIPs from template (like the bash script)
-	templateDNS, err := cp.extractConfigLine(templatePath, "DNS")
+// installAsIssued installs a user config without merging a template,
+// dropping only the directives the merge drops too.
+func (cp *ConfigProcessor) installAsIssued(userConfigPath, outputPath string) error {
+	content, err := os.ReadFile(userConfigPath)
 	if err != nil {
-		return fmt.Errorf("failed to extract DNS from template: %v", err)
+		return err
 	}
+	if _, err := os.Stat(outputPath); err == nil {
+		if _, err := cp.backupFile(outputPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", outputPath, err)
+		}
+	}
+	if err := cp.writeFileWithContent(outputPath, cp.issuedContent(string(content))); err != nil {
+		return fmt.Errorf("failed to update config: %v", err)
+	}
+	return nil
+}
 
-	templateAllowedIPs, err := cp.extractConfigLine(templatePath, "AllowedIPs")
-	if err != nil {
-		return fmt.Errorf("failed to extract AllowedIPs from template: %v", err)
+// issuedContent is a config as installAsIssued installs it.
+func (cp *ConfigProcessor) issuedContent(content string) string {
+	config := ParseWGConfig(content)
+	for _, section := range config.Sections {
+		section.Filter(func(line WGLine) bool { return cp.filterDirective(line.Raw) })
 	}
+	return config.Marshal()
+}
 
-	// Read user config
-	userFile, err := os.Open(userConfigPath)
+// updateConfig replicates the awk script in j1-vpn-update-config, per peer;
+// see mergeConfig
+func (cp *ConfigProcessor) updateConfig(userConfigPath, templatePath, outputPath string) error {
+	template, err := os.ReadFile(templatePath)
+	if err != nil {
+		return fmt.Errorf("failed to read template: %v", err)
+	}
+	user, err := os.ReadFile(userConfigPath)
 	if err != nil {
 		return err
 	}
-	defer userFile.Close()
 
-	// Create output file
-	outputFile, err := os.Create(outputPath)
+	// Merged before the output is created, so a refused config leaves the
+	// installed one as it was
+	merged, err := cp.mergeConfig(string(user), string(template))
 	if err != nil {
-		return fmt.Errorf("failed to create output file (try running with sudo): %v", err)
-	}
-	defer outputFile.Close()
-
-	// Process user config line by line, replicating the awk script:
-	// /^AllowedIPs/ { print newroute; }
-	// /^DNS/ { print dns; }
-	// !/^AllowedIPs/ && !/^DNS/ {print $0;}
-	scanner := bufio.NewScanner(userFile)
-	allowedIPsRegex := regexp.MustCompile(`^AllowedIPs`)
-	dnsRegex := regexp.MustCompile(`^DNS`)
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		switch {
-		case allowedIPsRegex.MatchString(line):
-			// Replace with template AllowedIPs
-			fmt.Fprintln(outputFile, templateAllowedIPs)
-		case dnsRegex.MatchString(line):
-			// Replace with template DNS
-			fmt.Fprintln(outputFile, templateDNS)
-		default:
-			// Keep original line
-			fmt.Fprintln(outputFile, line)
+		return err
+	}
+	// Kept for Rollback, should the update turn out bad
+	if _, err := os.Stat(outputPath); err == nil {
+		if _, err := cp.backupFile(outputPath); err != nil {
+			return fmt.Errorf("failed to back up %s: %v", outputPath, err)
 		}
 	}
 
-	return scanner.Err()
+	if err := writeFile(outputPath, merged); err != nil {
+		return fmt.Errorf("failed to write output file (try running with sudo): %v", err)
+	}
+	return nil
 }
 
-func (cp *ConfigProcessor) extractEndpoint(configPath string) (string, error) {
-	file, err := os.Open(configPath)
+// DetectEnvironment reports which environment ("prod" or "nonprod") a user
+// config belongs to, based on its peers; see EnvironmentForConfig.
+func (cp *ConfigProcessor) DetectEnvironment(userConfigPath string) (string, error) {
+	content, err := os.ReadFile(userConfigPath)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	// Replicate the bash awk pattern: awk '/Endpoint/ { print $3;}'
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "Endpoint") {
-			fields := strings.Fields(line)
-			if len(fields) >= 3 {
-				return fields[2], nil
-			}
-		}
-	}
-
-	return "", fmt.Errorf("no Endpoint found in config file")
+	return EnvironmentForConfig(string(content))
 }
 
-func (cp *ConfigProcessor) extractConfigLine(configPath, key string) (string, error) {
-	file, err := os.Open(configPath)
+func (cp *ConfigProcessor) extractEndpoint(configPath string) (string, error) {
+	content, err := os.ReadFile(configPath)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	// Look for lines starting with the key (like grep DNS ${NEWCFG})
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, key) {
-			return line, nil // Return the full line
-		}
+	endpoint, err := ParseEndpoint(string(content))
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", configPath, err)
 	}
-
-	return "", fmt.Errorf("key %s not found in config file", key)
+	return endpoint, nil
 }
 
 func (cp *ConfigProcessor) writeFileWithContent(path, content string) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return privileged(audit.ActionConfigWrite, path, func() error {
+		return writeFile(path, content)
+	})
+}
 
-	_, err = file.WriteString(content)
-	return err
+// privileged runs a write under core.ConfigDir through the audit log, tagging it
+// with the environment the file belongs to. It holds the file's lock while
+// it runs, so two processes updating the same config (the TUI and a
+// scheduled update-config, say) take turns instead of interleaving.
+func privileged(action audit.Action, path string, fn func() error) error {
+	return audit.Run(action, string(core.EnvironmentOf(filepath.Base(path))), path, func() error {
+		unlock, err := lockFile(path)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		return fn()
+	})
 }
 
 // RunSetup performs the complete setup process (like make install + j1-vpn-update-config)
@@ -224,15 +312,19 @@ func (cp *ConfigProcessor) RunSetup(prodConfigPath, nonprodConfigPath string) er
 	// Step 1: Install templates (like "make install")
 	// Don't print directly - let the TUI handle the output
 	// fmt.Println("Installing WireGuard configuration templates...")
-	if err := cp.InstallTemplates(); err != nil {
-		return fmt.Errorf("failed to install templates: %v", err)
+	if err := cp.step(StepInstallTemplates, cp.InstallTemplates); err != nil {
+		// Wrapped for callers to tell ErrTemplateCustomized apart
+		return fmt.Errorf("failed to install templates: %w", err)
 	}
 
 	// Step 2: Process user configs (like "j1-vpn-update-config")
 	if prodConfigPath != "" {
 		// Don't print directly - let the TUI handle the output
 		// fmt.Println("\nProcessing production configuration...")
-		if err := cp.ProcessUserConfig(prodConfigPath); err != nil {
+		err := cp.step(StepProdConfig, func() error {
+			return cp.ProcessUserConfig(prodConfigPath)
+		})
+		if err != nil {
 			return fmt.Errorf("failed to process production config: %v", err)
 		}
 	}
@@ -240,7 +332,10 @@ func (cp *ConfigProcessor) RunSetup(prodConfigPath, nonprodConfigPath string) er
 	if nonprodConfigPath != "" {
 		// Don't print directly - let the TUI handle the output
 		// fmt.Println("\nProcessing non-production configuration...")
-		if err := cp.ProcessUserConfig(nonprodConfigPath); err != nil {
+		err := cp.step(StepNonProdConfig, func() error {
+			return cp.ProcessUserConfig(nonprodConfigPath)
+		})
+		if err != nil {
 			return fmt.Errorf("failed to process non-production config: %v", err)
 		}
 	}
@@ -248,9 +343,14 @@ func (cp *ConfigProcessor) RunSetup(prodConfigPath, nonprodConfigPath string) er
 	return nil
 }
 
-func RunSetupDirectly(prodConfigPath, nonprodConfigPath string) error {
+// RunSetupDirectly runs the setup process and returns any warnings collected
+// along the way. templates says what happens to templates with local
+// changes; progress, when set, follows its steps.
+func RunSetupDirectly(prodConfigPath, nonprodConfigPath string, templates CustomTemplates, progress func(ops.StepEvent)) ([]string, error) {
 	// Try to run the setup process directly, like the original bash scripts
 	processor := NewConfigProcessor()
+	processor.CustomTemplates = templates
+	processor.Progress = progress
 	err := processor.RunSetup(prodConfigPath, nonprodConfigPath)
 
 	if err != nil {
@@ -258,13 +358,17 @@ func RunSetupDirectly(prodConfigPath, nonprodConfigPath string) error {
 		if strings.Contains(err.Error(), "permission denied") ||
 			strings.Contains(err.Error(), "operation not permitted") ||
 			strings.Contains(err.Error(), "access is denied") {
-			return getSetupPermissionErrorMessage()
+			return processor.Warnings, getSetupPermissionErrorMessage()
 		}
-		return err
+		return processor.Warnings, err
 	}
-	return nil
+	return processor.Warnings, nil
 }
 
+// ErrInsufficientPermissions is what RunSetupDirectly fails with when it
+// isn't allowed to write the config directory.
+var ErrInsufficientPermissions = errors.New("insufficient permissions to install templates and config files")
+
 func getSetupPermissionErrorMessage() error {
 	var instructions string
 
@@ -281,7 +385,7 @@ func getSetupPermissionErrorMessage() error {
 			"sudo tui-wireguard-vpn"
 	}
 
-	return fmt.Errorf("insufficient permissions to install templates and config files.\n\n%s\n\nThen run the initial setup again.", instructions)
+	return fmt.Errorf("%w.\n\n%s\n\nThen run the initial setup again.", ErrInsufficientPermissions, instructions)
 }
 
 func (cp *ConfigProcessor) ProcessUserConfigDirectly(userConfigPath string) error {
@@ -0,0 +1,168 @@
+package config
+
+import "strings"
+
+// WGConfig is a WireGuard config as wg-quick reads it: an [Interface]
+// section and any number of [Peer] sections of "Key = value" lines. Keys
+// match case-insensitively with any spacing around "=", and everything
+// after a "#" is a comment, as in wg-quick. Every line keeps its spelling,
+// so Marshal gives back exactly the text ParseWGConfig was given, comments,
+// blank lines and unknown keys included, until something is changed.
+type WGConfig struct {
+	// Sections in file order. The lines before the first header form a
+	// first section without a Name.
+	Sections []*WGSection
+}
+
+// WGSection is a [Name] header and the lines under it.
+type WGSection struct {
+	Name  string   // as written between the brackets, e.g. "Peer"
+	Lines []WGLine // including the header line
+}
+
+// WGLine is one line of a config.
+type WGLine struct {
+	Raw   string // the line as written, which Marshal writes back
+	Key   string // as written, "" for headers, blank lines and comments
+	Value string // trimmed, without its inline comment
+}
+
+// ParseWGConfig reads a config. It never fails: a line it can't make sense
+// of is kept as it is, for validation to reject or wg-quick to complain
+// about.
+func ParseWGConfig(content string) *WGConfig {
+	config := &WGConfig{Sections: []*WGSection{{}}}
+	for _, raw := range strings.Split(content, "\n") {
+		if name, ok := sectionHeader(raw); ok {
+			config.Sections = append(config.Sections, &WGSection{Name: name})
+		}
+		current := config.Sections[len(config.Sections)-1]
+		current.Lines = append(current.Lines, parseWGLine(raw))
+	}
+	return config
+}
+
+// sectionHeader returns the name of a "[Name]" line.
+func sectionHeader(raw string) (string, bool) {
+	text, _, _ := strings.Cut(raw, "#")
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "[") || !strings.HasSuffix(text, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(text[1 : len(text)-1]), true
+}
+
+func parseWGLine(raw string) WGLine {
+	line := WGLine{Raw: raw}
+	// wg-quick drops everything from a "#" on; keys and values never
+	// contain one
+	text, _, _ := strings.Cut(raw, "#")
+	key, value, ok := strings.Cut(text, "=")
+	if key = strings.TrimSpace(key); ok && key != "" {
+		line.Key, line.Value = key, strings.TrimSpace(value)
+	}
+	return line
+}
+
+// Marshal writes the config back out.
+func (c *WGConfig) Marshal() string {
+	var lines []string
+	for _, section := range c.Sections {
+		for _, line := range section.Lines {
+			lines = append(lines, line.Raw)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Interface returns the [Interface] section, nil when there is none.
+func (c *WGConfig) Interface() *WGSection {
+	for _, section := range c.Sections {
+		if strings.EqualFold(section.Name, "Interface") {
+			return section
+		}
+	}
+	return nil
+}
+
+// Peers returns the [Peer] sections in file order.
+func (c *WGConfig) Peers() []*WGSection {
+	var peers []*WGSection
+	for _, section := range c.Sections {
+		if strings.EqualFold(section.Name, "Peer") {
+			peers = append(peers, section)
+		}
+	}
+	return peers
+}
+
+// Get returns the value of key's first line, "" when it has none.
+func (s *WGSection) Get(key string) string {
+	for _, line := range s.Lines {
+		if strings.EqualFold(line.Key, key) {
+			return line.Value
+		}
+	}
+	return ""
+}
+
+// List returns the comma-separated items of every line of key, the way
+// wg-quick adds up repeated AllowedIPs or DNS lines.
+func (s *WGSection) List(key string) []string {
+	var items []string
+	for _, line := range s.Lines {
+		if !strings.EqualFold(line.Key, key) {
+			continue
+		}
+		for _, item := range strings.Split(line.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
+
+// Set makes value the only value of key: the first line of key is
+// rewritten as "Key = value" and the others are dropped. A key the section
+// doesn't have yet goes after its last setting.
+func (s *WGSection) Set(key, value string) {
+	line := WGLine{Raw: key + " = " + value, Key: key, Value: value}
+	at := -1
+	lines := s.Lines[:0:0]
+	for _, existing := range s.Lines {
+		if strings.EqualFold(existing.Key, key) {
+			if at < 0 {
+				at = len(lines)
+				lines = append(lines, line)
+			}
+			continue
+		}
+		lines = append(lines, existing)
+	}
+	if at < 0 {
+		at = 0
+		if s.Name != "" {
+			at = 1 // after the header
+		}
+		for i, existing := range lines {
+			if existing.Key != "" {
+				at = i + 1
+			}
+		}
+		lines = append(lines[:at], append([]WGLine{line}, lines[at:]...)...)
+	}
+	s.Lines = lines
+}
+
+// Filter keeps the settings keep accepts; headers, comments and blank lines
+// always stay.
+func (s *WGSection) Filter(keep func(WGLine) bool) {
+	lines := s.Lines[:0:0]
+	for _, line := range s.Lines {
+		if line.Key == "" || keep(line) {
+			lines = append(lines, line)
+		}
+	}
+	s.Lines = lines
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+// userConfig is an issued config written the way people hand-edit them:
+// leading spaces, lowercase keys, inline comments, an unknown key and two
+// peers.
+const userConfig = `# issued 2026-05-01 by ops
+[Interface]
+ PrivateKey = cHJpdmF0ZQ==
+Address=10.9.0.2/32
+dns = 1.1.1.1 # the office resolver is slower
+MTU = 1380
+X-Laptop-Tag = dev-42
+
+[Peer] # primary gateway
+publickey = Z2F0ZXdheQ==   # rotated in May
+AllowedIPs = 0.0.0.0/0
+AllowedIPs = ::/0
+Endpoint = 34.101.166.184:51820
+PersistentKeepalive = 25
+
+[Peer]
+# backup gateway
+PublicKey = YmFja3Vw
+AllowedIPs = 192.0.2.0/24
+Endpoint = 198.51.100.9:51820
+`
+
+func TestWGConfigRoundTrip(t *testing.T) {
+	for _, content := range []string{userConfig, "", "\n\n", "no sections at all\n", "[Interface]\r\nPrivateKey = a\r\n", builtinTemplateFor("prod")} {
+		if got := ParseWGConfig(content).Marshal(); got != content {
+			t.Errorf("round trip of %q gave %q", content, got)
+		}
+	}
+}
+
+func TestWGConfigRead(t *testing.T) {
+	config := ParseWGConfig(userConfig)
+	iface := config.Interface()
+	if iface == nil {
+		t.Fatal("no [Interface]")
+	}
+	if got := iface.Get("DNS"); got != "1.1.1.1" {
+		t.Errorf("DNS = %q", got)
+	}
+	if got := iface.Get("privatekey"); got != "cHJpdmF0ZQ==" {
+		t.Errorf("PrivateKey = %q", got)
+	}
+	if got := iface.Get("X-Laptop-Tag"); got != "dev-42" {
+		t.Errorf("X-Laptop-Tag = %q", got)
+	}
+
+	peers := config.Peers()
+	if len(peers) != 2 {
+		t.Fatalf("%d peers, want 2", len(peers))
+	}
+	if got := peers[0].Get("PublicKey"); got != "Z2F0ZXdheQ==" {
+		t.Errorf("PublicKey = %q", got)
+	}
+	if got := peers[0].List("AllowedIPs"); !slices.Equal(got, []string{"0.0.0.0/0", "::/0"}) {
+		t.Errorf("AllowedIPs = %q", got)
+	}
+	if got := peers[1].Get("Endpoint"); got != "198.51.100.9:51820" {
+		t.Errorf("backup Endpoint = %q", got)
+	}
+}
+
+func TestWGSectionSet(t *testing.T) {
+	config := ParseWGConfig(userConfig)
+	peer := config.Peers()[0]
+	peer.Set("AllowedIPs", "10.0.0.0/8")
+	config.Interface().Set("Table", "off")
+
+	want := `# issued 2026-05-01 by ops
+[Interface]
+ PrivateKey = cHJpdmF0ZQ==
+Address=10.9.0.2/32
+dns = 1.1.1.1 # the office resolver is slower
+MTU = 1380
+X-Laptop-Tag = dev-42
+Table = off
+
+[Peer] # primary gateway
+publickey = Z2F0ZXdheQ==   # rotated in May
+AllowedIPs = 10.0.0.0/8
+Endpoint = 34.101.166.184:51820
+PersistentKeepalive = 25
+
+[Peer]
+# backup gateway
+PublicKey = YmFja3Vw
+AllowedIPs = 192.0.2.0/24
+Endpoint = 198.51.100.9:51820
+`
+	if got := config.Marshal(); got != want {
+		t.Errorf("after Set:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeConfigReplacesSemantically(t *testing.T) {
+	cp := NewConfigProcessor()
+	merged, err := cp.mergeConfig(userConfig, mergeTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := ParseWGConfig(merged)
+	if got := config.Interface().List("DNS"); !slices.Equal(got, []string{"10.10.0.2", "10.10.0.3"}) {
+		t.Errorf("DNS = %q", got)
+	}
+	peers := config.Peers()
+	if got := peers[0].List("AllowedIPs"); !slices.Equal(got, []string{"10.10.0.0/16", "172.16.0.0/12"}) {
+		t.Errorf("gateway AllowedIPs = %q", got)
+	}
+	// The peer the template doesn't know stays as issued, with a warning
+	if got := peers[1].List("AllowedIPs"); !slices.Equal(got, []string{"192.0.2.0/24"}) {
+		t.Errorf("backup AllowedIPs = %q", got)
+	}
+	if len(cp.Warnings) != 1 {
+		t.Errorf("warnings %q", cp.Warnings)
+	}
+	// Everything else is kept as written
+	for _, key := range []string{"PrivateKey", "Address", "MTU", "X-Laptop-Tag"} {
+		if got, want := config.Interface().Get(key), ParseWGConfig(userConfig).Interface().Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	for _, comment := range []string{"# issued 2026-05-01 by ops", "[Peer] # primary gateway", "# backup gateway", "publickey = Z2F0ZXdheQ==   # rotated in May"} {
+		if !slices.ContainsFunc(config.Sections, func(s *WGSection) bool {
+			return slices.ContainsFunc(s.Lines, func(l WGLine) bool { return l.Raw == comment })
+		}) {
+			t.Errorf("%q is gone", comment)
+		}
+	}
+}
+
+func TestMergeConfigRefusesAnotherGateway(t *testing.T) {
+	template := "[Interface]\nDNS = 10.10.0.2\n\n[Peer]\nPublicKey = b3RoZXI=\nAllowedIPs = 10.10.0.0/16\n"
+	if _, err := NewConfigProcessor().mergeConfig(userConfig, template); err == nil {
+		t.Error("merged a config for another gateway")
+	}
+}
@@ -0,0 +1,112 @@
+// Package core is what the rest of the app agrees on about the environments:
+// their identity, where their files are installed, and the results config
+// hands to vpn. config and vpn both build on it, so neither has to import
+// the other to name an environment or find its config. It depends on
+// nothing inside internal/.
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+// Environment names a profile ("prod", "nonprod", or one added with
+// AddEnvironment). The type is the one of the public wgvpn API, so values
+// pass between the two unchanged.
+type Environment = wgvpn.Environment
+
+const (
+	Production    = wgvpn.Production
+	NonProduction = wgvpn.NonProduction
+)
+
+// Environments returns every environment, production first; see
+// wgvpn.Environments.
+func Environments() []Environment {
+	return wgvpn.Environments()
+}
+
+// AddEnvironment makes a profile besides prod and nonprod known; see
+// wgvpn.AddEnvironment. It is called at startup, before anything lists the
+// environments.
+func AddEnvironment(name string) (Environment, error) {
+	return wgvpn.AddEnvironment(name)
+}
+
+// ParseEnvironment accepts the short names used on the command line and in
+// settings ("prod", "nonprod", an added profile's name).
+func ParseEnvironment(name string) (Environment, error) {
+	return wgvpn.ParseEnvironment(name)
+}
+
+// Names of the files installed under ConfigDir.
+const (
+	ProdTemplate    = "julo-prod-template.conf"
+	NonProdTemplate = "julo-nonprod-template.conf"
+	ProdConfig      = "julo-prod.conf"
+	NonProdConfig   = "julo-nonprod.conf"
+)
+
+// ConfigDir is where the templates and configs are installed: the directory
+// wg-quick reads them from, see SetConfigDir.
+var ConfigDir = wgvpn.DefaultConfigDir
+
+// SetConfigDir sets the directory configs are installed to and read from. It
+// is called once at startup, before anything touches the configs.
+func SetConfigDir(dir string) {
+	ConfigDir = dir
+}
+
+// ConfigFile returns the name of env's installed config, which wg-quick
+// also takes as the interface name.
+func ConfigFile(env Environment) string {
+	return env.Interface() + ".conf"
+}
+
+// TemplateFile returns the name of env's installed template.
+func TemplateFile(env Environment) string {
+	return env.Interface() + "-template.conf"
+}
+
+// EnvironmentOf returns the environment an installed file belongs to, ""
+// for a file the app doesn't manage.
+func EnvironmentOf(name string) Environment {
+	for _, env := range Environments() {
+		if name == ConfigFile(env) || name == TemplateFile(env) {
+			return env
+		}
+	}
+	return ""
+}
+
+// InstalledProfiles names the profiles besides prod and nonprod whose
+// configs are installed in ConfigDir: each julo-<name>.conf that isn't a
+// template. A directory that can't be listed has none.
+func InstalledProfiles() []string {
+	entries, err := os.ReadDir(ConfigDir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutPrefix(entry.Name(), "julo-")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if name, ok = strings.CutSuffix(name, ".conf"); !ok || strings.HasSuffix(name, "-template") {
+			continue
+		}
+		if name != string(Production) && name != string(NonProduction) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// InstalledPath returns the path of an installed file.
+func InstalledPath(name string) string {
+	return filepath.Join(ConfigDir, name)
+}
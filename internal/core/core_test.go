@@ -0,0 +1,31 @@
+package core
+
+import (
+	"slices"
+	"testing"
+
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+func TestEnvironmentsIsOneList(t *testing.T) {
+	// Added through the public API, seen here and by wgvpn's own lookups
+	env, err := wgvpn.AddEnvironment("lab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(Environments(), env) {
+		t.Errorf("Environments() = %v after wgvpn.AddEnvironment(%q)", Environments(), env)
+	}
+	if got, err := ParseEnvironment("lab"); err != nil || got != env {
+		t.Errorf("ParseEnvironment(%q) = %q, %v", "lab", got, err)
+	}
+	if got := EnvironmentOf("julo-lab.conf"); got != env {
+		t.Errorf("EnvironmentOf(%q) = %q, want %q", "julo-lab.conf", got, env)
+	}
+
+	envs := Environments()
+	envs[0] = "changed"
+	if Environments()[0] != Production {
+		t.Error("changing the returned slice changed the environments")
+	}
+}
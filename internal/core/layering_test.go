@@ -0,0 +1,40 @@
+package core
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const module = "tui-wireguard-vpn"
+
+// goList runs go list with args and returns the lines it prints.
+func goList(t *testing.T, args ...string) []string {
+	t.Helper()
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go not in PATH")
+	}
+	out, err := exec.Command("go", append([]string{"list"}, args...)...).Output()
+	if err != nil {
+		t.Fatalf("go list %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.Fields(string(out))
+}
+
+// TestLayering keeps core below config and vpn, and config and vpn apart:
+// vpn reaches the installed configs through vpn.Configs, so config is free
+// to use whatever it likes short of vpn itself.
+func TestLayering(t *testing.T) {
+	for _, imp := range goList(t, "-f", `{{join .Imports "\n"}}`, module+"/internal/core") {
+		if strings.HasPrefix(imp, module+"/internal/") {
+			t.Errorf("internal/core imports %s", imp)
+		}
+	}
+	if slices.Contains(goList(t, "-deps", module+"/internal/config"), module+"/internal/vpn") {
+		t.Error("internal/config depends on internal/vpn")
+	}
+	if slices.Contains(goList(t, "-f", `{{join .Imports "\n"}}`, module+"/internal/vpn"), module+"/internal/config") {
+		t.Error("internal/vpn imports internal/config; use vpn.Configs")
+	}
+}
@@ -0,0 +1,19 @@
+package core
+
+// GeneratedConfig describes a client config created from scratch. It never
+// carries the private key, which only ever exists in the written file.
+type GeneratedConfig struct {
+	Environment string
+	Path        string
+	PublicKey   string
+	BackupPath  string // previous config, if one was replaced
+}
+
+// DeviceExport describes a device template written for another device.
+type DeviceExport struct {
+	Environment string
+	Path        string
+	// PublicKey is the new device's key to register with infra, when a
+	// keypair was generated
+	PublicKey string
+}
@@ -0,0 +1,240 @@
+// Package doctor runs local health checks for the `doctor` subcommand.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/paths"
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+type Status int
+
+const (
+	OK Status = iota
+	Warn
+	Fail
+	NotApplicable
+)
+
+func (s Status) Symbol() string {
+	switch s {
+	case OK:
+		return "✅"
+	case Warn:
+		return "⚠️ "
+	case Fail:
+		return "❌"
+	}
+	return "➖"
+}
+
+// String names the status for machine-readable output.
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warn:
+		return "warn"
+	case Fail:
+		return "fail"
+	}
+	return "n/a"
+}
+
+// Check is the outcome of a single doctor check.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	Notes  []string
+}
+
+// Run performs every check and returns them in display order.
+func Run() []Check {
+	checks := []Check{checkTools(), checkPaths(), checkConfigDir()}
+	// Every gateway's template must be installed; a profile without a
+	// gateway only has one when it was installed by hand
+	gateways := map[core.Environment]bool{}
+	for _, g := range config.Gateways() {
+		gateways[core.Environment(g.Environment)] = true
+	}
+	for _, env := range core.Environments() {
+		name := core.TemplateFile(env)
+		if _, err := os.Stat(core.InstalledPath(name)); !gateways[env] && os.IsNotExist(err) {
+			continue
+		}
+		checks = append(checks, checkTemplate(name))
+	}
+	checks = append(checks, checkFirewall())
+	return checks
+}
+
+// Failed reports whether any check failed outright.
+func Failed(checks []Check) bool {
+	for _, check := range checks {
+		if check.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes checks as a human readable checklist.
+func Print(w io.Writer, checks []Check) {
+	for _, check := range checks {
+		fmt.Fprintf(w, "%s %s: %s\n", check.Status.Symbol(), check.Name, check.Detail)
+		for _, note := range check.Notes {
+			fmt.Fprintf(w, "     %s\n", note)
+		}
+	}
+}
+
+func checkTools() Check {
+	check := Check{Name: "WireGuard tools"}
+	var missing []string
+	for _, tool := range []string{"wg", "wg-quick"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			missing = append(missing, tool)
+		}
+	}
+	if len(missing) > 0 {
+		check.Status = Fail
+		check.Detail = fmt.Sprintf("%v not found in PATH — install wireguard-tools", missing)
+		return check
+	}
+	check.Status = OK
+	check.Detail = "wg and wg-quick found"
+	return check
+}
+
+// checkPaths reports where the app keeps its per-user files. Running doctor
+// under sudo resolves root's directories, which is called out explicitly.
+func checkPaths() Check {
+	check := Check{Name: "User directories", Status: OK, Detail: "resolved"}
+	for _, kind := range paths.Kinds {
+		dir, err := paths.Dir(kind)
+		if err != nil {
+			check.Status = Warn
+			check.Detail = "some directories could not be resolved"
+			check.Notes = append(check.Notes, fmt.Sprintf("%-8s %v", kind, err))
+			continue
+		}
+		check.Notes = append(check.Notes, fmt.Sprintf("%-8s %s", kind, dir))
+	}
+	if os.Getenv("SUDO_USER") != "" && os.Geteuid() == 0 {
+		check.Notes = append(check.Notes, "(running under sudo: these are root's directories, not "+os.Getenv("SUDO_USER")+"'s)")
+	}
+	return check
+}
+
+// checkConfigDir makes sure configs are installed where wg-quick reads them
+// from, and finds any left in a directory it doesn't read: there Start fails
+// with "does not exist" although setup succeeded.
+func checkConfigDir() Check {
+	check := Check{Name: "Config directory", Status: OK, Detail: core.ConfigDir}
+	searched := wgvpn.DetectConfigSearchPaths()
+	check.Notes = append(check.Notes, "wg-quick reads configs from "+strings.Join(searched, ", "))
+	if !slices.Contains(searched, core.ConfigDir) {
+		check.Status = Fail
+		check.Detail = core.ConfigDir + " — wg-quick won't read configs from it (check wireguard_dir in the settings)"
+	}
+
+	for _, dir := range wgvpn.KnownConfigDirs {
+		if slices.Contains(searched, dir) {
+			continue
+		}
+		// Glob can't tell an unreadable directory from an empty one; these
+		// are usually readable, only the files in them aren't
+		matches, _ := filepath.Glob(filepath.Join(dir, "julo-*.conf"))
+		if len(matches) == 0 {
+			continue
+		}
+		check.Status = Fail
+		if check.Detail == core.ConfigDir {
+			check.Detail = "configs found where wg-quick won't read them"
+		}
+		check.Notes = append(check.Notes, fmt.Sprintf("%s has %d config(s) wg-quick ignores — move them to %s", dir, len(matches), core.ConfigDir))
+	}
+	return check
+}
+
+func checkTemplate(name string) Check {
+	check := Check{Name: "Template " + name}
+	path := filepath.Join(core.ConfigDir, name)
+
+	content, err := os.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		check.Status = Fail
+		check.Detail = "not installed — run the setup"
+		return check
+	case os.IsPermission(err):
+		check.Status = Warn
+		check.Detail = "cannot read " + path + " — run doctor with sudo"
+		return check
+	case err != nil:
+		check.Status = Fail
+		check.Detail = err.Error()
+		return check
+	}
+
+	findings := config.LintTemplate(string(content))
+	if len(findings) == 0 {
+		check.Status = OK
+		check.Detail = "no lint findings"
+		return check
+	}
+	check.Status = Warn
+	check.Detail = fmt.Sprintf("%d lint finding(s)", len(findings))
+	for _, finding := range findings {
+		check.Notes = append(check.Notes, finding.String())
+	}
+	return check
+}
+
+// checkFirewall looks for firewall rules dropping outbound UDP to the
+// gateways or the replies coming back. The port is taken from the installed
+// production config when it can be read.
+func checkFirewall() Check {
+	check := Check{Name: "Firewall"}
+	port := wgvpn.DefaultPort
+	if endpoint, err := config.NewConfigProcessor().InstalledEndpoint("prod"); err == nil {
+		port = wgvpn.EndpointPort(endpoint)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	name, findings, err := wgvpn.CheckFirewall(ctx, wgvpn.ExecRunner{}, port)
+	switch {
+	case err != nil:
+		check.Status = Warn
+		check.Detail = fmt.Sprintf("cannot inspect %s — run doctor with sudo", name)
+		check.Notes = append(check.Notes, err.Error())
+		return check
+	case name == "":
+		check.Status = NotApplicable
+		check.Detail = "no active ufw, firewalld or nftables"
+		return check
+	case len(findings) == 0:
+		check.Status = OK
+		check.Detail = fmt.Sprintf("%s allows WireGuard on UDP %d", name, port)
+		return check
+	}
+	check.Status = Warn
+	check.Detail = fmt.Sprintf("%s may block WireGuard on UDP %d", name, port)
+	for _, finding := range findings {
+		check.Notes = append(check.Notes, finding.Rule, "  allow with: "+finding.Fix)
+	}
+	return check
+}
@@ -0,0 +1,135 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+// MaxSnapshot bounds a snapshot's length so it pastes into a chat message.
+const MaxSnapshot = 1500
+
+// maxSnapshotError bounds each error entry so three of them always fit.
+const maxSnapshotError = 200
+
+// SnapshotInfo is what the dashboard knows that the snapshot includes.
+type SnapshotInfo struct {
+	Status    *wgvpn.ConnectionStatus
+	StatusErr error
+	// Errors are the newest error entries of the activity log, oldest first
+	Errors []string
+}
+
+// Snapshot is a short text summary for pasting into a support request, a
+// lighter alternative to the full doctor output: app and tool versions, the
+// connection state and the recent errors. It is redacted like wg-quick
+// output and never longer than MaxSnapshot.
+func Snapshot(info SnapshotInfo, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "tui-wireguard-vpn %s (%s/%s) at %s\n", AppVersion(), runtime.GOOS, runtime.GOARCH, now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Tools: %s\n", toolVersions())
+	fmt.Fprintf(&b, "Config directory: %s\n", core.ConfigDir)
+
+	switch status := info.Status; {
+	case info.StatusErr != nil:
+		fmt.Fprintf(&b, "Status: check failed: %v\n", info.StatusErr)
+	case status == nil || !status.Connected:
+		b.WriteString("Status: disconnected\n")
+	default:
+		fmt.Fprintf(&b, "Status: connected to %s (%s)\n", status.Environment.DisplayName(), status.Interface)
+		endpoint := status.Endpoint
+		if status.EndpointHost != "" {
+			endpoint += " (" + status.EndpointHost + ")"
+		}
+		fmt.Fprintf(&b, "Endpoint: %s\n", endpoint)
+		if status.LastSeen != nil {
+			fmt.Fprintf(&b, "Handshake: %s ago\n", wgvpn.FormatDuration(now.Sub(*status.LastSeen)))
+		} else {
+			b.WriteString("Handshake: none yet\n")
+		}
+	}
+
+	if len(info.Errors) == 0 {
+		b.WriteString("Recent errors: none\n")
+	} else {
+		b.WriteString("Recent errors:\n")
+		for _, entry := range info.Errors {
+			// Redacted before clipping, which could cut a key short of
+			// what Redact recognizes
+			entry = clip(wgvpn.Redact(strings.TrimSpace(entry)), maxSnapshotError)
+			b.WriteString("  " + entry + "\n")
+		}
+	}
+
+	snapshot := wgvpn.RedactText(b.String())
+	return clip(snapshot, MaxSnapshot)
+}
+
+// clip shortens s to at most n bytes, ending in "..." when it had to, without
+// splitting a character.
+func clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n - 3
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+// AppVersion is the module version the binary was built from, or the VCS
+// revision for builds from a checkout.
+func AppVersion() string {
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if version := build.Main.Version; version != "" && version != "(devel)" {
+		return version
+	}
+	revision, modified := "", false
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return "devel " + revision
+}
+
+// toolVersions reports wg's version; wg-quick has none of its own and comes
+// from the same wireguard-tools release, so only its presence is checked.
+func toolVersions() string {
+	if check := checkTools(); check.Status != OK {
+		return check.Detail
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, "wg", "--version").Output()
+	if err != nil {
+		return "wg and wg-quick found, wg --version failed"
+	}
+	// "wireguard-tools v1.0.20210914 - https://git.zx2c4.com/wireguard-tools/"
+	version, _, _ := strings.Cut(strings.TrimSpace(string(output)), " - ")
+	return version + " (wg, wg-quick)"
+}
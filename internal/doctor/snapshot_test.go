@@ -0,0 +1,89 @@
+package doctor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+const (
+	privateKey   = "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	presharedKey = "FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE="
+	publicKey    = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg="
+)
+
+func TestSnapshotNeverCarriesKeyMaterial(t *testing.T) {
+	now := time.Date(2026, time.May, 11, 9, 0, 0, 0, time.UTC)
+	seen := now.Add(-90 * time.Second)
+	tests := []struct {
+		name string
+		info SnapshotInfo
+	}{
+		{"errors", SnapshotInfo{
+			Status: &wgvpn.ConnectionStatus{Connected: true, Environment: wgvpn.Production, Interface: "julo-prod", Endpoint: "34.101.166.184:51820", LastSeen: &seen},
+			Errors: []string{
+				"❌ Configuration update failed: line 2: PrivateKey = " + privateKey,
+				"❌ wg-quick: PresharedKey: " + presharedKey,
+				"❌ Failed to start Production VPN: peer " + publicKey + " rejected",
+			},
+		}},
+		// A key right where an entry is clipped is hidden before the cut
+		{"clipped", SnapshotInfo{Errors: []string{strings.Repeat("x", maxSnapshotError-20) + " " + privateKey}}},
+		{"status error", SnapshotInfo{StatusErr: errors.New("wg: bad key " + privateKey)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot := Snapshot(tt.info, now)
+			for _, key := range []string{privateKey, presharedKey, publicKey} {
+				// Even the start of one is too much
+				if strings.Contains(snapshot, key[:12]) {
+					t.Errorf("the snapshot holds key material:\n%s", snapshot)
+				}
+			}
+			if len(snapshot) > MaxSnapshot {
+				t.Errorf("the snapshot is %d bytes long", len(snapshot))
+			}
+		})
+	}
+}
+
+func TestSnapshotLength(t *testing.T) {
+	long := strings.Repeat("wg-quick: ", 100)
+	snapshot := Snapshot(SnapshotInfo{
+		StatusErr: errors.New(strings.Repeat("sudo: a password is required ", 100)),
+		Errors:    []string{long, long, long},
+	}, time.Now())
+	if len(snapshot) > MaxSnapshot || !utf8.ValidString(snapshot) {
+		t.Errorf("the snapshot is %d bytes long, valid UTF-8: %v", len(snapshot), utf8.ValidString(snapshot))
+	}
+
+	snapshot = Snapshot(SnapshotInfo{Errors: []string{long}}, time.Now())
+	for _, line := range strings.Split(snapshot, "\n") {
+		if strings.HasPrefix(line, "  ") && len(line) > maxSnapshotError+2 {
+			t.Errorf("an error entry is %d bytes long", len(line)-2)
+		}
+	}
+}
+
+func TestClip(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"a bit too long", 10, "a bit t..."},
+		// Never half of "é"
+		{"ééééé", 8, "éé..."},
+	}
+	for _, tt := range tests {
+		if got := clip(tt.s, tt.n); got != tt.want {
+			t.Errorf("clip(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
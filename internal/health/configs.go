@@ -0,0 +1,222 @@
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+)
+
+// ConfigsSchema is the version of the GET /v1/configs response. It only
+// changes when a field goes away or changes meaning; new fields may appear
+// without it.
+const ConfigsSchema = 1
+
+// ConfigsResponse is the body of GET /v1/configs.
+type ConfigsResponse struct {
+	Schema      int              `json:"schema"`
+	Host        string           `json:"host,omitempty"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Configs     []ConfigRevision `json:"configs"`
+}
+
+// ConfigRevision is which revision of an environment's template and config
+// is installed. It carries hashes and times only: no keys, no addresses, no
+// file contents.
+type ConfigRevision struct {
+	Environment    string `json:"environment"`
+	Installed      bool   `json:"installed"`
+	ConfigSHA256   string `json:"config_sha256,omitempty"`
+	TemplateSHA256 string `json:"template_sha256,omitempty"`
+	Routes         int    `json:"routes"`
+	// Source is how the config got there: "file", "generated", "server",
+	// "backup" or "provisioned"
+	Source           string     `json:"source,omitempty"`
+	InstalledAt      *time.Time `json:"installed_at,omitempty"`
+	TemplateSyncedAt *time.Time `json:"template_synced_at,omitempty"`
+	ConfigSyncedAt   *time.Time `json:"config_synced_at,omitempty"`
+	// Error is "unreadable" when the files couldn't be read; the reason
+	// stays in the agent's output
+	Error string `json:"error,omitempty"`
+}
+
+// ConfigsEndpoint configures GET /v1/configs, which a fleet dashboard polls
+// to see which config revisions are installed. It always requires a client
+// to authenticate, with Token as a bearer token or with a certificate signed
+// by ClientCA (which needs TLSCert and TLSKey), even on loopback.
+type ConfigsEndpoint struct {
+	Token    string
+	ClientCA string
+	TLSCert  string
+	TLSKey   string
+	Host     string
+	// Collect reads the revisions, one per environment
+	Collect func(ctx context.Context) []ConfigRevision
+}
+
+// Requests a client may make to /v1/configs in a burst, and how often it
+// earns another one
+const (
+	configsBurst    = 5
+	configsInterval = 10 * time.Second
+)
+
+// ConfigsHandler answers GET /v1/configs. Every request is recorded in the
+// audit log, refused ones too, except that a client's rate-limited requests
+// are recorded once per configsInterval: they would otherwise let anyone
+// fill the log. Clients are rate limited by address before their credentials
+// are looked at, so guessing tokens is slow as well.
+func ConfigsHandler(endpoint *ConfigsEndpoint) http.Handler {
+	limiter := newLimiter(configsBurst, configsInterval)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := clientAddress(r)
+		if now := time.Now(); !limiter.allow(client, now) {
+			refuse := func() error {
+				w.Header().Set("Retry-After", fmt.Sprintf("%.0f", configsInterval.Seconds()))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return errors.New("rate limited")
+			}
+			if limiter.firstRefusal(client, now) {
+				_ = audit.Run(audit.ActionConfigMetadata, "", "unauthenticated from "+client, refuse)
+			} else {
+				_ = refuse()
+			}
+			return
+		}
+
+		identity, authErr := authenticate(r, endpoint.Token)
+		_ = audit.Run(audit.ActionConfigMetadata, "", identity+" from "+client, func() error {
+			if authErr != nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return authErr
+			}
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return errors.New("method not allowed")
+			}
+
+			response := ConfigsResponse{
+				Schema:      ConfigsSchema,
+				Host:        endpoint.Host,
+				GeneratedAt: time.Now().UTC(),
+				Configs:     endpoint.Collect(r.Context()),
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			return json.NewEncoder(w).Encode(response)
+		})
+	})
+}
+
+// authenticate names the client: "cert <CN>" for a verified client
+// certificate, "token" for the bearer token. Without either the name is
+// "anonymous" and the request is refused.
+func authenticate(r *http.Request, token string) (string, error) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cert := r.TLS.VerifiedChains[0][0]
+		if cert.Subject.CommonName != "" {
+			return "cert " + cert.Subject.CommonName, nil
+		}
+		return "cert serial " + cert.SerialNumber.String(), nil
+	}
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "anonymous", errors.New("no credentials")
+	}
+	if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		return "anonymous", errors.New("wrong token")
+	}
+	return "token", nil
+}
+
+// clientAddress is the address the request came from, without the port.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limiter is a token bucket per client: burst requests at once, then one
+// per every.
+type limiter struct {
+	mu      sync.Mutex
+	burst   float64
+	every   time.Duration
+	clients map[string]*bucket
+}
+
+type bucket struct {
+	tokens   float64
+	at       time.Time
+	reported time.Time // last refusal worth recording
+}
+
+// Clients remembered before full buckets are forgotten
+const maxLimiterClients = 1024
+
+func newLimiter(burst int, every time.Duration) *limiter {
+	return &limiter{burst: float64(burst), every: every, clients: map[string]*bucket{}}
+}
+
+// allow takes a token from client's bucket at now, if it has one.
+func (l *limiter) allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.clients[client]
+	if !ok {
+		if len(l.clients) >= maxLimiterClients {
+			l.forgetFull(now)
+		}
+		b = &bucket{tokens: l.burst, at: now}
+		l.clients[client] = b
+	}
+	if elapsed := now.Sub(b.at); elapsed > 0 {
+		b.tokens = min(l.burst, b.tokens+elapsed.Seconds()/l.every.Seconds())
+		b.at = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// firstRefusal reports whether refusing client at now is the first refusal
+// in every, the one worth recording, and notes it if so.
+func (l *limiter) firstRefusal(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.clients[client]
+	if !ok {
+		return true
+	}
+	if !b.reported.IsZero() && now.Sub(b.reported) < l.every {
+		return false
+	}
+	b.reported = now
+	return true
+}
+
+// forgetFull drops the clients whose buckets have refilled: they are as
+// good as new.
+func (l *limiter) forgetFull(now time.Time) {
+	for client, b := range l.clients {
+		if b.tokens+now.Sub(b.at).Seconds()/l.every.Seconds() >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+)
+
+func configsEndpoint(token string) *ConfigsEndpoint {
+	return &ConfigsEndpoint{
+		Token: token,
+		Host:  "laptop-42",
+		Collect: func(context.Context) []ConfigRevision {
+			return []ConfigRevision{{Environment: "prod", Installed: true, ConfigSHA256: "9f86d081884c7d65", Routes: 3, Source: "server"}}
+		},
+	}
+}
+
+func TestConfigsAuthentication(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		given      string
+		code       int
+	}{
+		{"no credentials", "s3cret", "", http.StatusUnauthorized},
+		{"wrong token", "s3cret", "guess", http.StatusUnauthorized},
+		{"right token", "s3cret", "s3cret", http.StatusOK},
+		// Only a client CA configured: no token lets anyone in
+		{"empty token configured", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+			rec := get(t, ConfigsHandler(configsEndpoint(tt.configured)), http.MethodGet, "/v1/configs", tt.given)
+			if rec.Code != tt.code {
+				t.Fatalf("GET /v1/configs = %d %q, want %d", rec.Code, rec.Body.String(), tt.code)
+			}
+			if tt.code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("WWW-Authenticate = %q", rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestConfigsResponse(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	rec := get(t, ConfigsHandler(configsEndpoint("s3cret")), http.MethodGet, "/v1/configs", "s3cret")
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("headers %v", rec.Header())
+	}
+	var response ConfigsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Schema != ConfigsSchema || response.Host != "laptop-42" || len(response.Configs) != 1 || response.Configs[0].Routes != 3 {
+		t.Errorf("response %+v", response)
+	}
+
+	if rec := get(t, ConfigsHandler(configsEndpoint("s3cret")), http.MethodPost, "/v1/configs", "s3cret"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /v1/configs = %d, want 405", rec.Code)
+	}
+}
+
+func TestConfigsRateLimit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	h := ConfigsHandler(configsEndpoint("s3cret"))
+	// Wrong tokens spend the budget too
+	for i := range configsBurst {
+		token := "s3cret"
+		if i%2 == 1 {
+			token = "guess"
+		}
+		if rec := get(t, h, http.MethodGet, "/v1/configs", token); rec.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d limited", i+1)
+		}
+	}
+	rec := get(t, h, http.MethodGet, "/v1/configs", "s3cret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("request %d = %d, Retry-After %q; want 429 after 10s", configsBurst+1, rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestConfigsRequestsAreAudited(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	h := ConfigsHandler(configsEndpoint("s3cret"))
+	get(t, h, http.MethodGet, "/v1/configs", "s3cret")
+	get(t, h, http.MethodGet, "/v1/configs", "guess")
+
+	entries, err := audit.Recent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ target, result string }{
+		{"token from 192.0.2.1", "ok"},
+		{"anonymous from 192.0.2.1", "failed"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("%d audit entries, want %d: %v", len(entries), len(want), entries)
+	}
+	for i, entry := range entries {
+		if entry.Action != audit.ActionConfigMetadata || entry.Target != want[i].target || entry.Result != want[i].result {
+			t.Errorf("entry %d: %s", i, entry)
+		}
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	l := newLimiter(2, 10*time.Second)
+	t0 := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	steps := []struct {
+		client string
+		at     time.Duration
+		allow  bool
+	}{
+		{"a", 0, true},
+		{"a", 0, true},
+		{"a", time.Second, false},
+		{"b", time.Second, true}, // a bucket per client
+		{"a", 10 * time.Second, true},
+		{"a", 10 * time.Second, false},
+		{"a", time.Hour, true}, // refills up to the burst only
+		{"a", time.Hour, true},
+		{"a", time.Hour, false},
+	}
+	for i, s := range steps {
+		if got := l.allow(s.client, t0.Add(s.at)); got != s.allow {
+			t.Errorf("step %d: allow(%s) = %v, want %v", i, s.client, got, s.allow)
+		}
+	}
+}
+
+// Rate-limited requests aren't authenticated, and are recorded once per
+// interval: a client refused anyway can't fill the audit log.
+func TestConfigsRateLimitAudit(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	h := ConfigsHandler(configsEndpoint("s3cret"))
+	for range configsBurst + 3 {
+		get(t, h, http.MethodGet, "/v1/configs", "s3cret")
+	}
+	entries, err := audit.Recent(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != configsBurst+1 {
+		t.Fatalf("%d audit entries, want %d: %v", len(entries), configsBurst+1, entries)
+	}
+	if last := entries[len(entries)-1]; last.Target != "unauthenticated from 192.0.2.1" || last.Result != "failed" {
+		t.Errorf("the refusal is recorded as %s", last)
+	}
+}
+
+func TestLimiterFirstRefusal(t *testing.T) {
+	l := newLimiter(1, 10*time.Second)
+	t0 := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	l.allow("a", t0)
+	steps := []struct {
+		client string
+		at     time.Duration
+		first  bool
+	}{
+		{"a", time.Second, true},
+		{"a", 2 * time.Second, false},
+		{"a", 10 * time.Second, false},
+		{"b", 10 * time.Second, true}, // a window per client
+		{"a", 11 * time.Second, true},
+		{"a", 12 * time.Second, false},
+	}
+	for i, s := range steps {
+		if got := l.firstRefusal(s.client, t0.Add(s.at)); got != s.first {
+			t.Errorf("step %d: firstRefusal(%s) = %v, want %v", i, s.client, got, s.first)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package health serves the tunnel state over HTTP for monitoring: GET
+// /healthz for up/down checks and GET /metrics in the Prometheus text format.
+// With a ConfigsEndpoint it also serves GET /v1/configs, the installed
+// config revisions for a fleet dashboard. ServePrompt answers the shell
+// prompt status on a unix socket.
+//
+// The server binds only the address it is given. On a loopback address no
+// authentication is required for /healthz and /metrics; anywhere else a
+// bearer token must be configured, and every request has to present it.
+// /v1/configs always requires its own credentials.
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// How long in-flight scrapes get to finish on shutdown
+const shutdownTimeout = 5 * time.Second
+
+// Upper bound for answering one request; GetStatus runs wg under sudo
+const requestTimeout = 10 * time.Second
+
+// Handler answers /healthz and /metrics from svc's status. A non-empty token
+// is required as "Authorization: Bearer <token>" on every request.
+func Handler(svc vpn.Service, token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status, err := svc.GetStatus()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "unknown: %v\n", err)
+			return
+		}
+		for _, env := range vpn.Environments() {
+			if vpn.Healthy(status, env, vpn.DefaultHandshakeAge) {
+				fmt.Fprintf(w, "ok: %s connected\n", env)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		switch {
+		case status == nil || !status.Connected:
+			fmt.Fprintln(w, "down: no tunnel connected")
+		case status.LastSeen == nil:
+			fmt.Fprintf(w, "down: %s has no handshake\n", status.Environment)
+		default:
+			fmt.Fprintf(w, "down: %s handshake is %s old\n", status.Environment, time.Since(*status.LastSeen).Truncate(time.Second))
+		}
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		status, err := svc.GetStatus()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("status unavailable: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, status)
+	})
+
+	return http.TimeoutHandler(guard(mux, token), requestTimeout, "status timed out\n")
+}
+
+// guard only lets GET and HEAD through, with the token when there is one.
+func guard(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeMetrics writes one sample per profile; only the connected profile has
+// a handshake age and transfer counters.
+func writeMetrics(w http.ResponseWriter, status *vpn.ConnectionStatus) {
+	type sample struct {
+		env   vpn.Environment
+		value string
+	}
+	metric := func(name, kind, help string, samples []sample) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		for _, s := range samples {
+			fmt.Fprintf(w, "%s{profile=%q} %s\n", name, string(s.env), s.value)
+		}
+	}
+
+	var connected, handshake, rx, tx []sample
+	for _, env := range vpn.Environments() {
+		up := status != nil && status.Connected && status.Environment == env
+		value := "0"
+		if up {
+			value = "1"
+		}
+		connected = append(connected, sample{env, value})
+		if !up {
+			continue
+		}
+		if status.LastSeen != nil {
+			age := time.Since(*status.LastSeen).Seconds()
+			handshake = append(handshake, sample{env, fmt.Sprintf("%.0f", age)})
+		}
+		rx = append(rx, sample{env, fmt.Sprintf("%d", status.BytesRx)})
+		tx = append(tx, sample{env, fmt.Sprintf("%d", status.BytesTx)})
+	}
+
+	metric("wgvpn_connected", "gauge", "Whether the profile's tunnel is up.", connected)
+	metric("wgvpn_handshake_age_seconds", "gauge", "Seconds since the latest handshake of the connected profile.", handshake)
+	metric("wgvpn_rx_bytes_total", "counter", "Bytes received through the connected profile's tunnel.", rx)
+	metric("wgvpn_tx_bytes_total", "counter", "Bytes sent through the connected profile's tunnel.", tx)
+}
+
+// Loopback reports whether addr (host:port) only listens on the local
+// machine. An empty host listens everywhere.
+func Loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve listens on addr and answers until ctx is cancelled, then lets
+// in-flight requests finish. Listening beyond loopback without a token is
+// refused. configs, when not nil, adds /v1/configs and may switch the
+// server to HTTPS.
+func Serve(ctx context.Context, addr string, svc vpn.Service, token string, configs *ConfigsEndpoint) error {
+	if !Loopback(addr) && token == "" {
+		return fmt.Errorf("%s is not a loopback address: a bearer token is required", addr)
+	}
+	handler := Handler(svc, token)
+	var tlsConfig *tls.Config
+	if configs != nil {
+		if configs.Token == "" && configs.ClientCA == "" {
+			return fmt.Errorf("/v1/configs needs a token or a client CA")
+		}
+		var err error
+		if tlsConfig, err = configsTLS(configs); err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/v1/configs", http.TimeoutHandler(ConfigsHandler(configs), requestTimeout, "configs timed out\n"))
+		mux.Handle("/", handler)
+		handler = mux
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %v", err)
+	}
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// configsTLS loads the server certificate and the client CAs of configs,
+// nil when the server stays on plain HTTP. A client certificate is asked
+// for but not required, so bearer tokens keep working over HTTPS.
+func configsTLS(configs *ConfigsEndpoint) (*tls.Config, error) {
+	if configs.TLSCert == "" {
+		if configs.ClientCA != "" {
+			return nil, fmt.Errorf("a client CA needs a TLS certificate and key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(configs.TLSCert, configs.TLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate %s: %v", configs.TLSCert, err)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if configs.ClientCA != "" {
+		pem, err := os.ReadFile(configs.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in client CA %s", configs.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
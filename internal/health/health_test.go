@@ -0,0 +1,164 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// fakeService answers GetStatus; the handlers use nothing else.
+type fakeService struct {
+	vpn.Service
+	status *vpn.ConnectionStatus
+	err    error
+}
+
+func (f fakeService) GetStatus() (*vpn.ConnectionStatus, error) {
+	return f.status, f.err
+}
+
+func get(t *testing.T, h http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func connected(handshakeAge time.Duration) *vpn.ConnectionStatus {
+	lastSeen := time.Now().Add(-handshakeAge)
+	return &vpn.ConnectionStatus{
+		Connected:   true,
+		Environment: vpn.Production,
+		Interface:   "julo-prod",
+		LastSeen:    &lastSeen,
+		BytesRx:     7319060,
+		BytesTx:     24568012,
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	tests := []struct {
+		name   string
+		svc    fakeService
+		code   int
+		prefix string
+	}{
+		{"fresh handshake", fakeService{status: connected(30 * time.Second)}, http.StatusOK, "ok: prod connected"},
+		{"stale handshake", fakeService{status: connected(10 * time.Minute)}, http.StatusServiceUnavailable, "down: prod handshake is 10m0s old"},
+		{"no handshake", fakeService{status: &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production}}, http.StatusServiceUnavailable, "down: prod has no handshake"},
+		{"disconnected", fakeService{status: &vpn.ConnectionStatus{}}, http.StatusServiceUnavailable, "down: no tunnel connected"},
+		{"status fails", fakeService{err: errors.New("wg: permission denied")}, http.StatusServiceUnavailable, "unknown: wg: permission denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := get(t, Handler(tt.svc, ""), http.MethodGet, "/healthz", "")
+			if rec.Code != tt.code || !strings.HasPrefix(rec.Body.String(), tt.prefix) {
+				t.Errorf("GET /healthz = %d %q, want %d %q", rec.Code, rec.Body.String(), tt.code, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	rec := get(t, Handler(fakeService{status: connected(42 * time.Second)}, ""), http.MethodGet, "/metrics", "")
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("GET /metrics = %d, %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	for _, line := range []string{
+		"# TYPE wgvpn_connected gauge",
+		`wgvpn_connected{profile="prod"} 1`,
+		`wgvpn_connected{profile="nonprod"} 0`,
+		`wgvpn_handshake_age_seconds{profile="prod"} 42`,
+		"# TYPE wgvpn_rx_bytes_total counter",
+		`wgvpn_rx_bytes_total{profile="prod"} 7319060`,
+		`wgvpn_tx_bytes_total{profile="prod"} 24568012`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, rec.Body.String())
+		}
+	}
+	// Only the connected profile has handshake and transfer samples
+	if strings.Contains(rec.Body.String(), `_total{profile="nonprod"}`) {
+		t.Errorf("metrics of a profile that is down:\n%s", rec.Body.String())
+	}
+
+	rec = get(t, Handler(fakeService{err: errors.New("wg not found")}, ""), http.MethodGet, "/metrics", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("GET /metrics with status failing = %d", rec.Code)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	svc := fakeService{status: connected(time.Second)}
+	tests := []struct {
+		name   string
+		token  string // the server's
+		method string
+		given  string
+		code   int
+	}{
+		{"no token configured", "", http.MethodGet, "", http.StatusOK},
+		{"HEAD", "", http.MethodHead, "", http.StatusOK},
+		{"POST", "", http.MethodPost, "", http.StatusMethodNotAllowed},
+		{"token given", "s3cret", http.MethodGet, "s3cret", http.StatusOK},
+		{"token missing", "s3cret", http.MethodGet, "", http.StatusUnauthorized},
+		{"token wrong", "s3cret", http.MethodGet, "s3cret-not", http.StatusUnauthorized},
+		{"unauthorized before method", "s3cret", http.MethodPost, "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := get(t, Handler(svc, tt.token), tt.method, "/healthz", tt.given); rec.Code != tt.code {
+				t.Errorf("%s /healthz = %d, want %d", tt.method, rec.Code, tt.code)
+			}
+		})
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:9821": true,
+		"[::1]:9821":     true,
+		"localhost:9821": true,
+		"127.0.0.2:9821": true,
+		"0.0.0.0:9821":   false,
+		":9821":          false,
+		"10.9.0.2:9821":  false,
+		"127.0.0.1":      false,
+	}
+	for addr, want := range tests {
+		if got := Loopback(addr); got != want {
+			t.Errorf("Loopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	svc := fakeService{status: connected(time.Second)}
+	if err := Serve(context.Background(), "0.0.0.0:0", svc, "", nil); err == nil || !strings.Contains(err.Error(), "bearer token is required") {
+		t.Errorf("Serve beyond loopback without a token = %v", err)
+	}
+
+	// Shuts down cleanly with the agent
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, "127.0.0.1:0", svc, "", nil) }()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve() = %v after cancel", err)
+		}
+	case <-time.After(shutdownTimeout):
+		t.Error("Serve didn't return after cancel")
+	}
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"time"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// ServePrompt answers the prompt status (status --prompt) on the unix socket
+// at path until ctx is cancelled. Each connection gets the environments
+// whose tunnels svc reports up; a status that can't be read within
+// vpn.PromptAgentTimeout gets no answer, and the prompt checks the
+// interfaces itself.
+func ServePrompt(ctx context.Context, path string, svc vpn.Service) error {
+	if conn, err := net.DialTimeout("unix", path, vpn.PromptAgentTimeout); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another agent", path)
+	}
+	// A socket left by an agent that didn't get to remove it
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go answerPrompt(ctx, conn, svc)
+	}
+}
+
+func answerPrompt(ctx context.Context, conn net.Conn, svc vpn.Service) {
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(ctx, vpn.PromptAgentTimeout)
+	defer cancel()
+	conn.SetDeadline(time.Now().Add(vpn.PromptAgentTimeout))
+	tunnels, err := svc.Tunnels(ctx)
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+	up := make([]vpn.Environment, len(tunnels))
+	for i, tunnel := range tunnels {
+		up[i] = tunnel.Environment
+	}
+	vpn.WritePromptAnswer(conn, up)
+}
@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"tui-wireguard-vpn/internal/paths"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// tunnelService reports the tunnels up; the prompt uses nothing else.
+type tunnelService struct {
+	vpn.Service
+	up []vpn.Environment
+}
+
+func (s tunnelService) Tunnels(context.Context) ([]*vpn.ConnectionStatus, error) {
+	var tunnels []*vpn.ConnectionStatus
+	for _, env := range s.up {
+		tunnels = append(tunnels, &vpn.ConnectionStatus{Connected: true, Environment: env, Interface: env.Interface()})
+	}
+	return tunnels, nil
+}
+
+func TestServePrompt(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	socket, err := paths.EnsureFile(paths.Runtime, vpn.AgentSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- ServePrompt(ctx, socket, tunnelService{up: []vpn.Environment{vpn.NonProduction}}) }()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			break
+		} else if time.Now().After(deadline) {
+			t.Fatalf("the agent isn't listening: %v", err)
+		}
+	}
+
+	if segment, code := vpn.PromptStatus(); segment != "wg:nonprod" || code != 0 {
+		t.Errorf("PromptStatus() = %q, %d from the agent", segment, code)
+	}
+	if err := ServePrompt(ctx, socket, tunnelService{}); err == nil || !strings.Contains(err.Error(), "in use") {
+		t.Errorf("a second ServePrompt() = %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("ServePrompt() = %v after cancel", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("ServePrompt didn't return after cancel")
+	}
+}
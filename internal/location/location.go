@@ -0,0 +1,193 @@
+// Package location recognizes the network the machine is on (the office LAN,
+// home) from the rules in the settings file, so the VPN can be skipped where
+// it isn't needed and connected where it is.
+//
+// Rule evaluation (Match) only looks at Facts, so it can be checked against
+// synthetic networks; Gather collects the facts of the real one.
+package location
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/settings"
+)
+
+// Upper bound for one probe host connection
+const probeTimeout = 2 * time.Second
+
+const airportPath = "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
+
+// Facts describe the current network.
+type Facts struct {
+	Interface string // interface of the default route, "" when offline
+	Address   string // local address on it
+	SSID      string // Wi-Fi network, "" when unknown or wired
+	// Reachable holds the probe hosts that answered
+	Reachable map[string]bool
+}
+
+// Matches reports whether every criterion loc sets holds for facts. A rule
+// without criteria never matches.
+func Matches(loc *settings.Location, facts Facts) bool {
+	criteria := 0
+	if loc.Interface != "" {
+		criteria++
+		if loc.Interface != facts.Interface {
+			return false
+		}
+	}
+	if loc.SSID != "" {
+		criteria++
+		if loc.SSID != facts.SSID {
+			return false
+		}
+	}
+	if loc.Subnet != "" {
+		criteria++
+		prefix, err := netip.ParsePrefix(loc.Subnet)
+		addr, addrErr := netip.ParseAddr(facts.Address)
+		if err != nil || addrErr != nil || !prefix.Contains(addr.Unmap()) {
+			return false
+		}
+	}
+	if loc.ProbeHost != "" {
+		criteria++
+		if !facts.Reachable[loc.ProbeHost] {
+			return false
+		}
+	}
+	return criteria > 0
+}
+
+// Match returns the first location matching facts, or nil.
+func Match(locations []*settings.Location, facts Facts) *settings.Location {
+	for _, loc := range locations {
+		if Matches(loc, facts) {
+			return loc
+		}
+	}
+	return nil
+}
+
+// Detect gathers the facts the rules need and matches them.
+func Detect(ctx context.Context, locations []*settings.Location) (*settings.Location, Facts) {
+	facts := Gather(ctx, locations)
+	return Match(locations, facts), facts
+}
+
+// Gather collects the facts of the current network. The SSID is only looked
+// up, and probe hosts only contacted, when a rule asks for them.
+func Gather(ctx context.Context, locations []*settings.Location) Facts {
+	var facts Facts
+	facts.Interface, facts.Address = DefaultRoute()
+	facts.Reachable = map[string]bool{}
+
+	needSSID := false
+	var hosts []string
+	for _, loc := range locations {
+		needSSID = needSSID || loc.SSID != ""
+		if loc.ProbeHost != "" {
+			hosts = append(hosts, loc.ProbeHost)
+		}
+	}
+	if needSSID {
+		facts.SSID = currentSSID(ctx)
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			ok := reachable(ctx, host)
+			mu.Lock()
+			facts.Reachable[host] = ok
+			mu.Unlock()
+		}(host)
+	}
+	wg.Wait()
+	return facts
+}
+
+// DefaultRoute returns the interface and local address the default route
+// uses, or "" for both when offline. The UDP "connection" only selects a
+// route; no packet is sent.
+func DefaultRoute() (iface, addr string) {
+	conn, err := net.Dial("udp", "192.0.2.1:9")
+	if err != nil {
+		return "", ""
+	}
+	ip := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	if ifaces, err := net.Interfaces(); err == nil {
+		for _, candidate := range ifaces {
+			addrs, _ := candidate.Addrs()
+			for _, a := range addrs {
+				if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+					iface = candidate.Name
+				}
+			}
+		}
+	}
+	return iface, ip.String()
+}
+
+// reachable reports whether host (host:port, port 443 when omitted) accepts
+// a TCP connection.
+func reachable(ctx context.Context, host string) bool {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "443")
+	}
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", host)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// currentSSID asks NetworkManager or, on macOS, airport for the Wi-Fi
+// network. Missing tools just mean no SSID.
+func currentSSID(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if runtime.GOOS == "darwin" {
+		output, err := exec.CommandContext(ctx, airportPath, "-I").Output()
+		if err != nil {
+			return ""
+		}
+		// "           SSID: Office-WiFi"
+		for _, line := range strings.Split(string(output), "\n") {
+			if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "SSID" {
+				return strings.TrimSpace(value)
+			}
+		}
+		return ""
+	}
+
+	output, err := exec.CommandContext(ctx, "nmcli", "-t", "-f", "active,ssid", "dev", "wifi").Output()
+	if err != nil {
+		return ""
+	}
+	// "yes:Office-WiFi" for the connected network
+	for _, line := range strings.Split(string(output), "\n") {
+		if ssid, ok := strings.CutPrefix(line, "yes:"); ok {
+			return strings.ReplaceAll(ssid, `\:`, ":")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package location
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"tui-wireguard-vpn/internal/settings"
+)
+
+func TestMatches(t *testing.T) {
+	office := Facts{
+		Interface: "eth0",
+		Address:   "10.20.4.17",
+		SSID:      "Office-WiFi",
+		Reachable: map[string]bool{"intranet.julo.local": true},
+	}
+	tests := []struct {
+		name  string
+		loc   settings.Location
+		facts Facts
+		want  bool
+	}{
+		{"no criteria", settings.Location{Name: "anywhere"}, office, false},
+		{"interface", settings.Location{Interface: "eth0"}, office, true},
+		{"other interface", settings.Location{Interface: "wlan0"}, office, false},
+		{"SSID", settings.Location{SSID: "Office-WiFi"}, office, true},
+		{"SSID when wired", settings.Location{SSID: "Office-WiFi"}, Facts{Interface: "eth0"}, false},
+		{"subnet", settings.Location{Subnet: "10.20.0.0/16"}, office, true},
+		{"other subnet", settings.Location{Subnet: "192.168.1.0/24"}, office, false},
+		{"bad subnet", settings.Location{Subnet: "10.20.0.0"}, office, false},
+		{"subnet offline", settings.Location{Subnet: "10.20.0.0/16"}, Facts{}, false},
+		{"IPv4-mapped address", settings.Location{Subnet: "10.20.0.0/16"}, Facts{Address: "::ffff:10.20.4.17"}, true},
+		{"probe host", settings.Location{ProbeHost: "intranet.julo.local"}, office, true},
+		{"probe host unreachable", settings.Location{ProbeHost: "nas.home.arpa"}, office, false},
+		{"every criterion", settings.Location{Interface: "eth0", SSID: "Office-WiFi", Subnet: "10.20.0.0/16", ProbeHost: "intranet.julo.local"}, office, true},
+		{"one criterion off", settings.Location{Interface: "eth0", SSID: "Home"}, office, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Matches(&tt.loc, tt.facts); got != tt.want {
+				t.Errorf("Matches() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// Rules are tried in declaration order; the first match wins.
+func TestMatch(t *testing.T) {
+	locations := []*settings.Location{
+		{Name: "office", Subnet: "10.20.0.0/16", VPN: "skip"},
+		{Name: "office-guest", SSID: "Office-Guest", VPN: "connect"},
+		{Name: "lan", Subnet: "10.0.0.0/8", VPN: "connect"},
+	}
+	tests := []struct {
+		facts Facts
+		want  string
+	}{
+		{Facts{Address: "10.20.4.17", SSID: "Office-Guest"}, "office"},
+		{Facts{Address: "172.16.0.9", SSID: "Office-Guest"}, "office-guest"},
+		{Facts{Address: "10.1.1.1"}, "lan"},
+		{Facts{Address: "192.168.1.20"}, ""},
+	}
+	for _, tt := range tests {
+		name := ""
+		if got := Match(locations, tt.facts); got != nil {
+			name = got.Name
+		}
+		if name != tt.want {
+			t.Errorf("Match(%+v) = %q, want %q", tt.facts, name, tt.want)
+		}
+	}
+}
+
+// Gather contacts the probe hosts rules name, and only those.
+func TestGatherProbeHosts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	open := listener.Addr().String()
+	facts := Gather(context.Background(), []*settings.Location{
+		{Name: "office", ProbeHost: open},
+		{Name: "home", Subnet: "192.168.1.0/24"},
+		{Name: "lab", ProbeHost: closedAddr},
+	})
+	if len(facts.Reachable) != 2 || !facts.Reachable[open] || facts.Reachable[closedAddr] {
+		t.Errorf("Reachable = %v, want %s alone reachable of two", facts.Reachable, open)
+	}
+	if facts.SSID != "" {
+		t.Errorf("SSID = %q looked up without a rule asking", facts.SSID)
+	}
+}
@@ -0,0 +1,169 @@
+// Package mfa is the one-time code check a profile can require before its
+// tunnel is started (mfa in [profiles.<name>]). A code is checked either as a
+// TOTP (RFC 6238) against a secret kept in the OS keyring, or by an external
+// command whose exit code decides.
+//
+// Secrets never pass through the settings file, and neither secrets nor codes
+// are logged: every attempt is recorded in the audit log with its outcome
+// only.
+package mfa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/paths"
+)
+
+const (
+	ModeTOTP    = "totp"
+	ModeCommand = "command"
+
+	// Digits is the length of a code.
+	Digits = 6
+	// MaxAttempts is how many wrong codes one Start allows.
+	MaxAttempts = 3
+
+	step = 30 * time.Second
+)
+
+// ErrInvalidCode is a code that was checked and rejected.
+var ErrInvalidCode = errors.New("invalid code")
+
+// Gate is the check a profile requires before Start.
+type Gate struct {
+	Profile string
+	Mode    string // ModeTOTP or ModeCommand
+	// Command is run with "sh -c" for ModeCommand. The code is written to
+	// its stdin, never passed as an argument where ps would show it.
+	Command string
+}
+
+// ValidFormat reports whether code looks like a code: exactly Digits digits.
+func ValidFormat(code string) bool {
+	if len(code) != Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Check verifies code and records the attempt in the audit log. A code that
+// doesn't match is ErrInvalidCode; any other error means the check itself
+// couldn't run (no secret in the keyring, command not found, ...).
+func (g Gate) Check(ctx context.Context, code string) error {
+	return audit.Run(audit.ActionMFA, g.Profile, g.Mode, func() error {
+		return g.check(ctx, code)
+	})
+}
+
+func (g Gate) check(ctx context.Context, code string) error {
+	if !ValidFormat(code) {
+		return fmt.Errorf("code must be %d digits", Digits)
+	}
+	switch g.Mode {
+	case ModeTOTP:
+		secret, err := keyringSecret(ctx, g.Profile)
+		if err != nil {
+			return err
+		}
+		key, err := DecodeSecret(secret)
+		if err != nil {
+			// The decode error would quote the secret
+			return fmt.Errorf("the TOTP secret for %s in the OS keyring is not valid base32", g.Profile)
+		}
+		if !Valid(key, code, time.Now()) {
+			return ErrInvalidCode
+		}
+		return nil
+	case ModeCommand:
+		cmd := exec.CommandContext(ctx, "sh", "-c", g.Command)
+		cmd.Stdin = strings.NewReader(code + "\n")
+		if err := cmd.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return ErrInvalidCode
+			}
+			return fmt.Errorf("failed to run MFA command: %v", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown MFA mode %q", g.Mode)
+}
+
+// Code returns the TOTP code of key at t: HMAC-SHA1 over 30-second steps,
+// 6 digits, as authenticator apps compute it.
+func Code(key []byte, t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix())/uint64(step/time.Second))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
+
+// Valid reports whether code is key's code at now or one step either side,
+// for clocks that drift. Every candidate is compared in constant time, and
+// all of them are, so the timing says nothing about which one matched.
+func Valid(key []byte, code string, now time.Time) bool {
+	match := 0
+	for _, skew := range []time.Duration{-step, 0, step} {
+		match |= subtle.ConstantTimeCompare([]byte(Code(key, now.Add(skew))), []byte(code))
+	}
+	return match == 1
+}
+
+// DecodeSecret decodes a base32 TOTP secret the way authenticator apps show
+// it: any case, optionally grouped with spaces, padding optional.
+func DecodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Join(strings.Fields(secret), ""))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+}
+
+// keyringSecret looks up profile's TOTP secret in the OS keyring: the Secret
+// Service (secret-tool) on Linux and the BSDs, the login keychain on macOS.
+func keyringSecret(ctx context.Context, profile string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", paths.AppName, "-a", profile, "-w")
+	case "windows":
+		return "", fmt.Errorf("TOTP secrets in the OS keyring aren't supported on Windows; use mfa = \"command\"")
+	default:
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", paths.AppName, "account", profile)
+	}
+	// Output's error never includes stdout, where the secret is
+	output, err := cmd.Output()
+	secret := strings.TrimSpace(string(output))
+	if err != nil || secret == "" {
+		return "", fmt.Errorf("no TOTP secret for %s in the OS keyring (store it with: %s)", profile, StoreCommand(profile))
+	}
+	return secret, nil
+}
+
+// StoreCommand is the command that stores profile's TOTP secret where
+// keyringSecret looks for it; it prompts for the secret.
+func StoreCommand(profile string) string {
+	if runtime.GOOS == "darwin" {
+		return fmt.Sprintf("security add-generic-password -s %s -a %s -w", paths.AppName, profile)
+	}
+	return fmt.Sprintf("secret-tool store --label='%s %s' service %s account %s", paths.AppName, profile, paths.AppName, profile)
+}
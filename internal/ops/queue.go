@@ -0,0 +1,161 @@
+// Package ops keeps the tunnel operations the TUI starts (Start and Stop)
+// from racing each other: one runs at a time, and at most one more waits
+// behind it.
+//
+// An operation moves through Queued → Running → Done, Failed or Cancelled;
+// it starts out Running when nothing else is. A request for the operation
+// that is already running is refused with a BusyError. Any other request
+// waits, replacing the one queued before it, since only the user's latest
+// choice matters.
+package ops
+
+import (
+	"fmt"
+	"time"
+)
+
+type State int
+
+const (
+	Queued State = iota
+	Running
+	Done
+	Failed
+	Cancelled
+)
+
+func (s State) String() string {
+	switch s {
+	case Queued:
+		return "queued"
+	case Running:
+		return "running"
+	case Done:
+		return "done"
+	case Failed:
+		return "failed"
+	case Cancelled:
+		return "cancelled"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Op is an operation request.
+type Op struct {
+	// Key identifies what to run, e.g. "start_prod"; requests with the same
+	// key are the same operation
+	Key string
+	// Name describes it in messages, e.g. "starting Production"
+	Name string
+}
+
+// Transition is an operation entering a state.
+type Transition struct {
+	Op    Op
+	State State
+}
+
+func (t Transition) String() string {
+	return fmt.Sprintf("%s: %s", t.Op.Name, t.State)
+}
+
+// BusyError refuses a request while the same operation is running.
+type BusyError struct {
+	Running Op
+	For     time.Duration // how long it has been running
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("busy: %s (%s) — press c to cancel", e.Running.Name, e.For.Round(time.Second))
+}
+
+// Queue is the state machine. The zero value is an idle queue.
+type Queue struct {
+	running    *Op
+	started    time.Time
+	cancelling bool
+	queued     *Op
+}
+
+// Running returns the running operation and when it started.
+func (q *Queue) Running() (Op, time.Time, bool) {
+	if q.running == nil {
+		return Op{}, time.Time{}, false
+	}
+	return *q.running, q.started, true
+}
+
+// Queued returns the operation waiting behind the running one.
+func (q *Queue) Queued() (Op, bool) {
+	if q.queued == nil {
+		return Op{}, false
+	}
+	return *q.queued, true
+}
+
+// Cancelling reports whether the running operation was asked to stop.
+func (q *Queue) Cancelling() bool {
+	return q.cancelling
+}
+
+// Submit requests op at now. run reports whether the caller should start it
+// right away; otherwise it is queued, or refused with a *BusyError.
+func (q *Queue) Submit(op Op, now time.Time) (run bool, transitions []Transition, err error) {
+	if q.running == nil {
+		q.running, q.started, q.cancelling = &op, now, false
+		return true, []Transition{{op, Running}}, nil
+	}
+	// Asking again for what is cancelling is a retry, which has to wait
+	if op.Key == q.running.Key && !q.cancelling {
+		return false, nil, &BusyError{Running: *q.running, For: now.Sub(q.started)}
+	}
+	if q.queued != nil {
+		if q.queued.Key == op.Key {
+			return false, nil, nil
+		}
+		transitions = append(transitions, Transition{*q.queued, Cancelled})
+	}
+	q.queued = &op
+	return false, append(transitions, Transition{op, Queued}), nil
+}
+
+// Cancel drops the queued operation if there is one. Otherwise the running
+// operation is marked cancelling and cancelRunning tells the caller to stop
+// it; it still ends with Finish.
+func (q *Queue) Cancel() (cancelRunning bool, transitions []Transition) {
+	switch {
+	case q.queued != nil:
+		transitions = []Transition{{*q.queued, Cancelled}}
+		q.queued = nil
+		return false, transitions
+	case q.running != nil && !q.cancelling:
+		q.cancelling = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// Finish ends the running operation and starts the queued one, which the
+// caller then runs. An operation cancelled while running ends Cancelled
+// whatever its outcome.
+func (q *Queue) Finish(success bool, now time.Time) (next *Op, transitions []Transition) {
+	if q.running == nil {
+		return nil, nil
+	}
+	end := Done
+	switch {
+	case q.cancelling:
+		end = Cancelled
+	case !success:
+		end = Failed
+	}
+	transitions = []Transition{{*q.running, end}}
+	q.running, q.cancelling = nil, false
+
+	if q.queued != nil {
+		next, q.queued = q.queued, nil
+		q.running, q.started = next, now
+		transitions = append(transitions, Transition{*next, Running})
+	}
+	return next, transitions
+}
@@ -0,0 +1,265 @@
+package ops
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	startProd    = Op{Key: "start_prod", Name: "starting Production"}
+	startNonprod = Op{Key: "start_nonprod", Name: "starting Non-Production"}
+	stop         = Op{Key: "stop", Name: "stopping VPN"}
+)
+
+// An event happens to the queue at a time and describes what came of it:
+// the transitions, then what the caller is told to do.
+type event func(q *Queue, now time.Time) string
+
+func submit(op Op) event {
+	return func(q *Queue, now time.Time) string {
+		run, transitions, err := q.Submit(op, now)
+		var busy *BusyError
+		if errors.As(err, &busy) {
+			return "busy: " + busy.Running.Name + " for " + busy.For.String()
+		}
+		return outcome(transitions, run, "run")
+	}
+}
+
+func cancel(q *Queue, _ time.Time) string {
+	cancelRunning, transitions := q.Cancel()
+	return outcome(transitions, cancelRunning, "stop the running one")
+}
+
+func finish(success bool) event {
+	return func(q *Queue, now time.Time) string {
+		next, transitions := q.Finish(success, now)
+		return outcome(transitions, next != nil, "run the next one")
+	}
+}
+
+func outcome(transitions []Transition, act bool, action string) string {
+	var parts []string
+	for _, t := range transitions {
+		parts = append(parts, t.String())
+	}
+	if act {
+		parts = append(parts, "("+action+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// describe is what the queue holds, e.g. "stop since 0s (cancelling) | start_prod".
+func describe(q *Queue, t0 time.Time) string {
+	var s string
+	if op, started, ok := q.Running(); ok {
+		s = fmt.Sprintf("%s since %s", op.Key, started.Sub(t0))
+		if q.Cancelling() {
+			s += " (cancelling)"
+		}
+	} else if q.Cancelling() {
+		s = "cancelling nothing"
+	}
+	if op, ok := q.Queued(); ok {
+		s += " | " + op.Key
+	}
+	return s
+}
+
+func TestQueue(t *testing.T) {
+	type step struct {
+		event event
+		want  string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		// what the queue holds at the end; each step is a second apart
+		want string
+	}{
+		{
+			name:  "idle runs at once",
+			steps: []step{{submit(startProd), "starting Production: running, (run)"}},
+			want:  "start_prod since 0s",
+		},
+		{
+			name: "done",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{finish(true), "starting Production: done"},
+			},
+			want: "",
+		},
+		{
+			name: "failed",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{finish(false), "starting Production: failed"},
+			},
+			want: "",
+		},
+		{
+			name: "same operation is busy",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(startProd), "busy: starting Production for 1s"},
+			},
+			want: "start_prod since 0s",
+		},
+		{
+			name: "another operation waits",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{finish(true), "starting Production: done, stopping VPN: running, (run the next one)"},
+			},
+			want: "stop since 2s",
+		},
+		{
+			name: "queued operation runs after a failure",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{finish(false), "starting Production: failed, stopping VPN: running, (run the next one)"},
+			},
+			want: "stop since 2s",
+		},
+		{
+			name: "replace while running",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{submit(startNonprod), "stopping VPN: cancelled, starting Non-Production: queued"},
+				{finish(true), "starting Production: done, starting Non-Production: running, (run the next one)"},
+			},
+			want: "start_nonprod since 3s",
+		},
+		{
+			name: "queued operation asked again",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{submit(stop), ""},
+			},
+			want: "start_prod since 0s | stop",
+		},
+		{
+			name: "running operation asked again keeps the queued one",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{submit(startProd), "busy: starting Production for 2s"},
+			},
+			want: "start_prod since 0s | stop",
+		},
+		{
+			name: "cancel while queued",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{cancel, "stopping VPN: cancelled"},
+				{finish(true), "starting Production: done"},
+			},
+			want: "",
+		},
+		{
+			name: "cancel while queued leaves the running one",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{cancel, "stopping VPN: cancelled"},
+			},
+			want: "start_prod since 0s",
+		},
+		{
+			name: "cancel while running",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{cancel, "(stop the running one)"},
+				{cancel, ""},
+				{finish(true), "starting Production: cancelled"},
+			},
+			want: "",
+		},
+		{
+			name: "cancelled whatever the outcome",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{cancel, "(stop the running one)"},
+				{finish(false), "starting Production: cancelled"},
+			},
+			want: "",
+		},
+		{
+			name: "retry while cancelling waits",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{cancel, "(stop the running one)"},
+				{submit(startProd), "starting Production: queued"},
+				{finish(false), "starting Production: cancelled, starting Production: running, (run the next one)"},
+			},
+			want: "start_prod since 3s",
+		},
+		{
+			name: "the next operation is not cancelling",
+			steps: []step{
+				{submit(startProd), "starting Production: running, (run)"},
+				{submit(stop), "stopping VPN: queued"},
+				{cancel, "stopping VPN: cancelled"},
+				{cancel, "(stop the running one)"},
+				{submit(stop), "stopping VPN: queued"},
+				{finish(true), "starting Production: cancelled, stopping VPN: running, (run the next one)"},
+				{submit(stop), "busy: stopping VPN for 1s"},
+			},
+			want: "stop since 5s",
+		},
+		{
+			name: "idle",
+			steps: []step{
+				{cancel, ""},
+				{finish(true), ""},
+			},
+			want: "",
+		},
+	}
+	t0 := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var q Queue
+			for i, s := range tt.steps {
+				if got := s.event(&q, t0.Add(time.Duration(i)*time.Second)); got != s.want {
+					t.Fatalf("step %d: got %q, want %q", i, got, s.want)
+				}
+			}
+			if got := describe(&q, t0); got != tt.want {
+				t.Errorf("queue holds %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBusyError(t *testing.T) {
+	err := &BusyError{Running: startProd, For: 12400 * time.Millisecond}
+	if want := "busy: starting Production (12s) — press c to cancel"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestStateString(t *testing.T) {
+	tests := map[State]string{
+		Queued:    "queued",
+		Running:   "running",
+		Done:      "done",
+		Failed:    "failed",
+		Cancelled: "cancelled",
+		State(9):  "State(9)",
+	}
+	for state, want := range tests {
+		if got := state.String(); got != want {
+			t.Errorf("State(%d).String() = %q, want %q", int(state), got, want)
+		}
+	}
+}
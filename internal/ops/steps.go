@@ -0,0 +1,185 @@
+package ops
+
+import (
+	"fmt"
+	"time"
+)
+
+// StepStatus is where a step of a multi-step operation stands.
+type StepStatus int
+
+const (
+	StepPending StepStatus = iota
+	StepRunning
+	StepDone
+	StepFailed
+	// StepSkipped is a step that never ran: the operation didn't need it,
+	// or an earlier one failed
+	StepSkipped
+)
+
+func (s StepStatus) String() string {
+	switch s {
+	case StepPending:
+		return "pending"
+	case StepRunning:
+		return "running"
+	case StepDone:
+		return "done"
+	case StepFailed:
+		return "failed"
+	case StepSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("StepStatus(%d)", int(s))
+}
+
+// Step is one named step of an operation.
+type Step struct {
+	Name     string
+	Status   StepStatus
+	Err      error // why it failed
+	Started  time.Time
+	Finished time.Time
+}
+
+// StepEvent is a step starting (StepRunning) or finishing (StepDone or
+// StepFailed), as an operation reports it.
+type StepEvent struct {
+	Step   string
+	Status StepStatus
+	Err    error
+}
+
+// Steps is the progress of an operation made of named steps run in a
+// declared order, such as a switch (stop, start, verify) or the setup. Steps
+// may be left out: starting a later one skips those before it. The first
+// failure ends the operation and skips the rest.
+type Steps struct {
+	Title string
+	Steps []Step
+}
+
+// NewSteps declares an operation's steps, all pending.
+func NewSteps(title string, names ...string) *Steps {
+	s := &Steps{Title: title, Steps: make([]Step, len(names))}
+	for i, name := range names {
+		s.Steps[i] = Step{Name: name}
+	}
+	return s
+}
+
+func (s *Steps) find(name string) int {
+	for i, step := range s.Steps {
+		if step.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Running returns the step running now, nil between steps.
+func (s *Steps) Running() *Step {
+	for i := range s.Steps {
+		if s.Steps[i].Status == StepRunning {
+			return &s.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Failed returns the step that failed, nil if none did.
+func (s *Steps) Failed() *Step {
+	for i := range s.Steps {
+		if s.Steps[i].Status == StepFailed {
+			return &s.Steps[i]
+		}
+	}
+	return nil
+}
+
+// Apply records event at at. Events that don't fit the sequence (an unknown
+// step, starting one while another runs or after a failure, finishing one
+// that isn't running) are refused and change nothing.
+func (s *Steps) Apply(event StepEvent, at time.Time) error {
+	i := s.find(event.Step)
+	if i < 0 {
+		return fmt.Errorf("unknown step %q", event.Step)
+	}
+	step := &s.Steps[i]
+	switch event.Status {
+	case StepRunning:
+		if running := s.Running(); running != nil {
+			return fmt.Errorf("step %q started while %q runs", event.Step, running.Name)
+		}
+		if s.Failed() != nil {
+			return fmt.Errorf("step %q started after a failure", event.Step)
+		}
+		if step.Status != StepPending {
+			return fmt.Errorf("step %q is already %s", event.Step, step.Status)
+		}
+		for j := 0; j < i; j++ {
+			if s.Steps[j].Status == StepPending {
+				s.Steps[j].Status = StepSkipped
+			}
+		}
+		step.Status, step.Started = StepRunning, at
+	case StepDone, StepFailed:
+		if step.Status != StepRunning {
+			return fmt.Errorf("step %q finished while %s", event.Step, step.Status)
+		}
+		step.Status, step.Err, step.Finished = event.Status, event.Err, at
+		if event.Status == StepFailed {
+			s.skipPending()
+		}
+	default:
+		return fmt.Errorf("step %q: %s is not an event", event.Step, event.Status)
+	}
+	return nil
+}
+
+// Finish ends the operation with its outcome: a step still running takes
+// err (nil for success) and the ones never started are skipped.
+func (s *Steps) Finish(err error, at time.Time) {
+	if running := s.Running(); running != nil {
+		running.Status, running.Finished = StepDone, at
+		if err != nil {
+			running.Status, running.Err = StepFailed, err
+		}
+	}
+	s.skipPending()
+}
+
+func (s *Steps) skipPending() {
+	for i := range s.Steps {
+		if s.Steps[i].Status == StepPending {
+			s.Steps[i].Status = StepSkipped
+		}
+	}
+}
+
+// Summary describes the finished operation in one line, e.g. "3/3 steps,
+// 8.2s" or "failed at Verify handshake (2/3 steps)".
+func (s *Steps) Summary() string {
+	done, ran := 0, 0
+	var first, last time.Time
+	for _, step := range s.Steps {
+		if step.Status == StepSkipped || step.Status == StepPending {
+			continue
+		}
+		ran++
+		if step.Status == StepDone {
+			done++
+		}
+		if first.IsZero() || step.Started.Before(first) {
+			first = step.Started
+		}
+		if step.Finished.After(last) {
+			last = step.Finished
+		}
+	}
+	if failed := s.Failed(); failed != nil {
+		return fmt.Sprintf("failed at %s (%d/%d steps)", failed.Name, done, ran)
+	}
+	return fmt.Sprintf("%d/%d steps, %.1fs", done, ran, last.Sub(first).Seconds())
+}
@@ -0,0 +1,133 @@
+package ops
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stepStates is the status of every step, e.g. "Stop:done Start:running".
+func stepStates(s *Steps) string {
+	var states []string
+	for _, step := range s.Steps {
+		states = append(states, step.Name+":"+step.Status.String())
+	}
+	return strings.Join(states, " ")
+}
+
+func running(step string) StepEvent { return StepEvent{Step: step, Status: StepRunning} }
+func done(step string) StepEvent    { return StepEvent{Step: step, Status: StepDone} }
+
+func TestStepsInOrder(t *testing.T) {
+	t0 := time.Date(2024, time.June, 13, 10, 0, 0, 0, time.UTC)
+	s := NewSteps("Switching to Production", "Stop", "Start", "Verify")
+	for i, event := range []StepEvent{running("Stop"), done("Stop"), running("Start"), done("Start"), running("Verify"), done("Verify")} {
+		if err := s.Apply(event, t0.Add(time.Duration(i)*1640*time.Millisecond)); err != nil {
+			t.Fatalf("Apply(%+v) = %v", event, err)
+		}
+	}
+	if got, want := stepStates(s), "Stop:done Start:done Verify:done"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+	if got, want := s.Summary(), "3/3 steps, 8.2s"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+// Starting a later step skips those left out before it.
+func TestStepsLeftOut(t *testing.T) {
+	s := NewSteps("Connecting", "Stop", "Start", "Verify")
+	if err := s.Apply(running("Start"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stepStates(s), "Stop:skipped Start:running Verify:pending"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+	if err := s.Apply(running("Stop"), time.Now()); err == nil {
+		t.Error("a skipped step started")
+	}
+}
+
+// The first failure ends the operation: the rest are skipped and no step
+// starts after it.
+func TestStepsStopAtTheFirstFailure(t *testing.T) {
+	t0 := time.Now()
+	errHandshake := errors.New("no handshake")
+	s := NewSteps("Switching to Production", "Stop", "Start", "Verify", "Routes")
+	s.Apply(running("Stop"), t0)
+	s.Apply(done("Stop"), t0)
+	s.Apply(running("Start"), t0)
+	s.Apply(done("Start"), t0)
+	s.Apply(running("Verify"), t0)
+	if err := s.Apply(StepEvent{Step: "Verify", Status: StepFailed, Err: errHandshake}, t0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stepStates(s), "Stop:done Start:done Verify:failed Routes:skipped"; got != want {
+		t.Errorf("steps = %s, want %s", got, want)
+	}
+	if failed := s.Failed(); failed == nil || failed.Name != "Verify" || failed.Err != errHandshake {
+		t.Errorf("Failed() = %+v, want Verify with its error", failed)
+	}
+	if err := s.Apply(running("Routes"), t0); err == nil {
+		t.Error("a step started after the failure")
+	}
+	if got, want := s.Summary(), "failed at Verify (2/3 steps)"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+}
+
+func TestStepsRefuseOutOfSequence(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []StepEvent
+		event StepEvent
+	}{
+		{"unknown step", nil, running("Reboot")},
+		{"finished before it started", nil, done("Stop")},
+		{"two at once", []StepEvent{running("Stop")}, running("Start")},
+		{"started twice", []StepEvent{running("Stop"), done("Stop")}, running("Stop")},
+		{"finished twice", []StepEvent{running("Stop"), done("Stop")}, done("Stop")},
+		{"not an event", nil, StepEvent{Step: "Stop", Status: StepSkipped}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSteps("Switching", "Stop", "Start")
+			for _, event := range tt.setup {
+				if err := s.Apply(event, time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := stepStates(s)
+			if err := s.Apply(tt.event, time.Now()); err == nil {
+				t.Errorf("Apply(%+v) accepted", tt.event)
+			}
+			if after := stepStates(s); after != before {
+				t.Errorf("a refused event changed the steps from %s to %s", before, after)
+			}
+		})
+	}
+}
+
+func TestStepsFinish(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, "Stop:done Start:done Verify:skipped"},
+		{errors.New("wg-quick exited 1"), "Stop:done Start:failed Verify:skipped"},
+	}
+	for _, tt := range tests {
+		s := NewSteps("Switching", "Stop", "Start", "Verify")
+		s.Apply(running("Stop"), time.Now())
+		s.Apply(done("Stop"), time.Now())
+		s.Apply(running("Start"), time.Now())
+		s.Finish(tt.err, time.Now())
+		if got := stepStates(s); got != tt.want {
+			t.Errorf("Finish(%v): steps = %s, want %s", tt.err, got, tt.want)
+		}
+		if s.Running() != nil {
+			t.Errorf("Finish(%v) left %q running", tt.err, s.Running().Name)
+		}
+	}
+}
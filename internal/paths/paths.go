@@ -0,0 +1,168 @@
+// Package paths resolves the per-user directories the app keeps its files
+// in. Every feature that persists something (settings, state, caches,
+// sockets) goes through here so they all agree on locations.
+//
+// The XDG base directory variables are honored on every platform, which also
+// makes them the override for tests. Without them the platform convention is
+// used:
+//
+//	         Linux/BSD                 macOS                                  Windows
+//	Config   ~/.config/<app>           ~/Library/Application Support/<app>    %AppData%\<app>
+//	State    ~/.local/state/<app>      ~/Library/Application Support/<app>    %LocalAppData%\<app>
+//	Cache    ~/.cache/<app>            ~/Library/Caches/<app>                 %LocalAppData%\<app>\cache
+//	Runtime  $XDG_RUNTIME_DIR/<app>    $TMPDIR/<app>-<uid>                    %TEMP%\<app>
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// AppName is the directory name used under each base directory.
+const AppName = "tui-wireguard-vpn"
+
+// Kind selects one of the base directories.
+type Kind int
+
+const (
+	Config Kind = iota
+	State
+	Cache
+	Runtime
+)
+
+// Kinds lists every directory kind in display order.
+var Kinds = []Kind{Config, State, Cache, Runtime}
+
+func (k Kind) String() string {
+	switch k {
+	case Config:
+		return "config"
+	case State:
+		return "state"
+	case Cache:
+		return "cache"
+	case Runtime:
+		return "runtime"
+	}
+	return "unknown"
+}
+
+// envVar is the XDG variable overriding the base directory of k.
+func (k Kind) envVar() string {
+	switch k {
+	case Config:
+		return "XDG_CONFIG_HOME"
+	case State:
+		return "XDG_STATE_HOME"
+	case Cache:
+		return "XDG_CACHE_HOME"
+	case Runtime:
+		return "XDG_RUNTIME_DIR"
+	}
+	return ""
+}
+
+// Dir returns the app's directory of the given kind without creating it.
+func Dir(kind Kind) (string, error) {
+	if base := os.Getenv(kind.envVar()); base != "" && filepath.IsAbs(base) {
+		return filepath.Join(base, AppName), nil
+	}
+
+	if kind == Runtime {
+		// No per-user runtime dir outside systemd-style sessions; fall back
+		// to a user-suffixed directory in the temp dir
+		name := AppName
+		if uid := os.Getuid(); uid >= 0 {
+			name += "-" + strconv.Itoa(uid)
+		}
+		return filepath.Join(os.TempDir(), name), nil
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return windowsDir(kind)
+	case "darwin", "ios":
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		if kind == Cache {
+			return filepath.Join(home, "Library", "Caches", AppName), nil
+		}
+		return filepath.Join(home, "Library", "Application Support", AppName), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case Config:
+		return filepath.Join(home, ".config", AppName), nil
+	case State:
+		return filepath.Join(home, ".local", "state", AppName), nil
+	default:
+		return filepath.Join(home, ".cache", AppName), nil
+	}
+}
+
+func windowsDir(kind Kind) (string, error) {
+	variable := "LocalAppData"
+	if kind == Config {
+		variable = "AppData"
+	}
+	base := os.Getenv(variable)
+	if base == "" {
+		return "", fmt.Errorf("%%%s%% is not set", variable)
+	}
+	dir := filepath.Join(base, AppName)
+	if kind == Cache {
+		dir = filepath.Join(dir, "cache")
+	}
+	return dir, nil
+}
+
+// File returns the path of name inside the directory of the given kind.
+func File(kind Kind, name string) (string, error) {
+	dir, err := Dir(kind)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// Ensure returns the directory of the given kind, creating it (and any
+// missing parents) if needed. The app directory itself is private to the
+// user (0700); a pre-existing one with looser permissions is tightened.
+func Ensure(kind Kind) (string, error) {
+	dir, err := Dir(kind)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create %s directory %s: %v", kind, dir, err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s directory %s: %v", kind, dir, err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		if err := os.Chmod(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to restrict permissions on %s: %v", dir, err)
+		}
+	}
+	return dir, nil
+}
+
+// EnsureFile is like File but creates the containing directory first.
+func EnsureFile(kind Kind, name string) (string, error) {
+	dir, err := Ensure(kind)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,219 @@
+// Package platform works out what the app runs inside: the host itself, WSL
+// or a container. wg-quick changes the network namespace it runs in, which
+// under WSL2 is a VM's, not Windows', and in a container is the container's
+// (and usually isn't allowed at all), so the tunnel a user expects is never
+// touched and the failures are baffling. The TUI uses this to explain the
+// limitation up front and to disable what can't work.
+package platform
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Kind is an execution context.
+type Kind int
+
+const (
+	Host Kind = iota
+	WSL1
+	WSL2
+	Container
+)
+
+// Context is where the app runs.
+type Context struct {
+	Kind Kind
+	// Runtime names the container runtime ("docker", "podman",
+	// "kubernetes", ...) when it is known
+	Runtime string
+	// NetAdmin reports whether the process holds CAP_NET_ADMIN, which
+	// wg-quick needs inside a container
+	NetAdmin bool
+	// WindowsWireGuard is the path of WireGuard for Windows' wireguard.exe
+	// under WSL when Windows interop can run it, "" otherwise
+	WindowsWireGuard string
+}
+
+// Facts are the raw observations Classify decides from, so the decision can
+// be made on synthetic contents.
+type Facts struct {
+	ProcVersion  string // /proc/version
+	Cgroup       string // /proc/1/cgroup
+	Status       string // /proc/self/status
+	DockerEnv    bool   // /.dockerenv exists
+	ContainerEnv bool   // /run/.containerenv exists (podman)
+	ContainerVar string // $container, set by systemd-nspawn, podman and others
+}
+
+// Classify decides the execution context from facts. WSL wins over a
+// container, since a container inside WSL is still limited by WSL.
+func Classify(facts Facts) Context {
+	version := strings.ToLower(facts.ProcVersion)
+	if strings.Contains(version, "microsoft") {
+		// WSL2 kernels are "...-microsoft-standard-WSL2"; WSL1 reports
+		// "...-Microsoft" without a real kernel behind it
+		if strings.Contains(version, "wsl2") || strings.Contains(version, "microsoft-standard") {
+			return Context{Kind: WSL2}
+		}
+		return Context{Kind: WSL1}
+	}
+
+	runtime := ""
+	switch {
+	case facts.DockerEnv:
+		runtime = "docker"
+	case facts.ContainerEnv:
+		runtime = "podman"
+	case facts.ContainerVar != "":
+		runtime = facts.ContainerVar
+	default:
+		runtime = cgroupRuntime(facts.Cgroup)
+	}
+	if runtime == "" {
+		return Context{Kind: Host}
+	}
+	return Context{Kind: Container, Runtime: runtime, NetAdmin: hasNetAdmin(facts.Status)}
+}
+
+// cgroupRuntime recognizes the container runtimes that show in the cgroup
+// paths of PID 1 (cgroup v1, and v2 hosts that nest the container's).
+func cgroupRuntime(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		switch {
+		case strings.Contains(line, "kubepods"):
+			return "kubernetes"
+		case strings.Contains(line, "/docker"):
+			return "docker"
+		case strings.Contains(line, "libpod"):
+			return "podman"
+		case strings.Contains(line, "/lxc"):
+			return "lxc"
+		case strings.Contains(line, "containerd"):
+			return "containerd"
+		}
+	}
+	return ""
+}
+
+// capNetAdmin is CAP_NET_ADMIN's bit in the capability sets.
+const capNetAdmin = 12
+
+// hasNetAdmin reads the effective capabilities (CapEff) of a
+// /proc/<pid>/status.
+func hasNetAdmin(status string) bool {
+	for _, line := range strings.Split(status, "\n") {
+		if value, ok := strings.CutPrefix(line, "CapEff:"); ok {
+			caps, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+			return err == nil && caps&(1<<capNetAdmin) != 0
+		}
+	}
+	return false
+}
+
+// Detect looks at the running system. Anything unreadable counts as absent,
+// which on other platforms than Linux means Host.
+func Detect() Context {
+	read := func(path string) string {
+		content, _ := os.ReadFile(path)
+		return string(content)
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	ctx := Classify(Facts{
+		ProcVersion:  read("/proc/version"),
+		Cgroup:       read("/proc/1/cgroup"),
+		Status:       read("/proc/self/status"),
+		DockerEnv:    exists("/.dockerenv"),
+		ContainerEnv: exists("/run/.containerenv"),
+		ContainerVar: os.Getenv("container"),
+	})
+	if ctx.Kind == WSL2 && exists("/proc/sys/fs/binfmt_misc/WSLInterop") {
+		if path, err := exec.LookPath("wireguard.exe"); err == nil {
+			ctx.WindowsWireGuard = path
+		} else if path := "/mnt/c/Program Files/WireGuard/wireguard.exe"; exists(path) {
+			ctx.WindowsWireGuard = path
+		}
+	}
+	return ctx
+}
+
+// CanManageTunnel reports whether wg-quick can bring up the tunnel the user
+// means from here.
+func (c Context) CanManageTunnel() bool {
+	switch c.Kind {
+	case WSL1, WSL2:
+		return false
+	case Container:
+		return c.NetAdmin
+	}
+	return true
+}
+
+// Banner explains the limitation of the context, "" on a plain host.
+func (c Context) Banner() string {
+	switch c.Kind {
+	case WSL1:
+		return "running inside WSL1 — there is no WireGuard here; manage the tunnel from Windows"
+	case WSL2:
+		banner := "running inside WSL2 — the tunnel must be managed from Windows; status shown is for this namespace only"
+		if c.WindowsWireGuard != "" {
+			banner += " (Start/Stop hand over to WireGuard for Windows)"
+		}
+		return banner
+	case Container:
+		name := "a container"
+		if c.Runtime != "" {
+			name = fmt.Sprintf("a %s container", c.Runtime)
+		}
+		if !c.NetAdmin {
+			return fmt.Sprintf("running inside %s without NET_ADMIN — Start/Stop can't work here; run on the host", name)
+		}
+		return fmt.Sprintf("running inside %s — a tunnel started here only covers the container's network namespace", name)
+	}
+	return ""
+}
+
+// DisabledReason is why Start and Stop are unavailable, "" when they work
+// (with wg-quick, or through WireGuard for Windows).
+func (c Context) DisabledReason() string {
+	if c.CanManageTunnel() || c.WindowsWireGuard != "" {
+		return ""
+	}
+	switch c.Kind {
+	case WSL1, WSL2:
+		return "inside WSL — manage the tunnel from Windows"
+	}
+	return "inside a container without NET_ADMIN"
+}
+
+// WindowsUp installs configPath (a path in WSL) as a tunnel service of
+// WireGuard for Windows, which brings it up. Like the WireGuard app, this
+// needs an elevated Windows session.
+func (c Context) WindowsUp(ctx context.Context, configPath string) (string, error) {
+	windowsPath, err := exec.CommandContext(ctx, "wslpath", "-w", configPath).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to translate %s to a Windows path: %v", configPath, err)
+	}
+	output, err := exec.CommandContext(ctx, c.WindowsWireGuard, "/installtunnelservice", strings.TrimSpace(string(windowsPath))).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("wireguard.exe /installtunnelservice failed (is the terminal elevated on Windows?): %v", err)
+	}
+	return string(output), nil
+}
+
+// WindowsDown removes the tunnel service name from WireGuard for Windows,
+// which takes the tunnel down.
+func (c Context) WindowsDown(ctx context.Context, name string) (string, error) {
+	output, err := exec.CommandContext(ctx, c.WindowsWireGuard, "/uninstalltunnelservice", name).CombinedOutput()
+	if err != nil {
+		return string(output), fmt.Errorf("wireguard.exe /uninstalltunnelservice %s failed: %v", name, err)
+	}
+	return string(output), nil
+}
@@ -0,0 +1,82 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+)
+
+// Samples of /proc contents as seen in each context.
+const (
+	hostVersion = "Linux version 6.8.0-45-generic (buildd@lcy02-amd64-075) (x86_64-linux-gnu-gcc-13 (Ubuntu 13.2.0-23ubuntu4) 13.2.0) #45-Ubuntu SMP PREEMPT_DYNAMIC Fri Aug 30 12:02:04 UTC 2024\n"
+	wsl1Version = "Linux version 4.4.0-19041-Microsoft (Microsoft@Microsoft.com) (gcc version 5.4.0 (GCC) ) #1237-Microsoft Sat Sep 11 14:32:00 PST 2021\n"
+	wsl2Version = "Linux version 5.15.153.1-microsoft-standard-WSL2 (root@941d701f84f1) (gcc (GCC) 12.2.0) #1 SMP Fri Mar 29 23:14:13 UTC 2024\n"
+
+	hostCgroup   = "0::/init.scope\n"
+	dockerCgroup = "12:pids:/docker/3f1a0c9d5e2b\n11:memory:/docker/3f1a0c9d5e2b\n0::/\n"
+	k8sCgroup    = "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-abc.scope\n"
+	lxcCgroup    = "0::/lxc.payload.web/init.scope\n"
+
+	// CapEff of a root shell with every capability, and of docker's default
+	// set, which leaves out NET_ADMIN
+	privileged   = "Name:\tbash\nCapInh:\t0000000000000000\nCapEff:\t000001ffffffffff\nCapBnd:\t000001ffffffffff\n"
+	unprivileged = "Name:\tbash\nCapInh:\t0000000000000000\nCapEff:\t00000000a80425fb\nCapBnd:\t00000000a80425fb\n"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name  string
+		facts Facts
+		want  Context
+	}{
+		{"host", Facts{ProcVersion: hostVersion, Cgroup: hostCgroup, Status: privileged}, Context{Kind: Host}},
+		{"nothing readable", Facts{}, Context{Kind: Host}},
+		{"wsl1", Facts{ProcVersion: wsl1Version}, Context{Kind: WSL1}},
+		{"wsl2", Facts{ProcVersion: wsl2Version, Cgroup: hostCgroup}, Context{Kind: WSL2}},
+		{"docker inside wsl2", Facts{ProcVersion: wsl2Version, DockerEnv: true}, Context{Kind: WSL2}},
+		{"dockerenv", Facts{ProcVersion: hostVersion, DockerEnv: true, Status: unprivileged}, Context{Kind: Container, Runtime: "docker"}},
+		{"docker with NET_ADMIN", Facts{ProcVersion: hostVersion, DockerEnv: true, Status: privileged}, Context{Kind: Container, Runtime: "docker", NetAdmin: true}},
+		{"podman", Facts{ProcVersion: hostVersion, ContainerEnv: true, Status: unprivileged}, Context{Kind: Container, Runtime: "podman"}},
+		{"nspawn", Facts{ProcVersion: hostVersion, ContainerVar: "systemd-nspawn", Status: privileged}, Context{Kind: Container, Runtime: "systemd-nspawn", NetAdmin: true}},
+		{"docker cgroup", Facts{ProcVersion: hostVersion, Cgroup: dockerCgroup}, Context{Kind: Container, Runtime: "docker"}},
+		{"kubernetes", Facts{ProcVersion: hostVersion, Cgroup: k8sCgroup, Status: unprivileged}, Context{Kind: Container, Runtime: "kubernetes"}},
+		{"lxc", Facts{ProcVersion: hostVersion, Cgroup: lxcCgroup}, Context{Kind: Container, Runtime: "lxc"}},
+		{"unreadable capabilities", Facts{DockerEnv: true, Status: "CapEff:\tnot hex\n"}, Context{Kind: Container, Runtime: "docker"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.facts); got != tt.want {
+				t.Errorf("Classify() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitations(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      Context
+		manage   bool
+		disabled string
+		banner   string
+	}{
+		{"host", Context{Kind: Host}, true, "", ""},
+		{"wsl1", Context{Kind: WSL1}, false, "inside WSL", "WSL1"},
+		{"wsl2", Context{Kind: WSL2}, false, "inside WSL", "this namespace only"},
+		{"wsl2 with wireguard.exe", Context{Kind: WSL2, WindowsWireGuard: "/mnt/c/Program Files/WireGuard/wireguard.exe"}, false, "", "hand over to WireGuard for Windows"},
+		{"container", Context{Kind: Container, Runtime: "docker"}, false, "without NET_ADMIN", "a docker container without NET_ADMIN"},
+		{"container with NET_ADMIN", Context{Kind: Container, NetAdmin: true}, true, "", "a container — a tunnel started here only covers the container's network namespace"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ctx.CanManageTunnel(); got != tt.manage {
+				t.Errorf("CanManageTunnel() = %v, want %v", got, tt.manage)
+			}
+			if got := tt.ctx.DisabledReason(); !strings.Contains(got, tt.disabled) || (got == "") != (tt.disabled == "") {
+				t.Errorf("DisabledReason() = %q, want %q", got, tt.disabled)
+			}
+			if got := tt.ctx.Banner(); !strings.Contains(got, tt.banner) || (got == "") != (tt.banner == "") {
+				t.Errorf("Banner() = %q, want %q", got, tt.banner)
+			}
+		})
+	}
+}
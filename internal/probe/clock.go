@@ -0,0 +1,68 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// DefaultClockServer is the NTP server ClockOffset asks by default.
+const DefaultClockServer = "pool.ntp.org:123"
+
+// ntpEpochOffset is the number of seconds between the NTP era 0 epoch
+// (1900) and the Unix epoch.
+const ntpEpochOffset = 2208988800
+
+// ClockOffset asks the NTP server (host:port) for the time and returns how
+// far the local clock is off from it, positive when it runs ahead. It sends
+// a single SNTP request, the way ntpdate -q does, and corrects for the round
+// trip.
+func (p *UDPProber) ClockOffset(ctx context.Context, server string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	conn, err := p.Dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	request := make([]byte, 48)
+	request[0] = 0x23 // version 4, client mode
+	sent := time.Now()
+	binary.BigEndian.PutUint64(request[40:], ntpTime(sent))
+	if _, err := conn.Write(request); err != nil {
+		return 0, err
+	}
+	reply := make([]byte, 48)
+	n, err := conn.Read(reply)
+	received := time.Now()
+	if err != nil {
+		return 0, err
+	}
+	if n < 48 || reply[0]&0x07 != 4 || reply[1] == 0 {
+		return 0, fmt.Errorf("%s sent no usable time", server)
+	}
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(reply[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(reply[40:]))
+	// The usual SNTP offset: the server's clock minus ours, averaged over
+	// both legs of the exchange
+	offset := (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	return -offset, nil
+}
+
+func ntpTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(v uint64) time.Time {
+	seconds := int64(v>>32) - ntpEpochOffset
+	nanos := int64((v & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(seconds, nanos)
+}
@@ -0,0 +1,61 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// ntpServer answers SNTP requests with a clock ahead of ours by ahead.
+func ntpServer(ahead time.Duration, stratum byte) func(net.Conn) {
+	return func(conn net.Conn) {
+		request := make([]byte, 48)
+		if _, err := conn.Read(request); err != nil {
+			return
+		}
+		reply := make([]byte, 48)
+		reply[0] = 0x24 // version 4, server mode
+		reply[1] = stratum
+		now := ntpTime(time.Now().Add(ahead))
+		binary.BigEndian.PutUint64(reply[32:], now)
+		binary.BigEndian.PutUint64(reply[40:], now)
+		conn.Write(reply)
+	}
+}
+
+func TestClockOffset(t *testing.T) {
+	for _, ahead := range []time.Duration{0, 3 * time.Second, -90 * time.Second} {
+		p := prober(fakeNetwork{"udp " + DefaultClockServer: ntpServer(ahead, 2)})
+		offset, err := p.ClockOffset(context.Background(), DefaultClockServer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The server ahead is our clock behind
+		if diff := offset + ahead; diff < -20*time.Millisecond || diff > 20*time.Millisecond {
+			t.Errorf("ClockOffset() = %s with the server %s ahead", offset, ahead)
+		}
+	}
+
+	// A server that isn't synchronized itself (stratum 0) says nothing
+	p := prober(fakeNetwork{"udp " + DefaultClockServer: ntpServer(0, 0)})
+	if offset, err := p.ClockOffset(context.Background(), DefaultClockServer); err == nil {
+		t.Errorf("ClockOffset() = %s from an unsynchronized server", offset)
+	}
+	if offset, err := prober(fakeNetwork{"udp " + DefaultClockServer: silent}).ClockOffset(context.Background(), DefaultClockServer); err == nil {
+		t.Errorf("ClockOffset() = %s without an answer", offset)
+	}
+}
+
+func TestNTPTime(t *testing.T) {
+	for _, at := range []time.Time{
+		time.Date(2026, time.May, 11, 9, 0, 0, 0, time.UTC),
+		time.Date(2026, time.May, 11, 9, 0, 0, 999_999_000, time.UTC),
+		time.Unix(0, 500_000_000),
+	} {
+		if got := fromNTPTime(ntpTime(at)); got.Sub(at).Abs() > time.Microsecond {
+			t.Errorf("fromNTPTime(ntpTime(%s)) = %s", at, got)
+		}
+	}
+}
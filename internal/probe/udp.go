@@ -0,0 +1,246 @@
+// Package probe contains quick network heuristics used to explain why a
+// WireGuard tunnel isn't handshaking.
+package probe
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Dialer opens network connections; net.Dialer satisfies it and tests can
+// substitute a fake.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+type Verdict int
+
+const (
+	// Unknown means the heuristics couldn't tell what is wrong
+	Unknown Verdict = iota
+	// UDPBlocked means no UDP traffic gets through this network at all
+	UDPBlocked
+	// EndpointPortBlocked means the gateway host is reachable but its
+	// WireGuard port appears filtered
+	EndpointPortBlocked
+	// EndpointDown means the gateway isn't answering at all
+	EndpointDown
+)
+
+type Result struct {
+	Verdict  Verdict
+	Endpoint string
+}
+
+// Hint returns a short user-facing explanation, or "" when nothing useful
+// was learned.
+func (r Result) Hint() string {
+	_, port, _ := net.SplitHostPort(r.Endpoint)
+	switch r.Verdict {
+	case UDPBlocked:
+		return "this network appears to block UDP — try a hotspot"
+	case EndpointPortBlocked:
+		return fmt.Sprintf("the gateway is reachable but UDP port %s appears blocked on this network — try a hotspot", port)
+	case EndpointDown:
+		return fmt.Sprintf("the gateway %s is not responding — it may be down, check with the Infra team", r.Endpoint)
+	}
+	return ""
+}
+
+var (
+	// Public resolvers used as a known-good UDP reference
+	DefaultReferenceResolvers = []string{"1.1.1.1:53", "8.8.8.8:53"}
+
+	// TCP ports tried on the gateway host to see whether it is reachable at all
+	defaultHostPorts = []string{"443", "22"}
+)
+
+type UDPProber struct {
+	Dialer             Dialer
+	ReferenceResolvers []string
+	HostPorts          []string
+	Timeout            time.Duration
+}
+
+func NewUDPProber() *UDPProber {
+	return &UDPProber{
+		Dialer:             &net.Dialer{},
+		ReferenceResolvers: DefaultReferenceResolvers,
+		HostPorts:          defaultHostPorts,
+		Timeout:            2 * time.Second,
+	}
+}
+
+// Diagnose distinguishes "all UDP blocked", "endpoint port blocked" and
+// "endpoint down" for a WireGuard endpoint (host:port).
+func (p *UDPProber) Diagnose(ctx context.Context, endpoint string) Result {
+	result := Result{Verdict: Unknown, Endpoint: endpoint}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return result
+	}
+
+	if !p.referenceUDPWorks(ctx) {
+		result.Verdict = UDPBlocked
+		return result
+	}
+
+	// WireGuard never answers unauthenticated packets, so silence is normal;
+	// an ICMP port unreachable (ECONNREFUSED) however means nothing listens.
+	if p.udpRefused(ctx, endpoint) {
+		result.Verdict = EndpointDown
+		return result
+	}
+
+	if p.hostReachable(ctx, host) {
+		result.Verdict = EndpointPortBlocked
+	} else {
+		result.Verdict = EndpointDown
+	}
+	return result
+}
+
+// Reachability is what can be learned about a WireGuard endpoint without
+// bringing a tunnel up.
+type Reachability struct {
+	Endpoint string
+	// Refused means an ICMP port unreachable came back: nothing listens on
+	// the WireGuard port
+	Refused bool
+	// HostReachable means the gateway host answered TCP on a probe port
+	HostReachable bool
+	// Latency is the TCP connect round trip to the host, 0 when unreachable
+	Latency time.Duration
+}
+
+// UDPWorks reports whether UDP round-trips work on this network at all,
+// using the reference resolvers.
+func (p *UDPProber) UDPWorks(ctx context.Context) bool {
+	return p.referenceUDPWorks(ctx)
+}
+
+// Reach probes endpoint (host:port) without sending anything WireGuard would
+// act on. Every step is bounded by the prober's Timeout and by ctx.
+func (p *UDPProber) Reach(ctx context.Context, endpoint string) Reachability {
+	result := Reachability{Endpoint: endpoint}
+	host, _, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return result
+	}
+	result.Refused = p.udpRefused(ctx, endpoint)
+	result.Latency, result.HostReachable = p.hostLatency(ctx, host)
+	return result
+}
+
+// TCP connects to address (host:port) and reports how long the connection
+// took. Unlike the gateway probes, a refused connection is a failure: the
+// question is whether a service there accepts connections.
+func (p *UDPProber) TCP(ctx context.Context, address string) (time.Duration, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	started := time.Now()
+	conn, err := p.Dialer.DialContext(dialCtx, "tcp", address)
+	if err != nil {
+		return 0, err
+	}
+	conn.Close()
+	return time.Since(started), nil
+}
+
+// referenceUDPWorks sends a DNS query to each reference resolver and reports
+// whether any of them answered.
+func (p *UDPProber) referenceUDPWorks(ctx context.Context) bool {
+	for _, resolver := range p.ReferenceResolvers {
+		if p.dnsExchange(ctx, resolver) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *UDPProber) dnsExchange(ctx context.Context, resolver string) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	conn, err := p.Dialer.DialContext(ctx, "udp", resolver)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	query := dnsQuery()
+	if _, err := conn.Write(query); err != nil {
+		return false
+	}
+	reply := make([]byte, 512)
+	n, err := conn.Read(reply)
+	// Any well-formed reply with our ID proves UDP round-trips work
+	return err == nil && n >= 12 && reply[0] == query[0] && reply[1] == query[1]
+}
+
+// dnsQuery builds a minimal recursive A query for example.com.
+func dnsQuery() []byte {
+	id := make([]byte, 2)
+	rand.Read(id)
+	query := []byte{id[0], id[1], 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+	for _, label := range []string{"example", "com"} {
+		query = append(query, byte(len(label)))
+		query = append(query, label...)
+	}
+	return append(query, 0x00, 0x00, 0x01, 0x00, 0x01)
+}
+
+func (p *UDPProber) udpRefused(ctx context.Context, endpoint string) bool {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	conn, err := p.Dialer.DialContext(ctx, "udp", endpoint)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return errors.Is(err, syscall.ECONNREFUSED)
+	}
+	_, err = conn.Read(make([]byte, 64))
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// hostReachable reports whether the host answers TCP at all; a refused
+// connection counts, since it proves packets reach the host and come back.
+func (p *UDPProber) hostReachable(ctx context.Context, host string) bool {
+	_, ok := p.hostLatency(ctx, host)
+	return ok
+}
+
+// hostLatency is hostReachable that also returns how long the first
+// answering connection attempt took.
+func (p *UDPProber) hostLatency(ctx context.Context, host string) (time.Duration, bool) {
+	for _, port := range p.HostPorts {
+		dialCtx, cancel := context.WithTimeout(ctx, p.Timeout)
+		started := time.Now()
+		conn, err := p.Dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(host, port))
+		elapsed := time.Since(started)
+		cancel()
+		if err == nil {
+			conn.Close()
+			return elapsed, true
+		}
+		if errors.Is(err, syscall.ECONNREFUSED) {
+			return elapsed, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,161 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Each address of a fakeNetwork is a responder given the far end of the
+// connection, or an error for the dial; anything else times out.
+type fakeNetwork map[string]any
+
+func (n fakeNetwork) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch peer := n[network+" "+address].(type) {
+	case func(net.Conn):
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			peer(server)
+		}()
+		return client, nil
+	case error:
+		return nil, peer
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+var refused = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+// resolver answers DNS queries with their ID.
+func resolver(conn net.Conn) {
+	query := make([]byte, 512)
+	n, err := conn.Read(query)
+	if err != nil || n < 12 {
+		return
+	}
+	reply := append([]byte{query[0], query[1], 0x81, 0x80}, make([]byte, 8)...)
+	conn.Write(reply)
+}
+
+// wrongID answers with a reply to another query.
+func wrongID(conn net.Conn) {
+	query := make([]byte, 512)
+	conn.Read(query)
+	conn.Write(append([]byte{query[0] + 1, query[1]}, make([]byte, 10)...))
+}
+
+// silent reads everything and never answers, as a WireGuard peer does for
+// packets it can't authenticate, and as a filtered port does.
+func silent(conn net.Conn) {
+	io.Copy(io.Discard, conn)
+}
+
+// refusedConn is a connected UDP socket the host answered with ICMP port
+// unreachable.
+type refusedConn struct{ net.Conn }
+
+func (refusedConn) Write(b []byte) (int, error) { return len(b), nil }
+func (refusedConn) Read([]byte) (int, error) {
+	return 0, &net.OpError{Op: "read", Net: "udp", Err: os.NewSyscallError("read", syscall.ECONNREFUSED)}
+}
+
+type refusingNetwork struct {
+	fakeNetwork
+	refusing string
+}
+
+func (n refusingNetwork) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if network+" "+address == n.refusing {
+		client, _ := net.Pipe()
+		return refusedConn{client}, nil
+	}
+	return n.fakeNetwork.DialContext(ctx, network, address)
+}
+
+func prober(dialer Dialer) *UDPProber {
+	return &UDPProber{
+		Dialer:             dialer,
+		ReferenceResolvers: []string{"1.1.1.1:53", "8.8.8.8:53"},
+		HostPorts:          []string{"443", "22"},
+		Timeout:            50 * time.Millisecond,
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	const endpoint = "34.101.166.184:51820"
+	tests := []struct {
+		name    string
+		network Dialer
+		want    Verdict
+	}{
+		{"no UDP at all", fakeNetwork{}, UDPBlocked},
+		{"resolvers unreachable", fakeNetwork{"udp 1.1.1.1:53": errors.New("network is unreachable")}, UDPBlocked},
+		{"wrong answer", fakeNetwork{"udp 1.1.1.1:53": wrongID, "udp 8.8.8.8:53": silent}, UDPBlocked},
+		{"nothing listening", refusingNetwork{fakeNetwork{"udp 8.8.8.8:53": resolver}, "udp " + endpoint}, EndpointDown},
+		{"port filtered", fakeNetwork{"udp 8.8.8.8:53": resolver, "udp " + endpoint: silent, "tcp 34.101.166.184:443": silent}, EndpointPortBlocked},
+		{"port filtered, TCP refused", fakeNetwork{"udp 1.1.1.1:53": resolver, "udp " + endpoint: silent, "tcp 34.101.166.184:443": refused}, EndpointPortBlocked},
+		{"port filtered, second TCP port", fakeNetwork{"udp 1.1.1.1:53": resolver, "udp " + endpoint: silent, "tcp 34.101.166.184:22": silent}, EndpointPortBlocked},
+		{"host gone", fakeNetwork{"udp 1.1.1.1:53": resolver, "udp " + endpoint: silent}, EndpointDown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prober(tt.network).Diagnose(context.Background(), endpoint); got.Verdict != tt.want || got.Endpoint != endpoint {
+				t.Errorf("Diagnose() = %+v, want verdict %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := prober(fakeNetwork{}).Diagnose(context.Background(), "34.101.166.184"); got.Verdict != Unknown {
+		t.Errorf("Diagnose() without a port = %+v", got)
+	}
+}
+
+func TestHint(t *testing.T) {
+	tests := []struct {
+		verdict Verdict
+		want    string
+	}{
+		{UDPBlocked, "this network appears to block UDP — try a hotspot"},
+		{EndpointPortBlocked, "the gateway is reachable but UDP port 51820 appears blocked on this network — try a hotspot"},
+		{EndpointDown, "the gateway 34.101.166.184:51820 is not responding — it may be down, check with the Infra team"},
+		{Unknown, ""},
+	}
+	for _, tt := range tests {
+		if got := (Result{Verdict: tt.verdict, Endpoint: "34.101.166.184:51820"}).Hint(); got != tt.want {
+			t.Errorf("Hint() of %d = %q, want %q", tt.verdict, got, tt.want)
+		}
+	}
+}
+
+func TestReach(t *testing.T) {
+	const endpoint = "34.101.166.184:51820"
+	reach := prober(refusingNetwork{fakeNetwork{"tcp 34.101.166.184:443": silent}, "udp " + endpoint}).Reach(context.Background(), endpoint)
+	if !reach.Refused || !reach.HostReachable {
+		t.Errorf("Reach() = %+v, want refused on a reachable host", reach)
+	}
+	reach = prober(fakeNetwork{"udp " + endpoint: silent}).Reach(context.Background(), endpoint)
+	if reach.Refused || reach.HostReachable || reach.Latency != 0 {
+		t.Errorf("Reach() = %+v, want nothing learned", reach)
+	}
+}
+
+func TestTCP(t *testing.T) {
+	p := prober(fakeNetwork{"tcp 10.80.1.5:5432": silent, "tcp 10.80.1.5:6379": refused})
+	if _, err := p.TCP(context.Background(), "10.80.1.5:5432"); err != nil {
+		t.Errorf("TCP() to an open port = %v", err)
+	}
+	// Unlike a gateway probe, a refused connection is a failure here
+	if _, err := p.TCP(context.Background(), "10.80.1.5:6379"); !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("TCP() to a closed port = %v", err)
+	}
+	if _, err := p.TCP(context.Background(), "10.80.1.5:22"); err == nil {
+		t.Error("TCP() to a filtered port succeeded")
+	}
+}
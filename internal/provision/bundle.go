@@ -0,0 +1,320 @@
+package provision
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/doctor"
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/state"
+)
+
+const (
+	// BundleManifest is the provisioning file of a bundle, at its top or in
+	// its only top-level directory.
+	BundleManifest = "provision.toml"
+	// Limits on what a bundle unpacks to
+	maxBundleFile  = 1 << 20
+	maxBundleTotal = 16 << 20
+)
+
+// ReportVersion is the version of the Report layout. It goes up whenever a
+// field changes meaning or goes away; new fields don't change it.
+const ReportVersion = 1
+
+// Bundle is a provisioning bundle unpacked into a private directory: a
+// .tar.gz of a provisioning file and every file it references.
+type Bundle struct {
+	Path      string // the archive
+	Dir       string // where it is unpacked
+	Provision *settings.Provision
+}
+
+// OpenBundle unpacks and validates a bundle. Only regular files and
+// directories are unpacked, none outside the bundle's own directory, and
+// the provisioning file may only reference files in the bundle. Close
+// removes the unpacked files.
+func OpenBundle(archive string) (*Bundle, error) {
+	file, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir, err := os.MkdirTemp("", "tui-wireguard-vpn-bundle-*")
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{Path: archive, Dir: dir}
+	if err := b.unpack(file); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle %s: %v", archive, err)
+	}
+
+	manifest, err := b.manifest()
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle %s: %v", archive, err)
+	}
+	if b.Provision, err = settings.LoadProvision(manifest); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("invalid bundle %s: %v", archive, err)
+	}
+	for role, path := range b.Provision.Files() {
+		if rel, err := filepath.Rel(b.Dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			b.Close()
+			return nil, fmt.Errorf("invalid bundle %s: the %s (%s) is outside the bundle", archive, role, path)
+		}
+	}
+	return b, nil
+}
+
+// Name returns a path in the unpacked bundle the way the bundle names it,
+// e.g. "issued/julo-prod.conf", and any other path as it is.
+func (b *Bundle) Name(path string) string {
+	rel, err := filepath.Rel(filepath.Dir(b.Provision.Path), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Close removes the unpacked bundle.
+func (b *Bundle) Close() error {
+	return os.RemoveAll(b.Dir)
+}
+
+func (b *Bundle) unpack(r io.Reader) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a .tar.gz: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	total := int64(0)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("corrupted archive: %v", err)
+		}
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." {
+			continue
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("%s points outside the bundle", header.Name)
+		}
+		target := filepath.Join(b.Dir, filepath.FromSlash(name))
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if header.Size > maxBundleFile {
+				return fmt.Errorf("%s is larger than %d bytes", header.Name, maxBundleFile)
+			}
+			if total += header.Size; total > maxBundleTotal {
+				return fmt.Errorf("unpacks to more than %d bytes", maxBundleTotal)
+			}
+			content, err := io.ReadAll(io.LimitReader(tr, maxBundleFile))
+			if err != nil {
+				return fmt.Errorf("corrupted archive: %v", err)
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return err
+			}
+			if err := os.WriteFile(target, content, 0600); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%s is not a regular file or directory", header.Name)
+		}
+	}
+}
+
+// manifest finds the bundle's provisioning file.
+func (b *Bundle) manifest() (string, error) {
+	top := filepath.Join(b.Dir, BundleManifest)
+	if _, err := os.Stat(top); err == nil {
+		return top, nil
+	}
+	entries, err := os.ReadDir(b.Dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		nested := filepath.Join(b.Dir, entries[0].Name(), BundleManifest)
+		if _, err := os.Stat(nested); err == nil {
+			return nested, nil
+		}
+	}
+	return "", fmt.Errorf("no %s in it", BundleManifest)
+}
+
+// Item is what applying a bundle did with one of its files.
+type Item struct {
+	Item   string `json:"item"` // e.g. "prod config"
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	// Action is "installed", "updated", "skipped" or "failed"
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// InstallItems is Install for unattended runs: every template and config is
+// tried and reported, and those already in place are skipped. It needs the
+// privileges setup needs.
+func InstallItems(p *settings.Provision) ([]Item, []string) {
+	if p.Settings != "" {
+		if provisioned, err := settings.LoadFile(p.Settings); err == nil && provisioned.StripHookScripts {
+			config.SetStripHooks(true)
+		}
+	}
+	applied, warnings := config.ApplyProvisioning(p.ProdTemplate, p.NonProdTemplate, p.ProdConfig, p.NonProdConfig)
+	items := make([]Item, len(applied))
+	for i, item := range applied {
+		items[i] = Item{Item: item.Role, Source: item.Source, Target: item.Target, Action: item.Action, Reason: item.Reason}
+	}
+	return items, warnings
+}
+
+// FinishItems is Finish for unattended runs, once InstallItems ran: it
+// reports on the settings file and records the configs it installed. The
+// version is only recorded as applied when nothing failed.
+func FinishItems(p *settings.Provision, installed []Item) (*Item, error) {
+	var item *Item
+	if p.Settings != "" {
+		item = &Item{Item: "settings", Source: p.Settings}
+		item.Target, _ = settings.Path()
+		current, err := os.ReadFile(item.Target)
+		provisioned, _ := os.ReadFile(p.Settings)
+		switch {
+		case err == nil && string(current) == string(provisioned):
+			item.Action, item.Reason = config.ItemSkipped, "already installed"
+		case err == nil:
+			item.Action, item.Reason = config.ItemSkipped, "the user has settings of their own"
+		default:
+			if _, err := installSettings(p); err != nil {
+				item.Action, item.Reason = config.ItemFailed, err.Error()
+			} else {
+				item.Action = config.ItemInstalled
+			}
+		}
+	}
+
+	failed := item != nil && item.Action == config.ItemFailed
+	changed := map[string]string{}
+	for _, i := range installed {
+		switch i.Action {
+		case config.ItemFailed:
+			failed = true
+		case config.ItemInstalled, config.ItemUpdated:
+			if env, ok := strings.CutSuffix(i.Item, " config"); ok {
+				changed[env] = i.Source
+			}
+		}
+	}
+	if failed && len(changed) == 0 {
+		return item, nil
+	}
+	err := state.Update(func(s *state.State) {
+		if !failed {
+			s.Provisioned = record(p)
+		}
+		if s.Configs == nil {
+			s.Configs = map[string]*state.ConfigProvenance{}
+		}
+		for env, path := range changed {
+			s.Configs[env] = &state.ConfigProvenance{Source: "provisioned " + path, UpdatedAt: time.Now()}
+		}
+	})
+	return item, err
+}
+
+// Report is the machine-readable account of applying a bundle.
+type Report struct {
+	Version int    `json:"version"` // ReportVersion
+	Bundle  string `json:"bundle"`
+	// ProvisioningVersion is the version in the bundle's provisioning file,
+	// 0 when it couldn't be read
+	ProvisioningVersion int       `json:"provisioning_version"`
+	StartedAt           time.Time `json:"started_at"`
+	FinishedAt          time.Time `json:"finished_at"`
+	// Changed is false when the run installed and updated nothing, as when
+	// the same bundle is applied again
+	Changed  bool     `json:"changed"`
+	Failed   bool     `json:"failed"`
+	Error    string   `json:"error,omitempty"` // why the bundle couldn't be applied at all
+	Items    []Item   `json:"items"`
+	Warnings []string `json:"warnings,omitempty"`
+	Doctor   *Doctor  `json:"doctor,omitempty"`
+}
+
+// Doctor is the doctor's verdict after a run.
+type Doctor struct {
+	OK     bool          `json:"ok"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok", "warn", "fail" or "n/a"
+	Detail string `json:"detail"`
+}
+
+// NewReport starts the report of applying archive.
+func NewReport(archive string) *Report {
+	return &Report{Version: ReportVersion, Bundle: archive, StartedAt: time.Now(), Items: []Item{}}
+}
+
+// Add records items and works out Changed and Failed.
+func (r *Report) Add(items ...Item) {
+	for _, item := range items {
+		r.Items = append(r.Items, item)
+		switch item.Action {
+		case config.ItemInstalled, config.ItemUpdated:
+			r.Changed = true
+		case config.ItemFailed:
+			r.Failed = true
+		}
+	}
+}
+
+// Fail records why the bundle couldn't be applied at all.
+func (r *Report) Fail(err error) {
+	r.Failed = true
+	r.Error = err.Error()
+}
+
+// Finish adds the doctor's checks and the finishing time.
+func (r *Report) Finish(checks []doctor.Check) {
+	r.Doctor = &Doctor{OK: !doctor.Failed(checks), Checks: []DoctorCheck{}}
+	for _, check := range checks {
+		r.Doctor.Checks = append(r.Doctor.Checks, DoctorCheck{Name: check.Name, Status: check.Status.String(), Detail: check.Detail})
+	}
+	r.FinishedAt = time.Now()
+}
+
+// Write saves the report as indented JSON.
+func (r *Report) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+	return nil
+}
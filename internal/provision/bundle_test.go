@@ -0,0 +1,194 @@
+package provision
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/doctor"
+)
+
+// entry is a file of a test bundle; a Typeflag other than TypeReg makes it
+// a link to body.
+type entry struct {
+	name string
+	body string
+	kind byte
+}
+
+// writeBundle packs entries into a .tar.gz and returns its path.
+func writeBundle(t *testing.T, entries ...entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0600, Typeflag: e.kind}
+		switch e.kind {
+		case 0, tar.TypeReg:
+			header.Typeflag, header.Size = tar.TypeReg, int64(len(e.body))
+		case tar.TypeDir:
+			header.Mode = 0700
+		default:
+			header.Linkname = e.body
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(e.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestOpenBundle(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		// the prod config's name within the bundle
+		want string
+	}{
+		{
+			name:    "manifest at the top",
+			entries: []entry{{name: "provision.toml", body: "version = 3\nprod_config = \"issued/julo-prod.conf\"\n"}, {name: "issued/julo-prod.conf", body: "[Interface]\n"}},
+			want:    "issued/julo-prod.conf",
+		},
+		{
+			name: "manifest in the only directory",
+			entries: []entry{
+				{name: "./fleet-2026-05/", kind: tar.TypeDir},
+				{name: "./fleet-2026-05/provision.toml", body: "version = 3\nprod_config = \"julo-prod.conf\"\n"},
+				{name: "./fleet-2026-05/julo-prod.conf", body: "[Interface]\n"},
+			},
+			want: "julo-prod.conf",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := OpenBundle(writeBundle(t, tt.entries...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer b.Close()
+			if b.Provision.Version != 3 {
+				t.Errorf("Version = %d", b.Provision.Version)
+			}
+			if got := b.Name(b.Provision.ProdConfig); got != tt.want {
+				t.Errorf("prod config %q, want %q", got, tt.want)
+			}
+			if err := b.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(b.Dir); !os.IsNotExist(err) {
+				t.Errorf("Close left %s", b.Dir)
+			}
+		})
+	}
+}
+
+func TestOpenBundleRefuses(t *testing.T) {
+	manifest := entry{name: "provision.toml", body: "version = 1\n"}
+	outside := filepath.Join(t.TempDir(), "julo-prod.conf")
+	if err := os.WriteFile(outside, []byte("[Interface]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		entries []entry
+		err     string
+	}{
+		{"traversal", []entry{manifest, {name: "../../etc/wireguard/julo-prod.conf", body: "x"}}, "points outside the bundle"},
+		{"absolute path", []entry{manifest, {name: "/etc/wireguard/julo-prod.conf", body: "x"}}, "points outside the bundle"},
+		{"symlink", []entry{manifest, {name: "julo-prod.conf", body: "/etc/shadow", kind: tar.TypeSymlink}}, "not a regular file or directory"},
+		{"hard link", []entry{manifest, {name: "julo-prod.conf", body: "provision.toml", kind: tar.TypeLink}}, "not a regular file or directory"},
+		{"too large", []entry{manifest, {name: "big.conf", body: strings.Repeat("#", maxBundleFile+1)}}, "larger than"},
+		{"no manifest", []entry{{name: "julo-prod.conf", body: "[Interface]\n"}}, "no provision.toml"},
+		{"no version", []entry{{name: "provision.toml", body: "prod_config = \"julo-prod.conf\"\n"}, {name: "julo-prod.conf", body: "x"}}, "version is required"},
+		{"reference outside", []entry{{name: "provision.toml", body: "version = 1\nprod_config = " + strconv.Quote(outside) + "\n"}}, "is outside the bundle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := OpenBundle(writeBundle(t, tt.entries...))
+			if err == nil {
+				b.Close()
+				t.Fatal("OpenBundle() succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("OpenBundle() = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	notGzip := filepath.Join(t.TempDir(), "bundle.tar.gz")
+	if err := os.WriteFile(notGzip, []byte("provision.toml"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBundle(notGzip); err == nil || !strings.Contains(err.Error(), "not a .tar.gz") {
+		t.Errorf("OpenBundle(not gzip) = %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	tests := []struct {
+		name            string
+		items           []Item
+		changed, failed bool
+	}{
+		{"rerun", []Item{{Item: "prod template", Action: config.ItemSkipped}, {Item: "prod config", Action: config.ItemSkipped}}, false, false},
+		{"first run", []Item{{Item: "prod template", Action: config.ItemInstalled}, {Item: "prod config", Action: config.ItemSkipped}}, true, false},
+		{"partly failed", []Item{{Item: "prod config", Action: config.ItemUpdated}, {Item: "nonprod config", Action: config.ItemFailed, Reason: "not installed: the nonprod template failed"}}, true, true},
+		{"nothing", nil, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReport("bundle.tar.gz")
+			r.Add(tt.items...)
+			r.Finish([]doctor.Check{{Name: "wg installed", Status: doctor.OK}})
+			if r.Changed != tt.changed || r.Failed != tt.failed {
+				t.Errorf("Changed, Failed = %v, %v; want %v, %v", r.Changed, r.Failed, tt.changed, tt.failed)
+			}
+
+			path := filepath.Join(t.TempDir(), "report.json")
+			if err := r.Write(path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var report map[string]any
+			if err := json.Unmarshal(data, &report); err != nil {
+				t.Fatal(err)
+			}
+			if report["version"] != float64(ReportVersion) || report["items"] == nil || report["doctor"] == nil {
+				t.Errorf("report:\n%s", data)
+			}
+		})
+	}
+
+	r := NewReport("bundle.tar.gz")
+	r.Fail(os.ErrNotExist)
+	if !r.Failed || r.Error != "file does not exist" {
+		t.Errorf("after Fail: Failed %v, Error %q", r.Failed, r.Error)
+	}
+}
@@ -0,0 +1,153 @@
+// Package provision applies the provisioning file fleet tooling places on a
+// machine (see settings.Provision): the templates and issued configs are
+// installed through the usual config paths, the settings become the user's
+// defaults, and the applied version is recorded so each version is only
+// applied once. A bundle packs a provisioning file with the files it
+// references, for fleet tooling to apply unattended with a report.
+package provision
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/state"
+)
+
+// Pending loads the provisioning file at path and returns it when its
+// version hasn't been applied yet, with the record of the version applied
+// before (nil the first time). No file means nothing is pending.
+func Pending(path string) (*settings.Provision, *state.Provisioning, error) {
+	p, err := settings.LoadProvision(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	st, err := state.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if st.Provisioned != nil && st.Provisioned.Version >= p.Version {
+		return nil, st.Provisioned, nil
+	}
+	return p, st.Provisioned, nil
+}
+
+// Changes describes what applying p changes compared to the previously
+// applied version, one line per difference.
+func Changes(previous *state.Provisioning, p *settings.Provision) []string {
+	if previous == nil {
+		return nil
+	}
+	changes := []string{fmt.Sprintf("version %d → %d", previous.Version, p.Version)}
+	current := record(p).Files
+
+	roles := map[string]bool{}
+	for role := range previous.Files {
+		roles[role] = true
+	}
+	for role := range current {
+		roles[role] = true
+	}
+	sorted := make([]string, 0, len(roles))
+	for role := range roles {
+		sorted = append(sorted, role)
+	}
+	sort.Strings(sorted)
+
+	for _, role := range sorted {
+		before, had := previous.Files[role]
+		after, has := current[role]
+		switch {
+		case !had:
+			changes = append(changes, fmt.Sprintf("%s added: %s", role, after.Path))
+		case !has:
+			changes = append(changes, fmt.Sprintf("%s no longer provisioned (the installed one is kept)", role))
+		case before.Path != after.Path:
+			changes = append(changes, fmt.Sprintf("%s: %s → %s", role, before.Path, after.Path))
+		case before.SHA256 == "" || after.SHA256 == "":
+			changes = append(changes, fmt.Sprintf("%s: %s (re-installed; contents not readable to compare)", role, after.Path))
+		case before.SHA256 != after.SHA256:
+			changes = append(changes, fmt.Sprintf("%s: %s contents changed", role, after.Path))
+		}
+	}
+	return changes
+}
+
+// Install writes the templates and configs under /etc/wireguard. It needs
+// the privileges setup needs.
+func Install(p *settings.Provision) ([]string, error) {
+	// The provisioned settings can require stripping hooks, and they apply
+	// to the configs provisioned with them
+	if p.Settings != "" {
+		if provisioned, err := settings.LoadFile(p.Settings); err == nil && provisioned.StripHookScripts {
+			config.SetStripHooks(true)
+		}
+	}
+	return config.RunProvisioning(p.ProdTemplate, p.NonProdTemplate, p.ProdConfig, p.NonProdConfig)
+}
+
+// Finish does the unprivileged part once Install succeeded: the provisioned
+// settings become the user's when they have none, and the applied version
+// is recorded. It reports whether the settings file was installed.
+func Finish(p *settings.Provision) (bool, error) {
+	installed, err := installSettings(p)
+	if err != nil {
+		return false, err
+	}
+	err = state.Update(func(s *state.State) {
+		s.Provisioned = record(p)
+		if s.Configs == nil {
+			s.Configs = map[string]*state.ConfigProvenance{}
+		}
+		for env, path := range map[string]string{"prod": p.ProdConfig, "nonprod": p.NonProdConfig} {
+			if path != "" {
+				s.Configs[env] = &state.ConfigProvenance{Source: "provisioned " + path, UpdatedAt: time.Now()}
+			}
+		}
+	})
+	return installed, err
+}
+
+// installSettings copies the provisioned settings file to the user's
+// settings path unless the user already has settings of their own.
+func installSettings(p *settings.Provision) (bool, error) {
+	if p.Settings == "" {
+		return false, nil
+	}
+	path, err := settings.Path()
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	}
+	content, err := os.ReadFile(p.Settings)
+	if err != nil {
+		return false, fmt.Errorf("failed to read provisioned settings: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return false, fmt.Errorf("failed to create settings directory: %v", err)
+	}
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return false, fmt.Errorf("failed to write settings file: %v", err)
+	}
+	return true, nil
+}
+
+// record describes p for the state file. Files that can't be read without
+// privileges (issued configs usually) are recorded by path only.
+func record(p *settings.Provision) *state.Provisioning {
+	files := map[string]state.ProvisionedFile{}
+	for role, path := range p.Files() {
+		digest, _ := state.HashFile(path)
+		files[role] = state.ProvisionedFile{Path: path, SHA256: digest}
+	}
+	return &state.Provisioning{Version: p.Version, Files: files, AppliedAt: time.Now()}
+}
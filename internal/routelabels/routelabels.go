@@ -0,0 +1,191 @@
+// Package routelabels keeps short labels for AllowedIPs ranges ("GKE pods"),
+// shown wherever the app lists routes.
+//
+// The user's labels live in annotations.json in the config directory, which
+// the Routes view edits. Labels shipped for every user of the machine, e.g.
+// by a provisioning bundle, live in SystemFile and fill in the ranges the
+// user hasn't labelled: a user's label always wins over a shipped one, and
+// the difference is reported as a Conflict.
+package routelabels
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"tui-wireguard-vpn/internal/paths"
+)
+
+const (
+	// File holds the user's labels in the config directory.
+	File = "annotations.json"
+	// SystemFile holds the labels shipped for every user of the machine.
+	SystemFile = "/etc/tui-wireguard-vpn/annotations.json"
+	// MaxLength is the longest label, in characters, so it fits next to a
+	// range in the Routes view.
+	MaxLength = 40
+)
+
+// Labels maps ranges, masked and in canonical form ("10.80.0.0/16"), to
+// their labels.
+type Labels map[string]string
+
+// Label returns prefix's label, "" when it has none.
+func (l Labels) Label(prefix netip.Prefix) string {
+	if !prefix.IsValid() {
+		return ""
+	}
+	return l[prefix.Masked().String()]
+}
+
+// Conflict is a range labelled both by the user and by the shipped labels,
+// differently. The user's label is the one kept.
+type Conflict struct {
+	Prefix  string
+	Local   string
+	Shipped string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: kept your label %q over the shipped %q", c.Prefix, c.Local, c.Shipped)
+}
+
+// document is the layout of both files.
+type document struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// Path returns the location of the user's labels.
+func Path() (string, error) {
+	return paths.File(paths.Config, File)
+}
+
+// Load reads the user's labels merged over the shipped ones. Missing files
+// yield no labels.
+func Load() (Labels, []Conflict, error) {
+	shipped, err := LoadFile(SystemFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	local, err := loadLocal()
+	if err != nil {
+		return nil, nil, err
+	}
+	labels, conflicts := Merge(local, shipped)
+	return labels, conflicts, nil
+}
+
+func loadLocal() (Labels, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	return LoadFile(path)
+}
+
+// LoadFile reads a labels file. A missing file yields no labels.
+func LoadFile(path string) (Labels, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Labels{}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid labels file %s: %v", path, err)
+	}
+	labels := Labels{}
+	for key, label := range doc.Labels {
+		prefix, err := netip.ParsePrefix(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid labels file %s: %q is not a CIDR", path, key)
+		}
+		if label = strings.TrimSpace(label); label != "" {
+			labels[prefix.Masked().String()] = label
+		}
+	}
+	return labels, nil
+}
+
+// Merge lays local over shipped. Ranges both label differently keep the
+// local label and are returned as conflicts, by range.
+func Merge(local, shipped Labels) (Labels, []Conflict) {
+	merged := Labels{}
+	for prefix, label := range shipped {
+		merged[prefix] = label
+	}
+	var conflicts []Conflict
+	for prefix, label := range local {
+		if other, ok := shipped[prefix]; ok && other != label {
+			conflicts = append(conflicts, Conflict{Prefix: prefix, Local: label, Shipped: other})
+		}
+		merged[prefix] = label
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Prefix < conflicts[j].Prefix })
+	return merged, conflicts
+}
+
+// Validate checks a label as typed, before Set.
+func Validate(label string) error {
+	if strings.ContainsAny(label, "\r\n\t") {
+		return fmt.Errorf("a label is a single line")
+	}
+	if n := len([]rune(strings.TrimSpace(label))); n > MaxLength {
+		return fmt.Errorf("a label is at most %d characters, not %d", MaxLength, n)
+	}
+	return nil
+}
+
+// Set labels prefix in the user's file, or removes its label when label is
+// empty. The file is replaced atomically.
+func Set(prefix netip.Prefix, label string) error {
+	if err := Validate(label); err != nil {
+		return err
+	}
+	local, err := loadLocal()
+	if err != nil {
+		return err
+	}
+	key := prefix.Masked().String()
+	if label = strings.TrimSpace(label); label == "" {
+		delete(local, key)
+	} else {
+		local[key] = label
+	}
+	return save(local)
+}
+
+func save(labels Labels) error {
+	path, err := paths.EnsureFile(paths.Config, File)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(document{Labels: labels}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), File+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package schedule decides when the scheduled server sync (sync_schedule in
+// the settings) is due. A schedule is a time of day, daily or on one
+// weekday, in local time:
+//
+//	sync_schedule = "weekly Monday 09:00"
+//	sync_schedule = "daily 08:30"
+//
+// Runs are keyed by the slot they cover (the scheduled time), not by when
+// they happened, so each slot runs at most once however the clock moves: a
+// clock set back never reaches a new slot, and one set forward by days runs
+// only the latest slot it skipped.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Clock is the time source of a Claimer.
+type Clock func() time.Time
+
+// Schedule is a recurring time of day.
+type Schedule struct {
+	Daily   bool
+	Weekday time.Weekday // when not Daily
+	Hour    int
+	Minute  int
+}
+
+// Parse reads "daily HH:MM", "weekly <weekday> HH:MM" or "<weekday> HH:MM".
+// Weekdays may be abbreviated to three letters ("Mon").
+func Parse(text string) (*Schedule, error) {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) > 0 && fields[0] == "weekly" {
+		fields = fields[1:]
+	}
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("schedule %q must be \"daily HH:MM\" or \"weekly <weekday> HH:MM\"", text)
+	}
+
+	s := &Schedule{}
+	if fields[0] == "daily" {
+		s.Daily = true
+	} else {
+		day, ok := parseWeekday(fields[0])
+		if !ok {
+			return nil, fmt.Errorf("schedule %q: unknown weekday %q", text, fields[0])
+		}
+		s.Weekday = day
+	}
+
+	hour, minute, ok := strings.Cut(fields[1], ":")
+	var err error
+	if ok {
+		if s.Hour, err = strconv.Atoi(hour); err == nil {
+			s.Minute, err = strconv.Atoi(minute)
+		}
+	}
+	if !ok || err != nil || s.Hour < 0 || s.Hour > 23 || s.Minute < 0 || s.Minute > 59 {
+		return nil, fmt.Errorf("schedule %q: time must be HH:MM (24-hour)", text)
+	}
+	return s, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Schedule) String() string {
+	if s.Daily {
+		return fmt.Sprintf("daily %02d:%02d", s.Hour, s.Minute)
+	}
+	return fmt.Sprintf("weekly %s %02d:%02d", s.Weekday, s.Hour, s.Minute)
+}
+
+// Prev returns the latest slot at or before now, in now's location.
+func (s *Schedule) Prev(now time.Time) time.Time {
+	slot := time.Date(now.Year(), now.Month(), now.Day(), s.Hour, s.Minute, 0, 0, now.Location())
+	if slot.After(now) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if !s.Daily {
+		back := (int(slot.Weekday()) - int(s.Weekday) + 7) % 7
+		slot = slot.AddDate(0, 0, -back)
+	}
+	return slot
+}
+
+// Due returns the slot to run at now, and whether it is due: it is later
+// than lastRun, the slot that ran last (zero if none ever did).
+func (s *Schedule) Due(now, lastRun time.Time) (time.Time, bool) {
+	slot := s.Prev(now)
+	return slot, slot.After(lastRun)
+}
+
+// Claimer hands out the slots of a schedule as they come due, each once
+// across every process sharing its record of the last run.
+type Claimer struct {
+	Schedule *Schedule
+	// Clock is nil for the wall clock
+	Clock Clock
+	// LastRun returns the slot that ran last, zero if none ever did
+	LastRun func() time.Time
+	// Claim records slot as run. It reports false when slot, or a later
+	// one, was claimed first.
+	Claim func(slot time.Time) (bool, error)
+}
+
+// Next returns the slot due now, if any, and whether this call claimed it:
+// only then is it the caller's to run.
+func (c *Claimer) Next() (time.Time, bool, error) {
+	now := c.Clock
+	if now == nil {
+		now = time.Now
+	}
+	slot, due := c.Schedule.Due(now(), c.LastRun())
+	if !due {
+		return slot, false, nil
+	}
+	claimed, err := c.Claim(slot)
+	return slot, claimed, err
+}
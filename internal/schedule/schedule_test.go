@@ -0,0 +1,206 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // "" for an error
+	}{
+		{"weekly Monday 09:00", "weekly Monday 09:00"},
+		{"Monday 09:00", "weekly Monday 09:00"},
+		{"weekly mon 9:05", "weekly Monday 09:05"},
+		{"daily 08:30", "daily 08:30"},
+		{"DAILY 00:00", "daily 00:00"},
+		{"daily 23:59", "daily 23:59"},
+		{"daily 24:00", ""},
+		{"daily 08:60", ""},
+		{"daily 0830", ""},
+		{"weekly Mondays 09:00", ""},
+		{"weekly Monday", ""},
+		{"every day 08:30", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.text)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("Parse(%q) = %v, want an error", tt.text, s)
+		case tt.want != "" && err != nil:
+			t.Errorf("Parse(%q) failed: %v", tt.text, err)
+		case tt.want != "" && s.String() != tt.want:
+			t.Errorf("Parse(%q) = %v, want %s", tt.text, s, tt.want)
+		}
+	}
+}
+
+func TestPrev(t *testing.T) {
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	weekly := &Schedule{Weekday: time.Monday, Hour: 9}
+	daily := &Schedule{Daily: true, Hour: 23, Minute: 30}
+	tests := []struct {
+		name     string
+		schedule *Schedule
+		now      time.Time
+		want     time.Time
+	}{
+		// 2026-05-11 is a Monday
+		{"window start", weekly, at(5, 11, 9, 0), at(5, 11, 9, 0)},
+		{"just before", weekly, at(5, 11, 8, 59), at(5, 4, 9, 0)},
+		{"later that day", weekly, at(5, 11, 17, 0), at(5, 11, 9, 0)},
+		{"window end", weekly, at(5, 18, 8, 59), at(5, 11, 9, 0)},
+		{"next window", weekly, at(5, 18, 9, 0), at(5, 18, 9, 0)},
+		{"weekday before", weekly, at(5, 10, 12, 0), at(5, 4, 9, 0)},
+		{"daily", daily, at(5, 11, 23, 45), at(5, 11, 23, 30)},
+		{"day rollover", daily, at(5, 12, 0, 10), at(5, 11, 23, 30)},
+		{"month rollover", daily, at(6, 1, 0, 10), at(5, 31, 23, 30)},
+		{"year rollover", daily, time.Date(2027, time.January, 1, 0, 5, 0, 0, time.UTC), time.Date(2026, time.December, 31, 23, 30, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Prev(tt.now); !got.Equal(tt.want) {
+				t.Errorf("Prev(%s) = %s, want %s", tt.now.Format(time.ANSIC), got.Format(time.ANSIC), tt.want.Format(time.ANSIC))
+			}
+		})
+	}
+}
+
+// fakeClock is a clock the test sets.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+// runs is the record of the last run a state file keeps, with the same
+// claim rule.
+type runs struct {
+	last    time.Time
+	claimed []time.Time
+}
+
+func (r *runs) lastRun() time.Time { return r.last }
+
+func (r *runs) claim(slot time.Time) (bool, error) {
+	if !slot.After(r.last) {
+		return false, nil
+	}
+	r.last = slot
+	r.claimed = append(r.claimed, slot)
+	return true, nil
+}
+
+func newClaimer(s *Schedule, clock *fakeClock, r *runs) *Claimer {
+	return &Claimer{Schedule: s, Clock: clock.Now, LastRun: r.lastRun, Claim: r.claim}
+}
+
+func TestClaimer(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.May, day, hour, minute, 0, 0, time.UTC)
+	}
+	clock := &fakeClock{}
+	r := &runs{}
+	claimer := newClaimer(&Schedule{Weekday: time.Monday, Hour: 9}, clock, r)
+	steps := []struct {
+		name string
+		now  time.Time
+		want time.Time // the slot claimed, zero for none
+	}{
+		// A slot missed while nothing ran is caught up on
+		{"first start", at(11, 8, 0), at(4, 9, 0)},
+		{"same window", at(11, 8, 59), time.Time{}},
+		{"window start", at(11, 9, 0), at(11, 9, 0)},
+		{"again", at(11, 9, 0), time.Time{}},
+		{"window end", at(18, 8, 59), time.Time{}},
+		{"clock set back", at(11, 8, 30), time.Time{}},
+		{"back to the slot", at(11, 9, 5), time.Time{}},
+		{"next window", at(18, 9, 0), at(18, 9, 0)},
+		// Only the latest of the skipped slots runs
+		{"clock set forward by weeks", at(31, 12, 0), at(25, 9, 0)},
+		{"after the jump", at(31, 12, 1), time.Time{}},
+	}
+	for _, step := range steps {
+		clock.now = step.now
+		slot, claimed, err := claimer.Next()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if claimed != !step.want.IsZero() || (claimed && !slot.Equal(step.want)) {
+			t.Errorf("%s: Next() = %s, %v; want %s claimed", step.name, slot.Format(time.ANSIC), claimed, step.want.Format(time.ANSIC))
+		}
+	}
+}
+
+// Two processes sharing the record run each slot once between them.
+func TestClaimerShared(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, time.May, 11, 9, 0, 0, 0, time.UTC)}
+	r := &runs{}
+	daily := &Schedule{Daily: true, Hour: 9}
+	tui, agent := newClaimer(daily, clock, r), newClaimer(daily, clock, r)
+	if _, claimed, _ := tui.Next(); !claimed {
+		t.Error("the first process didn't claim the slot")
+	}
+	if _, claimed, _ := agent.Next(); claimed {
+		t.Error("the second process claimed the slot again")
+	}
+}
+
+// walk moves the clock a minute at a time from start to end, claiming as
+// it goes, and returns the slots claimed.
+func walk(t *testing.T, s *Schedule, start, end time.Time) []time.Time {
+	t.Helper()
+	clock := &fakeClock{now: start}
+	r := &runs{last: s.Prev(start)}
+	claimer := newClaimer(s, clock, r)
+	for ; !clock.now.After(end); clock.now = clock.now.Add(time.Minute) {
+		if _, _, err := claimer.Next(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r.claimed
+}
+
+// TestClaimerDST runs a daily slot inside the hour that daylight saving
+// time skips, and one inside the hour it repeats: each day runs once.
+func TestClaimerDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		schedule   *Schedule
+		start, end time.Time
+	}{
+		// 02:00 EST jumps to 03:00 EDT on 2026-03-08
+		{"spring forward", &Schedule{Daily: true, Hour: 2, Minute: 30}, time.Date(2026, time.March, 7, 12, 0, 0, 0, newYork), time.Date(2026, time.March, 10, 12, 0, 0, 0, newYork)},
+		// 02:00 EDT goes back to 01:00 EST on 2026-11-01
+		{"fall back", &Schedule{Daily: true, Hour: 1, Minute: 30}, time.Date(2026, time.October, 31, 12, 0, 0, 0, newYork), time.Date(2026, time.November, 3, 12, 0, 0, 0, newYork)},
+		{"fall back, weekly", &Schedule{Weekday: time.Sunday, Hour: 1, Minute: 30}, time.Date(2026, time.October, 31, 12, 0, 0, 0, newYork), time.Date(2026, time.November, 3, 12, 0, 0, 0, newYork)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claimed := walk(t, tt.schedule, tt.start, tt.end)
+			days := map[string]int{}
+			for _, slot := range claimed {
+				days[slot.Format(time.DateOnly)]++
+			}
+			want := 3
+			if !tt.schedule.Daily {
+				want = 1
+			}
+			if len(claimed) != want {
+				t.Errorf("claimed %d slots, want %d: %v", len(claimed), want, claimed)
+			}
+			for day, n := range days {
+				if n > 1 {
+					t.Errorf("%s ran %d times", day, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package settings
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"tui-wireguard-vpn/internal/paths"
+)
+
+const (
+	// OrgFile is the organization config in the user's config directory,
+	// which takes precedence over SystemOrgFile.
+	OrgFile = "org.toml"
+	// SystemOrgFile is where IT places the organization config for every
+	// user of the machine.
+	SystemOrgFile = "/etc/tui-wireguard-vpn/org.toml"
+)
+
+// Org is an organization config: the WireGuard gateways the app manages
+// configs for, instead of the built-in JULO ones. It uses the settings file
+// syntax, an [environments.<name>] section per environment:
+//
+//	[environments.prod]
+//	name = "Production"
+//	endpoint = "vpn.example.com:51820"
+//	public_key = "…"
+//	dns = ["10.0.0.2"]
+//	allowed_ips = ["10.0.0.0/16"]
+//
+// prod and nonprod are required; other names add profiles.
+type Org struct {
+	Path string
+	// Environments are prod, nonprod, then the other profiles by name
+	Environments []*OrgEnvironment
+}
+
+// OrgEnvironment is one environment's gateway: what recognizes its configs
+// and what its template holds.
+type OrgEnvironment struct {
+	// Key is the short name ("prod"), Name the display name ("Production",
+	// "" for the default)
+	Key  string
+	Name string
+	// Endpoint (host:port) and PublicKey identify the gateway
+	Endpoint  string
+	PublicKey string
+	// DNS and AllowedIPs are what the merge gives every config
+	DNS        []string
+	AllowedIPs []string
+	// MTU and PersistentKeepalive go into the template when set
+	MTU                 int
+	PersistentKeepalive int
+}
+
+// OrgPaths lists where the organization config is looked for, in order of
+// precedence.
+func OrgPaths() []string {
+	var found []string
+	if path, err := paths.File(paths.Config, OrgFile); err == nil {
+		found = append(found, path)
+	}
+	return append(found, SystemOrgFile)
+}
+
+// LoadOrg reads the first organization config of OrgPaths. With none there
+// it returns nil and no error: the built-in gateways apply.
+func LoadOrg() (*Org, error) {
+	for _, path := range OrgPaths() {
+		org, err := LoadOrgFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		return org, err
+	}
+	return nil, nil
+}
+
+// LoadOrgFile reads and validates an organization config. A missing file
+// returns an error satisfying os.IsNotExist.
+func LoadOrgFile(path string) (*Org, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	doc, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid organization config %s: %v", path, err)
+	}
+	org := &Org{Path: path}
+	for section, values := range doc {
+		key, ok := strings.CutPrefix(section, "environments.")
+		if !ok {
+			continue
+		}
+		env, err := parseOrgEnvironment(key, values)
+		if err != nil {
+			return nil, fmt.Errorf("invalid organization config %s: [environments.%s]: %v", path, key, err)
+		}
+		org.Environments = append(org.Environments, env)
+	}
+	rank := func(key string) int {
+		switch key {
+		case "prod":
+			return 0
+		case "nonprod":
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(org.Environments, func(i, j int) bool {
+		a, b := org.Environments[i].Key, org.Environments[j].Key
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return a < b
+	})
+	if len(org.Environments) < 2 || org.Environments[0].Key != "prod" || org.Environments[1].Key != "nonprod" {
+		return nil, fmt.Errorf("invalid organization config %s: [environments.prod] and [environments.nonprod] are required", path)
+	}
+	return org, nil
+}
+
+func parseOrgEnvironment(key string, values map[string]value) (*OrgEnvironment, error) {
+	env := &OrgEnvironment{Key: key}
+	if v, ok := values["name"]; ok {
+		env.Name = strings.TrimSpace(v.String())
+	}
+
+	v, ok := values["endpoint"]
+	if !ok {
+		return nil, fmt.Errorf("endpoint is required")
+	}
+	env.Endpoint = strings.TrimSpace(v.String())
+	host, port, err := net.SplitHostPort(env.Endpoint)
+	if n, perr := strconv.Atoi(port); err != nil || host == "" || perr != nil || n < 1 || n > 65535 {
+		return nil, fmt.Errorf("line %d: endpoint must be host:port, not %q", v.line, env.Endpoint)
+	}
+
+	if v, ok = values["public_key"]; !ok {
+		return nil, fmt.Errorf("public_key is required")
+	}
+	env.PublicKey = strings.TrimSpace(v.String())
+	if key, err := base64.StdEncoding.DecodeString(env.PublicKey); err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("line %d: public_key is not a WireGuard key", v.line)
+	}
+
+	if v, ok = values["dns"]; !ok || len(v.List()) == 0 {
+		return nil, fmt.Errorf("dns is required")
+	}
+	env.DNS = v.List()
+
+	if v, ok = values["allowed_ips"]; !ok || len(v.List()) == 0 {
+		return nil, fmt.Errorf("allowed_ips is required")
+	}
+	for _, prefix := range v.List() {
+		if _, err := netip.ParsePrefix(prefix); err != nil {
+			return nil, fmt.Errorf("line %d: allowed_ips: %q is not a CIDR prefix", v.line, prefix)
+		}
+	}
+	env.AllowedIPs = v.List()
+
+	for name, field := range map[string]*int{"mtu": &env.MTU, "persistent_keepalive": &env.PersistentKeepalive} {
+		v, ok := values[name]
+		if !ok {
+			continue
+		}
+		n, err := v.Int()
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("line %d: %s must be a non-negative number", v.line, name)
+		}
+		*field = n
+	}
+	return env, nil
+}
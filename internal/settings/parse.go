@@ -0,0 +1,170 @@
+package settings
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// document is the raw result of parsing a settings file: a map of section
+// name ("" for the top level) to its key/value pairs.
+type document map[string]map[string]value
+
+// value is a single parsed right-hand side. Only the small subset of TOML
+// the settings file needs is supported: strings, integers, booleans and
+// flat arrays of strings, which may span lines.
+type value struct {
+	str   string
+	list  []string
+	isArr bool
+	line  int
+}
+
+func (v value) String() string { return v.str }
+
+func (v value) Bool() (bool, error) {
+	return strconv.ParseBool(v.str)
+}
+
+func (v value) Int() (int, error) {
+	return strconv.Atoi(v.str)
+}
+
+func (v value) List() []string {
+	if v.isArr {
+		return v.list
+	}
+	if v.str == "" {
+		return nil
+	}
+	return []string{v.str}
+}
+
+// parse reads a TOML-style settings file:
+//
+//	auto_connect = "nonprod"
+//
+//	[profiles.prod]
+//	endpoint_host = "vpn-prod.example.com"
+func parse(r io.Reader) (document, error) {
+	doc := document{"": {}}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == "" {
+				return nil, fmt.Errorf("line %d: empty section name", lineNo)
+			}
+			if _, ok := doc[section]; !ok {
+				doc[section] = map[string]value{}
+			}
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key := strings.TrimSpace(parts[0])
+		if key == "" {
+			return nil, fmt.Errorf("line %d: missing key", lineNo)
+		}
+		raw := strings.TrimSpace(parts[1])
+		start := lineNo
+		// An array goes on until its closing bracket
+		for strings.HasPrefix(raw, "[") && !strings.HasSuffix(raw, "]") && scanner.Scan() {
+			lineNo++
+			raw += " " + strings.TrimSpace(stripComment(scanner.Text()))
+			raw = strings.TrimSpace(raw)
+		}
+		v, err := parseValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", start, err)
+		}
+		v.line = start
+		doc[section][key] = v
+	}
+	return doc, scanner.Err()
+}
+
+func parseValue(raw string) (value, error) {
+	if strings.HasPrefix(raw, "[") {
+		if !strings.HasSuffix(raw, "]") {
+			return value{}, fmt.Errorf("unterminated array")
+		}
+		inner := strings.TrimSpace(raw[1 : len(raw)-1])
+		v := value{isArr: true}
+		if inner == "" {
+			return v, nil
+		}
+		for _, item := range strings.Split(inner, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			s, err := unquote(item)
+			if err != nil {
+				return value{}, err
+			}
+			v.list = append(v.list, s)
+		}
+		return v, nil
+	}
+	s, err := unquote(raw)
+	if err != nil {
+		return value{}, err
+	}
+	return value{str: s}, nil
+}
+
+func unquote(raw string) (string, error) {
+	if strings.HasPrefix(raw, `"`) {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return s, nil
+	}
+	if strings.HasPrefix(raw, "'") {
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	}
+	return raw, nil
+}
+
+// stripComment removes a trailing # comment that is not inside a quoted string.
+func stripComment(line string) string {
+	inQuote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuote != 0:
+			if c == '\\' && inQuote == '"' {
+				i++
+			} else if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -0,0 +1,115 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProvisionFile is where fleet tooling places a provisioning file so the
+// first launch needs no interaction.
+const ProvisionFile = "/etc/tui-wireguard-vpn/provision.toml"
+
+// Provision is a provisioning file: the issued configs, templates and
+// settings IT pre-placed on the machine. It uses the settings file syntax:
+//
+//	version = 3
+//	prod_config = "issued/julo-prod.conf"
+//	nonprod_config = "issued/julo-nonprod.conf"
+//	settings = "settings.toml"
+//
+// Relative paths are resolved against the provisioning file's directory.
+type Provision struct {
+	Path string
+	// Version increases whenever IT changes anything; a machine applies each
+	// version once
+	Version int
+	// ProdConfig and NonProdConfig are issued configs, merged like a
+	// picked file
+	ProdConfig    string
+	NonProdConfig string
+	// ProdTemplate and NonProdTemplate replace the built-in templates
+	ProdTemplate    string
+	NonProdTemplate string
+	// Settings is a settings file (profile metadata, template sources,
+	// defaults) installed for users who don't have one yet
+	Settings string
+}
+
+// Files maps each file the provisioning references to its role, e.g.
+// "prod config".
+func (p *Provision) Files() map[string]string {
+	files := map[string]string{}
+	for role, path := range map[string]string{
+		"prod config":      p.ProdConfig,
+		"nonprod config":   p.NonProdConfig,
+		"prod template":    p.ProdTemplate,
+		"nonprod template": p.NonProdTemplate,
+		"settings":         p.Settings,
+	} {
+		if path != "" {
+			files[role] = path
+		}
+	}
+	return files
+}
+
+// LoadProvision reads and validates a provisioning file. A missing file
+// returns an error satisfying os.IsNotExist.
+func LoadProvision(path string) (*Provision, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	doc, err := parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provisioning file %s: %v", path, err)
+	}
+	p := &Provision{Path: path}
+	top := doc[""]
+	v, ok := top["version"]
+	if !ok {
+		return nil, fmt.Errorf("invalid provisioning file %s: version is required", path)
+	}
+	if p.Version, err = v.Int(); err != nil || p.Version < 1 {
+		return nil, fmt.Errorf("invalid provisioning file %s: line %d: version must be a positive number", path, v.line)
+	}
+
+	dir := filepath.Dir(path)
+	for key, field := range map[string]*string{
+		"prod_config":      &p.ProdConfig,
+		"nonprod_config":   &p.NonProdConfig,
+		"prod_template":    &p.ProdTemplate,
+		"nonprod_template": &p.NonProdTemplate,
+		"settings":         &p.Settings,
+	} {
+		v, ok := top[key]
+		if !ok {
+			continue
+		}
+		file := strings.TrimSpace(v.String())
+		if file == "" {
+			continue
+		}
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+		// Issued configs are often root-only; only a missing file is an error
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return nil, fmt.Errorf("invalid provisioning file %s: line %d: %s not found", path, v.line, file)
+		}
+		*field = file
+	}
+	if p.ProdConfig == "" && p.NonProdConfig == "" {
+		return nil, fmt.Errorf("invalid provisioning file %s: no prod_config or nonprod_config", path)
+	}
+	if p.Settings != "" {
+		if _, err := LoadFile(p.Settings); err != nil {
+			return nil, fmt.Errorf("provisioned settings: %v", err)
+		}
+	}
+	return p, nil
+}
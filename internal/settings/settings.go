@@ -0,0 +1,557 @@
+package settings
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"tui-wireguard-vpn/internal/paths"
+	"tui-wireguard-vpn/internal/schedule"
+)
+
+const SettingsFile = "settings.toml"
+
+// Profile holds the per-environment metadata users (or their infra team)
+// can declare in the [profiles.<name>] sections of the settings file. A
+// section for another name than prod or nonprod adds that profile, with its
+// config installed as julo-<name>.conf.
+type Profile struct {
+	Name string
+	// Endpoint is the gateway (host:port) configs of a profile besides
+	// prod and nonprod name, which is how the app recognizes them.
+	Endpoint string
+	// EndpointHost is the canonical DNS name of the environment's gateway.
+	// When set, the configured numeric Endpoint is periodically checked
+	// against it to catch gateway migrations.
+	EndpointHost string
+	// Hostnames lists additional gateway DNS names issued configs may use
+	// as their Endpoint; configs naming one of them are recognized as this
+	// profile without a DNS lookup.
+	Hostnames []string
+	// RemoteTemplateURL and RemoteConfigURL point at the canonical
+	// template and the user's issued config on the infra team's server.
+	// RemoteAuthEnv names the environment variable holding the
+	// Authorization header value sent with both requests.
+	RemoteTemplateURL string
+	RemoteConfigURL   string
+	RemoteAuthEnv     string
+	// Confirm marks a sensitive profile: connecting to it without an explicit
+	// menu selection (e.g. auto-connect) asks for confirmation first.
+	Confirm bool
+	// SSHHosts are ~/.ssh/config Host aliases (globs) reachable through this
+	// profile, for hosts whose HostName is a DNS name rather than an
+	// address inside its AllowedIPs.
+	SSHHosts []string
+	// MFA requires a one-time code before Start: "totp" checks it against
+	// the secret stored in the OS keyring, "command" runs MFACommand with
+	// the code on stdin and accepts it when it exits 0. "" means no check.
+	MFA        string
+	MFACommand string
+	// BindInterface pins the tunnel to one underlying interface (e.g.
+	// "enp3s0") on machines with several uplinks: Start routes the
+	// endpoint through it and Stop removes the route. Linux only.
+	BindInterface string
+	// PreflightChecks picks which checks "preflight" runs for the profile,
+	// by name ("config", "endpoint", "clock", "dns", "routes",
+	// "handshake"); empty runs all of them. PreflightDNS are internal
+	// names that must resolve and PreflightRoutes CIDR prefixes that must
+	// go through the tunnel.
+	PreflightChecks []string
+	PreflightDNS    []string
+	PreflightRoutes []string
+}
+
+// PreflightCheckNames are the checks a profile's preflight_checks can pick,
+// in the order they run.
+var PreflightCheckNames = []string{"config", "endpoint", "clock", "dns", "routes", "handshake"}
+
+type Settings struct {
+	// AutoConnect names the profile to start automatically on launch when
+	// no tunnel is up ("" disables it).
+	AutoConnect string
+	// ConfigMaxAgeDays is the age after which an installed config is
+	// flagged as due for an update (0 disables the warning).
+	ConfigMaxAgeDays int
+	// ConfigBackups is how many backups of each installed file are kept in
+	// the backups directory next to it, the oldest pruned first (0 keeps
+	// them all).
+	ConfigBackups int
+	// StripHookScripts removes PreUp/PostUp/PreDown/PostDown from every
+	// installed config, for machines where configs must not run commands.
+	StripHookScripts bool
+	// DisconnectCleanup decides what happens to DNS settings and routes a
+	// tunnel left behind after Stop: "ask" (default), "auto" or "off".
+	DisconnectCleanup string
+	// ConfirmDisconnect asks y/N before Stop, or a Start switching
+	// environments, takes a tunnel down (default on).
+	ConfirmDisconnect bool
+	// DisconnectOnSleep stops the tunnel before the system suspends and
+	// starts it again on resume (Linux, with logind).
+	DisconnectOnSleep bool
+	// WireGuardDir overrides where configs are installed, which is otherwise
+	// the directory the local wg-quick reads them from.
+	WireGuardDir string
+	// Glyphs picks how the dashboard is drawn: "auto" (default) detects
+	// terminals that can't draw Unicode, "unicode" or "ascii" force one.
+	Glyphs string
+	// SyncSchedule checks the profiles' remote sources at a set time while
+	// the TUI or the agent runs (nil: only on request). SyncPolicy decides
+	// what happens to what it finds: "prompt" (default) asks, "auto"
+	// installs it and reconnects a tunnel whose config changed.
+	SyncSchedule *schedule.Schedule
+	SyncPolicy   string
+	// BandwidthWarnMiB is the transfer rate (MiB/s) which, held for a
+	// minute, makes the dashboard ask whether it should go through the VPN
+	// at all (0 disables the warning).
+	BandwidthWarnMiB int
+	// StatusRefreshSeconds is how often the dashboard checks the status on
+	// its own (0 leaves it to Refresh Status and operations).
+	StatusRefreshSeconds int
+	// IgnorePatterns hides clutter such as backups and editor swap files
+	// from the file browsers (gitignore-style globs; "!" shows again).
+	IgnorePatterns []string
+	// OfficeSubnets are the local networks of the offices (CIDR prefixes);
+	// the network overview says when the machine is on one of them.
+	OfficeSubnets []string
+	// Locations are the network location rules in declaration order.
+	Locations []*Location
+	Profiles  map[string]*Profile
+	Backup    Backup
+	Agent     Agent
+}
+
+// Location is a network location rule ([locations.<name>]). It matches when
+// every criterion it sets holds for the current network.
+type Location struct {
+	Name      string
+	Interface string // interface of the default route, e.g. "eth0"
+	SSID      string // Wi-Fi network name
+	Subnet    string // CIDR prefix the local address is in
+	ProbeHost string // host[:port] that only answers on this network
+	// VPN is what to do here: "skip" (no VPN needed, suppress auto-connect)
+	// or "connect" (connect Profile, or auto_connect, automatically)
+	VPN     string
+	Profile string
+	line    int // where the section starts, for declaration order
+}
+
+// Backup configures the encrypted config backups ([backup] section).
+type Backup struct {
+	// Dir is where archives are written; "" disables backups.
+	Dir string
+	// PassphraseEnv names the environment variable holding the archive
+	// passphrase. Automatic backups only run while it is set.
+	PassphraseEnv string
+}
+
+// Agent configures the agent's /v1/configs endpoint, which tells a fleet
+// dashboard which config revisions are installed ([agent] section). Without
+// a token or a client CA the endpoint isn't served.
+type Agent struct {
+	// ConfigTokenEnv names the environment variable holding the bearer
+	// token the endpoint requires.
+	ConfigTokenEnv string
+	// ClientCA is a PEM bundle of CAs whose client certificates are let in
+	// instead (mTLS). It needs TLSCert and TLSKey, which switch the agent
+	// to HTTPS.
+	ClientCA string
+	TLSCert  string
+	TLSKey   string
+}
+
+// ConfigToken returns the /v1/configs token from the environment, or "".
+func (a Agent) ConfigToken() string {
+	if a.ConfigTokenEnv == "" {
+		return ""
+	}
+	return os.Getenv(a.ConfigTokenEnv)
+}
+
+// Passphrase returns the backup passphrase from the environment, or "".
+func (b Backup) Passphrase() string {
+	if b.PassphraseEnv == "" {
+		return ""
+	}
+	return os.Getenv(b.PassphraseEnv)
+}
+
+// Default returns the settings used when no settings file exists.
+func Default() *Settings {
+	return &Settings{
+		ConfigMaxAgeDays:     90,
+		ConfigBackups:        10,
+		BandwidthWarnMiB:     40,
+		StatusRefreshSeconds: 5,
+		SyncPolicy:           "prompt",
+		DisconnectCleanup:    "ask",
+		ConfirmDisconnect:    true,
+		Glyphs:               "auto",
+		IgnorePatterns:       []string{"*~", "*.swp", "*.swo", "*.bak", "*.orig", ".#*"},
+		Profiles:             map[string]*Profile{},
+	}
+}
+
+// Path returns the location of the user's settings file.
+func Path() (string, error) {
+	return paths.File(paths.Config, SettingsFile)
+}
+
+// Load reads the user's settings file. A missing file is not an error and
+// yields the defaults.
+func Load() (*Settings, error) {
+	path, err := Path()
+	if err != nil {
+		return Default(), nil
+	}
+	return LoadFile(path)
+}
+
+func LoadFile(path string) (*Settings, error) {
+	s := Default()
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, fmt.Errorf("failed to open settings file %s: %v", path, err)
+	}
+	defer file.Close()
+
+	doc, err := parse(file)
+	if err != nil {
+		return s, fmt.Errorf("invalid settings file %s: %v", path, err)
+	}
+
+	top := doc[""]
+	if v, ok := top["auto_connect"]; ok {
+		s.AutoConnect = strings.TrimSpace(v.String())
+	}
+	if v, ok := top["strip_hook_scripts"]; ok {
+		strip, err := v.Bool()
+		if err != nil {
+			return s, fmt.Errorf("invalid settings file %s: line %d: strip_hook_scripts must be true or false", path, v.line)
+		}
+		s.StripHookScripts = strip
+	}
+	if v, ok := top["disconnect_cleanup"]; ok {
+		switch mode := strings.TrimSpace(v.String()); mode {
+		case "ask", "auto", "off":
+			s.DisconnectCleanup = mode
+		default:
+			return s, fmt.Errorf("invalid settings file %s: line %d: disconnect_cleanup must be \"ask\", \"auto\" or \"off\"", path, v.line)
+		}
+	}
+	if v, ok := top["confirm_disconnect"]; ok {
+		confirm, err := v.Bool()
+		if err != nil {
+			return s, fmt.Errorf("invalid settings file %s: line %d: confirm_disconnect must be true or false", path, v.line)
+		}
+		s.ConfirmDisconnect = confirm
+	}
+	if v, ok := top["disconnect_on_sleep"]; ok {
+		disconnect, err := v.Bool()
+		if err != nil {
+			return s, fmt.Errorf("invalid settings file %s: line %d: disconnect_on_sleep must be true or false", path, v.line)
+		}
+		s.DisconnectOnSleep = disconnect
+	}
+	if v, ok := top["wireguard_dir"]; ok {
+		dir := expandHome(strings.TrimSpace(v.String()))
+		if !strings.HasPrefix(dir, "/") {
+			return s, fmt.Errorf("invalid settings file %s: line %d: wireguard_dir must be an absolute path", path, v.line)
+		}
+		s.WireGuardDir = dir
+	}
+	if v, ok := top["glyphs"]; ok {
+		switch mode := strings.TrimSpace(v.String()); mode {
+		case "auto", "unicode", "ascii":
+			s.Glyphs = mode
+		default:
+			return s, fmt.Errorf("invalid settings file %s: line %d: glyphs must be \"auto\", \"unicode\" or \"ascii\"", path, v.line)
+		}
+	}
+	if v, ok := top["ignore_patterns"]; ok {
+		for _, pattern := range v.List() {
+			if !validIgnorePattern(pattern) {
+				return s, fmt.Errorf("invalid settings file %s: line %d: ignore_patterns: %q is not a glob pattern", path, v.line, pattern)
+			}
+		}
+		s.IgnorePatterns = v.List()
+	}
+	if v, ok := top["office_subnets"]; ok {
+		for _, subnet := range v.List() {
+			if _, err := netip.ParsePrefix(subnet); err != nil {
+				return s, fmt.Errorf("invalid settings file %s: line %d: office_subnets: %q is not a CIDR prefix", path, v.line, subnet)
+			}
+		}
+		s.OfficeSubnets = v.List()
+	}
+	if v, ok := top["config_max_age_days"]; ok {
+		days, err := v.Int()
+		if err != nil || days < 0 {
+			return s, fmt.Errorf("invalid settings file %s: line %d: config_max_age_days must be a non-negative number", path, v.line)
+		}
+		s.ConfigMaxAgeDays = days
+	}
+	if v, ok := top["config_backups"]; ok {
+		kept, err := v.Int()
+		if err != nil || kept < 0 {
+			return s, fmt.Errorf("invalid settings file %s: line %d: config_backups must be a non-negative number", path, v.line)
+		}
+		s.ConfigBackups = kept
+	}
+	if v, ok := top["sync_schedule"]; ok {
+		sched, err := schedule.Parse(v.String())
+		if err != nil {
+			return s, fmt.Errorf("invalid settings file %s: line %d: sync_schedule: %v", path, v.line, err)
+		}
+		s.SyncSchedule = sched
+	}
+	if v, ok := top["sync_policy"]; ok {
+		switch policy := strings.TrimSpace(v.String()); policy {
+		case "prompt", "auto":
+			s.SyncPolicy = policy
+		default:
+			return s, fmt.Errorf("invalid settings file %s: line %d: sync_policy must be \"prompt\" or \"auto\"", path, v.line)
+		}
+	}
+	if v, ok := top["bandwidth_warn_mib"]; ok {
+		rate, err := v.Int()
+		if err != nil || rate < 0 {
+			return s, fmt.Errorf("invalid settings file %s: line %d: bandwidth_warn_mib must be a non-negative number", path, v.line)
+		}
+		s.BandwidthWarnMiB = rate
+	}
+	if v, ok := top["status_refresh_seconds"]; ok {
+		seconds, err := v.Int()
+		if err != nil || seconds < 0 {
+			return s, fmt.Errorf("invalid settings file %s: line %d: status_refresh_seconds must be a non-negative number", path, v.line)
+		}
+		s.StatusRefreshSeconds = seconds
+	}
+
+	if values, ok := doc["backup"]; ok {
+		if v, ok := values["dir"]; ok {
+			s.Backup.Dir = expandHome(strings.TrimSpace(v.String()))
+		}
+		if v, ok := values["passphrase_env"]; ok {
+			s.Backup.PassphraseEnv = strings.TrimSpace(v.String())
+		}
+	}
+
+	if values, ok := doc["agent"]; ok {
+		if v, ok := values["config_token_env"]; ok {
+			s.Agent.ConfigTokenEnv = strings.TrimSpace(v.String())
+		}
+		if v, ok := values["client_ca"]; ok {
+			s.Agent.ClientCA = expandHome(strings.TrimSpace(v.String()))
+		}
+		if v, ok := values["tls_cert"]; ok {
+			s.Agent.TLSCert = expandHome(strings.TrimSpace(v.String()))
+		}
+		if v, ok := values["tls_key"]; ok {
+			s.Agent.TLSKey = expandHome(strings.TrimSpace(v.String()))
+		}
+		if (s.Agent.TLSCert == "") != (s.Agent.TLSKey == "") {
+			return s, fmt.Errorf("invalid settings file %s: [agent] tls_cert and tls_key go together", path)
+		}
+		if v, ok := values["client_ca"]; ok && s.Agent.TLSCert == "" {
+			return s, fmt.Errorf("invalid settings file %s: line %d: client_ca needs tls_cert and tls_key", path, v.line)
+		}
+	}
+
+	for section, values := range doc {
+		if name, ok := strings.CutPrefix(section, "locations."); ok {
+			loc, err := parseLocation(name, values)
+			if err != nil {
+				return s, fmt.Errorf("invalid settings file %s: %v", path, err)
+			}
+			s.Locations = append(s.Locations, loc)
+			continue
+		}
+		if !strings.HasPrefix(section, "profiles.") {
+			continue
+		}
+		name := strings.TrimPrefix(section, "profiles.")
+		profile := s.profile(name)
+		if v, ok := values["endpoint"]; ok {
+			endpoint := strings.TrimSpace(v.String())
+			if _, port, err := net.SplitHostPort(endpoint); err != nil || port == "" {
+				return s, fmt.Errorf("invalid settings file %s: line %d: endpoint must be host:port", path, v.line)
+			}
+			profile.Endpoint = endpoint
+		}
+		if v, ok := values["endpoint_host"]; ok {
+			profile.EndpointHost = strings.TrimSpace(v.String())
+		}
+		if v, ok := values["remote_template_url"]; ok {
+			profile.RemoteTemplateURL = strings.TrimSpace(v.String())
+		}
+		if v, ok := values["remote_config_url"]; ok {
+			profile.RemoteConfigURL = strings.TrimSpace(v.String())
+		}
+		if v, ok := values["remote_auth_env"]; ok {
+			profile.RemoteAuthEnv = strings.TrimSpace(v.String())
+		}
+		if v, ok := values["hostnames"]; ok {
+			profile.Hostnames = v.List()
+		}
+		if v, ok := values["ssh_hosts"]; ok {
+			profile.SSHHosts = v.List()
+		}
+		if v, ok := values["confirm"]; ok {
+			confirm, err := v.Bool()
+			if err != nil {
+				return s, fmt.Errorf("invalid settings file %s: line %d: confirm must be true or false", path, v.line)
+			}
+			profile.Confirm = confirm
+		}
+		for _, key := range []string{"mfa_secret", "totp_secret", "otp_secret"} {
+			if v, ok := values[key]; ok {
+				return s, fmt.Errorf("invalid settings file %s: line %d: %s: the TOTP secret belongs in the OS keyring, not the settings file", path, v.line, key)
+			}
+		}
+		if v, ok := values["bind_interface"]; ok {
+			device := strings.TrimSpace(v.String())
+			if device == "" || len(device) > 15 || strings.ContainsAny(device, " /") {
+				return s, fmt.Errorf("invalid settings file %s: line %d: bind_interface must be a network interface name", path, v.line)
+			}
+			profile.BindInterface = device
+		}
+		if v, ok := values["preflight_checks"]; ok {
+			for _, check := range v.List() {
+				if !slices.Contains(PreflightCheckNames, check) {
+					return s, fmt.Errorf("invalid settings file %s: line %d: preflight_checks: unknown check %q (known: %s)", path, v.line, check, strings.Join(PreflightCheckNames, ", "))
+				}
+			}
+			profile.PreflightChecks = v.List()
+		}
+		if v, ok := values["preflight_dns"]; ok {
+			profile.PreflightDNS = v.List()
+		}
+		if v, ok := values["preflight_routes"]; ok {
+			for _, route := range v.List() {
+				if _, err := netip.ParsePrefix(route); err != nil {
+					return s, fmt.Errorf("invalid settings file %s: line %d: preflight_routes: %q is not a CIDR prefix", path, v.line, route)
+				}
+			}
+			profile.PreflightRoutes = v.List()
+		}
+		if v, ok := values["mfa_command"]; ok {
+			profile.MFACommand = strings.TrimSpace(v.String())
+		}
+		if v, ok := values["mfa"]; ok {
+			switch mode := strings.TrimSpace(v.String()); mode {
+			case "totp", "":
+				profile.MFA = mode
+			case "command":
+				if profile.MFACommand == "" {
+					return s, fmt.Errorf("invalid settings file %s: line %d: mfa = \"command\" needs mfa_command", path, v.line)
+				}
+				profile.MFA = mode
+			default:
+				return s, fmt.Errorf("invalid settings file %s: line %d: mfa must be \"totp\" or \"command\"", path, v.line)
+			}
+		}
+	}
+
+	sort.Slice(s.Locations, func(i, j int) bool { return s.Locations[i].line < s.Locations[j].line })
+
+	return s, nil
+}
+
+func parseLocation(name string, values map[string]value) (*Location, error) {
+	loc := &Location{Name: name, line: -1}
+	for key, v := range values {
+		if loc.line < 0 || v.line < loc.line {
+			loc.line = v.line
+		}
+		text := strings.TrimSpace(v.String())
+		switch key {
+		case "interface":
+			loc.Interface = text
+		case "ssid":
+			loc.SSID = text
+		case "subnet":
+			if _, err := netip.ParsePrefix(text); err != nil {
+				return nil, fmt.Errorf("line %d: subnet %q is not a CIDR prefix", v.line, text)
+			}
+			loc.Subnet = text
+		case "probe_host":
+			loc.ProbeHost = text
+		case "vpn":
+			if text != "skip" && text != "connect" {
+				return nil, fmt.Errorf("line %d: vpn must be \"skip\" or \"connect\"", v.line)
+			}
+			loc.VPN = text
+		case "profile":
+			loc.Profile = text
+		}
+	}
+	if loc.Interface == "" && loc.SSID == "" && loc.Subnet == "" && loc.ProbeHost == "" {
+		return nil, fmt.Errorf("[locations.%s] needs interface, ssid, subnet or probe_host", name)
+	}
+	return loc, nil
+}
+
+// GatewayHostnames returns every hostname declared for the profile: the
+// canonical endpoint_host first, then the extra hostnames.
+func (p *Profile) GatewayHostnames() []string {
+	if p == nil {
+		return nil
+	}
+	var hosts []string
+	if p.EndpointHost != "" {
+		hosts = append(hosts, p.EndpointHost)
+	}
+	return append(hosts, p.Hostnames...)
+}
+
+// expandHome turns a leading "~/" into the user's home directory.
+func expandHome(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return home + path[1:]
+}
+
+// Profile returns the metadata for the named profile, or nil if the settings
+// file doesn't mention it.
+func (s *Settings) Profile(name string) *Profile {
+	if s == nil {
+		return nil
+	}
+	return s.Profiles[name]
+}
+
+func (s *Settings) profile(name string) *Profile {
+	if p, ok := s.Profiles[name]; ok {
+		return p
+	}
+	p := &Profile{Name: name}
+	s.Profiles[name] = p
+	return p
+}
+
+// validIgnorePattern reports whether pattern is one the file browsers can
+// match: a glob for path.Match, optionally with a leading "!" or "/" and a
+// trailing "/".
+func validIgnorePattern(pattern string) bool {
+	glob := strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "/")
+	glob = strings.TrimSuffix(glob, "/")
+	if glob == "" {
+		return false
+	}
+	_, err := path.Match(glob, "")
+	return err == nil
+}
@@ -0,0 +1,56 @@
+// Package sleep tells the app that the system is about to suspend, and that
+// it has resumed. A tunnel left up across a suspend leaves half-open TCP
+// sessions that hang on resume, and a peer that some office firewalls flag,
+// so the TUI and the agent can take it down first and bring it back after.
+//
+// On Linux the events are logind's PrepareForSleep signal, and a delay
+// inhibitor lock holds the suspend back (at most logind's InhibitDelayMaxSec,
+// 5 seconds by default) until the tunnel is down. Elsewhere there are no
+// events. Both go through the Monitor interface, so the D-Bus side can be
+// replaced.
+package sleep
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrUnsupported is returned where there are no sleep events to watch.
+var ErrUnsupported = errors.New("sleep events are not available on this system")
+
+// Monitor is the system's power management.
+type Monitor interface {
+	// Watch delivers true when sleep is imminent and false on resume, until
+	// ctx ends, when the channel is closed.
+	Watch(ctx context.Context) (<-chan bool, error)
+	// Inhibit takes a delay lock: a suspend waits until release is called
+	// (or logind's delay runs out). why is shown by systemd-inhibit --list.
+	Inhibit(why string) (release func(), err error)
+}
+
+type unsupported struct{}
+
+func (unsupported) Watch(context.Context) (<-chan bool, error) { return nil, ErrUnsupported }
+func (unsupported) Inhibit(string) (func(), error)             { return nil, ErrUnsupported }
+
+// ParseSignal reads a PrepareForSleep signal from a line of
+// "gdbus monitor" output:
+//
+//	/org/freedesktop/login1: org.freedesktop.login1.Manager.PrepareForSleep (true,)
+//
+// sleeping is true before a suspend and false after the resume; ok is false
+// for any other line.
+func ParseSignal(line string) (sleeping, ok bool) {
+	_, args, found := strings.Cut(line, ".PrepareForSleep ")
+	if !found {
+		return false, false
+	}
+	switch strings.Trim(strings.TrimSpace(args), "(),") {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
@@ -0,0 +1,68 @@
+package sleep
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// System returns logind's monitor, or one reporting ErrUnsupported without
+// gdbus or a system bus (containers, WSL without systemd).
+func System() Monitor {
+	if _, err := exec.LookPath("gdbus"); err != nil {
+		return unsupported{}
+	}
+	if _, err := os.Stat("/run/dbus/system_bus_socket"); err != nil && os.Getenv("DBUS_SYSTEM_BUS_ADDRESS") == "" {
+		return unsupported{}
+	}
+	return logind{}
+}
+
+// logind talks to org.freedesktop.login1 through gdbus and systemd-inhibit,
+// which every systemd desktop has. Both are killed with the app, so neither
+// a monitor nor a lock outlives it.
+type logind struct{}
+
+func (logind) Watch(ctx context.Context) (<-chan bool, error) {
+	cmd := exec.CommandContext(ctx, "gdbus", "monitor", "--system",
+		"--dest", "org.freedesktop.login1", "--object-path", "/org/freedesktop/login1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	events := make(chan bool)
+	go func() {
+		defer close(events)
+		defer cmd.Wait()
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			if sleeping, ok := ParseSignal(scanner.Text()); ok {
+				select {
+				case events <- sleeping:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (logind) Inhibit(why string) (func(), error) {
+	cmd := exec.Command("systemd-inhibit", "--what=sleep", "--mode=delay",
+		"--who=tui-wireguard-vpn", "--why="+why, "sleep", "infinity")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package sleep
+
+// System returns a monitor reporting ErrUnsupported: only logind has the
+// events.
+func System() Monitor {
+	return unsupported{}
+}
@@ -0,0 +1,23 @@
+package sleep
+
+import "testing"
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		line         string
+		sleeping, ok bool
+	}{
+		{"/org/freedesktop/login1: org.freedesktop.login1.Manager.PrepareForSleep (true,)", true, true},
+		{"/org/freedesktop/login1: org.freedesktop.login1.Manager.PrepareForSleep (false,)", false, true},
+		{"/org/freedesktop/login1: org.freedesktop.login1.Manager.PrepareForSleep (true,)\n", true, true},
+		{"/org/freedesktop/login1: org.freedesktop.login1.Manager.PrepareForShutdown (true,)", false, false},
+		{"/org/freedesktop/login1: org.freedesktop.login1.Manager.PrepareForSleep (maybe,)", false, false},
+		{"Monitoring signals from all objects owned by org.freedesktop.login1", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		if sleeping, ok := ParseSignal(tt.line); sleeping != tt.sleeping || ok != tt.ok {
+			t.Errorf("ParseSignal(%q) = %v, %v; want %v, %v", tt.line, sleeping, ok, tt.sleeping, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+// Package sshhosts reads the Host entries of the user's ssh config and works
+// out which of them a tunnel's routes make reachable.
+//
+// Only what that needs is understood: Host aliases, their HostName, and
+// Include directives (globs, relative paths resolved against ~/.ssh).
+// Wildcard and negated Host patterns aren't hosts one can connect to by name
+// and are skipped.
+package sshhosts
+
+import (
+	"bufio"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Upper bound for nested Include directives
+const maxIncludeDepth = 16
+
+// Host is one connectable Host alias.
+type Host struct {
+	Alias    string
+	HostName string // the HostName option, or the alias when there is none
+}
+
+// Load reads ~/.ssh/config. A missing file yields no hosts.
+func Load() ([]Host, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return ParseFile(filepath.Join(home, ".ssh", "config"), filepath.Join(home, ".ssh"))
+}
+
+// ParseFile reads an ssh config; sshDir resolves relative Include paths.
+func ParseFile(file, sshDir string) ([]Host, error) {
+	p := &parser{sshDir: sshDir, seen: map[string]bool{}, hostNames: map[string]string{}}
+	if err := p.parse(file, 0); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	hosts := make([]Host, 0, len(p.aliases))
+	for _, alias := range p.aliases {
+		hostName := p.hostNames[alias]
+		if hostName == "" {
+			hostName = alias
+		}
+		hosts = append(hosts, Host{Alias: alias, HostName: hostName})
+	}
+	return hosts, nil
+}
+
+type parser struct {
+	sshDir    string
+	seen      map[string]bool // files already read, against Include loops
+	aliases   []string        // in order of first appearance
+	hostNames map[string]string
+	current   []string // aliases of the Host block being read
+}
+
+func (p *parser) parse(file string, depth int) error {
+	if depth > maxIncludeDepth || p.seen[file] {
+		return nil
+	}
+	p.seen[file] = true
+
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, args := splitOption(scanner.Text())
+		switch strings.ToLower(key) {
+		case "host":
+			p.current = p.current[:0]
+			for _, alias := range args {
+				if strings.ContainsAny(alias, "*?!") {
+					continue
+				}
+				if _, known := p.hostNames[alias]; !known {
+					p.aliases = append(p.aliases, alias)
+					p.hostNames[alias] = ""
+				}
+				p.current = append(p.current, alias)
+			}
+		case "match":
+			// Match blocks can't be evaluated here; their options are ignored
+			p.current = p.current[:0]
+		case "hostname":
+			if len(args) == 0 {
+				continue
+			}
+			// ssh uses the first value given for a host
+			for _, alias := range p.current {
+				if p.hostNames[alias] == "" {
+					p.hostNames[alias] = args[0]
+				}
+			}
+		case "include":
+			// Options after an Include in a Host block still belong to it
+			current := append([]string(nil), p.current...)
+			for _, pattern := range args {
+				if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "~") {
+					pattern = filepath.Join(p.sshDir, pattern)
+				}
+				if rest, ok := strings.CutPrefix(pattern, "~/"); ok {
+					pattern = filepath.Join(filepath.Dir(p.sshDir), rest)
+				}
+				matches, _ := filepath.Glob(pattern)
+				for _, match := range matches {
+					p.current = current
+					_ = p.parse(match, depth+1) // An unreadable include only loses its hosts
+				}
+			}
+			p.current = current
+		}
+	}
+	return scanner.Err()
+}
+
+// splitOption splits "Key value ..." or "Key=value" into the key and its
+// arguments, dropping comments and quotes.
+func splitOption(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+	key, rest, _ := strings.Cut(line, " ")
+	if k, v, ok := strings.Cut(key, "="); ok {
+		key, rest = k, v+" "+rest
+	}
+	rest = strings.TrimPrefix(strings.TrimSpace(rest), "=")
+	var args []string
+	for _, field := range strings.Fields(rest) {
+		args = append(args, strings.Trim(field, `"`))
+	}
+	return key, args
+}
+
+// Reachable returns the aliases of hosts a tunnel with routes (AllowedIPs
+// prefixes) reaches: those whose HostName is an address inside a route, and
+// those matching one of patterns (path.Match globs over the aliases, for
+// hosts known by DNS name). Default routes are ignored; a full tunnel would
+// otherwise claim every host.
+func Reachable(hosts []Host, routes, patterns []string) []string {
+	var prefixes []netip.Prefix
+	for _, route := range routes {
+		if prefix, err := netip.ParsePrefix(strings.TrimSpace(route)); err == nil && prefix.Bits() > 0 {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	var reachable []string
+	for _, host := range hosts {
+		if matchesAny(host.Alias, patterns) || routed(host.HostName, prefixes) {
+			reachable = append(reachable, host.Alias)
+		}
+	}
+	return reachable
+}
+
+func matchesAny(alias string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, alias); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func routed(hostName string, prefixes []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(hostName)
+	if err != nil {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package sshhosts
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// The fixture home has a config with wildcard, negated and Match blocks,
+// a glob Include of config.d (one file including the main config back), and
+// a ~/ Include of a file including config.d again.
+func parseFixture(t *testing.T) []Host {
+	t.Helper()
+	sshDir := filepath.Join("testdata", "home", ".ssh")
+	hosts, err := ParseFile(filepath.Join(sshDir, "config"), sshDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hosts
+}
+
+func TestParseFile(t *testing.T) {
+	want := []Host{
+		{"bastion", "10.80.0.10"}, // the later block doesn't override it
+		{"db-prod", "10.80.3.21"}, // not the Match block's
+		{"db-replica", "10.80.3.21"},
+		{"lab-gpu", "172.20.1.40"},
+		{"office-nas", "office-nas"},
+		{"jenkins", "jenkins.julo.internal"},
+		{"staging-web", "fd00:80::15"},
+		{"github.com", "github.com"},
+	}
+	if hosts := parseFixture(t); !slices.Equal(hosts, want) {
+		t.Errorf("ParseFile() =\n%v\nwant\n%v", hosts, want)
+	}
+}
+
+func TestParseFileMissing(t *testing.T) {
+	hosts, err := ParseFile(filepath.Join(t.TempDir(), "config"), t.TempDir())
+	if err != nil || len(hosts) != 0 {
+		t.Errorf("ParseFile() of a missing config = %v, %v", hosts, err)
+	}
+}
+
+func TestParseFileUnreadableInclude(t *testing.T) {
+	sshDir := t.TempDir()
+	config := filepath.Join(sshDir, "config")
+	content := "Include gone.conf missing/*\nHost nas\n  HostName 192.168.1.5\n"
+	if err := os.WriteFile(config, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := ParseFile(config, sshDir)
+	if err != nil || !slices.Equal(hosts, []Host{{"nas", "192.168.1.5"}}) {
+		t.Errorf("ParseFile() = %v, %v", hosts, err)
+	}
+}
+
+func TestSplitOption(t *testing.T) {
+	tests := []struct {
+		line string
+		key  string
+		args []string
+	}{
+		{"Host bastion", "Host", []string{"bastion"}},
+		{"  HostName 10.80.0.10  ", "HostName", []string{"10.80.0.10"}},
+		{"HostName=10.80.0.10", "HostName", []string{"10.80.0.10"}},
+		{"HostName = 10.80.0.10", "HostName", []string{"10.80.0.10"}},
+		{`IdentityFile "~/.ssh/id_github"`, "IdentityFile", []string{"~/.ssh/id_github"}},
+		{"Host db-prod db-replica", "Host", []string{"db-prod", "db-replica"}},
+		{"# Host commented", "", nil},
+		{"", "", nil},
+	}
+	for _, tt := range tests {
+		key, args := splitOption(tt.line)
+		if key != tt.key || !slices.Equal(args, tt.args) {
+			t.Errorf("splitOption(%q) = %q, %q; want %q, %q", tt.line, key, args, tt.key, tt.args)
+		}
+	}
+}
+
+func TestReachable(t *testing.T) {
+	hosts := parseFixture(t)
+	tests := []struct {
+		name     string
+		routes   []string
+		patterns []string
+		want     []string
+	}{
+		{"prod routes", []string{"10.80.0.0/16", " fd00:80::/64"}, nil, []string{"bastion", "db-prod", "db-replica", "staging-web"}},
+		{"narrow route", []string{"10.80.3.21/32"}, nil, []string{"db-prod", "db-replica"}},
+		{"patterns", []string{"172.20.0.0/16"}, []string{"jenk*", "office-?as"}, []string{"lab-gpu", "office-nas", "jenkins"}},
+		{"full tunnel", []string{"0.0.0.0/0", "::/0"}, nil, nil},
+		{"bad route", []string{"10.80.0.0"}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Reachable(hosts, tt.routes, tt.patterns); !slices.Equal(got, tt.want) {
+				t.Errorf("Reachable() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"tui-wireguard-vpn/internal/paths"
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+const stateFile = "state.json"
+
+// State is the small amount of data the app remembers between runs.
+type State struct {
+	// LastUpdate is the most recent config update that failed, kept so it can
+	// be retried without browsing for the file again. Cleared on success.
+	LastUpdate *UpdateAttempt `json:"last_update,omitempty"`
+	// Configs records where each environment's installed config came from
+	// and when, keyed by "prod" or "nonprod". Configs installed before this
+	// was tracked have no entry.
+	Configs map[string]*ConfigProvenance `json:"configs,omitempty"`
+	// SetupSkipped is set when the user skipped the setup wizard to use the
+	// dashboard read-only, so the wizard isn't forced on every launch.
+	SetupSkipped bool `json:"setup_skipped,omitempty"`
+	// Timings holds the most recent successful durations of each operation
+	// in milliseconds, oldest first, for spotting unusually slow runs.
+	Timings map[string][]int64 `json:"timings,omitempty"`
+	// Provisioned is the provisioning file version last applied, so a newer
+	// one is recognized and its changes summarized.
+	Provisioned *Provisioning `json:"provisioned,omitempty"`
+	// DNSBefore is the DNS configuration recorded before the last Start
+	// from disconnected, checked against after Stop.
+	DNSBefore *wgvpn.DNSSnapshot `json:"dns_before,omitempty"`
+	// ScheduledSync is the schedule slot the scheduled server sync last ran
+	// for, shared by the TUI and the agent so a slot runs once.
+	ScheduledSync time.Time `json:"scheduled_sync,omitempty"`
+	// PendingConfigs are config updates staged while their environment was
+	// connected, keyed by environment, to be installed once it disconnects.
+	PendingConfigs map[string]*PendingConfig `json:"pending_configs,omitempty"`
+	// LastSession is the tunnel most recently brought up, so a launch after
+	// a reboot or crash can offer to bring it back.
+	LastSession *Session `json:"last_session,omitempty"`
+}
+
+// maxTimings is how many durations are kept per operation.
+const maxTimings = 20
+
+type ConfigProvenance struct {
+	Source    string    `json:"source"` // e.g. "file ~/Downloads/prod.conf", "generated", "server", "backup"
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PendingConfig struct {
+	Source   string    `json:"source"` // as in ConfigProvenance, recorded once it is installed
+	Path     string    `json:"path"`   // the staged, already merged config
+	SHA256   string    `json:"sha256"`
+	StagedAt time.Time `json:"staged_at"`
+	// Error is why installing it last failed; it is then only retried on
+	// demand
+	Error string `json:"error,omitempty"`
+}
+
+type Provisioning struct {
+	Version int `json:"version"`
+	// Files holds the referenced files by role ("prod config", "settings")
+	Files     map[string]ProvisionedFile `json:"files,omitempty"`
+	AppliedAt time.Time                  `json:"applied_at"`
+}
+
+type ProvisionedFile struct {
+	Path string `json:"path"`
+	// SHA256 is empty when the file wasn't readable without privileges
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Session is a tunnel the app brought up. Stopped is set once it is taken
+// down on purpose; a session that ended without it (a reboot, a crash, the
+// tunnel dropped outside the app) is the one worth reconnecting.
+type Session struct {
+	Environment string    `json:"environment"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Stopped     bool      `json:"stopped,omitempty"`
+}
+
+type UpdateAttempt struct {
+	SourcePath  string    `json:"source_path"`
+	SourceHash  string    `json:"source_hash"`
+	Environment string    `json:"environment,omitempty"`
+	Error       string    `json:"error"`
+	AttemptedAt time.Time `json:"attempted_at"`
+}
+
+// Path returns the location of the state file.
+func Path() (string, error) {
+	return paths.File(paths.State, stateFile)
+}
+
+// Load reads the state file. A missing file yields an empty state.
+func Load() (*State, error) {
+	path, err := Path()
+	if err != nil {
+		return &State{}, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &State{}, nil
+		}
+		return &State{}, fmt.Errorf("failed to read state file %s: %v", path, err)
+	}
+
+	var s State
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &State{}, fmt.Errorf("invalid state file %s: %v", path, err)
+	}
+	return &s, nil
+}
+
+// Save writes the state file, replacing it atomically.
+func (s *State) Save() error {
+	path, err := paths.EnsureFile(paths.State, stateFile)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), stateFile+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	return nil
+}
+
+// Update loads the state, applies fn and saves the result.
+func Update(fn func(s *State)) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	fn(s)
+	return s.Save()
+}
+
+// RecordConfig notes that env's installed config was just written from
+// source.
+func RecordConfig(env, source string) error {
+	return Update(func(s *State) {
+		if s.Configs == nil {
+			s.Configs = map[string]*ConfigProvenance{}
+		}
+		s.Configs[env] = &ConfigProvenance{Source: source, UpdatedAt: time.Now()}
+	})
+}
+
+// RecordConnected notes that env's tunnel just came up.
+func RecordConnected(env string) error {
+	return Update(func(s *State) {
+		s.LastSession = &Session{Environment: env, ConnectedAt: time.Now()}
+	})
+}
+
+// RecordStopped notes that env's tunnel was taken down on purpose; "" stands
+// for whichever tunnel was up. A session of another environment is left as
+// it is.
+func RecordStopped(env string) error {
+	return Update(func(s *State) {
+		if s.LastSession != nil && (env == "" || s.LastSession.Environment == env) {
+			s.LastSession.Stopped = true
+		}
+	})
+}
+
+// RecordInterrupted undoes RecordStopped for env's session, for a stop that
+// is meant to be undone, like the agent's before system sleep.
+func RecordInterrupted(env string) error {
+	return Update(func(s *State) {
+		if s.LastSession != nil && s.LastSession.Environment == env {
+			s.LastSession.Stopped = false
+		}
+	})
+}
+
+// RecordTiming adds a successful run of operation and returns the durations
+// recorded before it.
+func RecordTiming(operation string, d time.Duration) ([]time.Duration, error) {
+	var history []time.Duration
+	err := Update(func(s *State) {
+		if s.Timings == nil {
+			s.Timings = map[string][]int64{}
+		}
+		for _, ms := range s.Timings[operation] {
+			history = append(history, time.Duration(ms)*time.Millisecond)
+		}
+		timings := append(s.Timings[operation], d.Milliseconds())
+		if len(timings) > maxTimings {
+			timings = timings[len(timings)-maxTimings:]
+		}
+		s.Timings[operation] = timings
+	})
+	return history, err
+}
+
+// ClaimScheduledSync records that the scheduled sync runs for slot. It
+// reports false when slot (or a later one) was already claimed, e.g. by the
+// agent while the TUI is open too.
+func ClaimScheduledSync(slot time.Time) (bool, error) {
+	claimed := false
+	err := Update(func(s *State) {
+		if slot.After(s.ScheduledSync) {
+			s.ScheduledSync = slot
+			claimed = true
+		}
+	})
+	return claimed && err == nil, err
+}
+
+// HashFile returns the hex SHA-256 of a file's contents.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
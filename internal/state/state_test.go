@@ -0,0 +1,46 @@
+package state
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// useStateDir gives the test a state file of its own.
+func useStateDir(t *testing.T) {
+	t.Helper()
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+}
+
+func TestRecordTiming(t *testing.T) {
+	useStateDir(t)
+
+	var want []time.Duration
+	for i := 1; i <= maxTimings+5; i++ {
+		d := time.Duration(i) * time.Second
+		history, err := RecordTiming("start_prod", d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// What came before, capped at the last maxTimings
+		if !slices.Equal(history, want) {
+			t.Fatalf("run %d: history %v, want %v", i, history, want)
+		}
+		want = append(want, d)
+		if len(want) > maxTimings {
+			want = want[1:]
+		}
+	}
+
+	history, err := RecordTiming("stop", time.Second)
+	if err != nil || len(history) != 0 {
+		t.Errorf("another operation's history = %v, %v", history, err)
+	}
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Timings["start_prod"]; len(got) != maxTimings || got[0] != 6000 {
+		t.Errorf("stored timings %v", got)
+	}
+}
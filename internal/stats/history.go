@@ -0,0 +1,145 @@
+// Package stats keeps the connection history: what the app saw of the
+// tunnels while it was running, recorded as it went, and the timelines built
+// from it. Nothing is known about the time the app wasn't running, and the
+// timelines say so rather than guess.
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/paths"
+)
+
+const (
+	historyFile = "history.log"
+	// Size after which Record drops the events older than keepDays
+	pruneSize = 1 << 20
+	keepDays  = 14
+)
+
+// HeartbeatInterval is how often a running app records what it sees even
+// when nothing changes; see staleAfter.
+const HeartbeatInterval = 5 * time.Minute
+
+// staleAfter is how long an event speaks for: it covers the time until the
+// next one, but a gap longer than this means the app wasn't watching (it
+// was closed, crashed or the machine slept), and the gap is unknown.
+const staleAfter = 2*HeartbeatInterval + time.Minute
+
+// The states an Event records besides an environment name.
+const (
+	StateDisconnected = "disconnected"
+	// StateExit marks the app closing: what follows is unknown until the
+	// next event
+	StateExit = "exit"
+)
+
+// Event is one line of the history: the state the app saw at Time, an
+// environment name ("prod") while its tunnel was up.
+type Event struct {
+	Time  time.Time `json:"time"`
+	State string    `json:"state"`
+}
+
+var mu sync.Mutex
+
+// Path returns the location of the history.
+func Path() (string, error) {
+	return paths.File(paths.State, historyFile)
+}
+
+// Record appends event to the history.
+func Record(event Event) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	path, err := paths.EnsureFile(paths.State, historyFile)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil && info.Size() >= pruneSize {
+		if err := prune(path, event.Time.AddDate(0, 0, -keepDays)); err != nil {
+			return fmt.Errorf("failed to prune history: %v", err)
+		}
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %v", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write history: %v", err)
+	}
+	return nil
+}
+
+// prune rewrites the history without the events before cutoff.
+func prune(path string, cutoff time.Time) error {
+	events, err := read(path, cutoff)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	for _, event := range events {
+		line, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		data = append(append(data, line...), '\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load returns the events recorded since since, oldest first. A missing
+// history yields no events.
+func Load(since time.Time) ([]Event, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return read(path, since)
+}
+
+func read(path string, since time.Time) ([]Event, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue // A torn line from a crash shouldn't hide the rest
+		}
+		if !event.Time.Before(since) {
+			events = append(events, event)
+		}
+	}
+	// Appends from concurrent writers may land out of order
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	return events, scanner.Err()
+}
@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRecordAndLoad(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	day := time.Date(2024, time.June, 13, 0, 0, 0, 0, time.UTC)
+	for _, event := range []Event{
+		{at(day, "09:00"), "prod"},
+		{at(day, "09:10"), StateExit},
+		{at(day, "09:05"), "prod"}, // a concurrent writer's, late
+	} {
+		if err := Record(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"time":"2024-06-13T09:2`) // torn by a crash
+	file.Close()
+
+	events, err := Load(at(day, "09:01"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{{at(day, "09:05"), "prod"}, {at(day, "09:10"), StateExit}}
+	if len(events) != len(want) {
+		t.Fatalf("Load() = %v, want %v", events, want)
+	}
+	for i := range want {
+		if !events[i].Time.Equal(want[i].Time) || events[i].State != want[i].State {
+			t.Errorf("event %d = %v, want %v", i, events[i], want[i])
+		}
+	}
+}
+
+// Past pruneSize, recording drops the events older than keepDays.
+func TestRecordRollsOver(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	now := time.Date(2024, time.June, 13, 9, 0, 0, 0, time.UTC)
+	if err := Record(Event{now.AddDate(0, 0, -30), "prod"}); err != nil {
+		t.Fatal(err)
+	}
+	path, _ := Path()
+	line, _ := json.Marshal(Event{now.AddDate(0, 0, -20), "nonprod"})
+	var filler []byte
+	for len(filler) < pruneSize {
+		filler = append(append(filler, line...), '\n')
+	}
+	recent, _ := json.Marshal(Event{now.AddDate(0, 0, -1), "prod"})
+	filler = append(append(filler, recent...), '\n')
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(filler)
+	file.Close()
+
+	if err := Record(Event{now, StateDisconnected}); err != nil {
+		t.Fatal(err)
+	}
+	events, err := Load(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].State != "prod" || events[1].State != StateDisconnected {
+		t.Errorf("after the rollover Load() = %v, want the last day only", events)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= pruneSize {
+		t.Errorf("history is %d bytes after the rollover", info.Size())
+	}
+}
@@ -0,0 +1,135 @@
+package stats
+
+import "time"
+
+// SlotDuration is the stretch of time one slot of a timeline stands for.
+const SlotDuration = 10 * time.Minute
+
+// StateUnknown fills the time the app wasn't watching.
+const StateUnknown = "unknown"
+
+// Timeline is one day cut into SlotDuration slots.
+type Timeline struct {
+	Start time.Time // midnight at the start of the day
+	// Slots hold the state that covered most of each slot: an environment
+	// name, StateDisconnected, StateUnknown, or "" for time still to come
+	Slots []string
+	// Totals is the time spent in each state so far, unknown included
+	Totals map[string]time.Duration
+}
+
+// span is the time one event speaks for.
+type span struct {
+	state      string
+	start, end time.Time
+}
+
+// LoadDay builds the timeline of the day day falls on, in its location,
+// from the recorded history.
+func LoadDay(day, now time.Time) (*Timeline, error) {
+	// A session running over midnight is known from its last event before
+	events, err := Load(startOfDay(day).Add(-staleAfter))
+	if err != nil {
+		return nil, err
+	}
+	return DayTimeline(events, day, now), nil
+}
+
+// DayTimeline builds the timeline of the day day falls on from events,
+// oldest first. Each event covers the time until the next one, for at most
+// staleAfter; StateExit covers nothing. Time after now is left empty.
+func DayTimeline(events []Event, day, now time.Time) *Timeline {
+	start := startOfDay(day)
+	end := start.AddDate(0, 0, 1) // not always 24 hours later
+	if now.Before(end) {
+		end = now
+	}
+
+	var spans []span
+	for i, event := range events {
+		if event.State == StateExit {
+			continue
+		}
+		until := event.Time.Add(staleAfter)
+		if i+1 < len(events) && events[i+1].Time.Before(until) {
+			until = events[i+1].Time
+		}
+		if now.Before(until) {
+			until = now
+		}
+		spans = append(spans, span{event.State, event.Time, until})
+	}
+
+	timeline := &Timeline{Start: start, Totals: map[string]time.Duration{}}
+	slots := int((start.AddDate(0, 0, 1).Sub(start) + SlotDuration - 1) / SlotDuration)
+	for i := 0; i < slots; i++ {
+		from := start.Add(time.Duration(i) * SlotDuration)
+		if !from.Before(end) {
+			timeline.Slots = append(timeline.Slots, "")
+			continue
+		}
+		to := from.Add(SlotDuration)
+		if end.Before(to) {
+			to = end
+		}
+		timeline.Slots = append(timeline.Slots, mostOf(spans, from, to))
+	}
+
+	known := time.Duration(0)
+	for _, s := range spans {
+		if d := overlap(s, start, end); d > 0 {
+			timeline.Totals[s.state] += d
+			known += d
+		}
+	}
+	if unknown := end.Sub(start) - known; unknown > 0 {
+		timeline.Totals[StateUnknown] = unknown
+	}
+	return timeline
+}
+
+// mostOf returns the state covering most of [from, to). A known state wins
+// a tie with the unknown rest, and the earlier of two known states wins
+// theirs.
+func mostOf(spans []span, from, to time.Time) string {
+	covered := map[string]time.Duration{}
+	var order []string
+	known := time.Duration(0)
+	for _, s := range spans {
+		d := overlap(s, from, to)
+		if d <= 0 {
+			continue
+		}
+		if _, seen := covered[s.state]; !seen {
+			order = append(order, s.state)
+		}
+		covered[s.state] += d
+		known += d
+	}
+	best, bestCovered := StateUnknown, time.Duration(0)
+	for _, state := range order {
+		if covered[state] > bestCovered {
+			best, bestCovered = state, covered[state]
+		}
+	}
+	if to.Sub(from)-known > bestCovered {
+		return StateUnknown
+	}
+	return best
+}
+
+// overlap is how much of [from, to) the span covers.
+func overlap(s span, from, to time.Time) time.Duration {
+	if s.start.After(from) {
+		from = s.start
+	}
+	if s.end.Before(to) {
+		to = s.end
+	}
+	return to.Sub(from)
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func at(day time.Time, clock string) time.Time {
+	t, err := time.ParseInLocation("15:04", clock, day.Location())
+	if err != nil {
+		panic(err)
+	}
+	return startOfDay(day).Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+}
+
+func TestDayTimeline(t *testing.T) {
+	day := time.Date(2024, time.June, 13, 0, 0, 0, 0, time.UTC)
+	events := []Event{
+		{at(day, "09:00"), "prod"},
+		{at(day, "09:05"), "prod"},
+		{at(day, "09:10"), StateDisconnected},
+		{at(day, "09:15"), StateExit},
+		// Opened again, then no heartbeat: a stale event covers staleAfter
+		{at(day, "10:00"), "nonprod"},
+		{at(day, "11:00"), "nonprod"},
+	}
+	timeline := DayTimeline(events, day, at(day, "12:00"))
+
+	wantTotals := map[string]time.Duration{
+		"prod":            10 * time.Minute,
+		StateDisconnected: 5 * time.Minute,
+		"nonprod":         2 * staleAfter,
+		StateUnknown:      12*time.Hour - 15*time.Minute - 2*staleAfter,
+	}
+	if len(timeline.Totals) != len(wantTotals) {
+		t.Errorf("Totals = %v, want %v", timeline.Totals, wantTotals)
+	}
+	for state, want := range wantTotals {
+		if got := timeline.Totals[state]; got != want {
+			t.Errorf("Totals[%s] = %s, want %s", state, got, want)
+		}
+	}
+
+	if len(timeline.Slots) != 144 {
+		t.Fatalf("%d slots, want 144", len(timeline.Slots))
+	}
+	wantSlots := map[int]string{
+		0:   StateUnknown,
+		54:  "prod",            // 09:00
+		55:  StateDisconnected, // 09:10, half known: the known state wins
+		56:  StateUnknown,
+		60:  "nonprod",    // 10:00
+		61:  StateUnknown, // 10:10, one minute of nonprod
+		66:  "nonprod",    // 11:00
+		71:  StateUnknown,
+		72:  "", // 12:00, still to come
+		143: "",
+	}
+	for slot, want := range wantSlots {
+		if got := timeline.Slots[slot]; got != want {
+			t.Errorf("slot %d = %q, want %q", slot, got, want)
+		}
+	}
+}
+
+// A session running over midnight counts from midnight, from the last event
+// of the day before.
+func TestDayTimelineOverMidnight(t *testing.T) {
+	day := time.Date(2024, time.June, 13, 0, 0, 0, 0, time.UTC)
+	events := []Event{
+		{at(day, "23:55").AddDate(0, 0, -1), "prod"},
+		{at(day, "00:03"), "prod"},
+	}
+	timeline := DayTimeline(events, day, at(day, "00:20"))
+	if got, want := timeline.Totals["prod"], 14*time.Minute; got != want {
+		t.Errorf("Totals[prod] = %s, want %s", got, want)
+	}
+	if got, want := timeline.Totals[StateUnknown], 6*time.Minute; got != want {
+		t.Errorf("Totals[unknown] = %s, want %s", got, want)
+	}
+	if got, want := timeline.Slots[:3], []string{"prod", StateUnknown, ""}; !slices.Equal(got, want) {
+		t.Errorf("Slots = %q, want %q", got, want)
+	}
+
+	// The day before ends at midnight
+	yesterday := DayTimeline(events, day.AddDate(0, 0, -1), at(day, "00:20"))
+	if got, want := yesterday.Totals["prod"], 5*time.Minute; got != want {
+		t.Errorf("yesterday's Totals[prod] = %s, want %s", got, want)
+	}
+	if last := yesterday.Slots[len(yesterday.Slots)-1]; last != "prod" {
+		t.Errorf("yesterday's last slot = %q, want prod: half of it is known", last)
+	}
+}
+
+// Days are cut at midnight in their location, not every 24 hours.
+func TestDayTimelineDaylightSaving(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	for _, tt := range []struct {
+		day   time.Time
+		slots int
+	}{
+		{time.Date(2024, time.March, 10, 12, 0, 0, 0, newYork), 23 * 6},
+		{time.Date(2024, time.November, 3, 12, 0, 0, 0, newYork), 25 * 6},
+	} {
+		timeline := DayTimeline(nil, tt.day, tt.day.AddDate(0, 0, 2))
+		if len(timeline.Slots) != tt.slots {
+			t.Errorf("%s has %d slots, want %d", tt.day.Format("2006-01-02"), len(timeline.Slots), tt.slots)
+		}
+		if got, want := timeline.Totals[StateUnknown], time.Duration(tt.slots)*SlotDuration; got != want {
+			t.Errorf("%s: Totals[unknown] = %s, want %s", tt.day.Format("2006-01-02"), got, want)
+		}
+	}
+}
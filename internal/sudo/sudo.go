@@ -0,0 +1,104 @@
+// Package sudo keeps sudo's credential cache warm for a TUI session, so
+// privileged actions stop asking for the password again and again. The
+// password never passes through the app: sudo prompts for it on the terminal
+// itself, and only sudo's own timestamp remembers that it was given.
+// TunnelRunner is what puts wg and wg-quick under that timestamp.
+package sudo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+// RefreshInterval is how often Refresh runs. sudo's timestamp_timeout
+// defaults to 5 minutes (15 on some distributions), so a minute keeps well
+// inside it.
+const RefreshInterval = time.Minute
+
+// Runner runs sudo for the TUI; the functions take a runner so the refresh
+// can be driven by a fake.
+var Runner wgvpn.CommandRunner = wgvpn.ExecRunner{}
+
+var (
+	// ErrNoCaching means sudo asked for the password again right after it
+	// was given: timestamp_timeout=0 in sudoers, so there is nothing to keep
+	// warm.
+	ErrNoCaching = errors.New("sudo doesn't cache credentials on this system (timestamp_timeout=0), so they can't be kept")
+	// ErrExpired means the cached credentials were lost between refreshes,
+	// e.g. to sudo -k in another shell or a timeout under a minute.
+	ErrExpired = errors.New("sudo credentials are no longer cached")
+)
+
+// IsRoot reports whether the app already runs as root, where sudo isn't
+// needed at all.
+func IsRoot() bool {
+	return os.Geteuid() == 0
+}
+
+// TunnelRunner returns the runner the VPN service runs its commands with.
+// Without root, wg and wg-quick go through sudo -n, so the credentials
+// Refresh keeps warm (or a NOPASSWD rule) cover them and sudo never prompts
+// over the TUI; every other command runs as the user.
+func TunnelRunner() wgvpn.CommandRunner {
+	if IsRoot() {
+		return wgvpn.ExecRunner{}
+	}
+	return privileged{user: wgvpn.ExecRunner{}, root: wgvpn.ExecRunner{Sudo: true}}
+}
+
+// privileged runs the tools needing root with root and the rest with user.
+type privileged struct {
+	user, root wgvpn.CommandRunner
+}
+
+func (p privileged) runner(name string) wgvpn.CommandRunner {
+	if name == "wg" || name == "wg-quick" {
+		return p.root
+	}
+	return p.user
+}
+
+func (p privileged) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return p.runner(name).Output(ctx, name, args...)
+}
+
+func (p privileged) CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return p.runner(name).CombinedOutput(ctx, name, args...)
+}
+
+func (p privileged) Stream(ctx context.Context, onLine func(line string), name string, args ...string) ([]byte, error) {
+	return p.runner(name).Stream(ctx, onLine, name, args...)
+}
+
+// ValidateCommand asks for the password once: run it on the terminal (the
+// TUI suspends for it), then call Check.
+func ValidateCommand() *exec.Cmd {
+	return exec.Command("sudo", "-v")
+}
+
+// Check confirms sudo cached the credentials ValidateCommand just validated.
+func Check(ctx context.Context, runner wgvpn.CommandRunner) error {
+	if _, err := runner.CombinedOutput(ctx, "sudo", "-n", "-v"); err != nil {
+		return ErrNoCaching
+	}
+	return nil
+}
+
+// Refresh extends the cached credentials without ever prompting.
+func Refresh(ctx context.Context, runner wgvpn.CommandRunner) error {
+	if _, err := runner.CombinedOutput(ctx, "sudo", "-n", "-v"); err != nil {
+		return ErrExpired
+	}
+	return nil
+}
+
+// Drop invalidates the cached credentials, so the next sudo prompts again.
+func Drop(ctx context.Context, runner wgvpn.CommandRunner) error {
+	_, err := runner.CombinedOutput(ctx, "sudo", "-k")
+	return err
+}
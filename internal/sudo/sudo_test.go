@@ -0,0 +1,115 @@
+package sudo
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeSudo answers sudo -n -v as if credentials were cached or not, and
+// records what was run.
+type fakeSudo struct {
+	cached bool
+	calls  []string
+}
+
+func (f *fakeSudo) run(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	f.calls = append(f.calls, command)
+	switch command {
+	case "sudo -n -v":
+		if !f.cached {
+			return []byte("sudo: a password is required\n"), errors.New("exit status 1")
+		}
+		return nil, nil
+	case "sudo -k":
+		f.cached = false
+		return nil, nil
+	}
+	return nil, errors.New("unexpected command: " + command)
+}
+
+func (f *fakeSudo) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	return f.run(name, args...)
+}
+
+func (f *fakeSudo) CombinedOutput(_ context.Context, name string, args ...string) ([]byte, error) {
+	return f.run(name, args...)
+}
+
+func (f *fakeSudo) Stream(_ context.Context, _ func(string), name string, args ...string) ([]byte, error) {
+	return f.run(name, args...)
+}
+
+func TestKeepRefreshDrop(t *testing.T) {
+	ctx := context.Background()
+	runner := &fakeSudo{cached: true}
+
+	if err := Check(ctx, runner); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+	for range 3 {
+		if err := Refresh(ctx, runner); err != nil {
+			t.Fatalf("Refresh() = %v", err)
+		}
+	}
+	if err := Drop(ctx, runner); err != nil {
+		t.Fatalf("Drop() = %v", err)
+	}
+	if err := Refresh(ctx, runner); !errors.Is(err, ErrExpired) {
+		t.Errorf("Refresh() after Drop = %v, want ErrExpired", err)
+	}
+	// Never a command that could prompt
+	want := []string{"sudo -n -v", "sudo -n -v", "sudo -n -v", "sudo -n -v", "sudo -k", "sudo -n -v"}
+	if !slices.Equal(runner.calls, want) {
+		t.Errorf("ran %q, want %q", runner.calls, want)
+	}
+}
+
+func TestCheckWithoutCaching(t *testing.T) {
+	// timestamp_timeout=0: the password was just given, yet nothing is cached
+	if err := Check(context.Background(), &fakeSudo{}); !errors.Is(err, ErrNoCaching) {
+		t.Errorf("Check() = %v, want ErrNoCaching", err)
+	}
+}
+
+// recorder records the commands run through it.
+type recorder struct{ calls []string }
+
+func (r *recorder) record(name string, args ...string) ([]byte, error) {
+	r.calls = append(r.calls, strings.Join(append([]string{name}, args...), " "))
+	return nil, nil
+}
+
+func (r *recorder) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.record(name, args...)
+}
+
+func (r *recorder) CombinedOutput(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.record(name, args...)
+}
+
+func (r *recorder) Stream(_ context.Context, _ func(string), name string, args ...string) ([]byte, error) {
+	return r.record(name, args...)
+}
+
+// Only wg and wg-quick run as root; the kept credentials are what let them.
+func TestPrivilegedRunsWireGuardAsRoot(t *testing.T) {
+	ctx := context.Background()
+	user, root := &recorder{}, &recorder{}
+	runner := privileged{user: user, root: root}
+	runner.Output(ctx, "wg", "show", "interfaces")
+	runner.Stream(ctx, nil, "wg-quick", "up", "julo-prod")
+	runner.Output(ctx, "ip", "route", "get", "10.80.1.5")
+	runner.CombinedOutput(ctx, "resolvectl", "dns")
+	runner.CombinedOutput(ctx, "wg-quick", "down", "julo-prod")
+
+	if want := []string{"wg show interfaces", "wg-quick up julo-prod", "wg-quick down julo-prod"}; !slices.Equal(root.calls, want) {
+		t.Errorf("ran %q as root, want %q", root.calls, want)
+	}
+	if want := []string{"ip route get 10.80.1.5", "resolvectl dns"}; !slices.Equal(user.calls, want) {
+		t.Errorf("ran %q as the user, want %q", user.calls, want)
+	}
+}
@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/atotto/clipboard"
+	"github.com/muesli/termenv"
+)
+
+// CopyToClipboard puts text on the system clipboard, falling back to an OSC52
+// escape sequence (which also works over SSH) when no clipboard tool exists.
+func CopyToClipboard(text string) {
+	if err := clipboard.WriteAll(text); err != nil {
+		termenv.Copy(text)
+	}
+}
+
+// CopyOrSave puts text on the system clipboard. Without a clipboard tool it
+// is sent with OSC52, which the terminal may silently ignore, so it is also
+// written to a private temp file whose path is returned.
+func CopyOrSave(text, pattern string) (string, error) {
+	if err := clipboard.WriteAll(text); err == nil {
+		return "", nil
+	}
+	termenv.Copy(text)
+
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to save a copy: %v", err)
+	}
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to save a copy: %v", err)
+	}
+	return file.Name(), nil
+}
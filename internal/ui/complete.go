@@ -0,0 +1,168 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"tui-wireguard-vpn/internal/ui/render"
+)
+
+// Candidates listed below the input at once
+const maxShownCandidates = 8
+
+var candidateStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#007ACC"))
+
+// pathCompleter implements Tab completion for typed config paths: it
+// completes directories and .conf files, cycles through the candidates on
+// repeated Tab and lists them below the input.
+type pathCompleter struct {
+	candidates []string // full values, e.g. "/home/me/Downloads/"
+	index      int      // candidate last put into the input, -1 for none
+	applied    string   // input value after the last completion
+}
+
+// expandPath expands a leading ~ and $VARS in a typed path.
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+	return path
+}
+
+// pathCandidates lists what input can complete to: directories (ending in
+// "/") and .conf files whose names start with the last path element. Hidden
+// entries only match when that element starts with a dot. Unreadable
+// directories simply have no candidates.
+func pathCandidates(input string) []string {
+	expanded := expandPath(input)
+	dir, prefix := filepath.Split(expanded)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var dirs, files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Follow links so linked directories complete like directories
+			info, err := os.Stat(filepath.Join(readDir, name))
+			if err != nil {
+				continue
+			}
+			isDir = info.IsDir()
+		}
+		switch {
+		case isDir:
+			dirs = append(dirs, dir+name+string(filepath.Separator))
+		case strings.HasSuffix(name, ".conf"):
+			files = append(files, dir+name)
+		}
+	}
+	sort.Strings(dirs)
+	sort.Strings(files)
+	return append(dirs, files...)
+}
+
+// commonPrefix returns the longest prefix shared by all values.
+func commonPrefix(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// Next returns the input value after pressing Tab on input. A fresh Tab
+// completes as far as all candidates agree; once that is reached, repeated
+// Tabs cycle through them.
+func (c *pathCompleter) Next(input string) string {
+	if c.applied == "" || input != c.applied {
+		c.candidates = pathCandidates(input)
+		c.index = -1
+		if len(c.candidates) == 0 {
+			c.applied = ""
+			return input
+		}
+		if len(c.candidates) == 1 {
+			// Nothing to cycle; the next Tab completes inside a directory
+			only := c.candidates[0]
+			c.Reset()
+			return only
+		}
+		if common := commonPrefix(c.candidates); len(common) > len(expandPath(input)) {
+			c.applied = common
+			return common
+		}
+	}
+	if len(c.candidates) == 0 {
+		return input
+	}
+	c.index = (c.index + 1) % len(c.candidates)
+	c.applied = c.candidates[c.index]
+	return c.applied
+}
+
+// Reset forgets the candidates, e.g. after the input was edited.
+func (c *pathCompleter) Reset() {
+	*c = pathCompleter{index: -1}
+}
+
+// View lists the current candidates by name, the selected one highlighted.
+// Nothing is shown while there is no choice to make.
+func (c *pathCompleter) View(width int) string {
+	if len(c.candidates) < 2 {
+		return ""
+	}
+	if width <= 0 {
+		width = 80
+	}
+
+	// Keep the selected candidate inside the listed window
+	start := 0
+	if c.index >= maxShownCandidates {
+		start = c.index - maxShownCandidates + 1
+	}
+	end := min(start+maxShownCandidates, len(c.candidates))
+
+	var b strings.Builder
+	for i := start; i < end; i++ {
+		candidate := c.candidates[i]
+		name := filepath.Base(strings.TrimSuffix(candidate, string(filepath.Separator)))
+		if strings.HasSuffix(candidate, string(filepath.Separator)) {
+			name += string(filepath.Separator)
+		}
+		line := "  " + render.FileName(render.SanitizeName(name), width-2)
+		if i == c.index {
+			line = candidateStyle.Render("> " + strings.TrimPrefix(line, "  "))
+		}
+		b.WriteString(line + "\n")
+	}
+	if hidden := len(c.candidates) - (end - start); hidden > 0 {
+		b.WriteString(fmt.Sprintf("  … %d more (Tab to cycle)\n", hidden))
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// completionTree lays out a directory to complete in and returns its path
+// with a trailing separator.
+func completionTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"Documents", "Downloads", ".hidden", "my configs"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"julo-prod.conf", "julo-nonprod.conf", "notes.txt", "my configs/julo prod.conf"} {
+		if err := os.WriteFile(filepath.Join(root, file), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "Downloads"), filepath.Join(root, "latest")); err != nil {
+		t.Fatal(err)
+	}
+	return root + string(filepath.Separator)
+}
+
+func TestPathCandidates(t *testing.T) {
+	root := completionTree(t)
+	t.Setenv("HOME", filepath.Clean(root))
+	t.Setenv("VPN_CONFIGS", filepath.Clean(root))
+
+	tests := []struct {
+		input string
+		want  []string // relative to root
+	}{
+		// Directories first, then .conf files; no hidden entries, no other files
+		{root, []string{"Documents/", "Downloads/", "latest/", "my configs/", "julo-nonprod.conf", "julo-prod.conf"}},
+		{root + "Do", []string{"Documents/", "Downloads/"}},
+		{root + ".h", []string{".hidden/"}},
+		{root + "my", []string{"my configs/"}},
+		{root + "my configs/j", []string{"my configs/julo prod.conf"}},
+		{root + "notes", nil},
+		{root + "missing/", nil},
+		{"~/julo-p", []string{"julo-prod.conf"}},
+		{"$VPN_CONFIGS/julo-n", []string{"julo-nonprod.conf"}},
+	}
+	for _, tt := range tests {
+		var want []string
+		for _, rel := range tt.want {
+			want = append(want, root+filepath.FromSlash(rel))
+		}
+		if got := pathCandidates(tt.input); !slices.Equal(got, want) {
+			t.Errorf("pathCandidates(%q) = %q, want %q", tt.input, got, want)
+		}
+	}
+}
+
+func TestPathCandidatesUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root reads every directory")
+	}
+	root := completionTree(t)
+	locked := filepath.Join(root, "Documents")
+	if err := os.WriteFile(filepath.Join(locked, "secret.conf"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(locked, 0755) })
+	if got := pathCandidates(locked + "/"); got != nil {
+		t.Errorf("pathCandidates in an unreadable directory = %q", got)
+	}
+}
+
+func TestCompleterNext(t *testing.T) {
+	root := completionTree(t)
+	tests := []struct {
+		name  string
+		input string
+		tabs  []string // the input after each Tab, relative to root
+	}{
+		{"one candidate", root + "julo-n", []string{"julo-nonprod.conf"}},
+		{"into a directory", root + "my", []string{"my configs/", "my configs/julo prod.conf"}},
+		{"common prefix, then cycle", root + "ju", []string{"julo-", "julo-nonprod.conf", "julo-prod.conf", "julo-nonprod.conf"}},
+		{"cycle at once", root + "Do", []string{"Documents/", "Downloads/", "Documents/"}},
+		{"nothing to complete", root + "zzz", []string{"zzz", "zzz"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c pathCompleter
+			c.Reset()
+			input := tt.input
+			for i, want := range tt.tabs {
+				input = c.Next(input)
+				if want := root + filepath.FromSlash(want); input != want {
+					t.Fatalf("Tab %d: %q, want %q", i+1, input, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleterEditedInput(t *testing.T) {
+	root := completionTree(t)
+	var c pathCompleter
+	c.Reset()
+	c.Next(root + "Do") // Documents/
+	// Editing the input starts over from what is typed
+	if got := c.Next(root + "ju"); got != root+"julo-" {
+		t.Errorf("Next after editing = %q", got)
+	}
+}
+
+func TestCompleterView(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"} {
+		if err := os.WriteFile(filepath.Join(root, name+".conf"), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var c pathCompleter
+	c.Reset()
+	if view := c.View(40); view != "" {
+		t.Errorf("View() before Tab:\n%s", view)
+	}
+	c.Next(root + string(filepath.Separator))
+
+	view := c.View(40)
+	lines := strings.Split(strings.TrimSuffix(view, "\n"), "\n")
+	if len(lines) != maxShownCandidates+1 || lines[0] != "> a.conf" || lines[1] != "  b.conf" || lines[maxShownCandidates] != "  … 2 more (Tab to cycle)" {
+		t.Errorf("View() =\n%s", view)
+	}
+	for range 9 {
+		c.Next(c.applied)
+	}
+	// The selected one stays listed
+	if view := c.View(40); !strings.Contains(view, "> j.conf") {
+		t.Errorf("View() after cycling to the last =\n%s", view)
+	}
+}
@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/ui/render"
+)
+
+// GenerateModel is the "Generate new client config" wizard shown in the
+// input panel: pick an environment, enter the assigned Address, confirm,
+// then show the public key to send to infra.
+type GenerateModel struct {
+	stage     int // 0: choose environment, 1: address input, 2: confirm, 3: generating, 4: result
+	envIndex  int
+	address   textinput.Model
+	message   string
+	confirmed bool
+	result    *config.GeneratedConfig
+	err       error
+	copied    bool
+	done      bool
+}
+
+var generateEnvironments = []struct {
+	name  string
+	label string
+}{
+	{"prod", "Production"},
+	{"nonprod", "Non-Production"},
+}
+
+func NewGenerateModel() *GenerateModel {
+	ti := textinput.New()
+	ti.Placeholder = "10.80.12.34/32"
+	ti.CharLimit = 64
+	ti.Width = 30
+
+	return &GenerateModel{
+		address: ti,
+	}
+}
+
+func (m *GenerateModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *GenerateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		if m.stage == 1 {
+			var cmd tea.Cmd
+			m.address, cmd = m.address.Update(msg)
+			return m, cmd
+		}
+		return m, nil
+	}
+
+	switch m.stage {
+	case 0: // Choose environment
+		switch keyMsg.String() {
+		case "up", "k", "down", "j", "tab":
+			m.envIndex = 1 - m.envIndex
+		case "enter":
+			m.stage = 1
+			m.message = ""
+			m.address.Focus()
+			return m, textinput.Blink
+		}
+		return m, nil
+
+	case 1: // Address input
+		switch keyMsg.String() {
+		case "enter":
+			address := strings.TrimSpace(m.address.Value())
+			if err := config.ValidateAddress(address); err != nil {
+				m.message = err.Error()
+				return m, nil
+			}
+			m.message = ""
+			m.address.Blur()
+			m.stage = 2
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.address, cmd = m.address.Update(msg)
+		return m, cmd
+
+	case 2: // Confirm
+		switch keyMsg.String() {
+		case "y", "enter":
+			m.confirmed = true
+			m.stage = 3
+		case "n":
+			m.stage = 1
+			m.address.Focus()
+		}
+		return m, nil
+
+	case 4: // Result
+		switch keyMsg.String() {
+		case "c":
+			if m.result != nil {
+				CopyToClipboard(m.result.PublicKey)
+				m.copied = true
+			}
+		case "enter":
+			m.done = true
+		}
+	}
+	return m, nil
+}
+
+// Request returns the environment and address once the user has confirmed
+// generation. It only reports ready once per confirmation.
+func (m *GenerateModel) Request() (env, address string, ready bool) {
+	if !m.confirmed {
+		return "", "", false
+	}
+	m.confirmed = false
+	return generateEnvironments[m.envIndex].name, strings.TrimSpace(m.address.Value()), true
+}
+
+// SetResult records the outcome of generation and shows it.
+func (m *GenerateModel) SetResult(result *config.GeneratedConfig, err error) {
+	m.result = result
+	m.err = err
+	m.stage = 4
+}
+
+// Done reports whether the user has dismissed the result screen.
+func (m *GenerateModel) Done() bool {
+	return m.done
+}
+
+// Hints reports the keys of the current stage for the controls panel.
+func (m *GenerateModel) Hints() render.HintGroup {
+	group := render.HintGroup{Title: "Generate Config"}
+	switch m.stage {
+	case 0:
+		group.Add("↑/↓", "Choose environment")
+		group.Add("Enter", "Continue")
+	case 1:
+		group.Add("Enter", "Check the address")
+	case 2:
+		group.Add("y/Enter", "Generate")
+		group.Add("n", "Change the address")
+	case 4:
+		if m.result != nil {
+			group.Add("c", "Copy the public key")
+		}
+		group.Add("Enter", "Done")
+	}
+	group.Add("Esc", "Cancel")
+	return group
+}
+
+func (m *GenerateModel) View() string {
+	var s strings.Builder
+
+	s.WriteString(updateTitleStyle.Render("Generate New Client Config"))
+	s.WriteString("\n\n")
+
+	env := generateEnvironments[m.envIndex]
+
+	switch m.stage {
+	case 0:
+		s.WriteString("Which environment is this config for?\n\n")
+		for i, e := range generateEnvironments {
+			cursor := "  "
+			if i == m.envIndex {
+				cursor = "> "
+			}
+			s.WriteString(fmt.Sprintf("%s%s\n", cursor, e.label))
+		}
+		s.WriteString("\nUse ↑/↓ to switch, Enter to select, Esc to cancel")
+
+	case 1:
+		s.WriteString(fmt.Sprintf("Environment: %s\n\n", env.label))
+		s.WriteString("Enter the Address infra assigned to you (CIDR):\n\n")
+		s.WriteString(m.address.View())
+		s.WriteString("\n\nPress Enter to continue, Esc to cancel")
+
+	case 2:
+		s.WriteString(fmt.Sprintf("Environment: %s\n", env.label))
+		s.WriteString(fmt.Sprintf("Address:     %s\n\n", strings.TrimSpace(m.address.Value())))
+		s.WriteString("A new keypair will be generated and written to\n")
+		s.WriteString(fmt.Sprintf("%s/%s (mode 0600).\n", core.ConfigDir, core.ConfigFile(core.Environment(env.name))))
+		s.WriteString("An existing config there will be backed up first.\n\n")
+		s.WriteString("Generate now? (y/n)")
+
+	case 3:
+		s.WriteString("Generating keypair and writing config...")
+
+	case 4:
+		if m.err != nil {
+			s.WriteString(updateErrorStyle.Render(fmt.Sprintf("❌ Failed to generate config: %v", m.err)))
+			s.WriteString("\n\nPress Enter to close")
+			break
+		}
+		s.WriteString(updateSuccessStyle.Render(fmt.Sprintf("✅ %s config written to %s", env.label, m.result.Path)))
+		s.WriteString("\n\n")
+		if m.result.BackupPath != "" {
+			s.WriteString(fmt.Sprintf("Previous config backed up to %s\n\n", m.result.BackupPath))
+		}
+		s.WriteString("Send this public key to infra for registration:\n\n")
+		s.WriteString("  " + m.result.PublicKey + "\n\n")
+		s.WriteString("⚠️  The tunnel won't work until infra confirms the key is registered.\n\n")
+		if m.copied {
+			s.WriteString("Public key copied to clipboard. ")
+		}
+		s.WriteString("Press c to copy the public key, Enter to close")
+	}
+
+	if m.message != "" {
+		s.WriteString("\n\n")
+		s.WriteString(updateErrorStyle.Render(m.message))
+	}
+
+	return s.String()
+}
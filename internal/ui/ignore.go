@@ -0,0 +1,60 @@
+package ui
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// IgnoreList hides file browser entries by name, gitignore style: each
+// pattern is a glob as path.Match takes it (*, ?, [a-z]) matched against
+// the entry's name, a trailing "/" limits it to directories, and a leading
+// "!" shows what an earlier pattern hid. The last pattern matching a name
+// decides.
+type IgnoreList []string
+
+// ignorePatterns is the list the file browsers apply; see SetIgnorePatterns.
+var ignorePatterns IgnoreList
+
+// SetIgnorePatterns sets the patterns of the entries the file browsers hide,
+// on top of the hidden-files toggle. It is called once at startup, from the
+// settings.
+func SetIgnorePatterns(patterns []string) {
+	ignorePatterns = patterns
+}
+
+// Ignored reports whether the entry name, a directory when dir is set, is
+// hidden. ".." never is.
+func (l IgnoreList) Ignored(name string, dir bool) bool {
+	if name == ".." {
+		return false
+	}
+	ignored := false
+	for _, pattern := range l {
+		negated := strings.HasPrefix(pattern, "!")
+		glob := strings.TrimPrefix(pattern, "!")
+		glob, dirOnly := strings.CutSuffix(glob, "/")
+		// A leading "/" anchors a gitignore pattern; the browsers only
+		// ever match names within one directory
+		glob = strings.TrimPrefix(glob, "/")
+		if dirOnly && !dir {
+			continue
+		}
+		if matched, err := path.Match(glob, name); err == nil && matched {
+			ignored = !negated
+		}
+	}
+	return ignored
+}
+
+// filterStatus is the file browsers' header note on the ignore patterns,
+// e.g. " | 3 hidden by filters", "" when they hide nothing.
+func filterStatus(filtered int, unfiltered bool) string {
+	switch {
+	case unfiltered:
+		return " | Filters: OFF"
+	case filtered > 0:
+		return fmt.Sprintf(" | %d hidden by filters", filtered)
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package ui
+
+import "testing"
+
+func TestIgnored(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns IgnoreList
+		entry    string
+		dir      bool
+		want     bool
+	}{
+		{"no patterns", nil, "julo-prod.conf", false, false},
+		{"exact name", IgnoreList{"node_modules"}, "node_modules", true, true},
+		{"star", IgnoreList{"*.bak"}, "julo-prod.conf.bak", false, true},
+		{"star misses", IgnoreList{"*.bak"}, "julo-prod.conf", false, false},
+		{"question mark", IgnoreList{"julo-?.conf"}, "julo-x.conf", false, true},
+		{"class", IgnoreList{"backup-[0-9]*"}, "backup-2024", true, true},
+		{"class misses", IgnoreList{"backup-[0-9]*"}, "backup-old", true, false},
+		{"directories only", IgnoreList{"build/"}, "build", true, true},
+		{"directories only, on a file", IgnoreList{"build/"}, "build", false, false},
+		{"anchored", IgnoreList{"/vendor"}, "vendor", true, true},
+		{"negated", IgnoreList{"*.conf", "!julo-*.conf"}, "julo-prod.conf", false, false},
+		{"negated, others still hidden", IgnoreList{"*.conf", "!julo-*.conf"}, "wg0.conf", false, true},
+		{"last match decides", IgnoreList{"!*.conf", "*.conf"}, "julo-prod.conf", false, true},
+		{"negated directory", IgnoreList{"*", "!configs/"}, "configs", true, false},
+		{"negated directory, on a file", IgnoreList{"*", "!configs/"}, "configs", false, true},
+		{"bad pattern", IgnoreList{"[a-"}, "a", false, false},
+		{"parent", IgnoreList{"*", ".*"}, "..", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.patterns.Ignored(tt.entry, tt.dir); got != tt.want {
+				t.Errorf("%q.Ignored(%q, %t) = %t, want %t", tt.patterns, tt.entry, tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterStatus(t *testing.T) {
+	tests := []struct {
+		filtered   int
+		unfiltered bool
+		want       string
+	}{
+		{0, false, ""},
+		{3, false, " | 3 hidden by filters"},
+		{3, true, " | Filters: OFF"},
+		{0, true, " | Filters: OFF"},
+	}
+	for _, tt := range tests {
+		if got := filterStatus(tt.filtered, tt.unfiltered); got != tt.want {
+			t.Errorf("filterStatus(%d, %t) = %q, want %q", tt.filtered, tt.unfiltered, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/ui/render"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// LogView is the scroll state of the activity log: which entries are visible,
+// which one is selected, and whether the view follows new entries.
+//
+// In follow mode the view sticks to the newest entry. Moving the selection up
+// leaves follow mode, so entries arriving while an old one is being read
+// don't move anything; End or F re-engage it.
+//
+// Entries can belong to an operation's group. A group is drawn as one header
+// row ("▸ Start Production — 6 steps, 8.2s, success") at the place the
+// operation began, with its entries below it while expanded. Entries logged
+// without a group render as plain rows.
+//
+// An entry identical to the one logged just before it in the same place (the
+// plain log or the same group) isn't added again; the earlier row counts it
+// instead ("endpoint unreachable ×14, last 14:32:05").
+type LogView struct {
+	entries []logEntry
+	groups  map[int]*logGroup
+	// Positions are kept as entry indexes rather than rows, so expanding or
+	// growing a group above them doesn't move what is on screen
+	start  int // entry of the first visible row
+	cursor int // entry of the selected row
+	size   int // rows visible at once
+	follow bool
+}
+
+type logEntry struct {
+	text   string
+	group  int  // 0 for ungrouped entries
+	header bool // the entry standing for the group's header row
+	count  int  // times the entry was logged in a row
+	last   time.Time
+}
+
+// display is the entry's text with its repeat counter.
+func (e logEntry) display() string {
+	if e.count < 2 {
+		return e.text
+	}
+	return fmt.Sprintf("%s ×%d, last %s", e.text, e.count, e.last.Format("15:04:05"))
+}
+
+type logGroup struct {
+	title    string
+	started  time.Time
+	elapsed  time.Duration
+	children []int // entry indexes
+	done     bool
+	success  bool
+	expanded bool
+}
+
+func (g *logGroup) headerText() string {
+	marker := "▸"
+	if g.expanded {
+		marker = "▾"
+	}
+	steps := fmt.Sprintf("%d steps", len(g.children))
+	if len(g.children) == 1 {
+		steps = "1 step"
+	}
+	if !g.done {
+		return fmt.Sprintf("%s %s — %s, running", marker, g.title, steps)
+	}
+	outcome := "success"
+	if !g.success {
+		outcome = "failed"
+	}
+	return fmt.Sprintf("%s %s — %s, %s, %s", marker, g.title, steps, vpn.FormatDuration(g.elapsed), outcome)
+}
+
+func NewLogView() *LogView {
+	return &LogView{groups: map[int]*logGroup{}, size: 1, follow: true}
+}
+
+// Append adds an ungrouped entry. Only in follow mode does the view move to
+// it.
+func (l *LogView) Append(entry string) {
+	if n := len(l.entries); n > 0 && l.entries[n-1].group == 0 && l.repeat(n-1, entry) {
+		return
+	}
+	l.add(logEntry{text: entry, count: 1})
+}
+
+// Last returns the text of the newest n entries starting with prefix (e.g.
+// "❌" for errors), oldest first. Group headers aren't entries of their own.
+func (l *LogView) Last(prefix string, n int) []string {
+	var found []string
+	for i := len(l.entries) - 1; i >= 0 && len(found) < n; i-- {
+		entry := l.entries[i]
+		if !entry.header && strings.HasPrefix(strings.TrimSpace(entry.text), prefix) {
+			found = append([]string{entry.display()}, found...)
+		}
+	}
+	return found
+}
+
+// BeginGroup starts an operation's group, expanded while it runs, and
+// returns its ID for AppendTo and EndGroup.
+func (l *LogView) BeginGroup(title string) int {
+	return l.BeginGroupSince(title, time.Now())
+}
+
+// BeginGroupSince is BeginGroup for an operation that started earlier, e.g.
+// one logged as a summary once it is over.
+func (l *LogView) BeginGroupSince(title string, started time.Time) int {
+	id := len(l.groups) + 1
+	l.groups[id] = &logGroup{title: title, started: started, expanded: true}
+	l.add(logEntry{group: id, header: true})
+	return id
+}
+
+// AppendTo adds an entry to a group; unknown groups (like 0) get a plain
+// entry.
+func (l *LogView) AppendTo(group int, entry string) {
+	g, ok := l.groups[group]
+	if !ok {
+		l.Append(entry)
+		return
+	}
+	if n := len(g.children); n > 0 && l.repeat(g.children[n-1], entry) {
+		return
+	}
+	g.children = append(g.children, len(l.entries))
+	l.add(logEntry{text: entry, group: group, count: 1})
+}
+
+// repeat counts entry on the existing entry i when they are the same text.
+func (l *LogView) repeat(i int, entry string) bool {
+	existing := &l.entries[i]
+	if existing.header || existing.text != entry {
+		return false
+	}
+	existing.count++
+	existing.last = time.Now()
+	return true
+}
+
+// EndGroup records the operation's outcome. Successful groups collapse to
+// their header; failed ones stay open so the output is at hand.
+func (l *LogView) EndGroup(group int, success bool) {
+	g, ok := l.groups[group]
+	if !ok || g.done {
+		return
+	}
+	g.done, g.success = true, success
+	g.elapsed = time.Since(g.started)
+	g.expanded = !success
+	if l.follow {
+		l.toEnd()
+		return
+	}
+	l.clamp()
+}
+
+// Toggle expands or collapses the group under the selection. On an entry
+// inside a group, the group collapses and its header is selected.
+func (l *LogView) Toggle() {
+	if len(l.entries) == 0 {
+		return
+	}
+	entry := l.entries[l.cursor]
+	g, ok := l.groups[entry.group]
+	if !ok {
+		return
+	}
+	if entry.header {
+		g.expanded = !g.expanded
+	} else {
+		g.expanded = false
+		l.cursor = l.headerOf(entry.group)
+	}
+	l.clamp()
+}
+
+func (l *LogView) add(entry logEntry) {
+	l.entries = append(l.entries, entry)
+	if l.follow {
+		l.toEnd()
+	}
+}
+
+// SetSize changes how many rows are visible, e.g. after a resize, keeping
+// the selection on screen.
+func (l *LogView) SetSize(size int) {
+	l.size = max(size, 1)
+	if l.follow {
+		l.toEnd()
+		return
+	}
+	l.clamp()
+}
+
+// Up moves the selection to the previous row and stops following.
+func (l *LogView) Up() {
+	l.moveCursor(-1)
+	l.follow = false
+}
+
+// Down moves the selection to the next row. Following only resumes
+// explicitly, so reaching the bottom doesn't start pulling the view again.
+func (l *LogView) Down() {
+	l.moveCursor(1)
+}
+
+// PageUp and PageDown move the selection by a screenful.
+func (l *LogView) PageUp() {
+	l.moveCursor(-l.size)
+	l.follow = false
+}
+
+func (l *LogView) PageDown() {
+	l.moveCursor(l.size)
+}
+
+// Home selects the oldest entry and stops following.
+func (l *LogView) Home() {
+	l.moveCursor(-len(l.entries))
+	l.follow = false
+}
+
+// Follow selects the newest entry and keeps the view on new ones (End, F).
+func (l *LogView) Follow() {
+	l.follow = true
+	l.toEnd()
+}
+
+func (l *LogView) Following() bool {
+	return l.follow
+}
+
+// Hints reports the log's keys for the controls panel.
+func (l *LogView) Hints() render.HintGroup {
+	group := render.HintGroup{Title: "Activity Log"}
+	group.Add("↑/↓", "Select entry")
+	group.Add("Enter", "Expand/collapse operation")
+	group.Add("PgUp/PgDn/Home", "Scroll")
+	if l.follow {
+		group.Add("", "Following new entries")
+	} else {
+		group.Add("End/F", "Follow new entries")
+	}
+	return group
+}
+
+// Selected returns the text of the selected row, false when the log is
+// empty.
+func (l *LogView) Selected() (string, bool) {
+	if len(l.entries) == 0 {
+		return "", false
+	}
+	entry := l.entries[l.cursor]
+	if entry.header {
+		return l.groups[entry.group].headerText(), true
+	}
+	return entry.display(), true
+}
+
+// Position is the panel title's readout of where the selection is among the
+// rows, e.g. "12/87"; "" when every row fits.
+func (l *LogView) Position() string {
+	rows, index := l.rows()
+	return render.ScrollPosition(l.rowOf(index, l.cursor)+1, len(rows), l.size)
+}
+
+// View draws the visible rows; the selection is only marked when focused.
+func (l *LogView) View(width int, focused bool) string {
+	rows, index := l.rows()
+	selected := -1
+	if focused {
+		selected = l.rowOf(index, l.cursor)
+	}
+	return render.RenderLogViewport(rows, l.rowOf(index, l.start), l.size, selected, width)
+}
+
+// rows flattens the entries into what is drawn, and the entry behind each
+// row.
+func (l *LogView) rows() ([]render.LogRow, []int) {
+	rows := make([]render.LogRow, 0, len(l.entries))
+	index := make([]int, 0, len(l.entries))
+	for i, entry := range l.entries {
+		switch {
+		case entry.header:
+			g := l.groups[entry.group]
+			rows = append(rows, render.LogRow{Text: g.headerText(), Header: true})
+			index = append(index, i)
+			if !g.expanded {
+				continue
+			}
+			for _, child := range g.children {
+				rows = append(rows, render.LogRow{Text: l.entries[child].display(), Nested: true})
+				index = append(index, child)
+			}
+		case entry.group == 0:
+			rows = append(rows, render.LogRow{Text: entry.display()})
+			index = append(index, i)
+		}
+	}
+	return rows, index
+}
+
+// rowOf finds the row showing entry; an entry of a collapsed group is shown
+// by its header.
+func (l *LogView) rowOf(index []int, entry int) int {
+	if len(l.entries) == 0 {
+		return 0
+	}
+	if group := l.entries[entry].group; group != 0 && !l.groups[group].expanded {
+		entry = l.headerOf(group)
+	}
+	for row, e := range index {
+		if e == entry {
+			return row
+		}
+	}
+	return 0
+}
+
+func (l *LogView) headerOf(group int) int {
+	for i, entry := range l.entries {
+		if entry.header && entry.group == group {
+			return i
+		}
+	}
+	return 0
+}
+
+func (l *LogView) moveCursor(delta int) {
+	_, index := l.rows()
+	if len(index) == 0 {
+		return
+	}
+	row := max(min(l.rowOf(index, l.cursor)+delta, len(index)-1), 0)
+	l.cursor = index[row]
+	l.clamp()
+}
+
+func (l *LogView) toEnd() {
+	_, index := l.rows()
+	if len(index) == 0 {
+		return
+	}
+	l.cursor = index[len(index)-1]
+	l.start = index[max(len(index)-l.size, 0)]
+}
+
+// clamp scrolls just enough to keep the cursor visible and the window
+// inside the rows.
+func (l *LogView) clamp() {
+	_, index := l.rows()
+	if len(index) == 0 {
+		return
+	}
+	start, cursor := l.rowOf(index, l.start), l.rowOf(index, l.cursor)
+	if cursor < start {
+		start = cursor
+	}
+	if cursor >= start+l.size {
+		start = cursor - l.size + 1
+	}
+	start = max(min(start, len(index)-l.size), 0)
+	l.start, l.cursor = index[start], index[cursor]
+}
@@ -0,0 +1,205 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// window is the text of the rows the log shows.
+func window(l *LogView) []string {
+	rows, index := l.rows()
+	start := l.rowOf(index, l.start)
+	var texts []string
+	for _, row := range rows[start:min(start+l.size, len(rows))] {
+		texts = append(texts, row.Text)
+	}
+	return texts
+}
+
+func appendEntries(l *LogView, from, to int) {
+	for i := from; i <= to; i++ {
+		l.Append(fmt.Sprintf("entry %d", i))
+	}
+}
+
+func entries(from, to int) []string {
+	var texts []string
+	for i := from; i <= to; i++ {
+		texts = append(texts, fmt.Sprintf("entry %d", i))
+	}
+	return texts
+}
+
+func selected(l *LogView) string {
+	text, _ := l.Selected()
+	return text
+}
+
+func TestLogViewFollowsTheTail(t *testing.T) {
+	l := NewLogView()
+	l.SetSize(3)
+	appendEntries(l, 1, 5)
+	if got := window(l); !slices.Equal(got, entries(3, 5)) || selected(l) != "entry 5" || !l.Following() {
+		t.Errorf("window %q, selected %q, following %v", got, selected(l), l.Following())
+	}
+}
+
+func TestLogViewScrolledUpNewEntryArrives(t *testing.T) {
+	l := NewLogView()
+	l.SetSize(3)
+	appendEntries(l, 1, 10)
+	for range 6 {
+		l.Up()
+	}
+	want := entries(4, 6)
+	if got := window(l); !slices.Equal(got, want) || selected(l) != "entry 4" || l.Following() {
+		t.Fatalf("after scrolling up: window %q, selected %q, following %v", got, selected(l), l.Following())
+	}
+
+	// What is being read stays put
+	appendEntries(l, 11, 15)
+	if got := window(l); !slices.Equal(got, want) || selected(l) != "entry 4" {
+		t.Errorf("after new entries: window %q, selected %q", got, selected(l))
+	}
+	// Reaching the bottom doesn't resume following...
+	for range 20 {
+		l.Down()
+	}
+	appendEntries(l, 16, 16)
+	if got := window(l); !slices.Equal(got, entries(13, 15)) || l.Following() {
+		t.Errorf("at the bottom: window %q, following %v", got, l.Following())
+	}
+	// ...End or F does
+	l.Follow()
+	appendEntries(l, 17, 17)
+	if got := window(l); !slices.Equal(got, entries(15, 17)) || selected(l) != "entry 17" {
+		t.Errorf("following again: window %q, selected %q", got, selected(l))
+	}
+}
+
+func TestLogViewPaging(t *testing.T) {
+	l := NewLogView()
+	l.SetSize(4)
+	appendEntries(l, 1, 20)
+	l.PageUp()
+	if got := selected(l); got != "entry 16" || l.Following() {
+		t.Errorf("PageUp selected %q", got)
+	}
+	l.Home()
+	if got := window(l); !slices.Equal(got, entries(1, 4)) || selected(l) != "entry 1" {
+		t.Errorf("Home: window %q, selected %q", got, selected(l))
+	}
+	l.PageDown()
+	if got := window(l); !slices.Equal(got, entries(2, 5)) || selected(l) != "entry 5" || l.Following() {
+		t.Errorf("PageDown: window %q, selected %q", got, selected(l))
+	}
+}
+
+func TestLogViewResize(t *testing.T) {
+	l := NewLogView()
+	l.SetSize(5)
+	appendEntries(l, 1, 20)
+	l.Home()
+	for range 4 {
+		l.Down()
+	}
+	// Shrinking keeps the selection on screen
+	l.SetSize(2)
+	if got := window(l); !slices.Equal(got, entries(4, 5)) || selected(l) != "entry 5" {
+		t.Errorf("shrunk: window %q, selected %q", got, selected(l))
+	}
+	// Growing past the end shows as much as there is
+	l.SetSize(30)
+	if got := window(l); !slices.Equal(got, entries(1, 20)) || selected(l) != "entry 5" {
+		t.Errorf("grown: window %q, selected %q", got, selected(l))
+	}
+	// A following log stays at the tail through resizes
+	l.Follow()
+	l.SetSize(3)
+	if got := window(l); !slices.Equal(got, entries(18, 20)) {
+		t.Errorf("following, resized: window %q", got)
+	}
+	l.SetSize(0)
+	if got := window(l); !slices.Equal(got, entries(20, 20)) {
+		t.Errorf("size 0: window %q", got)
+	}
+}
+
+func TestLogViewEmpty(t *testing.T) {
+	l := NewLogView()
+	l.Up()
+	l.Down()
+	l.Home()
+	l.SetSize(3)
+	if _, ok := l.Selected(); ok {
+		t.Error("an empty log has a selection")
+	}
+	if got := l.Position(); got != "" {
+		t.Errorf("Position() = %q", got)
+	}
+}
+
+// texts is every row of the log.
+func texts(l *LogView) []string {
+	rows, _ := l.rows()
+	var all []string
+	for _, row := range rows {
+		all = append(all, row.Text)
+	}
+	return all
+}
+
+func TestLogViewCollapsesRepeats(t *testing.T) {
+	l := NewLogView()
+	for range 14 {
+		l.Append("❌ endpoint unreachable")
+	}
+	rows := texts(l)
+	if len(rows) != 1 || !strings.HasPrefix(rows[0], "❌ endpoint unreachable ×14, last ") {
+		t.Errorf("rows %q", rows)
+	}
+
+	// A different entry in between starts the count over
+	l.Append("VPN status: connected")
+	l.Append("❌ endpoint unreachable")
+	l.Append("❌ endpoint unreachable")
+	rows = texts(l)
+	if len(rows) != 3 || !strings.HasPrefix(rows[2], "❌ endpoint unreachable ×2, last ") || rows[1] != "VPN status: connected" {
+		t.Errorf("rows %q", rows)
+	}
+	// Errors are still counted as one entry each
+	if got := l.Last("❌", 5); len(got) != 2 || !strings.Contains(got[0], "×14") {
+		t.Errorf("Last() = %q", got)
+	}
+}
+
+func TestLogViewInterleavedRepeats(t *testing.T) {
+	l := NewLogView()
+	for range 3 {
+		l.Append("❌ endpoint unreachable")
+		l.Append("⚠️ handshake stale")
+	}
+	want := []string{"❌ endpoint unreachable", "⚠️ handshake stale", "❌ endpoint unreachable", "⚠️ handshake stale", "❌ endpoint unreachable", "⚠️ handshake stale"}
+	if got := texts(l); !slices.Equal(got, want) {
+		t.Errorf("rows %q", got)
+	}
+}
+
+func TestLogViewRepeatsInGroups(t *testing.T) {
+	l := NewLogView()
+	l.Append("retrying")
+	group := l.BeginGroup("Start Production")
+	// The plain entry before the group is not the previous one in the group
+	l.AppendTo(group, "retrying")
+	l.AppendTo(group, "retrying")
+	l.Append("retrying")
+	rows := texts(l)
+	if len(rows) != 4 || rows[0] != "retrying" || !strings.HasPrefix(rows[2], "retrying ×2, last ") || rows[3] != "retrying" {
+		t.Errorf("rows %q", rows)
+	}
+	if !strings.HasPrefix(rows[1], "▾ Start Production — 1 step, running") {
+		t.Errorf("header %q", rows[1])
+	}
+}
@@ -0,0 +1,143 @@
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/ui/render"
+)
+
+// pathHintDelay is how long typing has to pause before the path is checked.
+const pathHintDelay = 300 * time.Millisecond
+
+// Only this much of a file is read to recognize it; configs are far smaller
+const pathHintReadLimit = 64 << 10
+
+var (
+	hintOKStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B"))
+	hintWarnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C"))
+	hintBadStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F87"))
+)
+
+type hintLevel int
+
+const (
+	hintOK hintLevel = iota
+	hintWarn
+	hintBad
+)
+
+// PathHintMsg is the result of checking a typed path, for the input whose
+// value had revision then.
+type PathHintMsg struct {
+	revision int64
+	level    hintLevel
+	text     string
+}
+
+// pathHint is the live hint line under a config path input. Every edit bumps
+// the revision and schedules a check once typing pauses; a check whose input
+// was edited since is skipped, and its result dropped, so the hint only ever
+// shows the current value.
+type pathHint struct {
+	latest *atomic.Int64 // shared with the scheduled checks
+	shown  *PathHintMsg
+}
+
+func newPathHint() pathHint {
+	return pathHint{latest: new(atomic.Int64)}
+}
+
+// Changed notes the input now reads value and schedules its check.
+func (h *pathHint) Changed(value string) tea.Cmd {
+	revision := h.latest.Add(1)
+	h.shown = nil
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+	latest := h.latest
+	return tea.Tick(pathHintDelay, func(time.Time) tea.Msg {
+		if latest.Load() != revision {
+			return nil // typed on since; a newer check is scheduled
+		}
+		level, text := checkConfigPath(expandPath(strings.TrimSpace(value)))
+		return PathHintMsg{revision: revision, level: level, text: text}
+	})
+}
+
+// Update shows msg's result unless the input changed since it was checked.
+func (h *pathHint) Update(msg PathHintMsg) {
+	if msg.revision == h.latest.Load() {
+		h.shown = &msg
+	}
+}
+
+// Reset clears the hint, e.g. when the input is left.
+func (h *pathHint) Reset() {
+	h.latest.Add(1)
+	h.shown = nil
+}
+
+// View is the hint line, "" while there is none.
+func (h *pathHint) View(width int) string {
+	if h.shown == nil {
+		return ""
+	}
+	if width <= 0 {
+		width = 80
+	}
+	style := hintOKStyle
+	switch h.shown.level {
+	case hintWarn:
+		style = hintWarnStyle
+	case hintBad:
+		style = hintBadStyle
+	}
+	return style.Render(render.Truncate(h.shown.text, width)) + "\n"
+}
+
+// checkConfigPath describes what path is, as far as a stat and a look at
+// the file's Endpoint tell: nothing is resolved, so it stays quick.
+func checkConfigPath(path string) (hintLevel, string) {
+	info, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return hintBad, "✗ not found"
+	case err != nil:
+		return hintBad, fmt.Sprintf("✗ %v", err)
+	case info.IsDir():
+		return hintWarn, "directory, press Tab to complete"
+	case !strings.HasSuffix(path, ".conf"):
+		return hintWarn, "file exists, but is not a .conf file"
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return hintBad, fmt.Sprintf("✗ %v", err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, pathHintReadLimit))
+	if err != nil {
+		return hintBad, fmt.Sprintf("✗ %v", err)
+	}
+	if config.LooksLikeTemplate(string(content)) {
+		return hintOK, "✓ file exists, looks like a template"
+	}
+	endpoint, err := config.ParseEndpoint(string(content))
+	if err != nil {
+		return hintWarn, fmt.Sprintf("file exists, but %v", err)
+	}
+	if env, ok := config.KnownEnvironment(endpoint); ok {
+		return hintOK, fmt.Sprintf("✓ file exists, looks like a %s config", core.Environment(env).DisplayName())
+	}
+	return hintOK, fmt.Sprintf("✓ file exists (Endpoint %s is checked on Enter)", endpoint)
+}
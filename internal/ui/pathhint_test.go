@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("VPN_CONFIGS", "/etc/wireguard")
+	tests := map[string]string{
+		"~":                           home,
+		"~/Downloads/julo-prod.conf":  home + "/Downloads/julo-prod.conf",
+		"$VPN_CONFIGS/julo-prod.conf": "/etc/wireguard/julo-prod.conf",
+		"~other/julo-prod.conf":       "~other/julo-prod.conf",
+		"/tmp/~/julo-prod.conf":       "/tmp/~/julo-prod.conf",
+		"julo-prod.conf":              "julo-prod.conf",
+	}
+	for input, want := range tests {
+		if got := expandPath(input); got != want {
+			t.Errorf("expandPath(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestCheckConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	files := map[string]string{
+		"julo-prod.conf":    "[Interface]\nPrivateKey = aGVsbG8=\n\n[Peer]\nEndpoint = 34.101.166.184:51820\n",
+		"template.conf":     "[Interface]\nPrivateKey = xxxxxxxx\nAddress = xxxx\n",
+		"vendor.conf":       "[Peer]\nEndpoint = 198.51.100.7:51820\n",
+		"no-endpoint.conf":  "[Interface]\nPrivateKey = aGVsbG8=\n",
+		"bad-endpoint.conf": "[Peer]\nEndpoint = gateway\n",
+		"notes.txt":         "Endpoint = 34.101.166.184:51820\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(home, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(home, "configs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		level hintLevel
+		text  string
+	}{
+		{"~/julo-prod.conf", hintOK, "✓ file exists, looks like a Production config"},
+		{"~/missing.conf", hintBad, "✗ not found"},
+		{"~/configs", hintWarn, "directory, press Tab to complete"},
+		{"~/notes.txt", hintWarn, "file exists, but is not a .conf file"},
+		{"~/template.conf", hintOK, "✓ file exists, looks like a template"},
+		{"~/vendor.conf", hintOK, "✓ file exists (Endpoint 198.51.100.7:51820 is checked on Enter)"},
+		{"~/no-endpoint.conf", hintWarn, "file exists, but no Endpoint found in config file"},
+		{"~/bad-endpoint.conf", hintWarn, "file exists, but invalid Endpoint"},
+	}
+	for _, tt := range tests {
+		level, text := checkConfigPath(expandPath(tt.input))
+		if level != tt.level || !strings.HasPrefix(text, tt.text) {
+			t.Errorf("checkConfigPath(%q) = %d, %q; want %d, %q", tt.input, level, text, tt.level, tt.text)
+		}
+	}
+}
+
+// Only the check of what the input reads now shows; one scheduled before
+// an edit is skipped, and a result arriving after one is dropped.
+func TestPathHintRevisions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	h := newPathHint()
+
+	stale := h.Changed("~/julo")
+	fresh := h.Changed("~/julo-prod.conf")
+	if msg := stale(); msg != nil {
+		t.Errorf("a check of an edited input ran: %+v", msg)
+	}
+	msg, ok := fresh().(PathHintMsg)
+	if !ok {
+		t.Fatal("the check of the current input didn't run")
+	}
+	h.Update(msg)
+	if view := h.View(80); !strings.Contains(view, "✗ not found") {
+		t.Errorf("View() = %q, want the missing-file hint", view)
+	}
+
+	h.Changed("~/julo-prod.con")
+	h.Update(msg)
+	if view := h.View(80); view != "" {
+		t.Errorf("View() = %q after an edit, want the old hint dropped", view)
+	}
+	if cmd := h.Changed("  "); cmd != nil {
+		t.Error("an empty input is checked")
+	}
+}
@@ -0,0 +1,32 @@
+package render
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfigAge describes how old an installed config is, e.g.
+// "config from 2024-03-11 (94 days old, generated)". stale reports whether
+// it is older than maxAge; a zero maxAge never marks a config stale. A zero
+// updatedAt means the config predates provenance tracking.
+func ConfigAge(updatedAt time.Time, source string, now time.Time, maxAge time.Duration) (text string, stale bool) {
+	if updatedAt.IsZero() {
+		return "config of unknown age", false
+	}
+
+	age := now.Sub(updatedAt)
+	days := int(age.Hours() / 24)
+	var ago string
+	switch {
+	case days <= 0:
+		ago = "today"
+	case days == 1:
+		ago = "1 day old"
+	default:
+		ago = fmt.Sprintf("%d days old", days)
+	}
+	if source != "" {
+		ago += ", " + source
+	}
+	return fmt.Sprintf("config from %s (%s)", updatedAt.Format("2006-01-02"), ago), maxAge > 0 && age > maxAge
+}
@@ -0,0 +1,36 @@
+package render
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigAge(t *testing.T) {
+	now := time.Date(2024, time.June, 13, 10, 0, 0, 0, time.UTC)
+	month := 30 * 24 * time.Hour
+	tests := []struct {
+		name      string
+		updatedAt time.Time
+		source    string
+		maxAge    time.Duration
+		want      string
+		stale     bool
+	}{
+		{"unknown", time.Time{}, "generated", month, "config of unknown age", false},
+		{"today", now.Add(-3 * time.Hour), "", month, "config from 2024-06-13 (today)", false},
+		{"from the future", now.Add(2 * time.Hour), "", month, "config from 2024-06-13 (today)", false},
+		{"one day", now.Add(-25 * time.Hour), "", month, "config from 2024-06-12 (1 day old)", false},
+		{"with a source", now.AddDate(0, 0, -94), "generated", month, "config from 2024-03-11 (94 days old, generated)", true},
+		{"at the limit", now.Add(-month), "imported", month, "config from 2024-05-14 (30 days old, imported)", false},
+		{"past the limit", now.Add(-month - time.Minute), "", month, "config from 2024-05-14 (30 days old)", true},
+		{"no limit", now.AddDate(-2, 0, 0), "", 0, "config from 2022-06-13 (731 days old)", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, stale := ConfigAge(tt.updatedAt, tt.source, now, tt.maxAge)
+			if text != tt.want || stale != tt.stale {
+				t.Errorf("ConfigAge() = %q, %t; want %q, %t", text, stale, tt.want, tt.stale)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// ConflictItems lists the choices of the conflict screen: keeping each
+// tunnel, noted with its endpoint, handshake age and transfer, then stopping
+// them all.
+func ConflictItems(tunnels []*vpn.ConnectionStatus) []MenuItem {
+	items := make([]MenuItem, 0, len(tunnels)+1)
+	for _, tunnel := range tunnels {
+		var note []string
+		if tunnel.Endpoint != "" {
+			note = append(note, endpointLabel(tunnel))
+		}
+		if tunnel.LastSeen != nil {
+			note = append(note, fmt.Sprintf("handshake %s ago", time.Since(*tunnel.LastSeen).Truncate(time.Second)))
+		} else {
+			note = append(note, "no handshake")
+		}
+		note = append(note, fmt.Sprintf("↓ %s ↑ %s", FormatBytes(tunnel.BytesRx), FormatBytes(tunnel.BytesTx)))
+		name := tunnel.Interface
+		if tunnel.Environment != "" {
+			name = fmt.Sprintf("%s (%s)", tunnel.Environment.DisplayName(), tunnel.Interface)
+		}
+		items = append(items, MenuItem{
+			Label: fmt.Sprintf("Keep %s, stop the others", name),
+			Note:  strings.Join(note, " · "),
+		})
+	}
+	return append(items, MenuItem{Label: "Stop all"})
+}
+
+// RenderConflict draws the screen resolving several tunnels up at once, with
+// the message of the last change, e.g. a tunnel that went down on its own.
+func RenderConflict(tunnels []*vpn.ConnectionStatus, cursor int, message string, width int) string {
+	var b strings.Builder
+	b.WriteString(warningStyle.Render(Truncate(fmt.Sprintf("⚠️ %d VPN interfaces are up at once", len(tunnels)), width)) + "\n\n")
+	b.WriteString(RenderMenu(ConflictItems(tunnels), cursor, true, width))
+	if message != "" {
+		b.WriteString("\n" + warningStyle.Render(Truncate(message, width)) + "\n")
+	}
+	b.WriteString("\n" + disabledStyle.Render("↑/↓ choose · Enter resolve · Esc decide later"))
+	return switcherStyle.BorderStyle(Border()).Render(b.String())
+}
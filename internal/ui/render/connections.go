@@ -0,0 +1,62 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// RenderConnections draws the active connections screen: the established
+// TCP connections env's tunnel carries, grouped by process.
+func RenderConnections(env vpn.Environment, connections []vpn.Connection, err error, loading bool, width int) string {
+	var b strings.Builder
+	line := func(text string) {
+		b.WriteString(Truncate(text, width) + "\n")
+	}
+
+	line("🔌 Active Connections")
+	b.WriteString(Rule(width) + "\n")
+	switch {
+	case loading:
+		line("Listing connections...")
+	case err != nil:
+		b.WriteString(warningStyle.Render(Truncate(fmt.Sprintf("⚠️ %v", err), width)) + "\n")
+	case len(connections) == 0:
+		line(fmt.Sprintf("Nothing is using the %s tunnel", env.DisplayName()))
+	default:
+		line(fmt.Sprintf("%d through %s:", len(connections), env.DisplayName()))
+		for _, group := range vpn.GroupByProcess(connections) {
+			name := group.Process
+			if name == "" {
+				name = "unknown process (run with sudo to see it)"
+			}
+			b.WriteString("\n")
+			line(fmt.Sprintf("%s (%d)", name, len(group.Connections)))
+			for _, connection := range group.Connections {
+				line("  → " + connection.Remote.String())
+			}
+		}
+	}
+
+	b.WriteString("\n")
+	line("r to refresh · Esc to close")
+	return b.String()
+}
+
+// ConnectionSummary names the processes behind connections, busiest first,
+// e.g. "kubectl ×2, psql".
+func ConnectionSummary(connections []vpn.Connection) string {
+	var names []string
+	for _, group := range vpn.GroupByProcess(connections) {
+		name := group.Process
+		if name == "" {
+			name = "unknown"
+		}
+		if n := len(group.Connections); n > 1 {
+			name += fmt.Sprintf(" ×%d", n)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
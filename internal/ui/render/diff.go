@@ -0,0 +1,94 @@
+package render
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/routelabels"
+)
+
+var (
+	diffAddedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#50FA7B"))
+
+	diffRemovedStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FF5F87"))
+
+	diffHunkStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#8BE9FD"))
+)
+
+// RenderDiff draws a unified diff for the config viewport, additions in
+// green and removals in red, each line cut to width. Under a changed
+// AllowedIPs line go the labelled ranges it adds or drops.
+func RenderDiff(lines []config.DiffLine, labels routelabels.Labels, width int) string {
+	if len(lines) == 0 {
+		return "No changes: the installed config is already the same."
+	}
+	sides := map[byte]map[netip.Prefix]bool{'+': {}, '-': {}}
+	for _, line := range lines {
+		if side, ok := sides[line.Kind]; ok {
+			for _, prefix := range allowedIPsOf(line.Text) {
+				side[prefix] = true
+			}
+		}
+	}
+	var rows []string
+	for _, line := range lines {
+		row := Truncate(line.String(), width)
+		switch line.Kind {
+		case '+':
+			row = diffAddedStyle.Render(row)
+		case '-':
+			row = diffRemovedStyle.Render(row)
+		case '@':
+			row = diffHunkStyle.Render(row)
+		}
+		rows = append(rows, row)
+		if note := labelNote(line, sides, labels); note != "" {
+			rows = append(rows, disabledStyle.Render(Truncate(note, width)))
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+// labelNote names the labelled ranges an AllowedIPs line of the diff adds
+// or drops, "" when there are none.
+func labelNote(line config.DiffLine, sides map[byte]map[netip.Prefix]bool, labels routelabels.Labels) string {
+	other, verb := sides['-'], "adds"
+	switch line.Kind {
+	case '+':
+	case '-':
+		other, verb = sides['+'], "drops"
+	default:
+		return ""
+	}
+	var named []string
+	for _, prefix := range allowedIPsOf(line.Text) {
+		if label := labels.Label(prefix); label != "" && !other[prefix] {
+			named = append(named, prefix.String()+" ("+label+")")
+		}
+	}
+	if len(named) == 0 {
+		return ""
+	}
+	return "  ↳ " + verb + " " + strings.Join(named, ", ")
+}
+
+// allowedIPsOf returns the ranges of an AllowedIPs line, none for any other.
+func allowedIPsOf(text string) []netip.Prefix {
+	text, _, _ = strings.Cut(text, "#")
+	key, value, ok := strings.Cut(text, "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(key), "AllowedIPs") {
+		return nil
+	}
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(value, ",") {
+		if prefix, err := netip.ParsePrefix(strings.TrimSpace(item)); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+		}
+	}
+	return prefixes
+}
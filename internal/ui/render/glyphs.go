@@ -0,0 +1,99 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/rivo/uniseg"
+)
+
+// asciiMode is set once at startup for terminals that can't draw rounded
+// borders, box drawing or emoji (old fonts, mosh with a non-UTF-8 locale).
+var asciiMode bool
+
+// SetASCII switches every view to plain ASCII.
+func SetASCII(on bool) {
+	asciiMode = on
+}
+
+// ASCII reports whether views are drawn in plain ASCII.
+func ASCII() bool {
+	return asciiMode
+}
+
+// DetectASCII decides from the environment whether the terminal likely can't
+// draw Unicode: a console TERM without Unicode fonts, or a locale whose
+// charmap isn't UTF-8. No locale at all is taken as UTF-8, which is what
+// terminals default to.
+func DetectASCII(getenv func(string) string) bool {
+	switch getenv("TERM") {
+	case "dumb", "linux", "vt100", "vt102", "vt220", "ansi", "cons25":
+		return true
+	}
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		locale := getenv(name)
+		if locale == "" {
+			continue
+		}
+		charmap := strings.ToLower(strings.ReplaceAll(locale, "-", ""))
+		return !strings.Contains(charmap, "utf8")
+	}
+	return false
+}
+
+// Border is the panel border of the current glyph set.
+func Border() lipgloss.Border {
+	if asciiMode {
+		return lipgloss.ASCIIBorder()
+	}
+	return lipgloss.RoundedBorder()
+}
+
+// asciiGlyphs are the replacements for the glyphs the views use. Anything
+// else outside ASCII becomes "*".
+var asciiGlyphs = map[string]string{
+	"✅": "OK", "❌": "X", "⚠": "!", "➖": "-", "✓": "v", "×": "x",
+	"●": "*", "○": "o", "•": "*", "›": ">", "▸": ">", "▾": "v",
+	"↑": "^", "↓": "v", "←": "<", "→": ">",
+	"…": ".", "—": "-", "–": "-", "─": "-", "━": "=", "│": "|",
+	"🔒": "#", "🔑": "k", "🔧": "+", "🔄": "~", "⏳": "~",
+	"📁": "D", "📂": "D", "📄": "F",
+	"█": "#", "▓": "=", "▒": "+", "▁": "_", "░": ".",
+}
+
+// ToASCII replaces every grapheme outside ASCII in a rendered view with an
+// ASCII stand-in of the same cell width, so layouts computed with the
+// original glyphs stay aligned. ANSI styling is left intact.
+func ToASCII(s string) string {
+	var b strings.Builder
+	state := -1
+	for len(s) > 0 {
+		var cluster string
+		cluster, s, _, state = uniseg.FirstGraphemeClusterInString(s, state)
+		if isASCII(cluster) {
+			b.WriteString(cluster)
+			continue
+		}
+		width := ansi.StringWidth(cluster)
+		// Emoji presentation selectors don't change which glyph it is
+		replacement, ok := asciiGlyphs[strings.TrimRight(cluster, "\uFE0F")]
+		if !ok {
+			replacement = "*"
+		}
+		if len(replacement) > width {
+			replacement = replacement[:width]
+		}
+		b.WriteString(replacement + strings.Repeat(" ", width-len(replacement)))
+	}
+	return b.String()
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+package render
+
+import "strings"
+
+// Hint is one line of the controls panel: the keys and what they do, e.g.
+// {"↑/↓", "Navigate menu"}. A hint without keys is a plain note.
+type Hint struct {
+	Keys   string
+	Action string
+}
+
+// HintGroup is what a panel, view or dialog reports about its keys while it
+// has the focus, under a title such as "Menu + Status".
+type HintGroup struct {
+	Title string
+	Hints []Hint
+}
+
+// Add appends a hint, for building groups whose hints depend on state.
+func (g *HintGroup) Add(keys, action string) {
+	g.Hints = append(g.Hints, Hint{Keys: keys, Action: action})
+}
+
+// RenderHints draws the groups one after the other, a blank line apart:
+//
+//	Menu + Status:
+//	• ↑/↓ - Navigate menu
+//
+// Groups without hints are left out.
+func RenderHints(groups ...HintGroup) string {
+	var blocks []string
+	for _, group := range groups {
+		if len(group.Hints) == 0 {
+			continue
+		}
+		var b strings.Builder
+		b.WriteString(group.Title + ":\n")
+		for _, hint := range group.Hints {
+			if hint.Keys == "" {
+				b.WriteString("• " + hint.Action + "\n")
+			} else {
+				b.WriteString("• " + hint.Keys + " - " + hint.Action + "\n")
+			}
+		}
+		blocks = append(blocks, b.String())
+	}
+	return strings.Join(blocks, "\n")
+}
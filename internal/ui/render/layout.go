@@ -0,0 +1,51 @@
+package render
+
+import "github.com/charmbracelet/lipgloss"
+
+// Panel is one block of a row laid out by Columns.
+type Panel struct {
+	Style  lipgloss.Style
+	Weight int
+}
+
+// Columns splits total terminal columns between panels drawn side by side
+// and returns, for each panel, the value to pass to its style's Width. Each
+// panel's share is proportional to its weight, with the rounding remainder
+// handed out one column at a time from the left, so the rendered panels
+// (borders and margins included) add up to exactly total. Panels too narrow
+// for their frame get the smallest usable width; the row then overflows and
+// the caller should clip it (see Clip).
+func Columns(total int, panels ...Panel) []int {
+	sum := 0
+	for _, p := range panels {
+		sum += p.Weight
+	}
+	widths := make([]int, len(panels))
+	if sum == 0 {
+		return widths
+	}
+
+	shares := make([]int, len(panels))
+	used := 0
+	for i, p := range panels {
+		shares[i] = total * p.Weight / sum
+		used += shares[i]
+	}
+	for i := 0; used < total; i = (i + 1) % len(panels) {
+		shares[i]++
+		used++
+	}
+
+	for i, p := range panels {
+		// lipgloss draws border and margins outside Width, padding inside
+		outside := p.Style.GetHorizontalFrameSize() - p.Style.GetHorizontalPadding()
+		widths[i] = max(shares[i]-outside, p.Style.GetHorizontalPadding()+1)
+	}
+	return widths
+}
+
+// Clip cuts every line of a rendered block to width cells, the last line of
+// defence on terminals too small for the layout.
+func Clip(block string, width int) string {
+	return lipgloss.NewStyle().MaxWidth(width).Render(block)
+}
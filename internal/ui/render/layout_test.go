@@ -0,0 +1,57 @@
+package render
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+func TestColumnsFillTheRow(t *testing.T) {
+	framed := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1)
+	rows := map[string][]Panel{
+		"halves":       {{framed.MarginRight(1), 1}, {framed, 1}},
+		"thirds":       {{framed, 2}, {framed.MarginLeft(1), 1}},
+		"three panels": {{framed, 1}, {framed.MarginLeft(2), 1}, {framed.Padding(0, 3), 1}},
+	}
+	for name, panels := range rows {
+		for total := 40; total <= 200; total++ {
+			widths := Columns(total, panels...)
+			var blocks []string
+			for i, p := range panels {
+				blocks = append(blocks, p.Style.Width(widths[i]).Render(strings.Repeat("x", 200)))
+			}
+			if got := lipgloss.Width(lipgloss.JoinHorizontal(lipgloss.Top, blocks...)); got != total {
+				t.Errorf("%s at %d columns: row is %d wide (widths %v)", name, total, got, widths)
+			}
+		}
+	}
+}
+
+func TestColumnsRemainder(t *testing.T) {
+	plain := lipgloss.NewStyle()
+	// 10 = 3+3+3 and one left over, which goes to the first panel
+	got := Columns(10, Panel{plain, 1}, Panel{plain, 1}, Panel{plain, 1})
+	if want := []int{4, 3, 3}; !slices.Equal(got, want) {
+		t.Errorf("Columns(10) = %v, want %v", got, want)
+	}
+	if got := Columns(10, Panel{plain, 0}); !slices.Equal(got, []int{0}) {
+		t.Errorf("Columns with no weight = %v", got)
+	}
+}
+
+func TestColumnsTooNarrow(t *testing.T) {
+	framed := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1)
+	widths := Columns(6, Panel{framed, 1}, Panel{framed, 1})
+	for _, w := range widths {
+		// The smallest width that still leaves a cell of content
+		if w != 3 {
+			t.Errorf("Columns(6) = %v", widths)
+		}
+	}
+	row := lipgloss.JoinHorizontal(lipgloss.Top, framed.Width(widths[0]).Render("x"), framed.Width(widths[1]).Render("x"))
+	if got := lipgloss.Width(Clip(row, 6)); got != 6 {
+		t.Errorf("clipped row is %d wide", got)
+	}
+}
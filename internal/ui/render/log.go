@@ -0,0 +1,102 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// LogViewportSize is how many entries fit in a log panel body of height
+// lines, leaving room for the title and separator, and with the ASCII glyph
+// set for the scroll indicators and position line.
+func LogViewportSize(height int) int {
+	size := height - 2
+	if ASCII() {
+		size = height - 5
+	}
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+// LogRow is one line of the activity log: an entry, or the header of an
+// operation's group of entries.
+type LogRow struct {
+	Text   string
+	Header bool // drawn without a bullet; Text starts with ▸ or ▾
+	Nested bool // an entry inside an expanded group
+}
+
+// RenderLogViewport draws entries[start:start+size] as a bulleted list with
+// a scrollbar column when not everything fits (the panel title carries the
+// position, see ScrollPosition). With the ASCII glyph set, scroll indicators
+// and a position line stand in for the scrollbar. The entry at index
+// selected is highlighted; -1 highlights none.
+func RenderLogViewport(entries []LogRow, start, size, selected, width int) string {
+	var b strings.Builder
+
+	if len(entries) == 0 {
+		b.WriteString(Truncate("No activity yet. Start by using the VPN controls above.", width) + "\n")
+		return b.String()
+	}
+
+	if start < 0 {
+		start = 0
+	}
+	end := start + size
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	if start > 0 && ASCII() {
+		b.WriteString(Truncate("  ↑ (more entries above)", width) + "\n")
+	}
+
+	// Rows make room for the scrollbar themselves, so their ellipses stay
+	// visible
+	rowWidth := width
+	if !ASCII() && len(entries) > end-start {
+		rowWidth = width - 2
+	}
+	var lines []string
+	for i := start; i < end; i++ {
+		row := entries[i]
+		indent := ""
+		if row.Nested {
+			indent = "  "
+		}
+		entry := strings.TrimSpace(row.Text)
+		switch {
+		case i == selected && row.Header:
+			lines = append(lines, selectedStyle.Render(Truncate(entry, rowWidth)))
+		case i == selected:
+			lines = append(lines, selectedStyle.Render(indent+"› "+Truncate(entry, rowWidth-ansi.StringWidth(indent+"› "))))
+		case row.Header:
+			lines = append(lines, Truncate(entry, rowWidth))
+		default:
+			lines = append(lines, indent+"• "+Truncate(entry, rowWidth-ansi.StringWidth(indent+"• ")))
+		}
+	}
+	for _, line := range WithScrollbar(lines, len(entries), start, width) {
+		b.WriteString(line + "\n")
+	}
+	if !ASCII() {
+		return b.String()
+	}
+
+	if end < len(entries) {
+		b.WriteString(Truncate("  ↓ (more entries below)", width) + "\n")
+	}
+
+	if len(entries) > size {
+		indicator := fmt.Sprintf("Showing %d-%d of %d entries", start+1, end, len(entries))
+		if ansi.StringWidth(indicator) > width {
+			indicator = fmt.Sprintf("%d-%d/%d", start+1, end, len(entries))
+		}
+		b.WriteString(Truncate(indicator, width))
+	}
+
+	return b.String()
+}
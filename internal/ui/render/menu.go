@@ -0,0 +1,71 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	selectedStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#007ACC"))
+
+	disabledStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#6272A4"))
+)
+
+// MenuItem is one entry of the main menu along with the state that decides
+// how it is drawn.
+type MenuItem struct {
+	Label    string
+	Disabled bool
+	// DisabledNote replaces "disabled" after the label of a disabled item
+	DisabledNote string
+	Loading      bool // an operation started from this item is running
+	Queued       bool // an operation of this item waits for the running one
+	// Note is drawn dimmed on its own line below the label, or in the
+	// warning color when NoteWarn is set.
+	Note     string
+	NoteWarn bool
+}
+
+// RenderMenu draws items one per line with a ">" cursor. The cursor is only
+// shown, and the selected item only highlighted, when focused is true.
+func RenderMenu(items []MenuItem, cursor int, focused bool, width int) string {
+	var b strings.Builder
+	for i, item := range items {
+		marker := " "
+		if cursor == i && focused {
+			marker = ">"
+		}
+
+		var line string
+		switch {
+		case item.Disabled:
+			note := item.DisabledNote
+			if note == "" {
+				note = "disabled"
+			}
+			line = disabledStyle.Render(Truncate(fmt.Sprintf("%s %s (%s)", marker, item.Label, note), width))
+		case item.Loading:
+			line = Truncate(fmt.Sprintf("%s %s (loading...)", marker, item.Label), width)
+		case item.Queued:
+			line = Truncate(fmt.Sprintf("%s %s (queued)", marker, item.Label), width)
+		case cursor == i && focused:
+			line = selectedStyle.Render(Truncate(fmt.Sprintf("%s %s", marker, item.Label), width))
+		default:
+			line = Truncate(fmt.Sprintf("%s %s", marker, item.Label), width)
+		}
+		b.WriteString(line + "\n")
+
+		if item.Note != "" {
+			style := disabledStyle
+			if item.NoteWarn {
+				style = warningStyle
+			}
+			b.WriteString(style.Render(Truncate("    "+item.Note, width)) + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// RenderOverview draws the network overview screen. While probing is set the
+// previous overview (if any) stays visible under a progress note.
+func RenderOverview(overview *vpn.NetworkOverview, probing bool, width int) string {
+	var b strings.Builder
+	line := func(text string) {
+		b.WriteString(Truncate(text, width) + "\n")
+	}
+	warn := func(text string) {
+		b.WriteString(warningStyle.Render(Truncate(text, width)) + "\n")
+	}
+
+	line("🌐 Network Overview")
+	b.WriteString(Rule(width) + "\n")
+	if probing {
+		line("Probing endpoints...")
+	}
+	if overview == nil {
+		return b.String()
+	}
+
+	local := overview.Local
+	switch {
+	case local.Address == "":
+		warn("Local: offline (no default route)")
+	default:
+		line(fmt.Sprintf("Local: %s on %s", local.Address, local.Interface))
+	}
+	if local.OfficeSubnet != "" {
+		line(fmt.Sprintf("On office network %s", local.OfficeSubnet))
+	}
+	if !overview.UDPWorks {
+		warn("UDP appears blocked on this network")
+	}
+
+	switch {
+	case overview.StatusErr != nil:
+		warn(fmt.Sprintf("Active tunnel: unknown (%v)", overview.StatusErr))
+	case overview.Active != nil && overview.Active.Connected:
+		line(fmt.Sprintf("Active tunnel: %s (%s)", overview.Active.Environment.DisplayName(), overview.Active.Interface))
+	default:
+		line("Active tunnel: none")
+	}
+
+	for _, env := range overview.Environments {
+		b.WriteString("\n")
+		line(env.Environment.DisplayName())
+		if env.Installed {
+			line(fmt.Sprintf("  Endpoint: %s", env.Endpoint))
+			line(fmt.Sprintf("  Routes: %d", len(env.Routes)))
+			if env.Reach.HostReachable {
+				line(fmt.Sprintf("  Gateway latency: %s", env.Reach.Latency.Round(time.Millisecond)))
+			}
+		}
+		verdict := env.Verdict(overview.UDPWorks)
+		if verdict == "reachable" {
+			line("  ✅ " + verdict)
+		} else {
+			warn("  ⚠️ " + verdict)
+		}
+	}
+
+	b.WriteString("\n")
+	line(fmt.Sprintf("Checked %s · r to refresh · Esc to close", Ago(time.Since(overview.Collected))))
+	return b.String()
+}
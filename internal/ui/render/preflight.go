@@ -0,0 +1,45 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"tui-wireguard-vpn/internal/doctor"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// RenderPreflight draws the preflight screen: env's checklist and the
+// overall verdict. While running is set the previous run of env (if any)
+// stays visible under a progress note.
+func RenderPreflight(env vpn.Environment, result *vpn.PreflightResult, running bool, width int) string {
+	var b strings.Builder
+	line := func(text string) {
+		b.WriteString(Truncate(text, width) + "\n")
+	}
+
+	line(fmt.Sprintf("🛫 Preflight: %s", env.DisplayName()))
+	b.WriteString(Rule(width) + "\n")
+	if running {
+		line(fmt.Sprintf("Checking (at most %s)...", vpn.PreflightTimeout))
+	}
+	if result != nil {
+		for _, check := range result.Checks {
+			text := fmt.Sprintf("%s %s: %s", check.Status.Symbol(), check.Name, check.Detail)
+			if check.Status == doctor.Fail {
+				b.WriteString(warningStyle.Render(Truncate(text, width)) + "\n")
+			} else {
+				line(text)
+			}
+		}
+		b.WriteString("\n")
+		if result.Failed() {
+			b.WriteString(warningStyle.Render(Truncate(result.Summary(), width)) + "\n")
+		} else {
+			line(result.Summary())
+		}
+	}
+
+	b.WriteString("\n")
+	line("r to check again · Esc to close")
+	return b.String()
+}
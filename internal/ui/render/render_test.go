@@ -0,0 +1,130 @@
+package render
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenWidths are the panel widths each helper is drawn at: a wide
+// terminal down to one narrower than most labels.
+var goldenWidths = []int{80, 40, 24, 12}
+
+// golden compares what render draws at each of goldenWidths with
+// testdata/<name>.golden, and checks no line is wider than it may be.
+func golden(t *testing.T, name string, render func(width int) string) {
+	t.Helper()
+	var b strings.Builder
+	for _, width := range goldenWidths {
+		out := render(width)
+		for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+			if w := ansi.StringWidth(line); w > width {
+				t.Errorf("width %d: %q is %d cells wide", width, line, w)
+			}
+		}
+		fmt.Fprintf(&b, "-- width %d --\n%s", width, out)
+		if !strings.HasSuffix(out, "\n") {
+			b.WriteString("\n")
+		}
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(want) {
+		t.Errorf("%s differs from %s (go test -update rewrites it):\n%s", name, path, b.String())
+	}
+}
+
+func TestRenderMenuGolden(t *testing.T) {
+	items := []MenuItem{
+		{Label: "🔒 Start Production VPN"},
+		{Label: "🔧 Start Non-Production VPN", Loading: true},
+		{Label: "🛑 Stop VPN", Queued: true},
+		{Label: "🔄 Update Config", Disabled: true, DisabledNote: "no config file selected"},
+		{Label: "📋 View Active Config", Note: "julo-prod.conf, installed 3 days ago"},
+		{Label: "🩺 Doctor", Note: "2 problems found", NoteWarn: true},
+	}
+	golden(t, "RenderMenu", func(width int) string {
+		return RenderMenu(items, 0, true, width)
+	})
+}
+
+func TestRenderLogViewportGolden(t *testing.T) {
+	entries := []LogRow{
+		{Text: "▾ starting Production", Header: true},
+		{Text: "[wg-quick] ip link add julo-prod type wireguard", Nested: true},
+		{Text: "[wg-quick] wg setconf julo-prod /dev/fd/63", Nested: true},
+		{Text: "✅ Connected to Production (julo-prod) — handshake after 1.2s", Nested: true},
+		{Text: "▸ stopping VPN (3 lines)", Header: true},
+		{Text: "⚠️ Multiple VPN interfaces are up: julo-prod, julo-nonprod"},
+		{Text: "Config updated from ~/Downloads/julo-nonprod-user.conf"},
+	}
+	golden(t, "RenderLogViewport", func(width int) string {
+		return RenderLogViewport(entries, 1, 4, 3, width)
+	})
+}
+
+func TestRenderLogViewportASCIIGolden(t *testing.T) {
+	SetASCII(true)
+	t.Cleanup(func() { SetASCII(false) })
+	entries := []LogRow{
+		{Text: "VPN started: Production"},
+		{Text: "Status: connected to julo-prod, endpoint 34.101.166.184:51820"},
+		{Text: "VPN stopped"},
+	}
+	golden(t, "RenderLogViewportASCII", func(width int) string {
+		return RenderLogViewport(entries, 1, 1, -1, width)
+	})
+}
+
+func TestRenderStatusGolden(t *testing.T) {
+	status := &vpn.ConnectionStatus{
+		Connected:    true,
+		Environment:  vpn.Production,
+		Interface:    "julo-prod",
+		Endpoint:     "34.101.166.184:51820",
+		EndpointHost: "vpn-prod.julo.co.id",
+		EgressPinned: "wlp2s0",
+		Egress:       "eth0",
+		BytesRx:      7319060,
+		BytesTx:      24568012,
+		Note:         "details unavailable (unrecognized wg output)",
+	}
+	rate := &vpn.TransferRate{Rx: 2048, Tx: 512}
+	golden(t, "RenderStatus", func(width int) string {
+		return RenderStatus(status, rate, width) + RenderStatus(nil, nil, width)
+	})
+}
+
+func TestRenderStatusErrorGolden(t *testing.T) {
+	errs := []error{
+		fmt.Errorf("%w: sudo: wg: command not found", vpn.ErrWireGuardNotInstalled),
+		fmt.Errorf("%w: sudo: a password is required", vpn.ErrPermissionDenied),
+		errors.New("wg failed: Unable to list interfaces: Protocol not supported"),
+	}
+	golden(t, "RenderStatusError", func(width int) string {
+		var b strings.Builder
+		for _, err := range errs {
+			b.WriteString(RenderStatusError(err, width))
+		}
+		return b.String()
+	})
+}
@@ -0,0 +1,64 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"tui-wireguard-vpn/internal/routelabels"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// RenderRoutes draws the routes screen: the AllowedIPs env's tunnel routes,
+// those carrying connections first, with the ranges reaching public
+// addresses marked as the likely carriers of unintended traffic. Ranges
+// show their labels; the one at cursor is selected, and editor, when not
+// empty, is the label being typed for it.
+func RenderRoutes(env vpn.Environment, usages []vpn.RouteUsage, labels routelabels.Labels, cursor int, editor string, err error, loading bool, width int) string {
+	var b strings.Builder
+	line := func(text string) {
+		b.WriteString(Truncate(text, width) + "\n")
+	}
+
+	line("🧭 Routes")
+	b.WriteString(Rule(width) + "\n")
+	switch {
+	case loading:
+		line("Reading routes...")
+	case err != nil:
+		b.WriteString(warningStyle.Render(Truncate(fmt.Sprintf("⚠️ %v", err), width)) + "\n")
+	case len(usages) == 0:
+		line(fmt.Sprintf("The %s tunnel routes nothing", env.DisplayName()))
+	default:
+		line(fmt.Sprintf("AllowedIPs of %s:", env.DisplayName()))
+		b.WriteString("\n")
+		for i, usage := range usages {
+			text := usage.Prefix.String()
+			if label := labels.Label(usage.Prefix); label != "" {
+				text += " (" + label + ")"
+			}
+			if n := len(usage.Connections); n > 0 {
+				text += fmt.Sprintf("  %d connection(s): %s", n, ConnectionSummary(usage.Connections))
+			}
+			if usage.Broad {
+				text += "  (public range)"
+			}
+			switch {
+			case i == cursor:
+				b.WriteString(selectedStyle.Render(Truncate("›  "+text, width)) + "\n")
+			case usage.Broad:
+				b.WriteString(warningStyle.Render(Truncate("⚠️ "+text, width)) + "\n")
+			default:
+				line("   " + text)
+			}
+		}
+	}
+
+	b.WriteString("\n")
+	if editor != "" {
+		b.WriteString(editor + "\n")
+		line("Enter to save (empty removes the label) · Esc to cancel")
+		return b.String()
+	}
+	line("↑/↓ select · a to label · r to refresh · Esc to close")
+	return b.String()
+}
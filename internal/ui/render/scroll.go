@@ -0,0 +1,61 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ScrollThumb places the thumb of a scrollbar track rows tall for a list of
+// total rows, visible of them shown from offset. The thumb's length is the
+// visible share of the list (at least one row), and it touches the top of the
+// track at offset 0 and the bottom at the last offset. A list that fits
+// entirely gets a thumb filling the track.
+func ScrollThumb(total, visible, offset, track int) (start, length int) {
+	if track <= 0 {
+		return 0, 0
+	}
+	if total <= visible || visible <= 0 {
+		return 0, track
+	}
+	length = min(max((track*visible+total/2)/total, 1), track)
+	maxOffset := total - visible
+	offset = min(max(offset, 0), maxOffset)
+	free := track - length
+	start = (offset*free + maxOffset/2) / maxOffset
+	return start, length
+}
+
+// WithScrollbar draws a scrollbar column at the right edge of rows, the
+// visible rows of a list of total starting at offset: each row is cut or
+// padded to width-2 cells, followed by a space and a track (░) or thumb (█)
+// cell. Lists that fit, and the ASCII glyph set, get the rows unchanged; the
+// views keep their text indicators there.
+func WithScrollbar(rows []string, total, offset, width int) []string {
+	if ASCII() || total <= len(rows) || width < 3 {
+		return rows
+	}
+	start, length := ScrollThumb(total, len(rows), offset, len(rows))
+	out := make([]string, len(rows))
+	for i, row := range rows {
+		row = Truncate(row, width-2)
+		row += strings.Repeat(" ", width-2-ansi.StringWidth(row))
+		bar := "░"
+		if i >= start && i < start+length {
+			bar = "█"
+		}
+		out[i] = row + " " + bar
+	}
+	return out
+}
+
+// ScrollPosition is the readout of a list's position for a panel title,
+// e.g. "12/87" for the 12th of 87 rows; "" when the list fits in visible
+// rows.
+func ScrollPosition(current, total, visible int) string {
+	if total <= visible || total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", min(max(current, 1), total), total)
+}
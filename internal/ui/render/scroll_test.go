@@ -0,0 +1,76 @@
+package render
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestScrollThumb(t *testing.T) {
+	tests := []struct {
+		name                          string
+		total, visible, offset, track int
+		start, length                 int
+	}{
+		{"shorter than the viewport", 5, 10, 0, 10, 0, 10},
+		{"exactly the viewport", 10, 10, 0, 10, 0, 10},
+		{"top", 100, 10, 0, 10, 0, 1},
+		{"middle", 100, 10, 45, 10, 5, 1},
+		{"scrolled to the end", 100, 10, 90, 10, 9, 1},
+		{"past the end", 100, 10, 200, 10, 9, 1},
+		{"before the top", 100, 10, -5, 10, 0, 1},
+		{"half visible", 20, 10, 5, 10, 3, 5},
+		{"half visible, at the end", 20, 10, 10, 10, 5, 5},
+		{"short track, at the end", 30, 10, 20, 5, 3, 2},
+		{"1-row viewport", 50, 1, 0, 1, 0, 1},
+		{"1-row viewport, at the end", 50, 1, 49, 1, 0, 1},
+		{"no track", 100, 10, 0, 0, 0, 0},
+		{"nothing visible", 100, 0, 0, 10, 0, 10},
+	}
+	for _, tt := range tests {
+		start, length := ScrollThumb(tt.total, tt.visible, tt.offset, tt.track)
+		if start != tt.start || length != tt.length {
+			t.Errorf("%s: ScrollThumb(%d, %d, %d, %d) = %d, %d; want %d, %d",
+				tt.name, tt.total, tt.visible, tt.offset, tt.track, start, length, tt.start, tt.length)
+		}
+		if start < 0 || start+length > tt.track {
+			t.Errorf("%s: the thumb at %d+%d leaves the %d-row track", tt.name, start, length, tt.track)
+		}
+	}
+}
+
+func TestWithScrollbar(t *testing.T) {
+	rows := []string{"first entry", "b"}
+	if got, want := WithScrollbar(rows, 4, 2, 8), []string{"first… ░", "b      █"}; !slices.Equal(got, want) {
+		t.Errorf("WithScrollbar() = %q, want %q", got, want)
+	}
+	if got := WithScrollbar(rows, 2, 0, 8); !slices.Equal(got, rows) {
+		t.Errorf("WithScrollbar() = %q for a list that fits", got)
+	}
+	if got := WithScrollbar(rows, 4, 0, 2); !slices.Equal(got, rows) {
+		t.Errorf("WithScrollbar() = %q with no room for a bar", got)
+	}
+
+	SetASCII(true)
+	t.Cleanup(func() { SetASCII(false) })
+	if got := WithScrollbar(rows, 4, 2, 8); !slices.Equal(got, rows) {
+		t.Errorf("WithScrollbar() = %q in ASCII", got)
+	}
+}
+
+func TestScrollPosition(t *testing.T) {
+	tests := []struct {
+		current, total, visible int
+		want                    string
+	}{
+		{12, 87, 10, "12/87"},
+		{0, 87, 10, "1/87"},
+		{100, 87, 10, "87/87"},
+		{3, 8, 10, ""},
+		{0, 0, 10, ""},
+	}
+	for _, tt := range tests {
+		if got := ScrollPosition(tt.current, tt.total, tt.visible); got != tt.want {
+			t.Errorf("ScrollPosition(%d, %d, %d) = %q, want %q", tt.current, tt.total, tt.visible, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+var (
+	connectedStatusStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FAFAFA")).
+				Background(lipgloss.Color("#28A745")).
+				Padding(1, 2)
+
+	disconnectedStatusStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FAFAFA")).
+				Background(lipgloss.Color("#DC3545")).
+				Padding(1, 2)
+
+	unknownStatusStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FAFAFA")).
+				Background(lipgloss.Color("#D97706")).
+				Padding(1, 2)
+
+	checkingStatusStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#FAFAFA")).
+				Background(lipgloss.Color("#6272A4")).
+				Padding(1, 2)
+
+	warningStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FFB86C"))
+
+	overdueStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#F1FA8C"))
+
+	staleStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#FF5555"))
+)
+
+// RenderStatus draws the connection badge followed by endpoint, handshake
+// and transfer details when connected, with the transfer rate when one is
+// known. A nil status renders as disconnected.
+func RenderStatus(status *vpn.ConnectionStatus, rate *vpn.TransferRate, width int) string {
+	var b strings.Builder
+
+	if status == nil || !status.Connected {
+		text := Truncate("Status: Disconnected", width-disconnectedStatusStyle.GetHorizontalPadding())
+		b.WriteString(disconnectedStatusStyle.Render(text) + "\n")
+		return b.String()
+	}
+
+	envName := "Unknown"
+	if status.Environment != "" {
+		envName = status.Environment.DisplayName()
+	}
+	text := fmt.Sprintf("Status: Connected to %s", envName)
+	if status.Interface != "" {
+		text += fmt.Sprintf(" (%s)", status.Interface)
+	}
+	text = Truncate(text, width-connectedStatusStyle.GetHorizontalPadding())
+	b.WriteString(connectedStatusStyle.Render(text) + "\n")
+
+	if status.Note != "" {
+		b.WriteString(warningStyle.Render(Truncate("⚠️ "+status.Note, width)) + "\n")
+	}
+	if status.Endpoint != "" {
+		b.WriteString(Truncate("Endpoint: "+endpointLabel(status), width) + "\n")
+	}
+	switch {
+	case status.EgressPinned == "":
+	case status.Egress == status.EgressPinned:
+		b.WriteString(Truncate("Egress: via "+status.Egress, width) + "\n")
+	case status.Egress != "":
+		b.WriteString(warningStyle.Render(Truncate(fmt.Sprintf("⚠️ Egress: via %s, not the pinned %s", status.Egress, status.EgressPinned), width)) + "\n")
+	default:
+		b.WriteString(Truncate(fmt.Sprintf("Egress: pinned to %s (not observed)", status.EgressPinned), width) + "\n")
+	}
+	if status.LastSeen != nil {
+		b.WriteString(handshakeLine(vpn.EstimateHandshake(*status.LastSeen, rate.Idle(), time.Now()), width) + "\n")
+	}
+	if status.BytesRx > 0 || status.BytesTx > 0 {
+		line := fmt.Sprintf("Data: ↓ %s  ↑ %s", FormatBytes(status.BytesRx), FormatBytes(status.BytesTx))
+		if rate != nil {
+			line += fmt.Sprintf("  (↓ %s ↑ %s)", FormatRate(rate.Rx), FormatRate(rate.Tx))
+		}
+		b.WriteString(Truncate(line, width) + "\n")
+	}
+
+	return b.String()
+}
+
+// handshakeLine is the handshake age with a countdown to the next rekey,
+// yellow once it is overdue and red once the tunnel counts as unhealthy.
+func handshakeLine(estimate vpn.HandshakeEstimate, width int) string {
+	line := fmt.Sprintf("Last Handshake: %s ago", estimate.Age.Truncate(time.Second))
+	switch estimate.State {
+	case vpn.HandshakeIdle:
+		return Truncate(line+" · idle (no traffic, no rekey due)", width)
+	case vpn.HandshakeExpected:
+		return Truncate(fmt.Sprintf("%s · next in ~%s", line, estimate.Next.Round(time.Second)), width)
+	case vpn.HandshakeOverdue:
+		return overdueStyle.Render(Truncate(fmt.Sprintf("%s · next overdue by %s", line, estimate.Next.Truncate(time.Second)), width))
+	}
+	return staleStyle.Render(Truncate(fmt.Sprintf("⚠️ %s · stale (healthy under %s)", line, vpn.DefaultHandshakeAge), width))
+}
+
+// RenderStatusError is the status badge when the status check failed: the
+// tunnel state is unknown, not disconnected. The error follows, and a way
+// out when the failure is one StatusErrorHint knows.
+func RenderStatusError(err error, width int) string {
+	var b strings.Builder
+	text := Truncate("Status: Unknown", width-unknownStatusStyle.GetHorizontalPadding())
+	b.WriteString(unknownStatusStyle.Render(text) + "\n")
+	b.WriteString(warningStyle.Render(Truncate("⚠️ "+err.Error(), width)) + "\n")
+	if hint := StatusErrorHint(err); hint != "" {
+		b.WriteString(Truncate("→ "+hint, width) + "\n")
+	}
+	return b.String()
+}
+
+// StatusErrorHint is the remediation for a failed status check, "" when
+// there is no specific one.
+func StatusErrorHint(err error) string {
+	switch {
+	case errors.Is(err, vpn.ErrWireGuardNotInstalled):
+		return "install wireguard-tools (e.g. sudo apt install wireguard-tools)"
+	case errors.Is(err, vpn.ErrPermissionDenied):
+		return "run with sudo: sudo tui-wireguard-vpn"
+	}
+	return ""
+}
+
+// RenderStatusChecking is the status badge shown before the first status
+// check has come back.
+func RenderStatusChecking(width int) string {
+	text := Truncate("Status: checking…", width-disconnectedStatusStyle.GetHorizontalPadding())
+	return checkingStatusStyle.Render(text) + "\n"
+}
+
+// RenderMiniStatus is the single status line of mini mode. It deliberately
+// names only the environment: no interface, endpoint or transfer data.
+func RenderMiniStatus(status *vpn.ConnectionStatus, busy bool) string {
+	switch {
+	case busy:
+		return "● Working…"
+	case status == nil || !status.Connected:
+		return "○ Disconnected"
+	}
+	envName := "Unknown"
+	if status.Environment != "" {
+		envName = status.Environment.DisplayName()
+	}
+	return "● Connected — " + envName
+}
+
+// RenderSyncStatus is the remote sync line of the status details, e.g.
+// "Synced: 3 days ago". After a failed sync it warns that the installed
+// files are in use.
+func RenderSyncStatus(lastSync time.Time, failed bool, width int) string {
+	synced := "never"
+	if !lastSync.IsZero() {
+		synced = Ago(time.Since(lastSync))
+	}
+	if failed {
+		return warningStyle.Render(Truncate(fmt.Sprintf("⚠️ Offline, using installed files (last synced %s)", synced), width))
+	}
+	return Truncate("Synced: "+synced, width)
+}
+
+// Names of reachable hosts listed before the rest are counted
+const reachableShown = 3
+
+// RenderReachableHosts lists the ssh hosts each environment reaches, e.g.
+// "via prod: db-primary, bastion-prod (2 more)". Environments without hosts
+// are left out; nothing is drawn when none has any.
+func RenderReachableHosts(hosts map[vpn.Environment][]string, width int) string {
+	var b strings.Builder
+	for _, env := range vpn.Environments() {
+		names := hosts[env]
+		if len(names) == 0 {
+			continue
+		}
+		line := "via " + string(env) + ": " + strings.Join(names[:min(len(names), reachableShown)], ", ")
+		if len(names) > reachableShown {
+			line += fmt.Sprintf(" (%d more)", len(names)-reachableShown)
+		}
+		b.WriteString(Truncate("  "+line, width) + "\n")
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return Truncate("Reachable hosts:", width) + "\n" + b.String()
+}
+
+// Ago renders an elapsed duration the way people say it: "just now",
+// "5 minutes ago", "3 days ago".
+func Ago(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	}
+	return plural(int(d/(24*time.Hour)), "day")
+}
+
+// endpointLabel shows "hostname (ip)" when the gateway hostname is known and
+// the bare ip:port otherwise.
+func endpointLabel(status *vpn.ConnectionStatus) string {
+	if status.EndpointHost == "" {
+		return status.Endpoint
+	}
+	ip := status.Endpoint
+	if host, _, err := net.SplitHostPort(status.Endpoint); err == nil {
+		ip = host
+	}
+	return fmt.Sprintf("%s (%s)", status.EndpointHost, ip)
+}
+
+// FormatBytes renders a byte count with binary units, e.g. "1.5 MB".
+func FormatBytes(bytes uint64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := uint64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+// FormatRate renders bytes per second with IEC units, e.g. "1.2 MiB/s",
+// "240 KiB/s"; rates under 1 KiB/s are whole bytes.
+func FormatRate(rate float64) string {
+	const unit = 1024
+	if rate < unit {
+		return fmt.Sprintf("%.0f B/s", max(rate, 0))
+	}
+	exp := 0
+	for rate /= unit; rate >= unit && exp < 5; rate /= unit {
+		exp++
+	}
+	if rate < 10 {
+		return fmt.Sprintf("%.1f %ciB/s", rate, "KMGTPE"[exp])
+	}
+	return fmt.Sprintf("%.0f %ciB/s", rate, "KMGTPE"[exp])
+}
@@ -0,0 +1,45 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+
+	"tui-wireguard-vpn/internal/ops"
+)
+
+// StepGlyph is the checklist mark of a step: a tick, a cross, an arrow for
+// the running one, a circle for those to come and a dash for skipped ones.
+func StepGlyph(status ops.StepStatus) string {
+	switch status {
+	case ops.StepRunning:
+		return "▸"
+	case ops.StepDone:
+		return "✓"
+	case ops.StepFailed:
+		return "×"
+	case ops.StepSkipped:
+		return "–"
+	}
+	return "○"
+}
+
+// RenderSteps draws an operation's steps as a checklist under its title,
+// the failed step followed by its error.
+func RenderSteps(steps *ops.Steps, width int) string {
+	var b strings.Builder
+	b.WriteString(Truncate(steps.Title, width) + "\n")
+	for _, step := range steps.Steps {
+		line := fmt.Sprintf("  %s %s", StepGlyph(step.Status), step.Name)
+		switch step.Status {
+		case ops.StepRunning:
+			b.WriteString(selectedStyle.Render(Truncate(line+"…", width)) + "\n")
+		case ops.StepFailed:
+			b.WriteString(warningStyle.Render(Truncate(fmt.Sprintf("%s: %v", line, step.Err), width)) + "\n")
+		case ops.StepPending, ops.StepSkipped:
+			b.WriteString(disabledStyle.Render(Truncate(line, width)) + "\n")
+		default:
+			b.WriteString(Truncate(line, width) + "\n")
+		}
+	}
+	return b.String()
+}
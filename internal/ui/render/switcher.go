@@ -0,0 +1,25 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var switcherStyle = lipgloss.NewStyle().
+	BorderForeground(lipgloss.Color("#007ACC")).
+	Padding(0, 2)
+
+// RenderSwitcher draws the environment switcher popup: a small bordered
+// list of profiles with their readiness, and the message of the last pick
+// that went nowhere, if any.
+func RenderSwitcher(items []MenuItem, cursor int, message string, width int) string {
+	var b strings.Builder
+	b.WriteString("🔀 Switch environment\n\n")
+	b.WriteString(RenderMenu(items, cursor, true, width))
+	if message != "" {
+		b.WriteString("\n" + warningStyle.Render(Truncate(message, width)) + "\n")
+	}
+	b.WriteString("\n" + disabledStyle.Render("↑/↓ choose · Enter switch · Esc close"))
+	return switcherStyle.BorderStyle(Border()).Render(b.String())
+}
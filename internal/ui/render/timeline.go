@@ -0,0 +1,130 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"tui-wireguard-vpn/internal/stats"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+var (
+	prodSlotStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#DC3545"))
+	nonprodSlotStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#007ACC"))
+	profileSlotStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#BD93F9"))
+	disconnectedSlotStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#6272A4"))
+	unknownSlotStyle = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("#44475A"))
+)
+
+// slotsPerHour is how many timeline cells make an hour.
+const slotsPerHour = int(time.Hour / stats.SlotDuration)
+
+// RenderTimeline draws a day's connections, a cell per stats.SlotDuration:
+// rows of as many hours as fit the width, labelled at the hour marks, then a
+// legend with the time spent in each state.
+func RenderTimeline(timeline *stats.Timeline, err error, width int) string {
+	var b strings.Builder
+	line := func(text string) {
+		b.WriteString(Truncate(text, width) + "\n")
+	}
+
+	if timeline == nil {
+		line("📅 Connections Today")
+		b.WriteString(Rule(width) + "\n")
+		if err != nil {
+			b.WriteString(warningStyle.Render(Truncate(fmt.Sprintf("Could not read the history: %v", err), width)) + "\n")
+		} else {
+			line("Reading the history...")
+		}
+		return b.String()
+	}
+
+	line(fmt.Sprintf("📅 Connections Today (%s)", timeline.Start.Format("Mon 2 Jan")))
+	b.WriteString(Rule(width) + "\n")
+	hours := 1
+	for _, h := range []int{24, 12, 8, 6, 4, 3, 2} {
+		if h*slotsPerHour <= width {
+			hours = h
+			break
+		}
+	}
+	perRow := hours * slotsPerHour
+	for row := 0; row < len(timeline.Slots); row += perRow {
+		slots := timeline.Slots[row:min(row+perRow, len(timeline.Slots))]
+		labels := []byte(strings.Repeat(" ", len(slots)+1))
+		var cells strings.Builder
+		for i, state := range slots {
+			at := timeline.Start.Add(time.Duration(row+i) * stats.SlotDuration)
+			if at.Minute() == 0 && i+2 <= len(labels) {
+				copy(labels[i:], fmt.Sprintf("%02d", at.Hour()))
+			}
+			glyph, style := slotGlyph(state)
+			cells.WriteString(style.Render(glyph))
+		}
+		line(strings.TrimRight(string(labels), " "))
+		b.WriteString(cells.String() + "\n")
+	}
+
+	b.WriteString("\n")
+	var states []string
+	for _, env := range vpn.Environments() {
+		states = append(states, string(env))
+	}
+	states = append(states, stats.StateDisconnected, stats.StateUnknown)
+	for _, state := range states {
+		spent, ok := timeline.Totals[state]
+		if !ok {
+			continue
+		}
+		glyph, style := slotGlyph(state)
+		b.WriteString(style.Render(glyph) + " " + Truncate(fmt.Sprintf("%s %s", stateName(state), hoursMinutes(spent)), width-2) + "\n")
+	}
+	b.WriteString("\n")
+	line(fmt.Sprintf("One cell is %d minutes · r to reload · Esc to close", int(stats.SlotDuration.Minutes())))
+	return b.String()
+}
+
+// slotGlyph is how a timeline draws a state. The glyphs differ as well as
+// the colors, for terminals without them.
+func slotGlyph(state string) (string, lipgloss.Style) {
+	switch state {
+	case "":
+		return " ", lipgloss.NewStyle()
+	case stats.StateUnknown:
+		return "░", unknownSlotStyle
+	case stats.StateDisconnected:
+		return "▁", disconnectedSlotStyle
+	case string(vpn.Production):
+		return "█", prodSlotStyle
+	case string(vpn.NonProduction):
+		return "▓", nonprodSlotStyle
+	}
+	return "▒", profileSlotStyle
+}
+
+func stateName(state string) string {
+	switch state {
+	case stats.StateUnknown:
+		return "Unknown (app not running)"
+	case stats.StateDisconnected:
+		return "Disconnected"
+	}
+	return vpn.Environment(state).DisplayName()
+}
+
+// hoursMinutes renders d as "2h05m", or "40m" under an hour.
+func hoursMinutes(d time.Duration) string {
+	minutes := int(d.Round(time.Minute).Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+}
@@ -0,0 +1,115 @@
+// Package render holds the pure string-building helpers behind the main
+// dashboard panels. Every helper takes the space it may use and never
+// returns a line wider than that, measured in terminal cells.
+package render
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Ellipsis marks text cut short by Truncate.
+const Ellipsis = "…"
+
+// ContentWidth is the width left for text inside a panel rendered with
+// style.Width(width). lipgloss counts padding inside Width and draws the
+// border and margins outside of it.
+func ContentWidth(style lipgloss.Style, width int) int {
+	w := width - style.GetHorizontalPadding()
+	if w < 1 {
+		return 1
+	}
+	return w
+}
+
+// Truncate shortens s to at most width cells, ending in Ellipsis when cut.
+// Wide runes (emoji, CJK) and ANSI styling are measured the way the terminal
+// draws them, so a cut never splits a rune or leaves a dangling escape.
+func Truncate(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if ansi.StringWidth(s) <= width {
+		return s
+	}
+	return ansi.Truncate(s, width, Ellipsis)
+}
+
+// Rule is a horizontal separator exactly width cells wide.
+func Rule(width int) string {
+	if width < 1 {
+		width = 1
+	}
+	return strings.Repeat("─", width)
+}
+
+// SanitizeName makes a file name safe to draw: bytes that aren't valid UTF-8
+// (e.g. Windows-1252 names) and control characters become "�". Use it for
+// display only; the original name is what must be opened.
+func SanitizeName(name string) string {
+	if utf8.ValidString(name) && !strings.ContainsFunc(name, unicode.IsControl) {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); {
+		r, size := utf8.DecodeRuneInString(name[i:])
+		if r == utf8.RuneError || unicode.IsControl(r) {
+			r = utf8.RuneError
+		}
+		b.WriteRune(r)
+		i += size
+	}
+	return b.String()
+}
+
+// FileName sanitizes name and, when it is wider than width cells, cuts the
+// middle out so both the start and the extension stay visible:
+// "very-long-vendor-…-name.conf".
+func FileName(name string, width int) string {
+	name = SanitizeName(name)
+	if width <= 0 {
+		return ""
+	}
+	if ansi.StringWidth(name) <= width {
+		return name
+	}
+
+	ext := filepath.Ext(name)
+	if ext == name || ansi.StringWidth(ext)+2 > width || len(ext) > 10 {
+		ext = ""
+	}
+	stem := []rune(strings.TrimSuffix(name, ext))
+	avail := width - ansi.StringWidth(ext) - ansi.StringWidth(Ellipsis)
+	if avail < 1 {
+		return Truncate(name, width)
+	}
+	headWidth := (avail + 1) / 2
+	tailWidth := avail - headWidth
+
+	var head []rune
+	used := 0
+	for _, r := range stem {
+		w := ansi.StringWidth(string(r))
+		if used+w > headWidth {
+			break
+		}
+		head = append(head, r)
+		used += w
+	}
+	var tail []rune
+	used = 0
+	for i := len(stem) - 1; i >= len(head); i-- {
+		w := ansi.StringWidth(string(stem[i]))
+		if used+w > tailWidth {
+			break
+		}
+		tail = append([]rune{stem[i]}, tail...)
+		used += w
+	}
+	return string(head) + Ellipsis + string(tail) + ext
+}
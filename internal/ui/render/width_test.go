@@ -0,0 +1,68 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"julo-prod.conf":           "julo-prod.conf",
+		"julo-\x93vendor\x94.conf": "julo-�vendor�.conf",
+		"tab\there.conf":           "tab�here.conf",
+		"日本語.conf":                 "日本語.conf",
+	}
+	for name, want := range tests {
+		if got := SanitizeName(name); got != want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestFileName(t *testing.T) {
+	long := strings.Repeat("very-long-vendor-name-", 9) + "prod.conf"
+	tests := []struct {
+		name  string
+		width int
+		want  string
+	}{
+		{"julo-prod.conf", 40, "julo-prod.conf"},
+		{long, 30, "very-long-ve…or-name-prod.conf"},
+		{long, 12, "ver…rod.conf"},
+		{long, 5, "ve…nf"},
+		{"julo-\x93vendor\x94.conf", 40, "julo-�vendor�.conf"},
+		{"設定ファイル-本番環境.conf", 16, "設定…環境.conf"},
+		{"julo-prod.conf", 0, ""},
+	}
+	for _, tt := range tests {
+		got := FileName(tt.name, tt.width)
+		if got != tt.want {
+			t.Errorf("FileName(%q, %d) = %q, want %q", tt.name, tt.width, got, tt.want)
+		}
+		if w := ansi.StringWidth(got); w > tt.width {
+			t.Errorf("FileName(%q, %d) is %d cells wide", tt.name, tt.width, w)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"Stop VPN", 20, "Stop VPN"},
+		{"Stop VPN", 6, "Stop …"},
+		{"🔒 Start Production VPN", 4, "🔒 …"},
+		{"🔒 Start", 2, "…"},
+		{"\x1b[31mConnected\x1b[0m", 5, "\x1b[31mConn…\x1b[0m"},
+		{"anything", 0, ""},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
@@ -3,15 +3,22 @@ package ui
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/ops"
+	"tui-wireguard-vpn/internal/sudo"
+	"tui-wireguard-vpn/internal/ui/render"
 )
 
 var (
@@ -48,8 +55,26 @@ type SetupModel struct {
 	files         []os.FileInfo
 	selectedIndex int
 	showHidden    bool
+	unfiltered    bool // the ignore patterns are off (Ctrl+F)
+	filtered      int  // entries of the directory the ignore patterns hide
 	viewportStart int
 	viewportSize  int
+	width         int // terminal width, 0 until the first WindowSizeMsg
+	skipped       bool // user chose to continue read-only without setup
+	completer     pathCompleter // Tab completion for the path inputs
+	hint          pathHint      // live check of the typed path
+	// A partial setup skips the step of the config that is already installed
+	skipProd      bool
+	skipNonProd   bool
+	// Processing (stages 6 and 7)
+	hooks         []config.Hook // hook scripts waiting to be acknowledged
+	customized    []string      // templates with local changes, waiting for replace or keep
+	templates     config.CustomTemplates // the answer about them
+	steps         *ops.Steps
+	spinner       spinner.Model
+	running       bool
+	elevated      bool // the setup ran in a sudo child process
+	warnings      []string
 }
 
 func NewSetupModel(status *config.SetupStatus) *SetupModel {
@@ -89,6 +114,15 @@ func NewSetupModel(status *config.SetupStatus) *SetupModel {
 		showHidden:    true,
 		viewportStart: 0,
 		viewportSize:  10,
+		spinner:       spinner.New(spinner.WithSpinner(spinner.Dot)),
+		hint:          newPathHint(),
+	}
+	if render.ASCII() {
+		model.spinner.Spinner = spinner.Line
+	}
+	if status.Partial() {
+		model.skipProd = status.HasProdConfig
+		model.skipNonProd = status.HasNonProdConfig
 	}
 	
 	return model
@@ -109,10 +143,15 @@ func (m *SetupModel) loadDirectory() error {
 
 	// Filter and sort files
 	var filteredFiles []os.FileInfo
+	m.filtered = 0
 	for _, f := range files {
 		if !m.showHidden && strings.HasPrefix(f.Name(), ".") {
 			continue
 		}
+		if !m.unfiltered && ignorePatterns.Ignored(f.Name(), f.IsDir()) {
+			m.filtered++
+			continue
+		}
 		filteredFiles = append(filteredFiles, f)
 	}
 
@@ -168,23 +207,52 @@ func (m *SetupModel) Init() tea.Cmd {
 
 func (m *SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
-	case ExitAndSetupMsg:
-		// Store the paths and quit TUI to run setup in terminal
-		m.prodPath = msg.prodPath
-		m.nonprodPath = msg.nonprodPath
-		return m, tea.Quit
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		return m, nil
+	case spinner.TickMsg:
+		if !m.running {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case PathHintMsg:
+		m.hint.Update(msg)
+		return m, nil
+	case setupStepMsg:
+		// Events out of sequence are dropped; the checklist stays as it was
+		m.steps.Apply(msg.event, time.Now())
+		return m, waitForSetup(msg.stream)
 	case SetupCompleteMsg:
+		m.running = false
+		m.elevated = msg.elevated
+		m.warnings = msg.warnings
 		if msg.success {
+			if msg.elevated {
+				// The sudo child reported its steps on the terminal, not here
+				for i := range m.steps.Steps {
+					m.steps.Steps[i].Status = ops.StepDone
+				}
+			}
+			m.steps.Finish(nil, time.Now())
 			m.stage = 7 // Complete
 			m.message = ""
 			m.err = nil
 		} else {
+			m.steps.Finish(msg.err, time.Now())
 			m.stage = 6 // Stay in processing but show error
 			m.message = fmt.Sprintf("Setup failed: %v", msg.err)
 			m.err = msg.err
 		}
 		return m, nil
 	case tea.KeyMsg:
+		if m.typingPath() {
+			return m.handlePathKey(msg)
+		}
+		if m.stage == 6 || m.stage == 7 {
+			return m.handleProcessingKey(msg)
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return m, tea.Quit
@@ -200,8 +268,15 @@ func (m *SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.handleHomeKey()
 		case "ctrl+h":
 			return m.handleToggleHiddenKey()
+		case "ctrl+f":
+			return m.handleToggleFiltersKey()
 		case "esc":
 			return m.handleEscKey()
+		case "s":
+			if m.stage == 0 { // Info screen
+				m.skipped = true
+				return m, tea.Quit
+			}
 		case "1":
 			if m.stage == 1 || m.stage == 4 { // Choice screens
 				m.inputMode = 0
@@ -229,10 +304,63 @@ func (m *SetupModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// typingPath reports whether a path text input has the keys.
+func (m *SetupModel) typingPath() bool {
+	return (m.stage == 3 || m.stage == 5) && m.inputMode == 0
+}
+
+// handlePathKey handles keys while typing a path: everything but these few
+// keys goes into the input, so letters are never taken as shortcuts.
+func (m *SetupModel) handlePathKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	input := &m.inputs[0]
+	if m.configStep == 1 {
+		input = &m.inputs[1]
+	}
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "enter":
+		m.completer.Reset()
+		stage := m.stage
+		model, cmd := m.handleEnterKey()
+		if m.stage != stage {
+			m.hint.Reset()
+		}
+		return model, cmd
+	case "esc":
+		m.completer.Reset()
+		m.hint.Reset()
+		return m.handleEscKey()
+	case "tab":
+		before := input.Value()
+		input.SetValue(m.completer.Next(before))
+		input.CursorEnd()
+		if input.Value() != before {
+			return m, m.hint.Changed(input.Value())
+		}
+		return m, nil
+	}
+
+	m.completer.Reset()
+	before := input.Value()
+	var cmd tea.Cmd
+	*input, cmd = input.Update(msg)
+	if input.Value() != before {
+		cmd = tea.Batch(cmd, m.hint.Changed(input.Value()))
+	}
+	return m, cmd
+}
+
 // Handler methods for different key actions
 func (m *SetupModel) handleEnterKey() (tea.Model, tea.Cmd) {
 	switch m.stage {
 	case 0: // Info screen
+		if m.skipProd {
+			m.configStep = 1
+			m.stage = 4 // Straight to the nonprod choice
+			return m, nil
+		}
 		m.stage = 1 // Go to choice mode
 		return m, nil
 	case 1: // Production config choice
@@ -247,7 +375,7 @@ func (m *SetupModel) handleEnterKey() (tea.Model, tea.Cmd) {
 	case 2: // File browser for production
 		return m.handleFileBrowserEnter()
 	case 3: // Text input for production
-		path := strings.TrimSpace(m.inputs[0].Value())
+		path := expandPath(strings.TrimSpace(m.inputs[0].Value()))
 		if path == "" {
 			m.message = "Please enter the production config file path"
 			return m, nil
@@ -256,11 +384,7 @@ func (m *SetupModel) handleEnterKey() (tea.Model, tea.Cmd) {
 			m.message = "Please select a .conf file"
 			return m, nil
 		}
-		m.prodPath = path
-		m.configStep = 1 // Move to nonprod
-		m.stage = 4 // Choice for nonprod
-		m.inputMode = 0 // Reset to text input
-		return m, nil
+		return m.prodChosen(path)
 	case 4: // Non-production config choice
 		if m.inputMode == 0 {
 			m.stage = 5 // Text input
@@ -271,7 +395,7 @@ func (m *SetupModel) handleEnterKey() (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case 5: // Text input for nonprod
-		path := strings.TrimSpace(m.inputs[1].Value())
+		path := expandPath(strings.TrimSpace(m.inputs[1].Value()))
 		if path == "" {
 			m.message = "Please enter the non-production config file path"
 			return m, nil
@@ -281,8 +405,7 @@ func (m *SetupModel) handleEnterKey() (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		m.nonprodPath = path
-		// Exit TUI and run setup, then return to main app
-		return m, m.exitAndRunSetup()
+		return m, m.startSetup()
 	}
 	return m, nil
 }
@@ -303,16 +426,10 @@ func (m *SetupModel) handleFileBrowserEnter() (tea.Model, tea.Cmd) {
 			filePath := filepath.Join(m.currentDir, selectedFile.Name())
 			if strings.HasSuffix(strings.ToLower(selectedFile.Name()), ".conf") {
 				if m.configStep == 0 {
-					m.prodPath = filePath
-					m.configStep = 1
-					m.stage = 4 // Choice for nonprod
-					m.inputMode = 0
-				} else {
-					m.nonprodPath = filePath
-					// Exit TUI and run setup, then return to main app
-					return m, m.exitAndRunSetup()
+					return m.prodChosen(filePath)
 				}
-				return m, nil
+				m.nonprodPath = filePath
+				return m, m.startSetup()
 			} else {
 				m.message = "Please select a .conf file"
 				return m, nil
@@ -322,6 +439,31 @@ func (m *SetupModel) handleFileBrowserEnter() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// prodChosen moves on from the production step: to the nonprod step, or
+// straight to running setup when nonprod is already installed.
+func (m *SetupModel) prodChosen(path string) (tea.Model, tea.Cmd) {
+	m.prodPath = path
+	if m.skipNonProd {
+		return m, m.startSetup()
+	}
+	m.configStep = 1 // Move to nonprod
+	m.stage = 4      // Choice for nonprod
+	m.inputMode = 0  // Reset to text input
+	return m, nil
+}
+
+// stepTitle names a step, numbered only when both configs are set up.
+func (m *SetupModel) stepTitle(step int) string {
+	name := "Production Configuration"
+	if step == 1 {
+		name = "Non-Production Configuration"
+	}
+	if m.skipProd || m.skipNonProd {
+		return name
+	}
+	return fmt.Sprintf("Step %d: %s", step+1, name)
+}
+
 func (m *SetupModel) handleUpKey() (tea.Model, tea.Cmd) {
 	if m.stage == 2 && len(m.files) > 0 { // File browser
 		if m.selectedIndex > 0 {
@@ -376,6 +518,14 @@ func (m *SetupModel) handleToggleHiddenKey() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+func (m *SetupModel) handleToggleFiltersKey() (tea.Model, tea.Cmd) {
+	if m.stage == 2 { // File browser
+		m.unfiltered = !m.unfiltered
+		m.loadDirectory()
+	}
+	return m, nil
+}
+
 func (m *SetupModel) handleEscKey() (tea.Model, tea.Cmd) {
 	switch m.stage {
 	case 1: // Choice -> Info
@@ -389,6 +539,11 @@ func (m *SetupModel) handleEscKey() (tea.Model, tea.Cmd) {
 		}
 		m.message = ""
 	case 4: // Nonprod choice -> back to prod (if we want to change prod selection)
+		if m.skipProd {
+			m.stage = 0
+			m.message = ""
+			break
+		}
 		m.stage = 1
 		m.configStep = 0
 		m.prodPath = ""
@@ -396,11 +551,75 @@ func (m *SetupModel) handleEscKey() (tea.Model, tea.Cmd) {
 	case 5: // Nonprod text input -> Choice
 		m.stage = 4
 		m.message = ""
+	case 6: // Hooks or template changes refused, or setup failed -> pick the configs again
+		m.hooks = nil
+		m.customized = nil
+		m.message, m.err = "", nil
+		if m.skipNonProd {
+			m.stage = 1
+			m.configStep = 0
+		} else {
+			m.stage = 4
+			m.configStep = 1
+		}
 	}
 	return m, nil
 }
 
+// handleProcessingKey handles keys once the configs are chosen: answering
+// the hook scripts prompt, retrying or going back after a failure, and
+// leaving when done. Nothing interrupts a running setup.
+func (m *SetupModel) handleProcessingKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.running {
+		return m, nil
+	}
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "enter":
+		if m.stage == 7 {
+			return m, tea.Quit
+		}
+	case "y":
+		if len(m.hooks) > 0 {
+			m.hooks = nil
+			if len(m.customized) > 0 {
+				return m, nil // On to the templates question
+			}
+			return m, m.runSetup()
+		}
+		if len(m.customized) > 0 {
+			m.customized = nil
+			m.templates = config.ReplaceCustomTemplates
+			return m, m.runSetup()
+		}
+	case "k":
+		if len(m.hooks) == 0 && len(m.customized) > 0 {
+			m.customized = nil
+			m.templates = config.KeepCustomTemplates
+			return m, m.runSetup()
+		}
+	case "r":
+		if m.err != nil {
+			return m, m.runSetup()
+		}
+	case "esc", "n":
+		if m.stage == 6 {
+			return m.handleEscKey()
+		}
+	}
+	return m, nil
+}
+
+// View draws the wizard, in plain ASCII on terminals that need it.
 func (m *SetupModel) View() string {
+	if render.ASCII() {
+		return render.ToASCII(m.view())
+	}
+	return m.view()
+}
+
+func (m *SetupModel) view() string {
 	var s strings.Builder
 	
 	s.WriteString(setupTitleStyle.Render("WireGuard VPN Setup Required"))
@@ -408,6 +627,19 @@ func (m *SetupModel) View() string {
 
 	switch m.stage {
 	case 0: // Info screen
+		if m.skipProd || m.skipNonProd {
+			missing, kept := "production", "non-production"
+			if m.skipProd {
+				missing, kept = kept, missing
+			}
+			s.WriteString(setupInfoStyle.Render(fmt.Sprintf("The %s configuration is not set up yet. This will:", missing)))
+			s.WriteString("\n")
+			s.WriteString(fmt.Sprintf("• Process your %s config file\n", missing))
+			s.WriteString(fmt.Sprintf("• Keep the installed %s configuration as it is\n", kept))
+			s.WriteString("\n")
+			s.WriteString("Press Enter to continue, s to go back to the dashboard")
+			break
+		}
 		s.WriteString(setupInfoStyle.Render("Initial setup is required. This will:"))
 		s.WriteString("\n")
 		s.WriteString("• Install WireGuard configuration templates\n")
@@ -423,10 +655,11 @@ func (m *SetupModel) View() string {
 			s.WriteString("\n")
 		}
 		
-		s.WriteString("Press Enter to continue, Ctrl+C to quit")
+		s.WriteString("Press Enter to continue, Ctrl+C to quit\n")
+		s.WriteString("Press s to skip setup and continue read-only (status and viewing only)")
 
 	case 1: // Production config choice
-		s.WriteString("Step 1: Production Configuration\n\n")
+		s.WriteString(m.stepTitle(0) + "\n\n")
 		s.WriteString("Choose how to select your production config file:\n\n")
 		
 		if m.inputMode == 0 {
@@ -443,15 +676,16 @@ func (m *SetupModel) View() string {
 		return m.buildFileBrowserView()
 
 	case 3: // Text input for production
-		s.WriteString("Step 1: Production Configuration\n\n")
+		s.WriteString(m.stepTitle(0) + "\n\n")
 		s.WriteString("Enter the path to your production WireGuard config file:\n")
 		s.WriteString("(This should contain your production private key and settings)\n\n")
 		s.WriteString(m.inputs[0].View())
-		s.WriteString("\n\nPress Enter to confirm, Esc to go back")
+		s.WriteString("\n" + m.hint.View(m.width) + m.completer.View(m.width))
+		s.WriteString("\nTab to complete, Enter to confirm, Esc to go back")
 
 	case 4: // Non-production config choice
-		s.WriteString("Step 2: Non-Production Configuration\n\n")
-		s.WriteString(fmt.Sprintf("Production config: %s\n\n", m.prodPath))
+		s.WriteString(m.stepTitle(1) + "\n\n")
+		s.WriteString(m.prodSummary())
 		s.WriteString("Choose how to select your non-production config file:\n\n")
 		
 		if m.inputMode == 0 {
@@ -462,31 +696,74 @@ func (m *SetupModel) View() string {
 			s.WriteString("> 2. Browse files\n")
 		}
 		
-		s.WriteString("\nUse ↑/↓ or Tab to switch, Enter to select, Esc to change production config")
+		if m.skipProd {
+			s.WriteString("\nUse ↑/↓ or Tab to switch, Enter to select, Esc to go back")
+		} else {
+			s.WriteString("\nUse ↑/↓ or Tab to switch, Enter to select, Esc to change production config")
+		}
 
 	case 5: // Text input for nonprod
-		s.WriteString("Step 2: Non-Production Configuration\n\n")
-		s.WriteString(fmt.Sprintf("Production config: %s\n\n", m.prodPath))
+		s.WriteString(m.stepTitle(1) + "\n\n")
+		s.WriteString(m.prodSummary())
 		s.WriteString("Enter the path to your non-production WireGuard config file:\n")
 		s.WriteString("(This should contain your non-production private key and settings)\n\n")
 		s.WriteString(m.inputs[1].View())
-		s.WriteString("\n\nPress Enter to start setup, Esc to go back")
+		s.WriteString("\n" + m.hint.View(m.width) + m.completer.View(m.width))
+		s.WriteString("\nTab to complete, Enter to start setup, Esc to go back")
 
 	case 6: // Processing
-		s.WriteString("Processing configuration files...\n\n")
-		s.WriteString("This requires sudo privileges to write to /etc/wireguard/\n")
+		if len(m.hooks) > 0 {
+			s.WriteString("⚠️  These configs run shell commands as root when the tunnel goes up or down:\n")
+			for _, hook := range m.hooks {
+				s.WriteString(fmt.Sprintf("    %s\n", hook))
+			}
+			s.WriteString("\nPress y to install them anyway, Esc to choose other files")
+			break
+		}
+		if len(m.customized) > 0 {
+			s.WriteString("⚠️  These templates were changed since they were installed:\n")
+			for _, path := range m.customized {
+				s.WriteString(fmt.Sprintf("    %s\n", path))
+			}
+			s.WriteString("\nPress y to replace them with the built-in ones (the changes are saved as\n")
+			s.WriteString(fmt.Sprintf("%s/%s/<name>.local.<time>), k to keep them, Esc to go back", core.ConfigDir, config.BackupDir))
+			break
+		}
+		if m.running {
+			s.WriteString(m.spinner.View() + " Processing configuration files...\n\n")
+		} else {
+			s.WriteString("Processing configuration files...\n\n")
+		}
+		s.WriteString(render.RenderSteps(m.steps, m.viewWidth()))
+		if m.running {
+			s.WriteString(fmt.Sprintf("\nThis requires sudo privileges to write to %s/\n", core.ConfigDir))
+		} else {
+			s.WriteString("\nPress r to retry, Esc to choose other files, q to quit\n")
+		}
 
 	case 7: // Complete
-		s.WriteString(setupSuccessStyle.Render("Configuration Paths Selected!"))
+		s.WriteString(setupSuccessStyle.Render("Setup Complete!"))
 		s.WriteString("\n\n")
-		s.WriteString("Configuration file paths have been saved:\n")
-		s.WriteString(fmt.Sprintf("• Production: %s\n", m.prodPath))
-		s.WriteString(fmt.Sprintf("• Non-Production: %s\n", m.nonprodPath))
+		s.WriteString(render.RenderSteps(m.steps, m.viewWidth()))
+		for _, warning := range m.warnings {
+			s.WriteString(render.Truncate("⚠️  "+warning, m.viewWidth()) + "\n")
+		}
+		if m.elevated {
+			s.WriteString("(The setup ran through sudo; its output is above.)\n")
+		}
+		s.WriteString("\n")
+		s.WriteString("Configuration files installed:\n")
+		if m.prodPath != "" {
+			s.WriteString(fmt.Sprintf("• Production: %s\n", m.prodPath))
+		}
+		if m.nonprodPath != "" {
+			s.WriteString(fmt.Sprintf("• Non-Production: %s\n", m.nonprodPath))
+		}
 		s.WriteString("\n")
 		s.WriteString("You can now proceed to the main application to:\n")
 		s.WriteString("• Start VPN connections\n")
 		s.WriteString("• Update configurations as needed\n\n")
-		s.WriteString("Press q to continue to main application")
+		s.WriteString("Press Enter or q to continue to main application")
 	}
 
 	if m.message != "" {
@@ -504,23 +781,28 @@ func (m *SetupModel) View() string {
 func (m *SetupModel) buildFileBrowserView() string {
 	var s strings.Builder
 	
-	configType := "Production"
-	if m.configStep == 1 {
-		configType = "Non-Production"
-	}
-	
-	s.WriteString(fmt.Sprintf("Step %d: %s Configuration\n\n", m.configStep+1, configType))
+	s.WriteString(m.stepTitle(m.configStep) + "\n\n")
 	s.WriteString("Browse for your WireGuard config file:\n")
 	
 	hiddenStatus := "Hidden files: OFF"
 	if m.showHidden {
 		hiddenStatus = "Hidden files: ON"
 	}
-	s.WriteString(fmt.Sprintf("📁 Current directory: %s | %s\n", m.currentDir, hiddenStatus))
-	s.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-	s.WriteString("📂 = Directory | 📄 = File | ↑↓ Navigate | → Enter directory | Enter = Select .conf file\n")
-	s.WriteString("Shortcuts: h = Home | Ctrl+H = Toggle hidden files | Esc = Go back\n")
-	s.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+	width := m.width
+	if width <= 0 {
+		width = 80
+	}
+	separator := strings.Repeat("━", min(width, 78))
+	header := fmt.Sprintf("📁 Current directory: %s | %s", render.SanitizeName(m.currentDir), hiddenStatus)
+	header += filterStatus(m.filtered, m.unfiltered)
+	if position := render.ScrollPosition(m.selectedIndex+1, len(m.files), m.viewportSize); position != "" {
+		header += " | " + position
+	}
+	s.WriteString(render.Truncate(header, width) + "\n")
+	s.WriteString(separator + "\n")
+	s.WriteString(render.Truncate("📂 = Directory | 📄 = File | ↑↓ Navigate | → Enter directory | Enter = Select .conf file", width) + "\n")
+	s.WriteString(render.Truncate("Shortcuts: h = Home | Ctrl+H = Toggle hidden files | Ctrl+F = Toggle filters | Esc = Go back", width) + "\n")
+	s.WriteString(separator + "\n\n")
 	
 	// Display files
 	viewportEnd := m.viewportStart + m.viewportSize
@@ -528,10 +810,16 @@ func (m *SetupModel) buildFileBrowserView() string {
 		viewportEnd = len(m.files)
 	}
 	
-	if m.viewportStart > 0 {
+	// The scrollbar does without the indicators
+	if m.viewportStart > 0 && render.ASCII() {
 		s.WriteString("  ↑ (more files above)\n")
 	}
 	
+	rowWidth := width
+	if !render.ASCII() && len(m.files) > viewportEnd-m.viewportStart {
+		rowWidth = width - 2 // room for the scrollbar
+	}
+	var lines []string
 	for i := m.viewportStart; i < viewportEnd; i++ {
 		file := m.files[i]
 		cursor := "  "
@@ -544,15 +832,22 @@ func (m *SetupModel) buildFileBrowserView() string {
 			icon = "📂"
 		}
 		
-		name := file.Name()
+		// Display only: the selection path uses file.Name() unchanged
+		nameWidth := rowWidth - 5 // cursor (2) + icon (2) + space
+		name := ""
 		if file.IsDir() {
-			name += "/"
+			name = render.FileName(file.Name(), nameWidth-1) + "/"
+		} else {
+			name = render.FileName(file.Name(), nameWidth)
 		}
 		
-		s.WriteString(fmt.Sprintf("%s%s %s\n", cursor, icon, name))
+		lines = append(lines, fmt.Sprintf("%s%s %s", cursor, icon, name))
+	}
+	for _, line := range render.WithScrollbar(lines, len(m.files), m.viewportStart, width) {
+		s.WriteString(line + "\n")
 	}
 	
-	if viewportEnd < len(m.files) {
+	if viewportEnd < len(m.files) && render.ASCII() {
 		s.WriteString("  ↓ (more files below)\n")
 	}
 	
@@ -563,25 +858,156 @@ func (m *SetupModel) buildFileBrowserView() string {
 	return s.String()
 }
 
-func (m *SetupModel) exitAndRunSetup() tea.Cmd {
+// prodSummary is the line recalling the production choice on the nonprod
+// step.
+func (m *SetupModel) prodSummary() string {
+	if m.skipProd {
+		return "Production config: already installed\n\n"
+	}
+	return fmt.Sprintf("Production config: %s\n\n", m.prodPath)
+}
+
+// viewWidth is the width the views fit, 80 columns until the terminal's
+// size is known.
+func (m *SetupModel) viewWidth() int {
+	if m.width <= 0 {
+		return 80
+	}
+	return m.width
+}
+
+// startSetup asks about any hook scripts in the chosen configs, then runs
+// the setup.
+func (m *SetupModel) startSetup() tea.Cmd {
+	m.message, m.err = "", nil
+	m.stage = 6
+	m.hooks = configHooks(m.prodPath, m.nonprodPath)
+	m.templates = config.RefuseCustomTemplates
+	// Templates that can't be read from here are the install's to find
+	m.customized, _ = config.CustomizedTemplates()
+	if len(m.hooks) > 0 || len(m.customized) > 0 {
+		return nil
+	}
+	return m.runSetup()
+}
+
+// configHooks lists the hook scripts the configs at paths would install,
+// none when they are stripped on install.
+func configHooks(paths ...string) []config.Hook {
+	if config.NewConfigProcessor().StripHooks {
+		return nil
+	}
+	var hooks []config.Hook
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		directives, err := config.InspectConfig(path)
+		if err != nil {
+			continue // Processing reports unreadable files itself
+		}
+		hooks = append(hooks, directives.Hooks...)
+	}
+	return hooks
+}
+
+// runSetup installs the chosen configs in the background, streaming its
+// steps to the checklist. Without root, the setup runs in a sudo child
+// process instead, the TUI stepping aside for sudo's password prompt.
+func (m *SetupModel) runSetup() tea.Cmd {
+	m.stage = 6
+	m.message, m.err = "", nil
+	m.warnings = nil
+	m.steps = config.SetupSteps(m.prodPath, m.nonprodPath)
+	m.running = true
+	if runtime.GOOS != "windows" && !sudo.IsRoot() {
+		return tea.Batch(m.spinner.Tick, m.elevatedSetup())
+	}
+
+	prodPath, nonprodPath, templates := m.prodPath, m.nonprodPath, m.templates
+	stream := make(chan tea.Msg, 16)
+	go func() {
+		defer close(stream)
+		warnings, err := config.RunSetupDirectly(prodPath, nonprodPath, templates, func(event ops.StepEvent) {
+			stream <- setupStepMsg{event: event, stream: stream}
+		})
+		stream <- SetupCompleteMsg{success: err == nil, err: err, warnings: warnings}
+	}()
+	return tea.Batch(m.spinner.Tick, waitForSetup(stream))
+}
+
+// elevatedSetup runs "sudo tui-wireguard-vpn setup" with the chosen configs
+// on the terminal.
+func (m *SetupModel) elevatedSetup() tea.Cmd {
+	execPath, err := os.Executable()
+	if err != nil {
+		return func() tea.Msg {
+			return SetupCompleteMsg{err: fmt.Errorf("failed to locate executable: %v", err)}
+		}
+	}
+	// A retry may find the config of a step that went through installed.
+	// --force also replaces templates with local changes, saving them,
+	// unless they are to be kept
+	args := []string{execPath, "setup", "--force"}
+	if m.templates == config.KeepCustomTemplates {
+		args = append(args, "--keep-templates")
+	}
+	if m.prodPath != "" {
+		args = append(args, "--prod", m.prodPath)
+	}
+	if m.nonprodPath != "" {
+		args = append(args, "--nonprod", m.nonprodPath)
+	}
+	switch {
+	case m.prodPath == "":
+		args = append(args, "--nonprod-only")
+	case m.nonprodPath == "":
+		args = append(args, "--prod-only")
+	}
+	return tea.ExecProcess(exec.Command("sudo", args...), func(err error) tea.Msg {
+		if err != nil {
+			err = fmt.Errorf("privileged setup failed: %v", err)
+		}
+		return SetupCompleteMsg{success: err == nil, err: err, elevated: true}
+	})
+}
+
+func waitForSetup(stream <-chan tea.Msg) tea.Cmd {
 	return func() tea.Msg {
-		return ExitAndSetupMsg{
-			prodPath:    m.prodPath,
-			nonprodPath: m.nonprodPath,
+		msg, ok := <-stream
+		if !ok {
+			return nil
 		}
+		return msg
 	}
 }
 
-type SetupCompleteMsg struct {
-	success bool
-	err     error
+// setupStepMsg is a step of the running setup starting or finishing.
+type setupStepMsg struct {
+	event  ops.StepEvent
+	stream <-chan tea.Msg
 }
 
-type ExitAndSetupMsg struct {
-	prodPath    string
-	nonprodPath string
+// SetupCompleteMsg ends a setup run.
+type SetupCompleteMsg struct {
+	success  bool
+	err      error
+	warnings []string
+	elevated bool // it ran in a sudo child process, which reports no steps
 }
 
 func (m *SetupModel) GetConfigPaths() (string, string) {
 	return m.prodPath, m.nonprodPath
+}
+
+// Err is the error the last setup run failed with, nil if it succeeded or
+// never ran.
+func (m *SetupModel) Err() error {
+	return m.err
+}
+
+// Skipped reports whether the user chose to skip setup and continue
+// read-only.
+func (m *SetupModel) Skipped() bool {
+	return m.skipped
 }
\ No newline at end of file
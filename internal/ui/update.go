@@ -11,6 +11,8 @@ import (
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"tui-wireguard-vpn/internal/state"
+	"tui-wireguard-vpn/internal/ui/render"
 )
 
 var (
@@ -30,10 +32,18 @@ var (
 				Foreground(lipgloss.Color("#50FA7B"))
 )
 
+// UpdateResult is how one config picked during an update session went.
+type UpdateResult struct {
+	Environment string // display name, e.g. "Production"
+	Source      string // the picked file
+	Result      string // e.g. "updated", "failed: ..."
+	OK          bool
+}
+
 type UpdateModel struct {
 	textinput  textinput.Model
 	stage      int // 0: info, 1: choose mode, 2: text input, 3: file picker, 4: processing, 5: complete
-	inputMode  int // 0: text input, 1: file browser
+	inputMode  int // 0: text input, 1: file browser, 2: retry last failed update
 	message    string
 	err        error
 	configPath string
@@ -42,12 +52,36 @@ type UpdateModel struct {
 	files         []os.FileInfo
 	selectedIndex int
 	showHidden    bool
+	unfiltered    bool // the ignore patterns are off (Ctrl+F)
+	filtered      int  // entries of the directory the ignore patterns hide
 	// Scrolling support
 	viewportStart int // First visible item index
 	viewportSize  int // Number of items visible at once
+	// Last failed update, offered as the first choice when present
+	retry *state.UpdateAttempt
+	// Available content width, 0 until the first WindowSizeMsg
+	width int
+	// Tab completion in text input mode
+	completer pathCompleter
+	// Live check of the typed path
+	hint pathHint
+	// The picked file is a new template rather than a personal config
+	asTemplate bool
+	// What the session applied so far, shown once a config is in
+	session []UpdateResult
+	// Stage the last config was picked in, where another round starts
+	pickedStage int
+	// The user is done updating
+	done bool
+}
+
+// TypingPath reports whether keys go to the path text input, so the caller
+// shouldn't treat Tab or letters as shortcuts.
+func (m *UpdateModel) TypingPath() bool {
+	return m.stage == 2
 }
 
-func NewUpdateModel() *UpdateModel {
+func NewUpdateModel(lastUpdate *state.UpdateAttempt) *UpdateModel {
 	// Setup text input
 	ti := textinput.New()
 	ti.Placeholder = "/path/to/config.conf"
@@ -73,11 +107,88 @@ func NewUpdateModel() *UpdateModel {
 		showHidden:    true, // Show all files including hidden ones by default
 		viewportStart: 0,
 		viewportSize:  15, // Show 15 files at once
+		retry:         lastUpdate,
+		hint:          newPathHint(),
+	}
+	if lastUpdate != nil {
+		model.inputMode = 2 // Preselect the retry option
 	}
 
 	return model
 }
 
+// choiceOrder lists the input modes in the order they're shown on the
+// choose-mode screen.
+func (m *UpdateModel) choiceOrder() []int {
+	if m.retry != nil {
+		return []int{2, 0, 1}
+	}
+	return []int{0, 1}
+}
+
+func (m *UpdateModel) moveChoice(delta int) {
+	order := m.choiceOrder()
+	for i, mode := range order {
+		if mode == m.inputMode {
+			m.inputMode = order[(i+delta+len(order))%len(order)]
+			return
+		}
+	}
+	m.inputMode = order[0]
+}
+
+// Another shows what the session applied so far and asks whether to update
+// another environment, which picks up where the last file was picked.
+func (m *UpdateModel) Another(results []UpdateResult) {
+	m.session = results
+	m.stage = 5
+	m.configPath = ""
+	m.message = ""
+	m.hint.Reset()
+	// A successful update leaves nothing to retry
+	m.retry = nil
+	if m.inputMode == 2 {
+		m.inputMode = 0
+	}
+}
+
+// Done reports whether the user declined to update another environment.
+func (m *UpdateModel) Done() bool {
+	return m.done
+}
+
+// retryLastUpdate re-selects the previously attempted file after checking it
+// still exists and hasn't changed since the failed attempt.
+func (m *UpdateModel) retryLastUpdate() {
+	if m.retry == nil {
+		return
+	}
+	hash, err := state.HashFile(m.retry.SourcePath)
+	if err != nil {
+		m.message = fmt.Sprintf("Cannot retry: %s is no longer readable", abbreviateHome(m.retry.SourcePath))
+		m.retry = nil
+		m.inputMode = 0
+		return
+	}
+	if hash != m.retry.SourceHash {
+		m.message = fmt.Sprintf("Cannot retry: %s has changed since the failed attempt; select it again", abbreviateHome(m.retry.SourcePath))
+		m.retry = nil
+		m.inputMode = 0
+		return
+	}
+	m.configPath = m.retry.SourcePath
+	m.pickedStage = m.stage
+}
+
+// abbreviateHome replaces the user's home directory prefix with ~.
+func abbreviateHome(path string) string {
+	home := os.Getenv("HOME")
+	if home != "" && (path == home || strings.HasPrefix(path, home+string(filepath.Separator))) {
+		return "~" + strings.TrimPrefix(path, home)
+	}
+	return path
+}
+
 func (m *UpdateModel) loadDirectory() error {
 	file, err := os.Open(m.currentDir)
 	if err != nil {
@@ -92,11 +203,16 @@ func (m *UpdateModel) loadDirectory() error {
 
 	// Filter and sort files
 	var filteredFiles []os.FileInfo
+	m.filtered = 0
 	for _, f := range files {
 		// Skip hidden files unless showHidden is true
 		if !m.showHidden && strings.HasPrefix(f.Name(), ".") {
 			continue
 		}
+		if !m.unfiltered && ignorePatterns.Ignored(f.Name(), f.IsDir()) {
+			m.filtered++
+			continue
+		}
 		filteredFiles = append(filteredFiles, f)
 	}
 
@@ -156,15 +272,27 @@ func (m *UpdateModel) Init() tea.Cmd {
 func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
-		// No special handling needed for custom file browser
+		// Width of the panel the browser is drawn in; names are fit to it
+		m.width = msg.Width
+		return m, nil
+
+	case PathHintMsg:
+		m.hint.Update(msg)
 		return m, nil
 
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "ctrl+c", "q":
+			if m.stage == 2 && msg.String() == "q" {
+				break // Typed into the path
+			}
 			if m.stage == 0 {
 				return m, tea.Quit
 			}
+			if m.stage == 5 {
+				m.done = true
+				return m, nil
+			}
 			// For panel embedding, don't quit - let main handle it
 			if m.stage == 3 {
 				return m, nil
@@ -176,7 +304,7 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		case "up", "k":
 			if m.stage == 1 { // Choice mode
-				m.inputMode = 1 - m.inputMode // Toggle between 0 and 1
+				m.moveChoice(-1)
 				return m, nil
 			} else if m.stage == 3 && len(m.files) > 0 {
 				if m.selectedIndex > 0 {
@@ -190,7 +318,7 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "down", "j":
 			if m.stage == 1 { // Choice mode
-				m.inputMode = 1 - m.inputMode // Toggle between 0 and 1
+				m.moveChoice(1)
 				return m, nil
 			} else if m.stage == 3 && len(m.files) > 0 {
 				if m.selectedIndex < len(m.files)-1 {
@@ -228,7 +356,9 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter":
 			switch m.stage {
 			case 1: // Choose mode screen
-				if m.inputMode == 0 {
+				if m.inputMode == 2 {
+					m.retryLastUpdate()
+				} else if m.inputMode == 0 {
 					m.stage = 2 // Text input
 					m.textinput.Focus()
 				} else {
@@ -237,7 +367,7 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return m, nil
 			case 2: // Text input mode
-				path := strings.TrimSpace(m.textinput.Value())
+				path := expandPath(strings.TrimSpace(m.textinput.Value()))
 				if path == "" {
 					m.message = "Please enter a file path"
 					return m, nil
@@ -252,6 +382,7 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 				m.configPath = path
+				m.pickedStage = m.stage
 				return m, nil
 			case 3: // Custom file browser
 				if len(m.files) > 0 && m.selectedIndex < len(m.files) {
@@ -276,6 +407,7 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						filePath := filepath.Join(m.currentDir, selectedFile.Name())
 						if strings.HasSuffix(strings.ToLower(selectedFile.Name()), ".conf") {
 							m.configPath = filePath
+							m.pickedStage = m.stage
 							return m, nil
 						} else {
 							m.message = "Please select a .conf file"
@@ -283,6 +415,9 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						}
 					}
 				}
+			case 5: // Session summary: Enter means done
+				m.done = true
+				return m, nil
 			}
 		case "esc":
 			// For panel embedding in stage 3, don't handle esc - let main handle it
@@ -292,6 +427,7 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.stage > 0 {
 				m.stage--
 				m.message = ""
+				m.hint.Reset()
 				return m, nil
 			} else {
 				return m, tea.Quit
@@ -313,6 +449,13 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.loadDirectory()
 				return m, nil
 			}
+		case "ctrl+f":
+			// Show what the ignore patterns hide, or hide it again
+			if m.stage == 3 {
+				m.unfiltered = !m.unfiltered
+				m.loadDirectory()
+				return m, nil
+			}
 		case "1":
 			if m.stage == 1 { // Choose mode screen
 				m.inputMode = 0 // Text input
@@ -325,7 +468,56 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "tab":
 			if m.stage == 1 { // Choose mode screen
-				m.inputMode = 1 - m.inputMode // Toggle between 0 and 1
+				m.moveChoice(1)
+				return m, nil
+			}
+			if m.stage == 2 { // Text input: complete the path
+				before := m.textinput.Value()
+				m.textinput.SetValue(m.completer.Next(before))
+				m.textinput.CursorEnd()
+				if m.textinput.Value() != before {
+					return m, m.hint.Changed(m.textinput.Value())
+				}
+				return m, nil
+			}
+		case "r":
+			if m.stage == 1 && m.retry != nil { // Choose mode screen
+				m.inputMode = 2
+				return m, nil
+			}
+		case "t":
+			if m.stage == 1 { // Choose mode screen
+				m.asTemplate = !m.asTemplate
+				return m, nil
+			}
+		case "y", "Y":
+			if m.stage == 5 { // Session summary: another round
+				m.stage = m.pickedStage
+				switch m.stage {
+				case 2:
+					m.textinput.Focus()
+				case 3:
+					// The directory may have changed meanwhile; the cursor
+					// stays on the file picked last
+					picked := ""
+					if m.selectedIndex < len(m.files) {
+						picked = m.files[m.selectedIndex].Name()
+					}
+					m.loadDirectory()
+					for i, file := range m.files {
+						if file.Name() == picked {
+							m.selectedIndex = i
+							if m.selectedIndex >= m.viewportStart+m.viewportSize {
+								m.viewportStart = m.selectedIndex - m.viewportSize + 1
+							}
+						}
+					}
+				}
+				return m, nil
+			}
+		case "n", "N":
+			if m.stage == 5 {
+				m.done = true
 				return m, nil
 			}
 		}
@@ -333,8 +525,15 @@ func (m *UpdateModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	// Handle text input updates when in text input mode
 	if m.stage == 2 {
+		if _, ok := msg.(tea.KeyMsg); ok {
+			m.completer.Reset()
+		}
+		before := m.textinput.Value()
 		var cmd tea.Cmd
 		m.textinput, cmd = m.textinput.Update(msg)
+		if m.textinput.Value() != before {
+			cmd = tea.Batch(cmd, m.hint.Changed(m.textinput.Value()))
+		}
 		return m, cmd
 	}
 
@@ -353,20 +552,33 @@ func (m *UpdateModel) View() string {
 	case 1: // Choose input mode
 		s.WriteString("Choose how to select your config file:\n\n")
 
-		if m.inputMode == 0 {
-			s.WriteString("> 1. Type file path manually\n")
-			s.WriteString("  2. Browse files\n")
-		} else {
-			s.WriteString("  1. Type file path manually\n")
-			s.WriteString("> 2. Browse files\n")
+		labels := map[int]string{
+			0: "1. Type file path manually",
+			1: "2. Browse files",
+		}
+		if m.retry != nil {
+			labels[2] = fmt.Sprintf("r. Retry last update: %s (failed: %s)", abbreviateHome(m.retry.SourcePath), m.retry.Error)
+		}
+		for _, mode := range m.choiceOrder() {
+			cursor := "  "
+			if mode == m.inputMode {
+				cursor = "> "
+			}
+			s.WriteString(cursor + labels[mode] + "\n")
 		}
 
+		if m.asTemplate {
+			s.WriteString("\nImport as: template (new AllowedIPs/peer for everyone) — t to switch\n")
+		} else {
+			s.WriteString("\nImport as: personal config — t to switch to template\n")
+		}
 		s.WriteString("\nUse Tab to switch, Enter to select, Esc to go back")
 
 	case 2: // Text input mode
 		s.WriteString("Enter the path to your WireGuard config file:\n\n")
 		s.WriteString(m.textinput.View())
-		s.WriteString("\n\nPress Enter to confirm, Esc to go back")
+		s.WriteString("\n" + m.hint.View(m.width) + m.completer.View(m.width))
+		s.WriteString("\nTab to complete, Enter to confirm, Esc to go back")
 
 	case 3: // Custom file browser
 		s.WriteString("Browse for your WireGuard config file:\n")
@@ -374,11 +586,21 @@ func (m *UpdateModel) View() string {
 		if m.showHidden {
 			hiddenStatus = "Hidden files: ON"
 		}
-		s.WriteString(fmt.Sprintf("📁 Current directory: %s | %s | Files found: %d\n", m.currentDir, hiddenStatus, len(m.files)))
-		s.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-		s.WriteString("📂 = Directory | 📄 = File | ↑↓ Navigate | → Enter directory | Enter = Select .conf file\n")
-		s.WriteString("Shortcuts: h = Home | Ctrl+H = Toggle hidden files | Esc = Go back\n")
-		s.WriteString("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n\n")
+		width := m.width
+		if width <= 0 {
+			width = 80
+		}
+		separator := strings.Repeat("━", min(width, 78))
+		header := fmt.Sprintf("📁 Current directory: %s | %s | Files found: %d", render.SanitizeName(m.currentDir), hiddenStatus, len(m.files))
+		header += filterStatus(m.filtered, m.unfiltered)
+		if position := render.ScrollPosition(m.selectedIndex+1, len(m.files), m.viewportSize); position != "" {
+			header += " | " + position
+		}
+		s.WriteString(render.Truncate(header, width) + "\n")
+		s.WriteString(separator + "\n")
+		s.WriteString(render.Truncate("📂 = Directory | 📄 = File | ↑↓ Navigate | → Enter directory | Enter = Select .conf file", width) + "\n")
+		s.WriteString(render.Truncate("Shortcuts: h = Home | Ctrl+H = Toggle hidden files | Ctrl+F = Toggle filters | Esc = Go back", width) + "\n")
+		s.WriteString(separator + "\n\n")
 
 		// Display files and directories (viewport only)
 		viewportEnd := m.viewportStart + m.viewportSize
@@ -386,11 +608,16 @@ func (m *UpdateModel) View() string {
 			viewportEnd = len(m.files)
 		}
 
-		// Show scrolling indicator if needed
-		if m.viewportStart > 0 {
+		// Show scrolling indicator if needed (the scrollbar does without)
+		if m.viewportStart > 0 && render.ASCII() {
 			s.WriteString("  ↑ (more files above)\n")
 		}
 
+		rowWidth := width
+		if !render.ASCII() && len(m.files) > viewportEnd-m.viewportStart {
+			rowWidth = width - 2 // room for the scrollbar
+		}
+		var lines []string
 		for i := m.viewportStart; i < viewportEnd; i++ {
 			file := m.files[i]
 			cursor := "  "
@@ -403,9 +630,13 @@ func (m *UpdateModel) View() string {
 				icon = "📂"
 			}
 
-			name := file.Name()
+			// Display only: the selection path uses file.Name() unchanged
+			nameWidth := rowWidth - 5 // cursor (2) + icon (2) + space
+			name := ""
 			if file.IsDir() {
-				name += "/"
+				name = render.FileName(file.Name(), nameWidth-1) + "/"
+			} else {
+				name = render.FileName(file.Name(), nameWidth)
 			}
 
 			// Highlight selected item
@@ -414,11 +645,14 @@ func (m *UpdateModel) View() string {
 				// Add some visual highlight for selected item
 				line = fmt.Sprintf("> %s %s", icon, name)
 			}
+			lines = append(lines, line)
+		}
+		for _, line := range render.WithScrollbar(lines, len(m.files), m.viewportStart, width) {
 			s.WriteString(line + "\n")
 		}
 
 		// Show scrolling indicator if there are more files below
-		if viewportEnd < len(m.files) {
+		if viewportEnd < len(m.files) && render.ASCII() {
 			s.WriteString("  ↓ (more files below)\n")
 		}
 
@@ -427,6 +661,21 @@ func (m *UpdateModel) View() string {
 		}
 
 		s.WriteString("Note: Navigate with ↑↓, Enter to select/enter directories")
+
+	case 5: // Session summary
+		width := m.width
+		if width <= 0 {
+			width = 80
+		}
+		s.WriteString("Applied this session:\n\n")
+		for _, result := range m.session {
+			icon := "✅"
+			if !result.OK {
+				icon = "❌"
+			}
+			s.WriteString(render.Truncate(fmt.Sprintf("%s %s ← %s: %s", icon, result.Environment, render.SanitizeName(abbreviateHome(result.Source)), result.Result), width) + "\n")
+		}
+		s.WriteString("\nUpdate another environment? (y/N)")
 	}
 
 	if m.message != "" {
@@ -445,3 +694,49 @@ func (m *UpdateModel) GetConfigPath() string {
 	return m.configPath
 }
 
+// ImportAsTemplate reports whether the user chose to import the file as a
+// template.
+func (m *UpdateModel) ImportAsTemplate() bool {
+	return m.asTemplate
+}
+
+
+// Hints reports the keys of the current stage for the controls panel.
+func (m *UpdateModel) Hints() render.HintGroup {
+	switch m.stage {
+	case 1:
+		group := render.HintGroup{Title: "Update Config"}
+		group.Add("↑/↓", "Choose")
+		group.Add("1/2", "Type a path / Browse")
+		if m.retry != nil {
+			group.Add("r", "Retry the last update")
+		}
+		group.Add("t", "Import as template")
+		group.Add("Enter", "Continue")
+		group.Add("Esc", "Cancel")
+		return group
+	case 2:
+		return render.HintGroup{Title: "Config Path", Hints: []render.Hint{
+			{Keys: "Tab", Action: "Complete the path"},
+			{Keys: "Enter", Action: "Use this file"},
+			{Keys: "Esc", Action: "Cancel"},
+		}}
+	case 5:
+		return render.HintGroup{Title: "Update Session", Hints: []render.Hint{
+			{Keys: "y", Action: "Update another environment"},
+			{Keys: "n/Enter", Action: "Done"},
+			{Keys: "Esc", Action: "Done"},
+		}}
+	case 3:
+		return render.HintGroup{Title: "File Browser", Hints: []render.Hint{
+			{Keys: "↑/↓", Action: "Navigate files"},
+			{Keys: "PgUp/PgDn", Action: "Page"},
+			{Keys: "Enter", Action: "Select/Enter dir"},
+			{Keys: "h", Action: "Home directory"},
+			{Keys: "Ctrl+H", Action: "Toggle hidden"},
+			{Keys: "Ctrl+F", Action: "Toggle filters"},
+			{Keys: "Esc", Action: "Cancel"},
+		}}
+	}
+	return render.HintGroup{Title: "Update Config", Hints: []render.Hint{{Keys: "Esc", Action: "Close"}}}
+}
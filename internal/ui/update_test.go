@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+func TestFileBrowserNames(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"julo-\x93vendor\x94.conf", // Windows-1252 quotes
+		strings.Repeat("very-long-vendor-name-", 9) + "prod.conf",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const width = 40
+
+	for _, name := range names {
+		t.Run(name[:10], func(t *testing.T) {
+			m := NewUpdateModel(nil)
+			m.currentDir, m.stage = dir, 3
+			if err := m.loadDirectory(); err != nil {
+				t.Fatal(err)
+			}
+			m.Update(tea.WindowSizeMsg{Width: width, Height: 30})
+			m.selectedIndex = -1
+			for i, file := range m.files {
+				if file.Name() == name {
+					m.selectedIndex = i
+				}
+			}
+			if m.selectedIndex < 0 {
+				t.Fatalf("%q not listed", name)
+			}
+
+			for _, line := range strings.Split(m.View(), "\n") {
+				if !strings.Contains(line, "📄") {
+					continue
+				}
+				if w := ansi.StringWidth(line); w > width {
+					t.Errorf("%q is %d cells wide", line, w)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("%q shows the invalid bytes", line)
+				}
+			}
+
+			// The display name is for display only
+			m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+			if got, want := m.GetConfigPath(), filepath.Join(dir, name); got != want {
+				t.Errorf("selected %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package vpn
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"time"
+
+	"tui-wireguard-vpn/internal/state"
+)
+
+// BandwidthWatch notices a tunnel carrying a sustained heavy transfer, such
+// as a multi-GB image pull through the gateway that a broad AllowedIPs range
+// caught by accident. Feed it every status sample.
+type BandwidthWatch struct {
+	// Threshold is the rate in bytes per second that counts as heavy; 0
+	// disables the watch.
+	Threshold float64
+	// Sustain is how long the rate has to hold before Observe warns.
+	Sustain time.Duration
+	// Grace is how long after connecting nothing counts, so the burst of a
+	// fresh connection never warns.
+	Grace time.Duration
+	// SessionStart returns when env's tunnel came up, if that is known, so
+	// the grace period of a tunnel that was up before the watch first saw it
+	// runs from there rather than from the first sample. nil means unknown.
+	SessionStart func(env Environment) (time.Time, bool)
+
+	env         Environment
+	connectedAt time.Time
+	lastBytes   uint64
+	lastAt      time.Time
+	aboveSince  time.Time // zero while below the threshold
+	aboveBytes  uint64    // bytes at aboveSince
+	warned      bool      // once per heavy stretch
+}
+
+// BandwidthWarning is a sustained heavy transfer through the tunnel.
+type BandwidthWarning struct {
+	Environment Environment
+	Rate        float64 // average bytes per second over For
+	For         time.Duration
+}
+
+func (w *BandwidthWarning) String() string {
+	return fmt.Sprintf("sustained %.0f MiB/s through VPN for %dm — intended?", w.Rate/(1<<20), int(w.For.Round(time.Minute)/time.Minute))
+}
+
+// NewBandwidthWatch watches for thresholdMiB MiB/s held for a minute, never
+// in the first minute after connecting.
+func NewBandwidthWatch(thresholdMiB int) *BandwidthWatch {
+	return &BandwidthWatch{
+		Threshold: float64(thresholdMiB) * (1 << 20),
+		Sustain:   time.Minute,
+		Grace:     time.Minute,
+		SessionStart: func(env Environment) (time.Time, bool) {
+			st, err := state.Load()
+			if err != nil || st.LastSession == nil || st.LastSession.Stopped || st.LastSession.Environment != string(env) {
+				return time.Time{}, false
+			}
+			return st.LastSession.ConnectedAt, true
+		},
+	}
+}
+
+// Observe takes a status sample taken at at and returns a warning when the
+// transfer rate has stayed above the threshold for Sustain. It warns once per
+// stretch; the rate has to drop below the threshold before it warns again.
+func (w *BandwidthWatch) Observe(status *ConnectionStatus, at time.Time) *BandwidthWarning {
+	if w.Threshold <= 0 || status == nil || !status.Connected {
+		w.env = ""
+		return nil
+	}
+	// The sum may wrap around; the deltas taken of it stay right
+	bytes := status.BytesRx + status.BytesTx
+	delta, ok := counterDelta(w.lastBytes, bytes)
+	// Another tunnel, or counters that went back down (the same one came
+	// up again): a new connection
+	if status.Environment != w.env || !ok {
+		connectedAt := at
+		if w.SessionStart != nil {
+			if started, ok := w.SessionStart(status.Environment); ok && started.Before(at) {
+				connectedAt = started
+			}
+		}
+		*w = BandwidthWatch{Threshold: w.Threshold, Sustain: w.Sustain, Grace: w.Grace, SessionStart: w.SessionStart,
+			env: status.Environment, connectedAt: connectedAt, lastBytes: bytes, lastAt: at}
+		return nil
+	}
+
+	elapsed := at.Sub(w.lastAt)
+	if elapsed <= 0 {
+		return nil
+	}
+	rate := float64(delta) / elapsed.Seconds()
+	switch {
+	case rate < w.Threshold || w.lastAt.Sub(w.connectedAt) < w.Grace:
+		w.aboveSince, w.warned = time.Time{}, false
+	case w.aboveSince.IsZero():
+		w.aboveSince, w.aboveBytes = w.lastAt, w.lastBytes
+	}
+	w.lastBytes, w.lastAt = bytes, at
+
+	if w.aboveSince.IsZero() || w.warned || at.Sub(w.aboveSince) < w.Sustain {
+		return nil
+	}
+	w.warned = true
+	stretch := at.Sub(w.aboveSince)
+	total, _ := counterDelta(w.aboveBytes, bytes)
+	return &BandwidthWarning{
+		Environment: w.env,
+		Rate:        float64(total) / stretch.Seconds(),
+		For:         stretch,
+	}
+}
+
+// RouteUsage is one of a tunnel's AllowedIPs with the connections it carries.
+type RouteUsage struct {
+	Prefix      netip.Prefix
+	Connections []Connection
+	// Broad marks a range reaching beyond private address space, e.g. a CDN
+	// block, which is where unintended heavy traffic usually comes from.
+	Broad bool
+}
+
+// RouteUsages matches connections to the routes carrying them, the most
+// specific route winning as in the kernel. The routes carrying connections
+// come first, busiest first; the rest keep their order.
+func RouteUsages(routes []netip.Prefix, connections []Connection) []RouteUsage {
+	usages := make([]RouteUsage, len(routes))
+	for i, route := range routes {
+		usages[i] = RouteUsage{Prefix: route, Broad: broadRoute(route)}
+	}
+	for _, connection := range connections {
+		best := -1
+		for i, route := range routes {
+			if route.Contains(connection.Remote.Addr()) && (best < 0 || route.Bits() > routes[best].Bits()) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			usages[best].Connections = append(usages[best].Connections, connection)
+		}
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		return len(usages[i].Connections) > len(usages[j].Connections)
+	})
+	return usages
+}
+
+// privateRanges are the address blocks a corporate network uses internally.
+var privateRanges = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("fc00::/7"),
+}
+
+// broadRoute reports whether route reaches public addresses: it is not
+// inside one of the private ranges.
+func broadRoute(route netip.Prefix) bool {
+	for _, private := range privateRanges {
+		if private.Bits() <= route.Bits() && private.Contains(route.Addr()) {
+			return false
+		}
+	}
+	return true
+}
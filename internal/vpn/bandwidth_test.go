@@ -0,0 +1,74 @@
+package vpn
+
+import (
+	"testing"
+	"time"
+
+	"tui-wireguard-vpn/internal/state"
+)
+
+// heavyFor feeds w 10 MiB/s samples every 10s for d, starting at at, and
+// returns the first warning.
+func heavyFor(w *BandwidthWatch, at time.Time, d time.Duration) *BandwidthWarning {
+	var bytes uint64
+	for elapsed := time.Duration(0); elapsed <= d; elapsed += 10 * time.Second {
+		status := &ConnectionStatus{Connected: true, Environment: Production, BytesRx: bytes}
+		if warning := w.Observe(status, at.Add(elapsed)); warning != nil {
+			return warning
+		}
+		bytes += 100 << 20
+	}
+	return nil
+}
+
+func TestBandwidthWatchGrace(t *testing.T) {
+	t0 := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		started func(Environment) (time.Time, bool)
+		warnAt  time.Duration // 0 for no warning within 90s
+	}{
+		{"fresh connection", nil, 0},
+		{"connected an hour before", func(Environment) (time.Time, bool) { return t0.Add(-time.Hour), true }, time.Minute},
+		{"connected just before", func(Environment) (time.Time, bool) { return t0.Add(-10 * time.Second), true }, 0},
+		{"another session", func(Environment) (time.Time, bool) { return time.Time{}, false }, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewBandwidthWatch(5)
+			w.SessionStart = tt.started
+			warning := heavyFor(w, t0, 90*time.Second)
+			switch {
+			case tt.warnAt == 0 && warning != nil:
+				t.Errorf("warned %s within the grace period", warning)
+			case tt.warnAt != 0 && (warning == nil || warning.For != tt.warnAt):
+				t.Errorf("Observe() = %v, want a warning after %s", warning, tt.warnAt)
+			}
+		})
+	}
+}
+
+// The grace period of a tunnel the app attaches to runs from the recorded
+// connect time.
+func TestBandwidthWatchSessionStart(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	w := NewBandwidthWatch(5)
+	if _, ok := w.SessionStart(Production); ok {
+		t.Error("a session known without one recorded")
+	}
+	if err := state.RecordConnected(string(Production)); err != nil {
+		t.Fatal(err)
+	}
+	if started, ok := w.SessionStart(Production); !ok || time.Since(started) > time.Minute {
+		t.Errorf("SessionStart() = %s, %t; want just now", started, ok)
+	}
+	if _, ok := w.SessionStart(NonProduction); ok {
+		t.Error("another environment's session counted")
+	}
+	if err := state.RecordStopped(string(Production)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.SessionStart(Production); ok {
+		t.Error("a stopped session counted")
+	}
+}
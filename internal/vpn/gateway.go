@@ -0,0 +1,106 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const gatewayLookupTimeout = 5 * time.Second
+
+// Resolver is the subset of net.Resolver used for gateway checks.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// GatewayMigration describes an installed config whose numeric Endpoint no
+// longer matches what the environment's canonical hostname resolves to.
+type GatewayMigration struct {
+	Environment  Environment
+	Hostname     string
+	ConfiguredIP string
+	Port         string
+	ResolvedIPs  []string
+}
+
+// NewEndpoint returns the host:port the config should be updated to, picking
+// a resolved address of the same family as the configured one when possible.
+func (g *GatewayMigration) NewEndpoint() string {
+	configured := net.ParseIP(g.ConfiguredIP)
+	for _, ip := range g.ResolvedIPs {
+		parsed := net.ParseIP(ip)
+		if parsed != nil && configured != nil && (parsed.To4() != nil) == (configured.To4() != nil) {
+			return net.JoinHostPort(ip, g.Port)
+		}
+	}
+	return net.JoinHostPort(g.ResolvedIPs[0], g.Port)
+}
+
+func (g *GatewayMigration) String() string {
+	return fmt.Sprintf("gateway appears to have moved (config says %s, DNS says %s)",
+		g.ConfiguredIP, strings.Join(g.ResolvedIPs, ", "))
+}
+
+// CheckGateway resolves hostname and compares it against the configured
+// host:port endpoint. It returns nil when the endpoint is still current, when
+// the configured endpoint is not a numeric address, or when resolution fails
+// (the error is returned so callers can log it, but it never signals a move).
+func CheckGateway(ctx context.Context, resolver Resolver, env Environment, hostname, configuredEndpoint string) (*GatewayMigration, error) {
+	host, port, err := net.SplitHostPort(configuredEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %v", configuredEndpoint, err)
+	}
+	configuredIP := net.ParseIP(host)
+	if configuredIP == nil {
+		// Hostname endpoints are resolved by wg-quick itself and can't go stale
+		return nil, nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, gatewayLookupTimeout)
+	defer cancel()
+	addrs, err := resolver.LookupHost(lookupCtx, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", hostname, err)
+	}
+
+	var resolved []string
+	for _, addr := range addrs {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			continue
+		}
+		if ip.Equal(configuredIP) {
+			return nil, nil
+		}
+		resolved = append(resolved, ip.String())
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", hostname)
+	}
+
+	return &GatewayMigration{
+		Environment:  env,
+		Hostname:     hostname,
+		ConfiguredIP: configuredIP.String(),
+		Port:         port,
+		ResolvedIPs:  resolved,
+	}, nil
+}
+
+// DetectGatewayMigration checks the installed config for env against its
+// canonical hostname using the system resolver.
+func DetectGatewayMigration(ctx context.Context, env Environment, hostname string) (*GatewayMigration, error) {
+	endpoint, err := configs.InstalledEndpoint(string(env))
+	if err != nil {
+		return nil, err
+	}
+	return CheckGateway(ctx, net.DefaultResolver, env, hostname, endpoint)
+}
+
+// ApplyGatewayMigration rewrites the installed config's Endpoint to the newly
+// resolved address. The previous config is backed up.
+func ApplyGatewayMigration(migration *GatewayMigration) error {
+	return configs.UpdateEndpoint(string(migration.Environment), migration.NewEndpoint())
+}
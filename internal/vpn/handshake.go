@@ -0,0 +1,58 @@
+package vpn
+
+import "time"
+
+// RekeyAfterTime is the WireGuard protocol's REKEY_AFTER_TIME: a session
+// carrying traffic gets a fresh handshake this long after the last one.
+const RekeyAfterTime = 120 * time.Second
+
+// HandshakeState is where a tunnel stands in its rekey cycle.
+type HandshakeState int
+
+const (
+	// HandshakeExpected means the next handshake is due in Next
+	HandshakeExpected HandshakeState = iota
+	// HandshakeOverdue means the handshake was due Next ago, though the
+	// tunnel is still within DefaultHandshakeAge
+	HandshakeOverdue
+	// HandshakeStale means the last handshake is older than
+	// DefaultHandshakeAge
+	HandshakeStale
+	// HandshakeIdle means no traffic moved since the last sample while the
+	// handshake was still expected: without traffic WireGuard doesn't rekey
+	HandshakeIdle
+)
+
+// HandshakeEstimate is when the next handshake of a tunnel is expected.
+type HandshakeEstimate struct {
+	State HandshakeState
+	Age   time.Duration // since the last handshake
+	// Next is the time until the expected handshake (HandshakeExpected) or
+	// since it was due (HandshakeOverdue, HandshakeStale); 0 when idle
+	Next time.Duration
+}
+
+// EstimateHandshake works out the next handshake from the last one, seen at
+// lastSeen, assuming the rekey every RekeyAfterTime that traffic brings.
+// idle is whether the transfer counters stood still since the previous
+// status sample. A single quiet sample says little, so idle only relabels a
+// handshake that is still expected; an overdue or stale one is reported as
+// such.
+func EstimateHandshake(lastSeen time.Time, idle bool, now time.Time) HandshakeEstimate {
+	age := max(now.Sub(lastSeen), 0)
+	switch {
+	case age > DefaultHandshakeAge:
+		return HandshakeEstimate{State: HandshakeStale, Age: age, Next: age - RekeyAfterTime}
+	case age >= RekeyAfterTime:
+		return HandshakeEstimate{State: HandshakeOverdue, Age: age, Next: age - RekeyAfterTime}
+	case idle:
+		return HandshakeEstimate{State: HandshakeIdle, Age: age}
+	}
+	return HandshakeEstimate{State: HandshakeExpected, Age: age, Next: RekeyAfterTime - age}
+}
+
+// Idle reports whether a rate shows no traffic at all; nil (no sample to
+// compare with yet) isn't idle.
+func (r *TransferRate) Idle() bool {
+	return r != nil && r.Rx == 0 && r.Tx == 0
+}
@@ -0,0 +1,48 @@
+package vpn
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateHandshake(t *testing.T) {
+	now := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		age  time.Duration
+		idle bool
+		want HandshakeEstimate
+	}{
+		{"just now", 0, false, HandshakeEstimate{State: HandshakeExpected, Next: RekeyAfterTime}},
+		{"expected", 45 * time.Second, false, HandshakeEstimate{State: HandshakeExpected, Age: 45 * time.Second, Next: 75 * time.Second}},
+		{"due now", RekeyAfterTime, false, HandshakeEstimate{State: HandshakeOverdue, Age: RekeyAfterTime}},
+		{"overdue", 150 * time.Second, false, HandshakeEstimate{State: HandshakeOverdue, Age: 150 * time.Second, Next: 30 * time.Second}},
+		{"at the stale limit", DefaultHandshakeAge, false, HandshakeEstimate{State: HandshakeOverdue, Age: DefaultHandshakeAge, Next: DefaultHandshakeAge - RekeyAfterTime}},
+		{"stale", 190 * time.Second, false, HandshakeEstimate{State: HandshakeStale, Age: 190 * time.Second, Next: 70 * time.Second}},
+		{"idle while expected", 45 * time.Second, true, HandshakeEstimate{State: HandshakeIdle, Age: 45 * time.Second}},
+		{"idle while overdue", 150 * time.Second, true, HandshakeEstimate{State: HandshakeOverdue, Age: 150 * time.Second, Next: 30 * time.Second}},
+		{"idle while stale", 10 * time.Minute, true, HandshakeEstimate{State: HandshakeStale, Age: 10 * time.Minute, Next: 8 * time.Minute}},
+		{"in the future", -5 * time.Second, false, HandshakeEstimate{State: HandshakeExpected, Next: RekeyAfterTime}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := EstimateHandshake(now.Add(-tt.age), tt.idle, now)
+			if got != tt.want {
+				t.Errorf("EstimateHandshake(age %v, idle %v) = %+v, want %+v", tt.age, tt.idle, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferRateIdle(t *testing.T) {
+	var none *TransferRate
+	if none.Idle() {
+		t.Error("a missing rate is idle")
+	}
+	if !(&TransferRate{}).Idle() {
+		t.Error("a zero rate isn't idle")
+	}
+	if (&TransferRate{Rx: 12}).Idle() {
+		t.Error("a receiving tunnel is idle")
+	}
+}
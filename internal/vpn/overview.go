@@ -0,0 +1,168 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/location"
+	"tui-wireguard-vpn/internal/probe"
+)
+
+// Upper bound for collecting a whole network overview
+const overviewTimeout = 10 * time.Second
+
+// EnvOverview is what connecting to one environment right now would involve.
+type EnvOverview struct {
+	Environment Environment
+	Installed   bool
+	Endpoint    string
+	Routes      []string // AllowedIPs prefixes of the installed config
+	Reach       probe.Reachability
+	// ShadowsLocal means one of the routes covers the local address, so
+	// connecting would capture traffic to the local network
+	ShadowsLocal bool
+	Err          error // the installed config couldn't be read
+}
+
+// Verdict sums up in a few words what connecting would do.
+func (e EnvOverview) Verdict(udpWorks bool) string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("config unreadable: %v", e.Err)
+	case !e.Installed:
+		return "not installed"
+	case e.Endpoint == "":
+		return "config has no Endpoint"
+	case !udpWorks:
+		return "UDP blocked on this network"
+	case e.Reach.Refused:
+		return "nothing listening on the gateway port"
+	case !e.Reach.HostReachable:
+		return "gateway not answering (it may still accept WireGuard)"
+	case e.ShadowsLocal:
+		return "reachable, but routes cover your local network"
+	}
+	return "reachable"
+}
+
+// LocalNetwork describes the network the machine is on outside any tunnel.
+type LocalNetwork struct {
+	Interface string // interface of the default route, "" when offline
+	Address   string
+	// OfficeSubnet is the configured office subnet the address is in, ""
+	// when none matches
+	OfficeSubnet string
+}
+
+// NetworkOverview answers "what would happen if I connected right now"
+// without changing any connection.
+type NetworkOverview struct {
+	UDPWorks     bool
+	Environments []EnvOverview
+	Active       *ConnectionStatus // nil when the status couldn't be read
+	StatusErr    error
+	Local        LocalNetwork
+	Collected    time.Time
+}
+
+// CollectOverview probes both environments' endpoints and gathers the local
+// network context. Probes run concurrently and stop when ctx is cancelled or
+// after overviewTimeout; whatever wasn't learned by then reads as
+// unreachable.
+func CollectOverview(ctx context.Context, svc Service, officeSubnets []string) *NetworkOverview {
+	ctx, cancel := context.WithTimeout(ctx, overviewTimeout)
+	defer cancel()
+
+	overview := &NetworkOverview{Local: localNetwork(officeSubnets)}
+	prober := probe.NewUDPProber()
+	envs := Environments()
+	overview.Environments = make([]EnvOverview, len(envs))
+
+	var wg sync.WaitGroup
+	wg.Add(len(envs) + 2)
+	go func() {
+		defer wg.Done()
+		overview.UDPWorks = prober.UDPWorks(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		overview.Active, overview.StatusErr = svc.GetStatus()
+	}()
+	for i, env := range envs {
+		go func(i int, env Environment) {
+			defer wg.Done()
+			overview.Environments[i] = inspectEnvironment(ctx, prober, env, overview.Local.Address)
+		}(i, env)
+	}
+	wg.Wait()
+
+	overview.Collected = time.Now()
+	return overview
+}
+
+func inspectEnvironment(ctx context.Context, prober *probe.UDPProber, env Environment, localAddr string) EnvOverview {
+	result := EnvOverview{Environment: env}
+	content, err := configs.ReadInstalled(core.ConfigFile(env))
+	if err != nil {
+		// Without read access the error comes from "sudo cat"
+		if !os.IsNotExist(err) && !strings.Contains(err.Error(), "No such file") {
+			result.Err = err
+		}
+		return result
+	}
+	result.Installed = true
+
+	local, _ := netip.ParseAddr(localAddr)
+	for _, line := range strings.Split(string(content), "\n") {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Endpoint":
+			result.Endpoint = strings.TrimSpace(value)
+		case "AllowedIPs":
+			for _, item := range strings.Split(value, ",") {
+				prefix, err := netip.ParsePrefix(strings.TrimSpace(item))
+				if err != nil {
+					continue
+				}
+				result.Routes = append(result.Routes, prefix.String())
+				// A default route is the point of a full tunnel, not a clash
+				if local.IsValid() && prefix.Bits() > 0 && prefix.Contains(local) {
+					result.ShadowsLocal = true
+				}
+			}
+		}
+	}
+
+	if result.Endpoint != "" {
+		result.Reach = prober.Reach(ctx, result.Endpoint)
+	}
+	return result
+}
+
+// localNetwork describes the default route and whether it is on one of the
+// office subnets.
+func localNetwork(officeSubnets []string) LocalNetwork {
+	var local LocalNetwork
+	local.Interface, local.Address = location.DefaultRoute()
+	if local.Address == "" {
+		return local
+	}
+
+	ip, _ := netip.ParseAddr(local.Address)
+	for _, subnet := range officeSubnets {
+		if prefix, err := netip.ParsePrefix(subnet); err == nil && prefix.Contains(ip.Unmap()) {
+			local.OfficeSubnet = prefix.String()
+			break
+		}
+	}
+	return local
+}
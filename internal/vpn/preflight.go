@@ -0,0 +1,313 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/doctor"
+	"tui-wireguard-vpn/internal/probe"
+)
+
+// PreflightTimeout bounds a whole preflight run; checks still running then
+// fail as timed out.
+const PreflightTimeout = 10 * time.Second
+
+// A clock this far off makes preflight warn, then fail: one-time codes are
+// only accepted 30 seconds either way, and TLS starts to object too.
+const (
+	clockWarnOffset = 5 * time.Second
+	clockFailOffset = 30 * time.Second
+)
+
+// PreflightSpec is what a profile's preflight covers, from its
+// preflight_checks, preflight_dns and preflight_routes settings.
+type PreflightSpec struct {
+	Checks      []string // by name, see settings.PreflightCheckNames; empty runs all
+	DNSNames    []string
+	Routes      []netip.Prefix
+	ClockServer string // NTP host:port, probe.DefaultClockServer when ""
+}
+
+func (s PreflightSpec) runs(check string) bool {
+	return len(s.Checks) == 0 || slices.Contains(s.Checks, check)
+}
+
+// PreflightResult is a preflight run: its checks in display order.
+type PreflightResult struct {
+	Environment Environment
+	Checks      []doctor.Check
+	Took        time.Duration
+}
+
+// Failed reports whether any check failed; warnings don't fail a preflight.
+func (r *PreflightResult) Failed() bool {
+	return doctor.Failed(r.Checks)
+}
+
+// FailedNames lists the checks that failed.
+func (r *PreflightResult) FailedNames() []string {
+	var names []string
+	for _, check := range r.Checks {
+		if check.Status == doctor.Fail {
+			names = append(names, check.Name)
+		}
+	}
+	return names
+}
+
+// Summary is the overall verdict line, e.g. "PASS: Production is ready (8
+// checks, 3.2s)" or "FAIL: 2 of 8 checks failed (Clock, Handshake)".
+func (r *PreflightResult) Summary() string {
+	took := r.Took.Round(100 * time.Millisecond)
+	if failed := r.FailedNames(); len(failed) > 0 {
+		return fmt.Sprintf("FAIL: %d of %d checks failed (%s), %s", len(failed), len(r.Checks), strings.Join(failed, ", "), took)
+	}
+	return fmt.Sprintf("PASS: %s is ready (%d checks, %s)", r.Environment.DisplayName(), len(r.Checks), took)
+}
+
+// Record appends the run to the audit log, failed when any check failed.
+func (r *PreflightResult) Record() {
+	passed := 0
+	for _, check := range r.Checks {
+		if check.Status != doctor.Fail {
+			passed++
+		}
+	}
+	target := fmt.Sprintf("%d/%d passed in %s", passed, len(r.Checks), r.Took.Round(100*time.Millisecond))
+	_ = audit.Run(audit.ActionPreflight, string(r.Environment), target, func() error {
+		if failed := r.FailedNames(); len(failed) > 0 {
+			return fmt.Errorf("failed: %s", strings.Join(failed, ", "))
+		}
+		return nil
+	})
+}
+
+// preflightCheck is one check of a run, named for the display order before
+// it has a result.
+type preflightCheck struct {
+	name string
+	run  func() doctor.Check
+}
+
+// Preflight checks that env is ready to be relied on right now: its
+// config is installed and valid, the gateway answers, the clock is right,
+// the profile's internal names resolve and its critical prefixes are
+// routed through the tunnel, and, when it is connected, the handshake is
+// fresh. The checks run concurrently within PreflightTimeout and never
+// change a connection.
+func Preflight(ctx context.Context, svc Service, env Environment, spec PreflightSpec, prober *probe.UDPProber) *PreflightResult {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, PreflightTimeout)
+	defer cancel()
+
+	// What several checks need is looked up once
+	installed := sync.OnceValue(func() EnvOverview {
+		return inspectEnvironment(ctx, prober, env, "")
+	})
+	status := sync.OnceValues(func() (*ConnectionStatus, error) {
+		tunnels, err := svc.Tunnels(ctx)
+		for _, tunnel := range tunnels {
+			if tunnel.Environment == env {
+				return tunnel, err
+			}
+		}
+		return nil, err
+	})
+
+	var checks []preflightCheck
+	if spec.runs("config") {
+		checks = append(checks, preflightCheck{"Config", func() doctor.Check { return preflightConfig(env, installed()) }})
+	}
+	if spec.runs("endpoint") {
+		checks = append(checks, preflightCheck{"Endpoint", func() doctor.Check {
+			connected, _ := status()
+			return preflightEndpoint(ctx, prober, installed(), connected)
+		}})
+	}
+	if spec.runs("clock") {
+		server := spec.ClockServer
+		if server == "" {
+			server = probe.DefaultClockServer
+		}
+		checks = append(checks, preflightCheck{"Clock", func() doctor.Check { return preflightClock(ctx, prober, server) }})
+	}
+	if spec.runs("dns") {
+		for _, name := range spec.DNSNames {
+			checks = append(checks, preflightCheck{"DNS " + name, func() doctor.Check {
+				connected, _ := status()
+				return preflightDNS(ctx, name, connected != nil)
+			}})
+		}
+	}
+	if spec.runs("routes") {
+		for _, prefix := range spec.Routes {
+			checks = append(checks, preflightCheck{"Route " + prefix.String(), func() doctor.Check {
+				connected, _ := status()
+				return preflightRoute(ctx, svc, env, prefix, installed(), connected)
+			}})
+		}
+	}
+	if spec.runs("handshake") {
+		checks = append(checks, preflightCheck{"Handshake", func() doctor.Check {
+			connected, err := status()
+			return preflightHandshake(env, connected, err)
+		}})
+	}
+
+	var mu sync.Mutex
+	results := make([]*doctor.Check, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := check.run()
+			result.Name = check.name
+			mu.Lock()
+			results[i] = &result
+			mu.Unlock()
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Whatever still runs is abandoned; its result comes too late
+	}
+
+	result := &PreflightResult{Environment: env, Took: time.Since(started)}
+	mu.Lock()
+	defer mu.Unlock()
+	for i, check := range checks {
+		if results[i] == nil {
+			result.Checks = append(result.Checks, doctor.Check{Name: check.name, Status: doctor.Fail, Detail: fmt.Sprintf("timed out after %s", PreflightTimeout)})
+			continue
+		}
+		result.Checks = append(result.Checks, *results[i])
+	}
+	return result
+}
+
+func preflightConfig(env Environment, installed EnvOverview) doctor.Check {
+	switch {
+	case installed.Err != nil:
+		return doctor.Check{Status: doctor.Fail, Detail: fmt.Sprintf("unreadable: %v", installed.Err)}
+	case !installed.Installed:
+		return doctor.Check{Status: doctor.Fail, Detail: "not installed — run the setup"}
+	}
+	name := core.ConfigFile(env)
+	content, err := configs.ReadInstalled(name)
+	if err != nil {
+		return doctor.Check{Status: doctor.Fail, Detail: fmt.Sprintf("unreadable: %v", err)}
+	}
+	if err := configs.ValidateInstalled(name, string(content)); err != nil {
+		return doctor.Check{Status: doctor.Fail, Detail: err.Error()}
+	}
+	return doctor.Check{Status: doctor.OK, Detail: fmt.Sprintf("%s installed, %d route(s)", name, len(installed.Routes))}
+}
+
+// preflightEndpoint probes the gateway like the network overview. A tunnel
+// to it with a handshake answers the question already.
+func preflightEndpoint(ctx context.Context, prober *probe.UDPProber, installed EnvOverview, connected *ConnectionStatus) doctor.Check {
+	if connected != nil && Healthy(connected, connected.Environment, DefaultHandshakeAge) {
+		return doctor.Check{Status: doctor.OK, Detail: fmt.Sprintf("%s answers through the tunnel", connected.Endpoint)}
+	}
+	switch {
+	case !installed.Installed:
+		return doctor.Check{Status: doctor.Fail, Detail: "no installed config to take it from"}
+	case installed.Endpoint == "":
+		return doctor.Check{Status: doctor.Fail, Detail: "the config has no Endpoint"}
+	case installed.Reach.Refused:
+		return doctor.Check{Status: doctor.Fail, Detail: fmt.Sprintf("nothing listens on %s", installed.Endpoint)}
+	case installed.Reach.HostReachable:
+		return doctor.Check{Status: doctor.OK, Detail: fmt.Sprintf("%s reachable (%s)", installed.Endpoint, installed.Reach.Latency.Round(time.Millisecond))}
+	case !prober.UDPWorks(ctx):
+		return doctor.Check{Status: doctor.Fail, Detail: "UDP appears blocked on this network"}
+	}
+	// WireGuard stays silent to strangers, so this is all that can be said
+	return doctor.Check{Status: doctor.Warn, Detail: fmt.Sprintf("%s not answering TCP (it may still accept WireGuard)", installed.Endpoint)}
+}
+
+func preflightClock(ctx context.Context, prober *probe.UDPProber, server string) doctor.Check {
+	offset, err := prober.ClockOffset(ctx, server)
+	if err != nil {
+		return doctor.Check{Status: doctor.Warn, Detail: fmt.Sprintf("could not ask %s: %v", server, err)}
+	}
+	off := offset.Abs()
+	detail := fmt.Sprintf("%s off %s", offset.Round(time.Millisecond), server)
+	switch {
+	case off > clockFailOffset:
+		return doctor.Check{Status: doctor.Fail, Detail: detail + " — one-time codes will be rejected"}
+	case off > clockWarnOffset:
+		return doctor.Check{Status: doctor.Warn, Detail: detail}
+	}
+	return doctor.Check{Status: doctor.OK, Detail: detail}
+}
+
+// preflightDNS resolves an internal name. While disconnected a failure is
+// only a warning: the name may only resolve through the tunnel's DNS.
+func preflightDNS(ctx context.Context, name string, connected bool) doctor.Check {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+	switch {
+	case err == nil && len(addrs) > 0:
+		return doctor.Check{Status: doctor.OK, Detail: strings.Join(addrs, ", ")}
+	case connected:
+		return doctor.Check{Status: doctor.Fail, Detail: fmt.Sprintf("does not resolve: %v", err)}
+	}
+	return doctor.Check{Status: doctor.Warn, Detail: "does not resolve while disconnected"}
+}
+
+// preflightRoute checks that prefix is among the config's AllowedIPs and,
+// when connected, that the kernel sends its traffic into the tunnel.
+func preflightRoute(ctx context.Context, svc Service, env Environment, prefix netip.Prefix, installed EnvOverview, connected *ConnectionStatus) doctor.Check {
+	if !installed.Installed {
+		return doctor.Check{Status: doctor.Fail, Detail: "no installed config to check"}
+	}
+	var covering string
+	for _, route := range installed.Routes {
+		if allowed, err := netip.ParsePrefix(route); err == nil && allowed.Bits() <= prefix.Bits() && allowed.Contains(prefix.Addr()) {
+			covering = route
+			break
+		}
+	}
+	if covering == "" {
+		return doctor.Check{Status: doctor.Fail, Detail: "not in the config's AllowedIPs"}
+	}
+	if connected == nil {
+		return doctor.Check{Status: doctor.OK, Detail: fmt.Sprintf("in AllowedIPs (%s); the kernel route is checked while connected", covering)}
+	}
+	decision, err := svc.CheckRoute(ctx, env, prefix.Addr().String())
+	switch {
+	case err != nil:
+		return doctor.Check{Status: doctor.Warn, Detail: fmt.Sprintf("in AllowedIPs (%s), kernel route unknown: %v", covering, err)}
+	case !decision.KernelTunnel:
+		return doctor.Check{Status: doctor.Fail, Detail: fmt.Sprintf("in AllowedIPs but the kernel sends it out %s", decision.Device)}
+	}
+	return doctor.Check{Status: doctor.OK, Detail: fmt.Sprintf("through %s (%s)", decision.Device, covering)}
+}
+
+func preflightHandshake(env Environment, connected *ConnectionStatus, err error) doctor.Check {
+	switch {
+	case err != nil:
+		return doctor.Check{Status: doctor.Warn, Detail: fmt.Sprintf("status unavailable: %v", err)}
+	case connected == nil:
+		return doctor.Check{Status: doctor.NotApplicable, Detail: "not connected"}
+	case connected.LastSeen == nil:
+		return doctor.Check{Status: doctor.Fail, Detail: "connected but no handshake yet"}
+	case !Healthy(connected, env, DefaultHandshakeAge):
+		return doctor.Check{Status: doctor.Fail, Detail: fmt.Sprintf("%s old (at most %s)", time.Since(*connected.LastSeen).Truncate(time.Second), DefaultHandshakeAge)}
+	}
+	return doctor.Check{Status: doctor.OK, Detail: fmt.Sprintf("%s ago", time.Since(*connected.LastSeen).Truncate(time.Second))}
+}
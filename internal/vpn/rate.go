@@ -0,0 +1,66 @@
+package vpn
+
+import "time"
+
+// TransferRate is a tunnel's throughput between two status samples.
+type TransferRate struct {
+	Rx float64 // bytes per second received
+	Tx float64 // bytes per second sent
+}
+
+// RateMeter turns the cumulative transfer counters of successive status
+// samples into a rate. Feed it every status sample, whatever took it: the
+// auto-refresh timer or a Refresh.
+type RateMeter struct {
+	env    Environment
+	rx, tx uint64
+	at     time.Time
+	rate   *TransferRate
+}
+
+// counterDelta is how far a cumulative byte counter moved from prev to cur.
+// A counter that passed the top of the uint64 range and wrapped around
+// still gives the right delta, as modular arithmetic does. Any other
+// counter that went back down was reset (the interface came up again), and
+// ok is false.
+func counterDelta(prev, cur uint64) (delta uint64, ok bool) {
+	delta = cur - prev
+	return delta, cur >= prev || delta < 1<<62
+}
+
+// Observe takes a status sample taken at at and returns the rate since the
+// previous one, nil while there is nothing to compare with (the first sample
+// of a connection). Counters that went back down without wrapping around
+// mean the interface was brought up again; the rate is 0 then.
+func (r *RateMeter) Observe(status *ConnectionStatus, at time.Time) *TransferRate {
+	if status == nil || !status.Connected {
+		*r = RateMeter{}
+		return nil
+	}
+	if status.Environment != r.env || r.at.IsZero() {
+		*r = RateMeter{env: status.Environment, rx: status.BytesRx, tx: status.BytesTx, at: at}
+		return nil
+	}
+
+	elapsed := at.Sub(r.at)
+	if elapsed <= 0 {
+		return r.rate
+	}
+	rx, rxOK := counterDelta(r.rx, status.BytesRx)
+	tx, txOK := counterDelta(r.tx, status.BytesTx)
+	if !rxOK || !txOK {
+		r.rate = &TransferRate{}
+	} else {
+		r.rate = &TransferRate{
+			Rx: float64(rx) / elapsed.Seconds(),
+			Tx: float64(tx) / elapsed.Seconds(),
+		}
+	}
+	r.rx, r.tx, r.at = status.BytesRx, status.BytesTx, at
+	return r.rate
+}
+
+// Rate returns the rate of the latest sample, nil when there is none.
+func (r *RateMeter) Rate() *TransferRate {
+	return r.rate
+}
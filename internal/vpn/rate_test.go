@@ -0,0 +1,62 @@
+package vpn
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur uint64
+		delta     uint64
+		ok        bool
+	}{
+		{"unchanged", 1000, 1000, 0, true},
+		{"grew", 1000, 5096, 4096, true},
+		{"large", 1 << 40, 1<<40 + 3, 3, true},
+		{"wrapped around", math.MaxUint64 - 9, 20, 30, true},
+		{"reset", 7319060, 92, 0, false},
+		{"reset near the top", math.MaxUint64 - 9, math.MaxUint64 / 2, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delta, ok := counterDelta(tt.prev, tt.cur)
+			if ok != tt.ok || (ok && delta != tt.delta) {
+				t.Errorf("counterDelta(%d, %d) = %d, %v; want %d, %v", tt.prev, tt.cur, delta, ok, tt.delta, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRateMeter(t *testing.T) {
+	t0 := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	sample := func(env Environment, rx, tx uint64) *ConnectionStatus {
+		return &ConnectionStatus{Connected: true, Environment: env, BytesRx: rx, BytesTx: tx}
+	}
+	steps := []struct {
+		name   string
+		status *ConnectionStatus
+		at     time.Duration
+		want   *TransferRate
+	}{
+		{"first sample", sample(Production, 1000, 500), 0, nil},
+		{"two seconds later", sample(Production, 5096, 1524), 2 * time.Second, &TransferRate{Rx: 2048, Tx: 512}},
+		{"same instant", sample(Production, 9999, 9999), 2 * time.Second, &TransferRate{Rx: 2048, Tx: 512}},
+		{"reset", sample(Production, 92, 180), 3 * time.Second, &TransferRate{}},
+		{"after the reset", sample(Production, 1116, 180), 4 * time.Second, &TransferRate{Rx: 1024}},
+		{"near the top", sample(Production, math.MaxUint64-999, 180), 5 * time.Second, &TransferRate{Rx: math.MaxUint64 - 999 - 1116}},
+		{"wrapped around", sample(Production, 1000, 180), 6 * time.Second, &TransferRate{Rx: 2000}},
+		{"another tunnel", sample(NonProduction, 50, 50), 7 * time.Second, nil},
+		{"disconnected", &ConnectionStatus{}, 8 * time.Second, nil},
+		{"reconnected", sample(NonProduction, 60, 60), 9 * time.Second, nil},
+	}
+	var meter RateMeter
+	for _, s := range steps {
+		got := meter.Observe(s.status, t0.Add(s.at))
+		if (got == nil) != (s.want == nil) || (got != nil && *got != *s.want) {
+			t.Fatalf("%s: Observe() = %+v, want %+v", s.name, got, s.want)
+		}
+	}
+}
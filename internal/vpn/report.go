@@ -0,0 +1,37 @@
+package vpn
+
+import "time"
+
+// StatusReport is the tunnel status as the status subcommand prints it with
+// --json, for scripts and status bars. The field names are kept stable.
+type StatusReport struct {
+	Connected   bool        `json:"connected"`
+	Environment Environment `json:"environment"` // "" when disconnected
+	Interface   string      `json:"interface"`
+	Endpoint    string      `json:"endpoint"`
+	// LastHandshakeSeconds is how long ago the latest handshake was; null
+	// when disconnected or before the first one
+	LastHandshakeSeconds *int64 `json:"last_handshake_seconds"`
+	RxBytes              uint64 `json:"rx_bytes"`
+	TxBytes              uint64 `json:"tx_bytes"`
+}
+
+// NewStatusReport reports status as of now.
+func NewStatusReport(status *ConnectionStatus, now time.Time) StatusReport {
+	if status == nil || !status.Connected {
+		return StatusReport{}
+	}
+	report := StatusReport{
+		Connected:   true,
+		Environment: status.Environment,
+		Interface:   status.Interface,
+		Endpoint:    status.Endpoint,
+		RxBytes:     status.BytesRx,
+		TxBytes:     status.BytesTx,
+	}
+	if status.LastSeen != nil {
+		seconds := int64(max(now.Sub(*status.LastSeen), 0) / time.Second)
+		report.LastHandshakeSeconds = &seconds
+	}
+	return report
+}
@@ -0,0 +1,65 @@
+package vpn
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// TestStatusReportJSON pins the documented shape of status --json: scripts
+// and status bars read these names.
+func TestStatusReportJSON(t *testing.T) {
+	now := time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)
+	lastSeen := now.Add(-42 * time.Second)
+	future := now.Add(3 * time.Second) // a clock that stepped back
+	tests := []struct {
+		name   string
+		status *ConnectionStatus
+		want   string
+	}{
+		{
+			name: "connected",
+			status: &ConnectionStatus{
+				Connected:   true,
+				Environment: Production,
+				Interface:   "julo-prod",
+				Endpoint:    "34.101.166.184:51820",
+				LastSeen:    &lastSeen,
+				BytesRx:     7319060,
+				BytesTx:     24568135,
+				ListenPort:  41414,
+			},
+			want: `{"connected":true,"environment":"prod","interface":"julo-prod","endpoint":"34.101.166.184:51820","last_handshake_seconds":42,"rx_bytes":7319060,"tx_bytes":24568135}`,
+		},
+		{
+			name:   "no handshake yet",
+			status: &ConnectionStatus{Connected: true, Environment: NonProduction, Interface: "julo-nonprod"},
+			want:   `{"connected":true,"environment":"nonprod","interface":"julo-nonprod","endpoint":"","last_handshake_seconds":null,"rx_bytes":0,"tx_bytes":0}`,
+		},
+		{
+			name:   "handshake in the future",
+			status: &ConnectionStatus{Connected: true, Environment: Production, Interface: "julo-prod", LastSeen: &future},
+			want:   `{"connected":true,"environment":"prod","interface":"julo-prod","endpoint":"","last_handshake_seconds":0,"rx_bytes":0,"tx_bytes":0}`,
+		},
+		{
+			name:   "disconnected",
+			status: &ConnectionStatus{Connected: false, Interface: "julo-prod"},
+			want:   `{"connected":false,"environment":"","interface":"","endpoint":"","last_handshake_seconds":null,"rx_bytes":0,"tx_bytes":0}`,
+		},
+		{
+			name: "no status",
+			want: `{"connected":false,"environment":"","interface":"","endpoint":"","last_handshake_seconds":null,"rx_bytes":0,"tx_bytes":0}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := json.Marshal(NewStatusReport(tt.status, now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(encoded) != tt.want {
+				t.Errorf("got  %s\nwant %s", encoded, tt.want)
+			}
+		})
+	}
+}
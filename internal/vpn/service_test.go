@@ -0,0 +1,113 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/pkg/wgvpn"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// fakeRunner answers wg with canned output and records every command run;
+// any other command fails.
+type fakeRunner struct {
+	outputs map[string]string
+	calls   []string
+}
+
+func (r *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	r.calls = append(r.calls, command)
+	if output, ok := r.outputs[command]; ok {
+		return []byte(output), nil
+	}
+	return nil, fmt.Errorf("unexpected command: %s", command)
+}
+
+func (r *fakeRunner) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func (r *fakeRunner) CombinedOutput(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func (r *fakeRunner) Stream(_ context.Context, _ func(line string), name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+// fakeDevices stands in for wgctrl.
+type fakeDevices struct {
+	devices []*wgtypes.Device
+	err     error
+}
+
+func (f fakeDevices) Devices() ([]*wgtypes.Device, error) {
+	return f.devices, f.err
+}
+
+// fakeConfigs names every endpoint's gateway; GetStatus needs nothing else.
+type fakeConfigs struct {
+	Configs
+}
+
+func (fakeConfigs) EndpointHostname(env, endpoint string) string {
+	return "gw-" + env + ".example.com"
+}
+
+func TestGetStatusNeverRunsWgQuick(t *testing.T) {
+	peer := wgtypes.Peer{Endpoint: &net.UDPAddr{IP: net.ParseIP("34.101.166.184"), Port: 51820}}
+	device := func(name string) *wgtypes.Device {
+		return &wgtypes.Device{Name: name, ListenPort: 41414, Peers: []wgtypes.Peer{peer}}
+	}
+	tests := []struct {
+		name    string
+		devices wgvpn.DeviceReader
+		outputs map[string]string
+	}{
+		{
+			name:    "devices",
+			devices: fakeDevices{devices: []*wgtypes.Device{device("julo-prod"), device("julo-nonprod")}},
+		},
+		{
+			name:    "wg fallback",
+			devices: fakeDevices{err: errors.New("netlink: operation not permitted")},
+			outputs: map[string]string{
+				"wg show":           "interface: julo-prod\n  public key: x\n\ninterface: julo-nonprod\n  public key: z\n",
+				"wg show julo-prod": "interface: julo-prod\n  listening port: 41414\n\npeer: y\n  endpoint: 34.101.166.184:51820\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{outputs: tt.outputs}
+			service := &WireGuardService{
+				client:  wgvpn.New(wgvpn.WithRunner(runner), wgvpn.WithDeviceReader(tt.devices)),
+				configs: fakeConfigs{},
+			}
+
+			status, err := service.GetStatus()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Both tunnels up: the extra one is reported, not torn down
+			if !status.Connected || status.Environment != Production || !slices.Equal(status.ConflictingInterfaces, []string{"julo-nonprod"}) {
+				t.Errorf("GetStatus() = %+v", status)
+			}
+			if status.EndpointHost != "gw-prod.example.com" {
+				t.Errorf("EndpointHost = %q", status.EndpointHost)
+			}
+			for _, call := range runner.calls {
+				if strings.Contains(call, "wg-quick") {
+					t.Errorf("GetStatus ran %q", call)
+				}
+			}
+		})
+	}
+}
@@ -1,28 +1,214 @@
 package vpn
 
-import "time"
+import (
+	"context"
+	"io"
+	"net/netip"
+	"time"
 
-type Environment string
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/paths"
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+type Environment = core.Environment
 
 const (
-	Production    Environment = "prod"
-	NonProduction Environment = "nonprod"
+	Production    = core.Production
+	NonProduction = core.NonProduction
+)
+
+type ConnectionStatus = wgvpn.ConnectionStatus
+
+// ParseEnvironment accepts the short environment names ("prod", "nonprod",
+// an added profile's name).
+func ParseEnvironment(name string) (Environment, error) {
+	return core.ParseEnvironment(name)
+}
+
+// AgentSocket is the agent's socket in the runtime directory, which the
+// prompt status asks first.
+const AgentSocket = "agent.sock"
+
+// PromptStatus returns a short shell prompt segment ("wg:prod") and its exit
+// code without running wg, asking a running agent first; see
+// wgvpn.PromptStatus.
+func PromptStatus() (string, int) {
+	socket, _ := paths.File(paths.Runtime, AgentSocket)
+	return wgvpn.PromptStatus(socket)
+}
+
+// PromptAgentTimeout is how long PromptStatus waits for the agent.
+const PromptAgentTimeout = wgvpn.PromptAgentTimeout
+
+// WritePromptAnswer is the agent's answer to PromptStatus.
+func WritePromptAnswer(w io.Writer, up []Environment) error {
+	return wgvpn.WritePromptAnswer(w, up)
+}
+
+// Errors of a status check that couldn't run wg; see wgvpn.
+var (
+	ErrWireGuardNotInstalled = wgvpn.ErrWireGuardNotInstalled
+	ErrPermissionDenied      = wgvpn.ErrPermissionDenied
 )
 
-type ConnectionStatus struct {
-	Connected   bool
-	Environment Environment
-	Interface   string
-	Endpoint    string
-	LastSeen    *time.Time
-	BytesRx     uint64
-	BytesTx     uint64
+// DefaultHandshakeAge is how recent a handshake must be for a tunnel to
+// count as healthy.
+const DefaultHandshakeAge = wgvpn.DefaultHandshakeAge
+
+// Healthy reports whether status is a connection to env with a handshake no
+// older than maxAge.
+func Healthy(status *ConnectionStatus, env Environment, maxAge time.Duration) bool {
+	return wgvpn.Healthy(status, env, maxAge)
+}
+
+// Environments returns every environment, production first.
+func Environments() []Environment {
+	return core.Environments()
+}
+
+// AddEnvironment makes a profile besides prod and nonprod known; see
+// core.AddEnvironment.
+func AddEnvironment(name string) (Environment, error) {
+	return core.AddEnvironment(name)
+}
+
+// SetDisplayName names env in the UI; see wgvpn.SetDisplayName.
+func SetDisplayName(env Environment, name string) {
+	wgvpn.SetDisplayName(env, name)
+}
+
+// Timer measures an operation phase by phase; see wgvpn.Timer.
+type Timer = wgvpn.Timer
+
+// NewTimer starts timing an operation on the wall clock.
+func NewTimer() *Timer {
+	return wgvpn.NewTimer(nil)
+}
+
+// FormatDuration renders d to a tenth of a second, e.g. "6.4s".
+func FormatDuration(d time.Duration) string {
+	return wgvpn.FormatDuration(d)
+}
+
+// Leftover is DNS or routing state a tunnel left behind; see
+// wgvpn.Leftover.
+type Leftover = wgvpn.Leftover
+
+// DNSSnapshot is the DNS configuration before a tunnel replaced it; see
+// wgvpn.DNSSnapshot.
+type DNSSnapshot = wgvpn.DNSSnapshot
+
+// FirewallFinding is a firewall rule likely to block tunnel traffic; see
+// wgvpn.FirewallFinding.
+type FirewallFinding = wgvpn.FirewallFinding
+
+// Connection is an established TCP connection a tunnel carries; see
+// wgvpn.Connection.
+type Connection = wgvpn.Connection
+
+// ProcessConnections are the connections of one process.
+type ProcessConnections = wgvpn.ProcessConnections
+
+// RouteDecision is whether a destination uses a tunnel, by config and by
+// the kernel; see wgvpn.RouteDecision.
+type RouteDecision = wgvpn.RouteDecision
+
+// GroupByProcess groups connections by process name, busiest first.
+func GroupByProcess(connections []Connection) []ProcessConnections {
+	return wgvpn.GroupByProcess(connections)
+}
+
+// EndpointPort returns the UDP port of a host:port endpoint, 51820 when it
+// has none.
+func EndpointPort(endpoint string) int {
+	return wgvpn.EndpointPort(endpoint)
+}
+
+// DetectConfigDir returns the directory this machine's wg-quick reads
+// configs from; see wgvpn.DetectConfigDir.
+func DetectConfigDir() string {
+	return wgvpn.DetectConfigDir()
+}
+
+// OutputFunc receives redacted wg-quick output lines while an operation runs.
+type OutputFunc = wgvpn.OutputFunc
+
+// Configs is what vpn needs of the installed configs. config.Store provides
+// it: vpn doesn't import config, so config never has to avoid vpn's other
+// dependencies to stay out of an import cycle.
+type Configs interface {
+	// InstallUserConfig merges a user config with its template and installs
+	// it, returning what the merge warns about.
+	InstallUserConfig(userConfigPath string) ([]string, error)
+	InstalledEndpoint(env string) (string, error)
+	// UpdateEndpoint rewrites the gateway's Endpoint in env's config.
+	UpdateEndpoint(env, endpoint string) error
+	// EndpointHostname returns the gateway hostname behind a connected
+	// endpoint, "" when none is known.
+	EndpointHostname(env, endpoint string) string
+	ReadInstalled(name string) ([]byte, error)
+	ValidateInstalled(name, content string) error
+	GenerateClientConfig(env, address string) (*core.GeneratedConfig, error)
+	ExportDeviceTemplate(env string, newKey bool) (*core.DeviceExport, error)
+}
+
+// configs is the installed configs; see SetConfigs.
+var configs Configs
+
+// SetConfigs gives vpn the installed configs. It is called at startup,
+// before NewService and anything else in vpn reading a config.
+func SetConfigs(c Configs) {
+	configs = c
 }
 
 type Service interface {
+	// GetStatus only reads: tunnels up besides the reported one are named
+	// in ConflictingInterfaces, and only StopInterface brings them down.
 	GetStatus() (*ConnectionStatus, error)
 	Start(env Environment) error
 	Stop() error
-	UpdateConfig(userConfigPath string) error
+	// StartWithOutput and StopWithOutput stream wg-quick's output to out and
+	// abort when ctx is cancelled.
+	StartWithOutput(ctx context.Context, env Environment, out OutputFunc) error
+	StopWithOutput(ctx context.Context, out OutputFunc) error
+	// Tunnels reports every JULO tunnel that is up, more than one when they
+	// conflict; StopInterface brings one of them down by its interface.
+	Tunnels(ctx context.Context) ([]*ConnectionStatus, error)
+	StopInterface(ctx context.Context, interfaceName string, out OutputFunc) error
+	// VerifyHandshake waits until env is connected with a fresh handshake,
+	// returning the last observed status either way.
+	VerifyHandshake(ctx context.Context, env Environment) (*ConnectionStatus, error)
+	// UpdateConfig installs a user config merged with its template, and
+	// returns what the merge warns about, e.g. a DNS the config lacked and
+	// was given from the template.
+	UpdateConfig(userConfigPath string) ([]string, error)
 	GetConfig(env Environment) (string, error)
-}
\ No newline at end of file
+	// GenerateConfig creates a new keypair and client config for env.
+	GenerateConfig(env Environment, address string) (*core.GeneratedConfig, error)
+	// ExportDeviceTemplate writes env's config, minus the per-device values,
+	// for another device. It never changes the installed config.
+	ExportDeviceTemplate(env Environment, newKey bool) (*core.DeviceExport, error)
+	// ActiveConnections lists the established TCP connections env's tunnel
+	// carries.
+	ActiveConnections(ctx context.Context, env Environment) ([]Connection, error)
+	// TunnelRoutes returns the AllowedIPs the tunnel of env routes.
+	TunnelRoutes(ctx context.Context, env Environment) ([]netip.Prefix, error)
+	// CheckRoute compares what env's AllowedIPs and the kernel say about
+	// traffic to target, an address or a hostname.
+	CheckRoute(ctx context.Context, env Environment, target string) (*RouteDecision, error)
+	// Leftovers lists DNS settings and routes still referencing tunnels
+	// that are down; CleanUp removes the ones that have a fix.
+	Leftovers(ctx context.Context) ([]Leftover, error)
+	CleanUp(ctx context.Context, leftovers []Leftover) error
+	// CheckFirewall inspects the active firewall (ufw, firewalld or
+	// nftables) for rules blocking WireGuard on port; name is "" when none
+	// is active.
+	CheckFirewall(ctx context.Context, port int) (name string, findings []FirewallFinding, err error)
+	// SnapshotDNS records the current DNS configuration, TunnelDNS returns
+	// the servers env's config sets, and VerifyDNS lists how the DNS
+	// configuration differs from a snapshot once the tunnel is down.
+	SnapshotDNS(ctx context.Context) (*DNSSnapshot, error)
+	TunnelDNS(env Environment) ([]string, error)
+	VerifyDNS(ctx context.Context, snapshot *DNSSnapshot) ([]Leftover, error)
+}
@@ -0,0 +1,103 @@
+package vpn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/internal/probe"
+)
+
+// Verification is whether this machine is on an environment's VPN, as the
+// verify subcommand reports it to CI: connected to it, with a handshake no
+// older than the allowed age, and with every reachability check passing.
+type Verification struct {
+	Environment Environment `json:"environment"`
+	OK          bool        `json:"ok"`
+	// Reason is one line explaining the outcome
+	Reason string `json:"reason"`
+	// Connected lists the tunnels that are up
+	Connected []Environment `json:"connected"`
+	// HandshakeAge is how old env's latest handshake is, in seconds; nil
+	// when it isn't connected or never handshook
+	HandshakeAge *float64      `json:"handshake_age_seconds,omitempty"`
+	Checks       []CheckResult `json:"checks,omitempty"`
+}
+
+// CheckResult is one reachability check: a TCP connection to Address.
+type CheckResult struct {
+	Address string `json:"address"`
+	OK      bool   `json:"ok"`
+	// Millis is the connection time when it succeeded
+	Millis int64  `json:"ms,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Verify checks that env's tunnel is up with a handshake no older than
+// maxAge, using the same readiness test as the TUI (Healthy), then connects
+// to each of checks (host:port) with prober. The checks only run once the
+// tunnel is ready, since without it they prove nothing about the VPN.
+// Everything is bounded by ctx: the tunnel lookup only runs wg through
+// sudo -n, so it fails rather than waits for a password.
+func Verify(ctx context.Context, svc Service, env Environment, maxAge time.Duration, checks []string, prober *probe.UDPProber) *Verification {
+	v := &Verification{Environment: env, Connected: []Environment{}}
+	tunnels, err := svc.Tunnels(ctx)
+	if err != nil {
+		v.Reason = fmt.Sprintf("tunnel status unavailable: %v", err)
+		return v
+	}
+
+	var status *ConnectionStatus
+	for _, tunnel := range tunnels {
+		v.Connected = append(v.Connected, tunnel.Environment)
+		if tunnel.Environment == env {
+			status = tunnel
+		}
+	}
+	if status != nil && status.LastSeen != nil {
+		age := time.Since(*status.LastSeen).Seconds()
+		v.HandshakeAge = &age
+	}
+	switch {
+	case status == nil && len(tunnels) == 0:
+		v.Reason = fmt.Sprintf("not connected to %s: no tunnel is up", env)
+		return v
+	case status == nil:
+		names := make([]string, len(v.Connected))
+		for i, connected := range v.Connected {
+			names[i] = string(connected)
+		}
+		v.Reason = fmt.Sprintf("not connected to %s: %s is up instead", env, strings.Join(names, ", "))
+		return v
+	case status.LastSeen == nil:
+		v.Reason = fmt.Sprintf("connected to %s but it has no handshake", env)
+		return v
+	case !Healthy(status, env, maxAge):
+		v.Reason = fmt.Sprintf("connected to %s but the handshake is %s old (at most %s allowed)", env, time.Since(*status.LastSeen).Truncate(time.Second), maxAge)
+		return v
+	}
+
+	var failed []string
+	for _, address := range checks {
+		result := CheckResult{Address: address}
+		if took, err := prober.TCP(ctx, address); err != nil {
+			result.Error = err.Error()
+			failed = append(failed, address)
+		} else {
+			result.OK, result.Millis = true, took.Milliseconds()
+		}
+		v.Checks = append(v.Checks, result)
+	}
+	if len(failed) > 0 {
+		v.Reason = fmt.Sprintf("connected to %s but %s unreachable", env, strings.Join(failed, ", "))
+		return v
+	}
+
+	v.OK = true
+	v.Reason = fmt.Sprintf("connected to %s, handshake %s ago", env, time.Since(*status.LastSeen).Truncate(time.Second))
+	if len(checks) > 0 {
+		v.Reason += fmt.Sprintf(", %d check(s) passed", len(checks))
+	}
+	return v
+}
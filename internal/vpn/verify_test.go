@@ -0,0 +1,124 @@
+package vpn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"tui-wireguard-vpn/internal/probe"
+)
+
+// tunnelService reports the tunnels that are up; Verify needs nothing else.
+type tunnelService struct {
+	Service
+	tunnels []*ConnectionStatus
+	err     error
+}
+
+func (s tunnelService) Tunnels(context.Context) ([]*ConnectionStatus, error) {
+	return s.tunnels, s.err
+}
+
+// fakeDialer accepts connections to the addresses listed as open.
+type fakeDialer struct {
+	open map[string]bool
+}
+
+func (d fakeDialer) DialContext(_ context.Context, _, address string) (net.Conn, error) {
+	if !d.open[address] {
+		return nil, errors.New("connection refused")
+	}
+	client, server := net.Pipe()
+	server.Close()
+	return client, nil
+}
+
+func tunnel(env Environment, handshakeAge time.Duration) *ConnectionStatus {
+	status := &ConnectionStatus{Connected: true, Environment: env, Interface: env.Interface()}
+	if handshakeAge >= 0 {
+		lastSeen := time.Now().Add(-handshakeAge)
+		status.LastSeen = &lastSeen
+	}
+	return status
+}
+
+func TestVerify(t *testing.T) {
+	prodFresh := tunnel(Production, 30*time.Second)
+	tests := []struct {
+		name   string
+		svc    tunnelService
+		checks []string
+		ok     bool
+		reason string
+	}{
+		{
+			name:   "connected",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{prodFresh}},
+			ok:     true,
+			reason: "connected to prod, handshake 30s ago",
+		},
+		{
+			name:   "connected and reachable",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{prodFresh}},
+			checks: []string{"db.internal:5432", "git.internal:443"},
+			ok:     true,
+			reason: "connected to prod, handshake 30s ago, 2 check(s) passed",
+		},
+		{
+			name:   "status unavailable",
+			svc:    tunnelService{err: errors.New("sudo: a password is required")},
+			reason: "tunnel status unavailable: sudo: a password is required",
+		},
+		{
+			name:   "no tunnel",
+			svc:    tunnelService{},
+			reason: "not connected to prod: no tunnel is up",
+		},
+		{
+			name:   "another profile",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{tunnel(NonProduction, time.Second)}},
+			reason: "not connected to prod: nonprod is up instead",
+		},
+		{
+			name:   "no handshake",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{tunnel(Production, -1)}},
+			reason: "connected to prod but it has no handshake",
+		},
+		{
+			name:   "stale handshake",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{tunnel(Production, 5*time.Minute)}},
+			reason: "connected to prod but the handshake is 5m0s old (at most 1m30s allowed)",
+		},
+		{
+			name:   "stale handshake skips the checks",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{tunnel(Production, 5*time.Minute)}},
+			checks: []string{"closed.internal:22"},
+			reason: "connected to prod but the handshake is 5m0s old (at most 1m30s allowed)",
+		},
+		{
+			name:   "check fails",
+			svc:    tunnelService{tunnels: []*ConnectionStatus{tunnel(NonProduction, time.Second), prodFresh}},
+			checks: []string{"db.internal:5432", "closed.internal:22"},
+			reason: "connected to prod but closed.internal:22 unreachable",
+		},
+	}
+	prober := &probe.UDPProber{
+		Dialer:  fakeDialer{open: map[string]bool{"db.internal:5432": true, "git.internal:443": true}},
+		Timeout: time.Second,
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := Verify(context.Background(), tt.svc, Production, 90*time.Second, tt.checks, prober)
+			if v.OK != tt.ok || v.Reason != tt.reason {
+				t.Errorf("Verify() = %v %q, want %v %q", v.OK, v.Reason, tt.ok, tt.reason)
+			}
+			// Checks only run on a ready tunnel
+			if ready := tt.ok || strings.HasSuffix(tt.reason, "unreachable"); !ready && len(v.Checks) > 0 {
+				t.Errorf("%d checks ran for %q", len(v.Checks), v.Reason)
+			}
+		})
+	}
+}
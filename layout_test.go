@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// TestPanelRowsFitTheTerminal renders both rows' panels at the widths the
+// layout gives them, before any clipping, for every terminal width the app
+// is usable at.
+func TestPanelRowsFitTheTerminal(t *testing.T) {
+	content := strings.Repeat("x", 300)
+	for width := 40; width <= 200; width++ {
+		m := model{terminalWidth: width, terminalHeight: 40}
+		topLeft, topRight, bottomLeft, bottomRight := m.panelWidths()
+		rows := map[string]string{
+			"top": lipgloss.JoinHorizontal(lipgloss.Top,
+				mainPanelStyle.Width(topLeft).Render(content),
+				inputPanelStyle.Width(topRight).Render(content)),
+			"bottom": lipgloss.JoinHorizontal(lipgloss.Top,
+				outputPanelStyle.Width(bottomLeft).Render(content),
+				controlsPanelStyle.Width(bottomRight).Render(content)),
+		}
+		for name, row := range rows {
+			if got := lipgloss.Width(row); got != width {
+				t.Errorf("%d columns: %s row is %d wide", width, name, got)
+			}
+		}
+	}
+}
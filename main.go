@@ -1,15 +1,44 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"maps"
+	"net/netip"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/backup"
 	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/doctor"
+	"tui-wireguard-vpn/internal/location"
+	"tui-wireguard-vpn/internal/mfa"
+	"tui-wireguard-vpn/internal/ops"
+	"tui-wireguard-vpn/internal/platform"
+	"tui-wireguard-vpn/internal/probe"
+	"tui-wireguard-vpn/internal/routelabels"
+	"tui-wireguard-vpn/internal/schedule"
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/sleep"
+	"tui-wireguard-vpn/internal/sshhosts"
+	"tui-wireguard-vpn/internal/state"
+	"tui-wireguard-vpn/internal/stats"
+	"tui-wireguard-vpn/internal/sudo"
 	"tui-wireguard-vpn/internal/ui"
+	"tui-wireguard-vpn/internal/ui/render"
 	"tui-wireguard-vpn/internal/vpn"
 )
 
@@ -59,29 +88,76 @@ var (
 	// Active panel highlighting style
 	activePanelBorder = lipgloss.Color("#007ACC")
 	normalPanelBorder = lipgloss.Color("#FFFFFF")
-	connectedStatusStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Background(lipgloss.Color("#28A745")).
-		Padding(1, 2)
-
-	disconnectedStatusStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#FAFAFA")).
-		Background(lipgloss.Color("#DC3545")).
-		Padding(1, 2)
-
-	disabledStyle = lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#6272A4"))
+
+	warningStyle = lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#FFB86C"))
 )
 
-type vpnStatusMsg struct {
-	status *vpn.ConnectionStatus
+// applyGlyphs picks the glyph set: mode "ascii" or "unicode" from the
+// settings, otherwise detected from TERM and the locale.
+func applyGlyphs(mode string) {
+	switch mode {
+	case "ascii":
+		render.SetASCII(true)
+	case "unicode":
+		render.SetASCII(false)
+	default:
+		render.SetASCII(render.DetectASCII(os.Getenv))
+	}
+	border := render.Border()
+	mainPanelStyle = mainPanelStyle.BorderStyle(border)
+	inputPanelStyle = inputPanelStyle.BorderStyle(border)
+	outputPanelStyle = outputPanelStyle.BorderStyle(border)
+	statusPanelStyle = statusPanelStyle.BorderStyle(border)
+	controlsPanelStyle = controlsPanelStyle.BorderStyle(border)
+}
+
+const (
+	// How often canonical gateway hostnames are re-resolved
+	gatewayCheckInterval = 10 * time.Minute
+	// How often the default route is checked for a change of network
+	routeCheckInterval = 15 * time.Second
+	// Upper bound for a single wg-quick start/stop, including resolvconf and PostUp waits
+	vpnOperationTimeout = 2 * time.Minute
+	// How long a freshly started tunnel gets to complete its first handshake
+	handshakeVerifyTimeout = 15 * time.Second
+)
+
+// backupMsg reports a finished backup; reason is set for automatic ones.
+type backupMsg struct {
+	path   string
+	reason string
 	err    error
 }
 
-type vpnOperationMsg struct {
-	operation string
-	success   bool
-	err       error
+type setupCheckMsg struct {
+	status *config.SetupStatus
+	err    error
+	// loose are the installed configs others may read, with their modes
+	loose []string
+}
+
+type remoteSyncMsg struct {
+	result   *config.SyncResult
+	warnings []string
+	lastSync time.Time
+	// reloaded is the tunnel a scheduled sync reconnected to load its new
+	// config, reloadErr why that failed
+	reloaded  vpn.Environment
+	reloadErr error
+}
+
+// pendingUpdatesMsg is a scheduled check that found new files on the server
+// (sync_policy = "prompt").
+type pendingUpdatesMsg struct {
+	result *config.SyncResult
+}
+
+type syncScheduleTickMsg struct{}
+
+type vpnStatusMsg struct {
+	status *vpn.ConnectionStatus
+	err    error
 }
 
 type configViewMsg struct {
@@ -90,52 +166,578 @@ type configViewMsg struct {
 	err         error
 }
 
+type gatewayCheckMsg struct {
+	migration *vpn.GatewayMigration
+	errs      []error
+}
+
+type gatewayTickMsg struct{}
+
+// sudoMsg reports sudo's credential cache: validated (after the password
+// prompt), refreshed by a tick of generation seq, or dropped.
+type sudoMsg struct {
+	action string // "validate", "refresh" or "drop"
+	seq    int
+	err    error
+}
+
+type sudoTickMsg struct{ seq int }
+
+// keepSudo suspends the TUI for sudo's own password prompt, then checks the
+// credentials were cached.
+func keepSudo() tea.Cmd {
+	return tea.ExecProcess(sudo.ValidateCommand(), func(err error) tea.Msg {
+		if err == nil {
+			err = sudo.Check(context.Background(), sudo.Runner)
+		}
+		return sudoMsg{action: "validate", err: err}
+	})
+}
+
+func scheduleSudoRefresh(seq int) tea.Cmd {
+	return tea.Tick(sudo.RefreshInterval, func(time.Time) tea.Msg {
+		return sudoTickMsg{seq: seq}
+	})
+}
+
+func refreshSudo(seq int) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return sudoMsg{action: "refresh", seq: seq, err: sudo.Refresh(ctx, sudo.Runner)}
+	}
+}
+
+func dropSudo() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return sudoMsg{action: "drop", err: sudo.Drop(ctx, sudo.Runner)}
+	}
+}
+
+// sshHostsMsg carries the ssh hosts each environment's routes reach.
+type sshHostsMsg struct {
+	hosts map[vpn.Environment][]string
+}
+
+// locationMsg carries a finished network location detection.
+type locationMsg struct {
+	location *settings.Location
+	facts    location.Facts
+}
+
+// routeCheckMsg is the periodic look at the default route; at is when it
+// ran, to notice a resume from suspend.
+type routeCheckMsg struct {
+	route string
+	at    time.Time
+}
+
+// overviewMsg carries a finished network overview; seq tells stale results
+// of a refreshed probe apart.
+type overviewMsg struct {
+	overview *vpn.NetworkOverview
+	seq      int
+}
+
+// preflightMsg carries a finished preflight run.
+type preflightMsg struct {
+	result *vpn.PreflightResult
+}
+
+// runPreflight checks env's readiness like the preflight command, and
+// records the run in the audit log too.
+func runPreflight(svc vpn.Service, appSettings *settings.Settings, env vpn.Environment) tea.Cmd {
+	return func() tea.Msg {
+		result := vpn.Preflight(context.Background(), svc, env, preflightSpec(appSettings, env), probe.NewUDPProber())
+		result.Record()
+		return preflightMsg{result: result}
+	}
+}
+
+// historyMsg carries today's connection timeline.
+type historyMsg struct {
+	timeline *stats.Timeline
+	err      error
+}
+
+func loadHistory() tea.Cmd {
+	return func() tea.Msg {
+		now := time.Now()
+		timeline, err := stats.LoadDay(now, now)
+		return historyMsg{timeline: timeline, err: err}
+	}
+}
+
+// recordHistory adds event to the connection history. The history is a
+// convenience: failing to write it is not worth a message.
+func recordHistory(event stats.Event) tea.Cmd {
+	return func() tea.Msg {
+		stats.Record(event)
+		return nil
+	}
+}
+
+// historyTickMsg records a heartbeat in the history, so the time the app
+// runs tells apart from the time it doesn't.
+type historyTickMsg struct{}
+
+func scheduleHistoryTick() tea.Cmd {
+	return tea.Tick(stats.HeartbeatInterval, func(time.Time) tea.Msg {
+		return historyTickMsg{}
+	})
+}
+
+// historyState is what the history records for status.
+func historyState(status *vpn.ConnectionStatus) string {
+	if status.Connected {
+		return string(status.Environment)
+	}
+	return stats.StateDisconnected
+}
+
+// configPreviewMsg carries what installing a picked config would change.
+type configPreviewMsg struct {
+	path    string
+	preview *config.ConfigPreview
+	err     error
+}
+
+// previewConfig works out the changes installing configPath would make,
+// writing nothing.
+func previewConfig(configPath string) tea.Cmd {
+	return func() tea.Msg {
+		preview, err := config.NewConfigProcessor().PreviewUserConfig(configPath)
+		return configPreviewMsg{path: configPath, preview: preview, err: err}
+	}
+}
+
+// connectionsMsg lists the connections env's tunnel carries. forStop marks
+// the check before a Stop, which asks first when there are any.
+type connectionsMsg struct {
+	env         vpn.Environment
+	connections []vpn.Connection
+	err         error
+	forStop     bool
+}
+
+func listConnections(svc vpn.Service, env vpn.Environment, forStop bool) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		connections, err := svc.ActiveConnections(ctx, env)
+		return connectionsMsg{env: env, connections: connections, err: err, forStop: forStop}
+	}
+}
+
+// routesMsg carries the routes of env's tunnel with the connections each
+// carries.
+type routesMsg struct {
+	env    vpn.Environment
+	routes []vpn.RouteUsage
+	err    error
+}
+
+func lookUpRoutes(svc vpn.Service, env vpn.Environment) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		routes, err := svc.TunnelRoutes(ctx, env)
+		if err != nil {
+			return routesMsg{env: env, err: err}
+		}
+		// Without ss (or not on Linux) the routes are still worth showing
+		connections, _ := svc.ActiveConnections(ctx, env)
+		return routesMsg{env: env, routes: vpn.RouteUsages(routes, connections)}
+	}
+}
+
+// bandwidthMsg is a status sample for the bandwidth watch.
+type bandwidthMsg struct {
+	status *vpn.ConnectionStatus
+	at     time.Time
+}
+
+type bandwidthTickMsg struct{}
+
+// clockTickMsg ticks every second while auto-refresh is on: it keeps the
+// handshake age counting and starts a status check when one is due. seq
+// ends the ticks of an auto-refresh that was switched off.
+type clockTickMsg struct{ seq int }
+
+func scheduleClockTick(seq int) tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return clockTickMsg{seq: seq}
+	})
+}
+
+// tunnelsMsg lists the tunnels that are up, for the conflict screen.
+type tunnelsMsg struct {
+	tunnels []*vpn.ConnectionStatus
+	err     error
+}
+
+func listTunnels(svc vpn.Service) tea.Cmd {
+	return func() tea.Msg {
+		tunnels, err := svc.Tunnels(context.Background())
+		return tunnelsMsg{tunnels: tunnels, err: err}
+	}
+}
+
+// signalMsg is SIGINT, SIGTERM or SIGHUP arriving, e.g. from a terminal
+// that was closed or a tmux pane that was killed.
+type signalMsg struct{ signal os.Signal }
+
+// sleepWatchMsg reports whether the system's sleep events can be watched.
+type sleepWatchMsg struct {
+	events <-chan bool
+	err    error
+}
+
+// sleepMsg is the system about to sleep (sleeping) or resumed; closed is
+// the end of the events.
+type sleepMsg struct {
+	sleeping bool
+	closed   bool
+}
+
+func watchSleep(monitor sleep.Monitor) tea.Cmd {
+	return func() tea.Msg {
+		events, err := monitor.Watch(context.Background())
+		return sleepWatchMsg{events: events, err: err}
+	}
+}
+
+func waitForSleep(events <-chan bool) tea.Cmd {
+	return func() tea.Msg {
+		sleeping, ok := <-events
+		return sleepMsg{sleeping: sleeping, closed: !ok}
+	}
+}
+
+// Often enough to see a minute-long transfer, rarely enough not to matter
+const bandwidthSampleInterval = 15 * time.Second
+
+func scheduleBandwidthSample() tea.Cmd {
+	return tea.Tick(bandwidthSampleInterval, func(time.Time) tea.Msg {
+		return bandwidthTickMsg{}
+	})
+}
+
+func sampleBandwidth(svc vpn.Service) tea.Cmd {
+	return func() tea.Msg {
+		status, err := svc.GetStatus()
+		if err != nil {
+			status = nil
+		}
+		return bandwidthMsg{status: status, at: time.Now()}
+	}
+}
+
+// templateImportMsg reports an imported template, or the re-merge of an
+// installed config with it when remerge is set.
+type templateImportMsg struct {
+	env      string
+	remerge  bool
+	warnings []string
+	err      error
+}
+
+// configStagedMsg reports a config update deferred until its environment
+// disconnects; replaced is set when it took the place of an earlier one.
+type configStagedMsg struct {
+	env      string
+	pending  *state.PendingConfig
+	replaced bool
+	err      error
+}
+
+// deferredAppliedMsg reports the install of a deferred config update.
+type deferredAppliedMsg struct {
+	env string
+	err error
+}
+
+type generateConfigMsg struct {
+	result *config.GeneratedConfig
+	err    error
+}
+
+type deviceExportMsg struct {
+	result *config.DeviceExport
+	err    error
+}
+
 type model struct {
 	title          string
 	status         *vpn.ConnectionStatus
-	choices        []string
+	menu           []menuEntry
 	cursor         int
 	vpnSvc         vpn.Service
 	loading        bool
+	refreshing     bool // the loading state is a Refresh Status waiting for its check
 	message        string
 	// 4-panel layout fields
 	activePanel    int    // 0: main+status, 1: help/input, 2: activity log, 3: controls
 	showInputPanel bool   // whether to show the input panel
 	inputModel     *ui.UpdateModel // for configuration updates
-	outputLog      []string // log messages for output panel
+	updateSession  *updateSession  // Update VPN Configuration, across its rounds
+	generateModel  *ui.GenerateModel // for generating a new client config
+	activityLog    *ui.LogView // entries of the activity log panel
 	terminalWidth  int
 	terminalHeight int
-	// Activity log scrolling
-	logViewportStart int // First visible log entry
-	logViewportSize  int // Number of log entries visible at once
+	settings         *settings.Settings
+	gatewayMigration *vpn.GatewayMigration // set while a gateway move is detected
+	autoConnect      vpn.Environment       // profile to start after the initial status check
+	reconnect        *state.Session        // a session that ended without a Stop, offered on launch
+	confirm          *confirmPrompt        // pending yes/no question, intercepts keys
+	viewedConfig     vpn.Environment       // config last shown by View, for device exports
+	mfa              *mfaPrompt            // pending one-time code for a Start, intercepts keys
+	routeCheck       *routePrompt          // destination being typed for a route check, intercepts keys
+	switcher         *envSwitcher          // the environment switcher popup, intercepts keys
+	conflict         *conflictScreen       // the screen resolving several tunnels up at once, intercepts keys
+	conflictLater    string                // the conflicting interfaces the user left up, not asked about again
+	steps            *ops.Steps            // steps of the running Start, shown in the message area
+	autoRefresh      bool                  // the status is checked every StatusRefreshSeconds
+	refreshSeq       int                   // generation of the clock ticks, to end a switched-off one
+	statusPending    bool                  // an auto-refresh status check is out
+	lastStatusCheck  time.Time             // when a status check last came back
+	platform         platform.Context      // WSL or container, which limits what Start/Stop can do
+	miniMode         bool                  // collapsed single-line view for screen sharing
+	sudoKept         bool                  // sudo credentials are cached and kept warm
+	sudoSeq          int                   // generation of the keepalive ticks, to end a dropped one
+	lastSync         time.Time             // oldest successful remote sync, zero if never
+	syncFailed       bool                  // the last remote sync attempt had errors
+	statusChecked    bool                  // the first status check has come back
+	statusErr        error                 // error of the most recent status check
+	setupChecked     bool                  // the startup setup check has come back (or was skipped)
+	setupNeeded      *config.SetupStatus   // set when the app should quit into the setup wizard
+	configs          map[string]*state.ConfigProvenance // where each installed config came from
+	readOnly         bool                  // setup was skipped: only status and viewing work
+	setupIncomplete  *config.SetupStatus   // what setup is missing while read-only
+	setupStatus      *config.SetupStatus   // latest setup check, nil until it reports
+	overviewOpen     bool                  // the network overview replaces the help panel
+	overview         *vpn.NetworkOverview  // last collected overview
+	overviewProbing  bool                  // a probe is running
+	overviewCancel   context.CancelFunc    // stops the running probe
+	overviewSeq      int                   // sequence number of the latest probe
+	connectionsOpen  bool                  // the active connections view replaces the help panel
+	connections      []vpn.Connection      // connections through the tunnel, last listed
+	connectionsErr   error                 // why they couldn't be listed
+	connectionsBusy  bool                  // a listing is running
+	routesOpen       bool                  // the routes view replaces the help panel
+	routes           []vpn.RouteUsage      // the tunnel's AllowedIPs and what they carry
+	routesErr        error                 // why they couldn't be read
+	routesBusy       bool                  // a lookup is running
+	routesCursor     int                   // the selected route
+	routeLabels      routelabels.Labels    // labels of AllowedIPs ranges, the user's over the shipped ones
+	labelEdit        *labelEditor          // label being typed for a route, intercepts keys
+	configOpen       bool                  // the viewed config replaces the help panel
+	preflightOpen    bool                  // the preflight checklist replaces the help panel
+	preflightEnv     vpn.Environment       // the environment it checks
+	preflight        *vpn.PreflightResult  // its last run, nil until one finishes
+	preflightBusy    bool                  // a run is going on
+	historyOpen      bool                  // today's connection timeline replaces the help panel
+	history          *stats.Timeline       // the timeline, nil until it is read
+	historyErr       error                 // why it couldn't be read
+	historyState     string                // the state last recorded in the history, "" before the first status check
+	configView       viewport.Model        // the viewed config, scrolled with ↑/↓ and PgUp/PgDn
+	review           *configReview         // an update's changes in the config view, waiting for y or Esc
+	bandwidth        *vpn.BandwidthWatch   // notices sustained heavy transfers
+	rate             *vpn.RateMeter        // transfer rate between status checks
+	sleepMonitor     sleep.Monitor         // logind's sleep events
+	sleepEvents      <-chan bool           // nil while they aren't watched
+	sleepRelease     func()                // releases the inhibitor lock held for disconnect_on_sleep
+	sleeping         bool                  // the system announced a suspend and hasn't resumed
+	sleepStopped     vpn.Environment       // tunnel stopped for the suspend, started again on resume
+	pendingUpdates   []config.SyncChange   // new server files a scheduled check found
+	deferredConfigs  map[string]*state.PendingConfig // config updates waiting for their tunnel to go down
+	opGroup          int                   // activity log group of the running operation, 0 for none
+	autoConnectDone  bool                  // the launch auto-connect was decided
+	location         *settings.Location    // matched network location rule, nil for none
+	locationChecked  bool                  // the first location detection has come back (or there are no rules)
+	routeKey         string                // default route ("iface addr") the location was detected on
+	lastRouteCheck   time.Time             // when the default route was last looked at
+	// ssh hosts each environment reaches, nil until computed
+	sshHosts map[vpn.Environment][]string
+	opQueue  ops.Queue          // Start and Stop: the running one and the one waiting
+	opCancel context.CancelFunc // cancels the running Start or Stop
+	// exitSignal is the signal that ended the app, nil when it was quit
+	exitSignal os.Signal
+}
+
+// confirmPrompt is a yes/no question shown in the message area. The
+// dangerous choice is never the default: only "y" runs cmd.
+type confirmPrompt struct {
+	question string
+	message  string // progress message shown once confirmed
+	cmd      tea.Cmd
+	// operation titles the activity log group opened once confirmed; ""
+	// logs without one
+	operation string
+	// op, when set, is requested through the operation queue instead of
+	// running cmd
+	op *ops.Op
+	// deferCmd, when set, is what "d" runs instead: a config update waits
+	// for the next disconnect
+	deferCmd tea.Cmd
+}
+
+// choices is the hint after the question.
+func (p *confirmPrompt) choices() string {
+	if p.deferCmd != nil {
+		return "(y/N, d to defer until disconnect)"
+	}
+	return "(y/N)"
+}
+
+// routePrompt asks for the destination of a route check.
+type routePrompt struct {
+	env      vpn.Environment
+	target   string
+	checking bool
+}
+
+// labelEditor edits the label of a route in the Routes view.
+type labelEditor struct {
+	prefix netip.Prefix
+	input  textinput.Model
+	saving bool
+}
+
+// routeLabelsMsg carries the route labels read at startup, with the ranges
+// where the user's label overrides a shipped one.
+type routeLabelsMsg struct {
+	labels    routelabels.Labels
+	conflicts []routelabels.Conflict
+	err       error
+}
+
+func loadRouteLabels() tea.Msg {
+	labels, conflicts, err := routelabels.Load()
+	return routeLabelsMsg{labels: labels, conflicts: conflicts, err: err}
+}
+
+// routeLabelSavedMsg carries the outcome of saving a route's label, with
+// the labels read back.
+type routeLabelSavedMsg struct {
+	prefix    netip.Prefix
+	label     string
+	labels    routelabels.Labels
+	conflicts []routelabels.Conflict
+	err       error
 }
 
-func initialModel() model {
-	return model{
+func saveRouteLabel(prefix netip.Prefix, label string) tea.Cmd {
+	return func() tea.Msg {
+		msg := routeLabelSavedMsg{prefix: prefix, label: strings.TrimSpace(label)}
+		if msg.err = routelabels.Set(prefix, label); msg.err == nil {
+			msg.labels, msg.conflicts, msg.err = routelabels.Load()
+		}
+		return msg
+	}
+}
+
+// envSwitcher is the environment switcher popup, a shortcut to the Start
+// entries of the menu.
+type envSwitcher struct {
+	cursor  int    // index into vpn.Environments()
+	message string // why the last pick did nothing
+}
+
+// routeCheckResultMsg carries the answer to a route check.
+// conflictScreen resolves more than one tunnel being up at once: keep one
+// and stop the others, or stop them all. tunnels is nil until listed.
+type conflictScreen struct {
+	tunnels []*vpn.ConnectionStatus
+	cursor  int // a tunnel to keep, or len(tunnels) for Stop all
+	message string
+}
+
+type routeCheckResultMsg struct {
+	decision *vpn.RouteDecision
+	target   string
+	err      error
+}
+
+func checkRoute(svc vpn.Service, env vpn.Environment, target string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		decision, err := svc.CheckRoute(ctx, env, target)
+		return routeCheckResultMsg{decision: decision, target: target, err: err}
+	}
+}
+
+// mfaPrompt asks for the one-time code a profile requires before its Start
+// is requested.
+type mfaPrompt struct {
+	gate     mfa.Gate
+	op       ops.Op
+	code     string
+	attempts int  // wrong codes so far
+	checking bool // a check is running; keys wait for it
+}
+
+type mfaCheckMsg struct {
+	err error
+}
+
+func checkMFA(gate mfa.Gate, code string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		return mfaCheckMsg{err: gate.Check(ctx, code)}
+	}
+}
+
+func initialModel(appSettings *settings.Settings) model {
+	// A missing or unreadable state file just means defaults
+	st, _ := state.Load()
+	m := model{
 		title:  "WireGuard VPN Manager",
 		status: &vpn.ConnectionStatus{Connected: false},
-		choices: []string{
-			"Start Production VPN",
-			"Start Non-Production VPN", 
-			"Stop VPN",
-			"Refresh Status",
-			"Update VPN Configuration",
-			"View Production Config",
-			"View Non-Production Config",
-			"Quit",
-		},
+		menu:   mainMenu(),
 		cursor:         0,
 		vpnSvc:         vpn.NewService(),
 		loading:        false,
-		message:        "",
+		message:        "Checking VPN status...",
 		activePanel:    0,    // start with main menu active
 		showInputPanel: false,
-		outputLog:        []string{},
+		activityLog:      ui.NewLogView(),
 		terminalWidth:    80,  // default values
 		terminalHeight:   24,
-		logViewportStart: 0,
-		logViewportSize:  5,   // Show 5 log entries at once
+		settings:         appSettings,
+		lastSync:         config.LastRemoteSync(remoteSources(appSettings)),
+		configs:          st.Configs,
+		deferredConfigs:  st.PendingConfigs,
+		// Until the setup check says otherwise, a skipped setup is still
+		// incomplete
+		readOnly:         st.SetupSkipped,
+		locationChecked:  len(appSettings.Locations) == 0,
+		bandwidth:        vpn.NewBandwidthWatch(appSettings.BandwidthWarnMiB),
+		rate:             &vpn.RateMeter{},
+		sleepMonitor:     sleep.System(),
+		platform:         platform.Detect(),
+		autoRefresh:      appSettings.StatusRefreshSeconds > 0,
 	}
+	m.activityLog.SetSize(render.LogViewportSize(m.logPanelHeight()))
+	return m
+}
+
+// loadConfigProvenance returns the recorded origin of the installed configs.
+// A missing or unreadable state file just means unknown ages.
+func loadConfigProvenance() map[string]*state.ConfigProvenance {
+	s, _ := state.Load()
+	return s.Configs
+}
+
+// setSetupSkipped remembers whether the user chose to skip setup.
+func setSetupSkipped(skipped bool) error {
+	return state.Update(func(s *state.State) {
+		s.SetupSkipped = skipped
+	})
 }
 
 func checkVPNStatus(svc vpn.Service) tea.Cmd {
@@ -145,36 +747,207 @@ func checkVPNStatus(svc vpn.Service) tea.Cmd {
 	}
 }
 
-func startVPN(svc vpn.Service, env vpn.Environment) tea.Cmd {
+func updateConfig(svc vpn.Service, configPath string) tea.Cmd {
 	return func() tea.Msg {
-		err := svc.Start(env)
+		// Hash before the attempt so a retry can tell whether the file changed since
+		sourceHash, _ := state.HashFile(configPath)
+		timer := vpn.NewTimer()
+		var warnings []string
+		err := timer.Phase("exec", func() error {
+			var err error
+			warnings, err = svc.UpdateConfig(configPath)
+			return err
+		})
+		stateErr := recordUpdateOutcome(configPath, sourceHash, err)
+		timing, timingErr := finishTiming("update_config", timer, err)
+		if stateErr == nil {
+			stateErr = timingErr
+		}
 		return vpnOperationMsg{
-			operation: fmt.Sprintf("start_%s", string(env)),
+			operation: "update_config",
 			success:   err == nil,
 			err:       err,
+			stateErr:  stateErr,
+			timing:    timing,
+			warnings:  warnings,
 		}
 	}
 }
 
-func stopVPN(svc vpn.Service) tea.Cmd {
+// stageConfig merges configPath and keeps it for when its environment
+// disconnects, replacing an update deferred earlier for the same one.
+func stageConfig(configPath string) tea.Cmd {
 	return func() tea.Msg {
-		err := svc.Stop()
-		return vpnOperationMsg{
-			operation: "stop",
-			success:   err == nil,
-			err:       err,
+		staged, err := config.NewConfigProcessor().StageUserConfig(configPath)
+		if err != nil {
+			return configStagedMsg{err: err}
+		}
+		pending := &state.PendingConfig{
+			Source:   "file " + filepath.Base(configPath),
+			Path:     staged.Path,
+			SHA256:   staged.SHA256,
+			StagedAt: time.Now(),
 		}
+		replaced := false
+		err = state.Update(func(s *state.State) {
+			if s.PendingConfigs == nil {
+				s.PendingConfigs = map[string]*state.PendingConfig{}
+			}
+			_, replaced = s.PendingConfigs[staged.Environment]
+			s.PendingConfigs[staged.Environment] = pending
+		})
+		return configStagedMsg{env: staged.Environment, pending: pending, replaced: replaced, err: err}
 	}
 }
 
-func updateConfig(svc vpn.Service, configPath string) tea.Cmd {
+// installDeferred installs env's deferred update and forgets it.
+func installDeferred(env string, pending *state.PendingConfig) tea.Cmd {
 	return func() tea.Msg {
-		err := svc.UpdateConfig(configPath)
-		return vpnOperationMsg{
-			operation: "update_config",
-			success:   err == nil,
-			err:       err,
+		err := config.NewConfigProcessor().ApplyStaged(env, pending.SHA256)
+		if err == nil {
+			err = state.Update(func(s *state.State) {
+				delete(s.PendingConfigs, env)
+				if s.Configs == nil {
+					s.Configs = map[string]*state.ConfigProvenance{}
+				}
+				s.Configs[env] = &state.ConfigProvenance{Source: pending.Source + " (deferred)", UpdatedAt: time.Now()}
+			})
+		} else {
+			state.Update(func(s *state.State) {
+				if staged := s.PendingConfigs[env]; staged != nil {
+					staged.Error = summarizeError(err)
+				}
+			})
+		}
+		return deferredAppliedMsg{env: env, err: err}
+	}
+}
+
+func importTemplate(path string) tea.Cmd {
+	return func() tea.Msg {
+		processor := config.NewConfigProcessor()
+		env, err := processor.ImportTemplate(path)
+		return templateImportMsg{env: env, warnings: processor.Warnings, err: err}
+	}
+}
+
+func remergeConfig(env string) tea.Cmd {
+	return func() tea.Msg {
+		processor := config.NewConfigProcessor()
+		err := processor.RemergeInstalled(env)
+		if err == nil {
+			err = state.RecordConfig(env, "re-merged with imported template")
+		}
+		return templateImportMsg{env: env, remerge: true, warnings: processor.Warnings, err: err}
+	}
+}
+
+// reviewImportKind checks a picked file against what the user chose to
+// import it as. A template merged as a personal config would stamp its
+// placeholder key into the active config, and a personal config imported as
+// a template would spread its key, so a mismatch is refused. A template
+// import always asks first. handled is false for a personal config, which
+// goes through the usual update.
+func (m *model) reviewImportKind(path string, asTemplate bool) (handled bool, prompt *confirmPrompt) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// The update reports unreadable files
+		return false, nil
+	}
+	looksTemplate := config.LooksLikeTemplate(string(content))
+	name := filepath.Base(path)
+	switch {
+	case !asTemplate && !looksTemplate:
+		return false, nil
+	case !asTemplate:
+		m.message = fmt.Sprintf("❌ %s looks like a template (placeholder keys) — choose \"Import as: template\" with t", name)
+	case !looksTemplate:
+		m.message = fmt.Sprintf("❌ %s is a personal config (real PrivateKey), not a template", name)
+	default:
+		env, err := config.NewConfigProcessor().DetectEnvironment(path)
+		if err != nil {
+			m.message = fmt.Sprintf("❌ Cannot tell which environment %s is for: %v", name, err)
+			break
+		}
+		findings := config.LintTemplate(string(content))
+		return true, &confirmPrompt{
+			question:  fmt.Sprintf("Install %s as the %s template (%d lint finding(s), normalized)?", name, vpn.Environment(env).DisplayName(), len(findings)),
+			message:   "Importing template...",
+			cmd:       importTemplate(path),
+			operation: "Import template",
+		}
+	}
+	m.addLogEntry(m.message)
+	return true, nil
+}
+
+// recordUpdateOutcome remembers a failed update so it can be retried, and
+// forgets it once an update succeeds.
+func recordUpdateOutcome(configPath, sourceHash string, updateErr error) error {
+	return state.Update(func(s *state.State) {
+		env, _ := config.NewConfigProcessor().DetectEnvironment(configPath)
+		if updateErr == nil {
+			s.LastUpdate = nil
+			if env != "" {
+				if s.Configs == nil {
+					s.Configs = map[string]*state.ConfigProvenance{}
+				}
+				s.Configs[env] = &state.ConfigProvenance{Source: "file " + filepath.Base(configPath), UpdatedAt: time.Now()}
+				// An update deferred earlier would undo this one
+				if s.PendingConfigs[env] != nil && config.DiscardStaged(env) == nil {
+					delete(s.PendingConfigs, env)
+				}
+			}
+			return
+		}
+		s.LastUpdate = &state.UpdateAttempt{
+			SourcePath:  configPath,
+			SourceHash:  sourceHash,
+			Environment: env,
+			Error:       summarizeError(updateErr),
+			AttemptedAt: time.Now(),
+		}
+	})
+}
+
+// configReadHint suggests what to do about a config that couldn't be read.
+func configReadHint(err error) string {
+	text := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(text, "no such file") || strings.Contains(text, "cannot find"):
+		return "💡 The config isn't installed: run 'tui-wireguard-vpn setup' or Update VPN Configuration"
+	case strings.Contains(text, "permission denied") || strings.Contains(text, "access is denied"):
+		return "💡 The configs are readable by root only: run tui-wireguard-vpn with sudo"
+	}
+	return "💡 Run 'tui-wireguard-vpn doctor' to check the installation"
+}
+
+// summarizeError returns the first line of an error, shortened for compact
+// one-line display.
+func summarizeError(err error) string {
+	summary := strings.TrimSpace(strings.SplitN(err.Error(), "\n", 2)[0])
+	summary = strings.TrimSuffix(summary, ".")
+	if len(summary) > 60 {
+		summary = summary[:57] + "..."
+	}
+	return summary
+}
+
+func generateConfig(svc vpn.Service, env vpn.Environment, address string) tea.Cmd {
+	return func() tea.Msg {
+		result, err := svc.GenerateConfig(env, address)
+		if err == nil {
+			// Only the config age shown in the menu depends on this
+			_ = state.RecordConfig(string(env), "generated")
 		}
+		return generateConfigMsg{result: result, err: err}
+	}
+}
+
+func exportDeviceTemplate(svc vpn.Service, env vpn.Environment, newKey bool) tea.Cmd {
+	return func() tea.Msg {
+		result, err := svc.ExportDeviceTemplate(env, newKey)
+		return deviceExportMsg{result: result, err: err}
 	}
 }
 
@@ -189,20 +962,405 @@ func viewConfig(svc vpn.Service, env vpn.Environment) tea.Cmd {
 	}
 }
 
-func (m model) Init() tea.Cmd {
-	return checkVPNStatus(m.vpnSvc)
+func createBackup(dir, passphrase, reason string) tea.Cmd {
+	return func() tea.Msg {
+		path, err := backup.Create(dir, passphrase)
+		return backupMsg{path: path, reason: reason, err: err}
+	}
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.WindowSizeMsg:
-		m.terminalWidth = msg.Width
-		m.terminalHeight = msg.Height
-		
-		// Pass window size to input model if it exists
-		if m.inputModel != nil {
-			var cmd tea.Cmd
-			inputModel, cmd := m.inputModel.Update(msg)
+// autoBackup returns a backup command when automatic backups are set up
+// (a backup directory and a passphrase in the environment), or nil.
+func (m model) autoBackup(reason string) tea.Cmd {
+	passphrase := m.settings.Backup.Passphrase()
+	if m.settings.Backup.Dir == "" || passphrase == "" {
+		return nil
+	}
+	return createBackup(m.settings.Backup.Dir, passphrase, reason)
+}
+
+func backupPassphraseEnvName(appSettings *settings.Settings) string {
+	if appSettings.Backup.PassphraseEnv != "" {
+		return appSettings.Backup.PassphraseEnv
+	}
+	return "the variable named by passphrase_env in [backup]"
+}
+
+// remoteSources builds the remote sync sources declared in the settings.
+func remoteSources(appSettings *settings.Settings) []config.RemoteSource {
+	var sources []config.RemoteSource
+	for _, env := range vpn.Environments() {
+		profile := appSettings.Profile(string(env))
+		if profile == nil || (profile.RemoteTemplateURL == "" && profile.RemoteConfigURL == "") {
+			continue
+		}
+		sources = append(sources, config.RemoteSource{
+			Env:         string(env),
+			TemplateURL: profile.RemoteTemplateURL,
+			ConfigURL:   profile.RemoteConfigURL,
+			AuthEnv:     profile.RemoteAuthEnv,
+		})
+	}
+	return sources
+}
+
+func syncFromRemote(sources []config.RemoteSource) tea.Cmd {
+	return func() tea.Msg {
+		processor := config.NewConfigProcessor()
+		ctx, cancel := context.WithTimeout(context.Background(), vpnOperationTimeout)
+		defer cancel()
+		result := processor.SyncFromRemote(ctx, nil, sources)
+		for _, change := range result.Changes {
+			if change.Kind == "config" && change.Updated {
+				_ = state.RecordConfig(change.Env, "server")
+			}
+		}
+		return remoteSyncMsg{
+			result:   result,
+			warnings: processor.Warnings,
+			lastSync: config.LastRemoteSync(sources),
+		}
+	}
+}
+
+// How often the sync schedule is looked at; slots are whole minutes
+const syncScheduleInterval = time.Minute
+
+func scheduleSyncCheck() tea.Cmd {
+	return tea.Tick(syncScheduleInterval, func(time.Time) tea.Msg {
+		return syncScheduleTickMsg{}
+	})
+}
+
+// syncSlots claims the scheduled sync's slots in the state file: a slot
+// claimed by the agent (or an earlier run) isn't run again.
+func syncSlots(appSettings *settings.Settings) *schedule.Claimer {
+	return &schedule.Claimer{
+		Schedule: appSettings.SyncSchedule,
+		LastRun: func() time.Time {
+			st, _ := state.Load()
+			return st.ScheduledSync
+		},
+		Claim: state.ClaimScheduledSync,
+	}
+}
+
+// runScheduledSync does what the sync policy says for a due slot: "auto"
+// installs the new files and reconnects connected when its files changed,
+// "prompt" only checks what is new. Either way it goes in the audit log.
+func runScheduledSync(ctx context.Context, svc vpn.Service, sources []config.RemoteSource, policy string, connected vpn.Environment) remoteSyncMsg {
+	processor := config.NewConfigProcessor()
+	var msg remoteSyncMsg
+	_ = audit.Run(audit.ActionScheduledSync, "", policy, func() error {
+		if policy != "auto" {
+			msg.result = processor.CheckRemote(ctx, nil, sources)
+		} else {
+			msg.result = processor.SyncFromRemote(ctx, nil, sources)
+			for _, change := range msg.result.Changes {
+				if change.Kind == "config" && change.Updated {
+					_ = state.RecordConfig(change.Env, "server (scheduled)")
+				}
+				if change.Updated && connected != "" && change.Env == string(connected) {
+					msg.reloaded = connected
+				}
+			}
+		}
+		for _, change := range msg.result.Changes {
+			if change.Err != nil {
+				return fmt.Errorf("%s", change)
+			}
+		}
+		return nil
+	})
+	if msg.reloaded != "" {
+		// Start stops the current tunnel first, so this loads the new config
+		msg.reloadErr = svc.Start(msg.reloaded)
+	}
+	msg.warnings = processor.Warnings
+	msg.lastSync = config.LastRemoteSync(sources)
+	return msg
+}
+
+func scheduledSync(svc vpn.Service, sources []config.RemoteSource, policy string, connected vpn.Environment) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), vpnOperationTimeout)
+		defer cancel()
+		msg := runScheduledSync(ctx, svc, sources, policy, connected)
+		if policy != "auto" {
+			return pendingUpdatesMsg{result: msg.result}
+		}
+		return msg
+	}
+}
+
+// checkGateways resolves the canonical hostname of every profile that has one
+// and reports the first environment whose installed Endpoint looks stale.
+func checkGateways(appSettings *settings.Settings) tea.Cmd {
+	return func() tea.Msg {
+		var msg gatewayCheckMsg
+		for _, env := range vpn.Environments() {
+			profile := appSettings.Profile(string(env))
+			if profile == nil || profile.EndpointHost == "" {
+				continue
+			}
+			migration, err := vpn.DetectGatewayMigration(context.Background(), env, profile.EndpointHost)
+			if err != nil {
+				msg.errs = append(msg.errs, err)
+				continue
+			}
+			if migration != nil && msg.migration == nil {
+				msg.migration = migration
+			}
+		}
+		return msg
+	}
+}
+
+func scheduleGatewayCheck() tea.Cmd {
+	return tea.Tick(gatewayCheckInterval, func(time.Time) tea.Msg {
+		return gatewayTickMsg{}
+	})
+}
+
+func applyGatewayUpdate(svc vpn.Service, migration *vpn.GatewayMigration, reconnect bool) tea.Cmd {
+	return func() tea.Msg {
+		err := vpn.ApplyGatewayMigration(migration)
+		if err == nil && reconnect {
+			// Start stops the current tunnel first, so this reloads the new Endpoint
+			err = svc.Start(migration.Environment)
+		}
+		return vpnOperationMsg{
+			operation: "gateway_update",
+			success:   err == nil,
+			err:       err,
+		}
+	}
+}
+
+// rollbackMsg reports a config rollback: the backup restored, nil when
+// nothing was, and whether the tunnel was restarted on it.
+type rollbackMsg struct {
+	env       vpn.Environment
+	backup    *config.ConfigBackup
+	restarted bool
+	err       error
+}
+
+func rollBack(svc vpn.Service, env vpn.Environment, restart bool) tea.Cmd {
+	return func() tea.Msg {
+		backup, err := rollbackConfig(svc, env, restart)
+		return rollbackMsg{env: env, backup: backup, restarted: restart, err: err}
+	}
+}
+
+// confirmRollback asks to restore the newest config backup of whichever
+// environment's config changed last, and to restart its tunnel when it is
+// up.
+func (m *model) confirmRollback() {
+	var env vpn.Environment
+	var latest *config.ConfigBackup
+	for _, candidate := range vpn.Environments() {
+		backup, err := config.LatestBackup(string(candidate))
+		if err != nil {
+			m.addLogEntry(fmt.Sprintf("⚠️ Could not list the %s backups: %v", candidate.DisplayName(), err))
+			continue
+		}
+		if backup != nil && (latest == nil || backup.Taken.After(latest.Taken)) {
+			env, latest = candidate, backup
+		}
+	}
+	if latest == nil {
+		m.message = "Nothing to roll back: no config backups yet"
+		m.addLogEntry(fmt.Sprintf("⚠️ Nothing to roll back: %s holds no config backups", filepath.Join(core.ConfigDir, config.BackupDir)))
+		return
+	}
+	taken := latest.Taken.Format("2006-01-02 15:04:05")
+	restart := m.tunnelUp(env)
+	question := fmt.Sprintf("Roll back %s to the backup of %s? The current config is not kept.", core.ConfigFile(env), taken)
+	if restart {
+		question = fmt.Sprintf("%s VPN is connected: roll back %s to the backup of %s and restart it (%s goes down)?", env.DisplayName(), core.ConfigFile(env), taken, env.Interface())
+	}
+	m.confirm = &confirmPrompt{
+		question:  question,
+		message:   fmt.Sprintf("Rolling back %s...", core.ConfigFile(env)),
+		cmd:       rollBack(m.vpnSvc, env, restart),
+		operation: "Rollback config",
+	}
+}
+
+func (m model) hasGatewayHosts() bool {
+	if m.settings == nil {
+		return false
+	}
+	for _, profile := range m.settings.Profiles {
+		if profile.EndpointHost != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Init kicks off the startup checks concurrently; the dashboard renders with
+// placeholders until they report back.
+func (m model) Init() tea.Cmd {
+	cmds := []tea.Cmd{checkVPNStatus(m.vpnSvc)}
+	if !m.setupChecked {
+		cmds = append(cmds, checkSetup())
+	}
+	if m.hasGatewayHosts() {
+		cmds = append(cmds, checkGateways(m.settings))
+	}
+	if m.settings.Backup.Dir != "" && backup.Due(m.settings.Backup.Dir) {
+		cmds = append(cmds, m.autoBackup("weekly"))
+	}
+	if len(m.settings.Locations) > 0 {
+		cmds = append(cmds, detectLocation(m.settings.Locations), scheduleRouteCheck())
+	}
+	if m.settings.SyncSchedule != nil && len(remoteSources(m.settings)) > 0 {
+		// Look right away, for a slot missed while the app wasn't running
+		cmds = append(cmds, func() tea.Msg { return syncScheduleTickMsg{} })
+	}
+	if m.settings.BandwidthWarnMiB > 0 {
+		cmds = append(cmds, scheduleBandwidthSample())
+	}
+	if m.autoRefresh {
+		cmds = append(cmds, scheduleClockTick(m.refreshSeq))
+	}
+	cmds = append(cmds, findSSHHosts(m.settings), watchSleep(m.sleepMonitor), scheduleHistoryTick(), loadRouteLabels)
+	return tea.Batch(cmds...)
+}
+
+// collectOverview probes both environments without touching any tunnel.
+func collectOverview(ctx context.Context, svc vpn.Service, officeSubnets []string, seq int) tea.Cmd {
+	return func() tea.Msg {
+		return overviewMsg{overview: vpn.CollectOverview(ctx, svc, officeSubnets), seq: seq}
+	}
+}
+
+// refreshOverview starts a new overview probe, cancelling one still running.
+func (m *model) refreshOverview() tea.Cmd {
+	m.stopOverviewProbe()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.overviewCancel = cancel
+	m.overviewSeq++
+	m.overviewProbing = true
+	return collectOverview(ctx, m.vpnSvc, m.settings.OfficeSubnets, m.overviewSeq)
+}
+
+// startPreflight runs env's preflight for the preflight panel, unless one
+// is running already.
+func (m *model) startPreflight(env vpn.Environment) tea.Cmd {
+	if m.preflightBusy {
+		return nil
+	}
+	m.preflightEnv = env
+	m.preflightBusy = true
+	m.addLogEntry(fmt.Sprintf("🛫 Preflight for %s (no connection is changed)...", env.DisplayName()))
+	return runPreflight(m.vpnSvc, m.settings, env)
+}
+
+// preflightTarget is the environment the menu's preflight checks: the
+// connected one, else the auto_connect profile, else the first.
+func (m model) preflightTarget() vpn.Environment {
+	if m.status != nil && m.status.Connected {
+		return m.status.Environment
+	}
+	if env, err := vpn.ParseEnvironment(m.settings.AutoConnect); err == nil {
+		return env
+	}
+	return vpn.Environments()[0]
+}
+
+// startSync syncs the remote sources now, as Sync from Server does.
+func (m *model) startSync() tea.Cmd {
+	m.loading = true
+	m.message = "Syncing configs from server..."
+	m.beginOperation("Sync from server")
+	m.logStep("🔄 Syncing templates and configs from server...")
+	return syncFromRemote(remoteSources(m.settings))
+}
+
+// closeSidePanels closes whichever view replaces the help panel.
+func (m *model) closeSidePanels() {
+	m.stopOverviewProbe()
+	m.overviewOpen = false
+	m.connectionsOpen = false
+	m.routesOpen = false
+	m.configOpen = false
+	m.preflightOpen = false
+	m.historyOpen = false
+}
+
+func (m *model) stopOverviewProbe() {
+	if m.overviewCancel != nil {
+		m.overviewCancel()
+		m.overviewCancel = nil
+	}
+	m.overviewProbing = false
+}
+
+// findSSHHosts matches the ~/.ssh/config hosts against each environment's
+// installed routes and ssh_hosts. It runs in the background, so parsing and
+// reading the configs never hold up the status panel.
+func findSSHHosts(appSettings *settings.Settings) tea.Cmd {
+	return func() tea.Msg {
+		hosts, err := sshhosts.Load()
+		if err != nil || len(hosts) == 0 {
+			return sshHostsMsg{}
+		}
+		reachable := map[vpn.Environment][]string{}
+		for _, env := range vpn.Environments() {
+			routes, _ := config.InstalledRoutes(string(env))
+			var patterns []string
+			if profile := appSettings.Profile(string(env)); profile != nil {
+				patterns = profile.SSHHosts
+			}
+			reachable[env] = sshhosts.Reachable(hosts, routes, patterns)
+		}
+		return sshHostsMsg{hosts: reachable}
+	}
+}
+
+func detectLocation(locations []*settings.Location) tea.Cmd {
+	return func() tea.Msg {
+		loc, facts := location.Detect(context.Background(), locations)
+		return locationMsg{location: loc, facts: facts}
+	}
+}
+
+func scheduleRouteCheck() tea.Cmd {
+	return tea.Tick(routeCheckInterval, func(at time.Time) tea.Msg {
+		iface, addr := location.DefaultRoute()
+		return routeCheckMsg{route: iface + " " + addr, at: at}
+	})
+}
+
+func checkSetup() tea.Cmd {
+	return func() tea.Msg {
+		status, err := config.CheckSetupStatusNonInteractive()
+		return setupCheckMsg{status: status, err: err, loose: config.LoosePermissions()}
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case signalMsg:
+		if m.shutDown(msg.signal) {
+			m.message = "Cancelling before exit..."
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case tea.WindowSizeMsg:
+		m.terminalWidth = msg.Width
+		m.terminalHeight = msg.Height
+		m.activityLog.SetSize(render.LogViewportSize(m.logPanelHeight()))
+		m.configView.Width, m.configView.Height = m.configViewSize()
+		
+		// Pass the input panel's size to the input model if it exists
+		if m.inputModel != nil {
+			var cmd tea.Cmd
+			inputModel, cmd := m.inputModel.Update(m.inputPanelSize())
 			if updatedModel, ok := inputModel.(*ui.UpdateModel); ok {
 				m.inputModel = updatedModel
 			}
@@ -210,25 +1368,291 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 		
+	case ui.PathHintMsg:
+		// The check of a path typed into the update panel
+		if m.inputModel != nil {
+			m.inputModel.Update(msg)
+		}
+		return m, nil
+		
 	case tea.KeyMsg:
+		// A Start queued behind a running operation asks for its code too
+		if m.mfa != nil {
+			return m, m.updateMFA(msg)
+		}
+		if m.routeCheck != nil {
+			return m, m.updateRouteCheck(msg)
+		}
+		if m.labelEdit != nil {
+			return m, m.updateLabelEdit(msg)
+		}
+		if m.switcher != nil {
+			return m, m.updateSwitcher(msg)
+		}
+		if m.conflict != nil {
+			return m, m.updateConflict(msg)
+		}
+		// A question about a queued operation can be answered while another
+		// runs; one that would run a command itself waits its turn
+		if m.confirm != nil && (m.confirm.op != nil || !m.loading) {
+			prompt := m.confirm
+			m.confirm = nil
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "y", "Y":
+				if prompt.op != nil {
+					return m, m.requestOp(*prompt.op)
+				}
+				m.loading = true
+				m.message = prompt.message
+				if prompt.operation != "" {
+					m.beginOperation(prompt.operation)
+				}
+				return m, prompt.cmd
+			case "d", "D":
+				if prompt.deferCmd != nil {
+					return m, prompt.deferCmd
+				}
+			}
+			// Anything else, including Enter and Esc, means No
+			m.message = "Cancelled"
+			m.addLogEntry(fmt.Sprintf("❌ Cancelled: %s", prompt.question))
+			m.noteUpdateResult(false, "cancelled")
+			return m, nil
+		}
+		if m.review != nil && !m.loading {
+			return m, m.updateConfigReview(msg)
+		}
 		if m.loading {
+			return m, m.updateBusy(msg)
+		}
+		
+		if m.miniMode {
+			// Everything but the status is hidden, so nothing else is
+			// actionable until the dashboard is expanded again
+			switch msg.String() {
+			case "ctrl+c", "q":
+				return m, tea.Quit
+			case "m":
+				m.miniMode = false
+			}
 			return m, nil
 		}
 		
+		if m.reconnect != nil && !m.showInputPanel {
+			switch msg.String() {
+			case "enter":
+				env := vpn.Environment(m.reconnect.Environment)
+				m.reconnect = nil
+				return m, m.requestOp(startOp(env))
+			case "esc":
+				// Declined: the session counts as ended, so it isn't offered
+				// again on the next launch
+				m.reconnect = nil
+				_ = state.RecordStopped("")
+				return m, nil
+			}
+		}
+		
+		// While a path is being typed, Tab completes it and q is just a letter
+		typingPath := m.showInputPanel && m.activePanel == 1 && m.inputModel != nil && m.inputModel.TypingPath()
+		
 		switch msg.String() {
 		case "ctrl+c", "q":
+			if typingPath && msg.String() == "q" {
+				break
+			}
 			return m, tea.Quit
+		case "m":
+			// Collapse to a single status line, e.g. while screen sharing
+			if !m.showInputPanel {
+				m.miniMode = true
+				return m, nil
+			}
+		case "s":
+			// Leave read-only mode for the setup wizard
+			if m.readOnly && !m.showInputPanel {
+				if err := setSetupSkipped(false); err != nil {
+					m.addLogEntry(fmt.Sprintf("⚠️ Could not save state: %v", err))
+				}
+				m.setupNeeded = m.setupIncomplete
+				if m.setupNeeded == nil {
+					m.setupNeeded = &config.SetupStatus{NeedsSetup: true}
+				}
+				return m, tea.Quit
+			}
+		case "g":
+			// Update the Endpoint of a config whose gateway has moved
+			if m.gatewayMigration != nil && !m.showInputPanel && !m.readOnly {
+				migration := m.gatewayMigration
+				reconnect := m.status != nil && m.status.Connected && m.status.Environment == migration.Environment
+				m.gatewayMigration = nil
+				m.loading = true
+				m.message = fmt.Sprintf("Updating %s endpoint to %s...", migration.Environment.DisplayName(), migration.NewEndpoint())
+				m.beginOperation("Update gateway endpoint")
+				m.logStep(fmt.Sprintf("🔧 Updating %s endpoint: %s → %s", migration.Environment.DisplayName(), migration.ConfiguredIP, migration.NewEndpoint()))
+				return m, applyGatewayUpdate(m.vpnSvc, migration, reconnect)
+			}
+		case "u":
+			// Keep sudo's credentials cached for the session, or drop them
+			if m.showInputPanel {
+				break
+			}
+			if m.sudoKept {
+				m.sudoKept = false
+				m.sudoSeq++
+				return m, dropSudo()
+			}
+			if sudo.IsRoot() {
+				m.message = "🔒 Running as root: sudo isn't needed"
+				return m, nil
+			}
+			return m, keepSudo()
+		case "i":
+			// Resolve tunnels up at once, after Esc put it off
+			if !m.showInputPanel && m.status != nil && len(m.status.ConflictingInterfaces) > 0 {
+				m.conflictLater = ""
+				return m, m.openConflict()
+			}
+		case "c":
+			// Copy a short diagnostic snapshot for a support request
+			if !m.showInputPanel {
+				m.copySnapshot()
+				return m, nil
+			}
+		case "x", "X":
+			// Export the viewed config for another device; X also
+			// generates its keypair
+			if m.viewedConfig != "" && !m.showInputPanel {
+				return m, exportDeviceTemplate(m.vpnSvc, m.viewedConfig, msg.String() == "X")
+			}
+		case "a":
+			// Label the selected route
+			if m.routesOpen && m.activePanel == 1 && !m.showInputPanel && !m.routesBusy && m.routesCursor < len(m.routes) {
+				prefix := m.routes[m.routesCursor].Prefix
+				input := textinput.New()
+				input.Prompt = fmt.Sprintf("🏷️ Label for %s: ", prefix)
+				input.Placeholder = "e.g. GKE pods"
+				input.CharLimit = routelabels.MaxLength
+				input.SetValue(m.routeLabels.Label(prefix))
+				input.Focus()
+				m.labelEdit = &labelEditor{prefix: prefix, input: input}
+				return m, nil
+			}
+			// What would Stop drop?
+			if m.status != nil && m.status.Connected && !m.showInputPanel {
+				m.closeSidePanels()
+				m.connectionsOpen = true
+				m.connectionsBusy = true
+				m.activePanel = 1
+				return m, listConnections(m.vpnSvc, m.status.Environment, false)
+			}
+		case "A":
+			// Install the deferred config updates without waiting for the
+			// disconnect
+			if len(m.deferredConfigs) > 0 && !m.showInputPanel && !m.readOnly && !m.loading {
+				return m, m.applyDeferredConfig(true)
+			}
+		case "D":
+			// Drop the deferred config updates
+			if len(m.deferredConfigs) > 0 && !m.showInputPanel && !m.loading {
+				m.discardDeferredConfigs()
+				return m, nil
+			}
+		case "U":
+			// Apply what the scheduled check found
+			if len(m.pendingUpdates) > 0 && !m.showInputPanel && !m.readOnly {
+				return m, m.startSync()
+			}
+		case "p":
+			// Pause auto-refresh, e.g. on battery, or resume it
+			if m.showInputPanel {
+				break
+			}
+			m.refreshSeq++
+			if m.autoRefresh {
+				m.autoRefresh = false
+				m.message = "⏸️ Auto-refresh paused (p to resume)"
+				return m, nil
+			}
+			m.autoRefresh = true
+			seconds := m.settings.StatusRefreshSeconds
+			if seconds <= 0 {
+				seconds = settings.Default().StatusRefreshSeconds
+				m.settings.StatusRefreshSeconds = seconds
+			}
+			m.message = fmt.Sprintf("▶️ Auto-refresh every %ds", seconds)
+			return m, scheduleClockTick(m.refreshSeq)
+		case "e":
+			// Switch environments without going through the menu
+			if !m.showInputPanel {
+				m.switcher = &envSwitcher{}
+				if m.status != nil && m.status.Connected && m.status.Environment == vpn.Environments()[0] {
+					m.switcher.cursor = 1
+				}
+				return m, nil
+			}
+		case "w":
+			// Will traffic to X use the tunnel?
+			if m.status != nil && m.status.Connected && !m.showInputPanel {
+				m.routeCheck = &routePrompt{env: m.status.Environment}
+				return m, nil
+			}
+		case "o":
+			// Which AllowedIPs carry what
+			if m.status != nil && m.status.Connected && !m.showInputPanel {
+				m.closeSidePanels()
+				m.routesOpen = true
+				m.routesBusy = true
+				m.routesCursor = 0
+				m.activePanel = 1
+				return m, lookUpRoutes(m.vpnSvc, m.status.Environment)
+			}
+		case "r":
+			// Probe again; nothing else on the dashboard uses r
+			if m.overviewOpen && !m.showInputPanel {
+				return m, m.refreshOverview()
+			}
+			if m.connectionsOpen && !m.showInputPanel && m.status != nil && m.status.Connected {
+				m.connectionsBusy = true
+				return m, listConnections(m.vpnSvc, m.status.Environment, false)
+			}
+			if m.routesOpen && !m.showInputPanel && m.status != nil && m.status.Connected {
+				m.routesBusy = true
+				return m, lookUpRoutes(m.vpnSvc, m.status.Environment)
+			}
+			if m.preflightOpen && !m.showInputPanel {
+				return m, m.startPreflight(m.preflightEnv)
+			}
+			if m.historyOpen && !m.showInputPanel {
+				return m, loadHistory()
+			}
 		case "tab":
+			if typingPath {
+				break
+			}
 			// Cycle through panels: 0 (main+status) -> 1 (help/input) -> 2 (activity) -> 3 (controls) -> 0
 			m.activePanel = (m.activePanel + 1) % 4
 			return m, nil
 		case "esc":
 			// Close input panel if open, otherwise quit
 			if m.showInputPanel {
+				if m.generateModel != nil {
+					m.addLogEntry("❌ Config generation cancelled")
+				} else if m.updateSession == nil || len(m.updateSession.results) == 0 {
+					m.addLogEntry("❌ Configuration update cancelled")
+				}
 				m.showInputPanel = false
 				m.activePanel = 0
 				m.inputModel = nil
-				m.addLogEntry("❌ Configuration update cancelled")
+				m.generateModel = nil
+				m.finishUpdateSession()
+				return m, nil
+			}
+			if m.overviewOpen || m.connectionsOpen || m.routesOpen || m.configOpen || m.preflightOpen || m.historyOpen {
+				m.closeSidePanels()
+				m.activePanel = 0
 				return m, nil
 			}
 			return m, tea.Quit
@@ -237,76 +1661,185 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Main menu navigation
 				m.cursor--
 			} else if m.activePanel == 2 {
-				// Activity log scrolling up
-				if m.logViewportStart > 0 {
-					m.logViewportStart--
-				}
+				// Selecting an older entry stops following new ones
+				m.activityLog.Up()
+			} else if m.activePanel == 1 && m.configOpen && !m.showInputPanel {
+				m.configView.ScrollUp(1)
+			} else if m.activePanel == 1 && m.routesOpen && !m.showInputPanel && m.routesCursor > 0 {
+				m.routesCursor--
 			}
 		case "down", "j":
-			if m.activePanel == 0 && m.cursor < len(m.choices)-1 {
+			if m.activePanel == 0 && m.cursor < len(m.menu)-1 {
 				// Main menu navigation
 				m.cursor++
 			} else if m.activePanel == 2 {
-				// Activity log scrolling down
-				maxStart := len(m.outputLog) - 5 // Use constant viewport size for simplicity
-				if maxStart < 0 {
-					maxStart = 0
-				}
-				if m.logViewportStart < maxStart {
-					m.logViewportStart++
+				m.activityLog.Down()
+			} else if m.activePanel == 1 && m.configOpen && !m.showInputPanel {
+				m.configView.ScrollDown(1)
+			} else if m.activePanel == 1 && m.routesOpen && !m.showInputPanel && m.routesCursor < len(m.routes)-1 {
+				m.routesCursor++
+			}
+		case "pgup", "home", "pgdown", "end":
+			if m.activePanel == 1 && m.configOpen && !m.showInputPanel {
+				switch msg.String() {
+				case "pgup":
+					m.configView.PageUp()
+				case "home":
+					m.configView.GotoTop()
+				case "pgdown":
+					m.configView.PageDown()
+				case "end":
+					m.configView.GotoBottom()
 				}
+				break
+			}
+			if m.activePanel != 2 {
+				break
+			}
+			switch msg.String() {
+			case "pgup":
+				m.activityLog.PageUp()
+			case "home":
+				m.activityLog.Home()
+			case "pgdown":
+				m.activityLog.PageDown()
+			case "end":
+				m.activityLog.Follow()
+			}
+		case "F":
+			// Jump back to the newest entry and keep following
+			if !m.showInputPanel {
+				m.activityLog.Follow()
 			}
 		case "enter", " ":
+			// Expand or collapse an operation's entries in the activity log
+			if m.activePanel == 2 && !m.showInputPanel {
+				m.activityLog.Toggle()
+				break
+			}
 			// Only handle menu selection when main panel is active AND no input panel is showing
 			if m.activePanel != 0 || m.showInputPanel {
 				break
 			}
-			switch m.cursor {
-			case 0: // Start Production VPN
-				m.loading = true
-				if m.status != nil && m.status.Connected {
-					m.message = "Switching to Production VPN..."
-				} else {
-					m.message = "Starting Production VPN..."
-				}
-				return m, startVPN(m.vpnSvc, vpn.Production)
-			case 1: // Start Non-Production VPN
-				m.loading = true
-				if m.status != nil && m.status.Connected {
-					m.message = "Switching to Non-Production VPN..."
-				} else {
-					m.message = "Starting Non-Production VPN..."
-				}
-				return m, startVPN(m.vpnSvc, vpn.NonProduction)
-			case 2: // Stop VPN
-				m.loading = true
-				m.message = "Stopping VPN..."
-				return m, stopVPN(m.vpnSvc)
-			case 3: // Refresh Status
+			entry := m.menu[m.cursor]
+			if m.readOnly && entry.mutating() {
+				m.message = "🔒 Read-only: setup is incomplete (press s to run setup)"
+				break
+			}
+			if entry.action == menuStart && m.unconfigured(entry.env) {
+				return m, m.setUpMissing()
+			}
+			if m.menuDisabled(m.cursor) {
+				m.explainDisabled(m.cursor)
+				break
+			}
+			switch entry.action {
+			case menuStart:
+				return m, m.requestTeardown(startOp(entry.env), entry.env)
+			case menuStop:
+				// Asks first when it would drop connections
+				return m, listConnections(m.vpnSvc, m.status.Environment, true)
+			case menuRefresh:
 				m.loading = true
+				m.refreshing = true
 				m.message = "Checking VPN status..."
 				return m, checkVPNStatus(m.vpnSvc)
-			case 4: // Update Configuration
+			case menuUpdate:
+				// Reports a session left waiting, e.g. on a declined prompt
+				m.finishUpdateSession()
 				// Show input panel with embedded filepicker
 				m.showInputPanel = true
 				m.activePanel = 1 // Switch to input panel
-				m.inputModel = ui.NewUpdateModel()
+				var lastUpdate *state.UpdateAttempt
+				if st, err := state.Load(); err != nil {
+					m.addLogEntry(fmt.Sprintf("⚠️ %v", err))
+				} else {
+					lastUpdate = st.LastUpdate
+				}
+				m.inputModel = ui.NewUpdateModel(lastUpdate)
+				m.updateSession = &updateSession{started: time.Now()}
 				m.addLogEntry("🔧 Configuration update started...")
 				
 				// Initialize the input model and send it a window size message
 				initCmd := m.inputModel.Init()
+				panelSize := m.inputPanelSize()
 				sizeCmd := func() tea.Msg {
-					return tea.WindowSizeMsg{Width: m.terminalWidth, Height: m.terminalHeight}
+					return panelSize
 				}
 				return m, tea.Batch(initCmd, sizeCmd)
-			case 5: // View Production Config
-				return m, viewConfig(m.vpnSvc, vpn.Production)
-			case 6: // View Non-Production Config
-				return m, viewConfig(m.vpnSvc, vpn.NonProduction)
-			case 7: // Quit
+			case menuRollback:
+				m.confirmRollback()
+				return m, nil
+			case menuView:
+				return m, viewConfig(m.vpnSvc, entry.env)
+			case menuViewActive:
+				return m, viewConfig(m.vpnSvc, m.status.Environment)
+			case menuGenerate:
+				m.showInputPanel = true
+				m.activePanel = 1
+				m.generateModel = ui.NewGenerateModel()
+				m.addLogEntry("🔑 New client config generation started...")
+				return m, m.generateModel.Init()
+			case menuSync:
+				if len(remoteSources(m.settings)) == 0 {
+					break
+				}
+				return m, m.startSync()
+			case menuBackUp:
+				if m.settings.Backup.Dir == "" {
+					break
+				}
+				passphrase := m.settings.Backup.Passphrase()
+				if passphrase == "" {
+					m.message = "Set the backup passphrase variable or run 'tui-wireguard-vpn backup create'"
+					m.addLogEntry(fmt.Sprintf("⚠️ Backup needs a passphrase: export %s, or use 'tui-wireguard-vpn backup create'", backupPassphraseEnvName(m.settings)))
+					break
+				}
+				m.loading = true
+				m.message = "Backing up configs..."
+				return m, createBackup(m.settings.Backup.Dir, passphrase, "")
+			case menuOverview:
+				m.closeSidePanels()
+				m.overviewOpen = true
+				m.activePanel = 1
+				m.addLogEntry("🌐 Probing both environments (no connection is changed)...")
+				return m, m.refreshOverview()
+			case menuPreflight:
+				env := m.preflightTarget()
+				m.closeSidePanels()
+				m.preflightOpen = true
+				m.activePanel = 1
+				if env != m.preflightEnv {
+					m.preflight = nil
+				}
+				return m, m.startPreflight(env)
+			case menuHistory:
+				m.closeSidePanels()
+				m.historyOpen = true
+				m.activePanel = 1
+				return m, loadHistory()
+			case menuQuit:
 				return m, tea.Quit
 			}
 		}
+
+		// Delegate input to the config generation wizard when it's showing
+		if m.showInputPanel && m.activePanel == 1 && m.generateModel != nil {
+			generateModel, cmd := m.generateModel.Update(msg)
+			if updatedModel, ok := generateModel.(*ui.GenerateModel); ok {
+				m.generateModel = updatedModel
+				if env, address, ready := m.generateModel.Request(); ready {
+					m.addLogEntry(fmt.Sprintf("🔑 Generating %s config for %s", vpn.Environment(env).DisplayName(), address))
+					return m, generateConfig(m.vpnSvc, vpn.Environment(env), address)
+				}
+				if m.generateModel.Done() {
+					m.showInputPanel = false
+					m.activePanel = 0
+					m.generateModel = nil
+				}
+			}
+			return m, cmd
+		}
 		
 		// Delegate input to input model when input panel is active
 		if m.showInputPanel && m.activePanel == 1 && m.inputModel != nil {
@@ -314,449 +1847,1676 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			inputModel, cmd := m.inputModel.Update(msg)
 			if updatedModel, ok := inputModel.(*ui.UpdateModel); ok {
 				m.inputModel = updatedModel
+				if m.inputModel.Done() {
+					m.finishUpdateSession()
+					return m, nil
+				}
 				
 				// Check if input model has a config path (user completed selection)
 				if configPath := m.inputModel.GetConfigPath(); configPath != "" {
-					// Start config update process
+					asTemplate := m.inputModel.ImportAsTemplate()
+					// Start config update process; the browser is kept for
+					// another round
+					if m.updateSession != nil {
+						m.updateSession.browser = m.inputModel
+						m.updateSession.current = configPath
+					}
 					m.showInputPanel = false
 					m.activePanel = 0
 					m.inputModel = nil
-					m.loading = true
-					m.message = "Updating configuration..."
 					m.addLogEntry(fmt.Sprintf("🔧 Processing config: %s", configPath))
-					return m, updateConfig(m.vpnSvc, configPath)
+					if handled, prompt := m.reviewImportKind(configPath, asTemplate); handled {
+						m.confirm = prompt
+						if prompt == nil {
+							m.noteUpdateResult(false, "refused")
+						}
+						return m, nil
+					}
+					// Nothing is written until the changes are seen
+					return m, previewConfig(configPath)
 				}
 			}
 			return m, cmd
 		}
 		
 	case vpnStatusMsg:
+		m.statusChecked = true
+		m.statusPending = false
+		m.lastStatusCheck = time.Now()
+		// The log keeps the underlying error, once per change of it
+		if msg.err != nil && (m.statusErr == nil || m.statusErr.Error() != msg.err.Error()) {
+			m.addLogEntry(fmt.Sprintf("❌ Status check failed: %v", msg.err))
+		}
+		m.statusErr = msg.err
+		var record tea.Cmd
+		if msg.err == nil {
+			m.status = msg.status
+			m.rate.Observe(msg.status, m.lastStatusCheck)
+			if msg.status.Connected {
+				m.reconnect = nil
+			}
+			if state := historyState(msg.status); state != m.historyState {
+				m.historyState = state
+				record = recordHistory(stats.Event{Time: m.lastStatusCheck, State: state})
+			}
+		}
+		// The refresh after an operation can come back while the next one
+		// already runs; only an explicit Refresh owns the loading state and
+		// the message area, the rest belong to the operation's own result
+		if m.refreshing {
+			m.refreshing = false
+			m.loading = false
+			if msg.err != nil {
+				m.message = fmt.Sprintf("Error checking status: %v", msg.err)
+			} else {
+				m.message = "Status updated"
+			}
+		} else if msg.err != nil && !m.loading {
+			m.message = fmt.Sprintf("Error checking status: %v", msg.err)
+		}
+		// A deferred update goes in before anything starts the tunnel
+		if msg.err == nil {
+			if cmd := m.applyDeferredConfig(false); cmd != nil {
+				return m, tea.Batch(record, cmd, m.checkConflict())
+			}
+		}
+		return m, tea.Batch(record, m.tryAutoConnect(), m.checkConflict())
+
+	case configStagedMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Could not defer the update: %v", msg.err)
+			m.addLogEntry(m.message)
+			m.noteUpdateResult(false, fmt.Sprintf("could not defer: %v", msg.err))
+			return m, nil
+		}
+		envName := vpn.Environment(msg.env).DisplayName()
+		if msg.replaced {
+			m.addLogEntry(fmt.Sprintf("🔁 The deferred %s config update was replaced by a newer one (%s)", envName, msg.pending.Source))
+		}
+		if m.deferredConfigs == nil {
+			m.deferredConfigs = map[string]*state.PendingConfig{}
+		}
+		m.deferredConfigs[msg.env] = msg.pending
+		m.message = fmt.Sprintf("⏳ %s config update deferred: installed when %s disconnects", envName, envName)
+		m.addLogEntry(fmt.Sprintf("⏳ Deferred %s config update from %s (sha256 %s)", envName, msg.pending.Source, msg.pending.SHA256[:12]))
+		return m, m.noteUpdateResult(true, "deferred until disconnect")
+
+	case deferredAppliedMsg:
 		m.loading = false
+		envName := vpn.Environment(msg.env).DisplayName()
 		if msg.err != nil {
-			m.message = fmt.Sprintf("Error checking status: %v", msg.err)
-		} else {
-			m.status = msg.status
-			m.message = "Status updated"
+			// Kept for A to retry, but not tried again on every status check
+			if pending := m.deferredConfigs[msg.env]; pending != nil {
+				pending.Error = summarizeError(msg.err)
+			}
+			m.message = fmt.Sprintf("❌ Deferred %s config update failed: %v (A to retry, D to discard)", envName, msg.err)
+			m.logStep(m.message)
+			m.endOperation(false)
+			return m, nil
+		}
+		delete(m.deferredConfigs, msg.env)
+		m.message = fmt.Sprintf("✅ Deferred %s config update installed (previous config backed up)", envName)
+		m.logStep(m.message)
+		m.endOperation(true)
+		m.configs = loadConfigProvenance()
+		return m, tea.Batch(checkVPNStatus(m.vpnSvc), m.autoBackup("deferred config update"), findSSHHosts(m.settings))
+
+	case tunnelsMsg:
+		return m, m.updateConflictTunnels(msg)
+
+	case setupCheckMsg:
+		m.setupChecked = true
+		for _, file := range msg.loose {
+			m.addLogEntry(fmt.Sprintf("⚠️ %s is open to other users: run setup or chmod 600 it", file))
+		}
+		if msg.err != nil {
+			m.addLogEntry(fmt.Sprintf("⚠️ Could not verify setup: %v (run 'tui-wireguard-vpn doctor')", msg.err))
+			return m, m.tryAutoConnect()
 		}
+		m.setupStatus = msg.status
+		if msg.status.NeedsSetup && m.readOnly {
+			// Setup was skipped before; stay read-only instead of asking again
+			m.setupIncomplete = msg.status
+			m.addLogEntry("🔒 Setup is incomplete: read-only mode (press s to run setup)")
+			return m, m.tryAutoConnect()
+		}
+		if msg.status.NeedsSetup {
+			// Leave the dashboard; main runs the setup wizard
+			m.setupNeeded = msg.status
+			return m, tea.Quit
+		}
+		if m.readOnly {
+			// Setup was completed some other way since it was skipped
+			m.readOnly = false
+			m.setupIncomplete = nil
+			if err := setSetupSkipped(false); err != nil {
+				m.addLogEntry(fmt.Sprintf("⚠️ Could not save state: %v", err))
+			}
+		}
+		return m, m.tryAutoConnect()
 		
 	case vpnOperationMsg:
 		m.loading = false
+		if m.exitSignal != nil {
+			// The operation shutDown cancelled is over: its outcome is
+			// left to the status check the exit asks for
+			return m, tea.Quit
+		}
+		m.finishSteps(msg.err)
+		if msg.operation == stopOp.Key && m.sleeping {
+			// The tunnel is down (or won't go down): let the suspend go on
+			if !msg.success {
+				m.sleepStopped = ""
+			}
+			m.releaseSleep()
+		}
+		if msg.stateErr != nil {
+			m.logStep(fmt.Sprintf("⚠️ Could not save operation history: %v", msg.stateErr))
+		}
+		for _, warning := range msg.warnings {
+			m.logStep(fmt.Sprintf("⚠️ %s", warning))
+		}
 		if msg.success {
 			switch msg.operation {
 			case "update_config":
 				m.message = "✅ Configuration updated successfully!"
-				m.addLogEntry("✅ Configuration updated successfully!")
-			case "start_Production":
-				m.message = "✅ Production VPN started successfully!"
-				m.addLogEntry("✅ Production VPN started successfully!")
-			case "start_NonProduction":
-				m.message = "✅ Non-Production VPN started successfully!"
-				m.addLogEntry("✅ Non-Production VPN started successfully!")
-			case "stop":
+				m.logStep("✅ Configuration updated" + msg.timing.took())
+			case startOp(msg.env).Key:
+				m.message = fmt.Sprintf("✅ %s VPN started successfully!", msg.env.DisplayName())
+				m.logStep(fmt.Sprintf("✅ %s VPN started on %s%s", msg.env.DisplayName(), msg.env.Interface(), msg.timing.took()))
+			case keepOp(msg.kept).Key:
+				m.message = fmt.Sprintf("✅ Kept %s, the other tunnels are stopped", msg.kept)
+				m.logStep(m.message)
+			case stopOp.Key:
 				m.message = "✅ VPN stopped successfully!"
-				m.addLogEntry("✅ VPN stopped successfully!")
+				m.logStep("✅ VPN stopped" + msg.timing.took())
+				m.confirm = m.reviewLeftovers(msg.leftovers)
+			case "gateway_update":
+				m.message = "✅ Gateway endpoint updated (previous config backed up)"
+				m.logStep("✅ Gateway endpoint updated (previous config backed up)")
 			default:
 				m.message = fmt.Sprintf("Operation %s completed successfully", msg.operation)
-				m.addLogEntry(fmt.Sprintf("Operation %s completed successfully", msg.operation))
+				m.logStep(fmt.Sprintf("Operation %s completed successfully%s", msg.operation, msg.timing.took()))
+			}
+			m.endOperation(true)
+			// Refresh status after successful operation, and back up
+			// freshly written configs
+			if msg.operation == "update_config" || msg.operation == "gateway_update" {
+				m.configs = loadConfigProvenance()
+				if st, err := state.Load(); err == nil {
+					for env := range m.deferredConfigs {
+						if st.PendingConfigs[env] == nil {
+							m.logStep(fmt.Sprintf("🗑️ The deferred %s config update was dropped: superseded by this one", vpn.Environment(env).DisplayName()))
+						}
+					}
+					m.deferredConfigs = st.PendingConfigs
+				}
+				var another tea.Cmd
+				if msg.operation == "update_config" {
+					another = m.noteUpdateResult(true, "updated")
+				}
+				return m, tea.Batch(checkVPNStatus(m.vpnSvc), m.autoBackup("config update"), findSSHHosts(m.settings), another)
 			}
-			// Refresh status after successful operation
-			return m, checkVPNStatus(m.vpnSvc)
+			return m, tea.Batch(checkVPNStatus(m.vpnSvc), m.finishOp(msg.operation, true))
+		} else if env, ok := missingConfig(msg.operation, msg.err); ok {
+			// wg-quick's "does not exist": point at setup rather than wg-quick
+			m.message = fmt.Sprintf("❌ %s VPN is not configured — press Enter on it to set up", env.DisplayName())
+			m.logStep(fmt.Sprintf("❌ %s VPN is not configured: %v", env.DisplayName(), msg.err))
+			m.endOperation(false)
+			return m, tea.Batch(checkSetup(), m.finishOp(msg.operation, false))
 		} else {
 			switch msg.operation {
 			case "update_config":
 				m.message = fmt.Sprintf("❌ Configuration update failed: %v", msg.err)
-				m.addLogEntry(fmt.Sprintf("❌ Configuration update failed: %v", msg.err))
-			case "start_Production":
-				m.message = fmt.Sprintf("❌ Failed to start Production VPN: %v", msg.err)
-				m.addLogEntry(fmt.Sprintf("❌ Failed to start Production VPN: %v", msg.err))
-			case "start_NonProduction":
-				m.message = fmt.Sprintf("❌ Failed to start Non-Production VPN: %v", msg.err)
-				m.addLogEntry(fmt.Sprintf("❌ Failed to start Non-Production VPN: %v", msg.err))
-			case "stop":
+				m.logStep(fmt.Sprintf("❌ Configuration update failed: %v", msg.err))
+			case startOp(msg.env).Key:
+				m.message = fmt.Sprintf("❌ Failed to start %s VPN: %v", msg.env.DisplayName(), msg.err)
+				m.logStep(fmt.Sprintf("❌ Failed to start %s VPN on %s: %v", msg.env.DisplayName(), msg.env.Interface(), msg.err))
+			case keepOp(msg.kept).Key:
+				m.message = fmt.Sprintf("❌ Failed to stop the tunnels besides %s: %v", msg.kept, msg.err)
+				m.logStep(m.message)
+			case stopOp.Key:
 				m.message = fmt.Sprintf("❌ Failed to stop VPN: %v", msg.err)
-				m.addLogEntry(fmt.Sprintf("❌ Failed to stop VPN: %v", msg.err))
+				m.logStep(fmt.Sprintf("❌ Failed to stop VPN: %v", msg.err))
+			case "gateway_update":
+				m.message = fmt.Sprintf("❌ Failed to update gateway endpoint: %v", msg.err)
+				m.logStep(fmt.Sprintf("❌ Failed to update gateway endpoint: %v", msg.err))
 			default:
 				m.message = fmt.Sprintf("Operation %s failed: %v", msg.operation, msg.err)
-				m.addLogEntry(fmt.Sprintf("Operation %s failed: %v", msg.operation, msg.err))
+				m.logStep(fmt.Sprintf("Operation %s failed: %v", msg.operation, msg.err))
 			}
+			m.endOperation(false)
+			if msg.operation == "update_config" {
+				m.noteUpdateResult(false, "failed: "+summarizeError(msg.err))
+			}
+			return m, m.finishOp(msg.operation, false)
 		}
 		
-	case configViewMsg:
+	case templateImportMsg:
+		m.loading = false
+		for _, warning := range msg.warnings {
+			m.logStep(fmt.Sprintf("⚠️ %s", warning))
+		}
+		envName := vpn.Environment(msg.env).DisplayName()
+		switch {
+		case msg.err != nil && msg.remerge:
+			m.message = fmt.Sprintf("❌ Re-merge failed: %v", msg.err)
+		case msg.err != nil:
+			m.message = fmt.Sprintf("❌ Template import failed: %v", msg.err)
+		case msg.remerge:
+			m.message = fmt.Sprintf("✅ %s config re-merged with the new template (previous config backed up)", envName)
+		default:
+			m.message = fmt.Sprintf("✅ %s template installed", envName)
+		}
+		m.logStep(m.message)
+		m.endOperation(msg.err == nil)
 		if msg.err != nil {
-			envName := "Production"
-			if msg.environment == vpn.NonProduction {
-				envName = "Non-Production"
+			m.noteUpdateResult(false, "template import failed: "+summarizeError(msg.err))
+			return m, nil
+		}
+		if msg.remerge {
+			m.configs = loadConfigProvenance()
+			return m, tea.Batch(checkVPNStatus(m.vpnSvc), m.autoBackup("template re-merge"), findSSHHosts(m.settings))
+		}
+		if !m.unconfigured(vpn.Environment(msg.env)) {
+			m.confirm = &confirmPrompt{
+				question:  fmt.Sprintf("Re-merge the installed %s config with the new template (DNS and AllowedIPs; the current one is backed up)?", envName),
+				message:   "Re-merging config...",
+				cmd:       remergeConfig(msg.env),
+				operation: "Re-merge config with template",
 			}
-			m.message = fmt.Sprintf("❌ Failed to read %s config: %v", envName, msg.err)
-			m.addLogEntry(fmt.Sprintf("❌ Failed to read %s config: %v", envName, msg.err))
+		}
+		return m, tea.Batch(m.autoBackup("template import"), m.noteUpdateResult(true, "template installed"))
+
+	case cleanupMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Cleanup failed: %v", msg.err)
+			m.logStep(m.message)
 		} else {
-			envName := "Production"
-			if msg.environment == vpn.NonProduction {
-				envName = "Non-Production"
+			m.message = "✅ Leftovers of the tunnel removed"
+			m.logStep("🧹 Removed DNS settings and routes left behind by the tunnel")
+		}
+		m.endOperation(msg.err == nil)
+		return m, nil
+
+	case generateConfigMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Failed to generate config: %v", msg.err)
+			m.addLogEntry(fmt.Sprintf("❌ Failed to generate config: %v", msg.err))
+		} else {
+			envName := vpn.Environment(msg.result.Environment).DisplayName()
+			m.message = fmt.Sprintf("✅ %s config generated — register the public key with infra", envName)
+			m.addLogEntry(fmt.Sprintf("✅ Generated %s config at %s", envName, msg.result.Path))
+			m.addLogEntry(fmt.Sprintf("🔑 Public key for registration: %s", msg.result.PublicKey))
+			if msg.result.BackupPath != "" {
+				m.addLogEntry(fmt.Sprintf("  Previous config backed up to %s", msg.result.BackupPath))
 			}
-			m.message = fmt.Sprintf("📄 %s VPN Configuration", envName)
-			m.addLogEntry(fmt.Sprintf("📄 Viewed %s VPN Configuration", envName))
-			
-			// Add config details to activity log (without sensitive data)
-			configLines := strings.Split(msg.config, "\n")
-			for _, line := range configLines {
-				if strings.TrimSpace(line) != "" {
-					m.addLogEntry(fmt.Sprintf("  %s", line))
-				}
+		}
+		if m.generateModel != nil {
+			m.generateModel.SetResult(msg.result, msg.err)
+		}
+		if msg.err == nil {
+			m.configs = loadConfigProvenance()
+			return m, m.autoBackup("new config")
+		}
+		return m, nil
+
+	case mfaCheckMsg:
+		prompt := m.mfa
+		if prompt == nil {
+			return m, nil
+		}
+		envName := vpn.Environment(prompt.gate.Profile).DisplayName()
+		switch {
+		case msg.err == nil:
+			m.mfa = nil
+			m.addLogEntry(fmt.Sprintf("🔐 One-time code accepted for %s", envName))
+			return m, m.submitOp(prompt.op)
+		case errors.Is(msg.err, mfa.ErrInvalidCode):
+			prompt.attempts++
+			prompt.code = ""
+			prompt.checking = false
+			if prompt.attempts >= mfa.MaxAttempts {
+				m.mfa = nil
+				m.message = fmt.Sprintf("❌ Too many invalid codes — %s not started", envName)
+				m.addLogEntry(fmt.Sprintf("❌ %s not started: %d invalid one-time codes", envName, prompt.attempts))
+				return m, nil
+			}
+			m.message = fmt.Sprintf("❌ Invalid code (%d attempts left)", mfa.MaxAttempts-prompt.attempts)
+		default:
+			m.mfa = nil
+			m.message = fmt.Sprintf("❌ Could not check the one-time code: %v", msg.err)
+			m.addLogEntry(fmt.Sprintf("❌ %s not started: could not check the one-time code: %v", envName, msg.err))
+		}
+		return m, nil
+
+	case deviceExportMsg:
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Failed to export device template: %v", msg.err)
+			m.addLogEntry(fmt.Sprintf("❌ Failed to export device template: %v", msg.err))
+			return m, nil
+		}
+		envName := vpn.Environment(msg.result.Environment).DisplayName()
+		m.message = fmt.Sprintf("📤 %s device template exported — fill in the xxxx... placeholders", envName)
+		m.addLogEntry(fmt.Sprintf("📤 Exported %s device template to %s", envName, msg.result.Path))
+		if msg.result.PublicKey != "" {
+			m.addLogEntry(fmt.Sprintf("🔑 Public key of the new device for registration: %s", msg.result.PublicKey))
+		}
+		return m, nil
+
+	case stepMsg:
+		// Events out of sequence are dropped; the checklist stays as it was
+		if m.steps != nil && m.steps.Apply(msg.event, time.Now()) == nil && msg.event.Status == ops.StepDone {
+			m.logStep("✓ " + msg.event.Step)
+		}
+		return m, waitForStream(msg.stream)
+
+	case wgOutputMsg:
+		if strings.TrimSpace(msg.line) != "" {
+			m.logStep(fmt.Sprintf("%s │ %s", msg.operation, msg.line))
+		}
+		return m, waitForStream(msg.stream)
+
+	case gatewayCheckMsg:
+		// Resolution failures never count as a move; just note them
+		for _, err := range msg.errs {
+			m.addLogEntry(fmt.Sprintf("⚠️ Gateway check skipped: %v", err))
+		}
+		if msg.migration != nil {
+			previous := m.gatewayMigration
+			m.gatewayMigration = msg.migration
+			if previous == nil || previous.NewEndpoint() != msg.migration.NewEndpoint() {
+				m.addLogEntry(fmt.Sprintf("⚠️ %s %s", msg.migration.Environment.DisplayName(), msg.migration))
+			}
+		} else {
+			m.gatewayMigration = nil
+		}
+		return m, scheduleGatewayCheck()
+
+	case gatewayTickMsg:
+		return m, checkGateways(m.settings)
+
+	case sudoTickMsg:
+		if !m.sudoKept || msg.seq != m.sudoSeq {
+			return m, nil
+		}
+		return m, refreshSudo(msg.seq)
+
+	case sudoMsg:
+		switch msg.action {
+		case "validate":
+			if msg.err != nil {
+				m.message = fmt.Sprintf("❌ Could not keep sudo credentials: %v", msg.err)
+				return m, nil
+			}
+			m.sudoKept = true
+			m.sudoSeq++
+			m.message = "🔒 sudo credentials are kept for this session (u to drop them)"
+			m.addLogEntry("🔒 Keeping sudo credentials cached until quit")
+			return m, scheduleSudoRefresh(m.sudoSeq)
+		case "refresh":
+			if msg.seq != m.sudoSeq {
+				return m, nil
+			}
+			if msg.err != nil {
+				m.sudoKept = false
+				m.addLogEntry(fmt.Sprintf("⚠️ %v; press u to enter the password again", msg.err))
+				return m, nil
+			}
+			return m, scheduleSudoRefresh(msg.seq)
+		case "drop":
+			if msg.err != nil {
+				m.message = fmt.Sprintf("❌ Failed to drop sudo credentials: %v", msg.err)
+				return m, nil
+			}
+			m.message = "🔓 sudo credentials dropped"
+			m.addLogEntry("🔓 Dropped cached sudo credentials")
+		}
+		return m, nil
+
+	case routeCheckMsg:
+		// A different default route, or a gap much longer than the tick
+		// (the machine was suspended), may mean another network. Our own
+		// full tunnel taking the default route doesn't.
+		resumed := !m.lastRouteCheck.IsZero() && msg.at.Sub(m.lastRouteCheck) > 4*routeCheckInterval
+		m.lastRouteCheck = msg.at
+		if strings.HasPrefix(msg.route, "julo-") || (msg.route == m.routeKey && !resumed) {
+			return m, scheduleRouteCheck()
+		}
+		m.routeKey = msg.route
+		return m, tea.Batch(detectLocation(m.settings.Locations), scheduleRouteCheck())
+
+	case locationMsg:
+		return m, m.applyLocation(msg)
+
+	case sshHostsMsg:
+		m.sshHosts = msg.hosts
+		return m, nil
+
+	case routeCheckResultMsg:
+		m.routeCheck = nil
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Route check failed: %v", msg.err)
+			m.addLogEntry(fmt.Sprintf("❌ Route check for %s failed: %v", msg.target, msg.err))
+			return m, nil
+		}
+		icon := "🧭"
+		if msg.decision.Mismatch() {
+			icon = "⚠️"
+		}
+		decision := msg.decision.String()
+		if label := m.routeLabels.Label(msg.decision.ConfigRoute); label != "" {
+			decision += fmt.Sprintf(" [%s]", label)
+		}
+		m.message = fmt.Sprintf("%s %s", icon, decision)
+		m.addLogEntry(fmt.Sprintf("%s Route check: %s", icon, decision))
+		return m, nil
+
+	case routesMsg:
+		m.routesBusy = false
+		m.routes, m.routesErr = msg.routes, msg.err
+		m.routesCursor = max(0, min(m.routesCursor, len(m.routes)-1))
+		return m, nil
+
+	case routeLabelsMsg:
+		if msg.err != nil {
+			m.addLogEntry(fmt.Sprintf("⚠️ Route labels not loaded: %v", msg.err))
+			return m, nil
+		}
+		m.routeLabels = msg.labels
+		for _, conflict := range msg.conflicts {
+			m.addLogEntry(fmt.Sprintf("🏷️ %s", conflict))
+		}
+		return m, nil
+
+	case routeLabelSavedMsg:
+		m.labelEdit = nil
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Label not saved: %v", msg.err)
+			m.addLogEntry(fmt.Sprintf("❌ Label of %s not saved: %v", msg.prefix, msg.err))
+			return m, nil
+		}
+		m.routeLabels = msg.labels
+		if msg.label == "" {
+			m.addLogEntry(fmt.Sprintf("🏷️ Removed the label of %s", msg.prefix))
+		} else {
+			m.addLogEntry(fmt.Sprintf("🏷️ Labelled %s %q", msg.prefix, msg.label))
+		}
+		for _, conflict := range msg.conflicts {
+			if conflict.Prefix == msg.prefix.Masked().String() {
+				m.message = fmt.Sprintf("🏷️ %s", conflict)
+				m.addLogEntry(m.message)
+			}
+		}
+		if shipped := m.routeLabels.Label(msg.prefix); msg.label == "" && shipped != "" {
+			m.message = fmt.Sprintf("🏷️ %s shows its shipped label %q again", msg.prefix, shipped)
+		}
+		return m, nil
+
+	case bandwidthTickMsg:
+		if m.loading || m.status == nil || !m.status.Connected {
+			return m, scheduleBandwidthSample()
+		}
+		return m, sampleBandwidth(m.vpnSvc)
+
+	case clockTickMsg:
+		if !m.autoRefresh || msg.seq != m.refreshSeq {
+			return m, nil
+		}
+		// Nothing to do but redraw, so the handshake age keeps counting;
+		// no check while an operation runs, so none races a Start or Stop
+		interval := time.Duration(m.settings.StatusRefreshSeconds) * time.Second
+		if m.loading || m.statusPending || time.Since(m.lastStatusCheck) < interval {
+			return m, scheduleClockTick(msg.seq)
+		}
+		m.statusPending = true
+		return m, tea.Batch(checkVPNStatus(m.vpnSvc), scheduleClockTick(msg.seq))
+
+	case sleepWatchMsg:
+		if msg.err != nil {
+			if !errors.Is(msg.err, sleep.ErrUnsupported) {
+				m.addLogEntry(fmt.Sprintf("⚠️ Not watching for system sleep: %v", msg.err))
+			}
+			return m, nil
+		}
+		m.sleepEvents = msg.events
+		m.holdSleep()
+		return m, waitForSleep(m.sleepEvents)
+
+	case sleepMsg:
+		if msg.closed {
+			m.sleepEvents = nil
+			m.releaseSleep()
+			m.addLogEntry("⚠️ Stopped watching for system sleep")
+			return m, nil
+		}
+		if msg.sleeping {
+			return m, tea.Batch(m.prepareForSleep(), waitForSleep(m.sleepEvents))
+		}
+		return m, tea.Batch(m.resumeFromSleep(), waitForSleep(m.sleepEvents))
+
+	case bandwidthMsg:
+		if warning := m.bandwidth.Observe(msg.status, msg.at); warning != nil {
+			m.message = fmt.Sprintf("⚠️ %s (o to see which routes carry it)", warning)
+			m.addLogEntry(fmt.Sprintf("⚠️ %s: %s", warning.Environment.DisplayName(), warning))
+		}
+		return m, scheduleBandwidthSample()
+
+	case connectionsMsg:
+		if !msg.forStop {
+			m.connectionsBusy = false
+			m.connections, m.connectionsErr = msg.connections, msg.err
+			return m, nil
+		}
+		// A failed listing doesn't stand in the way of Stop
+		if msg.err != nil || len(msg.connections) == 0 {
+			return m, m.requestTeardown(stopOp, "")
+		}
+		op := stopOp
+		m.confirm = &confirmPrompt{
+			question: fmt.Sprintf("%d active connection(s) will be dropped (%s). Stop %s VPN (%s goes down)?",
+				len(msg.connections), render.ConnectionSummary(msg.connections), msg.env.DisplayName(), msg.env.Interface()),
+			op: &op,
+		}
+		return m, nil
+
+	case rollbackMsg:
+		m.loading = false
+		if msg.backup != nil {
+			m.logStep(fmt.Sprintf("⏪ Restored %s from %s (taken %s)", core.ConfigFile(msg.env), msg.backup, msg.backup.Taken.Format("2006-01-02 15:04:05")))
+			m.configs = loadConfigProvenance()
+		}
+		switch {
+		case msg.err != nil:
+			m.message = fmt.Sprintf("❌ Rollback failed: %v", msg.err)
+		case msg.restarted:
+			m.message = fmt.Sprintf("✅ %s config rolled back, VPN restarted", msg.env.DisplayName())
+		default:
+			m.message = fmt.Sprintf("✅ %s config rolled back", msg.env.DisplayName())
+		}
+		m.logStep(m.message)
+		m.endOperation(msg.err == nil)
+		return m, tea.Batch(checkVPNStatus(m.vpnSvc), findSSHHosts(m.settings))
+
+	case configPreviewMsg:
+		if msg.err != nil {
+			// The update reports why the config can't be merged
+			return m, m.installConfig(msg.path)
+		}
+		if m.confirm != nil || m.loading {
+			m.addLogEntry(fmt.Sprintf("❌ Configuration update cancelled: busy, pick %s again", filepath.Base(msg.path)))
+			m.noteUpdateResult(false, "cancelled: busy")
+			return m, nil
+		}
+		m.openConfigReview(msg.path, msg.preview)
+		return m, nil
+
+	case historyMsg:
+		m.history, m.historyErr = msg.timeline, msg.err
+		return m, nil
+
+	case historyTickMsg:
+		cmds := []tea.Cmd{scheduleHistoryTick()}
+		if m.historyState != "" {
+			cmds = append(cmds, recordHistory(stats.Event{Time: time.Now(), State: m.historyState}))
+		}
+		if m.historyOpen {
+			cmds = append(cmds, loadHistory())
+		}
+		return m, tea.Batch(cmds...)
+
+	case preflightMsg:
+		m.preflightBusy = false
+		if msg.result.Environment != m.preflightEnv {
+			return m, nil // the panel moved on to another environment
+		}
+		m.preflight = msg.result
+		icon := "✅"
+		if msg.result.Failed() {
+			icon = "❌"
+		}
+		m.addLogEntry(fmt.Sprintf("%s Preflight %s: %s", icon, msg.result.Environment.DisplayName(), msg.result.Summary()))
+		return m, nil
+
+	case overviewMsg:
+		if msg.seq != m.overviewSeq {
+			return m, nil // superseded by a refresh
+		}
+		m.overviewCancel = nil
+		m.overviewProbing = false
+		m.overview = msg.overview
+		return m, nil
+
+	case backupMsg:
+		switch {
+		case msg.err != nil && msg.reason != "":
+			m.addLogEntry(fmt.Sprintf("⚠️ Automatic backup (%s) failed: %v", msg.reason, msg.err))
+		case msg.err != nil:
+			m.loading = false
+			m.message = fmt.Sprintf("❌ Backup failed: %v", msg.err)
+			m.addLogEntry(m.message)
+		case msg.reason != "":
+			m.addLogEntry(fmt.Sprintf("💾 Automatic backup (%s) written to %s", msg.reason, msg.path))
+		default:
+			m.loading = false
+			m.message = "✅ Backup created"
+			m.addLogEntry(fmt.Sprintf("💾 Backup written to %s", msg.path))
+		}
+		return m, nil
+
+	case syncScheduleTickMsg:
+		if m.loading {
+			return m, scheduleSyncCheck()
+		}
+		slot, claimed, err := syncSlots(m.settings).Next()
+		if err != nil {
+			m.addLogEntry(fmt.Sprintf("⚠️ Scheduled sync skipped: %v", err))
+		}
+		if !claimed {
+			return m, scheduleSyncCheck()
+		}
+		connected := vpn.Environment("")
+		if m.status != nil && m.status.Connected {
+			connected = m.status.Environment
+		}
+		// Read-only mode installs nothing, so it can only ask
+		policy := m.settings.SyncPolicy
+		if m.readOnly {
+			policy = "prompt"
+		}
+		if policy == "auto" {
+			m.loading = true
+			m.message = "Running the scheduled sync from server..."
+			m.beginOperation("Scheduled sync from server")
+			m.logStep(fmt.Sprintf("🕘 Scheduled sync (%s, slot %s)", m.settings.SyncSchedule, slot.Format("Mon 2006-01-02 15:04")))
+		} else {
+			m.addLogEntry(fmt.Sprintf("🕘 Scheduled check for server updates (%s)", m.settings.SyncSchedule))
+		}
+		return m, tea.Batch(scheduledSync(m.vpnSvc, remoteSources(m.settings), policy, connected), scheduleSyncCheck())
+
+	case pendingUpdatesMsg:
+		m.pendingUpdates = nil
+		for _, change := range msg.result.Changes {
+			switch {
+			case change.Err != nil:
+				m.addLogEntry(fmt.Sprintf("❌ %s", change))
+			case change.Updated:
+				m.pendingUpdates = append(m.pendingUpdates, change)
+				m.addLogEntry(fmt.Sprintf("⬇️ %s %s: new version on the server", change.Env, change.Kind))
+			}
+		}
+		if len(m.pendingUpdates) > 0 {
+			m.message = fmt.Sprintf("⬇️ %d update(s) pending from the server — press U to apply", len(m.pendingUpdates))
+		}
+		return m, nil
+
+	case remoteSyncMsg:
+		m.loading = false
+		m.lastSync = msg.lastSync
+		m.syncFailed = msg.result.Failed()
+		if !m.syncFailed {
+			m.pendingUpdates = nil
+		}
+		updated := 0
+		for _, change := range msg.result.Changes {
+			switch {
+			case change.Err != nil:
+				m.logStep(fmt.Sprintf("❌ %s", change))
+			case change.Updated:
+				updated++
+				m.logStep(fmt.Sprintf("✅ %s", change))
+			default:
+				m.logStep(fmt.Sprintf("  %s", change))
+			}
+		}
+		for _, warning := range msg.warnings {
+			m.logStep(fmt.Sprintf("⚠️ %s", warning))
+		}
+		if msg.reloaded != "" {
+			if msg.reloadErr != nil {
+				m.logStep(fmt.Sprintf("❌ Failed to reconnect %s with the new config: %v", msg.reloaded.DisplayName(), msg.reloadErr))
+			} else {
+				m.logStep(fmt.Sprintf("🔄 Reconnected %s with the new config", msg.reloaded.DisplayName()))
 			}
 		}
+		m.endOperation(!m.syncFailed && msg.reloadErr == nil)
+		switch {
+		case m.syncFailed:
+			m.message = "❌ Sync failed, using installed files"
+		case updated == 0:
+			m.message = "✅ Already up to date"
+		default:
+			m.message = fmt.Sprintf("✅ Synced %d file(s) from server", updated)
+			m.configs = loadConfigProvenance()
+			return m, m.autoBackup("server sync")
+		}
+		return m, nil
+
+	case configViewMsg:
+		envName := msg.environment.DisplayName()
+		if msg.err != nil {
+			m.message = fmt.Sprintf("❌ Failed to read %s config: %v", envName, msg.err)
+			m.addLogEntry(m.message)
+			m.addLogEntry("    " + configReadHint(msg.err))
+		} else {
+			m.message = fmt.Sprintf("📄 %s VPN Configuration — x to export a device template", envName)
+			m.addLogEntry(fmt.Sprintf("📄 Viewed %s VPN Configuration", envName))
+			m.viewedConfig = msg.environment
+			m.openConfigView(msg.config)
+		}
 	}
 	
 	return m, nil
 }
 
-// addLogEntry adds a new entry to the activity log and adjusts viewport to show latest entries
-func (m *model) addLogEntry(entry string) {
-	m.outputLog = append(m.outputLog, entry)
-	
-	// Auto-scroll to show the latest entry (keep showing the most recent)
-	if len(m.outputLog) > m.logViewportSize {
-		m.logViewportStart = len(m.outputLog) - m.logViewportSize
+// unconfigured reports whether env's config is the one missing from a
+// partial setup; its Start entry then leads to setup instead.
+func (m model) unconfigured(env vpn.Environment) bool {
+	// The setup only installs prod and nonprod
+	if env != vpn.Production && env != vpn.NonProduction {
+		return false
+	}
+	return !m.readOnly && m.setupStatus != nil && m.setupStatus.Partial() && !m.setupStatus.HasConfig(string(env))
+}
+
+// setUpMissing leaves the dashboard for the setup wizard, which only asks
+// for the config a partial setup is missing.
+func (m *model) setUpMissing() tea.Cmd {
+	m.setupNeeded = m.setupStatus
+	return tea.Quit
+}
+
+// missingConfig recognizes a Start that failed because wg-quick found no
+// config for the environment.
+func missingConfig(operation string, err error) (vpn.Environment, bool) {
+	name, ok := strings.CutPrefix(operation, "start_")
+	if !ok || err == nil || !strings.Contains(err.Error(), "does not exist") {
+		return "", false
+	}
+	env, parseErr := vpn.ParseEnvironment(name)
+	return env, parseErr == nil
+}
+
+// configAge describes the age of env's installed config for its Start entry.
+func (m model) configAge(env vpn.Environment) (string, bool) {
+	if m.unconfigured(env) {
+		return "", false
+	}
+	if status := m.setupIncomplete; status != nil {
+		if installed, checked := status.Configs[string(env)]; checked && !installed {
+			return "not set up", true
+		}
+	}
+	maxAge := time.Duration(m.settings.ConfigMaxAgeDays) * 24 * time.Hour
+	if p := m.configs[string(env)]; p != nil {
+		return render.ConfigAge(p.UpdatedAt, p.Source, time.Now(), maxAge)
+	}
+	return render.ConfigAge(time.Time{}, "", time.Now(), maxAge)
+}
+
+// installConfig installs the user config at configPath, once it has been
+// reviewed, asking first when its hooks need an explicit yes or its tunnel
+// is up.
+func (m *model) installConfig(configPath string) tea.Cmd {
+	update := updateConfig(m.vpnSvc, configPath)
+	prompt := m.reviewDirectives(configPath, update)
+	if env, err := config.NewConfigProcessor().DetectEnvironment(configPath); err == nil && m.tunnelUp(vpn.Environment(env)) {
+		// Installing under a running tunnel only takes effect on
+		// reconnect; offer to wait for the disconnect instead
+		if prompt == nil {
+			prompt = &confirmPrompt{
+				question:  fmt.Sprintf("%s VPN is connected: install the update now (used from the next reconnect)?", vpn.Environment(env).DisplayName()),
+				message:   "Updating configuration...",
+				cmd:       update,
+				operation: "Update configuration",
+			}
+		}
+		prompt.deferCmd = stageConfig(configPath)
+	}
+	if prompt != nil {
+		m.confirm = prompt
+		return nil
+	}
+	m.loading = true
+	m.message = "Updating configuration..."
+	m.beginOperation("Update configuration")
+	return update
+}
+
+// updateSession follows Update VPN Configuration over its rounds, one
+// picked config each, so the configs of a credentials rotation go in without
+// starting over for every environment.
+type updateSession struct {
+	started time.Time
+	browser *ui.UpdateModel // parked while a picked config is applied
+	current string          // the config being applied, "" between rounds
+	results []ui.UpdateResult
+}
+
+// noteUpdateResult records how the session's current config went. After a
+// success the update panel comes back to offer another round (returning the
+// command that sizes it); anything else ends the session.
+func (m *model) noteUpdateResult(ok bool, result string) tea.Cmd {
+	s := m.updateSession
+	if s == nil || s.current == "" {
+		return nil
+	}
+	envName := "Unknown environment"
+	if env, err := config.NewConfigProcessor().DetectEnvironment(s.current); err == nil {
+		envName = vpn.Environment(env).DisplayName()
+	}
+	s.results = append(s.results, ui.UpdateResult{Environment: envName, Source: s.current, Result: result, OK: ok})
+	s.current = ""
+	// A question still pending (a re-merge, say) comes first
+	if !ok || s.browser == nil || m.confirm != nil || m.showInputPanel {
+		m.finishUpdateSession()
+		return nil
+	}
+	s.browser.Another(s.results)
+	m.inputModel = s.browser
+	m.showInputPanel = true
+	m.activePanel = 1
+	m.closeSidePanels()
+	panelSize := m.inputPanelSize()
+	return func() tea.Msg {
+		return panelSize
+	}
+}
+
+// finishUpdateSession ends the update session, closing its panel, and logs
+// what it applied as one group. Configs already applied stay installed
+// whatever ended it.
+func (m *model) finishUpdateSession() {
+	s := m.updateSession
+	if s == nil {
+		return
+	}
+	m.updateSession = nil
+	if m.inputModel != nil && m.inputModel == s.browser {
+		m.showInputPanel = false
+		m.activePanel = 0
+		m.inputModel = nil
+	}
+	applied := 0
+	for _, result := range s.results {
+		if result.OK {
+			applied++
+		}
+	}
+	// Nothing went in: the cancellation or failure is logged already
+	if applied == 0 {
+		return
+	}
+	group := m.activityLog.BeginGroupSince(fmt.Sprintf("Update session: %d of %d config(s) applied", applied, len(s.results)), s.started)
+	for _, result := range s.results {
+		icon := "✅"
+		if !result.OK {
+			icon = "❌"
+		}
+		m.activityLog.AppendTo(group, fmt.Sprintf("%s %s from %s: %s", icon, result.Environment, filepath.Base(result.Source), result.Result))
+	}
+	m.activityLog.EndGroup(group, applied == len(s.results))
+	if applied < len(s.results) {
+		m.message = fmt.Sprintf("⚠️ %d of %d config(s) applied this session; the applied ones stay installed", applied, len(s.results))
+	}
+}
+
+// configReview is a picked config's diff against the installed one, shown
+// in the config view until it is applied or cancelled.
+type configReview struct {
+	path    string
+	preview *config.ConfigPreview
+}
+
+// openConfigReview shows what installing configPath changes in the config
+// view; y installs it, Esc cancels.
+func (m *model) openConfigReview(configPath string, preview *config.ConfigPreview) {
+	m.review = &configReview{path: configPath, preview: preview}
+	width, _ := m.configViewSize()
+	m.openConfigView(render.RenderDiff(preview.Diff, m.routeLabels, width))
+	if preview.Unchanged() {
+		m.addLogEntry(fmt.Sprintf("📝 %s: no changes to %s", filepath.Base(configPath), filepath.Base(preview.Path)))
 	} else {
-		m.logViewportStart = 0
+		m.addLogEntry(fmt.Sprintf("📝 %s: +%d -%d lines in %s — y to apply, Esc to cancel", filepath.Base(configPath), preview.Added, preview.Removed, filepath.Base(preview.Path)))
 	}
 }
 
-func (m model) View() string {
-	// Simplified 4-panel layout with better proportions
-	leftWidth := m.terminalWidth / 2
-	rightWidth := m.terminalWidth / 2 - 2
-	bottomLeftWidth := (m.terminalWidth * 2 / 3) - 1
-	bottomRightWidth := (m.terminalWidth / 3) - 1
-	
-	topHeight := (m.terminalHeight * 2 / 3) - 6
-	bottomHeight := (m.terminalHeight / 3) - 3
-	
-	if m.showInputPanel && m.inputModel != nil {
-		// Layout with input panel: Menu + Status | Input | Activity Log | Controls
-		leftPanel := m.buildMainStatusPanel(leftWidth, topHeight)
-		inputPanel := m.buildInputPanel(rightWidth, topHeight)
-		activityPanel := m.buildOutputPanel(bottomLeftWidth, bottomHeight)
-		controlsPanel := m.buildControlsPanel(bottomRightWidth, bottomHeight)
-		
-		// Top row: Combined Menu+Status | Input
-		topRow := lipgloss.JoinHorizontal(lipgloss.Top, leftPanel, inputPanel)
-		
-		// Bottom row: Activity Log | Controls
-		bottomRow := lipgloss.JoinHorizontal(lipgloss.Top, activityPanel, controlsPanel)
-		
-		layout := lipgloss.JoinVertical(lipgloss.Left, 
-			titleStyle.Render(m.title),
-			"",
-			topRow,
-			"",
-			bottomRow)
-		
-		return layout
-	} else {
-		// Standard layout: Menu + Status | Help | Activity Log | Controls
-		leftPanel := m.buildMainStatusPanel(leftWidth, topHeight)
-		helpPanel := m.buildHelpPanel(rightWidth, topHeight)
-		activityPanel := m.buildOutputPanel(bottomLeftWidth, bottomHeight)
-		controlsPanel := m.buildControlsPanel(bottomRightWidth, bottomHeight)
-		
-		// Top row: Combined Menu+Status | Help
-		topRow := lipgloss.JoinHorizontal(lipgloss.Top, leftPanel, helpPanel)
-		
-		// Bottom row: Activity Log | Controls
-		bottomRow := lipgloss.JoinHorizontal(lipgloss.Top, activityPanel, controlsPanel)
-		
-		layout := lipgloss.JoinVertical(lipgloss.Left, 
-			titleStyle.Render(m.title),
-			"",
-			topRow,
-			"",
-			bottomRow)
-		
-		return layout
+// updateConfigReview handles keys while an update's changes are shown: y
+// installs it, Esc (or n) drops it, and the rest scrolls.
+func (m *model) updateConfigReview(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "y", "Y":
+		path := m.review.path
+		m.review = nil
+		m.closeSidePanels()
+		m.activePanel = 0
+		return m.installConfig(path)
+	case "esc", "n", "N", "q":
+		m.review = nil
+		m.closeSidePanels()
+		m.activePanel = 0
+		m.message = "Cancelled"
+		m.addLogEntry("❌ Configuration update cancelled")
+		m.noteUpdateResult(false, "cancelled")
+	case "up", "k":
+		m.configView.ScrollUp(1)
+	case "down", "j":
+		m.configView.ScrollDown(1)
+	case "pgup":
+		m.configView.PageUp()
+	case "pgdown":
+		m.configView.PageDown()
+	case "home":
+		m.configView.GotoTop()
+	case "end":
+		m.configView.GotoBottom()
+	}
+	return nil
+}
+
+// reviewDirectives logs what the merge will change about a config and, when
+// it runs hook scripts the policy doesn't strip, returns a prompt that only
+// installs it (cmd) after an explicit yes.
+func (m *model) reviewDirectives(configPath string, cmd tea.Cmd) *confirmPrompt {
+	directives, err := config.InspectConfig(configPath)
+	if err != nil {
+		return nil // The update itself reports unreadable files
+	}
+	if directives.SaveConfig {
+		m.addLogEntry("⚠️ SaveConfig = true will be removed: wg-quick would overwrite the managed config")
+	}
+	if len(directives.Hooks) == 0 {
+		return nil
+	}
+	if m.settings.StripHookScripts {
+		m.addLogEntry(fmt.Sprintf("⚠️ %d hook script(s) will be removed (strip_hook_scripts is set)", len(directives.Hooks)))
+		return nil
+	}
+	m.addLogEntry("⚠️ This config runs shell commands as root when the tunnel goes up or down:")
+	for _, hook := range directives.Hooks {
+		m.addLogEntry("    " + hook.String())
+	}
+	return &confirmPrompt{
+		question:  fmt.Sprintf("Install a config that runs %d shell command(s) as root (see log)?", len(directives.Hooks)),
+		message:   "Updating configuration...",
+		cmd:       cmd,
+		operation: "Update configuration",
+	}
+}
+
+// reviewLeftovers logs what a stopped tunnel left behind and, when some of it
+// can be fixed, returns a prompt to clean it up.
+func (m *model) reviewLeftovers(check *leftoverCheck) *confirmPrompt {
+	if check == nil {
+		return nil
+	}
+	if check.err != nil {
+		m.logStep(fmt.Sprintf("⚠️ Could not check for DNS and route leftovers: %v", check.err))
+	}
+	if len(check.dnsRestored) > 0 {
+		m.logStep(fmt.Sprintf("🌐 DNS restored: %s", strings.Join(check.dnsRestored, ", ")))
+	}
+	var fixable []vpn.Leftover
+	for _, leftover := range check.found {
+		m.logStep(fmt.Sprintf("⚠️ Left behind: %s", leftover))
+		if len(leftover.Fix) > 0 {
+			fixable = append(fixable, leftover)
+		}
+	}
+	switch {
+	case check.cleaned && check.cleanErr != nil:
+		m.logStep(fmt.Sprintf("❌ Automatic cleanup failed: %v", check.cleanErr))
+	case check.cleaned:
+		m.logStep("🧹 Removed what could be fixed automatically (disconnect_cleanup = \"auto\")")
+	case len(fixable) > 0:
+		return &confirmPrompt{
+			question:  fmt.Sprintf("Clean up %d DNS/route leftover(s) of the tunnel (see log)?", len(fixable)),
+			message:   "Cleaning up...",
+			cmd:       cleanUpLeftovers(m.vpnSvc, fixable),
+			operation: "Clean up tunnel leftovers",
+		}
+	}
+	return nil
+}
+
+// copySnapshot copies a redacted diagnostic snapshot to the clipboard.
+func (m *model) copySnapshot() {
+	snapshot := doctor.Snapshot(doctor.SnapshotInfo{
+		Status:    m.status,
+		StatusErr: m.statusErr,
+		Errors:    m.activityLog.Last("❌", 3),
+	}, time.Now())
+	path, err := ui.CopyOrSave(snapshot, "tui-wireguard-vpn-snapshot-*.txt")
+	switch {
+	case err != nil:
+		m.message = fmt.Sprintf("⚠️ Snapshot sent to the terminal clipboard, but %v", err)
+	case path != "":
+		m.message = "📋 Snapshot sent to the terminal clipboard (OSC52), also saved to " + path
+	default:
+		m.message = "📋 Diagnostic snapshot copied to clipboard"
+	}
+}
+
+// mfaGate returns the one-time code check op needs, if any: Starts of
+// profiles with mfa set.
+func (m model) mfaGate(op ops.Op) *mfa.Gate {
+	env, ok := strings.CutPrefix(op.Key, "start_")
+	if !ok {
+		return nil
+	}
+	profile := m.settings.Profile(env)
+	if profile == nil || profile.MFA == "" {
+		return nil
+	}
+	return &mfa.Gate{Profile: env, Mode: profile.MFA, Command: profile.MFACommand}
+}
+
+// updateMFA handles keys while a one-time code is asked for: digits,
+// Backspace, Enter to check and Esc to give up on the Start.
+func (m *model) updateMFA(msg tea.KeyMsg) tea.Cmd {
+	prompt := m.mfa
+	key := msg.String()
+	if key == "ctrl+c" {
+		return tea.Quit
+	}
+	if prompt.checking {
+		return nil
+	}
+	switch {
+	case key == "esc":
+		m.mfa = nil
+		m.message = "Cancelled"
+		m.addLogEntry(fmt.Sprintf("❌ Cancelled: one-time code for %s", vpn.Environment(prompt.gate.Profile).DisplayName()))
+	case key == "backspace":
+		if prompt.code != "" {
+			prompt.code = prompt.code[:len(prompt.code)-1]
+		}
+	case key == "enter":
+		if !mfa.ValidFormat(prompt.code) {
+			m.message = fmt.Sprintf("Enter the %d-digit code", mfa.Digits)
+			break
+		}
+		prompt.checking = true
+		m.message = "Checking code..."
+		return checkMFA(prompt.gate, prompt.code)
+	case len(key) == 1 && key[0] >= '0' && key[0] <= '9' && len(prompt.code) < mfa.Digits:
+		prompt.code += key
+	}
+	return nil
+}
+
+// updateRouteCheck handles keys while a route check destination is typed.
+func (m *model) updateRouteCheck(msg tea.KeyMsg) tea.Cmd {
+	prompt := m.routeCheck
+	switch key := msg.String(); {
+	case key == "ctrl+c":
+		return tea.Quit
+	case prompt.checking:
+	case key == "esc":
+		m.routeCheck = nil
+	case key == "backspace":
+		if prompt.target != "" {
+			prompt.target = prompt.target[:len(prompt.target)-1]
+		}
+	case key == "enter":
+		if strings.TrimSpace(prompt.target) == "" {
+			break
+		}
+		prompt.checking = true
+		return checkRoute(m.vpnSvc, prompt.env, prompt.target)
+	case msg.Type == tea.KeyRunes:
+		prompt.target += string(msg.Runes)
 	}
+	return nil
 }
 
-func (m model) buildMainStatusPanel(width, height int) string {
-	var content strings.Builder
-	
-	// VPN Status section first
-	statusText := "Disconnected"
-	if m.status != nil && m.status.Connected {
-		env := "Unknown"
-		if m.status.Environment == vpn.Production {
-			env = "Production"
-		} else if m.status.Environment == vpn.NonProduction {
-			env = "Non-Production"
+// updateLabelEdit handles keys while a route's label is typed.
+func (m *model) updateLabelEdit(msg tea.KeyMsg) tea.Cmd {
+	edit := m.labelEdit
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "esc":
+		if !edit.saving {
+			m.labelEdit = nil
 		}
-		statusText = fmt.Sprintf("Connected to %s", env)
-		if m.status.Interface != "" {
-			statusText += fmt.Sprintf(" (%s)", m.status.Interface)
+	case "enter":
+		if edit.saving {
+			break
+		}
+		if err := routelabels.Validate(edit.input.Value()); err != nil {
+			m.message = fmt.Sprintf("⚠️ %v", err)
+			break
+		}
+		edit.saving = true
+		return saveRouteLabel(edit.prefix, edit.input.Value())
+	default:
+		if !edit.saving {
+			var cmd tea.Cmd
+			edit.input, cmd = edit.input.Update(msg)
+			return cmd
 		}
 	}
-	
-	if m.status != nil && m.status.Connected {
-		content.WriteString(connectedStatusStyle.Render("Status: "+statusText) + "\n")
-	} else {
-		content.WriteString(disconnectedStatusStyle.Render("Status: "+statusText) + "\n")
-	}
-	
-	// Show connection details if connected
-	if m.status != nil && m.status.Connected {
-		if m.status.Endpoint != "" {
-			content.WriteString(fmt.Sprintf("Endpoint: %s\n", m.status.Endpoint))
+	return nil
+}
+
+// updateSwitcher handles keys while the environment switcher is open. A
+// pick goes the way of the matching Start entry of the menu, asking first
+// when it would replace a connection; Esc closes it without doing anything.
+func (m *model) updateSwitcher(msg tea.KeyMsg) tea.Cmd {
+	switcher := m.switcher
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "esc", "e", "q":
+		m.switcher = nil
+	case "up", "k":
+		if switcher.cursor > 0 {
+			switcher.cursor--
 		}
-		if m.status.LastSeen != nil {
-			content.WriteString(fmt.Sprintf("Last Handshake: %s ago\n", time.Since(*m.status.LastSeen).Truncate(time.Second)))
+		switcher.message = ""
+	case "down", "j":
+		if switcher.cursor < len(vpn.Environments())-1 {
+			switcher.cursor++
 		}
-		if m.status.BytesRx > 0 || m.status.BytesTx > 0 {
-			content.WriteString(fmt.Sprintf("Data: ↓ %s  ↑ %s\n", formatBytes(m.status.BytesRx), formatBytes(m.status.BytesTx)))
+		switcher.message = ""
+	case "enter", " ":
+		env := vpn.Environments()[switcher.cursor]
+		if m.unconfigured(env) {
+			m.switcher = nil
+			return m.setUpMissing()
 		}
+		if reason := disabledReason(menuEntry{action: menuStart, env: env}, m.readiness()); reason != "" {
+			switcher.message = "🚫 " + reason
+			return nil
+		}
+		m.switcher = nil
+		return m.requestTeardown(startOp(env), env)
 	}
-	
-	content.WriteString("\n🎛️  Main Menu\n")
-	content.WriteString("─────────────────────\n")
-	
-	// Menu
-	for i, choice := range m.choices {
-		cursor := " "
-		if m.cursor == i && m.activePanel == 0 {
-			cursor = ">"
+	return nil
+}
+
+// switcherItems lists the environments for the switcher with their
+// readiness, as the menu would show their Start entries.
+func (m model) switcherItems() []render.MenuItem {
+	readiness := m.readiness()
+	envs := vpn.Environments()
+	items := make([]render.MenuItem, len(envs))
+	for i, env := range envs {
+		item := render.MenuItem{Label: env.DisplayName()}
+		switch reason := disabledReason(menuEntry{action: menuStart, env: env}, readiness); {
+		case m.status != nil && m.status.Connected && m.status.Environment == env:
+			item.Note = "● connected"
+		case m.unconfigured(env):
+			item.Note = "config not installed — Enter to set up"
+			item.NoteWarn = true
+		case reason != "":
+			item.Disabled = true
+			item.DisabledNote = reason
+		default:
+			age, stale := m.configAge(env)
+			item.Note, item.NoteWarn = "ready · "+age, stale
 		}
-		
-		// Disable certain options based on state
-		disabled := false
-		if m.status != nil {
-			if i == 0 && m.status.Connected && m.status.Environment == vpn.Production {
-				disabled = true
-			}
-			if i == 1 && m.status.Connected && m.status.Environment == vpn.NonProduction {
-				disabled = true
-			}
-			if i == 2 && !m.status.Connected {
-				disabled = true
-			}
-		} else if i == 2 {
-			disabled = true
+		if running, _, ok := m.opQueue.Running(); ok && running.Key == startOp(env).Key {
+			item.Loading = true
 		}
-		
-		style := ""
-		if disabled {
-			style = disabledStyle.Render(fmt.Sprintf("%s %s (disabled)", cursor, choice))
-		} else if m.loading && m.cursor == i {
-			style = fmt.Sprintf("%s %s (loading...)", cursor, choice)
-		} else if m.cursor == i && m.activePanel == 0 {
-			style = selectedStyle.Render(fmt.Sprintf("%s %s", cursor, choice))
-		} else {
-			style = fmt.Sprintf("%s %s", cursor, choice)
+		if queued, ok := m.opQueue.Queued(); ok && queued.Key == startOp(env).Key {
+			item.Queued = true
 		}
-		
-		content.WriteString(style + "\n")
+		items[i] = item
 	}
-	
-	// Message area
-	if m.message != "" {
-		content.WriteString("\n" + m.message + "\n")
+	return items
+}
+
+// shutDown lets go of what the app holds before it is ended by sig: the
+// queued Start or Stop is dropped and the running one cancelled (its
+// wg-quick killed), a running probe stopped and a sleep inhibitor lock
+// released. The state and audit files need nothing: every change to them is
+// written out when it is made. wait reports a cancelled operation to wait
+// for, so the app doesn't quit while its wg-quick is still being reaped.
+func (m *model) shutDown(sig os.Signal) (wait bool) {
+	m.exitSignal = sig
+	if _, queued := m.opQueue.Queued(); queued {
+		m.opQueue.Cancel()
 	}
-	
-	panelStyle := mainPanelStyle.Width(width).Height(height)
-	if m.activePanel == 0 {
-		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue for active panel
-	} else {
-		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White for inactive panel
+	if _, _, running := m.opQueue.Running(); running && m.opCancel != nil {
+		m.opQueue.Cancel()
+		m.opCancel()
+		m.opCancel = nil
+		wait = true
 	}
-	
-	return panelStyle.Render(content.String())
+	m.stopOverviewProbe()
+	m.releaseSleep()
+	return wait
 }
 
-
-func (m model) buildInputPanel(width, height int) string {
-	if m.inputModel == nil {
-		return m.buildHelpPanel(width, height)
+// holdSleep takes the inhibitor lock that gives a Stop time to finish
+// before a suspend, when disconnect_on_sleep asks for one.
+func (m *model) holdSleep() {
+	if !m.settings.DisconnectOnSleep || m.sleepRelease != nil {
+		return
 	}
-	
-	// Get the input model view without panel styling first
-	inputView := m.inputModel.View()
-	
-	// Apply minimal panel styling that doesn't constrain content
-	panelStyle := lipgloss.NewStyle().
-		BorderStyle(lipgloss.RoundedBorder()).
-		Padding(1)
-		
-	if m.activePanel == 1 {
-		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue for active panel
-	} else {
-		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White for inactive panel
+	release, err := m.sleepMonitor.Inhibit("disconnect the VPN before sleep")
+	if err != nil {
+		m.addLogEntry(fmt.Sprintf("⚠️ Could not take a sleep inhibitor lock, the VPN may still be up when the system sleeps: %v", err))
+		return
+	}
+	m.sleepRelease = release
+}
+
+func (m *model) releaseSleep() {
+	if m.sleepRelease != nil {
+		m.sleepRelease()
+		m.sleepRelease = nil
 	}
-	
-	return panelStyle.Render(inputView)
 }
 
-func (m model) buildHelpPanel(width, height int) string {
-	helpText := `🔧 Configuration Panel
+// prepareForSleep handles an imminent suspend: a connected tunnel is
+// stopped under disconnect_on_sleep, and otherwise only warned about. The
+// inhibitor lock is released once the Stop is over.
+func (m *model) prepareForSleep() tea.Cmd {
+	m.sleeping = true
+	if m.status == nil || !m.status.Connected {
+		m.releaseSleep()
+		return nil
+	}
+	env := m.status.Environment
+	if !m.settings.DisconnectOnSleep {
+		m.addLogEntry(fmt.Sprintf("💤 System is going to sleep with %s connected; open sessions may hang on resume", env.DisplayName()))
+		return nil
+	}
+	m.addLogEntry(fmt.Sprintf("💤 Stopping %s: system sleep", env.DisplayName()))
+	m.sleepStopped = env
+	cmd := m.requestOp(stopOp)
+	if _, queued := m.opQueue.Queued(); cmd == nil && !queued {
+		m.addLogEntry(fmt.Sprintf("⚠️ Could not stop %s before sleep: %s", env.DisplayName(), m.message))
+		m.sleepStopped = ""
+		m.releaseSleep()
+	}
+	return cmd
+}
 
-File picker for config selection:
-• Use ↑/↓ to navigate files
-• Enter to select/enter directories  
-• h = Home directory
-• Ctrl+H = Toggle hidden files
-• Select .conf files to proceed
+// resumeFromSleep starts the tunnel prepareForSleep stopped again and takes
+// the inhibitor lock for the next suspend.
+func (m *model) resumeFromSleep() tea.Cmd {
+	m.sleeping = false
+	m.releaseSleep()
+	m.holdSleep()
+	cmds := []tea.Cmd{checkVPNStatus(m.vpnSvc)}
+	if env := m.sleepStopped; env != "" {
+		m.sleepStopped = ""
+		m.addLogEntry(fmt.Sprintf("☀️ Starting %s again: resumed from system sleep", env.DisplayName()))
+		cmds = append(cmds, m.requestOp(startOp(env)))
+	}
+	return tea.Batch(cmds...)
+}
 
-Tab to switch between panels
-Esc to close panels`
+// applyDeferredConfig installs the first deferred config update whose tunnel
+// is down, or with now, the first one at all; nil when there is none to
+// install or something else runs.
+func (m *model) applyDeferredConfig(now bool) tea.Cmd {
+	if m.loading || m.readOnly {
+		return nil
+	}
+	if _, _, running := m.opQueue.Running(); running {
+		return nil
+	}
+	for _, env := range vpn.Environments() {
+		pending := m.deferredConfigs[string(env)]
+		if pending == nil || (!now && (m.tunnelUp(env) || pending.Error != "")) {
+			continue
+		}
+		m.loading = true
+		m.message = fmt.Sprintf("Installing the deferred %s config update...", env.DisplayName())
+		m.beginOperation("Apply deferred config update")
+		if m.tunnelUp(env) {
+			m.logStep(fmt.Sprintf("⚠️ %s is connected: the update is used from the next reconnect", env.DisplayName()))
+		}
+		return installDeferred(string(env), pending)
+	}
+	return nil
+}
 
-	panelStyle := inputPanelStyle.Width(width).Height(height).BorderForeground(normalPanelBorder)
-	return panelStyle.Render(helpText)
+// discardDeferredConfigs drops every deferred config update.
+func (m *model) discardDeferredConfigs() {
+	for env := range m.deferredConfigs {
+		if err := config.DiscardStaged(env); err != nil {
+			m.addLogEntry(fmt.Sprintf("⚠️ Could not remove the staged %s config: %v", env, err))
+		}
+		m.addLogEntry(fmt.Sprintf("🗑️ Discarded the deferred %s config update", vpn.Environment(env).DisplayName()))
+	}
+	m.deferredConfigs = nil
+	m.message = "Deferred config updates discarded"
+	if err := state.Update(func(s *state.State) { s.PendingConfigs = nil }); err != nil {
+		m.addLogEntry(fmt.Sprintf("⚠️ Could not save state: %v", err))
+	}
 }
 
-func (m model) buildOutputPanel(width, height int) string {
-	var content strings.Builder
-	
-	// Calculate viewport size based on panel height
-	viewportSize := height - 5 // Account for title, separator and borders
-	if viewportSize < 1 {
-		viewportSize = 1
+func (m *model) checkConflict() tea.Cmd {
+	if m.conflict != nil {
+		return listTunnels(m.vpnSvc)
 	}
-	
-	// Panel title with focus indicator
-	title := "📊 Activity Log"
-	if m.activePanel == 2 {
-		title = "📊 Activity Log (Press ↑/↓ to scroll, Tab to switch panels)"
-		content.WriteString(selectedStyle.Render(title) + "\n")
-	} else {
-		content.WriteString(title + "\n")
+	if m.status == nil || len(m.status.ConflictingInterfaces) == 0 {
+		m.conflictLater = ""
+		return nil
 	}
-	// Use a dynamic separator that fits the panel width
-	separatorWidth := width - 4 // Account for borders
-	if separatorWidth < 1 {
-		separatorWidth = 1
+	names := strings.Join(append([]string{m.status.Interface}, m.status.ConflictingInterfaces...), ", ")
+	if m.loading || names == m.conflictLater {
+		return nil
 	}
-	separator := strings.Repeat("─", separatorWidth)
-	content.WriteString(separator + "\n")
-	
-	if len(m.outputLog) == 0 {
-		content.WriteString("No activity yet. Start by using the VPN controls above.\n")
-	} else {
-		// Calculate viewport
-		endIdx := m.logViewportStart + viewportSize
-		if endIdx > len(m.outputLog) {
-			endIdx = len(m.outputLog)
+	m.addLogEntry(fmt.Sprintf("⚠️ Multiple VPN interfaces are up: %s", names))
+	return m.openConflict()
+}
+
+func (m *model) openConflict() tea.Cmd {
+	m.closeSidePanels()
+	m.switcher = nil
+	m.conflict = &conflictScreen{}
+	return listTunnels(m.vpnSvc)
+}
+
+// updateConflictTunnels refreshes the conflict screen with the tunnels up
+// now. The cursor stays on the tunnel it was on; when no more than one is
+// left, the conflict is over and the screen closes.
+func (m *model) updateConflictTunnels(msg tunnelsMsg) tea.Cmd {
+	screen := m.conflict
+	if screen == nil {
+		return nil
+	}
+	if msg.err != nil {
+		screen.message = fmt.Sprintf("⚠️ Could not list the tunnels: %v", msg.err)
+		return nil
+	}
+	if len(msg.tunnels) < 2 {
+		m.conflict = nil
+		if len(msg.tunnels) == 1 {
+			m.message = fmt.Sprintf("✅ Only %s is up now: nothing to resolve", msg.tunnels[0].Interface)
+		} else {
+			m.message = "✅ No VPN interface is up any more: nothing to resolve"
 		}
-		
-		// Show scroll indicators
-		if m.logViewportStart > 0 {
-			content.WriteString("  ↑ (more entries above)\n")
+		m.addLogEntry(m.message)
+		return checkVPNStatus(m.vpnSvc)
+	}
+
+	selected := ""
+	if screen.cursor < len(screen.tunnels) {
+		selected = screen.tunnels[screen.cursor].Interface
+	}
+	var gone []string
+	for _, before := range screen.tunnels {
+		if !slices.ContainsFunc(msg.tunnels, func(t *vpn.ConnectionStatus) bool { return t.Interface == before.Interface }) {
+			gone = append(gone, before.Interface)
 		}
-		
-		// Show viewport entries
-		for i := m.logViewportStart; i < endIdx; i++ {
-			// Clean up the log entry and ensure it fits
-			logEntry := strings.TrimSpace(m.outputLog[i])
-			maxWidth := width - 8 // Account for borders (4) + bullet (2) + margin (2)
-			if maxWidth < 10 {
-				maxWidth = 10 // Minimum width
-			}
-			if len(logEntry) > maxWidth {
-				logEntry = logEntry[:maxWidth-3] + "..."
+	}
+	if len(gone) > 0 {
+		screen.message = fmt.Sprintf("%s went down on its own", strings.Join(gone, ", "))
+		m.addLogEntry(fmt.Sprintf("🔌 %s", screen.message))
+	}
+	first := screen.tunnels == nil
+	screen.tunnels = msg.tunnels
+	switch {
+	case first:
+		screen.cursor = 0
+	case selected == "":
+		screen.cursor = len(msg.tunnels) // Stop all stays selected
+	default:
+		screen.cursor = 0
+		for i, tunnel := range msg.tunnels {
+			if tunnel.Interface == selected {
+				screen.cursor = i
 			}
-			content.WriteString(fmt.Sprintf("• %s\n", logEntry))
 		}
-		
-		// Show bottom scroll indicator
-		if endIdx < len(m.outputLog) {
-			content.WriteString("  ↓ (more entries below)\n")
+	}
+	return nil
+}
+
+// updateConflict handles keys while the conflict screen is open. The choice
+// runs through the operation queue; Esc leaves the tunnels up and the
+// screen closed until the set of them changes (i opens it again).
+func (m *model) updateConflict(msg tea.KeyMsg) tea.Cmd {
+	screen := m.conflict
+	switch msg.String() {
+	case "ctrl+c":
+		return tea.Quit
+	case "esc", "q":
+		m.conflict = nil
+		if m.status != nil {
+			m.conflictLater = strings.Join(append([]string{m.status.Interface}, m.status.ConflictingInterfaces...), ", ")
 		}
-		
-		// Show position indicator
-		if len(m.outputLog) > viewportSize {
-			indicator := fmt.Sprintf("Showing %d-%d of %d entries", 
-				m.logViewportStart+1, endIdx, len(m.outputLog))
-			maxWidth := width - 4 // Account for borders
-			if len(indicator) > maxWidth {
-				indicator = fmt.Sprintf("%d-%d/%d", 
-					m.logViewportStart+1, endIdx, len(m.outputLog))
+		m.message = "⚠️ Multiple VPN interfaces left up — press i to resolve"
+	case "up", "k":
+		if screen.cursor > 0 {
+			screen.cursor--
+		}
+	case "down", "j":
+		if screen.cursor < len(screen.tunnels) {
+			screen.cursor++
+		}
+	case "enter":
+		if screen.tunnels == nil {
+			return nil
+		}
+		m.conflict = nil
+		m.conflictLater = ""
+		if screen.cursor < len(screen.tunnels) {
+			return m.requestOp(keepOp(screen.tunnels[screen.cursor].Interface))
+		}
+		return m.requestOp(stopOp)
+	}
+	return nil
+}
+
+// tryAutoConnect runs the pending auto-connect once both startup checks
+// have reported back.
+func (m *model) tryAutoConnect() tea.Cmd {
+	if m.autoConnectDone || !m.statusChecked || !m.setupChecked || !m.locationChecked {
+		return nil
+	}
+	m.autoConnectDone = true // Only ever attempted once, after the initial checks
+	env := m.autoConnect
+	if m.location != nil {
+		switch m.location.VPN {
+		case "skip":
+			if env != "" {
+				m.addLogEntry(fmt.Sprintf("🏢 %s network detected — VPN not needed, auto-connect skipped", m.location.Name))
 			}
-			content.WriteString(indicator)
+			return nil
+		case "connect":
+			env = m.locationProfile()
 		}
 	}
-	
-	// Apply focus styling to panel border
-	panelStyle := outputPanelStyle.Width(width).Height(height)
-	if m.activePanel == 2 {
-		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue when focused
-	} else {
-		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White when not focused
+	if env == "" {
+		m.offerReconnect()
+		return nil
 	}
-	
-	return panelStyle.Render(content.String())
+	if m.readOnly {
+		m.addLogEntry("Auto-connect skipped: setup is incomplete (read-only mode)")
+		return nil
+	}
+	if !m.platform.CanManageTunnel() {
+		m.addLogEntry("Auto-connect skipped: " + m.platform.Banner())
+		return nil
+	}
+	return m.maybeAutoConnect(env, m.statusErr)
 }
 
-func (m model) buildControlsPanel(width, height int) string {
-	var content strings.Builder
-	
-	content.WriteString("🎮 Controls\n")
-	content.WriteString("──────────────────────\n")
-	
-	// Show controls based on active panel
-	switch m.activePanel {
-	case 0: // Main+Status panel
-		content.WriteString("Menu + Status:\n")
-		content.WriteString("• ↑/↓ - Navigate menu\n")
-		content.WriteString("• Enter - Select option\n")
-		content.WriteString("• Tab - Switch panels\n")
-		content.WriteString("• View VPN status\n")
-	case 1: // Help/Input panel
-		if m.showInputPanel {
-			content.WriteString("File Browser:\n")
-			content.WriteString("• ↑/↓ - Navigate files\n")
-			content.WriteString("• Enter - Select/Enter dir\n")
-			content.WriteString("• h - Home directory\n")
-			content.WriteString("• Ctrl+H - Toggle hidden\n")
-			content.WriteString("• Esc - Cancel\n")
-		} else {
-			content.WriteString("Help Panel:\n")
-			content.WriteString("• Tab - Switch panels\n")
-			content.WriteString("• Information only\n")
-		}
-	case 2: // Activity log
-		content.WriteString("Activity Log:\n")
-		content.WriteString("• ↑/↓ - Scroll log\n")
-		content.WriteString("• Tab - Switch panels\n")
-		content.WriteString("• View operation history\n")
-	case 3: // Controls panel
-		content.WriteString("Controls Panel:\n")
-		content.WriteString("• View only\n")
-		content.WriteString("• Tab - Switch panels\n")
-		content.WriteString("• Context help\n")
+// offerReconnect puts the reconnect prompt above the menu when the last
+// session ended without being stopped, e.g. with a reboot, and its profile
+// can be started now. Auto-connect decides by itself instead.
+func (m *model) offerReconnect() {
+	if m.settings.AutoConnect != "" || m.readOnly || (m.status != nil && m.status.Connected) {
+		return
 	}
-	
-	content.WriteString("\nGlobal:\n")
-	content.WriteString("• q/Ctrl+C - Quit\n")
-	content.WriteString("• Tab - Cycle panels\n")
-	
-	panelStyle := controlsPanelStyle.Width(width).Height(height)
-	if m.activePanel == 3 {
-		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue when focused
-	} else {
-		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White when not focused
+	st, err := state.Load()
+	if err != nil || st.LastSession == nil || st.LastSession.Stopped {
+		return
+	}
+	env, err := vpn.ParseEnvironment(st.LastSession.Environment)
+	if err != nil || disabledReason(menuEntry{action: menuStart, env: env}, m.readiness()) != "" {
+		return
+	}
+	m.reconnect = st.LastSession
+}
+
+// locationProfile is the profile a "connect" location starts: its own, or
+// else auto_connect. "" when neither names a valid one.
+func (m *model) locationProfile() vpn.Environment {
+	name := m.location.Profile
+	if name == "" {
+		name = m.settings.AutoConnect
+	}
+	env, err := vpn.ParseEnvironment(name)
+	if err != nil {
+		m.addLogEntry(fmt.Sprintf("⚠️ Location %s: no profile to connect (%v)", m.location.Name, err))
+		return ""
+	}
+	return env
+}
+
+// applyLocation records a detected network location. After launch, arriving
+// at a "connect" location starts its profile like the launch auto-connect.
+func (m *model) applyLocation(msg locationMsg) tea.Cmd {
+	previous := m.location
+	m.location = msg.location
+	if m.routeKey == "" {
+		m.routeKey = msg.facts.Interface + " " + msg.facts.Address
+	}
+	if !m.locationChecked {
+		m.locationChecked = true
+		if m.location != nil {
+			m.addLogEntry(fmt.Sprintf("📍 Network location: %s", m.location.Name))
+		}
+		return m.tryAutoConnect()
+	}
+
+	switch {
+	case m.location == nil && previous == nil:
+		return nil
+	case m.location == nil:
+		m.addLogEntry(fmt.Sprintf("📍 Left the %s network", previous.Name))
+		return nil
+	case previous != nil && previous.Name == m.location.Name:
+		return nil
 	}
-	return panelStyle.Render(content.String())
+	m.addLogEntry(fmt.Sprintf("📍 Network location: %s", m.location.Name))
+	if m.location.VPN != "connect" || !m.autoConnectDone || m.loading || m.readOnly || m.confirm != nil {
+		return nil
+	}
+	env := m.locationProfile()
+	if env == "" {
+		return nil
+	}
+	return m.maybeAutoConnect(env, nil)
 }
 
-func formatBytes(bytes uint64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// maybeAutoConnect starts env after the initial status check unless a tunnel
+// is already up. Profiles marked confirm = true ask first.
+func (m *model) maybeAutoConnect(env vpn.Environment, statusErr error) tea.Cmd {
+	if statusErr != nil {
+		m.addLogEntry(fmt.Sprintf("⚠️ Auto-connect skipped: could not check status: %v", statusErr))
+		return nil
+	}
+	if m.status != nil && m.status.Connected {
+		if m.status.Environment != env {
+			m.addLogEntry(fmt.Sprintf("Auto-connect skipped: already connected to %s", m.status.Environment.DisplayName()))
+		}
+		return nil
 	}
-	div, exp := uint64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+
+	m.addLogEntry(fmt.Sprintf("🔌 Auto-connecting to %s VPN...", env.DisplayName()))
+	if profile := m.settings.Profile(string(env)); profile != nil && profile.Confirm {
+		op := startOp(env)
+		question := fmt.Sprintf("Auto-connect to %s VPN?", env.DisplayName())
+		if change := m.dnsPreview(env); change != "" {
+			question = fmt.Sprintf("%s %s.", question, change)
+		}
+		m.confirm = &confirmPrompt{
+			question: question,
+			op:       &op,
+		}
+		return nil
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return m.requestOp(startOp(env))
+}
+
+// dnsPreview describes how starting env changes the current DNS servers,
+// for the question asked before connecting.
+func (m *model) dnsPreview(env vpn.Environment) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	before, _ := m.vpnSvc.SnapshotDNS(ctx)
+	after, _ := m.vpnSvc.TunnelDNS(env)
+	return dnsChange(before, after)
+}
+
+// addLogEntry adds a new entry to the activity log and adjusts viewport to show latest entries
+// addLogEntry appends to the activity log; the view only moves to the new
+// entry while it is following.
+func (m *model) addLogEntry(entry string) {
+	m.activityLog.Append(entry)
+}
+
+// beginOperation opens the activity log group that logStep adds the running
+// operation's entries to, so background entries don't interleave with them.
+func (m *model) beginOperation(title string) {
+	m.opGroup = m.activityLog.BeginGroup(title)
+}
+
+// logStep logs an entry of the running operation, or a plain entry when
+// none is running.
+func (m *model) logStep(entry string) {
+	m.activityLog.AppendTo(m.opGroup, entry)
+}
+
+func (m *model) endOperation(success bool) {
+	m.activityLog.EndGroup(m.opGroup, success)
+	m.opGroup = 0
 }
 
 func main() {
+	// vpn reads and writes the installed configs through config
+	vpn.SetConfigs(config.Store{})
+
+	// Prompt segments run on every command line: answer before anything
+	// else is loaded (see the latency budget in wgvpn.PromptStatus)
+	if len(os.Args) > 1 && os.Args[1] == "status" && promptRequested(os.Args[2:]) {
+		os.Exit(handleStatusMode(os.Args[2:]))
+	}
+
+	// An organization config replaces the built-in gateways before anything
+	// installs templates or recognizes configs
+	applyOrgConfig()
+
+	// A pending provisioning file is applied before the settings are read,
+	// so the first launch on a provisioned machine needs no setup
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		if err := applyProvisioning(settings.ProvisionFile); err != nil {
+			fmt.Printf("⚠️ Provisioning failed: %v\n", err)
+		}
+	}
+
+	// Settings are needed by the subcommands too (e.g. gateway hostnames
+	// for recognizing configs that use DNS endpoints)
+	appSettings, err := settings.Load()
+	if err != nil {
+		fmt.Printf("Warning: %v (using defaults)\n", err)
+	}
+	config.SetStripHooks(appSettings.StripHookScripts)
+	config.SetBackupsKept(appSettings.ConfigBackups)
+	ui.SetIgnorePatterns(appSettings.IgnorePatterns)
+	applyGlyphs(appSettings.Glyphs)
+	// Configs go where wg-quick looks for them (/usr/local/etc/wireguard on
+	// the BSDs, Homebrew's prefix on macOS), unless the settings say otherwise
+	if appSettings.WireGuardDir != "" {
+		core.SetConfigDir(appSettings.WireGuardDir)
+	} else {
+		core.SetConfigDir(vpn.DetectConfigDir())
+	}
+	registerProfiles(appSettings)
+
 	// Handle command-line arguments
 	if len(os.Args) > 1 {
 		switch os.Args[1] {
@@ -769,12 +3529,55 @@ func main() {
 			fmt.Println("You can now run 'tui-wireguard-vpn' from anywhere.")
 			return
 		case "setup":
-			// Handle setup mode for processing configs with sudo
-			if err := handleSetupMode(); err != nil {
-				fmt.Printf("Setup failed: %v\n", err)
+			os.Exit(handleSetupMode(os.Args[2:]))
+		case "doctor":
+			checks := doctor.Run()
+			doctor.Print(os.Stdout, checks)
+			if doctor.Failed(checks) {
+				os.Exit(1)
+			}
+			return
+		case "backup":
+			if err := handleBackupMode(os.Args[2:], appSettings); err != nil {
+				fmt.Printf("Backup failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "logs":
+			if err := handleLogsMode(os.Args[2:]); err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "provision":
+			if len(os.Args) > 2 && os.Args[2] == "apply" {
+				os.Exit(handleProvisionApply(os.Args[3:]))
+			}
+			if err := handleProvisionMode(os.Args[2:]); err != nil {
+				fmt.Printf("Provisioning failed: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		case "verify":
+			os.Exit(handleVerifyMode(os.Args[2:]))
+		case "preflight":
+			os.Exit(handlePreflightMode(os.Args[2:], appSettings))
+		case "rollback":
+			os.Exit(handleRollbackMode(os.Args[2:]))
+		case "status":
+			os.Exit(handleStatusMode(os.Args[2:]))
+		case "up":
+			os.Exit(handleUpMode(os.Args[2:], appSettings))
+		case "down":
+			os.Exit(handleDownMode(os.Args[2:]))
+		case "agent":
+			if err := handleAgentMode(os.Args[2:], appSettings); err != nil {
+				fmt.Printf("Agent failed: %v\n", err)
 				os.Exit(1)
 			}
 			return
+		case "generate-org-config":
+			os.Exit(handleGenerateOrgConfig(os.Args[2:]))
 		case "update-config":
 			// Handle single config update mode
 			if len(os.Args) < 3 {
@@ -789,50 +3592,216 @@ func main() {
 		}
 	}
 
-	// Check if we need initial setup
-	setupStatus, err := config.CheckSetupStatus()
-	if err != nil {
-		fmt.Printf("Error checking setup status: %v\n", err)
-		os.Exit(1)
+	// Flags for the interactive TUI itself
+	flags := flag.NewFlagSet("tui-wireguard-vpn", flag.ExitOnError)
+	connectFlag := flags.String("connect", "", "start the named profile (prod or nonprod) on launch if not connected")
+	flags.Parse(os.Args[1:])
+
+	// The dashboard starts right away; setup is checked in the background and
+	// only if it turns out to be needed does the app switch to the wizard
+	autoConnect := appSettings.AutoConnect
+	if *connectFlag != "" {
+		autoConnect = *connectFlag
 	}
+	setupMode := false
+	var readOnlyStatus *config.SetupStatus // set when the wizard was skipped
+	partialSetup := false                  // the wizard only set up one environment
+	for {
+		mainModel := initialModel(appSettings)
+		if setupMode {
+			// The wizard just ran (or was dismissed); don't loop back into it,
+			// and don't auto-connect right after it
+			mainModel.setupChecked = !partialSetup
+			mainModel.autoConnectDone = true
+			mainModel.readOnly = readOnlyStatus != nil
+			mainModel.setupIncomplete = readOnlyStatus
+		} else if autoConnect != "" {
+			// Auto-connect never runs right after the setup wizard
+			env, err := vpn.ParseEnvironment(autoConnect)
+			if err != nil {
+				fmt.Printf("Auto-connect disabled: %v\n", err)
+			} else {
+				mainModel.autoConnect = env
+			}
+		}
 
-	// If setup is needed, start with setup screen
-	if setupStatus.NeedsSetup {
-		setupModel := ui.NewSetupModel(setupStatus)
-		p := tea.NewProgram(setupModel)
+		// Normal operation - start main VPN management UI. Signals go to
+		// the model, so it can let go of what it holds before quitting
+		p := tea.NewProgram(mainModel, tea.WithAltScreen(), tea.WithoutSignalHandler())
+		stopSignals := forwardSignals(p)
 		finalModel, err := p.Run()
+		stopSignals()
 		if err != nil {
-			fmt.Printf("Error running setup: %v", err)
+			fmt.Printf("Error running program: %v", err)
 			os.Exit(1)
 		}
-		
-		// Check if user completed config input and we need to run setup
-		if setupModelFinal, ok := finalModel.(*ui.SetupModel); ok {
-			prodPath, nonprodPath := setupModelFinal.GetConfigPaths()
-			if prodPath != "" || nonprodPath != "" {
-				// Exit TUI and run setup, then continue to main app
-				fmt.Println("\nStarting VPN configuration setup...")
-				fmt.Println("This process requires sudo privileges to write to /etc/wireguard/")
-				fmt.Println("")
-				
-				if err := config.RunSetupDirectly(prodPath, nonprodPath); err != nil {
-					fmt.Printf("Setup failed: %v\n", err)
-					os.Exit(1)
-				}
-				
-				fmt.Println("\n✅ Setup completed successfully!")
-				fmt.Println("Starting main VPN management interface...")
-				fmt.Println("")
+		final, ok := finalModel.(model)
+		if ok && final.historyState != "" {
+			// What follows is unknown to the connection history
+			stats.Record(stats.Event{Time: time.Now(), State: stats.StateExit})
+		}
+		if ok && final.exitSignal != nil {
+			if running, _, busy := final.opQueue.Running(); busy {
+				fmt.Fprintf(os.Stderr, "⚠️ %s was interrupted by %s; check the VPN status before going on\n", running.Name, final.exitSignal)
+			}
+			os.Exit(signalExitCode(final.exitSignal))
+		}
+		if !ok || final.setupNeeded == nil {
+			return
+		}
+
+		// A partial setup only adds the missing config: skipping it just goes
+		// back to the dashboard, which checks setup again
+		partialSetup = final.setupNeeded.Partial()
+		if runSetupWizard(final.setupNeeded) && !partialSetup {
+			if err := setSetupSkipped(true); err != nil {
+				fmt.Printf("Warning: could not remember the skipped setup: %v\n", err)
 			}
+			readOnlyStatus = final.setupNeeded
+		} else {
+			readOnlyStatus = nil
+		}
+		setupMode = true
+	}
+}
+
+// forwardSignals hands SIGINT, SIGTERM and SIGHUP to p's model as a
+// signalMsg; a second one, while the first is being handled, kills p. The
+// returned function stops the forwarding.
+func forwardSignals(p *tea.Program) func() {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			p.Send(signalMsg{signal: sig})
+		case <-done:
+			return
 		}
+		select {
+		case <-signals:
+			p.Kill()
+		case <-done:
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// signalExitCode is the shell's exit status for a process ended by sig:
+// 128 plus its number (130 for SIGINT, 143 for SIGTERM, 129 for SIGHUP).
+func signalExitCode(sig os.Signal) int {
+	if number, ok := sig.(syscall.Signal); ok {
+		return 128 + int(number)
 	}
+	return 1
+}
 
-	// Normal operation - start main VPN management UI
-	p := tea.NewProgram(initialModel(), tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
-		fmt.Printf("Error running program: %v", err)
+// runSetupWizard shows the setup screen, which runs the setup of the config
+// files picked. A setup left failed exits the program. It reports whether
+// the user skipped setup to continue read-only.
+func runSetupWizard(setupStatus *config.SetupStatus) bool {
+	setupModel := ui.NewSetupModel(setupStatus)
+	p := tea.NewProgram(setupModel)
+	finalModel, err := p.Run()
+	if err != nil {
+		fmt.Printf("Error running setup: %v", err)
 		os.Exit(1)
 	}
+	
+	if setupModelFinal, ok := finalModel.(*ui.SetupModel); ok {
+		if setupModelFinal.Skipped() {
+			return true
+		}
+		if err := setupModelFinal.Err(); err != nil {
+			fmt.Printf("Setup failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return false
+}
+
+// printStep prints a step of an operation run outside the TUI as it starts
+// or finishes.
+func printStep(event ops.StepEvent) {
+	switch event.Status {
+	case ops.StepRunning:
+		fmt.Printf("%s %s...\n", render.StepGlyph(event.Status), event.Step)
+	case ops.StepFailed:
+		fmt.Printf("%s %s: %v\n", render.StepGlyph(event.Status), event.Step, event.Err)
+	default:
+		fmt.Printf("%s %s\n", render.StepGlyph(event.Status), event.Step)
+	}
+}
+
+// registerProfiles makes the profiles besides prod and nonprod known, from
+// the [profiles.<name>] sections of the settings file and the
+// julo-<name>.conf files installed, and tells config environment detection
+// every profile's gateway endpoint and hostnames.
+func registerProfiles(appSettings *settings.Settings) {
+	var names []string
+	if appSettings != nil {
+		names = slices.Collect(maps.Keys(appSettings.Profiles))
+	}
+	names = append(names, core.InstalledProfiles()...)
+	slices.Sort(names)
+	for _, name := range slices.Compact(names) {
+		if _, err := vpn.AddEnvironment(name); err != nil {
+			fmt.Printf("⚠️ Profile ignored: %v\n", err)
+		}
+	}
+
+	for _, env := range vpn.Environments() {
+		profile := appSettings.Profile(string(env))
+		if profile != nil && profile.Endpoint != "" {
+			config.RegisterEndpoint(string(env), profile.Endpoint)
+		}
+		for _, host := range profile.GatewayHostnames() {
+			config.RegisterEndpointHost(string(env), host)
+		}
+		if profile != nil && profile.BindInterface != "" {
+			vpn.PinEgress(env, profile.BindInterface)
+		}
+	}
+}
+
+// applyOrgConfig puts the gateways of an organization config, when there is
+// one, in place of the built-in JULO ones. A broken file is reported and the
+// built-in gateways stay.
+func applyOrgConfig() {
+	org, err := settings.LoadOrg()
+	if err != nil {
+		fmt.Printf("⚠️ %v (using the built-in gateways)\n", err)
+		return
+	}
+	if org == nil {
+		return
+	}
+	var gateways []config.Gateway
+	for _, orgEnv := range org.Environments {
+		env, err := vpn.AddEnvironment(orgEnv.Key)
+		if err != nil {
+			fmt.Printf("⚠️ Environment ignored: %v\n", err)
+			continue
+		}
+		if orgEnv.Name != "" {
+			vpn.SetDisplayName(env, orgEnv.Name)
+		}
+		gateways = append(gateways, config.Gateway{
+			Environment:         orgEnv.Key,
+			Label:               strings.ToLower(env.DisplayName()),
+			Endpoint:            orgEnv.Endpoint,
+			PublicKey:           orgEnv.PublicKey,
+			DNS:                 orgEnv.DNS,
+			AllowedIPs:          orgEnv.AllowedIPs,
+			MTU:                 orgEnv.MTU,
+			PersistentKeepalive: orgEnv.PersistentKeepalive,
+		})
+	}
+	config.SetGateways(gateways)
 }
 
 func installToSystem() error {
@@ -868,59 +3837,3 @@ func installToSystem() error {
 
 	return nil
 }
-
-func handleSetupMode() error {
-	// This handles the sudo setup process when called with "setup" argument
-	// Parse additional arguments for config file paths
-	var prodConfigPath, nonprodConfigPath string
-	
-	fmt.Printf("Setup mode: Processing arguments: %v\n", os.Args)
-	
-	for i := 2; i < len(os.Args); i++ {
-		switch os.Args[i] {
-		case "--prod":
-			if i+1 < len(os.Args) {
-				prodConfigPath = os.Args[i+1]
-				fmt.Printf("Production config: %s\n", prodConfigPath)
-				i++
-			}
-		case "--nonprod":
-			if i+1 < len(os.Args) {
-				nonprodConfigPath = os.Args[i+1]
-				fmt.Printf("Non-production config: %s\n", nonprodConfigPath)
-				i++
-			}
-		}
-	}
-
-	// Validate config files exist
-	if prodConfigPath != "" {
-		if _, err := os.Stat(prodConfigPath); os.IsNotExist(err) {
-			return fmt.Errorf("production config file not found: %s", prodConfigPath)
-		}
-	}
-	
-	if nonprodConfigPath != "" {
-		if _, err := os.Stat(nonprodConfigPath); os.IsNotExist(err) {
-			return fmt.Errorf("non-production config file not found: %s", nonprodConfigPath)
-		}
-	}
-
-	// Run the setup process
-	processor := config.NewConfigProcessor()
-	return processor.RunSetup(prodConfigPath, nonprodConfigPath)
-}
-
-func handleUpdateConfigMode(userConfigPath string) error {
-	// This handles the sudo config update process when called with "update-config" argument
-	fmt.Printf("Update config mode: Processing config file: %s\n", userConfigPath)
-	
-	// Validate config file exists
-	if _, err := os.Stat(userConfigPath); os.IsNotExist(err) {
-		return fmt.Errorf("config file not found: %s", userConfigPath)
-	}
-
-	// Run the config update process (same as original j1-vpn-update-config)
-	processor := config.NewConfigProcessor()
-	return processor.ProcessUserConfig(userConfigPath)
-}
\ No newline at end of file
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+
+	"tui-wireguard-vpn/internal/platform"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// menuAction is what a main menu entry does.
+type menuAction int
+
+const (
+	menuStart menuAction = iota // start the entry's profile
+	menuStop
+	menuRefresh
+	menuUpdate
+	menuRollback   // restore the newest config backup
+	menuView       // view the entry's profile's config
+	menuViewActive // view the connected profile's config
+	menuGenerate
+	menuSync
+	menuBackUp
+	menuOverview
+	menuPreflight
+	menuHistory // today's connection timeline
+	menuQuit
+)
+
+// menuEntry is one entry of the main menu.
+type menuEntry struct {
+	action menuAction
+	env    vpn.Environment // for menuStart and menuView
+}
+
+// mainMenu lists the main menu entries, with a Start and a View entry for
+// every known profile.
+func mainMenu() []menuEntry {
+	var entries []menuEntry
+	for _, env := range vpn.Environments() {
+		entries = append(entries, menuEntry{action: menuStart, env: env})
+	}
+	entries = append(entries, menuEntry{action: menuStop}, menuEntry{action: menuRefresh}, menuEntry{action: menuUpdate}, menuEntry{action: menuRollback})
+	entries = append(entries, menuEntry{action: menuViewActive})
+	for _, env := range vpn.Environments() {
+		entries = append(entries, menuEntry{action: menuView, env: env})
+	}
+	for _, action := range []menuAction{menuGenerate, menuSync, menuBackUp, menuOverview, menuPreflight, menuHistory, menuQuit} {
+		entries = append(entries, menuEntry{action: action})
+	}
+	return entries
+}
+
+func (e menuEntry) label() string {
+	switch e.action {
+	case menuStart:
+		return fmt.Sprintf("Start %s VPN", e.env.DisplayName())
+	case menuStop:
+		return "Stop VPN"
+	case menuRefresh:
+		return "Refresh Status"
+	case menuUpdate:
+		return "Update VPN Configuration"
+	case menuRollback:
+		return "Rollback Last Config Update"
+	case menuView:
+		return fmt.Sprintf("View %s Config", e.env.DisplayName())
+	case menuViewActive:
+		return "View Active Config"
+	case menuGenerate:
+		return "Generate New Client Config"
+	case menuSync:
+		return "Sync from Server"
+	case menuBackUp:
+		return "Back Up Configs"
+	case menuOverview:
+		return "Network Overview"
+	case menuPreflight:
+		return "Preflight Check"
+	case menuHistory:
+		return "Connection History"
+	}
+	return "Quit"
+}
+
+// mutating reports whether the entry changes tunnels or installed configs,
+// which read-only mode doesn't allow.
+func (e menuEntry) mutating() bool {
+	switch e.action {
+	case menuStart, menuStop, menuUpdate, menuRollback, menuGenerate, menuSync:
+		return true
+	}
+	return false
+}
+
+// menuReadiness is the state that decides which menu entries are enabled.
+type menuReadiness struct {
+	status       *vpn.ConnectionStatus // nil until a status check succeeds
+	readOnly     bool
+	unconfigured map[vpn.Environment]bool // configs a partial setup is missing
+	canSync      bool                     // remote sources are configured
+	canBackUp    bool                     // a backup directory is configured
+	platform     platform.Context
+}
+
+func (m model) readiness() menuReadiness {
+	unconfigured := map[vpn.Environment]bool{}
+	for _, env := range vpn.Environments() {
+		unconfigured[env] = m.unconfigured(env)
+	}
+	return menuReadiness{
+		status:       m.status,
+		readOnly:     m.readOnly,
+		unconfigured: unconfigured,
+		canSync:      len(remoteSources(m.settings)) > 0,
+		canBackUp:    m.settings.Backup.Dir != "",
+		platform:     m.platform,
+	}
+}
+
+// disabledReason says why menu entry e makes no sense in state r, or
+// returns "" when it is enabled.
+func disabledReason(e menuEntry, r menuReadiness) string {
+	if r.readOnly && e.mutating() {
+		return "read-only: setup is incomplete (press s to run setup)"
+	}
+	if e.action == menuStart && r.unconfigured[e.env] {
+		return "config not installed — press Enter to set up"
+	}
+	switch e.action {
+	case menuStart, menuStop:
+		if reason := r.platform.DisabledReason(); reason != "" {
+			return reason
+		}
+	}
+	switch e.action {
+	case menuSync:
+		if !r.canSync {
+			return "no remote sources in the settings file"
+		}
+	case menuBackUp:
+		if !r.canBackUp {
+			return "no backup directory in the settings file"
+		}
+	case menuStart:
+		if r.status != nil && r.status.Connected && r.status.Environment == e.env {
+			return "already connected to " + e.env.DisplayName()
+		}
+	case menuViewActive:
+		if r.status == nil || !r.status.Connected {
+			return "no active connection"
+		}
+	case menuStop:
+		// What runs on Windows doesn't show in this namespace's status
+		if (r.status == nil || !r.status.Connected) && r.platform.CanManageTunnel() {
+			return "no active connection to stop"
+		}
+	}
+	return ""
+}
+
+// menuDisabled reports whether menu entry i makes no sense in the current
+// connection state.
+func (m model) menuDisabled(i int) bool {
+	return disabledReason(m.menu[i], m.readiness()) != ""
+}
+
+// explainDisabled flashes why the disabled menu entry i does nothing.
+func (m *model) explainDisabled(i int) {
+	m.message = fmt.Sprintf("🚫 %s: %s", m.menu[i].label(), disabledReason(m.menu[i], m.readiness()))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"tui-wireguard-vpn/internal/platform"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+func TestDisabledReason(t *testing.T) {
+	prodUp := &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production, Interface: "julo-prod"}
+	down := &vpn.ConnectionStatus{}
+	start := func(env vpn.Environment) menuEntry { return menuEntry{action: menuStart, env: env} }
+	stop := menuEntry{action: menuStop}
+	wsl := platform.Context{Kind: platform.WSL2}
+	wslWithWindows := platform.Context{Kind: platform.WSL2, WindowsWireGuard: "/mnt/c/Program Files/WireGuard/wireguard.exe"}
+	container := platform.Context{Kind: platform.Container, Runtime: "docker"}
+
+	tests := []struct {
+		name  string
+		entry menuEntry
+		r     menuReadiness
+		want  string
+	}{
+		{"start", start(vpn.Production), menuReadiness{status: down}, ""},
+		{"start the connected profile", start(vpn.Production), menuReadiness{status: prodUp}, "already connected to Production"},
+		{"start another profile while connected", start(vpn.NonProduction), menuReadiness{status: prodUp}, ""},
+		{"start before the first status", start(vpn.Production), menuReadiness{}, ""},
+		{"start unconfigured", start(vpn.Production), menuReadiness{status: down, unconfigured: map[vpn.Environment]bool{vpn.Production: true}}, "config not installed — press Enter to set up"},
+		{"start read-only", start(vpn.Production), menuReadiness{status: down, readOnly: true}, "read-only: setup is incomplete (press s to run setup)"},
+		{"start inside WSL", start(vpn.Production), menuReadiness{status: down, platform: wsl}, "inside WSL — manage the tunnel from Windows"},
+		{"start in a container", start(vpn.Production), menuReadiness{status: down, platform: container}, "inside a container without NET_ADMIN"},
+		{"start handed to Windows", start(vpn.Production), menuReadiness{status: down, platform: wslWithWindows}, ""},
+		{"stop", stop, menuReadiness{status: prodUp}, ""},
+		{"stop with nothing up", stop, menuReadiness{status: down}, "no active connection to stop"},
+		{"stop before the first status", stop, menuReadiness{}, "no active connection to stop"},
+		// What Windows runs doesn't show in this namespace's status
+		{"stop handed to Windows", stop, menuReadiness{status: down, platform: wslWithWindows}, ""},
+		{"view active", menuEntry{action: menuViewActive}, menuReadiness{status: prodUp}, ""},
+		{"view active with nothing up", menuEntry{action: menuViewActive}, menuReadiness{status: down}, "no active connection"},
+		{"view active read-only", menuEntry{action: menuViewActive}, menuReadiness{status: prodUp, readOnly: true}, ""},
+		{"sync", menuEntry{action: menuSync}, menuReadiness{canSync: true}, ""},
+		{"sync without sources", menuEntry{action: menuSync}, menuReadiness{}, "no remote sources in the settings file"},
+		{"back up", menuEntry{action: menuBackUp}, menuReadiness{canBackUp: true}, ""},
+		{"back up without a directory", menuEntry{action: menuBackUp}, menuReadiness{}, "no backup directory in the settings file"},
+		{"update read-only", menuEntry{action: menuUpdate}, menuReadiness{readOnly: true}, "read-only: setup is incomplete (press s to run setup)"},
+		{"refresh read-only", menuEntry{action: menuRefresh}, menuReadiness{readOnly: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := disabledReason(tt.entry, tt.r); got != tt.want {
+				t.Errorf("disabledReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,1308 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+
+	"tui-wireguard-vpn/internal/audit"
+	"tui-wireguard-vpn/internal/backup"
+	"tui-wireguard-vpn/internal/config"
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/doctor"
+	"tui-wireguard-vpn/internal/health"
+	"tui-wireguard-vpn/internal/mfa"
+	"tui-wireguard-vpn/internal/ops"
+	"tui-wireguard-vpn/internal/paths"
+	"tui-wireguard-vpn/internal/probe"
+	"tui-wireguard-vpn/internal/provision"
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/sleep"
+	"tui-wireguard-vpn/internal/state"
+	"tui-wireguard-vpn/internal/ui/render"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// handleGenerateOrgConfig writes a commented organization config describing
+// the gateways in use, to the user's org.toml unless -o says otherwise ("-"
+// for stdout). An existing file is only replaced with --force.
+func handleGenerateOrgConfig(args []string) int {
+	flags := flag.NewFlagSet("generate-org-config", flag.ContinueOnError)
+	output := flags.String("o", "", "where to write the sample (default: the user's org.toml, - for stdout)")
+	force := flags.Bool("force", false, "replace an existing file")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() > 0 {
+		fmt.Printf("Usage: %s generate-org-config [-o FILE|-] [--force]\n", os.Args[0])
+		return 64
+	}
+
+	sample := config.SampleOrgConfig()
+	if *output == "-" {
+		fmt.Print(sample)
+		return 0
+	}
+	path := *output
+	if path == "" {
+		path = settings.OrgPaths()[0]
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return 1
+		}
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		fmt.Printf("❌ %s already exists: run with --force to replace it\n", path)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(sample), 0644); err != nil {
+		fmt.Printf("❌ Failed to write %s: %v\n", path, err)
+		return 1
+	}
+	fmt.Printf("📝 Wrote a sample organization config to %s\n", path)
+	fmt.Println("Edit the gateways, then place it at " + settings.SystemOrgFile + " for every user of the machine")
+	return 0
+}
+
+// Exit codes of "setup", after sysexits.h so scripts can tell the failures
+// apart.
+const (
+	setupExitUsage      = 64 // EX_USAGE: bad flags
+	setupExitEndpoint   = 65 // EX_DATAERR: a config isn't for its environment's gateway
+	setupExitMissing    = 66 // EX_NOINPUT: a config file doesn't exist
+	setupExitExists     = 73 // EX_CANTCREAT: a config is installed already, or a template has local changes, without --force
+	setupExitPermission = 77 // EX_NOPERM: not allowed to write the WireGuard directory
+)
+
+// setupExitCodes documents the exit codes in "setup --help".
+var setupExitCodes = []struct {
+	code    int
+	meaning string
+}{
+	{0, "setup completed"},
+	{1, "setup failed otherwise (e.g. a template config, a failed merge)"},
+	{setupExitUsage, "invalid flags"},
+	{setupExitEndpoint, "a config's Endpoint is unknown or belongs to the other environment"},
+	{setupExitMissing, "a config file was not found"},
+	{setupExitExists, "a config is already installed, or a template has local changes (pass --force to overwrite them)"},
+	{setupExitPermission, "permission denied writing the WireGuard directory (run as root)"},
+}
+
+// setupEvent is a line of "setup --json" output.
+type setupEvent struct {
+	Event    string `json:"event"` // "step", "warning" or "result"
+	Step     string `json:"step,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Message  string `json:"message,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+}
+
+// handleSetupMode implements "setup", the non-interactive setup scripted
+// installs run (and the wizard runs through sudo), and returns the exit
+// code.
+func handleSetupMode(args []string) int {
+	flags := flag.NewFlagSet("setup", flag.ContinueOnError)
+	prodPath := flags.String("prod", "", "production config file")
+	nonprodPath := flags.String("nonprod", "", "non-production config file")
+	prodOnly := flags.Bool("prod-only", false, "set up production only (needs --prod, no --nonprod)")
+	nonprodOnly := flags.Bool("nonprod-only", false, "set up non-production only (needs --nonprod, no --prod)")
+	force := flags.Bool("force", false, "overwrite configs that are already installed, and templates with local changes (saved as .local backups first)")
+	keepTemplates := flags.Bool("keep-templates", false, "leave templates with local changes as they are")
+	endpointProd := flags.String("endpoint-prod", "", "production gateway (host:port) to recognize configs by, for other gateways than JULO's")
+	endpointNonProd := flags.String("endpoint-nonprod", "", "non-production gateway (host:port), likewise")
+	asJSON := flags.Bool("json", false, "print progress as JSON lines (step, warning and result events)")
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: %s setup --prod FILE --nonprod FILE [--force] [--keep-templates] [--json]\n", os.Args[0])
+		fmt.Fprintf(out, "       %s setup --prod-only --prod FILE | --nonprod-only --nonprod FILE\n\n", os.Args[0])
+		fmt.Fprintln(out, "Installs the templates and the given configs without the TUI. Needs root.")
+		fmt.Fprintln(out, "\nFlags:")
+		flags.PrintDefaults()
+		fmt.Fprintln(out, "\nExit codes:")
+		for _, exit := range setupExitCodes {
+			fmt.Fprintf(out, "  %3d  %s\n", exit.code, exit.meaning)
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return setupExitUsage
+	}
+
+	report := func(event setupEvent) {
+		if *asJSON {
+			encoded, _ := json.Marshal(event)
+			fmt.Println(string(encoded))
+			return
+		}
+		switch event.Event {
+		case "warning":
+			fmt.Printf("⚠️  %s\n", event.Message)
+		case "result":
+			if *event.ExitCode != 0 {
+				fmt.Printf("Setup failed: %s\n", event.Message)
+			} else {
+				fmt.Println(event.Message)
+			}
+		}
+	}
+	fail := func(code int, format string, a ...any) int {
+		report(setupEvent{Event: "result", Message: fmt.Sprintf(format, a...), ExitCode: &code})
+		return code
+	}
+
+	switch {
+	case flags.NArg() > 0:
+		return fail(setupExitUsage, "unexpected argument %q", flags.Arg(0))
+	case *prodOnly && *nonprodOnly:
+		return fail(setupExitUsage, "--prod-only and --nonprod-only exclude each other")
+	case *prodOnly && (*prodPath == "" || *nonprodPath != ""):
+		return fail(setupExitUsage, "--prod-only needs --prod and no --nonprod")
+	case *nonprodOnly && (*nonprodPath == "" || *prodPath != ""):
+		return fail(setupExitUsage, "--nonprod-only needs --nonprod and no --prod")
+	case !*prodOnly && !*nonprodOnly && (*prodPath == "" || *nonprodPath == ""):
+		return fail(setupExitUsage, "both --prod and --nonprod are needed (or --prod-only / --nonprod-only)")
+	}
+	endpoints := []string{*endpointProd, *endpointNonProd}
+	for i, env := range []vpn.Environment{vpn.Production, vpn.NonProduction} {
+		endpoint := endpoints[i]
+		if endpoint == "" {
+			continue
+		}
+		if _, port, err := net.SplitHostPort(endpoint); err != nil || port == "" {
+			return fail(setupExitUsage, "--endpoint-%s must be host:port, not %q", env, endpoint)
+		}
+		config.RegisterEndpoint(string(env), endpoint)
+	}
+
+	// Everything that can be checked up front is, so a failure leaves
+	// nothing half installed
+	installed, statusErr := config.CheckSetupStatusNonInteractive()
+	processor := config.NewConfigProcessor()
+	paths := []string{*prodPath, *nonprodPath}
+	for i, env := range []vpn.Environment{vpn.Production, vpn.NonProduction} {
+		path := paths[i]
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fail(setupExitMissing, "%s config file not found: %s", env.DisplayName(), path)
+		}
+		detected, err := processor.DetectEnvironment(path)
+		if err != nil {
+			return fail(setupExitEndpoint, "%s is not a %s config: %v", path, env.DisplayName(), err)
+		}
+		if detected != string(env) {
+			return fail(setupExitEndpoint, "%s is a %s config, not a %s one", path, vpn.Environment(detected).DisplayName(), env.DisplayName())
+		}
+		// An unknown status (sudo would prompt) is left to the write to find out
+		if statusErr == nil && installed.HasConfig(string(env)) && !*force {
+			return fail(setupExitExists, "the %s config is already installed (%s); pass --force to overwrite it", env.DisplayName(), core.InstalledPath(core.ConfigFile(env)))
+		}
+	}
+	templates := config.RefuseCustomTemplates
+	switch {
+	case *keepTemplates:
+		templates = config.KeepCustomTemplates
+	case *force:
+		templates = config.ReplaceCustomTemplates
+	}
+	customizedHint := "pass --force to replace it (the changes are saved as a .local backup) or --keep-templates to keep it"
+	if templates == config.RefuseCustomTemplates {
+		// Templates that can't be read yet are checked again by the install
+		if customized, err := config.CustomizedTemplates(); err == nil && len(customized) > 0 {
+			return fail(setupExitExists, "%s changed since it was installed; %s", strings.Join(customized, ", "), customizedHint)
+		}
+	}
+
+	warnings, err := config.RunSetupDirectly(*prodPath, *nonprodPath, templates, func(event ops.StepEvent) {
+		if *asJSON {
+			message := ""
+			if event.Err != nil {
+				message = event.Err.Error()
+			}
+			report(setupEvent{Event: "step", Step: event.Step, Status: event.Status.String(), Message: message})
+			return
+		}
+		printStep(event)
+	})
+	for _, warning := range warnings {
+		report(setupEvent{Event: "warning", Message: warning})
+	}
+	switch {
+	case errors.Is(err, config.ErrInsufficientPermissions):
+		return fail(setupExitPermission, "%v", err)
+	case errors.Is(err, config.ErrTemplateCustomized):
+		return fail(setupExitExists, "%v; %s", err, customizedHint)
+	case err != nil:
+		return fail(1, "%v", err)
+	}
+	code := 0
+	report(setupEvent{Event: "result", Message: "✅ Setup completed", ExitCode: &code})
+	return 0
+}
+
+// Exit codes of status, up and down, for hotkeys and status bars to branch
+// on. Usage errors exit with 64 (EX_USAGE), as elsewhere.
+const (
+	exitConnected    = 0 // connected, or up/down succeeded
+	exitDisconnected = 1
+	exitError        = 2
+)
+
+// promptRequested reports whether status args ask for the prompt segment,
+// which is answered before the settings are even read.
+func promptRequested(args []string) bool {
+	return slices.Contains(args, "--prompt") || slices.Contains(args, "-prompt")
+}
+
+// handleStatusMode implements "status [--json]" and "status --prompt", and
+// returns the exit code.
+func handleStatusMode(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	prompt := flags.Bool("prompt", false, "print a short segment for shell prompts (e.g. wg:prod)")
+	asJSON := flags.Bool("json", false, "print the status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() > 0 {
+		fmt.Printf("Usage: %s status [--json | --prompt]\n", os.Args[0])
+		return 64
+	}
+	if *prompt {
+		segment, code := vpn.PromptStatus()
+		if segment != "" {
+			fmt.Println(segment)
+		}
+		return code
+	}
+
+	status, err := vpn.NewService().GetStatus()
+	if err != nil {
+		return headlessError(*asJSON, err)
+	}
+	report := vpn.NewStatusReport(status, time.Now())
+	printStatusReport(report, *asJSON)
+	if !report.Connected {
+		return exitDisconnected
+	}
+	return exitConnected
+}
+
+// handleUpMode implements "up ENV": it starts env's tunnel (stopping any
+// other), waits for the handshake like a Start in the TUI, and prints the
+// status. A profile with mfa set asks for its one-time code on the
+// terminal, or reads it from stdin when that is piped.
+func handleUpMode(args []string, appSettings *settings.Settings) int {
+	flags := flag.NewFlagSet("up", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the resulting status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	// Flags may follow the environment too ("up prod --json")
+	envName := flags.Arg(0)
+	if flags.NArg() > 0 && flags.Parse(flags.Args()[1:]) != nil {
+		return 64
+	}
+	if envName == "" || flags.NArg() > 0 {
+		fmt.Printf("Usage: %s up [--json] <prod|nonprod>\n", os.Args[0])
+		return 64
+	}
+	env, err := vpn.ParseEnvironment(envName)
+	if err != nil {
+		fmt.Println(err)
+		return 64
+	}
+	if profile := appSettings.Profile(string(env)); profile != nil && profile.MFA != "" {
+		gate := mfa.Gate{Profile: string(env), Mode: profile.MFA, Command: profile.MFACommand}
+		if err := checkMFAOnTerminal(gate, env); err != nil {
+			return headlessError(*asJSON, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), vpnOperationTimeout)
+	defer cancel()
+	svc := vpn.NewService()
+	if err := svc.StartWithOutput(ctx, env, nil); err != nil {
+		return headlessError(*asJSON, err)
+	}
+	if err := verifyHandshake(ctx, svc, env); err != nil {
+		return headlessError(*asJSON, err)
+	}
+	status, err := svc.GetStatus()
+	if err != nil {
+		return headlessError(*asJSON, err)
+	}
+	printStatusReport(vpn.NewStatusReport(status, time.Now()), *asJSON)
+	return exitConnected
+}
+
+// handleDownMode implements "down", bringing down every JULO tunnel that is
+// up. Nothing being connected is a success too.
+func handleDownMode(args []string) int {
+	flags := flag.NewFlagSet("down", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the resulting status as JSON")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() > 0 {
+		fmt.Printf("Usage: %s down [--json]\n", os.Args[0])
+		return 64
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), vpnOperationTimeout)
+	defer cancel()
+	svc := vpn.NewService()
+	if err := svc.StopWithOutput(ctx, nil); err != nil {
+		return headlessError(*asJSON, err)
+	}
+	status, err := svc.GetStatus()
+	if err != nil {
+		return headlessError(*asJSON, err)
+	}
+	printStatusReport(vpn.NewStatusReport(status, time.Now()), *asJSON)
+	return exitConnected
+}
+
+// checkMFAOnTerminal asks for gate's code up to mfa.MaxAttempts times; a
+// code piped on stdin gets one attempt.
+func checkMFAOnTerminal(gate mfa.Gate, env vpn.Environment) error {
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for attempt := 1; ; attempt++ {
+		if interactive {
+			fmt.Fprintf(os.Stderr, "🔐 One-time code for %s: ", env.DisplayName())
+		}
+		line, err := reader.ReadString('\n')
+		code := strings.TrimSpace(line)
+		if code == "" && err != nil {
+			return fmt.Errorf("%s requires a one-time code: run up from a terminal or pipe the code on stdin", env.DisplayName())
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = gate.Check(ctx, code)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, mfa.ErrInvalidCode) || !interactive || attempt >= mfa.MaxAttempts {
+			return fmt.Errorf("one-time code check failed: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "❌ Invalid code (%d attempts left)\n", mfa.MaxAttempts-attempt)
+	}
+}
+
+// printStatusReport prints report as JSON or as one line.
+func printStatusReport(report vpn.StatusReport, asJSON bool) {
+	if asJSON {
+		encoded, _ := json.Marshal(report)
+		fmt.Println(string(encoded))
+		return
+	}
+	fmt.Println(statusSummary(report))
+}
+
+// statusSummary is a status report in one line, e.g. "connected: Production
+// (julo-prod) via 34.101.166.184:51820, handshake 12s ago, ↓ 1.2 MB ↑ 340.0 KB".
+func statusSummary(report vpn.StatusReport) string {
+	if !report.Connected {
+		return "disconnected"
+	}
+	summary := fmt.Sprintf("connected: %s (%s)", report.Environment.DisplayName(), report.Interface)
+	if report.Endpoint != "" {
+		summary += " via " + report.Endpoint
+	}
+	if report.LastHandshakeSeconds != nil {
+		summary += fmt.Sprintf(", handshake %s ago", time.Duration(*report.LastHandshakeSeconds)*time.Second)
+	} else {
+		summary += ", no handshake yet"
+	}
+	return summary + fmt.Sprintf(", ↓ %s ↑ %s", render.FormatBytes(report.RxBytes), render.FormatBytes(report.TxBytes))
+}
+
+// headlessError reports err of status, up or down, as {"error": ...} with
+// --json, and returns exitError.
+func headlessError(asJSON bool, err error) int {
+	if asJSON {
+		encoded, _ := json.Marshal(map[string]string{"error": err.Error()})
+		fmt.Println(string(encoded))
+	} else {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	return exitError
+}
+
+// handleVerifyMode implements "verify --env ENV", the check a CI pipeline
+// runs before touching an environment, and returns the exit code: 0 when the
+// machine is on env's VPN with a fresh handshake and every --check connects,
+// 1 when it isn't (or that couldn't be established within --timeout), 64
+// for usage errors. It never asks for a sudo password.
+func handleVerifyMode(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	envName := flags.String("env", "", "environment that must be connected (prod, nonprod, ...)")
+	maxAge := flags.Duration("max-handshake-age", vpn.DefaultHandshakeAge, "oldest acceptable handshake")
+	timeout := flags.Duration("timeout", 10*time.Second, "give up and fail after this long")
+	asJSON := flags.Bool("json", false, "print the result as JSON")
+	var checks []string
+	flags.Func("check", "host:port that must accept a TCP connection (repeatable)", func(address string) error {
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return fmt.Errorf("%q is not host:port", address)
+		}
+		checks = append(checks, address)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if *envName == "" {
+		fmt.Printf("Usage: %s verify --env ENV [--max-handshake-age 90s] [--check host:port]... [--json]\n", os.Args[0])
+		return 64
+	}
+	env, err := vpn.ParseEnvironment(*envName)
+	if err != nil {
+		fmt.Println(err)
+		return 64
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	done := make(chan *vpn.Verification, 1)
+	go func() {
+		done <- vpn.Verify(ctx, vpn.NewService(), env, *maxAge, checks, probe.NewUDPProber())
+	}()
+	var result *vpn.Verification
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		// Whatever is still running is abandoned with the process
+		result = &vpn.Verification{Environment: env, Connected: []vpn.Environment{}, Reason: fmt.Sprintf("timed out after %s", *timeout)}
+	}
+
+	if *asJSON {
+		encoded, _ := json.Marshal(result)
+		fmt.Println(string(encoded))
+	} else if result.OK {
+		fmt.Printf("ok: %s\n", result.Reason)
+	} else {
+		fmt.Printf("fail: %s\n", result.Reason)
+	}
+	if !result.OK {
+		return 1
+	}
+	return 0
+}
+
+// handlePreflightMode implements "preflight <env>": the profile's readiness
+// checks as a checklist with an overall PASS or FAIL, exiting 0 or 1 (64 for
+// invalid arguments). The run is recorded in the audit log.
+func handlePreflightMode(args []string, appSettings *settings.Settings) int {
+	flags := flag.NewFlagSet("preflight", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	if flags.NArg() != 1 {
+		fmt.Printf("Usage: %s preflight <prod|nonprod>\n", os.Args[0])
+		return 64
+	}
+	env, err := vpn.ParseEnvironment(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		return 64
+	}
+
+	fmt.Printf("🛫 Preflight for %s\n", env.DisplayName())
+	result := vpn.Preflight(context.Background(), vpn.NewService(), env, preflightSpec(appSettings, env), probe.NewUDPProber())
+	result.Record()
+	doctor.Print(os.Stdout, result.Checks)
+	fmt.Println(result.Summary())
+	if result.Failed() {
+		return 1
+	}
+	return 0
+}
+
+// preflightSpec is what env's profile asks preflight to check.
+func preflightSpec(appSettings *settings.Settings, env vpn.Environment) vpn.PreflightSpec {
+	var spec vpn.PreflightSpec
+	profile := appSettings.Profile(string(env))
+	if profile == nil {
+		return spec
+	}
+	spec.Checks = profile.PreflightChecks
+	spec.DNSNames = profile.PreflightDNS
+	for _, route := range profile.PreflightRoutes {
+		// Validated when the settings were loaded
+		if prefix, err := netip.ParsePrefix(route); err == nil {
+			spec.Routes = append(spec.Routes, prefix)
+		}
+	}
+	return spec
+}
+
+// handleRollbackMode implements "rollback [--restart] ENV": it puts the
+// newest backup of env's config back in place. While env's tunnel is up it
+// refuses, unless --restart lets it take the tunnel down and bring it up
+// again on the restored config.
+func handleRollbackMode(args []string) int {
+	flags := flag.NewFlagSet("rollback", flag.ContinueOnError)
+	restart := flags.Bool("restart", false, "stop the environment's tunnel if it is up and start it again afterwards")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	// Flags may follow the environment too ("rollback prod --restart")
+	envName := flags.Arg(0)
+	if flags.NArg() > 0 && flags.Parse(flags.Args()[1:]) != nil {
+		return 64
+	}
+	if envName == "" || flags.NArg() > 0 {
+		fmt.Printf("Usage: %s rollback [--restart] <prod|nonprod>\n", os.Args[0])
+		return 64
+	}
+	env, err := vpn.ParseEnvironment(envName)
+	if err != nil {
+		fmt.Println(err)
+		return 64
+	}
+
+	svc := vpn.NewService()
+	up := false
+	if status, err := svc.GetStatus(); err == nil {
+		up = (status.Connected && status.Environment == env) || slices.Contains(status.ConflictingInterfaces, env.Interface())
+	}
+	if up && !*restart {
+		fmt.Printf("❌ %s VPN is connected: run with --restart to take %s down, roll back and start it again\n", env.DisplayName(), env.Interface())
+		return 1
+	}
+	backup, err := rollbackConfig(svc, env, up)
+	if backup == nil {
+		fmt.Printf("❌ Rollback failed: %v\n", err)
+		return 1
+	}
+	fmt.Printf("⏪ Restored %s from %s (taken %s)\n", core.ConfigFile(env), backup, backup.Taken.Format("2006-01-02 15:04:05"))
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		return 1
+	}
+	if up {
+		fmt.Printf("✅ %s VPN restarted on the restored config\n", env.DisplayName())
+	}
+	return 0
+}
+
+// rollbackConfig restores the newest backup of env's config, stopping its
+// tunnel first and starting it again afterwards when restart is set, and
+// records where the config now comes from.
+func rollbackConfig(svc vpn.Service, env vpn.Environment, restart bool) (*config.ConfigBackup, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*vpnOperationTimeout)
+	defer cancel()
+	if restart {
+		// wg-quick down reads the config it brought up, so the tunnel goes
+		// down before that config is replaced
+		if err := svc.StopInterface(ctx, env.Interface(), nil); err != nil {
+			return nil, fmt.Errorf("failed to stop %s: %v", env.Interface(), err)
+		}
+	}
+	backup, err := config.NewConfigProcessor().Rollback(string(env))
+	if err != nil {
+		if restart {
+			// Leave the tunnel as it was found, on the config it had
+			if startErr := svc.StartWithOutput(ctx, env, nil); startErr != nil {
+				return nil, fmt.Errorf("%v; starting %s again also failed: %v", err, env.Interface(), startErr)
+			}
+		}
+		return nil, err
+	}
+	recordErr := state.RecordConfig(string(env), "rolled back to the backup of "+backup.Taken.Format("2006-01-02 15:04"))
+	if restart {
+		if err := svc.StartWithOutput(ctx, env, nil); err != nil {
+			return backup, fmt.Errorf("restored %s but failed to start %s again: %v", backup, env.Interface(), err)
+		}
+	}
+	if recordErr != nil {
+		return backup, fmt.Errorf("restored %s but could not save state: %v", backup, recordErr)
+	}
+	return backup, nil
+}
+
+// handleProvisionMode implements "provision [--file FILE]", applying the
+// provisioning file like a launch does. --install is the privileged half
+// applyProvisioning runs through sudo.
+func handleProvisionMode(args []string) error {
+	flags := flag.NewFlagSet("provision", flag.ContinueOnError)
+	file := flags.String("file", settings.ProvisionFile, "provisioning file")
+	install := flags.Bool("install", false, "only install the templates and configs (needs root)")
+	itemsPath := flags.String("items", "", "with --install: try every file and write what was done to this file as JSON")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*install {
+		if p, previous, err := provision.Pending(*file); err == nil && p == nil {
+			if previous == nil {
+				return fmt.Errorf("no provisioning file at %s", *file)
+			}
+			fmt.Printf("Provisioning version %d is already applied\n", previous.Version)
+			return nil
+		}
+		return applyProvisioning(*file)
+	}
+
+	p, err := settings.LoadProvision(*file)
+	if err != nil {
+		return err
+	}
+	if *itemsPath != "" {
+		var installed bundleInstall
+		installed.Items, installed.Warnings = provision.InstallItems(p)
+		data, err := json.Marshal(installed)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(*itemsPath, data, 0600)
+	}
+	warnings, err := provision.Install(p)
+	printWarnings(warnings)
+	return err
+}
+
+// bundleInstall is what "provision --install --items" hands back to the
+// unprivileged "provision apply" that ran it.
+type bundleInstall struct {
+	Items    []provision.Item `json:"items"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// handleProvisionApply implements "provision apply", the unattended run of
+// fleet tooling: the bundle is unpacked and validated, its templates and
+// configs are installed with one privilege escalation, skipping those
+// already in place, and every item is reported, as JSON with --report. It
+// returns the exit code: 0 when nothing failed, 1 when something did, 64
+// for bad flags and 65 for a bundle that can't be used.
+func handleProvisionApply(args []string) int {
+	flags := flag.NewFlagSet("provision apply", flag.ContinueOnError)
+	reportPath := flags.String("report", "", "write a JSON report of every item to this file")
+	if err := flags.Parse(args); err != nil {
+		return 64
+	}
+	// Flags may follow the bundle too
+	archive := flags.Arg(0)
+	if flags.NArg() > 0 && flags.Parse(flags.Args()[1:]) != nil {
+		return 64
+	}
+	if archive == "" || flags.NArg() > 0 {
+		fmt.Printf("Usage: %s provision apply [--report FILE] <bundle.tar.gz>\n", os.Args[0])
+		return 64
+	}
+
+	report := provision.NewReport(archive)
+	finish := func(code int) int {
+		report.Finish(doctor.Run())
+		printProvisionReport(report)
+		if *reportPath != "" {
+			if err := report.Write(*reportPath); err != nil {
+				fmt.Printf("❌ %v\n", err)
+				code = max(code, 1)
+			}
+		}
+		return code
+	}
+
+	bundle, err := provision.OpenBundle(archive)
+	if err != nil {
+		report.Fail(err)
+		return finish(65)
+	}
+	defer bundle.Close()
+	report.ProvisioningVersion = bundle.Provision.Version
+
+	installed, err := installBundle(bundle.Provision)
+	if err != nil {
+		report.Fail(err)
+		return finish(1)
+	}
+	// Named as in the bundle, not by where it happened to be unpacked
+	for i := range installed.Items {
+		installed.Items[i].Source = bundle.Name(installed.Items[i].Source)
+	}
+	settingsItem, err := provision.FinishItems(bundle.Provision, installed.Items)
+	if settingsItem != nil {
+		settingsItem.Source = bundle.Name(settingsItem.Source)
+		installed.Items = append(installed.Items, *settingsItem)
+	}
+	report.Add(installed.Items...)
+	report.Warnings = installed.Warnings
+	if err != nil {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("could not record the provisioning: %v", err))
+	}
+	if report.Failed {
+		return finish(1)
+	}
+	return finish(0)
+}
+
+// installBundle runs the privileged part of "provision apply": directly
+// when already root, through a single sudo otherwise.
+func installBundle(p *settings.Provision) (*bundleInstall, error) {
+	installed := &bundleInstall{}
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		installed.Items, installed.Warnings = provision.InstallItems(p)
+		return installed, nil
+	}
+
+	execPath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate executable: %v", err)
+	}
+	// A directory of our own: root may not write into another user's file
+	// in a sticky /tmp
+	dir, err := os.MkdirTemp("", "tui-wireguard-vpn-items-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "items.json")
+
+	cmd := exec.Command("sudo", execPath, "provision", "--install", "--file", p.Path, "--items", out)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("privileged install failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the privileged install's results: %v", err)
+	}
+	if err := json.Unmarshal(data, installed); err != nil {
+		return nil, fmt.Errorf("failed to read the privileged install's results: %v", err)
+	}
+	return installed, nil
+}
+
+// printProvisionReport prints a bundle run for the person or log watching.
+func printProvisionReport(report *provision.Report) {
+	fmt.Printf("📦 %s", report.Bundle)
+	if report.ProvisioningVersion > 0 {
+		fmt.Printf(" (provisioning version %d)", report.ProvisioningVersion)
+	}
+	fmt.Println()
+	for _, item := range report.Items {
+		icon := "✅"
+		switch item.Action {
+		case config.ItemSkipped:
+			icon = "➖"
+		case config.ItemFailed:
+			icon = "❌"
+		}
+		line := fmt.Sprintf("  %s %s: %s", icon, item.Item, item.Action)
+		if item.Reason != "" {
+			line += " (" + item.Reason + ")"
+		}
+		fmt.Println(line)
+	}
+	printWarnings(report.Warnings)
+	if report.Doctor != nil {
+		failed := 0
+		for _, check := range report.Doctor.Checks {
+			if check.Status == doctor.Fail.String() {
+				failed++
+			}
+		}
+		if failed > 0 {
+			fmt.Printf("🩺 Doctor: %d check(s) failed (run 'tui-wireguard-vpn doctor')\n", failed)
+		} else {
+			fmt.Println("🩺 Doctor: no failed checks")
+		}
+	}
+	switch {
+	case report.Error != "":
+		fmt.Printf("❌ %s\n", report.Error)
+	case report.Failed:
+		fmt.Println("❌ Bundle applied with failures")
+	case !report.Changed:
+		fmt.Println("✅ Nothing to do: the bundle is already applied")
+	default:
+		fmt.Println("✅ Bundle applied")
+	}
+}
+
+// applyProvisioning applies the provisioning file at path unless its version
+// was applied already. A newer version than the applied one is summarized
+// and only applied after confirmation. Installing escalates once, through
+// sudo, when not running as root.
+func applyProvisioning(path string) error {
+	p, previous, err := provision.Pending(path)
+	if err != nil || p == nil {
+		return err
+	}
+
+	if changes := provision.Changes(previous, p); len(changes) > 0 {
+		fmt.Printf("📦 A newer provisioning file is available (%s):\n", path)
+		for _, change := range changes {
+			fmt.Printf("    %s\n", change)
+		}
+		fmt.Print("Apply it? [y/N]: ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+			fmt.Println("Not applied; you will be asked again on the next launch.")
+			return nil
+		}
+	} else {
+		fmt.Printf("📦 Applying provisioning version %d from %s...\n", p.Version, path)
+	}
+
+	if runtime.GOOS != "windows" && os.Geteuid() != 0 {
+		execPath, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to locate executable: %v", err)
+		}
+		cmd := exec.Command("sudo", execPath, "provision", "--install", "--file", path)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("privileged install failed: %v", err)
+		}
+	} else {
+		warnings, err := provision.Install(p)
+		printWarnings(warnings)
+		if err != nil {
+			return err
+		}
+	}
+
+	installedSettings, err := provision.Finish(p)
+	if err != nil {
+		return err
+	}
+	if installedSettings {
+		fmt.Println("⚙️  Installed the provisioned settings file")
+	}
+	fmt.Printf("✅ Provisioning version %d applied\n", p.Version)
+	return nil
+}
+
+// handleAgentMode runs headless until SIGINT/SIGTERM, serving the health
+// endpoints for monitoring (and /v1/configs when [agent] sets it up) and
+// running the sync schedule, if any.
+func handleAgentMode(args []string, appSettings *settings.Settings) error {
+	flags := flag.NewFlagSet("agent", flag.ContinueOnError)
+	listen := flags.String("listen", "", "serve GET /healthz and /metrics on this address (e.g. 127.0.0.1:9821)")
+	tokenEnv := flags.String("token-env", "", "environment variable holding the bearer token (required off loopback)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *listen == "" {
+		return fmt.Errorf("Usage: %s agent --listen ADDR [--token-env VAR]", os.Args[0])
+	}
+	token := ""
+	if *tokenEnv != "" {
+		token = os.Getenv(*tokenEnv)
+		if token == "" {
+			return fmt.Errorf("%s is not set", *tokenEnv)
+		}
+	}
+
+	configs, err := configsEndpoint(appSettings)
+	if err != nil {
+		return err
+	}
+
+	// Closing the terminal it was started from stops it cleanly too
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+	fmt.Printf("🩺 Serving /healthz and /metrics on %s\n", *listen)
+	if configs != nil {
+		fmt.Println("🗂️ Serving /v1/configs (config revisions, authenticated)")
+	}
+	svc := vpn.NewService()
+	if appSettings.SyncSchedule != nil && len(remoteSources(appSettings)) > 0 {
+		fmt.Printf("🕘 Syncing from server %s (%s)\n", appSettings.SyncSchedule, appSettings.SyncPolicy)
+		go runSyncSchedule(ctx, svc, appSettings)
+	}
+	go runSleepWatch(ctx, sleep.System(), svc, appSettings)
+	if socket, err := paths.EnsureFile(paths.Runtime, vpn.AgentSocket); err != nil {
+		fmt.Printf("⚠️ Not answering shell prompts: %v\n", err)
+	} else {
+		go func() {
+			if err := health.ServePrompt(ctx, socket, svc); err != nil {
+				fmt.Printf("⚠️ Not answering shell prompts: %v\n", err)
+			}
+		}()
+	}
+	if err := health.Serve(ctx, *listen, svc, token, configs); err != nil {
+		return err
+	}
+	fmt.Println("👋 Agent stopped")
+	return nil
+}
+
+// configsEndpoint is the agent's /v1/configs as the [agent] settings
+// configure it, nil when they don't give it a token or a client CA.
+func configsEndpoint(appSettings *settings.Settings) (*health.ConfigsEndpoint, error) {
+	agent := appSettings.Agent
+	if agent.ConfigTokenEnv == "" && agent.ClientCA == "" {
+		return nil, nil
+	}
+	token := agent.ConfigToken()
+	if agent.ConfigTokenEnv != "" && token == "" {
+		return nil, fmt.Errorf("%s is not set", agent.ConfigTokenEnv)
+	}
+	host, _ := os.Hostname()
+	return &health.ConfigsEndpoint{
+		Token:    token,
+		ClientCA: agent.ClientCA,
+		TLSCert:  agent.TLSCert,
+		TLSKey:   agent.TLSKey,
+		Host:     host,
+		Collect: func(ctx context.Context) []health.ConfigRevision {
+			return configRevisions(appSettings)
+		},
+	}, nil
+}
+
+// configRevisions describes the installed configs for /v1/configs: hashes,
+// times and where they came from, never what is in them.
+func configRevisions(appSettings *settings.Settings) []health.ConfigRevision {
+	sources := remoteSources(appSettings)
+	appState, _ := state.Load()
+	optional := func(t time.Time) *time.Time {
+		if t.IsZero() {
+			return nil
+		}
+		return &t
+	}
+
+	var revisions []health.ConfigRevision
+	for _, env := range vpn.Environments() {
+		revision := health.ConfigRevision{Environment: string(env)}
+		installed, err := config.InstalledRevision(string(env), sources)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ /v1/configs: %v\n", err)
+			revision.Error = "unreadable"
+			revisions = append(revisions, revision)
+			continue
+		}
+		revision.Installed = installed.ConfigSHA256 != ""
+		revision.ConfigSHA256 = installed.ConfigSHA256
+		revision.TemplateSHA256 = installed.TemplateSHA256
+		revision.Routes = installed.Routes
+		revision.TemplateSyncedAt = optional(installed.TemplateSyncedAt)
+		revision.ConfigSyncedAt = optional(installed.ConfigSyncedAt)
+		// Only the kind of source: a file source carries a local path
+		if provenance := appState.Configs[string(env)]; provenance != nil && revision.Installed {
+			if kind := strings.Fields(provenance.Source); len(kind) > 0 {
+				revision.Source = kind[0]
+			}
+			revision.InstalledAt = optional(provenance.UpdatedAt)
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions
+}
+
+// runSyncSchedule runs the scheduled syncs for the agent until ctx ends.
+// Nobody is there to press U, so with sync_policy = "prompt" it only reports
+// what is pending.
+func runSyncSchedule(ctx context.Context, svc vpn.Service, appSettings *settings.Settings) {
+	ticker := time.NewTicker(syncScheduleInterval)
+	defer ticker.Stop()
+	slots := syncSlots(appSettings)
+	for {
+		slot, claimed, err := slots.Next()
+		if err != nil {
+			fmt.Printf("⚠️ Scheduled sync skipped: %v\n", err)
+		}
+		if claimed {
+			connected := vpn.Environment("")
+			if status, err := svc.GetStatus(); err == nil && status.Connected {
+				connected = status.Environment
+			}
+			fmt.Printf("🕘 Scheduled sync for %s\n", slot.Format("Mon 2006-01-02 15:04"))
+			msg := runScheduledSync(ctx, svc, remoteSources(appSettings), appSettings.SyncPolicy, connected)
+			for _, change := range msg.result.Changes {
+				switch {
+				case change.Err != nil:
+					fmt.Printf("❌ %s\n", change)
+				case change.Updated && appSettings.SyncPolicy != "auto":
+					fmt.Printf("⬇️ %s %s: new version on the server (apply it with U in the TUI)\n", change.Env, change.Kind)
+				case change.Updated:
+					fmt.Printf("✅ %s\n", change)
+				}
+			}
+			if msg.reloaded != "" {
+				if msg.reloadErr != nil {
+					fmt.Printf("❌ Failed to reconnect %s with the new config: %v\n", msg.reloaded.DisplayName(), msg.reloadErr)
+				} else {
+					fmt.Printf("🔄 Reconnected %s with the new config\n", msg.reloaded.DisplayName())
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runSleepWatch is the agent's side of disconnect_on_sleep: it stops a
+// connected tunnel before the system suspends and starts it again on
+// resume, or only warns when the setting is off. A profile behind an MFA
+// gate isn't started again, since there is nobody to type the code.
+func runSleepWatch(ctx context.Context, monitor sleep.Monitor, svc vpn.Service, appSettings *settings.Settings) {
+	events, err := monitor.Watch(ctx)
+	if err != nil {
+		if !errors.Is(err, sleep.ErrUnsupported) {
+			fmt.Printf("⚠️ Not watching for system sleep: %v\n", err)
+		}
+		return
+	}
+	var release func()
+	hold := func() {
+		if appSettings.DisconnectOnSleep && release == nil {
+			if release, err = monitor.Inhibit("disconnect the VPN before sleep"); err != nil {
+				fmt.Printf("⚠️ Could not take a sleep inhibitor lock, the VPN may still be up when the system sleeps: %v\n", err)
+			}
+		}
+	}
+	hold()
+	var stopped vpn.Environment
+	for sleeping := range events {
+		if sleeping {
+			if status, err := svc.GetStatus(); err == nil && status.Connected {
+				env := status.Environment
+				if !appSettings.DisconnectOnSleep {
+					fmt.Printf("💤 System is going to sleep with %s connected; open sessions may hang on resume\n", env.DisplayName())
+				} else if err := svc.Stop(); err != nil {
+					fmt.Printf("❌ Failed to stop %s (system sleep): %v\n", env.DisplayName(), err)
+				} else {
+					fmt.Printf("💤 Stopped %s: system sleep\n", env.DisplayName())
+					stopped = env
+					// Meant to come back on resume, or after a reboot
+					// while asleep
+					_ = state.RecordInterrupted(string(env))
+				}
+			}
+			if release != nil {
+				release()
+				release = nil
+			}
+			continue
+		}
+
+		hold()
+		if stopped == "" {
+			continue
+		}
+		env := stopped
+		stopped = ""
+		if profile := appSettings.Profile(string(env)); profile != nil && profile.MFA != "" {
+			fmt.Printf("⚠️ Not starting %s again after sleep: it needs a one-time code (start it from the TUI)\n", env.DisplayName())
+		} else if err := svc.Start(env); err != nil {
+			fmt.Printf("❌ Failed to start %s again (resumed from system sleep): %v\n", env.DisplayName(), err)
+		} else {
+			fmt.Printf("☀️ Started %s again: resumed from system sleep\n", env.DisplayName())
+		}
+	}
+	if release != nil {
+		release()
+	}
+}
+
+// handleBackupMode implements "backup create|list|restore <file>".
+func handleBackupMode(args []string, appSettings *settings.Settings) error {
+	usage := fmt.Errorf("Usage: %s backup [--dir DIR] create|list|restore <file>", os.Args[0])
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	dir := flags.String("dir", appSettings.Backup.Dir, "backup directory (default from [backup] dir in settings)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return usage
+	}
+
+	switch flags.Arg(0) {
+	case "create":
+		if *dir == "" {
+			return fmt.Errorf("no backup directory: set [backup] dir in %s or pass --dir", settingsPathForHelp())
+		}
+		passphrase, err := backupPassphrase(appSettings, true)
+		if err != nil {
+			return err
+		}
+		path, err := backup.Create(*dir, passphrase)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("💾 Backup written to %s\n", path)
+	case "list":
+		if *dir == "" {
+			return fmt.Errorf("no backup directory: set [backup] dir in %s or pass --dir", settingsPathForHelp())
+		}
+		archives, err := backup.List(*dir)
+		if err != nil {
+			return err
+		}
+		if len(archives) == 0 {
+			fmt.Printf("No backups in %s\n", *dir)
+		}
+		for _, archive := range archives {
+			fmt.Printf("%s  %6d bytes  %s\n", archive.Created.Format("2006-01-02 15:04"), archive.Size, archive.Path)
+		}
+	case "restore":
+		if flags.NArg() < 2 {
+			return usage
+		}
+		passphrase, err := backupPassphrase(appSettings, false)
+		if err != nil {
+			return err
+		}
+		restored, err := backup.Restore(flags.Arg(1), passphrase)
+		for _, name := range restored {
+			fmt.Printf("✅ Restored %s\n", filepath.Join(core.ConfigDir, name))
+			switch name {
+			case core.ProdConfig:
+				_ = state.RecordConfig(string(vpn.Production), "backup")
+			case core.NonProdConfig:
+				_ = state.RecordConfig(string(vpn.NonProduction), "backup")
+			}
+		}
+		if err != nil {
+			return err
+		}
+	default:
+		return usage
+	}
+	return nil
+}
+
+// backupPassphrase takes the passphrase from the configured environment
+// variable, or prompts for it without echo (twice when creating).
+func backupPassphrase(appSettings *settings.Settings, confirm bool) (string, error) {
+	if passphrase := appSettings.Backup.Passphrase(); passphrase != "" {
+		return passphrase, nil
+	}
+	fmt.Print("Backup passphrase: ")
+	first, err := term.ReadPassword(os.Stdin.Fd())
+	fmt.Println()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	if len(first) == 0 {
+		return "", fmt.Errorf("a passphrase is required")
+	}
+	if confirm {
+		fmt.Print("Repeat passphrase: ")
+		second, err := term.ReadPassword(os.Stdin.Fd())
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %v", err)
+		}
+		if string(first) != string(second) {
+			return "", fmt.Errorf("passphrases don't match")
+		}
+	}
+	return string(first), nil
+}
+
+func settingsPathForHelp() string {
+	if path, err := settings.Path(); err == nil {
+		return path
+	}
+	return "the settings file"
+}
+
+// handleLogsMode prints persisted logs. Only the audit log of privileged
+// actions is kept on disk, so --audit is required.
+func handleLogsMode(args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	showAudit := flags.Bool("audit", false, "show the audit log of privileged actions")
+	count := flags.Int("n", 50, "number of most recent entries to show (0 for all)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if !*showAudit {
+		return fmt.Errorf("Usage: %s logs --audit [-n N]", os.Args[0])
+	}
+
+	entries, err := audit.Recent(*count)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		path, _ := audit.Path()
+		fmt.Printf("No audit entries yet (%s)\n", path)
+		return nil
+	}
+	audit.Print(os.Stdout, entries)
+	return nil
+}
+
+// acknowledgeHooks prints the hook scripts of the given configs and asks for
+// "yes" before they are installed. Configs without hooks, or a policy that
+// strips them, need no answer.
+func acknowledgeHooks(paths ...string) bool {
+	var hooks []config.Hook
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		directives, err := config.InspectConfig(path)
+		if err != nil {
+			continue // Processing reports unreadable files itself
+		}
+		hooks = append(hooks, directives.Hooks...)
+	}
+	if len(hooks) == 0 || config.NewConfigProcessor().StripHooks {
+		return true
+	}
+
+	fmt.Println("⚠️  These configs run shell commands as root when the tunnel goes up or down:")
+	for _, hook := range hooks {
+		fmt.Printf("    %s\n", hook)
+	}
+	fmt.Print("Type yes to install them anyway: ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
+
+func printWarnings(warnings []string) {
+	for _, warning := range warnings {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+}
+
+func handleUpdateConfigMode(userConfigPath string) error {
+	// This handles the sudo config update process when called with "update-config" argument
+	fmt.Printf("Update config mode: Processing config file: %s\n", userConfigPath)
+
+	// Validate config file exists
+	if _, err := os.Stat(userConfigPath); os.IsNotExist(err) {
+		return fmt.Errorf("config file not found: %s", userConfigPath)
+	}
+
+	if !acknowledgeHooks(userConfigPath) {
+		return fmt.Errorf("cancelled: the config's hook scripts were not accepted")
+	}
+
+	// Run the config update process (same as original j1-vpn-update-config)
+	processor := config.NewConfigProcessor()
+	err := processor.ProcessUserConfig(userConfigPath)
+	printWarnings(processor.Warnings)
+	return err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"tui-wireguard-vpn/internal/platform"
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// testModel is the dashboard on a plain host, with its state and settings
+// in directories of the test's own.
+func testModel(t *testing.T) model {
+	t.Helper()
+	for _, dir := range []string{"XDG_STATE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(dir, t.TempDir())
+	}
+	m := initialModel(settings.Default())
+	m.platform = platform.Context{Kind: platform.Host}
+	return m
+}
+
+func update(m model, msg tea.Msg) model {
+	next, _ := m.Update(msg)
+	return next.(model)
+}
+
+// running puts m in the middle of a Stop, as runOp leaves it.
+func running(t *testing.T, m model) model {
+	t.Helper()
+	if run, _, err := m.opQueue.Submit(stopOp, time.Now()); !run || err != nil {
+		t.Fatalf("Submit(stop) = %v, %v", run, err)
+	}
+	m.loading = true
+	m.message = "Stopping VPN..."
+	m.beginOperation("Stop VPN")
+	return m
+}
+
+func TestStatusRefreshDuringAnOperation(t *testing.T) {
+	prodUp := &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production, Interface: "julo-prod"}
+	m := running(t, testModel(t))
+
+	// The refresh after the previous operation arrives mid-Stop
+	m = update(m, vpnStatusMsg{status: prodUp})
+	if !m.loading || m.message != "Stopping VPN..." || m.status != prodUp {
+		t.Fatalf("after the refresh: loading %v, message %q, status %+v", m.loading, m.message, m.status)
+	}
+	m = update(m, vpnStatusMsg{err: errors.New("wg: permission denied")})
+	if !m.loading || m.message != "Stopping VPN..." {
+		t.Fatalf("after a failed refresh: loading %v, message %q", m.loading, m.message)
+	}
+
+	// Only the operation's own result ends it
+	m = update(m, vpnOperationMsg{operation: stopOp.Key, success: true})
+	if m.loading || m.message != "✅ VPN stopped successfully!" {
+		t.Errorf("after the result: loading %v, message %q", m.loading, m.message)
+	}
+	if _, _, ok := m.opQueue.Running(); ok {
+		t.Error("the stop is still running")
+	}
+}
+
+func TestExplicitRefresh(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     vpnStatusMsg
+		message string
+	}{
+		{"succeeds", vpnStatusMsg{status: &vpn.ConnectionStatus{}}, "Status updated"},
+		{"fails", vpnStatusMsg{err: errors.New("wg: permission denied")}, "Error checking status: wg: permission denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testModel(t)
+			m.refreshing, m.loading, m.message = true, true, "Refreshing status..."
+			m = update(m, tt.msg)
+			if m.loading || m.refreshing || m.message != tt.message {
+				t.Errorf("loading %v, refreshing %v, message %q", m.loading, m.refreshing, m.message)
+			}
+		})
+	}
+}
+
+func TestBackgroundRefreshFailsWhileIdle(t *testing.T) {
+	m := testModel(t)
+	m.message = "✅ VPN stopped successfully!"
+	m = update(m, vpnStatusMsg{status: &vpn.ConnectionStatus{}})
+	if m.message != "✅ VPN stopped successfully!" {
+		t.Errorf("a successful background refresh replaced the message with %q", m.message)
+	}
+	m = update(m, vpnStatusMsg{err: errors.New("wg: permission denied")})
+	if m.message != "Error checking status: wg: permission denied" {
+		t.Errorf("message %q", m.message)
+	}
+}
+
+func TestOperationResults(t *testing.T) {
+	failure := errors.New("exit status 1")
+	tests := []struct {
+		name    string
+		msg     vpnOperationMsg
+		message string
+		entry   string // the newest ✅ or ❌ entry of the activity log
+	}{
+		{
+			name:    "prod started",
+			msg:     vpnOperationMsg{operation: startOp(vpn.Production).Key, env: vpn.Production, success: true},
+			message: "✅ Production VPN started successfully!",
+			entry:   "✅ Production VPN started on julo-prod",
+		},
+		{
+			name:    "nonprod started",
+			msg:     vpnOperationMsg{operation: startOp(vpn.NonProduction).Key, env: vpn.NonProduction, success: true},
+			message: "✅ Non-Production VPN started successfully!",
+			entry:   "✅ Non-Production VPN started on julo-nonprod",
+		},
+		{
+			name:    "prod failed to start",
+			msg:     vpnOperationMsg{operation: startOp(vpn.Production).Key, env: vpn.Production, err: failure},
+			message: "❌ Failed to start Production VPN: exit status 1",
+			entry:   "❌ Failed to start Production VPN on julo-prod: exit status 1",
+		},
+		{
+			name:    "nonprod failed to start",
+			msg:     vpnOperationMsg{operation: startOp(vpn.NonProduction).Key, env: vpn.NonProduction, err: failure},
+			message: "❌ Failed to start Non-Production VPN: exit status 1",
+			entry:   "❌ Failed to start Non-Production VPN on julo-nonprod: exit status 1",
+		},
+		{
+			name:    "stopped",
+			msg:     vpnOperationMsg{operation: stopOp.Key, success: true},
+			message: "✅ VPN stopped successfully!",
+			entry:   "✅ VPN stopped",
+		},
+		{
+			name:    "failed to stop",
+			msg:     vpnOperationMsg{operation: stopOp.Key, err: failure},
+			message: "❌ Failed to stop VPN: exit status 1",
+			entry:   "❌ Failed to stop VPN: exit status 1",
+		},
+		{
+			name:    "config updated",
+			msg:     vpnOperationMsg{operation: "update_config", success: true},
+			message: "✅ Configuration updated successfully!",
+			entry:   "✅ Configuration updated",
+		},
+		{
+			name:    "config update failed",
+			msg:     vpnOperationMsg{operation: "update_config", err: failure},
+			message: "❌ Configuration update failed: exit status 1",
+			entry:   "❌ Configuration update failed: exit status 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testModel(t)
+			m.beginOperation("operation")
+			m = update(m, tt.msg)
+			if m.message != tt.message {
+				t.Errorf("message %q, want %q", m.message, tt.message)
+			}
+			prefix := "❌"
+			if tt.msg.success {
+				prefix = "✅"
+			}
+			if entries := m.activityLog.Last(prefix, 1); len(entries) != 1 || entries[0] != tt.entry {
+				t.Errorf("log %q, want %q", entries, tt.entry)
+			}
+		})
+	}
+}
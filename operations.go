@@ -0,0 +1,633 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/ops"
+	"tui-wireguard-vpn/internal/platform"
+	"tui-wireguard-vpn/internal/probe"
+	"tui-wireguard-vpn/internal/state"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+type vpnOperationMsg struct {
+	operation string
+	env       vpn.Environment // the environment a Start brought up; its operation is startOp(env).Key
+	kept      string          // the interface a conflict resolution kept; its operation is keepOp(kept).Key
+	success   bool
+	err       error
+	stateErr  error          // failure to persist the outcome, reported as a warning
+	timing    *opTiming      // set for timed operations that succeeded
+	leftovers *leftoverCheck // set after a successful stop unless checks are off
+	warnings  []string       // what a config update's merge warned about
+}
+
+// leftoverCheck is the outcome of looking for DNS settings and routes a
+// stopped tunnel left behind.
+type leftoverCheck struct {
+	found    []vpn.Leftover
+	err      error // the check itself failed
+	cleaned  bool  // fixes were applied automatically
+	cleanErr error
+	// dnsRestored holds the servers recorded before Start once the DNS
+	// configuration was found back as it was
+	dnsRestored []string
+}
+
+// cleanupMsg reports confirmed cleanup of leftovers.
+type cleanupMsg struct {
+	count int
+	err   error
+}
+
+// opTiming is how long a successful operation took, compared with its recent
+// runs.
+type opTiming struct {
+	total  time.Duration
+	phases string        // e.g. "exec 5.1s, verify 1.3s"
+	usual  time.Duration // p90 of the earlier runs
+	slow   bool          // more than twice the usual duration
+}
+
+// finishTiming stops timer and, for a successful operation, records the
+// duration in the state file and compares it with the earlier runs. Failed
+// runs aren't recorded: timeouts would skew the history.
+func finishTiming(operation string, timer *vpn.Timer, err error) (*opTiming, error) {
+	total := timer.Stop()
+	if err != nil {
+		return nil, nil
+	}
+	history, stateErr := state.RecordTiming(operation, total)
+	usual, slow := timer.Usual(history)
+	return &opTiming{total: total, phases: timer.String(), usual: usual, slow: slow}, stateErr
+}
+
+// took renders the timing for a completion log entry: " in 6.4s", plus a
+// hint when the run was unusually slow.
+func (t *opTiming) took() string {
+	if t == nil {
+		return ""
+	}
+	text := " in " + vpn.FormatDuration(t.total)
+	if t.slow {
+		text += fmt.Sprintf(" (%s) — slower than usual (%s); network or sudo prompt delays?", t.phases, vpn.FormatDuration(t.usual))
+	}
+	return text
+}
+
+// wgOutputMsg carries one line of wg-quick output from a running operation.
+// stream is the channel the rest of the operation's messages arrive on.
+type wgOutputMsg struct {
+	operation string
+	line      string
+	stream    <-chan tea.Msg
+}
+
+// stepMsg is a step of the running operation starting or finishing.
+type stepMsg struct {
+	event  ops.StepEvent
+	stream <-chan tea.Msg
+}
+
+// stepFunc reports the steps of a streamed operation.
+type stepFunc func(ops.StepEvent)
+
+// run runs fn as the step name, reporting it started and then done or
+// failed with fn's error.
+func (report stepFunc) run(name string, fn func() error) error {
+	report(ops.StepEvent{Step: name, Status: ops.StepRunning})
+	err := fn()
+	status := ops.StepDone
+	if err != nil {
+		status = ops.StepFailed
+	}
+	report(ops.StepEvent{Step: name, Status: status, Err: err})
+	return err
+}
+
+// streamOperation runs op in the background, forwarding its wg-quick output
+// as wgOutputMsgs and its steps as stepMsgs, followed by the final message op
+// returns. The operation is cancelled with parent, or if it exceeds
+// vpnOperationTimeout.
+func streamOperation(parent context.Context, op func(ctx context.Context, out vpn.OutputFunc, step stepFunc) tea.Msg) tea.Cmd {
+	stream := make(chan tea.Msg, 64)
+	go func() {
+		defer close(stream)
+		ctx, cancel := context.WithTimeout(parent, vpnOperationTimeout)
+		defer cancel()
+		result := op(ctx, func(operation, line string) {
+			stream <- wgOutputMsg{operation: operation, line: line, stream: stream}
+		}, func(event ops.StepEvent) {
+			stream <- stepMsg{event: event, stream: stream}
+		})
+		stream <- result
+	}()
+	return waitForStream(stream)
+}
+
+func waitForStream(stream <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-stream
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// Step names of a Start; see startSteps.
+const verifyStep = "Verify handshake"
+
+func stopStep(env vpn.Environment) string { return "Stop " + env.DisplayName() }
+
+func startStep(env vpn.Environment) string { return "Start " + env.DisplayName() }
+
+// startSteps declares the steps of a Start of env, a switch when from, the
+// connected environment, is set: stop it, start env, verify the handshake.
+func startSteps(from, env vpn.Environment) *ops.Steps {
+	if from == "" {
+		return ops.NewSteps(fmt.Sprintf("Starting %s VPN", env.DisplayName()), startStep(env), verifyStep)
+	}
+	return ops.NewSteps(fmt.Sprintf("Switching to %s VPN", env.DisplayName()), stopStep(from), startStep(env), verifyStep)
+}
+
+// startVPN starts env, stopping from first when it is connected; from is ""
+// when no tunnel is up, so the DNS configuration recorded for Stop to check
+// is the system's own.
+func startVPN(parent context.Context, svc vpn.Service, from, env vpn.Environment) tea.Cmd {
+	return streamOperation(parent, func(ctx context.Context, out vpn.OutputFunc, step stepFunc) tea.Msg {
+		if line := recordDNS(ctx, svc, env, from == ""); line != "" {
+			out("dns", line)
+		}
+		timer := vpn.NewTimer()
+		var err error
+		if from != "" {
+			err = step.run(stopStep(from), func() error {
+				return timer.Phase("stop", func() error {
+					return svc.StopWithOutput(ctx, out)
+				})
+			})
+		}
+		if err == nil {
+			err = step.run(startStep(env), func() error {
+				return timer.Phase("exec", func() error {
+					return svc.StartWithOutput(ctx, env, out)
+				})
+			})
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s: %v", vpnOperationTimeout, err)
+		} else if ctx.Err() == context.Canceled {
+			err = fmt.Errorf("cancelled: %v", err)
+		}
+		if err == nil {
+			err = step.run(verifyStep, func() error {
+				return timer.Phase("verify", func() error {
+					return verifyHandshake(ctx, svc, env)
+				})
+			})
+		}
+		operation := startOp(env).Key
+		timing, stateErr := finishTiming(operation, timer, err)
+		return vpnOperationMsg{
+			operation: operation,
+			env:       env,
+			success:   err == nil,
+			err:       err,
+			stateErr:  stateErr,
+			timing:    timing,
+		}
+	})
+}
+
+// stopInterfaceStep names the step of a conflict resolution stopping one
+// tunnel.
+func stopInterfaceStep(interfaceName string) string { return "Stop " + interfaceName }
+
+// keepTunnel stops every tunnel but the one on kept, one step each. The
+// tunnels are listed again first, so one that went down on its own
+// meanwhile is left alone.
+func keepTunnel(parent context.Context, svc vpn.Service, kept string) tea.Cmd {
+	return streamOperation(parent, func(ctx context.Context, out vpn.OutputFunc, step stepFunc) tea.Msg {
+		tunnels, err := svc.Tunnels(ctx)
+		for _, tunnel := range tunnels {
+			if err != nil {
+				break
+			}
+			if tunnel.Interface == kept {
+				continue
+			}
+			name := tunnel.Interface
+			err = step.run(stopInterfaceStep(name), func() error {
+				return svc.StopInterface(ctx, name, out)
+			})
+		}
+		return vpnOperationMsg{operation: keepOp(kept).Key, kept: kept, success: err == nil, err: err}
+	})
+}
+
+// recordDNS saves the DNS configuration before a fresh Start (a switch would
+// record the previous tunnel's) and describes how env's config changes it.
+func recordDNS(ctx context.Context, svc vpn.Service, env vpn.Environment, fresh bool) string {
+	var before *vpn.DNSSnapshot
+	if fresh {
+		snapshot, err := svc.SnapshotDNS(ctx)
+		if err != nil {
+			return fmt.Sprintf("could not record the current DNS: %v", err)
+		}
+		if err := state.Update(func(s *state.State) { s.DNSBefore = snapshot }); err != nil {
+			return fmt.Sprintf("could not save the current DNS: %v", err)
+		}
+		before = snapshot
+	} else if st, err := state.Load(); err == nil {
+		before = st.DNSBefore
+	}
+	after, _ := svc.TunnelDNS(env)
+	return dnsChange(before, after)
+}
+
+// dnsChange reads e.g. "DNS will change: 192.168.1.1 → 169.254.169.254";
+// "" when the config sets no DNS.
+func dnsChange(before *vpn.DNSSnapshot, after []string) string {
+	if len(after) == 0 {
+		return ""
+	}
+	from := "unknown"
+	if before != nil && len(before.Servers()) > 0 {
+		from = strings.Join(before.Servers(), ", ")
+	}
+	return fmt.Sprintf("DNS will change: %s → %s", from, strings.Join(after, ", "))
+}
+
+// verifyHandshake checks that a freshly started tunnel handshakes. When it
+// doesn't, the network is probed and the likely cause appended to the error.
+func verifyHandshake(ctx context.Context, svc vpn.Service, env vpn.Environment) error {
+	verifyCtx, cancel := context.WithTimeout(ctx, handshakeVerifyTimeout)
+	status, err := svc.VerifyHandshake(verifyCtx, env)
+	cancel()
+	if err == nil {
+		return nil
+	}
+
+	endpoint := ""
+	if status != nil {
+		endpoint = status.Endpoint
+	}
+	failure := fmt.Sprintf("tunnel is up but no handshake with the gateway within %s", handshakeVerifyTimeout)
+	if endpoint == "" {
+		return fmt.Errorf("%s", failure)
+	}
+	if hint := probe.NewUDPProber().Diagnose(ctx, endpoint).Hint(); hint != "" {
+		failure = fmt.Sprintf("%s — %s", failure, hint)
+	} else {
+		failure = fmt.Sprintf("%s (%s)", failure, endpoint)
+	}
+	// A local firewall dropping the traffic looks like an unreachable gateway
+	if _, findings, err := svc.CheckFirewall(ctx, vpn.EndpointPort(endpoint)); err == nil && len(findings) > 0 {
+		failure = fmt.Sprintf("%s — firewall may be blocking it: %s", failure, findings[0])
+	}
+	return fmt.Errorf("%s", failure)
+}
+
+// stopVPN brings the tunnel down and, unless cleanup is "off", checks for
+// DNS settings and routes it left behind ("auto" also removes them).
+func stopVPN(parent context.Context, svc vpn.Service, cleanup string) tea.Cmd {
+	return streamOperation(parent, func(ctx context.Context, out vpn.OutputFunc, _ stepFunc) tea.Msg {
+		timer := vpn.NewTimer()
+		err := timer.Phase("exec", func() error {
+			return svc.StopWithOutput(ctx, out)
+		})
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s: %v", vpnOperationTimeout, err)
+		} else if ctx.Err() == context.Canceled {
+			err = fmt.Errorf("cancelled: %v", err)
+		}
+		timing, stateErr := finishTiming(stopOp.Key, timer, err)
+		msg := vpnOperationMsg{
+			operation: stopOp.Key,
+			success:   err == nil,
+			err:       err,
+			stateErr:  stateErr,
+			timing:    timing,
+		}
+		if err == nil && cleanup != "off" {
+			check := &leftoverCheck{}
+			check.found, check.err = svc.Leftovers(ctx)
+			if st, stErr := state.Load(); stErr == nil && st.DNSBefore != nil && check.err == nil {
+				var dns []vpn.Leftover
+				dns, check.err = svc.VerifyDNS(ctx, st.DNSBefore)
+				check.found = append(check.found, dns...)
+				if check.err == nil && len(dns) == 0 {
+					check.dnsRestored = st.DNSBefore.Servers()
+				}
+			}
+			if cleanup == "auto" && len(check.found) > 0 {
+				check.cleaned = true
+				check.cleanErr = svc.CleanUp(ctx, check.found)
+			}
+			msg.leftovers = check
+		}
+		return msg
+	})
+}
+
+// windowsTunnel runs a Start (env set) or a Stop (env "") through WireGuard
+// for Windows, for WSL where wg-quick would only change WSL's own namespace.
+// A Start first removes the other profile's tunnel, as wg-quick's would.
+func windowsTunnel(host platform.Context, env vpn.Environment, operation string) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), vpnOperationTimeout)
+		defer cancel()
+		var err error
+		if env != "" {
+			for _, other := range vpn.Environments() {
+				if other != env {
+					host.WindowsDown(ctx, other.Interface()) // most likely not installed
+				}
+			}
+			_, err = host.WindowsUp(ctx, core.InstalledPath(core.ConfigFile(env)))
+		} else {
+			// Which one runs on Windows isn't visible from here: remove both
+			stopped := false
+			for _, other := range vpn.Environments() {
+				if _, downErr := host.WindowsDown(ctx, other.Interface()); downErr == nil {
+					stopped = true
+				} else if err == nil {
+					err = downErr
+				}
+			}
+			if stopped {
+				err = nil
+			}
+		}
+		return vpnOperationMsg{operation: operation, env: env, success: err == nil, err: err}
+	}
+}
+
+func cleanUpLeftovers(svc vpn.Service, leftovers []vpn.Leftover) tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), vpnOperationTimeout)
+		defer cancel()
+		return cleanupMsg{count: len(leftovers), err: svc.CleanUp(ctx, leftovers)}
+	}
+}
+
+// stopOp is the Stop menu item's operation; its key is the operation name
+// stopVPN reports.
+var stopOp = ops.Op{Key: "stop", Name: "stopping VPN"}
+
+// requestTeardown requests op, a Stop or the Start of to, asking first when
+// it takes down the tunnel that is up and confirm_disconnect is on. No is the
+// default answer. Starting the environment already up asks nothing.
+func (m *model) requestTeardown(op ops.Op, to vpn.Environment) tea.Cmd {
+	if !m.settings.ConfirmDisconnect || m.status == nil || !m.status.Connected || m.status.Environment == to {
+		return m.requestOp(op)
+	}
+	from := m.status.Environment
+	question := fmt.Sprintf("Stop %s VPN? %s goes down.", from.DisplayName(), from.Interface())
+	if to != "" {
+		question = fmt.Sprintf("Switch from %s to %s? %s goes down.", from.DisplayName(), to.DisplayName(), from.Interface())
+	}
+	m.confirm = &confirmPrompt{question: question, op: &op}
+	return nil
+}
+
+// startOp is the operation starting env, keyed like startVPN reports it.
+func startOp(env vpn.Environment) ops.Op {
+	return ops.Op{Key: "start_" + string(env), Name: "starting " + env.DisplayName()}
+}
+
+// keepOp resolves a conflict by stopping every tunnel but the one on
+// interfaceName.
+func keepOp(interfaceName string) ops.Op {
+	return ops.Op{Key: "keep_" + interfaceName, Name: "keeping " + interfaceName}
+}
+
+// opItem is the menu item an operation was started from: a profile's
+// Start, or Stop for the others.
+func (m model) opItem(op ops.Op) int {
+	stop := 0
+	for i, entry := range m.menu {
+		switch {
+		case entry.action == menuStart && startOp(entry.env).Key == op.Key:
+			return i
+		case entry.action == menuStop:
+			stop = i
+		}
+	}
+	return stop
+}
+
+// requestOp runs a Start or Stop now, or queues it behind the running one.
+// Other operations don't go through the queue, so while one of those runs
+// the request is refused. A Start of a profile with an MFA gate asks for
+// the one-time code first.
+func (m *model) requestOp(op ops.Op) tea.Cmd {
+	if _, _, running := m.opQueue.Running(); m.loading && !running {
+		m.message = "⏳ busy: wait for the running operation to finish"
+		return nil
+	}
+	if gate := m.mfaGate(op); gate != nil {
+		m.mfa = &mfaPrompt{gate: *gate, op: op}
+		m.message = ""
+		return nil
+	}
+	return m.submitOp(op)
+}
+
+// submitOp hands an operation that passed its checks to the queue.
+func (m *model) submitOp(op ops.Op) tea.Cmd {
+	if _, _, running := m.opQueue.Running(); m.loading && !running {
+		m.message = "⏳ busy: wait for the running operation to finish"
+		return nil
+	}
+	run, transitions, err := m.opQueue.Submit(op, time.Now())
+	m.logTransitions(transitions)
+	switch {
+	case err != nil:
+		m.message = "⏳ " + err.Error()
+		return nil
+	case !run:
+		running, _, _ := m.opQueue.Running()
+		m.message = fmt.Sprintf("⏳ %s queued after %s — press c to cancel", op.Name, running.Name)
+		return nil
+	}
+	return m.runOp(op)
+}
+
+// runOp starts an operation the queue has moved to running.
+func (m *model) runOp(op ops.Op) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.opCancel = cancel
+	if kept, ok := strings.CutPrefix(op.Key, "keep_"); ok {
+		return m.beginKeep(ctx, kept)
+	}
+	if !m.platform.CanManageTunnel() && m.platform.WindowsWireGuard != "" {
+		m.loading = true
+		env := vpn.Environment(strings.TrimPrefix(op.Key, "start_"))
+		if op.Key == stopOp.Key {
+			env = ""
+			m.message = "Stopping the VPN in WireGuard for Windows..."
+			m.beginOperation("Stop VPN (Windows)")
+		} else {
+			m.message = fmt.Sprintf("Starting %s in WireGuard for Windows...", env.DisplayName())
+			m.beginOperation(fmt.Sprintf("Start %s (Windows)", env.DisplayName()))
+		}
+		return windowsTunnel(m.platform, env, op.Key)
+	}
+	if op.Key == stopOp.Key {
+		m.loading = true
+		m.message = "Stopping VPN..."
+		m.beginOperation("Stop VPN")
+		return stopVPN(ctx, m.vpnSvc, m.settings.DisconnectCleanup)
+	}
+	return m.beginStart(ctx, vpn.Environment(strings.TrimPrefix(op.Key, "start_")))
+}
+
+// finishOp ends the queued operation operation reported on, if it is the
+// running one, and runs the one waiting behind it.
+func (m *model) finishOp(operation string, success bool) tea.Cmd {
+	if running, _, ok := m.opQueue.Running(); !ok || running.Key != operation {
+		return nil
+	}
+	if m.opCancel != nil {
+		m.opCancel()
+		m.opCancel = nil
+	}
+	next, transitions := m.opQueue.Finish(success, time.Now())
+	m.logTransitions(transitions)
+	if next == nil {
+		return nil
+	}
+	return m.runOp(*next)
+}
+
+// cancelOp drops the queued operation, or else cancels the running one.
+func (m *model) cancelOp() {
+	cancelRunning, transitions := m.opQueue.Cancel()
+	m.logTransitions(transitions)
+	if cancelRunning && m.opCancel != nil {
+		running, _, _ := m.opQueue.Running()
+		m.message = fmt.Sprintf("Cancelling %s...", running.Name)
+		m.opCancel()
+	} else if len(transitions) > 0 {
+		m.message = "Queued operation cancelled"
+	}
+}
+
+func (m *model) logTransitions(transitions []ops.Transition) {
+	for _, transition := range transitions {
+		m.addLogEntry(fmt.Sprintf("⏳ %s", transition))
+	}
+}
+
+// updateBusy handles keys while an operation runs: the menu can still be
+// navigated, Start and Stop are queued, and c cancels.
+func (m *model) updateBusy(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "c":
+		m.cancelOp()
+	case "e":
+		// A pick queues behind the running Start or Stop
+		if !m.showInputPanel {
+			m.switcher = &envSwitcher{}
+		}
+	case "up", "k":
+		if m.activePanel == 0 && m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.activePanel == 0 && m.cursor < len(m.menu)-1 {
+			m.cursor++
+		}
+	case "enter", " ":
+		if m.activePanel != 0 || m.showInputPanel {
+			break
+		}
+		if m.menuDisabled(m.cursor) {
+			m.explainDisabled(m.cursor)
+			break
+		}
+		switch entry := m.menu[m.cursor]; {
+		case entry.action == menuStart && !m.unconfigured(entry.env):
+			return m.requestTeardown(startOp(entry.env), entry.env)
+		case entry.action == menuStop:
+			return m.requestTeardown(stopOp, "")
+		}
+		if running, started, ok := m.opQueue.Running(); ok {
+			m.message = "⏳ " + (&ops.BusyError{Running: running, For: time.Since(started)}).Error()
+		} else {
+			m.message = "⏳ busy: wait for the running operation to finish"
+		}
+	}
+	return nil
+}
+
+// beginStart puts the model into the loading state and starts env; ctx
+// cancels it.
+func (m *model) beginStart(ctx context.Context, env vpn.Environment) tea.Cmd {
+	m.loading = true
+	var from vpn.Environment
+	if m.status != nil && m.status.Connected {
+		from = m.status.Environment
+		m.message = fmt.Sprintf("Switching to %s VPN...", env.DisplayName())
+		m.beginOperation(fmt.Sprintf("Switch to %s", env.DisplayName()))
+	} else {
+		m.message = fmt.Sprintf("Starting %s VPN...", env.DisplayName())
+		m.beginOperation(fmt.Sprintf("Start %s", env.DisplayName()))
+	}
+	m.steps = startSteps(from, env)
+	return startVPN(ctx, m.vpnSvc, from, env)
+}
+
+// beginKeep runs a conflict resolution keeping the tunnel on kept. Its
+// steps are the other tunnels the last status check saw.
+func (m *model) beginKeep(ctx context.Context, kept string) tea.Cmd {
+	m.loading = true
+	m.message = fmt.Sprintf("Keeping %s, stopping the other tunnels...", kept)
+	m.beginOperation("Keep " + kept)
+	var names []string
+	if m.status != nil && m.status.Connected {
+		for _, name := range append([]string{m.status.Interface}, m.status.ConflictingInterfaces...) {
+			if name != kept {
+				names = append(names, stopInterfaceStep(name))
+			}
+		}
+	}
+	m.steps = ops.NewSteps("Keeping "+kept, names...)
+	return keepTunnel(ctx, m.vpnSvc, kept)
+}
+
+// checkConflict opens the conflict screen when the status shows more than
+// one tunnel up, unless the user left this very set up before or an
+// operation runs. While the screen is open, every status check lists the
+// tunnels again, so one going down on its own is noticed.
+// tunnelUp reports whether env's tunnel is up, as the main one or one of
+// those up besides it.
+func (m model) tunnelUp(env vpn.Environment) bool {
+	if m.status == nil {
+		return false
+	}
+	if m.status.Connected && m.status.Environment == env {
+		return true
+	}
+	return slices.Contains(m.status.ConflictingInterfaces, env.Interface())
+}
+
+// finishSteps closes the step list of the running operation with its
+// outcome and logs its summary.
+func (m *model) finishSteps(err error) {
+	if m.steps == nil {
+		return
+	}
+	m.steps.Finish(err, time.Now())
+	m.logStep(fmt.Sprintf("📋 %s: %s", m.steps.Title, m.steps.Summary()))
+	m.steps = nil
+}
@@ -0,0 +1,383 @@
+package wgvpn
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const DefaultConfigDir = "/etc/wireguard"
+
+// OutputFunc receives wg-quick output line by line while an operation runs.
+// operation names the command ("up julo-prod"); line is already redacted.
+type OutputFunc func(operation, line string)
+
+// ConfigUpdater merges a user-supplied config into the installed configs.
+type ConfigUpdater func(ctx context.Context, userConfigPath string) error
+
+// Client manages the WireGuard tunnels through wg and wg-quick. Status is
+// read through wgctrl where it can be, and from wg's output otherwise.
+type Client struct {
+	runner    CommandRunner
+	devices   DeviceReader
+	updater   ConfigUpdater
+	configDir string
+	egress    map[Environment]string // pinned underlying interfaces
+}
+
+type Option func(*Client)
+
+// WithRunner sets the runner used for wg/wg-quick invocations.
+func WithRunner(runner CommandRunner) Option {
+	return func(c *Client) { c.runner = runner }
+}
+
+// WithConfigUpdater sets the function used by UpdateConfig.
+func WithConfigUpdater(updater ConfigUpdater) Option {
+	return func(c *Client) { c.updater = updater }
+}
+
+// WithConfigDir sets the directory holding the installed configs.
+func WithConfigDir(dir string) Option {
+	return func(c *Client) { c.configDir = dir }
+}
+
+func New(opts ...Option) *Client {
+	c := &Client{
+		runner:    ExecRunner{},
+		devices:   WgctrlReader{},
+		configDir: DefaultConfigDir,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Status reports the active JULO tunnel, if any. When more than one is up,
+// the first is reported and the others are named in ConflictingInterfaces:
+// a status check never changes anything. When wg can't be run, the error
+// wraps ErrWireGuardNotInstalled or ErrPermissionDenied where that is the
+// reason; a failing wg never reads as disconnected.
+func (c *Client) Status(ctx context.Context) (*ConnectionStatus, error) {
+	if devices, ok := c.juloDevices(); ok {
+		if len(devices) == 0 {
+			return &ConnectionStatus{Connected: false}, nil
+		}
+		status := deviceStatus(devices[0])
+		for _, device := range devices[1:] {
+			status.ConflictingInterfaces = append(status.ConflictingInterfaces, device.Name)
+		}
+		c.observeEgress(ctx, status)
+		return status, nil
+	}
+
+	names, err := c.juloInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return &ConnectionStatus{Connected: false}, nil
+	}
+	status, err := c.interfaceStatus(ctx, names[0])
+	if err == nil && status.Connected && len(names) > 1 {
+		status.ConflictingInterfaces = names[1:]
+	}
+	if err == nil {
+		c.observeEgress(ctx, status)
+	}
+	return status, err
+}
+
+// Tunnels reports every active JULO tunnel, in the order the kernel lists
+// them.
+func (c *Client) Tunnels(ctx context.Context) ([]*ConnectionStatus, error) {
+	var tunnels []*ConnectionStatus
+	if devices, ok := c.juloDevices(); ok {
+		for _, device := range devices {
+			tunnels = append(tunnels, deviceStatus(device))
+		}
+		return tunnels, nil
+	}
+	names, err := c.juloInterfaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		status, err := c.interfaceStatus(ctx, name)
+		if err != nil {
+			return tunnels, err
+		}
+		// Gone between the two wg calls
+		if status.Connected {
+			tunnels = append(tunnels, status)
+		}
+	}
+	return tunnels, nil
+}
+
+// juloInterfaces lists the JULO interfaces wg shows.
+func (c *Client) juloInterfaces(ctx context.Context) ([]string, error) {
+	output, err := c.runner.Output(ctx, "wg", "show")
+	if err != nil {
+		return nil, wgError(err)
+	}
+
+	var names []string
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		// Interfaces are the unindented "interface: <name>" lines; the label
+		// isn't checked, in case a wg somewhere prints it translated
+		line := scanner.Text()
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		_, interfaceName, ok := strings.Cut(line, ":")
+		interfaceName = strings.TrimSpace(interfaceName)
+		// Only consider JULO interfaces
+		if ok && isJuloInterface(interfaceName) {
+			names = append(names, interfaceName)
+		}
+	}
+	return names, nil
+}
+
+// wgFields are the labels of wg show's output that interfaceStatus reads or
+// expects.
+var wgFields = []string{"interface:", "public key:", "private key:", "listening port:", "peer:", "endpoint:", "allowed ips:", "latest handshake:", "transfer:"}
+
+func (c *Client) interfaceStatus(ctx context.Context, interfaceName string) (*ConnectionStatus, error) {
+	output, err := c.runner.Output(ctx, "wg", "show", interfaceName)
+	if err != nil {
+		// Other failures are the interface going away since it was listed
+		if err = wgError(err); errors.Is(err, ErrWireGuardNotInstalled) || errors.Is(err, ErrPermissionDenied) {
+			return nil, err
+		}
+		return &ConnectionStatus{Connected: false}, nil
+	}
+
+	status := &ConnectionStatus{
+		Connected:   true,
+		Environment: interfaceEnvironment(interfaceName),
+		Interface:   interfaceName,
+	}
+
+	recognized := false
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		for _, field := range wgFields {
+			recognized = recognized || strings.HasPrefix(line, field)
+		}
+
+		if port, ok := strings.CutPrefix(line, "listening port:"); ok {
+			value, err := strconv.Atoi(strings.TrimSpace(port))
+			if err != nil {
+				return nil, malformed(interfaceName, "listening port", port)
+			}
+			status.ListenPort = value
+		}
+
+		if mark, ok := strings.CutPrefix(line, "fwmark:"); ok {
+			value, err := strconv.ParseUint(strings.TrimSpace(mark), 0, 32)
+			if err != nil {
+				return nil, malformed(interfaceName, "fwmark", mark)
+			}
+			status.fwmark = int(value)
+		}
+
+		if strings.HasPrefix(line, "endpoint:") {
+			status.Endpoint = strings.TrimSpace(strings.TrimPrefix(line, "endpoint:"))
+		}
+
+		if strings.HasPrefix(line, "latest handshake:") {
+			handshakeStr := strings.TrimSpace(strings.TrimPrefix(line, "latest handshake:"))
+			if handshakeStr != "" && handshakeStr != "0" {
+				t, err := parseHandshakeTime(handshakeStr)
+				if err != nil {
+					return nil, malformed(interfaceName, "latest handshake", handshakeStr)
+				}
+				status.LastSeen = &t
+			}
+		}
+
+		if strings.HasPrefix(line, "transfer:") {
+			transferStr := strings.TrimSpace(strings.TrimPrefix(line, "transfer:"))
+			received, sent, ok := strings.Cut(transferStr, ",")
+			if !ok {
+				return nil, malformed(interfaceName, "transfer", transferStr)
+			}
+			rx, rxErr := parseBytes(received)
+			tx, txErr := parseBytes(sent)
+			if rxErr != nil || txErr != nil {
+				return nil, malformed(interfaceName, "transfer", transferStr)
+			}
+			status.BytesRx, status.BytesTx = rx, tx
+		}
+	}
+
+	// wg succeeded, so the interface is there even if its output is in a
+	// form this doesn't know
+	if !recognized {
+		status.Note = "details unavailable (unrecognized wg output)"
+	}
+
+	return status, nil
+}
+
+// Connect brings up the tunnel for env, stopping any other JULO tunnel first.
+func (c *Client) Connect(ctx context.Context, env Environment) error {
+	return c.ConnectWithOutput(ctx, env, nil)
+}
+
+// ConnectWithOutput is Connect, streaming wg-quick's output to out as it runs.
+func (c *Client) ConnectWithOutput(ctx context.Context, env Environment, out OutputFunc) error {
+	// First, check if any VPN is currently running and stop it
+	status, err := c.Status(ctx)
+	if err == nil && status.Connected {
+		if stopErr := c.DisconnectWithOutput(ctx, out); stopErr != nil {
+			return fmt.Errorf("failed to stop current VPN (%s): %v", status.Interface, stopErr)
+		}
+	}
+
+	// A pinned tunnel gets its endpoint route first, so not even the first
+	// handshake leaves through another interface
+	if err := c.pinEgress(ctx, out, env); err != nil {
+		return err
+	}
+	// Known leftovers of a half-up attempt are cleaned up and retried once
+	if err := c.up(ctx, out, env.Interface()); err != nil {
+		c.unpinEgress(ctx, out, env.Interface())
+		return err
+	}
+	return nil
+}
+
+// Disconnect brings down the active JULO tunnels, all of them when more
+// than one is up. It is a no-op when nothing is connected.
+func (c *Client) Disconnect(ctx context.Context) error {
+	return c.DisconnectWithOutput(ctx, nil)
+}
+
+// DisconnectWithOutput is Disconnect, streaming wg-quick's output to out.
+func (c *Client) DisconnectWithOutput(ctx context.Context, out OutputFunc) error {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !status.Connected {
+		return nil
+	}
+
+	interfaceName := status.Interface
+	if interfaceName == "" {
+		// Fallback: try every known interface
+		for _, env := range environments {
+			if _, err := c.wgQuick(ctx, out, "down", env.Interface()); err == nil {
+				return c.unpinEgress(ctx, out, env.Interface())
+			}
+		}
+		return fmt.Errorf("no active VPN interfaces found to stop")
+	}
+
+	var firstErr error
+	for _, name := range append([]string{interfaceName}, status.ConflictingInterfaces...) {
+		if err := c.DisconnectInterface(ctx, name, out); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// DisconnectInterface brings down one JULO tunnel by its interface name,
+// leaving any other up, and removes its pinned egress route.
+func (c *Client) DisconnectInterface(ctx context.Context, interfaceName string, out OutputFunc) error {
+	output, err := c.wgQuick(ctx, out, "down", interfaceName)
+	if err != nil {
+		return fmt.Errorf("wg-quick down %s failed: %v\nOutput: %s", interfaceName, err, RedactText(string(output)))
+	}
+	return c.unpinEgress(ctx, out, interfaceName)
+}
+
+// wgQuick runs "wg-quick <action> <iface>", streaming redacted output to out
+// when one is given.
+func (c *Client) wgQuick(ctx context.Context, out OutputFunc, action, interfaceName string) ([]byte, error) {
+	if out == nil {
+		return c.runner.CombinedOutput(ctx, "wg-quick", action, interfaceName)
+	}
+	operation := action + " " + interfaceName
+	return c.runner.Stream(ctx, func(line string) {
+		out(operation, Redact(line))
+	}, "wg-quick", action, interfaceName)
+}
+
+// UpdateConfig merges a user config into the installed configs using the
+// configured ConfigUpdater.
+func (c *Client) UpdateConfig(ctx context.Context, userConfigPath string) error {
+	if userConfigPath == "" {
+		return fmt.Errorf("user config file path is required")
+	}
+	if c.updater == nil {
+		return fmt.Errorf("no config updater configured")
+	}
+	return c.updater(ctx, userConfigPath)
+}
+
+// Config returns the installed config for env with key material hidden.
+func (c *Client) Config(ctx context.Context, env Environment) (string, error) {
+	configPath := filepath.Join(c.configDir, env.Interface()+".conf")
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read config file %s: %v", configPath, err)
+	}
+
+	return SanitizeConfig(string(content)), nil
+}
+
+// keyFields are the config keys holding key material, which SanitizeConfig
+// hides whatever their case: wg-quick reads "privatekey" as well.
+var keyFields = []string{"PrivateKey", "PresharedKey", "PublicKey"}
+
+// SanitizeConfig hides key material and splits AllowedIPs one per line.
+// Keys are matched as wg-quick matches them, case-insensitively, and every
+// other line still goes through Redact, so a key in a line it doesn't
+// recognize is hidden too.
+func SanitizeConfig(content string) string {
+	lines := strings.Split(content, "\n")
+	var filteredLines []string
+
+	for _, line := range lines {
+		trimmedLine := strings.TrimSpace(line)
+
+		// Skip empty lines and comments
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmedLine, "=")
+		key = strings.TrimSpace(key)
+		switch {
+		case ok && slices.ContainsFunc(keyFields, func(field string) bool { return strings.EqualFold(key, field) }):
+			// Show field name but hide the actual key
+			filteredLines = append(filteredLines, fmt.Sprintf("%s = [HIDDEN]", key))
+		case ok && strings.EqualFold(key, "AllowedIPs"):
+			// Format AllowedIPs with one IP per indented line for readability
+			filteredLines = append(filteredLines, key+" =")
+			for _, ip := range strings.Split(strings.TrimSpace(value), ",") {
+				filteredLines = append(filteredLines, fmt.Sprintf("  %s", strings.TrimSpace(ip)))
+			}
+		default:
+			filteredLines = append(filteredLines, Redact(trimmedLine))
+		}
+	}
+
+	return strings.Join(filteredLines, "\n")
+}
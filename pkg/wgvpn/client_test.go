@@ -0,0 +1,41 @@
+package wgvpn
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeConfigHidesKeysInAnyCase(t *testing.T) {
+	const secret = "yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk="
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"canonical", "PrivateKey = " + secret, "PrivateKey = [HIDDEN]"},
+		{"lowercase", "privatekey = " + secret, "privatekey = [HIDDEN]"},
+		{"no spaces", "PRESHAREDKEY=" + secret, "PRESHAREDKEY = [HIDDEN]"},
+		{"inline comment", "PublicKey = " + secret + " # gateway", "PublicKey = [HIDDEN]"},
+		{"key in an unknown line", "PostUp = echo " + secret, "PostUp = echo [HIDDEN]"},
+		{"other settings stay", "Address = 10.9.0.2/32", "Address = 10.9.0.2/32"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SanitizeConfig("[Interface]\n" + tt.line + "\n")
+			if got != "[Interface]\n"+tt.want {
+				t.Errorf("SanitizeConfig(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+			if strings.Contains(got, secret) {
+				t.Errorf("SanitizeConfig(%q) shows the key", tt.line)
+			}
+		})
+	}
+}
+
+func TestSanitizeConfigSplitsAllowedIPs(t *testing.T) {
+	got := SanitizeConfig("[Peer]\n# routes\nallowedips = 10.0.0.0/8, 172.16.0.0/12\n")
+	want := "[Peer]\nallowedips =\n  10.0.0.0/8\n  172.16.0.0/12"
+	if got != want {
+		t.Errorf("SanitizeConfig() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package wgvpn
+
+import (
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// KnownConfigDirs are every directory some platform's wg-quick reads
+// configs from, for finding configs installed where wg-quick won't look.
+var KnownConfigDirs = []string{DefaultConfigDir, "/usr/local/etc/wireguard", "/opt/homebrew/etc/wireguard"}
+
+var (
+	// CONFIG_SEARCH_PATHS=( /etc/wireguard /usr/local/etc/wireguard )
+	searchPathsPattern = regexp.MustCompile(`CONFIG_SEARCH_PATHS=\(([^)]*)\)`)
+	// The Linux script has a single directory:
+	// CONFIG_FILE="/etc/wireguard/$CONFIG_FILE.conf"
+	configFilePattern = regexp.MustCompile(`CONFIG_FILE="(/[^"$]+)/\$CONFIG_FILE\.conf"`)
+)
+
+// ConfigSearchPaths returns the directories wg-quick looks for configs in,
+// in the order it tries them. script is the wg-quick script ("" when it
+// couldn't be read); the paths it declares win over the ones goos's wg-quick
+// ships with, as packagers patch them (Homebrew adds its prefix).
+func ConfigSearchPaths(goos, script string) []string {
+	if match := searchPathsPattern.FindStringSubmatch(script); match != nil {
+		var dirs []string
+		for _, dir := range strings.Fields(match[1]) {
+			dir = strings.Trim(dir, `"'`)
+			// Paths computed at run time, like $(brew --prefix), can't be
+			// resolved here
+			if strings.HasPrefix(dir, "/") && !strings.Contains(dir, "$") {
+				dirs = append(dirs, dir)
+			}
+		}
+		if len(dirs) > 0 {
+			return dirs
+		}
+	}
+	if match := configFilePattern.FindStringSubmatch(script); match != nil {
+		return []string{match[1]}
+	}
+
+	switch goos {
+	case "darwin":
+		return []string{DefaultConfigDir, "/usr/local/etc/wireguard", "/opt/homebrew/etc/wireguard"}
+	case "freebsd", "openbsd", "netbsd", "dragonfly":
+		return []string{"/usr/local/etc/wireguard"}
+	}
+	return []string{DefaultConfigDir}
+}
+
+// ResolveConfigDir picks the directory to keep configs in from wg-quick's
+// search paths: the first one that exists, or the first one when none does
+// yet.
+func ResolveConfigDir(searchPaths []string, isDir func(string) bool) string {
+	for _, dir := range searchPaths {
+		if isDir(dir) {
+			return dir
+		}
+	}
+	if len(searchPaths) == 0 {
+		return DefaultConfigDir
+	}
+	return searchPaths[0]
+}
+
+// DetectConfigSearchPaths returns the search paths of the wg-quick on PATH.
+func DetectConfigSearchPaths() []string {
+	script := ""
+	if path, err := exec.LookPath("wg-quick"); err == nil {
+		if content, err := os.ReadFile(path); err == nil {
+			script = string(content)
+		}
+	}
+	return ConfigSearchPaths(runtime.GOOS, script)
+}
+
+// DetectConfigDir returns the directory wg-quick on this machine reads
+// configs from.
+func DetectConfigDir() string {
+	return ResolveConfigDir(DetectConfigSearchPaths(), func(dir string) bool {
+		info, err := os.Stat(dir)
+		return err == nil && info.IsDir()
+	})
+}
@@ -0,0 +1,97 @@
+package wgvpn
+
+import (
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// DeviceReader reads the WireGuard devices directly from the kernel
+// (netlink) or a userspace implementation's socket, as wgctrl does. An error
+// means the devices can't be read that way here (no netlink, or not
+// privileged enough), and the client falls back to running wg.
+type DeviceReader interface {
+	Devices() ([]*wgtypes.Device, error)
+}
+
+// WithDeviceReader sets how devices are read; nil always uses wg.
+func WithDeviceReader(reader DeviceReader) Option {
+	return func(c *Client) { c.devices = reader }
+}
+
+// WgctrlReader reads devices with wgctrl, opening a client for every read.
+// Where wgctrl can't be built (FreeBSD without cgo) it always fails, and
+// wg is used instead.
+type WgctrlReader struct{}
+
+// juloDevices returns the JULO devices, in the order the kernel lists them
+// like wg does. ok is false when they couldn't be read, and wg has to be
+// asked instead.
+func (c *Client) juloDevices() (devices []*wgtypes.Device, ok bool) {
+	if c.devices == nil {
+		return nil, false
+	}
+	all, err := c.devices.Devices()
+	if err != nil {
+		return nil, false
+	}
+	for _, device := range all {
+		if isJuloInterface(device.Name) {
+			devices = append(devices, device)
+		}
+	}
+	return devices, true
+}
+
+// deviceStatus describes an up device. With several peers the transfer
+// counters are summed and the endpoint is the one of the peer with the
+// latest handshake.
+func deviceStatus(device *wgtypes.Device) *ConnectionStatus {
+	status := &ConnectionStatus{
+		Connected:   true,
+		Environment: interfaceEnvironment(device.Name),
+		Interface:   device.Name,
+		ListenPort:  device.ListenPort,
+		fwmark:      device.FirewallMark,
+	}
+	for _, peer := range device.Peers {
+		status.BytesRx += uint64(peer.ReceiveBytes)
+		status.BytesTx += uint64(peer.TransmitBytes)
+		if peer.Endpoint != nil && status.Endpoint == "" {
+			status.Endpoint = peer.Endpoint.String()
+		}
+		if peer.LastHandshakeTime.IsZero() {
+			continue
+		}
+		if status.LastSeen == nil || peer.LastHandshakeTime.After(*status.LastSeen) {
+			handshake := peer.LastHandshakeTime
+			status.LastSeen = &handshake
+			if peer.Endpoint != nil {
+				status.Endpoint = peer.Endpoint.String()
+			}
+		}
+	}
+	return status
+}
+
+// isJuloInterface reports whether name is one of the app's interfaces.
+func isJuloInterface(name string) bool {
+	return strings.HasPrefix(name, "julo-")
+}
+
+// interfaceEnvironment maps an interface name to its environment, "" for
+// one that belongs to none.
+func interfaceEnvironment(name string) Environment {
+	for _, env := range environments {
+		if env.Interface() == name {
+			return env
+		}
+	}
+	switch {
+	case strings.Contains(name, "nonprod"):
+		return NonProduction
+	case strings.Contains(name, "prod"):
+		return Production
+	}
+	return ""
+}
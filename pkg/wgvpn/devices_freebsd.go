@@ -0,0 +1,12 @@
+package wgvpn
+
+import (
+	"errors"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// wgctrl's FreeBSD backend needs cgo, which release builds go without.
+func (WgctrlReader) Devices() ([]*wgtypes.Device, error) {
+	return nil, errors.New("reading devices through wgctrl is not supported on FreeBSD")
+}
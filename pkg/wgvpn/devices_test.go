@@ -0,0 +1,106 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// fakeDevices stands in for wgctrl.
+type fakeDevices struct {
+	devices []*wgtypes.Device
+	err     error
+}
+
+func (f fakeDevices) Devices() ([]*wgtypes.Device, error) {
+	return f.devices, f.err
+}
+
+func TestStatusMapsInterfacesToEnvironments(t *testing.T) {
+	handshake := time.Date(2026, 5, 10, 14, 22, 31, 0, time.UTC)
+	peer := wgtypes.Peer{
+		Endpoint:          &net.UDPAddr{IP: net.ParseIP("34.101.166.184"), Port: 51820},
+		LastHandshakeTime: handshake,
+		ReceiveBytes:      1024,
+		TransmitBytes:     2048,
+	}
+	tests := []struct {
+		name        string
+		devices     []string
+		env         Environment
+		iface       string
+		connected   bool
+		conflicting []string
+	}{
+		{"prod", []string{"julo-prod"}, Production, "julo-prod", true, nil},
+		{"nonprod", []string{"julo-nonprod"}, NonProduction, "julo-nonprod", true, nil},
+		{"other tunnels ignored", []string{"wg0", "julo-nonprod", "tailscale0"}, NonProduction, "julo-nonprod", true, nil},
+		{"both up", []string{"julo-prod", "julo-nonprod"}, Production, "julo-prod", true, []string{"julo-nonprod"}},
+		{"none of ours", []string{"wg0"}, "", "", false, nil},
+		{"nothing up", nil, "", "", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var devices []*wgtypes.Device
+			for _, name := range tt.devices {
+				devices = append(devices, &wgtypes.Device{Name: name, ListenPort: 41414, Peers: []wgtypes.Peer{peer}})
+			}
+			runner := &fakeRunner{}
+			client := New(WithRunner(runner), WithDeviceReader(fakeDevices{devices: devices}))
+
+			status, err := client.Status(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Connected != tt.connected || status.Environment != tt.env || status.Interface != tt.iface {
+				t.Errorf("Status() = connected %v, %q on %q; want %v, %q on %q", status.Connected, status.Environment, status.Interface, tt.connected, tt.env, tt.iface)
+			}
+			if !slices.Equal(status.ConflictingInterfaces, tt.conflicting) {
+				t.Errorf("ConflictingInterfaces = %v, want %v", status.ConflictingInterfaces, tt.conflicting)
+			}
+			if tt.connected {
+				if status.LastSeen == nil || !status.LastSeen.Equal(handshake) || status.BytesRx != 1024 || status.BytesTx != 2048 ||
+					status.Endpoint != "34.101.166.184:51820" || status.ListenPort != 41414 {
+					t.Errorf("Status() = %+v, want the device's data", status)
+				}
+			}
+			if len(runner.calls) != 0 {
+				t.Errorf("ran %v, want no command", runner.calls)
+			}
+		})
+	}
+}
+
+func TestStatusFallsBackToWg(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"wg show":              "interface: julo-nonprod\n  public key: x\n",
+		"wg show julo-nonprod": "interface: julo-nonprod\n  listening port: 41414\n\npeer: y\n  endpoint: 34.128.85.147:51820\n  transfer: 1.00 KiB received, 2.00 KiB sent\n",
+	}}
+	client := New(WithRunner(runner), WithDeviceReader(fakeDevices{err: errors.New("netlink: operation not permitted")}))
+
+	status, err := client.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Connected || status.Environment != NonProduction || status.ListenPort != 41414 || status.BytesRx != 1024 || status.BytesTx != 2048 {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestInterfaceEnvironment(t *testing.T) {
+	tests := map[string]Environment{
+		"julo-prod":    Production,
+		"julo-nonprod": NonProduction,
+		"wg0":          "",
+	}
+	for name, want := range tests {
+		if got := interfaceEnvironment(name); got != want {
+			t.Errorf("interfaceEnvironment(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
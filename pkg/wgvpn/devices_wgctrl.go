@@ -0,0 +1,17 @@
+//go:build !freebsd
+
+package wgvpn
+
+import (
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func (WgctrlReader) Devices() ([]*wgtypes.Device, error) {
+	client, err := wgctrl.New()
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return client.Devices()
+}
@@ -0,0 +1,193 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DNSSnapshot is the system's DNS configuration before a tunnel replaced
+// it, so it can be shown and checked once the tunnel is down.
+type DNSSnapshot struct {
+	Backend string `json:"backend"` // "systemd-resolved" or "resolv.conf"
+	// Links holds systemd-resolved's servers per link, "Global" for the
+	// global ones. Tunnel links are left out.
+	Links map[string][]string `json:"links,omitempty"`
+	// Nameservers are the nameserver lines of a plain /etc/resolv.conf
+	Nameservers []string `json:"nameservers,omitempty"`
+	// ResolvConf is that file's content, to restore it from
+	ResolvConf string    `json:"resolv_conf,omitempty"`
+	TakenAt    time.Time `json:"taken_at"`
+}
+
+// Servers lists the snapshot's DNS servers once each, global ones first.
+func (s *DNSSnapshot) Servers() []string {
+	if s.Backend != "systemd-resolved" {
+		return s.Nameservers
+	}
+	links := make([]string, 0, len(s.Links))
+	for link := range s.Links {
+		if link != "Global" {
+			links = append(links, link)
+		}
+	}
+	sort.Strings(links)
+	var servers []string
+	seen := map[string]bool{}
+	for _, link := range append([]string{"Global"}, links...) {
+		for _, server := range s.Links[link] {
+			if !seen[server] {
+				seen[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+	return servers
+}
+
+// SnapshotDNS records the current DNS configuration of systemd-resolved
+// ("resolvectl dns") or, without it, of /etc/resolv.conf.
+func SnapshotDNS(ctx context.Context, runner CommandRunner) (*DNSSnapshot, error) {
+	if output, err := runner.Output(ctx, "resolvectl", "dns"); err == nil {
+		return &DNSSnapshot{Backend: "systemd-resolved", Links: parseResolvectlDNS(string(output)), TakenAt: time.Now()}, nil
+	}
+	output, err := runner.Output(ctx, "cat", "/etc/resolv.conf")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS configuration: %v", err)
+	}
+	return &DNSSnapshot{
+		Backend:     "resolv.conf",
+		Nameservers: parseNameservers(string(output)),
+		ResolvConf:  string(output),
+		TakenAt:     time.Now(),
+	}, nil
+}
+
+// SnapshotDNS records the current DNS configuration with the client's
+// runner.
+func (c *Client) SnapshotDNS(ctx context.Context) (*DNSSnapshot, error) {
+	return SnapshotDNS(ctx, c.runner)
+}
+
+// TunnelDNS returns the DNS servers env's installed config sets.
+func (c *Client) TunnelDNS(env Environment) ([]string, error) {
+	tunnel, err := c.tunnelConfig(env.Interface())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return tunnel.DNS, err
+}
+
+// DNSRestored compares the DNS configuration once a tunnel is down with
+// snapshot. Every difference is a Leftover whose Fix puts the snapshot's
+// servers back. Links that went away since (another network) and a
+// different resolver manager aren't compared.
+func DNSRestored(ctx context.Context, runner CommandRunner, snapshot *DNSSnapshot) ([]Leftover, error) {
+	current, err := SnapshotDNS(ctx, runner)
+	if err != nil {
+		return nil, err
+	}
+	if current.Backend != snapshot.Backend {
+		return nil, nil
+	}
+
+	var leftovers []Leftover
+	if snapshot.Backend == "resolv.conf" {
+		if !sameServers(current.Nameservers, snapshot.Nameservers) {
+			leftovers = append(leftovers, Leftover{
+				Description: fmt.Sprintf("/etc/resolv.conf lists %s, was %s before connecting",
+					serverList(current.Nameservers), serverList(snapshot.Nameservers)),
+				// The content is an argument, never part of the script
+				Fix: []string{"sh", "-c", `printf '%s' "$1" > /etc/resolv.conf`, "sh", snapshot.ResolvConf},
+			})
+		}
+		return leftovers, nil
+	}
+
+	links := make([]string, 0, len(snapshot.Links))
+	for link := range snapshot.Links {
+		links = append(links, link)
+	}
+	sort.Strings(links)
+	for _, link := range links {
+		now, ok := current.Links[link]
+		if !ok || sameServers(now, snapshot.Links[link]) {
+			continue
+		}
+		leftover := Leftover{Description: fmt.Sprintf("systemd-resolved DNS of %s is %s, was %s before connecting",
+			link, serverList(now), serverList(snapshot.Links[link]))}
+		if link == "Global" {
+			leftover.Description += " (check /etc/systemd/resolved.conf)"
+		} else {
+			leftover.Fix = append([]string{"resolvectl", "dns", link}, snapshot.Links[link]...)
+		}
+		leftovers = append(leftovers, leftover)
+	}
+	return leftovers, nil
+}
+
+// VerifyDNS compares the DNS configuration with snapshot using the client's
+// runner.
+func (c *Client) VerifyDNS(ctx context.Context, snapshot *DNSSnapshot) ([]Leftover, error) {
+	return DNSRestored(ctx, c.runner, snapshot)
+}
+
+// parseResolvectlDNS reads "resolvectl dns" output:
+//
+//	Global:
+//	Link 2 (wlp3s0): 192.168.1.1
+//	Link 7 (julo-prod): 169.254.169.254
+func parseResolvectlDNS(output string) map[string][]string {
+	links := map[string][]string{}
+	for _, line := range strings.Split(output, "\n") {
+		scope, servers, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// IPv6 servers contain colons too; the scope never does
+		link := strings.TrimSpace(scope)
+		if lp, rp := strings.Index(link, "("), strings.LastIndex(link, ")"); lp >= 0 && rp > lp {
+			link = link[lp+1 : rp]
+		} else if link != "Global" {
+			continue
+		}
+		if strings.HasPrefix(link, "julo-") {
+			continue
+		}
+		links[link] = strings.Fields(servers)
+	}
+	return links
+}
+
+func parseNameservers(resolvConf string) []string {
+	var servers []string
+	for _, line := range strings.Split(resolvConf, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			servers = append(servers, fields[1])
+		}
+	}
+	return servers
+}
+
+func sameServers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func serverList(servers []string) string {
+	if len(servers) == 0 {
+		return "none"
+	}
+	return strings.Join(servers, ", ")
+}
@@ -0,0 +1,158 @@
+package wgvpn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+)
+
+const (
+	resolvectlBefore = "Global:\nLink 2 (wlp2s0): 192.168.1.1 2001:db8::53\nLink 3 (docker0):\n"
+	resolvConfBefore = "# Generated by NetworkManager\nsearch lan\nnameserver 192.168.1.1\nnameserver 1.1.1.1\n"
+)
+
+func TestSnapshotDNS(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"resolvectl dns": resolvectlBefore + "Link 7 (julo-prod): 10.80.0.2\n",
+	}}
+	snapshot, err := SnapshotDNS(context.Background(), runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Backend != "systemd-resolved" || snapshot.TakenAt.IsZero() {
+		t.Fatalf("SnapshotDNS() = %+v", snapshot)
+	}
+	// The tunnel's own link isn't the configuration to come back to
+	want := map[string][]string{"Global": {}, "wlp2s0": {"192.168.1.1", "2001:db8::53"}, "docker0": {}}
+	if len(snapshot.Links) != len(want) {
+		t.Fatalf("Links = %q, want %q", snapshot.Links, want)
+	}
+	for link, servers := range want {
+		if got, ok := snapshot.Links[link]; !ok || !slices.Equal(got, servers) {
+			t.Errorf("Links[%s] = %q, want %q", link, got, servers)
+		}
+	}
+	if got := snapshot.Servers(); !slices.Equal(got, []string{"192.168.1.1", "2001:db8::53"}) {
+		t.Errorf("Servers() = %q", got)
+	}
+}
+
+func TestSnapshotDNSResolvConf(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{"cat /etc/resolv.conf": resolvConfBefore},
+		errs:    map[string]error{"resolvectl dns": errors.New("exit status 1")},
+	}
+	snapshot, err := SnapshotDNS(context.Background(), runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Backend != "resolv.conf" || snapshot.ResolvConf != resolvConfBefore || !slices.Equal(snapshot.Servers(), []string{"192.168.1.1", "1.1.1.1"}) {
+		t.Errorf("SnapshotDNS() = %+v", snapshot)
+	}
+
+	runner.errs["cat /etc/resolv.conf"] = errors.New("permission denied")
+	if snapshot, err := SnapshotDNS(context.Background(), runner); err == nil {
+		t.Errorf("SnapshotDNS() = %+v without any configuration", snapshot)
+	}
+}
+
+// TestDNSRoundTrip snapshots the DNS configuration, keeps it in JSON as the
+// state file does, and verifies it against the configuration once the
+// tunnel is down.
+func TestDNSRoundTrip(t *testing.T) {
+	noResolved := map[string]error{"resolvectl dns": errors.New("exit status 1")}
+	tests := []struct {
+		name          string
+		before, after map[string]string
+		errs          map[string]error
+		want          []Leftover
+	}{
+		{
+			name:   "resolved restored",
+			before: map[string]string{"resolvectl dns": resolvectlBefore + "Link 7 (julo-prod): 10.80.0.2\n"},
+			after:  map[string]string{"resolvectl dns": resolvectlBefore},
+		},
+		{
+			name:   "resolved link kept the tunnel's server",
+			before: map[string]string{"resolvectl dns": resolvectlBefore},
+			after:  map[string]string{"resolvectl dns": "Global:\nLink 2 (wlp2s0): 10.80.0.2\nLink 3 (docker0):\n"},
+			want: []Leftover{{
+				Description: "systemd-resolved DNS of wlp2s0 is 10.80.0.2, was 192.168.1.1, 2001:db8::53 before connecting",
+				Fix:         []string{"resolvectl", "dns", "wlp2s0", "192.168.1.1", "2001:db8::53"},
+			}},
+		},
+		{
+			name:   "resolved global changed",
+			before: map[string]string{"resolvectl dns": resolvectlBefore},
+			after:  map[string]string{"resolvectl dns": "Global: 10.80.0.2\nLink 2 (wlp2s0): 192.168.1.1 2001:db8::53\nLink 3 (docker0):\n"},
+			want: []Leftover{{
+				Description: "systemd-resolved DNS of Global is 10.80.0.2, was none before connecting (check /etc/systemd/resolved.conf)",
+			}},
+		},
+		{
+			// Another network since: nothing to compare with
+			name:   "resolved link gone",
+			before: map[string]string{"resolvectl dns": resolvectlBefore},
+			after:  map[string]string{"resolvectl dns": "Global:\nLink 4 (enp0s31f6): 10.1.1.1\nLink 3 (docker0):\n"},
+		},
+		{
+			name:   "resolv.conf restored",
+			before: map[string]string{"cat /etc/resolv.conf": resolvConfBefore},
+			after:  map[string]string{"cat /etc/resolv.conf": resolvConfBefore},
+			errs:   noResolved,
+		},
+		{
+			name:   "resolv.conf kept the tunnel's server",
+			before: map[string]string{"cat /etc/resolv.conf": resolvConfBefore},
+			after:  map[string]string{"cat /etc/resolv.conf": "# Generated by wg-quick\nnameserver 10.80.0.2\n"},
+			errs:   noResolved,
+			want: []Leftover{{
+				Description: "/etc/resolv.conf lists 10.80.0.2, was 192.168.1.1, 1.1.1.1 before connecting",
+				Fix:         []string{"sh", "-c", `printf '%s' "$1" > /etc/resolv.conf`, "sh", resolvConfBefore},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			taken, err := SnapshotDNS(ctx, &fakeRunner{outputs: tt.before, errs: tt.errs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			saved, err := json.Marshal(taken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var snapshot DNSSnapshot
+			if err := json.Unmarshal(saved, &snapshot); err != nil {
+				t.Fatal(err)
+			}
+
+			client := New(WithRunner(&fakeRunner{outputs: tt.after, errs: tt.errs}))
+			leftovers, err := client.VerifyDNS(ctx, &snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(leftovers) != len(tt.want) {
+				t.Fatalf("VerifyDNS() = %q, want %q", descriptions(leftovers), descriptions(tt.want))
+			}
+			for i, leftover := range leftovers {
+				if leftover.Description != tt.want[i].Description || !slices.Equal(leftover.Fix, tt.want[i].Fix) {
+					t.Errorf("leftover %d = %+v, want %+v", i, leftover, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// A snapshot of another resolver manager can't be compared.
+func TestVerifyDNSOtherBackend(t *testing.T) {
+	snapshot := &DNSSnapshot{Backend: "resolv.conf", Nameservers: []string{"192.168.1.1"}}
+	runner := &fakeRunner{outputs: map[string]string{"resolvectl dns": "Global: 10.80.0.2\n"}}
+	leftovers, err := DNSRestored(context.Background(), runner, snapshot)
+	if err != nil || leftovers != nil {
+		t.Errorf("DNSRestored() = %v, %v; want nothing", leftovers, err)
+	}
+}
@@ -0,0 +1,22 @@
+// Package wgvpn controls the JULO WireGuard tunnels (julo-prod, julo-nonprod)
+// without any TUI dependencies, so other tools can make sure the VPN is up
+// before they run.
+//
+// All operations take a context and shell out through a CommandRunner, which
+// can be replaced (for example to prefix commands with sudo, or with a fake in
+// tests):
+//
+//	client := wgvpn.New(wgvpn.WithRunner(wgvpn.ExecRunner{Sudo: true}))
+//
+//	err := client.WithConnection(ctx, wgvpn.NonProduction, func(ctx context.Context) error {
+//		return runDeploy(ctx)
+//	})
+//
+// WithConnection connects to the environment, waits until a fresh handshake is
+// observed, runs the function and disconnects again (unless the tunnel was
+// already up beforehand, in which case it is left as it was).
+//
+// Status and Tunnels read the devices through wgctrl (netlink, or the
+// userspace socket) and only run wg when that isn't possible, e.g. without
+// the privileges netlink needs. WithDeviceReader replaces wgctrl.
+package wgvpn
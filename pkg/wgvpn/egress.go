@@ -0,0 +1,202 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// EgressStateDir is where a pinned egress route is recorded while its tunnel
+// is up, so Stop removes exactly the route Start added, even from another
+// run of the app.
+var EgressStateDir = "/run/tui-wireguard-vpn"
+
+// WithEgress pins tunnels to underlying interfaces, by environment: on a
+// machine with several uplinks (ethernet and a metered modem, say), the
+// tunnel's own encrypted traffic then always leaves through the one named.
+// Linux only; see EgressRoute.
+func WithEgress(egress map[Environment]string) Option {
+	return func(c *Client) { c.egress = egress }
+}
+
+// EgressRoute is the host route that pins a tunnel's endpoint to one
+// underlying interface. wg-quick marks the tunnel's own packets so they skip
+// its routing table and use the main one, where this /32 (or /128) is the
+// most specific route to the endpoint, so it holds for full and split
+// tunnels alike.
+type EgressRoute struct {
+	Endpoint netip.Addr
+	Device   string
+	Gateway  netip.Addr // invalid when the endpoint is on the device's link
+	Source   netip.Addr // the device's address the kernel picks, if any
+}
+
+// Spec is the route as "ip route" takes it, the same for adding and
+// deleting it.
+func (r EgressRoute) Spec() []string {
+	spec := []string{netip.PrefixFrom(r.Endpoint, r.Endpoint.BitLen()).String()}
+	if r.Gateway.IsValid() {
+		spec = append(spec, "via", r.Gateway.String())
+	}
+	spec = append(spec, "dev", r.Device)
+	if r.Source.IsValid() {
+		spec = append(spec, "src", r.Source.String())
+	}
+	return spec
+}
+
+// AddCommand installs the route, replacing any route to the endpoint
+// already in the main table.
+func (r EgressRoute) AddCommand() []string {
+	return append([]string{"ip", "route", "replace"}, r.Spec()...)
+}
+
+// DeleteCommand removes the route again.
+func (r EgressRoute) DeleteCommand() []string {
+	return append([]string{"ip", "route", "del"}, r.Spec()...)
+}
+
+// ParseEgressRoute builds the route pinning endpoint to device from the
+// output of "ip route get <endpoint> oif <device>":
+//
+//	203.0.113.7 via 192.168.1.1 dev enp3s0 src 192.168.1.10 uid 0
+//	    cache
+//
+// It fails when the kernel would send the traffic elsewhere, i.e. device has
+// no route to the endpoint.
+func ParseEgressRoute(endpoint netip.Addr, device, output string) (EgressRoute, error) {
+	route := EgressRoute{Endpoint: endpoint}
+	fields := strings.Fields(output)
+	for i := 0; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "via":
+			route.Gateway, _ = netip.ParseAddr(fields[i+1])
+		case "dev":
+			route.Device = fields[i+1]
+		case "src":
+			route.Source, _ = netip.ParseAddr(fields[i+1])
+		}
+	}
+	if route.Device != device {
+		return EgressRoute{}, fmt.Errorf("no route to %s through %s", endpoint, device)
+	}
+	return route, nil
+}
+
+// pinEgress adds the route keeping env's tunnel on its pinned interface,
+// before the tunnel comes up, and records it for unpinEgress. It does
+// nothing for a tunnel that isn't pinned.
+func (c *Client) pinEgress(ctx context.Context, out OutputFunc, env Environment) error {
+	device := c.egress[env]
+	if device == "" {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("binding %s to %s is only supported on Linux", env.Interface(), device)
+	}
+	endpoint, err := c.endpointAddr(ctx, env.Interface())
+	if err != nil {
+		return err
+	}
+	output, err := c.runner.CombinedOutput(ctx, "ip", "route", "get", endpoint.String(), "oif", device)
+	if err != nil {
+		return fmt.Errorf("no route to %s through %s: %s", endpoint, device, strings.TrimSpace(string(output)))
+	}
+	route, err := ParseEgressRoute(endpoint, device, string(output))
+	if err != nil {
+		return err
+	}
+
+	add := route.AddCommand()
+	if output, err := c.runner.CombinedOutput(ctx, add[0], add[1:]...); err != nil {
+		return fmt.Errorf("%s failed: %v %s", strings.Join(add, " "), err, strings.TrimSpace(string(output)))
+	}
+	notify(out, "up "+env.Interface(), fmt.Sprintf("pinned the endpoint %s to %s", endpoint, device))
+	err = os.MkdirAll(EgressStateDir, 0o755)
+	if err == nil {
+		err = os.WriteFile(egressStateFile(env.Interface()), []byte(strings.Join(route.Spec(), " ")+"\n"), 0o644)
+	}
+	if err != nil {
+		// Without the record Stop couldn't remove the route; don't leave it
+		remove := route.DeleteCommand()
+		c.runner.CombinedOutput(ctx, remove[0], remove[1:]...)
+		return fmt.Errorf("failed to record the egress route: %v", err)
+	}
+	return nil
+}
+
+// unpinEgress removes the route pinEgress added for interfaceName, if any.
+func (c *Client) unpinEgress(ctx context.Context, out OutputFunc, interfaceName string) error {
+	path := egressStateFile(interfaceName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	spec := strings.Fields(string(content))
+	if len(spec) > 0 {
+		output, err := c.runner.CombinedOutput(ctx, "ip", append([]string{"route", "del"}, spec...)...)
+		// Gone already, e.g. with its device: nothing left to revert
+		if err != nil && !strings.Contains(string(output), "No such process") {
+			return fmt.Errorf("failed to remove the egress route %s: %v %s", strings.Join(spec, " "), err, strings.TrimSpace(string(output)))
+		}
+		notify(out, "down "+interfaceName, "removed the egress route "+spec[0])
+	}
+	return os.Remove(path)
+}
+
+func egressStateFile(interfaceName string) string {
+	return filepath.Join(EgressStateDir, interfaceName+".egress")
+}
+
+// endpointAddr resolves the Endpoint of interfaceName's installed config,
+// preferring IPv4 like the route checks.
+func (c *Client) endpointAddr(ctx context.Context, interfaceName string) (netip.Addr, error) {
+	tunnel, err := c.tunnelConfig(interfaceName)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	host, _, err := net.SplitHostPort(tunnel.Endpoint)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("%s has no usable Endpoint", interfaceName)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return addr.Unmap(), nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return netip.Addr{}, fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if addr.Unmap().Is4() {
+			return addr.Unmap(), nil
+		}
+	}
+	return addrs[0].Unmap(), nil
+}
+
+// observeEgress fills in which interface the tunnel's own traffic leaves
+// through, for a pinned tunnel: the kernel's route to the endpoint for
+// packets carrying the tunnel's firewall mark.
+func (c *Client) observeEgress(ctx context.Context, status *ConnectionStatus) {
+	if status == nil || !status.Connected || c.egress[status.Environment] == "" {
+		return
+	}
+	status.EgressPinned = c.egress[status.Environment]
+	endpoint, err := netip.ParseAddrPort(status.Endpoint)
+	if err != nil || runtime.GOOS != "linux" {
+		return
+	}
+	args := []string{"route", "get", endpoint.Addr().Unmap().String()}
+	if status.fwmark != 0 {
+		args = append(args, "mark", strconv.Itoa(status.fwmark))
+	}
+	if output, err := c.runner.Output(ctx, "ip", args...); err == nil {
+		status.Egress = ParseRouteGet(string(output))
+	}
+}
@@ -0,0 +1,70 @@
+package wgvpn
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var (
+	// ErrWireGuardNotInstalled is a status check that couldn't run wg at
+	// all: wireguard-tools is missing, or not in the PATH sudo uses.
+	ErrWireGuardNotInstalled = errors.New("WireGuard tools (wg) are not installed")
+	// ErrPermissionDenied is a status check wg refused for lack of
+	// privileges, or that sudo wouldn't run without a password.
+	ErrPermissionDenied = errors.New("not permitted to read the WireGuard interfaces")
+	// ErrMalformedOutput is wg output with a field that doesn't parse. The
+	// status isn't guessed at: a zero port or transfer would read as real.
+	ErrMalformedOutput = errors.New("unexpected wg output")
+)
+
+// Phrases of the shell's, sudo's and wg's error output that identify the
+// two failures above. Not installed is only what a failed exec says: wg
+// itself says "No such file or directory" of an interface that vanished.
+var (
+	notInstalledPhrases = []string{"command not found", "executable file not found"}
+	permissionPhrases   = []string{"operation not permitted", "permission denied", "a password is required", "a terminal is required", "not in the sudoers", "may not run sudo"}
+)
+
+// wgError tells apart why running wg failed, from err and, for a command
+// that ran, its standard error. The result wraps ErrWireGuardNotInstalled or
+// ErrPermissionDenied with what the command said, or is a plain error for
+// any other failure.
+func wgError(err error) error {
+	detail := err.Error()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if stderr := strings.TrimSpace(string(exitErr.Stderr)); stderr != "" {
+			detail, _, _ = strings.Cut(stderr, "\n")
+		}
+	}
+	lower := strings.ToLower(detail)
+	// A program that couldn't be started at all, as an absolute path that
+	// isn't there
+	notStarted := exitErr == nil && errors.Is(err, fs.ErrNotExist)
+	switch {
+	case errors.Is(err, exec.ErrNotFound) || notStarted || containsAny(lower, notInstalledPhrases):
+		return fmt.Errorf("%w: %s", ErrWireGuardNotInstalled, detail)
+	case errors.Is(err, os.ErrPermission) || containsAny(lower, permissionPhrases):
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, detail)
+	}
+	return fmt.Errorf("wg failed: %s", detail)
+}
+
+// malformed is the error for a field of wg show <interface> whose text
+// doesn't parse.
+func malformed(interfaceName, field, text string) error {
+	return fmt.Errorf("%w: %s of %s: %q", ErrMalformedOutput, field, interfaceName, strings.TrimSpace(text))
+}
+
+func containsAny(s string, phrases []string) bool {
+	for _, phrase := range phrases {
+		if strings.Contains(s, phrase) {
+			return true
+		}
+	}
+	return false
+}
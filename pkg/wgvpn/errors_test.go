@@ -0,0 +1,123 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// exitError is a command that ran and exited 1 after writing stderr.
+func exitError(stderr string) error {
+	return &exec.ExitError{Stderr: []byte(stderr)}
+}
+
+func TestStatusErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		errs    map[string]error
+		outputs map[string]string
+		want    error  // nil for a plain error
+		detail  string // what the error keeps of the command's own words
+	}{
+		{
+			name:   "wg not in PATH",
+			errs:   map[string]error{"wg show": &exec.Error{Name: "wg", Err: exec.ErrNotFound}},
+			want:   ErrWireGuardNotInstalled,
+			detail: "executable file not found",
+		},
+		{
+			name:   "sudo can't find wg",
+			errs:   map[string]error{"wg show": exitError("sudo: wg: command not found\n")},
+			want:   ErrWireGuardNotInstalled,
+			detail: "sudo: wg: command not found",
+		},
+		{
+			name:   "wg missing at its absolute path",
+			errs:   map[string]error{"wg show": &fs.PathError{Op: "fork/exec", Path: "/usr/bin/wg", Err: syscall.ENOENT}},
+			want:   ErrWireGuardNotInstalled,
+			detail: "no such file or directory",
+		},
+		{
+			name:   "sudo wants a password",
+			errs:   map[string]error{"wg show": exitError("sudo: a password is required\n")},
+			want:   ErrPermissionDenied,
+			detail: "sudo: a password is required",
+		},
+		{
+			name:   "wg without privileges",
+			errs:   map[string]error{"wg show": exitError("Unable to access interface: Operation not permitted\n")},
+			want:   ErrPermissionDenied,
+			detail: "Operation not permitted",
+		},
+		{
+			name:   "EACCES",
+			errs:   map[string]error{"wg show": &exec.Error{Name: "wg", Err: syscall.EACCES}},
+			want:   ErrPermissionDenied,
+			detail: "permission denied",
+		},
+		{
+			name:    "wg without privileges on the interface",
+			outputs: map[string]string{"wg show": "interface: julo-prod\n  listening port: 51820\n"},
+			errs:    map[string]error{"wg show julo-prod": exitError("Unable to access interface: Operation not permitted\n")},
+			want:    ErrPermissionDenied,
+			detail:  "Operation not permitted",
+		},
+		{
+			name:   "wg can't reach an interface",
+			errs:   map[string]error{"wg show": exitError("Unable to access interface: No such file or directory\n")},
+			detail: "wg failed: Unable to access interface: No such file or directory",
+		},
+		{
+			name:   "anything else",
+			errs:   map[string]error{"wg show": exitError("Unable to list interfaces: Protocol not supported\nmore\n")},
+			detail: "wg failed: Unable to list interfaces: Protocol not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{outputs: tt.outputs, errs: tt.errs}
+			client := New(WithRunner(runner), WithDeviceReader(fakeDevices{err: errors.New("netlink: operation not permitted")}))
+
+			status, err := client.Status(context.Background())
+			if err == nil {
+				t.Fatalf("Status() = %+v, want an error", status)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("Status() = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && (errors.Is(err, ErrWireGuardNotInstalled) || errors.Is(err, ErrPermissionDenied)) {
+				t.Errorf("Status() = %v, want a plain error", err)
+			}
+			if !strings.Contains(err.Error(), tt.detail) {
+				t.Errorf("Status() = %q, want it to say %q", err, tt.detail)
+			}
+			if _, tunnelsErr := client.Tunnels(context.Background()); tunnelsErr == nil {
+				t.Error("Tunnels() succeeded")
+			}
+		})
+	}
+}
+
+// An interface that goes away between wg show and wg show <interface> is
+// disconnected, not an error. Linux says so with ENODEV; macOS, where the
+// interface is a socket, with ENOENT.
+func TestStatusInterfaceGone(t *testing.T) {
+	for _, stderr := range []string{
+		"Unable to access interface: No such device\n",
+		"Unable to access interface: No such file or directory\n",
+	} {
+		runner := &fakeRunner{
+			outputs: map[string]string{"wg show": "interface: julo-prod\n  listening port: 51820\n"},
+			errs:    map[string]error{"wg show julo-prod": exitError(stderr)},
+		}
+		client := New(WithRunner(runner), WithDeviceReader(fakeDevices{err: errors.New("netlink: operation not permitted")}))
+		status, err := client.Status(context.Background())
+		if err != nil || status.Connected {
+			t.Errorf("%q: Status() = %+v, %v; want disconnected", stderr, status, err)
+		}
+	}
+}
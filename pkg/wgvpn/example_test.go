@@ -0,0 +1,94 @@
+package wgvpn_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+// tunnelRunner plays wg and wg-quick for one tunnel, so the examples run
+// without WireGuard. A real program uses wgvpn.ExecRunner, and keeps the
+// default device reader the examples turn off to ask the runner instead.
+type tunnelRunner struct {
+	up string // the interface that is up, "" for none
+}
+
+func (r *tunnelRunner) run(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	switch {
+	case command == "wg show":
+		if r.up == "" {
+			return nil, nil
+		}
+		return []byte("interface: " + r.up + "\n"), nil
+	case r.up != "" && command == "wg show "+r.up:
+		return []byte("interface: " + r.up + "\n  listening port: 51820\n\n" +
+			"peer: xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\n  endpoint: 34.128.85.147:51820\n" +
+			"  latest handshake: 4 seconds ago\n  transfer: 1.20 KiB received, 3.40 KiB sent\n"), nil
+	case strings.HasPrefix(command, "wg-quick up "):
+		r.up = args[1]
+		return nil, nil
+	case strings.HasPrefix(command, "wg-quick down "):
+		r.up = ""
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%s: not part of the example", command)
+}
+
+func (r *tunnelRunner) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func (r *tunnelRunner) CombinedOutput(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func (r *tunnelRunner) Stream(_ context.Context, _ func(line string), name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func ExampleNew() {
+	// wgvpn.ExecRunner{Sudo: true} runs wg and wg-quick under sudo
+	client := wgvpn.New(wgvpn.WithRunner(&tunnelRunner{}), wgvpn.WithDeviceReader(nil))
+
+	status, err := client.Status(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("connected:", status.Connected)
+	// Output: connected: false
+}
+
+func ExampleClient_Status() {
+	client := wgvpn.New(wgvpn.WithRunner(&tunnelRunner{up: "julo-prod"}), wgvpn.WithDeviceReader(nil))
+
+	status, err := client.Status(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Printf("%s on %s via %s, %d bytes received\n", status.Environment.DisplayName(), status.Interface, status.Endpoint, status.BytesRx)
+	// Output: Production on julo-prod via 34.128.85.147:51820, 1228 bytes received
+}
+
+// WithConnection brings nonprod up, waits for a handshake, runs the deploy
+// and brings the tunnel down again.
+func ExampleClient_WithConnection() {
+	runner := &tunnelRunner{}
+	client := wgvpn.New(wgvpn.WithRunner(runner), wgvpn.WithDeviceReader(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	err := client.WithConnection(ctx, wgvpn.NonProduction, func(ctx context.Context) error {
+		fmt.Println("deploying through", runner.up)
+		return nil
+	})
+	fmt.Printf("error: %v, still up: %q\n", err, runner.up)
+	// Output:
+	// deploying through julo-nonprod
+	// error: <nil>, still up: ""
+}
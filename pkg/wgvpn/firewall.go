@@ -0,0 +1,384 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort is WireGuard's conventional listen port, assumed when an
+// endpoint doesn't say otherwise.
+const DefaultPort = 51820
+
+// EndpointPort returns the UDP port of a host:port endpoint.
+func EndpointPort(endpoint string) int {
+	_, portText, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return DefaultPort
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil || port <= 0 || port > 65535 {
+		return DefaultPort
+	}
+	return port
+}
+
+// FirewallFinding is a firewall rule or default likely to block tunnel
+// traffic: outbound UDP to the gateway, or the replies coming back in.
+type FirewallFinding struct {
+	Firewall string // "ufw", "firewalld" or "nftables"
+	Rule     string // the offending rule or default, as the tool shows it
+	Fix      string // command that lets the traffic through
+}
+
+func (f FirewallFinding) String() string {
+	return fmt.Sprintf("%s: %s — allow with: %s", f.Firewall, f.Rule, f.Fix)
+}
+
+// Firewall recognizes the rules of one firewall manager that would block
+// WireGuard traffic on a UDP port.
+type Firewall interface {
+	Name() string
+	// Findings returns active=false when the firewall isn't installed or
+	// isn't running, so the next one is asked.
+	Findings(ctx context.Context, runner CommandRunner, port int) (findings []FirewallFinding, active bool, err error)
+}
+
+// Firewalls are asked in order; the first active one decides, since the
+// later ones are usually its backend.
+var Firewalls = []Firewall{UFW{}, Firewalld{}, NFTables{}}
+
+// CheckFirewall inspects the active firewall for rules blocking WireGuard
+// on port. name is "" when no firewall is active, which is not an error.
+func CheckFirewall(ctx context.Context, runner CommandRunner, port int) (name string, findings []FirewallFinding, err error) {
+	for _, firewall := range Firewalls {
+		findings, active, err := firewall.Findings(ctx, runner, port)
+		if active || err != nil {
+			return firewall.Name(), findings, err
+		}
+	}
+	return "", nil, nil
+}
+
+// CheckFirewall inspects the active firewall with the client's runner.
+func (c *Client) CheckFirewall(ctx context.Context, port int) (string, []FirewallFinding, error) {
+	return CheckFirewall(ctx, c.runner, port)
+}
+
+// missingTool reports whether a command failed because it isn't installed,
+// also when run through sudo.
+func missingTool(output []byte, err error) bool {
+	return errors.Is(err, exec.ErrNotFound) || strings.Contains(string(output), "command not found")
+}
+
+// UFW reads "ufw status verbose". Replies to outbound traffic are always
+// let in by ufw, so only outgoing rules matter.
+type UFW struct{}
+
+func (UFW) Name() string { return "ufw" }
+
+var ufwColumns = regexp.MustCompile(`\s{2,}`)
+
+func (UFW) Findings(ctx context.Context, runner CommandRunner, port int) ([]FirewallFinding, bool, error) {
+	output, err := runner.CombinedOutput(ctx, "ufw", "status", "verbose")
+	if missingTool(output, err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("ufw status failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	// Status: active
+	// Default: deny (incoming), deny (outgoing), disabled (routed)
+	//
+	// To                         Action      From
+	// --                         ------      ----
+	// 51820/udp                  DENY OUT    Anywhere
+	var (
+		findings    []FirewallFinding
+		defaultDeny string
+		allowed     bool
+		inRules     bool
+	)
+	fix := fmt.Sprintf("sudo ufw insert 1 allow out %d/udp", port)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Status:"):
+			if strings.TrimSpace(strings.TrimPrefix(line, "Status:")) != "active" {
+				return nil, false, nil
+			}
+		case strings.HasPrefix(line, "Default:"):
+			for _, policy := range strings.Split(strings.TrimPrefix(line, "Default:"), ",") {
+				policy = strings.TrimSpace(policy)
+				if strings.HasSuffix(policy, "(outgoing)") && !strings.HasPrefix(policy, "allow") {
+					defaultDeny = "Default: " + policy
+				}
+			}
+		case strings.HasPrefix(line, "--"):
+			inRules = true
+		case inRules && line != "":
+			columns := ufwColumns.Split(line, -1)
+			if len(columns) < 2 || !ufwPortMatches(columns[0], port) {
+				continue
+			}
+			switch columns[1] {
+			case "ALLOW OUT":
+				allowed = true
+			case "DENY OUT", "REJECT OUT":
+				findings = append(findings, FirewallFinding{Firewall: "ufw", Rule: line, Fix: fix})
+			}
+		}
+	}
+	if defaultDeny != "" && !allowed {
+		findings = append(findings, FirewallFinding{Firewall: "ufw", Rule: defaultDeny, Fix: fix})
+	}
+	return findings, true, nil
+}
+
+// ufwPortMatches reports whether a ufw "To" column covers UDP port.
+func ufwPortMatches(to string, port int) bool {
+	for _, field := range strings.Fields(to) {
+		if field == strconv.Itoa(port) || field == fmt.Sprintf("%d/udp", port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Firewalld checks direct OUTPUT rules and policies filtering the host's
+// own traffic; zones only filter incoming connections, and replies are
+// always let in.
+type Firewalld struct{}
+
+func (Firewalld) Name() string { return "firewalld" }
+
+func (Firewalld) Findings(ctx context.Context, runner CommandRunner, port int) ([]FirewallFinding, bool, error) {
+	output, err := runner.CombinedOutput(ctx, "firewall-cmd", "--state")
+	if missingTool(output, err) || strings.TrimSpace(string(output)) != "running" {
+		return nil, false, nil
+	}
+
+	var findings []FirewallFinding
+	output, err = runner.CombinedOutput(ctx, "firewall-cmd", "--direct", "--get-all-rules")
+	if err != nil {
+		return nil, true, fmt.Errorf("firewall-cmd --direct failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	// ipv4 filter OUTPUT 0 -p udp --dport 51820 -j DROP
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[2] != "OUTPUT" || !blocksUDP(fields[4:], port) {
+			continue
+		}
+		findings = append(findings, FirewallFinding{
+			Firewall: "firewalld",
+			Rule:     "direct rule: " + strings.TrimSpace(line),
+			Fix:      fmt.Sprintf("sudo firewall-cmd --permanent --direct --remove-rule %s && sudo firewall-cmd --reload", strings.TrimSpace(line)),
+		})
+	}
+
+	// Policies are newer than direct rules; older firewalld doesn't know them
+	output, err = runner.CombinedOutput(ctx, "firewall-cmd", "--list-all-policies")
+	if err == nil {
+		findings = append(findings, firewalldPolicyFindings(string(output), port)...)
+	}
+	return findings, true, nil
+}
+
+// blocksUDP reports whether iptables arguments drop or reject UDP to port
+// (or all UDP).
+func blocksUDP(args []string, port int) bool {
+	var proto, dport, target string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "-p", "--protocol":
+			proto = args[i+1]
+		case "--dport", "--destination-port":
+			dport = args[i+1]
+		case "-j", "--jump":
+			target = args[i+1]
+		}
+	}
+	if target != "DROP" && target != "REJECT" {
+		return false
+	}
+	return (proto == "udp" || proto == "") && (dport == "" || dport == strconv.Itoa(port))
+}
+
+// firewalldPolicyFindings finds active policies leaving the host (ingress
+// zone HOST) whose target rejects traffic without opening port.
+//
+//	block-out (active)
+//	  priority: -1
+//	  target: REJECT
+//	  ingress-zones: HOST
+//	  egress-zones: ANY
+//	  ports: 443/tcp
+func firewalldPolicyFindings(output string, port int) []FirewallFinding {
+	var findings []FirewallFinding
+	var name string
+	policy := map[string]string{}
+	flush := func() {
+		if name == "" {
+			return
+		}
+		target := policy["target"]
+		fromHost := strings.Contains(" "+policy["ingress-zones"]+" ", " HOST ")
+		open := strings.Contains(" "+policy["ports"]+" ", fmt.Sprintf(" %d/udp ", port))
+		if fromHost && (target == "REJECT" || target == "DROP") && !open {
+			findings = append(findings, FirewallFinding{
+				Firewall: "firewalld",
+				Rule:     fmt.Sprintf("policy %s (target %s)", name, target),
+				Fix:      fmt.Sprintf("sudo firewall-cmd --permanent --policy=%s --add-port=%d/udp && sudo firewall-cmd --reload", name, port),
+			})
+		}
+	}
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+			flush()
+			name, policy = "", map[string]string{}
+			if header, ok := strings.CutSuffix(strings.TrimSpace(line), " (active)"); ok {
+				name = header
+			}
+			continue
+		}
+		if key, value, ok := strings.Cut(strings.TrimSpace(line), ":"); ok {
+			policy[key] = strings.TrimSpace(value)
+		}
+	}
+	flush()
+	return findings
+}
+
+// NFTables reads "nft list ruleset" for filter chains dropping outbound UDP
+// to the gateway, or dropping incoming packets without letting established
+// traffic (the tunnel's replies) through.
+type NFTables struct{}
+
+func (NFTables) Name() string { return "nftables" }
+
+func (NFTables) Findings(ctx context.Context, runner CommandRunner, port int) ([]FirewallFinding, bool, error) {
+	output, err := runner.CombinedOutput(ctx, "nft", "list", "ruleset")
+	if missingTool(output, err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("nft list ruleset failed: %v %s", err, strings.TrimSpace(string(output)))
+	}
+	chains := parseNFTChains(string(output))
+	if len(chains) == 0 {
+		return nil, false, nil
+	}
+
+	var findings []FirewallFinding
+	portText := strconv.Itoa(port)
+	for _, chain := range chains {
+		location := fmt.Sprintf("%s %s %s", chain.family, chain.table, chain.name)
+		switch chain.hook {
+		case "output":
+			allowed := false
+			for _, rule := range chain.rules {
+				mentionsPort := strings.Contains(rule, "dport "+portText) || (strings.Contains(rule, "dport {") && strings.Contains(rule, portText))
+				allUDP := strings.Contains(rule, "udp") && !strings.Contains(rule, "dport") && !strings.Contains(rule, "sport")
+				switch {
+				case strings.HasSuffix(rule, "accept") && (mentionsPort || allUDP || rule == "accept"):
+					allowed = true
+				case (strings.HasSuffix(rule, "drop") || strings.Contains(rule, "reject")) && (mentionsPort || allUDP) && !allowed:
+					findings = append(findings, FirewallFinding{
+						Firewall: "nftables",
+						Rule:     fmt.Sprintf("%s: %s", location, rule),
+						Fix:      fmt.Sprintf("sudo nft insert rule %s udp dport %d accept", location, port),
+					})
+				}
+			}
+			if chain.policy == "drop" && !allowed {
+				findings = append(findings, FirewallFinding{
+					Firewall: "nftables",
+					Rule:     fmt.Sprintf("%s: policy drop", location),
+					Fix:      fmt.Sprintf("sudo nft insert rule %s udp dport %d accept", location, port),
+				})
+			}
+		case "input":
+			if chain.policy != "drop" {
+				continue
+			}
+			established := false
+			for _, rule := range chain.rules {
+				if strings.Contains(rule, "ct state") && strings.Contains(rule, "established") && strings.HasSuffix(rule, "accept") {
+					established = true
+				}
+			}
+			if !established {
+				findings = append(findings, FirewallFinding{
+					Firewall: "nftables",
+					Rule:     fmt.Sprintf("%s: policy drop without accepting established traffic", location),
+					Fix:      fmt.Sprintf("sudo nft insert rule %s ct state established,related accept", location),
+				})
+			}
+		}
+	}
+	return findings, true, nil
+}
+
+type nftChain struct {
+	family, table, name string
+	hook, policy        string // "" for regular (non-base) chains
+	rules               []string
+}
+
+// parseNFTChains extracts the filter chains of an "nft list ruleset":
+//
+//	table inet filter {
+//		chain output {
+//			type filter hook output priority filter; policy drop;
+//			udp dport 51820 accept
+//		}
+//	}
+func parseNFTChains(ruleset string) []nftChain {
+	var (
+		chains        []nftChain
+		family, table string
+		current       *nftChain
+	)
+	for _, line := range strings.Split(ruleset, "\n") {
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) >= 3 && fields[0] == "table":
+			family, table = fields[1], fields[2]
+		case len(fields) >= 2 && fields[0] == "chain":
+			chains = append(chains, nftChain{family: family, table: table, name: fields[1]})
+			current = &chains[len(chains)-1]
+		case line == "}":
+			current = nil
+		case current == nil || line == "":
+		case strings.HasPrefix(line, "type "):
+			for _, part := range strings.Split(line, ";") {
+				words := strings.Fields(part)
+				for i := 0; i+1 < len(words); i++ {
+					switch words[i] {
+					case "hook":
+						current.hook = words[i+1]
+					case "policy":
+						current.policy = words[i+1]
+					}
+				}
+			}
+			if !strings.HasPrefix(line, "type filter ") {
+				current.hook = "" // nat and route chains don't filter
+			}
+		default:
+			// Drop trailing "# handle 7" comments some nft versions print
+			rule, _, _ := strings.Cut(line, " # ")
+			current.rules = append(current.rules, strings.TrimSpace(rule))
+		}
+	}
+	return chains
+}
@@ -0,0 +1,211 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func rules(findings []FirewallFinding) []string {
+	var out []string
+	for _, finding := range findings {
+		out = append(out, finding.Rule)
+	}
+	return out
+}
+
+// checkFindings runs firewall over canned output and compares the rules it
+// reports.
+func checkFindings(t *testing.T, firewall Firewall, outputs map[string]string, want []string) []FirewallFinding {
+	t.Helper()
+	findings, active, err := firewall.Findings(context.Background(), &fakeRunner{outputs: outputs}, 51820)
+	if err != nil || !active {
+		t.Fatalf("Findings() = %v, %v, %v; want an active %s", findings, active, err, firewall.Name())
+	}
+	if got := rules(findings); !slices.Equal(got, want) {
+		t.Errorf("Findings() = %q, want %q", got, want)
+	}
+	return findings
+}
+
+func TestUFWFindings(t *testing.T) {
+	const header = "Status: active\nLogging: on (low)\nDefault: deny (incoming), %s (outgoing), disabled (routed)\nNew profiles: skip\n\n" +
+		"To                         Action      From\n--                         ------      ----\n22/tcp                     ALLOW IN    Anywhere\n"
+	status := func(outgoing, rules string) string {
+		return strings.Replace(header, "%s", outgoing, 1) + rules
+	}
+	tests := []struct {
+		name   string
+		status string
+		want   []string
+	}{
+		{"nothing blocks the port", status("allow", "443/tcp                    DENY OUT    Anywhere\n"), nil},
+		{"deny rule", status("allow", "51820/udp                  DENY OUT    Anywhere\n"), []string{"51820/udp                  DENY OUT    Anywhere"}},
+		{"reject rule on any protocol", status("allow", "51820                      REJECT OUT  Anywhere\n"), []string{"51820                      REJECT OUT  Anywhere"}},
+		{"deny by default", status("deny", ""), []string{"Default: deny (outgoing)"}},
+		{"deny by default, port allowed", status("deny", "51820/udp                  ALLOW OUT   Anywhere\n"), nil},
+		{"another port", status("allow", "51821/udp                  DENY OUT    Anywhere\n"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := checkFindings(t, UFW{}, map[string]string{"ufw status verbose": tt.status}, tt.want)
+			for _, finding := range findings {
+				if finding.Fix != "sudo ufw insert 1 allow out 51820/udp" {
+					t.Errorf("Fix = %q", finding.Fix)
+				}
+			}
+		})
+	}
+}
+
+func TestFirewalldFindings(t *testing.T) {
+	const policies = "allow-host-ipv6 (active)\n  priority: -15000\n  target: CONTINUE\n  ingress-zones: ANY\n  egress-zones: HOST\n\n" +
+		"block-out (active)\n  priority: -1\n  target: REJECT\n  ingress-zones: HOST\n  egress-zones: ANY\n  ports: 443/tcp\n"
+	tests := []struct {
+		name     string
+		direct   string
+		policies string
+		want     []string
+	}{
+		{"nothing blocks the port", "ipv4 filter OUTPUT 0 -p tcp --dport 25 -j REJECT\nipv4 filter INPUT 0 -p udp --dport 51820 -j DROP\n", "", nil},
+		{"direct drop", "ipv4 filter OUTPUT 0 -p udp --dport 51820 -j DROP\n", "", []string{"direct rule: ipv4 filter OUTPUT 0 -p udp --dport 51820 -j DROP"}},
+		{"direct reject of all UDP", "ipv6 filter OUTPUT 1 -p udp -j REJECT\n", "", []string{"direct rule: ipv6 filter OUTPUT 1 -p udp -j REJECT"}},
+		{"direct accept", "ipv4 filter OUTPUT 0 -p udp --dport 51820 -j ACCEPT\n", "", nil},
+		{"rejecting policy", "", policies, []string{"policy block-out (target REJECT)"}},
+		{"rejecting policy, port open", "", strings.Replace(policies, "443/tcp", "443/tcp 51820/udp", 1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFindings(t, Firewalld{}, map[string]string{
+				"firewall-cmd --state":                  "running\n",
+				"firewall-cmd --direct --get-all-rules": tt.direct,
+				"firewall-cmd --list-all-policies":      tt.policies,
+			}, tt.want)
+		})
+	}
+}
+
+// The direct rules are iptables arguments.
+func TestBlocksUDP(t *testing.T) {
+	tests := []struct {
+		args string
+		want bool
+	}{
+		{"-p udp --dport 51820 -j DROP", true},
+		{"--protocol udp --destination-port 51820 --jump REJECT", true},
+		{"-p udp -j DROP", true},
+		{"-j DROP", true},
+		{"-p udp --dport 51820 -j ACCEPT", false},
+		{"-p udp --dport 53 -j DROP", false},
+		{"-p tcp --dport 51820 -j DROP", false},
+		{"-p udp --dport 51820 -j LOG", false},
+	}
+	for _, tt := range tests {
+		if got := blocksUDP(strings.Fields(tt.args), 51820); got != tt.want {
+			t.Errorf("blocksUDP(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestNFTablesFindings(t *testing.T) {
+	ruleset := func(input, output string) string {
+		return "table inet filter {\n" +
+			"\tchain input {\n\t\ttype filter hook input priority filter; policy " + input + ";\n\t\tiif \"lo\" accept\n" +
+			"\t\ttcp dport 22 accept # handle 4\n\t}\n" +
+			"\tchain output {\n\t\ttype filter hook output priority filter; policy " + output + "\n" +
+			"\t}\n}\n" +
+			"table ip nat {\n\tchain postrouting {\n\t\ttype nat hook postrouting priority srcnat; policy accept;\n\t\tmasquerade\n\t}\n}\n"
+	}
+	tests := []struct {
+		name    string
+		ruleset string
+		want    []string
+	}{
+		{"nothing blocks the port", ruleset("accept", "accept;\n\t\tudp dport 53 drop"), nil},
+		{"dropped port", ruleset("accept", "accept;\n\t\tudp dport 51820 drop"), []string{"inet filter output: udp dport 51820 drop"}},
+		{"rejected port set", ruleset("accept", "accept;\n\t\tudp dport { 500, 51820 } reject"), []string{"inet filter output: udp dport { 500, 51820 } reject"}},
+		{"accepted before the drop", ruleset("accept", "accept;\n\t\tudp dport 51820 accept\n\t\tmeta l4proto udp drop"), nil},
+		{"output policy drop", ruleset("accept", "drop;\n\t\ttcp dport 443 accept"), []string{"inet filter output: policy drop"}},
+		{"output policy drop, port accepted", ruleset("accept", "drop;\n\t\tudp dport 51820 accept"), nil},
+		{"input drop without established", ruleset("drop", "accept;"), []string{"inet filter input: policy drop without accepting established traffic"}},
+		{"input drop with established", strings.Replace(ruleset("drop", "accept;"), "iif \"lo\" accept", "ct state established,related accept", 1), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkFindings(t, NFTables{}, map[string]string{"nft list ruleset": tt.ruleset}, tt.want)
+		})
+	}
+}
+
+func TestCheckFirewall(t *testing.T) {
+	notFound := &exec.Error{Name: "ufw", Err: exec.ErrNotFound}
+	tests := []struct {
+		name    string
+		runner  *fakeRunner
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "ufw inactive, firewalld running",
+			runner: &fakeRunner{outputs: map[string]string{
+				"ufw status verbose":                    "Status: inactive\n",
+				"firewall-cmd --state":                  "running\n",
+				"firewall-cmd --direct --get-all-rules": "",
+				"firewall-cmd --list-all-policies":      "",
+			}},
+			want: "firewalld",
+		},
+		{
+			name: "only nftables",
+			runner: &fakeRunner{
+				outputs: map[string]string{"nft list ruleset": "table inet filter {\n\tchain output {\n\t\ttype filter hook output priority filter; policy accept;\n\t}\n}\n"},
+				errs:    map[string]error{"ufw status verbose": notFound, "firewall-cmd --state": notFound},
+			},
+			want: "nftables",
+		},
+		{
+			name: "no firewall",
+			runner: &fakeRunner{
+				outputs: map[string]string{"nft list ruleset": ""},
+				errs:    map[string]error{"ufw status verbose": notFound, "firewall-cmd --state": notFound},
+			},
+		},
+		{
+			name: "ufw failing",
+			runner: &fakeRunner{errs: map[string]error{
+				"ufw status verbose": errors.New("exit status 1"),
+			}},
+			want:    "ufw",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, findings, err := CheckFirewall(context.Background(), tt.runner, 51820)
+			if name != tt.want || (err != nil) != tt.wantErr || len(findings) > 0 {
+				t.Errorf("CheckFirewall() = %q, %v, %v; want %q, error %v", name, findings, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEndpointPort(t *testing.T) {
+	tests := map[string]int{
+		"34.101.166.184:51820": 51820,
+		"vpn.example.com:443":  443,
+		"[2001:db8::1]:51821":  51821,
+		"34.101.166.184":       DefaultPort,
+		"34.101.166.184:0":     DefaultPort,
+		"34.101.166.184:70000": DefaultPort,
+		"34.101.166.184:wg":    DefaultPort,
+		"":                     DefaultPort,
+	}
+	for endpoint, want := range tests {
+		if got := EndpointPort(endpoint); got != want {
+			t.Errorf("EndpointPort(%q) = %d, want %d", endpoint, got, want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// wg-quick's default routing table and fwmark for full-tunnel configs
+const wgQuickTable = "51820"
+
+// Leftover is system state that still references a tunnel after it went
+// down, e.g. a DNS server only reachable through it.
+type Leftover struct {
+	Description string
+	// Fix is the command that removes it; nil when it has to be fixed by
+	// hand.
+	Fix []string
+}
+
+func (l Leftover) String() string {
+	return l.Description
+}
+
+// TunnelConfig is what a tunnel's config installs besides the interface.
+type TunnelConfig struct {
+	Interface string
+	Addresses []string // Address prefixes
+	DNS       []string // DNS server addresses
+	Routes    []string // AllowedIPs prefixes
+	Endpoint  string   // the peer's Endpoint (host:port)
+}
+
+// DNSBackend recognizes DNS settings one resolver manager kept after a
+// tunnel went down.
+type DNSBackend interface {
+	Name() string
+	// Leftovers returns ok=false when the backend doesn't manage DNS on this
+	// machine, so the next one is asked.
+	Leftovers(ctx context.Context, runner CommandRunner, tunnel TunnelConfig) (leftovers []Leftover, ok bool)
+}
+
+// DNSBackends are asked in order; the first one managing DNS decides.
+var DNSBackends = []DNSBackend{SystemdResolved{}, OpenResolv{}, ResolvConf{}}
+
+// SystemdResolved checks the per-link and global DNS servers of
+// systemd-resolved ("resolvectl dns").
+type SystemdResolved struct{}
+
+func (SystemdResolved) Name() string { return "systemd-resolved" }
+
+func (SystemdResolved) Leftovers(ctx context.Context, runner CommandRunner, tunnel TunnelConfig) ([]Leftover, bool) {
+	output, err := runner.Output(ctx, "resolvectl", "dns")
+	if err != nil {
+		return nil, false
+	}
+	var leftovers []Leftover
+	// "Global: 1.1.1.1" or "Link 7 (julo-prod): 10.0.0.1 10.0.0.2"
+	for _, line := range strings.Split(string(output), "\n") {
+		scope, servers, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		server := firstShared(strings.Fields(servers), tunnel.DNS)
+		if server == "" {
+			continue
+		}
+		scope = strings.TrimSpace(scope)
+		if scope == "Global" {
+			leftovers = append(leftovers, Leftover{
+				Description: fmt.Sprintf("systemd-resolved global DNS still uses %s (check /etc/systemd/resolved.conf)", server),
+			})
+			continue
+		}
+		link := scope
+		if lp, rp := strings.Index(scope, "("), strings.LastIndex(scope, ")"); lp >= 0 && rp > lp {
+			link = scope[lp+1 : rp]
+		}
+		leftovers = append(leftovers, Leftover{
+			Description: fmt.Sprintf("systemd-resolved still sends %s's DNS queries to %s", link, server),
+			Fix:         []string{"resolvectl", "revert", link},
+		})
+	}
+	return leftovers, true
+}
+
+// OpenResolv checks the records openresolv keeps per interface
+// ("resolvconf -l"); wg-quick registers its DNS as "tun.<interface>".
+type OpenResolv struct{}
+
+func (OpenResolv) Name() string { return "resolvconf" }
+
+func (OpenResolv) Leftovers(ctx context.Context, runner CommandRunner, tunnel TunnelConfig) ([]Leftover, bool) {
+	output, err := runner.Output(ctx, "resolvconf", "-l")
+	if err != nil {
+		// Debian's resolvconf has no -l; the resulting file is checked instead
+		return nil, false
+	}
+	var leftovers []Leftover
+	for _, line := range strings.Split(string(output), "\n") {
+		record, ok := strings.CutPrefix(strings.TrimSpace(line), "# resolv.conf from ")
+		if !ok {
+			continue
+		}
+		record = strings.TrimSpace(record)
+		if record == "tun."+tunnel.Interface || record == tunnel.Interface {
+			leftovers = append(leftovers, Leftover{
+				Description: fmt.Sprintf("resolvconf still holds DNS record %s", record),
+				Fix:         []string{"resolvconf", "-d", record, "-f"},
+			})
+		}
+	}
+	return leftovers, true
+}
+
+// ResolvConf checks a plain /etc/resolv.conf. Nothing here is safe to edit
+// automatically, so leftovers come without a fix.
+type ResolvConf struct{}
+
+func (ResolvConf) Name() string { return "resolv.conf" }
+
+func (ResolvConf) Leftovers(ctx context.Context, runner CommandRunner, tunnel TunnelConfig) ([]Leftover, bool) {
+	output, err := runner.Output(ctx, "cat", "/etc/resolv.conf")
+	if err != nil {
+		return nil, false
+	}
+	var leftovers []Leftover
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "nameserver" {
+			continue
+		}
+		if server := firstShared(fields[1:2], tunnel.DNS); server != "" {
+			leftovers = append(leftovers, Leftover{
+				Description: fmt.Sprintf("/etc/resolv.conf still lists nameserver %s; restore it by hand", server),
+			})
+		}
+	}
+	return leftovers, true
+}
+
+// firstShared returns the first of values that is also in wanted.
+func firstShared(values, wanted []string) string {
+	for _, value := range values {
+		for _, w := range wanted {
+			if value == w {
+				return value
+			}
+		}
+	}
+	return ""
+}
+
+// Leftovers checks, once env's tunnel is down, for DNS settings and routes
+// that still reference it. A missing config means there is nothing to check.
+func (c *Client) Leftovers(ctx context.Context, env Environment) ([]Leftover, error) {
+	tunnel, err := c.tunnelConfig(env.Interface())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var leftovers []Leftover
+	if len(tunnel.DNS) > 0 {
+		for _, backend := range DNSBackends {
+			if found, ok := backend.Leftovers(ctx, c.runner, tunnel); ok {
+				leftovers = append(leftovers, found...)
+				break
+			}
+		}
+	}
+	leftovers = append(leftovers, c.routeLeftovers(ctx, tunnel)...)
+	return leftovers, nil
+}
+
+// routeLeftovers finds routes for the tunnel's AllowedIPs still bound to a
+// julo-* device, and wg-quick's policy rules for full-tunnel configs once no
+// WireGuard interface is left to use them.
+func (c *Client) routeLeftovers(ctx context.Context, tunnel TunnelConfig) []Leftover {
+	var leftovers []Leftover
+	for _, route := range tunnel.Routes {
+		for _, dev := range c.devicesHolding(ctx, "route", route) {
+			leftovers = append(leftovers, Leftover{
+				Description: fmt.Sprintf("route %s still points at %s", route, dev),
+				Fix:         []string{"ip", "route", "del", route, "dev", dev},
+			})
+		}
+	}
+
+	if interfaces, err := c.runner.Output(ctx, "wg", "show", "interfaces"); err != nil || strings.TrimSpace(string(interfaces)) != "" {
+		return leftovers
+	}
+	output, err := c.runner.Output(ctx, "ip", "-o", "rule", "show")
+	if err != nil {
+		return leftovers
+	}
+	// "32764:	from all lookup main suppress_prefixlength 0"
+	// "32765:	not from all fwmark 0xca6c lookup 51820"
+	for _, line := range strings.Split(string(output), "\n") {
+		pref, rule, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		rule = strings.Join(strings.Fields(rule), " ")
+		if strings.HasSuffix(rule, "lookup "+wgQuickTable) || strings.HasSuffix(rule, "suppress_prefixlength 0") {
+			leftovers = append(leftovers, Leftover{
+				Description: fmt.Sprintf("routing rule %q of a full tunnel is still active", rule),
+				Fix:         []string{"ip", "rule", "del", "pref", strings.TrimSpace(pref)},
+			})
+		}
+	}
+	return leftovers
+}
+
+// CleanUp runs the fixes of leftovers, skipping those without one. It stops
+// at the first failure.
+func (c *Client) CleanUp(ctx context.Context, leftovers []Leftover) error {
+	for _, leftover := range leftovers {
+		if len(leftover.Fix) == 0 {
+			continue
+		}
+		output, err := c.runner.CombinedOutput(ctx, leftover.Fix[0], leftover.Fix[1:]...)
+		if err != nil {
+			return fmt.Errorf("%s failed: %v %s", strings.Join(leftover.Fix, " "), err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// tunnelConfig reads the addresses, DNS servers, routes and endpoint from the
+// installed config for interfaceName.
+func (c *Client) tunnelConfig(interfaceName string) (TunnelConfig, error) {
+	tunnel := TunnelConfig{Interface: interfaceName}
+	content, err := os.ReadFile(filepath.Join(c.configDir, interfaceName+".conf"))
+	if err != nil {
+		return tunnel, err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		// Read as wg-quick reads it: everything from a "#" on is a comment,
+		// and keys are case-insensitive
+		line, _, _ = strings.Cut(line, "#")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "address":
+				if prefix, err := netip.ParsePrefix(item); err == nil {
+					tunnel.Addresses = append(tunnel.Addresses, prefix.String())
+				}
+			case "dns":
+				// DNS also takes search domains; only addresses are servers
+				if addr, err := netip.ParseAddr(item); err == nil {
+					tunnel.DNS = append(tunnel.DNS, addr.String())
+				}
+			case "allowedips":
+				if prefix, err := netip.ParsePrefix(item); err == nil {
+					tunnel.Routes = append(tunnel.Routes, prefix.String())
+				}
+			case "endpoint":
+				tunnel.Endpoint = item
+			}
+		}
+	}
+	return tunnel, nil
+}
@@ -0,0 +1,237 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// prodConfig is an installed config as users edit them: comments at the
+// ends of lines, keys in any case, a search domain among the DNS servers.
+const prodConfig = `[Interface]
+PrivateKey = yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=
+address = 10.80.0.7/32
+DNS = 10.80.0.2, 10.80.0.3, corp.example # office resolvers
+
+[Peer]
+PublicKey = xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=
+Endpoint = 34.101.166.184:51820
+AllowedIPs = 10.80.0.0/16 # vpc
+allowedips = 10.0.0.0/8 # office
+# AllowedIPs = 192.168.0.0/16
+`
+
+// configClient is a client reading its configs from a directory holding
+// julo-prod.conf with content.
+func configClient(t *testing.T, runner CommandRunner, content string) *Client {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "julo-prod.conf"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return New(WithRunner(runner), WithConfigDir(dir))
+}
+
+func TestTunnelConfig(t *testing.T) {
+	client := configClient(t, &fakeRunner{}, prodConfig)
+	tunnel, err := client.tunnelConfig("julo-prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := TunnelConfig{
+		Interface: "julo-prod",
+		Addresses: []string{"10.80.0.7/32"},
+		DNS:       []string{"10.80.0.2", "10.80.0.3"},
+		Routes:    []string{"10.80.0.0/16", "10.0.0.0/8"},
+		Endpoint:  "34.101.166.184:51820",
+	}
+	if tunnel.Interface != want.Interface || tunnel.Endpoint != want.Endpoint ||
+		!slices.Equal(tunnel.Addresses, want.Addresses) || !slices.Equal(tunnel.DNS, want.DNS) || !slices.Equal(tunnel.Routes, want.Routes) {
+		t.Errorf("tunnelConfig() = %+v, want %+v", tunnel, want)
+	}
+}
+
+func descriptions(leftovers []Leftover) []string {
+	var out []string
+	for _, leftover := range leftovers {
+		out = append(out, leftover.Description)
+	}
+	return out
+}
+
+func TestDNSBackendLeftovers(t *testing.T) {
+	tunnel := TunnelConfig{Interface: "julo-prod", DNS: []string{"10.80.0.2", "10.80.0.3"}}
+	tests := []struct {
+		name    string
+		backend DNSBackend
+		outputs map[string]string
+		want    []Leftover
+	}{
+		{
+			name:    "resolved link",
+			backend: SystemdResolved{},
+			outputs: map[string]string{"resolvectl dns": "Global:\nLink 2 (wlp2s0): 192.168.1.1\nLink 7 (julo-prod): 10.80.0.2 10.80.0.3\n"},
+			want: []Leftover{{
+				Description: "systemd-resolved still sends julo-prod's DNS queries to 10.80.0.2",
+				Fix:         []string{"resolvectl", "revert", "julo-prod"},
+			}},
+		},
+		{
+			name:    "resolved global",
+			backend: SystemdResolved{},
+			outputs: map[string]string{"resolvectl dns": "Global: 10.80.0.3 1.1.1.1\nLink 2 (wlp2s0): 192.168.1.1\n"},
+			want: []Leftover{{
+				Description: "systemd-resolved global DNS still uses 10.80.0.3 (check /etc/systemd/resolved.conf)",
+			}},
+		},
+		{
+			name:    "resolved clean",
+			backend: SystemdResolved{},
+			outputs: map[string]string{"resolvectl dns": "Global:\nLink 2 (wlp2s0): 192.168.1.1\n"},
+		},
+		{
+			name:    "openresolv",
+			backend: OpenResolv{},
+			outputs: map[string]string{"resolvconf -l": "# resolv.conf from wlp2s0\nnameserver 192.168.1.1\n\n# resolv.conf from tun.julo-prod\nnameserver 10.80.0.2\n"},
+			want: []Leftover{{
+				Description: "resolvconf still holds DNS record tun.julo-prod",
+				Fix:         []string{"resolvconf", "-d", "tun.julo-prod", "-f"},
+			}},
+		},
+		{
+			name:    "openresolv clean",
+			backend: OpenResolv{},
+			outputs: map[string]string{"resolvconf -l": "# resolv.conf from wlp2s0\nnameserver 192.168.1.1\n"},
+		},
+		{
+			name:    "resolv.conf",
+			backend: ResolvConf{},
+			outputs: map[string]string{"cat /etc/resolv.conf": "# Generated by wg-quick\nnameserver 10.80.0.2\nsearch corp.example\n"},
+			want: []Leftover{{
+				Description: "/etc/resolv.conf still lists nameserver 10.80.0.2; restore it by hand",
+			}},
+		},
+		{
+			name:    "resolv.conf clean",
+			backend: ResolvConf{},
+			outputs: map[string]string{"cat /etc/resolv.conf": "nameserver 192.168.1.1\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leftovers, ok := tt.backend.Leftovers(context.Background(), &fakeRunner{outputs: tt.outputs}, tunnel)
+			if !ok {
+				t.Fatalf("%s doesn't manage DNS", tt.backend.Name())
+			}
+			if len(leftovers) != len(tt.want) {
+				t.Fatalf("Leftovers() = %q, want %q", descriptions(leftovers), descriptions(tt.want))
+			}
+			for i, leftover := range leftovers {
+				if leftover.Description != tt.want[i].Description || !slices.Equal(leftover.Fix, tt.want[i].Fix) {
+					t.Errorf("leftover %d = %+v, want %+v", i, leftover, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// A backend whose tool is missing lets the next one decide.
+func TestDNSBackendAbsent(t *testing.T) {
+	runner := &fakeRunner{errs: map[string]error{
+		"resolvectl dns": errors.New("exec: \"resolvectl\": executable file not found in $PATH"),
+		"resolvconf -l":  errors.New("exit status 1"),
+	}}
+	tunnel := TunnelConfig{Interface: "julo-prod", DNS: []string{"10.80.0.2"}}
+	for _, backend := range []DNSBackend{SystemdResolved{}, OpenResolv{}} {
+		if _, ok := backend.Leftovers(context.Background(), runner, tunnel); ok {
+			t.Errorf("%s manages DNS without its tool", backend.Name())
+		}
+	}
+}
+
+func TestRouteLeftovers(t *testing.T) {
+	const rules = "0:\tfrom all lookup local\n" +
+		"32764:\tfrom all lookup main suppress_prefixlength 0\n" +
+		"32765:\tnot from all fwmark 0xca6c lookup 51820\n" +
+		"32766:\tfrom all lookup main\n"
+	tests := []struct {
+		name       string
+		interfaces string
+		want       []Leftover
+	}{
+		{
+			name: "no tunnel left",
+			want: []Leftover{
+				{Description: "route 10.0.0.0/8 still points at julo-prod", Fix: []string{"ip", "route", "del", "10.0.0.0/8", "dev", "julo-prod"}},
+				{Description: `routing rule "from all lookup main suppress_prefixlength 0" of a full tunnel is still active`, Fix: []string{"ip", "rule", "del", "pref", "32764"}},
+				{Description: `routing rule "not from all fwmark 0xca6c lookup 51820" of a full tunnel is still active`, Fix: []string{"ip", "rule", "del", "pref", "32765"}},
+			},
+		},
+		{
+			// The rules belong to the tunnel still up
+			name:       "another tunnel up",
+			interfaces: "julo-nonprod\n",
+			want: []Leftover{
+				{Description: "route 10.0.0.0/8 still points at julo-prod", Fix: []string{"ip", "route", "del", "10.0.0.0/8", "dev", "julo-prod"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{outputs: map[string]string{
+				"ip -o route show exact 10.80.0.0/16": "",
+				// A route the office LAN holds isn't the tunnel's
+				"ip -o route show exact 10.0.0.0/8": "10.0.0.0/8 dev julo-prod scope link \n10.0.0.0/8 via 192.168.1.1 dev wlp2s0 metric 600 \n",
+				"wg show interfaces":                tt.interfaces,
+				"ip -o rule show":                   rules,
+			}}
+			tunnel := TunnelConfig{Interface: "julo-prod", Routes: []string{"10.80.0.0/16", "10.0.0.0/8"}}
+			leftovers := New(WithRunner(runner)).routeLeftovers(context.Background(), tunnel)
+			if len(leftovers) != len(tt.want) {
+				t.Fatalf("routeLeftovers() = %q, want %q", descriptions(leftovers), descriptions(tt.want))
+			}
+			for i, leftover := range leftovers {
+				if leftover.Description != tt.want[i].Description || !slices.Equal(leftover.Fix, tt.want[i].Fix) {
+					t.Errorf("leftover %d = %+v, want %+v", i, leftover, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestLeftovers reads the servers and routes from the installed config,
+// comments and all, and asks the first DNS backend present.
+func TestLeftovers(t *testing.T) {
+	runner := &fakeRunner{
+		outputs: map[string]string{
+			"resolvconf -l":                       "# resolv.conf from tun.julo-prod\nnameserver 10.80.0.2\n",
+			"ip -o route show exact 10.80.0.0/16": "",
+			"ip -o route show exact 10.0.0.0/8":   "10.0.0.0/8 dev julo-prod scope link \n",
+			"wg show interfaces":                  "",
+			"ip -o rule show":                     "0:\tfrom all lookup local\n32766:\tfrom all lookup main\n",
+		},
+		errs: map[string]error{"resolvectl dns": errors.New("exit status 1")},
+	}
+	leftovers, err := configClient(t, runner, prodConfig).Leftovers(context.Background(), Production)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"resolvconf still holds DNS record tun.julo-prod",
+		"route 10.0.0.0/8 still points at julo-prod",
+	}
+	if got := descriptions(leftovers); !slices.Equal(got, want) {
+		t.Errorf("Leftovers() = %q, want %q", got, want)
+	}
+}
+
+func TestLeftoversWithoutConfig(t *testing.T) {
+	runner := &fakeRunner{}
+	leftovers, err := New(WithRunner(runner), WithConfigDir(t.TempDir())).Leftovers(context.Background(), Production)
+	if err != nil || leftovers != nil || len(runner.calls) > 0 {
+		t.Errorf("Leftovers() = %v, %v after running %q; want nothing", leftovers, err, runner.calls)
+	}
+}
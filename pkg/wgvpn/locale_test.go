@@ -0,0 +1,77 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// wg show as a wg with German messages would print it, under LANG=de_DE.
+const (
+	germanListing = "Schnittstelle: julo-prod\n  öffentlicher Schlüssel: yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n  privater Schlüssel: (versteckt)\n\n" +
+		"Schnittstelle: julo-nonprod\n  öffentlicher Schlüssel: FpCyhws9cxwWoV4xELtfJvjJN+zQVRPISllRWgeopVE=\n"
+	germanShow = "Schnittstelle: julo-prod\n  öffentlicher Schlüssel: yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n  privater Schlüssel: (versteckt)\n  Port: 51820\n\n" +
+		"Peer: xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\n  Endpunkt: 34.101.166.184:51820\n  erlaubte IPs: 10.0.0.0/8\n" +
+		"  neuester Handshake: vor 1 Minute, 3 Sekunden\n  Übertragung: 6,98 MiB empfangen, 23,43 MiB gesendet\n"
+	englishShow = "interface: julo-prod\n  public key: yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n  private key: (hidden)\n  listening port: 51820\n\n" +
+		"peer: xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\n  endpoint: 34.101.166.184:51820\n  allowed ips: 10.0.0.0/8\n" +
+		"  latest handshake: 1 minute, 3 seconds ago\n  transfer: 6.98 MiB received, 23.43 MiB sent\n"
+)
+
+// TestStatusLocalizedOutput feeds wg output in another language through the
+// status check: the tunnels are still found and reported connected, with a
+// note instead of the details.
+func TestStatusLocalizedOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		show     string
+		endpoint string
+		note     string
+	}{
+		{"English", englishShow, "34.101.166.184:51820", ""},
+		{"German", germanShow, "", "details unavailable (unrecognized wg output)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{outputs: map[string]string{
+				"wg show":           strings.Replace(germanListing, "Schnittstelle: julo-nonprod", "Schnittstelle: wg0", 1),
+				"wg show julo-prod": tt.show,
+			}}
+			client := New(WithRunner(runner), WithDeviceReader(fakeDevices{err: errors.New("netlink: operation not permitted")}))
+			status, err := client.Status(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !status.Connected || status.Environment != Production || status.Endpoint != tt.endpoint || status.Note != tt.note {
+				t.Errorf("Status() = %+v; want connected to prod, endpoint %q, note %q", status, tt.endpoint, tt.note)
+			}
+		})
+	}
+}
+
+func TestJuloInterfacesLocalized(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{"wg show": germanListing}}
+	names, err := New(WithRunner(runner)).juloInterfaces(context.Background())
+	if err != nil || !slices.Equal(names, []string{"julo-prod", "julo-nonprod"}) {
+		t.Errorf("juloInterfaces() = %q, %v", names, err)
+	}
+}
+
+// ExecRunner runs everything in the C locale, whatever the user's is.
+func TestExecRunnerLocale(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	t.Setenv("LANG", "de_DE.UTF-8")
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	output, err := ExecRunner{}.Output(context.Background(), "sh", "-c", `echo "$LC_ALL $LANG"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(output)); got != "C C" {
+		t.Errorf("LC_ALL and LANG = %q, want C", got)
+	}
+}
@@ -0,0 +1,81 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultHandshakeAge is how recent a handshake must be for the tunnel to
+	// count as healthy. WireGuard re-handshakes every two minutes under traffic.
+	DefaultHandshakeAge = 3 * time.Minute
+
+	healthPollInterval = time.Second
+)
+
+// Healthy reports whether status is a connection to env with a handshake no
+// older than maxAge.
+func Healthy(status *ConnectionStatus, env Environment, maxAge time.Duration) bool {
+	if status == nil || !status.Connected || status.Environment != env || status.LastSeen == nil {
+		return false
+	}
+	return time.Since(*status.LastSeen) <= maxAge
+}
+
+// WaitHealthy polls the tunnel until it is connected to env with a recent
+// handshake, or until ctx is done.
+func (c *Client) WaitHealthy(ctx context.Context, env Environment) (*ConnectionStatus, error) {
+	ticker := time.NewTicker(healthPollInterval)
+	defer ticker.Stop()
+
+	for {
+		status, err := c.Status(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if Healthy(status, env, DefaultHandshakeAge) {
+			return status, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status, fmt.Errorf("%s VPN did not become healthy: %w", env.DisplayName(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithConnection makes sure env is connected and healthy, runs fn, and then
+// restores the previous state: the tunnel is brought down again only if this
+// call brought it up.
+func (c *Client) WithConnection(ctx context.Context, env Environment, fn func(ctx context.Context) error) error {
+	status, err := c.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	alreadyUp := status.Connected && status.Environment == env
+	if !alreadyUp {
+		if err := c.Connect(ctx, env); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.WaitHealthy(ctx, env); err != nil {
+		if !alreadyUp {
+			c.Disconnect(context.Background())
+		}
+		return err
+	}
+
+	fnErr := fn(ctx)
+
+	if !alreadyUp {
+		// Use a fresh context so a cancelled ctx still tears the tunnel down
+		if err := c.Disconnect(context.Background()); err != nil && fnErr == nil {
+			return err
+		}
+	}
+	return fnErr
+}
@@ -0,0 +1,157 @@
+package wgvpn
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseHandshakeTime reads wg's "latest handshake", e.g. "42 seconds ago" or
+// "Now", as the time it happened.
+func parseHandshakeTime(handshakeStr string) (time.Time, error) {
+	age, err := parseHandshakeAge(handshakeStr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Now().Add(-age), nil
+}
+
+// handshakeUnits are the units wg writes handshake ages in, singular.
+var handshakeUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+	"year":   365 * 24 * time.Hour,
+}
+
+// parseHandshakeAge reads how long ago a handshake was from wg's wording:
+// "Now", or one or more "<n> <unit>" separated by commas and followed by
+// "ago", e.g. "1 hour, 4 minutes, 12 seconds ago" or "2 days, 3 hours ago".
+// Each number takes the unit written after it. An age too long for a
+// time.Duration is an error rather than a wrapped-around one.
+func parseHandshakeAge(handshakeStr string) (time.Duration, error) {
+	text := strings.TrimSpace(handshakeStr)
+	// wg prints "Now" right after a handshake
+	if strings.EqualFold(text, "now") {
+		return 0, nil
+	}
+	parts := strings.Fields(strings.ReplaceAll(text, ",", " "))
+	if len(parts) > 0 && strings.EqualFold(parts[len(parts)-1], "ago") {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 0 || len(parts)%2 != 0 {
+		return 0, fmt.Errorf("unable to parse handshake time: %s", handshakeStr)
+	}
+	var age time.Duration
+	for i := 0; i < len(parts); i += 2 {
+		n, err := strconv.Atoi(parts[i])
+		unit, ok := handshakeUnits[strings.TrimSuffix(strings.ToLower(parts[i+1]), "s")]
+		if err != nil || n < 0 || !ok || time.Duration(n) > (math.MaxInt64-age)/unit {
+			return 0, fmt.Errorf("unable to parse handshake time: %s", handshakeStr)
+		}
+		age += time.Duration(n) * unit
+	}
+	return age, nil
+}
+
+// byteUnits are the suffixes wg writes byte counts with, longest first so
+// "B" doesn't match "KiB".
+var byteUnits = []struct {
+	suffix     string
+	multiplier uint64
+}{
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"PiB", 1 << 50},
+	{"EiB", 1 << 60},
+	{"B", 1},
+}
+
+// parseBytes reads a wg byte count such as "1.21 MiB", also as it appears in
+// the transfer line ("6.98 MiB received", "23.43 MiB sent"). The number is
+// read as a decimal with integer math, so large counts come out exact
+// rather than rounded through a float64, on 32-bit platforms too; a
+// fraction of a byte is dropped. Anything that isn't a plain non-negative
+// decimal, or doesn't fit in a uint64, is an error.
+func parseBytes(bytesStr string) (uint64, error) {
+	bytesStr = strings.TrimSpace(bytesStr)
+	original := bytesStr
+	for _, direction := range []string{"received", "sent"} {
+		if trimmed, ok := strings.CutSuffix(bytesStr, direction); ok {
+			bytesStr = strings.TrimSpace(trimmed)
+		}
+	}
+
+	multiplier := uint64(1)
+	for _, unit := range byteUnits {
+		if trimmed, ok := strings.CutSuffix(bytesStr, unit.suffix); ok {
+			multiplier, bytesStr = unit.multiplier, strings.TrimSpace(trimmed)
+			break
+		}
+	}
+
+	value, ok := parseDecimalBytes(bytesStr, multiplier)
+	if !ok {
+		return 0, fmt.Errorf("invalid byte count: %s", original)
+	}
+	return value, nil
+}
+
+// maxFractionDigits is the longest fraction parseDecimalBytes takes: 10^19
+// is the largest power of ten a uint64 holds.
+const maxFractionDigits = 19
+
+// parseDecimalBytes computes number × multiplier for a decimal number such
+// as "1.21", truncated to whole bytes. ok is false for anything but digits
+// with at most one ".", and when the result overflows a uint64.
+func parseDecimalBytes(number string, multiplier uint64) (uint64, bool) {
+	whole, fraction, _ := strings.Cut(number, ".")
+	if whole == "" || !allDigits(whole) || !allDigits(fraction) || len(fraction) > maxFractionDigits {
+		return 0, false
+	}
+	wholeValue, err := strconv.ParseUint(whole, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	hi, total := bits.Mul64(wholeValue, multiplier)
+	if hi != 0 {
+		return 0, false
+	}
+	if fraction == "" {
+		return total, true
+	}
+
+	// fraction × multiplier / 10^digits; the 128-bit product divides
+	// without overflow since fraction < 10^digits
+	fractionValue, err := strconv.ParseUint(fraction, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	scale := uint64(1)
+	for range fraction {
+		scale *= 10
+	}
+	hi, lo := bits.Mul64(fractionValue, multiplier)
+	fractionBytes, _ := bits.Div64(hi, lo, scale)
+	total, carry := bits.Add64(total, fractionBytes, 0)
+	if carry != 0 {
+		return 0, false
+	}
+	return total, true
+}
+
+func allDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
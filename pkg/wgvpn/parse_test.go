@@ -0,0 +1,204 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+// The seeds of these targets, in testdata/fuzz, are lines and whole outputs
+// captured from wg show; go test runs them as regression cases.
+
+func TestParseBytes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{in: "92 B", want: 92},
+		{in: "92 B sent", want: 92},
+		{in: "6.98 MiB received", want: 7319060},
+		{in: " 23.43 MiB sent", want: 24568135},
+		{in: "1.21 KiB", want: 1239},
+		{in: "3.10 GiB received", want: 3328599654},
+		{in: "1.50 TiB received", want: 1649267441664},
+		{in: "0 B", want: 0},
+		// Exact whatever the unit, also on 32-bit platforms
+		{in: "12345.67 GiB", want: 13256062224302},
+		{in: "1023.999 PiB", want: 1152920378706940133},
+		{in: "15.99 EiB", want: 18435214858663483146},
+		{in: "18446744073709551615 B", want: 18446744073709551615},
+		{in: "0.5 B", want: 0},
+		{in: "18446744073709551616 B", wantErr: true},
+		{in: "0.00000000000000000001 B", wantErr: true},
+		{in: "+1 B", wantErr: true},
+		{in: "NaN B", wantErr: true},
+		{in: "Inf MiB", wantErr: true},
+		{in: "1.2.3 KiB", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "received", wantErr: true},
+		{in: "6.98 MiB received, 1 B sent", wantErr: true},
+		{in: "MiB", wantErr: true},
+		{in: "-1 B", wantErr: true},
+		{in: "1e3 KiB", wantErr: true},
+		{in: "6.98 MiB lost", wantErr: true},
+		{in: "16.00 EiB", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseBytes(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseBytes(%q) = %d, %v; want %d, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// TestInterfaceStatusTransfer reads the transfer line of wg show as
+// wireguard-tools prints it.
+func TestInterfaceStatusTransfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer string
+		rx, tx   uint64
+	}{
+		{"long session", "transfer: 6.98 MiB received, 23.43 MiB sent", 7319060, 24568135},
+		{"fresh tunnel", "transfer: 92 B received, 180 B sent", 92, 180},
+		{"large", "transfer: 1.50 TiB received, 3.10 GiB sent", 1649267441664, 3328599654},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := "interface: julo-prod\n  public key: yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n  private key: (hidden)\n  listening port: 51820\n\n" +
+				"peer: xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\n  endpoint: 34.101.166.184:51820\n  allowed ips: 10.0.0.0/8\n" +
+				"  latest handshake: 1 minute, 3 seconds ago\n  " + tt.transfer + "\n"
+			runner := &fakeRunner{outputs: map[string]string{"wg show julo-prod": output}}
+			status, err := New(WithRunner(runner)).interfaceStatus(context.Background(), "julo-prod")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.BytesRx != tt.rx || status.BytesTx != tt.tx {
+				t.Errorf("BytesRx, BytesTx = %d, %d; want %d, %d", status.BytesRx, status.BytesTx, tt.rx, tt.tx)
+			}
+		})
+	}
+}
+
+// TestInterfaceStatusMalformed checks that a field that doesn't parse is an
+// error naming it, not a zero value.
+func TestInterfaceStatusMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{"port", "listening port: 5182o", `listening port of julo-prod: "5182o"`},
+		{"fwmark", "fwmark: off", `fwmark of julo-prod: "off"`},
+		{"handshake", "latest handshake: 2 fortnights ago", `latest handshake of julo-prod: "2 fortnights ago"`},
+		{"received", "transfer: lots received, 180 B sent", `transfer of julo-prod: "lots received, 180 B sent"`},
+		{"sent", "transfer: 92 B received, 180 bits sent", `transfer of julo-prod: "92 B received, 180 bits sent"`},
+		{"one direction", "transfer: 92 B received", `transfer of julo-prod: "92 B received"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := "interface: julo-prod\n  public key: yAnz5TF+lXXJte14tji3zlMNq+hd2rYUIgJBgB3fBmk=\n  " + tt.line + "\n"
+			runner := &fakeRunner{outputs: map[string]string{"wg show julo-prod": output}}
+			status, err := New(WithRunner(runner)).interfaceStatus(context.Background(), "julo-prod")
+			if !errors.Is(err, ErrMalformedOutput) {
+				t.Fatalf("interfaceStatus() = %+v, %v; want ErrMalformedOutput", status, err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q doesn't name %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParseBytes(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := parseBytes(s)
+		if err != nil {
+			return
+		}
+		// Whatever parsed is a count wg could have printed in bytes
+		if again, err := parseBytes(strconv.FormatUint(n, 10) + " B"); err != nil || again != n {
+			t.Errorf("parseBytes(%q) = %d, but %d B reads as %d, %v", s, n, n, again, err)
+		}
+	})
+}
+
+func TestParseHandshakeAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "Now", want: 0},
+		{in: "now", want: 0},
+		{in: "32 seconds ago", want: 32 * time.Second},
+		{in: "1 second ago", want: time.Second},
+		{in: "1 minute ago", want: time.Minute},
+		{in: "5 minutes, 3 seconds ago", want: 5*time.Minute + 3*time.Second},
+		{in: "1 hour, 4 minutes, 12 seconds ago", want: time.Hour + 4*time.Minute + 12*time.Second},
+		{in: "2 days, 3 hours ago", want: 51 * time.Hour},
+		{in: "1 week, 1 day ago", want: 8 * 24 * time.Hour},
+		{in: "  7 seconds ago\n", want: 7 * time.Second},
+		{in: "12 seconds", want: 12 * time.Second},
+		{in: "", wantErr: true},
+		{in: "ago", wantErr: true},
+		{in: "4 minutes, 12 ago", wantErr: true},
+		{in: "3 fortnights ago", wantErr: true},
+		{in: "-5 seconds ago", wantErr: true},
+		{in: "a while ago", wantErr: true},
+		{in: "9999999999 years ago", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHandshakeAge(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseHandshakeAge(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func FuzzParseHandshakeAge(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		age, err := parseHandshakeAge(s)
+		if err == nil && age < 0 {
+			t.Errorf("parseHandshakeAge(%q) = %v", s, age)
+		}
+	})
+}
+
+func FuzzParseRouteGet(f *testing.F) {
+	f.Fuzz(func(t *testing.T, s string) {
+		device := ParseRouteGet(s)
+		if device != "" && (strings.ContainsFunc(device, unicode.IsSpace) || !strings.Contains(s, device)) {
+			t.Errorf("ParseRouteGet(%q) = %q", s, device)
+		}
+	})
+}
+
+// FuzzInterfaceStatus feeds the output of wg show <interface> to
+// interfaceStatus: whatever wg prints, the interface is reported up, or the
+// output is reported malformed.
+func FuzzInterfaceStatus(f *testing.F) {
+	f.Fuzz(func(t *testing.T, output string) {
+		runner := &fakeRunner{outputs: map[string]string{"wg show julo-prod": output}}
+		client := New(WithRunner(runner))
+
+		status, err := client.interfaceStatus(context.Background(), "julo-prod")
+		if errors.Is(err, ErrMalformedOutput) {
+			return
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Connected || status.Interface != "julo-prod" || status.Environment != Production {
+			t.Errorf("interfaceStatus() = %+v", status)
+		}
+		if status.Endpoint != "" && !strings.Contains(output, status.Endpoint) {
+			t.Errorf("Endpoint %q is not in the output", status.Endpoint)
+		}
+	})
+}
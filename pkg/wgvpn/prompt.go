@@ -0,0 +1,113 @@
+package wgvpn
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Shell prompts run the prompt status before every command line, so it has a
+// latency budget of 50ms end to end, process start included. To stay well
+// inside it, InterfaceUp and PromptStatus never exec wg, wg-quick or sudo:
+// PromptStatus asks a running agent over its socket, giving it
+// PromptAgentTimeout, and otherwise stats files the kernel (Linux) or
+// wg-quick (macOS) already keeps. Anything slower belongs in the full status
+// check.
+
+// PromptAgentTimeout bounds the whole exchange with the agent: the dial and
+// the answer. Past it the prompt status falls back to the interface check.
+const PromptAgentTimeout = 20 * time.Millisecond
+
+// Exit codes of the prompt status, for prompts that color on them.
+const (
+	PromptConnected    = 0 // exactly one tunnel is up
+	PromptDisconnected = 1 // no tunnel is up; nothing is printed
+	PromptMultiple     = 2 // more than one tunnel is up
+)
+
+// interfaceMarkers are the files whose presence means a wg-quick interface
+// is up: the netdev on Linux, the utun name file wg-quick writes on macOS.
+var interfaceMarkers = []string{
+	"/sys/class/net/%s",
+	"/var/run/wireguard/%s.name",
+}
+
+// InterfaceUp reports whether the interface currently exists. It is a cheap
+// existence check, not a health check: it says nothing about handshakes.
+func InterfaceUp(interfaceName string) bool {
+	for _, marker := range interfaceMarkers {
+		if _, err := os.Lstat(strings.Replace(marker, "%s", filepath.Base(interfaceName), 1)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// PromptStatus returns a short prompt segment such as "wg:prod" and one of
+// the Prompt* exit codes. An agent listening on socket answers it, profiles
+// included; without one (socket may be ""), it checks which of prod and
+// nonprod have an interface.
+func PromptStatus(socket string) (string, int) {
+	up, err := AskPromptAgent(socket)
+	if err != nil {
+		up = nil
+		for _, env := range environments {
+			if InterfaceUp(env.Interface()) {
+				up = append(up, env)
+			}
+		}
+	}
+	switch len(up) {
+	case 0:
+		return "", PromptDisconnected
+	case 1:
+		return "wg:" + string(up[0]), PromptConnected
+	}
+	names := make([]string, len(up))
+	for i, env := range up {
+		names[i] = string(env)
+	}
+	return "wg:" + strings.Join(names, "+"), PromptMultiple
+}
+
+// AskPromptAgent asks the agent on socket which environments are up, within
+// PromptAgentTimeout. The agent answers with one line of their names
+// separated by spaces, an empty one when none is up; see WritePromptAnswer.
+func AskPromptAgent(socket string) ([]Environment, error) {
+	if socket == "" {
+		return nil, errors.New("no agent socket")
+	}
+	conn, err := net.DialTimeout("unix", socket, PromptAgentTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(PromptAgentTimeout)); err != nil {
+		return nil, err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		// The agent closes without answering when it doesn't know
+		return nil, err
+	}
+	var up []Environment
+	for _, name := range strings.Fields(line) {
+		up = append(up, Environment(name))
+	}
+	return up, nil
+}
+
+// WritePromptAnswer is the agent's side of AskPromptAgent.
+func WritePromptAnswer(w io.Writer, up []Environment) error {
+	names := make([]string, len(up))
+	for i, env := range up {
+		names[i] = string(env)
+	}
+	_, err := io.WriteString(w, strings.Join(names, " ")+"\n")
+	return err
+}
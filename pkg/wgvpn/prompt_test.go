@@ -0,0 +1,151 @@
+package wgvpn
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useInterfaces makes the named interfaces the ones that exist.
+func useInterfaces(t *testing.T, names ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := interfaceMarkers
+	interfaceMarkers = []string{filepath.Join(dir, "%s")}
+	t.Cleanup(func() { interfaceMarkers = saved })
+}
+
+// promptAgent listens on a socket and hands each connection to answer.
+func promptAgent(t *testing.T, answer func(conn net.Conn)) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				answer(conn)
+			}()
+		}
+	}()
+	return socket
+}
+
+func answering(up ...Environment) func(net.Conn) {
+	return func(conn net.Conn) { WritePromptAnswer(conn, up) }
+}
+
+func TestPromptStatus(t *testing.T) {
+	useInterfaces(t, "julo-prod")
+	// A profile only the agent knows about
+	staging := Environment("staging")
+	tests := []struct {
+		name    string
+		socket  string
+		segment string
+		code    int
+	}{
+		{"no agent", "", "wg:prod", PromptConnected},
+		{"agent gone", filepath.Join(t.TempDir(), "agent.sock"), "wg:prod", PromptConnected},
+		{"agent", promptAgent(t, answering(staging)), "wg:staging", PromptConnected},
+		{"agent, nothing up", promptAgent(t, answering()), "", PromptDisconnected},
+		{"agent, several up", promptAgent(t, answering(Production, staging)), "wg:prod+staging", PromptMultiple},
+		{"agent without an answer", promptAgent(t, func(net.Conn) {}), "wg:prod", PromptConnected},
+		{"agent too slow", promptAgent(t, func(conn net.Conn) {
+			time.Sleep(5 * PromptAgentTimeout)
+			WritePromptAnswer(conn, nil)
+		}), "wg:prod", PromptConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment, code := PromptStatus(tt.socket)
+			if segment != tt.segment || code != tt.code {
+				t.Errorf("PromptStatus() = %q, %d; want %q, %d", segment, code, tt.segment, tt.code)
+			}
+		})
+	}
+}
+
+func TestPromptStatusInterfaces(t *testing.T) {
+	tests := []struct {
+		up      []string
+		segment string
+		code    int
+	}{
+		{nil, "", PromptDisconnected},
+		{[]string{"julo-nonprod"}, "wg:nonprod", PromptConnected},
+		{[]string{"julo-prod", "julo-nonprod"}, "wg:prod+nonprod", PromptMultiple},
+		{[]string{"wg0"}, "", PromptDisconnected},
+	}
+	for _, tt := range tests {
+		useInterfaces(t, tt.up...)
+		if segment, code := PromptStatus(""); segment != tt.segment || code != tt.code {
+			t.Errorf("PromptStatus() with %q = %q, %d; want %q, %d", tt.up, segment, code, tt.segment, tt.code)
+		}
+	}
+}
+
+// The prompt status runs before every command line, within a 50ms budget
+// that also pays for starting the process: each way of answering has to
+// stay far below it.
+func BenchmarkPromptStatus(b *testing.B) {
+	b.Run("interfaces", func(b *testing.B) {
+		for range b.N {
+			PromptStatus("")
+		}
+	})
+	b.Run("agent", func(b *testing.B) {
+		socket := filepath.Join(b.TempDir(), "agent.sock")
+		listener, err := net.Listen("unix", socket)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer listener.Close()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				WritePromptAnswer(conn, []Environment{Production})
+				conn.Close()
+			}
+		}()
+		for range b.N {
+			if segment, _ := PromptStatus(socket); segment != "wg:prod" {
+				b.Fatalf("PromptStatus() = %q", segment)
+			}
+		}
+	})
+}
+
+// TestPromptStatusBudget guards the benchmark's point in the normal test
+// run: a regression to exec'ing wg or sudo takes milliseconds per call.
+func TestPromptStatusBudget(t *testing.T) {
+	useInterfaces(t, "julo-prod")
+	for _, socket := range []string{"", promptAgent(t, answering(Production))} {
+		const calls = 100
+		start := time.Now()
+		for range calls {
+			PromptStatus(socket)
+		}
+		if perCall := time.Since(start) / calls; perCall > 5*time.Millisecond {
+			t.Errorf("PromptStatus(%q) takes %s per call", socket, perCall)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// failureSignature recognizes a wg-quick up failure that has a known,
+// deterministic fix.
+type failureSignature struct {
+	name string
+	// match reports whether wg-quick's output shows this failure
+	match func(output string) bool
+	// reconcile repairs the leftover state so a retry can succeed
+	reconcile func(c *Client, ctx context.Context, out OutputFunc, interfaceName string) error
+}
+
+// failureSignatures are checked in order against a failed "wg-quick up".
+var failureSignatures = []failureSignature{
+	{
+		// An address or route of ours is still present from an earlier
+		// half-up attempt
+		name:      "stale address or route",
+		match:     containsAll("RTNETLINK answers: File exists"),
+		reconcile: (*Client).reconcileStale,
+	},
+	{
+		// The interface itself survived an earlier attempt
+		name:      "interface already exists",
+		match:     containsAll("wg-quick:", "already exists"),
+		reconcile: (*Client).reconcileStale,
+	},
+}
+
+func containsAll(needles ...string) func(string) bool {
+	return func(output string) bool {
+		for _, needle := range needles {
+			if !strings.Contains(output, needle) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// matchFailure returns the signature matching a failed up's output, or nil.
+func matchFailure(output string) *failureSignature {
+	for i := range failureSignatures {
+		if failureSignatures[i].match(output) {
+			return &failureSignatures[i]
+		}
+	}
+	return nil
+}
+
+// Attempt is one run of "wg-quick up".
+type Attempt struct {
+	Output string // redacted
+	Err    error
+}
+
+// UpError is returned when bringing a tunnel up fails. When the failure
+// matched a known signature, Reconciled names it and Attempts holds both the
+// original attempt and the retry.
+type UpError struct {
+	Interface  string
+	Reconciled string
+	Attempts   []Attempt
+}
+
+func (e *UpError) Error() string {
+	last := e.Attempts[len(e.Attempts)-1]
+	if e.Reconciled == "" {
+		return fmt.Sprintf("wg-quick up %s failed: %v\nOutput: %s", e.Interface, last.Err, last.Output)
+	}
+	return fmt.Sprintf("wg-quick up %s failed even after reconciling (%s): %v\nFirst attempt output: %s\nRetry output: %s",
+		e.Interface, e.Reconciled, last.Err, e.Attempts[0].Output, last.Output)
+}
+
+// up runs "wg-quick up" and, if it fails with a recognized signature,
+// reconciles and retries exactly once.
+func (c *Client) up(ctx context.Context, out OutputFunc, interfaceName string) error {
+	output, err := c.wgQuick(ctx, out, "up", interfaceName)
+	if err == nil {
+		return nil
+	}
+	upErr := &UpError{Interface: interfaceName}
+	upErr.Attempts = append(upErr.Attempts, Attempt{Output: RedactText(string(output)), Err: err})
+
+	signature := matchFailure(string(output))
+	if signature == nil || ctx.Err() != nil {
+		return upErr
+	}
+
+	upErr.Reconciled = signature.name
+	notify(out, "up "+interfaceName, fmt.Sprintf("↻ %s detected; cleaning up and retrying once", signature.name))
+	if err := signature.reconcile(c, ctx, out, interfaceName); err != nil {
+		notify(out, "up "+interfaceName, fmt.Sprintf("reconcile incomplete: %v", err))
+	}
+
+	output, err = c.wgQuick(ctx, out, "up", interfaceName)
+	if err == nil {
+		return nil
+	}
+	upErr.Attempts = append(upErr.Attempts, Attempt{Output: RedactText(string(output)), Err: err})
+	return upErr
+}
+
+func notify(out OutputFunc, operation, line string) {
+	if out != nil {
+		out(operation, line)
+	}
+}
+
+// reconcileStale removes what a half-up attempt leaves behind: the
+// interface itself, and addresses or routes from our config that are still
+// attached to another julo-* interface. Nothing outside julo-* is touched.
+func (c *Client) reconcileStale(ctx context.Context, out OutputFunc, interfaceName string) error {
+	operation := "up " + interfaceName
+
+	if _, err := c.runner.CombinedOutput(ctx, "ip", "link", "show", "dev", interfaceName); err == nil {
+		if _, err := c.wgQuick(ctx, out, "down", interfaceName); err != nil {
+			notify(out, operation, "wg-quick down failed; deleting the interface")
+			if output, err := c.runner.CombinedOutput(ctx, "ip", "link", "delete", "dev", interfaceName); err != nil {
+				return fmt.Errorf("failed to delete %s: %v %s", interfaceName, err, strings.TrimSpace(string(output)))
+			}
+		}
+	}
+
+	tunnel, err := c.tunnelConfig(interfaceName)
+	if err != nil {
+		return fmt.Errorf("can't read config to find stale routes: %v", err)
+	}
+	for _, addr := range tunnel.Addresses {
+		for _, dev := range c.devicesHolding(ctx, "addr", addr) {
+			notify(out, operation, fmt.Sprintf("removing stale address %s from %s", addr, dev))
+			c.runner.CombinedOutput(ctx, "ip", "address", "del", addr, "dev", dev)
+		}
+	}
+	for _, route := range tunnel.Routes {
+		for _, dev := range c.devicesHolding(ctx, "route", route) {
+			notify(out, operation, fmt.Sprintf("removing stale route %s via %s", route, dev))
+			c.runner.CombinedOutput(ctx, "ip", "route", "del", route, "dev", dev)
+		}
+	}
+	return nil
+}
+
+// devicesHolding lists the julo-* devices that currently have the address
+// (kind "addr") or an exact route (kind "route") for prefix.
+func (c *Client) devicesHolding(ctx context.Context, kind, prefix string) []string {
+	var args []string
+	if kind == "addr" {
+		ip := prefix
+		if p, err := netip.ParsePrefix(prefix); err == nil {
+			ip = p.Addr().String()
+		}
+		args = []string{"-o", "address", "show", "to", ip}
+	} else {
+		args = []string{"-o", "route", "show", "exact", prefix}
+	}
+	output, err := c.runner.Output(ctx, "ip", args...)
+	if err != nil {
+		return nil
+	}
+
+	var devices []string
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		dev := ""
+		if kind == "addr" && len(fields) > 1 {
+			// "7: julo-prod    inet 10.0.0.2/32 scope global julo-prod"
+			dev = fields[1]
+		}
+		for i := 0; kind == "route" && i+1 < len(fields); i++ {
+			if fields[i] == "dev" {
+				dev = fields[i+1]
+			}
+		}
+		if strings.HasPrefix(dev, "julo-") {
+			devices = append(devices, dev)
+		}
+	}
+	return devices
+}
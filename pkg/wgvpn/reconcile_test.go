@@ -0,0 +1,131 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Output of failed "wg-quick up" runs, as captured
+const (
+	upFileExists = `[#] ip link add julo-prod type wireguard
+[#] wg setconf julo-prod /dev/fd/63
+[#] ip -4 address add 10.9.0.2/32 dev julo-prod
+RTNETLINK answers: File exists
+`
+	upAlreadyExists = "wg-quick: `julo-prod' already exists\n"
+	upNoModule      = `[#] ip link add julo-prod type wireguard
+Error: Unknown device type.
+Unable to access interface: Protocol not supported
+[#] ip link delete dev julo-prod
+Cannot find device "julo-prod"
+`
+	upNoConfig = "wg-quick: `/etc/wireguard/julo-prod.conf' does not exist\n"
+)
+
+func TestMatchFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string // "" for no signature
+	}{
+		{"file exists", upFileExists, "stale address or route"},
+		{"interface exists", upAlreadyExists, "interface already exists"},
+		{"no kernel module", upNoModule, ""},
+		{"no config", upNoConfig, ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if signature := matchFailure(tt.output); signature != nil {
+				got = signature.name
+			}
+			if got != tt.want {
+				t.Errorf("matchFailure() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpReconcilesOnce(t *testing.T) {
+	failed := errors.New("exit status 1")
+	reconcile := []string{
+		"wg-quick up julo-prod",
+		"ip link show dev julo-prod",
+		"wg-quick down julo-prod",
+		"ip -o address show to 10.9.0.2",
+		"ip address del 10.9.0.2/32 dev julo-nonprod",
+		"ip -o route show exact 10.80.0.0/16",
+		"ip route del 10.80.0.0/16 dev julo-nonprod",
+		"wg-quick up julo-prod",
+	}
+	tests := []struct {
+		name     string
+		ups      []reply
+		calls    []string
+		attempts int // of the UpError, 0 when up succeeds
+	}{
+		{
+			name:  "retry succeeds",
+			ups:   []reply{{upFileExists, failed}, {"", nil}},
+			calls: reconcile,
+		},
+		{
+			name:     "retry fails",
+			ups:      []reply{{upFileExists, failed}, {upFileExists, failed}},
+			calls:    reconcile,
+			attempts: 2,
+		},
+		{
+			name:     "unknown failure is not retried",
+			ups:      []reply{{upNoModule, failed}},
+			calls:    []string{"wg-quick up julo-prod"},
+			attempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			config := "[Interface]\nAddress = 10.9.0.2/32\n\n[Peer]\nAllowedIPs = 10.80.0.0/16\n"
+			if err := os.WriteFile(filepath.Join(dir, "julo-prod.conf"), []byte(config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			runner := &fakeRunner{
+				replies: map[string][]reply{"wg-quick up julo-prod": tt.ups},
+				outputs: map[string]string{
+					"ip link show dev julo-prod": "12: julo-prod: <POINTOPOINT,NOARP> mtu 1420 qdisc noop state DOWN\n",
+					"wg-quick down julo-prod":    "[#] ip link delete dev julo-prod\n",
+					// Left on the other tunnel, next to one that isn't ours
+					"ip -o address show to 10.9.0.2":              "13: julo-nonprod    inet 10.9.0.2/32 scope global julo-nonprod\\       valid_lft forever preferred_lft forever\n",
+					"ip address del 10.9.0.2/32 dev julo-nonprod": "",
+					"ip -o route show exact 10.80.0.0/16":         "10.80.0.0/16 dev julo-nonprod scope link \n10.80.0.0/16 via 192.168.1.1 dev eth0 metric 600 \n",
+					"ip route del 10.80.0.0/16 dev julo-nonprod":  "",
+				},
+			}
+			client := New(WithRunner(runner), WithConfigDir(dir))
+
+			err := client.up(context.Background(), nil, "julo-prod")
+			if !slices.Equal(runner.calls, tt.calls) {
+				t.Errorf("ran:\n%s\nwant:\n%s", strings.Join(runner.calls, "\n"), strings.Join(tt.calls, "\n"))
+			}
+			var upErr *UpError
+			switch {
+			case tt.attempts == 0 && err != nil:
+				t.Fatalf("up() = %v", err)
+			case tt.attempts == 0:
+			case !errors.As(err, &upErr):
+				t.Fatalf("up() = %v, want an UpError", err)
+			case len(upErr.Attempts) != tt.attempts:
+				t.Errorf("%d attempts, want %d", len(upErr.Attempts), tt.attempts)
+			case tt.attempts == 2 && (upErr.Reconciled != "stale address or route" || strings.Count(err.Error(), "RTNETLINK answers: File exists") != 2):
+				// Both attempts' output is kept for the error details
+				t.Errorf("up() = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package wgvpn
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// WireGuard keys are 32 bytes of base64: 43 characters plus "=" padding
+	keyPattern = regexp.MustCompile(`[A-Za-z0-9+/]{42}[AEIMQUYcgkosw048]=`)
+
+	secretLinePattern = regexp.MustCompile(`(?i)^(\s*(?:private\s*key|preshared\s*key|privatekey|presharedkey)\s*[=:]\s*).+$`)
+)
+
+// Redact hides WireGuard key material in a line of tool or config output so
+// it can be shown on screen or written to logs.
+func Redact(line string) string {
+	line = secretLinePattern.ReplaceAllString(line, "${1}[HIDDEN]")
+	return keyPattern.ReplaceAllString(line, "[HIDDEN]")
+}
+
+// RedactText applies Redact to every line of text.
+func RedactText(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = Redact(line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,134 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// RouteDecision is whether traffic to a destination uses a tunnel, both as
+// its config intends (AllowedIPs) and as the kernel actually routes it.
+type RouteDecision struct {
+	Target      string // as entered: an address or a hostname
+	Destination netip.Addr
+	Interface   string // the tunnel's interface
+	// ConfigRoute is the most specific AllowedIPs entry containing
+	// Destination; invalid when none does
+	ConfigRoute netip.Prefix
+	// Device is the interface the kernel sends Destination's traffic out
+	// of, "" when the lookup failed
+	Device string
+	// KernelTunnel reports whether Device is the tunnel
+	KernelTunnel bool
+}
+
+// ConfigTunnel reports whether the config routes Destination through the
+// tunnel.
+func (d *RouteDecision) ConfigTunnel() bool {
+	return d.ConfigRoute.IsValid()
+}
+
+// Mismatch reports whether the kernel disagrees with the config.
+func (d *RouteDecision) Mismatch() bool {
+	return d.Device != "" && d.ConfigTunnel() != d.KernelTunnel
+}
+
+func (d *RouteDecision) String() string {
+	target := d.Destination.String()
+	if d.Target != target {
+		target = fmt.Sprintf("%s (%s)", d.Target, target)
+	}
+	config := "config says direct"
+	if d.ConfigTunnel() {
+		config = fmt.Sprintf("config says VPN (%s)", d.ConfigRoute)
+	}
+	switch {
+	case d.Device == "":
+		return fmt.Sprintf("%s: %s, kernel route unknown", target, config)
+	case d.Mismatch():
+		return fmt.Sprintf("%s: %s, kernel says %s — routes may be broken", target, config, d.Device)
+	case d.KernelTunnel:
+		return fmt.Sprintf("%s: through the VPN (%s via %s)", target, d.ConfigRoute, d.Device)
+	}
+	return fmt.Sprintf("%s: not through the VPN (direct via %s)", target, d.Device)
+}
+
+// ParseRouteGet returns the outgoing interface from the output of
+// "ip route get <addr>" (Linux):
+//
+//	10.80.1.5 dev julo-prod table 51820 src 10.9.0.2 uid 1000
+//
+// or "route -n get <addr>" (macOS, BSD):
+//
+//	interface: utun4
+func ParseRouteGet(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if (field == "dev" || field == "interface:") && i+1 < len(fields) {
+				return fields[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// CheckRoute answers "will traffic to target use env's tunnel?" for an
+// address or a hostname, which is resolved first (IPv4 preferred, as the
+// configs route IPv4).
+func (c *Client) CheckRoute(ctx context.Context, env Environment, target string) (*RouteDecision, error) {
+	target = strings.TrimSpace(target)
+	destination, err := netip.ParseAddr(target)
+	if err != nil {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", target)
+		if err != nil || len(addrs) == 0 {
+			return nil, fmt.Errorf("failed to resolve %s: %v", target, err)
+		}
+		destination = addrs[0]
+		for _, addr := range addrs {
+			if addr.Unmap().Is4() {
+				destination = addr
+				break
+			}
+		}
+	}
+	destination = destination.Unmap()
+
+	decision := &RouteDecision{Target: target, Destination: destination, Interface: env.Interface()}
+	routes, err := c.TunnelRoutes(ctx, env.Interface())
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		if route.Contains(destination) && (!decision.ConfigRoute.IsValid() || route.Bits() > decision.ConfigRoute.Bits()) {
+			decision.ConfigRoute = route
+		}
+	}
+
+	var output []byte
+	if runtime.GOOS == "linux" {
+		output, err = c.runner.Output(ctx, "ip", "route", "get", destination.String())
+	} else {
+		output, err = c.runner.Output(ctx, "route", "-n", "get", destination.String())
+	}
+	if err == nil {
+		decision.Device = ParseRouteGet(string(output))
+		decision.KernelTunnel = decision.Device != "" && isTunnelDevice(decision.Device, env.Interface())
+	}
+	return decision, nil
+}
+
+// isTunnelDevice reports whether device is interfaceName: on Linux it is
+// the device itself, on macOS wg-quick records the utun it got in
+// /var/run/wireguard/<name>.name.
+func isTunnelDevice(device, interfaceName string) bool {
+	if device == interfaceName {
+		return true
+	}
+	name, err := os.ReadFile("/var/run/wireguard/" + interfaceName + ".name")
+	return err == nil && strings.TrimSpace(string(name)) == device
+}
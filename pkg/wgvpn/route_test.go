@@ -0,0 +1,130 @@
+package wgvpn
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestParseRouteGet(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"10.80.1.5 dev julo-prod table 51820 src 10.9.0.2 uid 1000\n    cache \n", "julo-prod"},
+		{"8.8.8.8 via 192.168.1.1 dev wlp2s0 src 192.168.1.23 uid 1000\n", "wlp2s0"},
+		{"   route to: 10.80.1.5\ndestination: 10.80.0.0\n       mask: 255.255.0.0\n  interface: utun4\n", "utun4"},
+		{"local 127.0.0.1 dev lo table local src 127.0.0.1\n", "lo"},
+		{"RTNETLINK answers: Network is unreachable\n", ""},
+		{"10.80.1.5 dev", ""},
+	}
+	for _, tt := range tests {
+		if got := ParseRouteGet(tt.output); got != tt.want {
+			t.Errorf("ParseRouteGet(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+// TestCheckRoute pins the decisions for a config routing the office
+// networks and everything in 10.0.0.0/8 but the 10.192.0.0/10 range it
+// leaves out.
+func TestCheckRoute(t *testing.T) {
+	const allowedIPs = "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\t10.0.0.0/9 10.128.0.0/10 10.80.0.0/16 10.80.5.0/24\n"
+	tunnel := "10.80.5.9 dev julo-prod table 51820 src 10.9.0.2 uid 1000\n"
+	direct := "8.8.8.8 via 192.168.1.1 dev wlp2s0 src 192.168.1.23 uid 1000\n"
+	tests := []struct {
+		name     string
+		target   string
+		kernel   string // "" when the lookup fails
+		route    string // the AllowedIPs entry used, "" for none
+		device   string
+		mismatch bool
+		want     string
+	}{
+		{
+			name: "most specific range", target: "10.80.5.9", kernel: tunnel,
+			route: "10.80.5.0/24", device: "julo-prod",
+			want: "10.80.5.9: through the VPN (10.80.5.0/24 via julo-prod)",
+		},
+		{
+			name: "wider range", target: "10.80.1.5", kernel: tunnel,
+			route: "10.80.0.0/16", device: "julo-prod",
+			want: "10.80.1.5: through the VPN (10.80.0.0/16 via julo-prod)",
+		},
+		{
+			name: "widest range", target: "10.3.0.1", kernel: tunnel,
+			route: "10.0.0.0/9", device: "julo-prod",
+			want: "10.3.0.1: through the VPN (10.0.0.0/9 via julo-prod)",
+		},
+		{
+			name: "excluded range", target: "10.200.0.1", kernel: direct,
+			device: "wlp2s0",
+			want:   "10.200.0.1: not through the VPN (direct via wlp2s0)",
+		},
+		{
+			name: "off the tunnel", target: "8.8.8.8", kernel: direct,
+			device: "wlp2s0",
+			want:   "8.8.8.8: not through the VPN (direct via wlp2s0)",
+		},
+		{
+			name: "mapped address", target: "::ffff:10.80.1.5", kernel: tunnel,
+			route: "10.80.0.0/16", device: "julo-prod",
+			want: "::ffff:10.80.1.5 (10.80.1.5): through the VPN (10.80.0.0/16 via julo-prod)",
+		},
+		{
+			name: "kernel bypasses the tunnel", target: "10.80.1.5", kernel: direct,
+			route: "10.80.0.0/16", device: "wlp2s0", mismatch: true,
+			want: "10.80.1.5: config says VPN (10.80.0.0/16), kernel says wlp2s0 — routes may be broken",
+		},
+		{
+			name: "kernel uses the tunnel for an excluded range", target: "10.200.0.1", kernel: tunnel,
+			device: "julo-prod", mismatch: true,
+			want: "10.200.0.1: config says direct, kernel says julo-prod — routes may be broken",
+		},
+		{
+			name: "kernel route unknown", target: "10.80.1.5",
+			route: "10.80.0.0/16",
+			want:  "10.80.1.5: config says VPN (10.80.0.0/16), kernel route unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := &fakeRunner{
+				outputs: map[string]string{"wg show julo-prod allowed-ips": allowedIPs},
+				errs:    map[string]error{},
+			}
+			destination := netip.MustParseAddr(tt.target).Unmap().String()
+			for _, lookup := range []string{"ip route get " + destination, "route -n get " + destination} {
+				if tt.kernel == "" {
+					runner.errs[lookup] = errors.New("exit status 2")
+				} else {
+					runner.outputs[lookup] = tt.kernel
+				}
+			}
+
+			decision, err := New(WithRunner(runner)).CheckRoute(context.Background(), Production, tt.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			route := ""
+			if decision.ConfigTunnel() {
+				route = decision.ConfigRoute.String()
+			}
+			if route != tt.route || decision.Device != tt.device || decision.Mismatch() != tt.mismatch {
+				t.Errorf("CheckRoute() = route %q, device %q, mismatch %v; want %q, %q, %v",
+					route, decision.Device, decision.Mismatch(), tt.route, tt.device, tt.mismatch)
+			}
+			if got := decision.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRouteWithoutTunnel(t *testing.T) {
+	runner := &fakeRunner{errs: map[string]error{"wg show julo-prod allowed-ips": exitError("Unable to access interface: No such device\n")}}
+	if decision, err := New(WithRunner(runner)).CheckRoute(context.Background(), Production, "10.80.1.5"); err == nil {
+		t.Errorf("CheckRoute() = %v without a tunnel", decision)
+	}
+}
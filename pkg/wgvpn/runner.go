@@ -0,0 +1,87 @@
+package wgvpn
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Grace period for a killed command's children to release its output pipe
+const streamWaitDelay = 2 * time.Second
+
+// CommandRunner executes the external wg/wg-quick commands. Replace it to add
+// privilege escalation, logging, or to fake the tools in tests.
+type CommandRunner interface {
+	// Output runs the command and returns its standard output.
+	Output(ctx context.Context, name string, args ...string) ([]byte, error)
+	// CombinedOutput runs the command and returns stdout and stderr together.
+	CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error)
+	// Stream runs the command, calling onLine for every line of combined
+	// output as it is produced, and returns the complete output once the
+	// command exits.
+	Stream(ctx context.Context, onLine func(line string), name string, args ...string) ([]byte, error)
+}
+
+// ExecRunner runs commands with os/exec, optionally through sudo.
+type ExecRunner struct {
+	Sudo bool
+}
+
+func (r ExecRunner) Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return r.command(ctx, name, args...).Output()
+}
+
+func (r ExecRunner) CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return r.command(ctx, name, args...).CombinedOutput()
+}
+
+// command runs in the C locale, so wg's output is the English the parsers
+// expect. sudo keeps LC_ALL and LANG unless sudoers says otherwise.
+func (r ExecRunner) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if r.Sudo {
+		cmd = exec.CommandContext(ctx, "sudo", append([]string{"-n", name}, args...)...)
+	} else {
+		cmd = exec.CommandContext(ctx, name, args...)
+	}
+	cmd.Env = append(os.Environ(), "LC_ALL=C", "LANG=C")
+	return cmd
+}
+
+func (r ExecRunner) Stream(ctx context.Context, onLine func(line string), name string, args ...string) ([]byte, error) {
+	cmd := r.command(ctx, name, args...)
+	cmd.WaitDelay = streamWaitDelay
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var (
+		output bytes.Buffer
+		wg     sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			output.WriteString(line + "\n")
+			if onLine != nil {
+				onLine(line)
+			}
+		}
+		// Keep draining so the command never blocks on a full pipe
+		io.Copy(io.Discard, reader)
+	}()
+
+	err := cmd.Run()
+	writer.Close()
+	wg.Wait()
+	return output.Bytes(), err
+}
@@ -0,0 +1,58 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// fakeRunner stands in for wg and the other tools: each command line maps
+// to its output or its error. It records every command run, and any other
+// command fails.
+type fakeRunner struct {
+	outputs map[string]string
+	errs    map[string]error
+	// replies answer a command's first runs, in order, before outputs and
+	// errs do
+	replies map[string][]reply
+	calls   []string
+}
+
+type reply struct {
+	output string
+	err    error
+}
+
+func (r *fakeRunner) run(name string, args ...string) ([]byte, error) {
+	command := strings.Join(append([]string{name}, args...), " ")
+	r.calls = append(r.calls, command)
+	if replies := r.replies[command]; len(replies) > 0 {
+		r.replies[command] = replies[1:]
+		return []byte(replies[0].output), replies[0].err
+	}
+	if err, ok := r.errs[command]; ok {
+		return nil, err
+	}
+	if output, ok := r.outputs[command]; ok {
+		return []byte(output), nil
+	}
+	return nil, fmt.Errorf("unexpected command: %s", command)
+}
+
+func (r *fakeRunner) Output(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func (r *fakeRunner) CombinedOutput(_ context.Context, name string, args ...string) ([]byte, error) {
+	return r.run(name, args...)
+}
+
+func (r *fakeRunner) Stream(_ context.Context, onLine func(line string), name string, args ...string) ([]byte, error) {
+	output, err := r.run(name, args...)
+	if onLine != nil {
+		for _, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+			onLine(line)
+		}
+	}
+	return output, err
+}
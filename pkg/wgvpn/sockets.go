@@ -0,0 +1,160 @@
+package wgvpn
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Connection is an established TCP connection to an address a tunnel routes,
+// which Stop would drop.
+type Connection struct {
+	Local  netip.AddrPort
+	Remote netip.AddrPort
+	// Process and PID are empty when ss can't tell, e.g. for another user's
+	// process without root
+	Process string
+	PID     int
+}
+
+// ProcessConnections are the connections of one process name.
+type ProcessConnections struct {
+	Process     string // "" for connections of unknown processes
+	Connections []Connection
+}
+
+// ssProcessPattern matches the first process of ss's users:(("psql",pid=1234,fd=3)).
+var ssProcessPattern = regexp.MustCompile(`\("([^"]*)",pid=(\d+)`)
+
+// ParseSS reads the output of "ss -Htnp state established":
+//
+//	0  0  10.9.0.2:51234  10.80.1.5:5432  users:(("psql",pid=1234,fd=3))
+//	0  0  [::ffff:10.9.0.2]:40112  [::ffff:10.88.0.7]:443
+//
+// Lines it can't read are skipped.
+func ParseSS(output string) []Connection {
+	var connections []Connection
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		// With a state filter ss leaves out the State column, but some
+		// versions print it anyway
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			fields = fields[1:]
+			if len(fields) < 4 {
+				continue
+			}
+		}
+		local, err := parseSSAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		remote, err := parseSSAddr(fields[3])
+		if err != nil {
+			continue
+		}
+		connection := Connection{Local: local, Remote: remote}
+		if match := ssProcessPattern.FindStringSubmatch(strings.Join(fields[4:], " ")); match != nil {
+			connection.Process = match[1]
+			connection.PID, _ = strconv.Atoi(match[2])
+		}
+		connections = append(connections, connection)
+	}
+	return connections
+}
+
+// parseSSAddr reads "10.9.0.2:51234", "[fe80::1%eth0]:22" or, from older
+// ss, "::1:22".
+func parseSSAddr(field string) (netip.AddrPort, error) {
+	sep := strings.LastIndex(field, ":")
+	if sep < 0 {
+		return netip.AddrPort{}, fmt.Errorf("no port in %q", field)
+	}
+	addr, err := netip.ParseAddr(strings.Trim(field[:sep], "[]"))
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	port, err := strconv.ParseUint(field[sep+1:], 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+}
+
+// Routed keeps the connections whose remote address is inside one of routes.
+func Routed(connections []Connection, routes []netip.Prefix) []Connection {
+	var routed []Connection
+	for _, connection := range connections {
+		for _, route := range routes {
+			if route.Contains(connection.Remote.Addr()) {
+				routed = append(routed, connection)
+				break
+			}
+		}
+	}
+	return routed
+}
+
+// GroupByProcess groups connections by process name, busiest first, with
+// the unknown processes last.
+func GroupByProcess(connections []Connection) []ProcessConnections {
+	index := map[string]int{}
+	var groups []ProcessConnections
+	for _, connection := range connections {
+		i, ok := index[connection.Process]
+		if !ok {
+			i = len(groups)
+			index[connection.Process] = i
+			groups = append(groups, ProcessConnections{Process: connection.Process})
+		}
+		groups[i].Connections = append(groups[i].Connections, connection)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if (groups[i].Process == "") != (groups[j].Process == "") {
+			return groups[j].Process == ""
+		}
+		return len(groups[i].Connections) > len(groups[j].Connections)
+	})
+	return groups
+}
+
+// TunnelRoutes returns the AllowedIPs of the interface's peers as wg reports
+// them:
+//
+//	<peer public key>	10.80.0.0/16 10.88.0.0/16
+func (c *Client) TunnelRoutes(ctx context.Context, interfaceName string) ([]netip.Prefix, error) {
+	output, err := c.runner.Output(ctx, "wg", "show", interfaceName, "allowed-ips")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the routes of %s: %v", interfaceName, err)
+	}
+	var routes []netip.Prefix
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		for _, field := range fields[min(1, len(fields)):] {
+			if route, err := netip.ParsePrefix(field); err == nil {
+				routes = append(routes, route)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// ActiveConnections lists the established TCP connections env's tunnel
+// carries.
+func (c *Client) ActiveConnections(ctx context.Context, env Environment) ([]Connection, error) {
+	routes, err := c.TunnelRoutes(ctx, env.Interface())
+	if err != nil {
+		return nil, err
+	}
+	output, err := c.runner.Output(ctx, "ss", "-Htnp", "state", "established")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list connections (ss): %v", err)
+	}
+	return Routed(ParseSS(string(output)), routes), nil
+}
@@ -0,0 +1,97 @@
+package wgvpn
+
+import (
+	"context"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+// ssOutput is "ss -Htnp state established" with the tunnel up: a database
+// session and a browser tab through it, the browser over IPv6 too, and
+// connections that don't use the tunnel.
+const ssOutput = `0      0          10.9.0.2:51234        10.80.1.5:5432  users:(("psql",pid=1234,fd=3))
+0      0          10.9.0.2:40112        10.88.0.7:443   users:(("firefox",pid=2210,fd=91),("firefox",pid=2210,fd=93))
+0      0   [::ffff:10.9.0.2]:40114 [::ffff:10.88.0.7]:443   users:(("firefox",pid=2210,fd=95))
+0      0   [fd00:80::2]:50022      [fd00:80::15]:22     users:(("ssh",pid=3301,fd=3))
+0      0     192.168.1.23:51500   140.82.112.4:443   users:(("firefox",pid=2210,fd=97))
+0      0   [2a02:1388::5]:36210   [2606:4700::6810:84e5]:443
+0      0          10.9.0.2:43020        10.80.2.9:6379
+`
+
+func TestParseSS(t *testing.T) {
+	connections := ParseSS(ssOutput + "garbage\n0 0 10.9.0.2 10.80.1.5\n\n")
+	if len(connections) != 7 {
+		t.Fatalf("ParseSS() read %d connections, want 7: %+v", len(connections), connections)
+	}
+	tests := []struct {
+		i       int
+		local   string
+		remote  string
+		process string
+		pid     int
+	}{
+		{0, "10.9.0.2:51234", "10.80.1.5:5432", "psql", 1234},
+		{1, "10.9.0.2:40112", "10.88.0.7:443", "firefox", 2210},
+		// Mapped addresses read as the IPv4 ones
+		{2, "10.9.0.2:40114", "10.88.0.7:443", "firefox", 2210},
+		{3, "[fd00:80::2]:50022", "[fd00:80::15]:22", "ssh", 3301},
+		// Another user's process
+		{6, "10.9.0.2:43020", "10.80.2.9:6379", "", 0},
+	}
+	for _, tt := range tests {
+		got := connections[tt.i]
+		if got.Local.String() != tt.local || got.Remote.String() != tt.remote || got.Process != tt.process || got.PID != tt.pid {
+			t.Errorf("connection %d = %+v, want %s → %s of %q (%d)", tt.i, got, tt.local, tt.remote, tt.process, tt.pid)
+		}
+	}
+}
+
+// Some ss versions print the State column even with a state filter.
+func TestParseSSWithState(t *testing.T) {
+	connections := ParseSS("ESTAB 0 0 10.9.0.2:51234 10.80.1.5:5432 users:((\"psql\",pid=1234,fd=3))\n")
+	if len(connections) != 1 || connections[0].Remote.String() != "10.80.1.5:5432" || connections[0].Process != "psql" {
+		t.Errorf("ParseSS() = %+v", connections)
+	}
+}
+
+func TestActiveConnections(t *testing.T) {
+	runner := &fakeRunner{outputs: map[string]string{
+		"wg show julo-prod allowed-ips": "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=\t10.80.0.0/16 10.88.0.0/16 fd00:80::/64\n",
+		"ss -Htnp state established":    ssOutput,
+	}}
+	connections, err := New(WithRunner(runner)).ActiveConnections(context.Background(), Production)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var remotes []string
+	for _, connection := range connections {
+		remotes = append(remotes, connection.Remote.String())
+	}
+	want := []string{"10.80.1.5:5432", "10.88.0.7:443", "10.88.0.7:443", "[fd00:80::15]:22", "10.80.2.9:6379"}
+	if !slices.Equal(remotes, want) {
+		t.Errorf("ActiveConnections() reach %q, want %q", remotes, want)
+	}
+}
+
+func TestActiveConnectionsWithoutTunnel(t *testing.T) {
+	runner := &fakeRunner{errs: map[string]error{"wg show julo-prod allowed-ips": exitError("Unable to access interface: No such device\n")}}
+	if connections, err := New(WithRunner(runner)).ActiveConnections(context.Background(), Production); err == nil {
+		t.Errorf("ActiveConnections() = %+v without a tunnel", connections)
+	}
+}
+
+func TestGroupByProcess(t *testing.T) {
+	routes := []netip.Prefix{netip.MustParsePrefix("10.80.0.0/16"), netip.MustParsePrefix("10.88.0.0/16"), netip.MustParsePrefix("fd00:80::/64")}
+	groups := GroupByProcess(Routed(ParseSS(ssOutput), routes))
+	var got []string
+	var counts []int
+	for _, group := range groups {
+		got = append(got, group.Process)
+		counts = append(counts, len(group.Connections))
+	}
+	// Busiest first, unknown processes last
+	if !slices.Equal(got, []string{"firefox", "psql", "ssh", ""}) || !slices.Equal(counts, []int{2, 1, 1, 1}) {
+		t.Errorf("GroupByProcess() = %q with %v connections", got, counts)
+	}
+}
@@ -0,0 +1,107 @@
+package wgvpn
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// An operation is flagged as slow when it takes this many times its
+	// usual (p90) duration...
+	slowFactor = 2
+	// ...once there is enough history for the p90 to mean something
+	minTimingSamples = 5
+)
+
+// Clock is the time source of a Timer.
+type Clock func() time.Time
+
+// Phase is the duration of one step of an operation, e.g. "exec" for running
+// wg-quick or "verify" for waiting on the handshake.
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Timer measures an operation phase by phase.
+type Timer struct {
+	now     Clock
+	start   time.Time
+	stopped time.Duration // set by Stop
+	Phases  []Phase
+}
+
+// NewTimer starts timing an operation. A nil clock uses the wall clock.
+func NewTimer(clock Clock) *Timer {
+	if clock == nil {
+		clock = time.Now
+	}
+	return &Timer{now: clock, start: clock()}
+}
+
+// Phase runs fn as the named phase and records how long it took, whether or
+// not it failed.
+func (t *Timer) Phase(name string, fn func() error) error {
+	start := t.now()
+	err := fn()
+	t.Phases = append(t.Phases, Phase{Name: name, Duration: t.now().Sub(start)})
+	return err
+}
+
+// Stop ends the operation and returns its total duration.
+func (t *Timer) Stop() time.Duration {
+	if t.stopped == 0 {
+		t.stopped = t.now().Sub(t.start)
+	}
+	return t.stopped
+}
+
+// Total is the duration up to Stop, or so far if not stopped yet.
+func (t *Timer) Total() time.Duration {
+	if t.stopped != 0 {
+		return t.stopped
+	}
+	return t.now().Sub(t.start)
+}
+
+// Usual compares the total with earlier runs of the same operation. It
+// returns their p90, and whether this run took more than twice that.
+func (t *Timer) Usual(history []time.Duration) (usual time.Duration, slow bool) {
+	return P90(history), SlowerThanUsual(t.Total(), history)
+}
+
+// String breaks the phases down, e.g. "exec 5.1s, verify 1.3s".
+func (t *Timer) String() string {
+	parts := make([]string, len(t.Phases))
+	for i, phase := range t.Phases {
+		parts[i] = fmt.Sprintf("%s %s", phase.Name, FormatDuration(phase.Duration))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// FormatDuration renders d to a tenth of a second, e.g. "6.4s".
+func FormatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
+
+// P90 returns the 90th percentile of durations (nearest rank), or 0 for none.
+func P90(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := (len(sorted)*9 + 9) / 10 // ceil(0.9 * n)
+	return sorted[rank-1]
+}
+
+// SlowerThanUsual reports whether d is more than twice the p90 of history.
+// Too short a history never counts as slow.
+func SlowerThanUsual(d time.Duration, history []time.Duration) bool {
+	if len(history) < minTimingSamples {
+		return false
+	}
+	return d > slowFactor*P90(history)
+}
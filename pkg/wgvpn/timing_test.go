@@ -0,0 +1,112 @@
+package wgvpn
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock moves only when told to.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestTimer(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)}
+	timer := NewTimer(clock.Now)
+
+	clock.advance(200 * time.Millisecond) // before the first phase
+	timer.Phase("exec", func() error {
+		clock.advance(5100 * time.Millisecond)
+		return nil
+	})
+	failed := errors.New("handshake timed out")
+	if err := timer.Phase("verify", func() error {
+		clock.advance(1100 * time.Millisecond)
+		return failed
+	}); err != failed {
+		t.Errorf("Phase() = %v, want the phase's error", err)
+	}
+
+	if got := timer.Total(); got != 6400*time.Millisecond {
+		t.Errorf("Total() = %v while running", got)
+	}
+	if got := timer.Stop(); got != 6400*time.Millisecond {
+		t.Errorf("Stop() = %v", got)
+	}
+	clock.advance(time.Minute)
+	if got := timer.Total(); got != 6400*time.Millisecond {
+		t.Errorf("Total() = %v after Stop", got)
+	}
+	if got := timer.Stop(); got != 6400*time.Millisecond {
+		t.Errorf("second Stop() = %v", got)
+	}
+	if got, want := timer.String(), "exec 5.1s, verify 1.1s"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if got := FormatDuration(timer.Total()); got != "6.4s" {
+		t.Errorf("FormatDuration() = %q", got)
+	}
+}
+
+func seconds(values ...float64) []time.Duration {
+	durations := make([]time.Duration, len(values))
+	for i, v := range values {
+		durations[i] = time.Duration(v * float64(time.Second))
+	}
+	return durations
+}
+
+func TestP90(t *testing.T) {
+	tests := []struct {
+		durations []time.Duration
+		want      time.Duration
+	}{
+		{nil, 0},
+		{seconds(3), 3 * time.Second},
+		{seconds(5, 1, 4, 2, 3), 5 * time.Second},
+		{seconds(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 9 * time.Second},
+		{seconds(10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 20), 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := P90(tt.durations); got != tt.want {
+			t.Errorf("P90(%v) = %v, want %v", tt.durations, got, tt.want)
+		}
+	}
+}
+
+func TestSlowerThanUsual(t *testing.T) {
+	usual := seconds(3, 3.2, 2.9, 3.1, 3)
+	tests := []struct {
+		name    string
+		d       time.Duration
+		history []time.Duration
+		want    bool
+	}{
+		{"usual", 3 * time.Second, usual, false},
+		{"exactly twice", 6400 * time.Millisecond, usual, false},
+		{"over twice", 6500 * time.Millisecond, usual, true},
+		{"too little history", time.Minute, usual[:4], false},
+		{"no history", time.Minute, nil, false},
+	}
+	for _, tt := range tests {
+		if got := SlowerThanUsual(tt.d, tt.history); got != tt.want {
+			t.Errorf("%s: SlowerThanUsual(%v) = %v, want %v", tt.name, tt.d, got, tt.want)
+		}
+	}
+
+	clock := &fakeClock{now: time.Date(2026, 5, 10, 14, 0, 0, 0, time.UTC)}
+	timer := NewTimer(clock.Now)
+	clock.advance(7 * time.Second)
+	if p90, slow := timer.Usual(usual); p90 != 3200*time.Millisecond || !slow {
+		t.Errorf("Usual() = %v, %v", p90, slow)
+	}
+}
@@ -0,0 +1,122 @@
+package wgvpn
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+type Environment string
+
+const (
+	Production    Environment = "prod"
+	NonProduction Environment = "nonprod"
+)
+
+// environments lists every environment the client knows about: prod and
+// nonprod, then the profiles added with AddEnvironment. It is the only such
+// list; everything else asks Environments.
+var environments = []Environment{Production, NonProduction}
+
+// Environments returns every environment the client knows about: prod and
+// nonprod, then the profiles added with AddEnvironment.
+func Environments() []Environment {
+	return slices.Clone(environments)
+}
+
+// maxProfileName keeps "julo-<name>" within the 15 characters an interface
+// name may have.
+const maxProfileName = 10
+
+// AddEnvironment makes a profile besides prod and nonprod known, with its
+// config installed as julo-<name>.conf. Names are lowercase letters, digits
+// and dashes, at most 10 of them. Adding a known one changes nothing.
+func AddEnvironment(name string) (Environment, error) {
+	if name == "" || len(name) > maxProfileName || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("invalid profile name %q: use up to %d lowercase letters, digits and dashes", name, maxProfileName)
+	}
+	if strings.HasSuffix(name, "-template") {
+		return "", fmt.Errorf("invalid profile name %q: -template names the templates", name)
+	}
+	for _, env := range environments {
+		if string(env) == name {
+			return env, nil
+		}
+	}
+	env := Environment(name)
+	environments = append(environments, env)
+	return env, nil
+}
+
+// Interface returns the wg-quick interface (and config) name for the environment.
+func (e Environment) Interface() string {
+	return fmt.Sprintf("julo-%s", string(e))
+}
+
+// displayNames are the names set with SetDisplayName.
+var displayNames = map[Environment]string{}
+
+// SetDisplayName makes name the display name of env, e.g. an organization's
+// own name for its production environment. It is called at startup.
+func SetDisplayName(env Environment, name string) {
+	displayNames[env] = name
+}
+
+// DisplayName returns the human readable name of the environment; other
+// profiles than prod and nonprod go by their name, capitalized, unless
+// SetDisplayName named them.
+func (e Environment) DisplayName() string {
+	if name := displayNames[e]; name != "" {
+		return name
+	}
+	switch e {
+	case Production:
+		return "Production"
+	case NonProduction:
+		return "Non-Production"
+	case "":
+		return ""
+	}
+	return strings.ToUpper(string(e[:1])) + string(e[1:])
+}
+
+// ParseEnvironment accepts the short names used on the command line and in
+// settings ("prod", "nonprod", or an added profile's name).
+func ParseEnvironment(name string) (Environment, error) {
+	names := make([]string, len(environments))
+	for i, env := range environments {
+		if string(env) == name {
+			return env, nil
+		}
+		names[i] = string(env)
+	}
+	return "", fmt.Errorf("unknown environment %q (expected %s)", name, strings.Join(names, ", "))
+}
+
+type ConnectionStatus struct {
+	Connected   bool
+	Environment Environment
+	Interface   string
+	Endpoint    string // ip:port as reported by wg
+	ListenPort  int    // the local UDP port, 0 when unknown
+	// EndpointHost is the gateway hostname the endpoint was configured
+	// with, when known. Filled in by callers; the client never resolves.
+	EndpointHost string
+	LastSeen     *time.Time
+	BytesRx      uint64
+	BytesTx      uint64
+	// ConflictingInterfaces are the other JULO interfaces up at the same
+	// time, which only one tunnel should be
+	ConflictingInterfaces []string
+	// Note says why the details are missing while connected, "" when
+	// they aren't
+	Note string
+	// EgressPinned is the underlying interface the tunnel is pinned to
+	// (WithEgress), and Egress the one its traffic to the endpoint leaves
+	// through; "" when not pinned or not known
+	EgressPinned string
+	Egress       string
+
+	fwmark int // the tunnel's firewall mark, 0 when it has none
+}
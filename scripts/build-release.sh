@@ -22,6 +22,9 @@ CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -ldflags "-s -w" -o "${BUILD_DIR}
 echo "  → Linux arm64"
 CGO_ENABLED=0 GOOS=linux GOARCH=arm64 go build -ldflags "-s -w" -o "${BUILD_DIR}/${BINARY_NAME}-linux-arm64" .
 
+echo "  → Linux arm (32-bit, ARMv7)"
+CGO_ENABLED=0 GOOS=linux GOARCH=arm GOARM=7 go build -ldflags "-s -w" -o "${BUILD_DIR}/${BINARY_NAME}-linux-arm" .
+
 echo "  → Linux 386"
 CGO_ENABLED=0 GOOS=linux GOARCH=386 go build -ldflags "-s -w" -o "${BUILD_DIR}/${BINARY_NAME}-linux-386" .
 
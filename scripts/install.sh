@@ -46,6 +46,7 @@ detect_system() {
     case $arch in
         x86_64|amd64) ARCH="amd64" ;;
         aarch64|arm64) ARCH="arm64" ;;
+        armv7l|armv7*) ARCH="arm" ;;
         i386|i686) ARCH="386" ;;
         *) error "Unsupported architecture: $arch"; exit 1 ;;
     esac
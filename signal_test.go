@@ -0,0 +1,132 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// The tests below run this test binary again as the app: SIGNAL_TEST_CHILD
+// runs the TUI in the middle of a Stop until a signal ends it.
+func TestMain(m *testing.M) {
+	if os.Getenv("SIGNAL_TEST_CHILD") != "" {
+		os.Exit(runSignalChild())
+	}
+	os.Exit(m.Run())
+}
+
+// slowStop is a wg-quick down that runs until it is killed, and then takes
+// a while to be reaped. It starts once signals are forwarded.
+type slowStop struct {
+	vpn.Service
+	forwarding <-chan struct{}
+}
+
+func (s slowStop) StopWithOutput(ctx context.Context, _ vpn.OutputFunc) error {
+	<-s.forwarding
+	fmt.Println("stopping")
+	<-ctx.Done()
+	time.Sleep(200 * time.Millisecond)
+	fmt.Println("wg-quick ended")
+	return ctx.Err()
+}
+
+// stopInProgress is the app with a Stop running, read from Init.
+type stopInProgress struct {
+	model
+	stop tea.Cmd
+}
+
+func (s stopInProgress) Init() tea.Cmd { return s.stop }
+
+func (s stopInProgress) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := s.model.Update(msg)
+	s.model = next.(model)
+	return s, cmd
+}
+
+func (s stopInProgress) View() string { return "" }
+
+// runSignalChild runs the app as main does, and returns the exit status
+// main would.
+func runSignalChild() int {
+	m := initialModel(settings.Default())
+	forwarding := make(chan struct{})
+	m.vpnSvc = slowStop{forwarding: forwarding}
+	stop := m.requestOp(stopOp)
+	p := tea.NewProgram(stopInProgress{model: m, stop: stop}, tea.WithInput(nil), tea.WithoutRenderer(), tea.WithoutSignalHandler())
+	stopSignals := forwardSignals(p)
+	close(forwarding)
+	final, err := p.Run()
+	stopSignals()
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	fmt.Println("quit")
+	app := final.(stopInProgress).model
+	if app.exitSignal == nil {
+		return 0
+	}
+	if running, _, busy := app.opQueue.Running(); busy {
+		fmt.Printf("%s interrupted\n", running.Name)
+	}
+	return signalExitCode(app.exitSignal)
+}
+
+// TestSignalsEndTheApp sends the signals a terminal or a service manager
+// does to the app in the middle of a Stop: it is cancelled, the app waits
+// for its wg-quick to end, and exits with the signal's status.
+func TestSignalsEndTheApp(t *testing.T) {
+	for _, sig := range []syscall.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP} {
+		t.Run(sig.String(), func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^$")
+			cmd.Env = append(os.Environ(), "SIGNAL_TEST_CHILD=1")
+			for _, dir := range []string{"XDG_STATE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_CACHE_HOME"} {
+				cmd.Env = append(cmd.Env, dir+"="+t.TempDir())
+			}
+			cmd.Stderr = os.Stderr
+			stdout, err := cmd.StdoutPipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cmd.Start(); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { cmd.Process.Kill(); cmd.Wait() })
+
+			lines := bufio.NewScanner(stdout)
+			if !lines.Scan() || lines.Text() != "stopping" {
+				t.Fatalf("the app said %q, want the Stop running", lines.Text())
+			}
+			if err := cmd.Process.Signal(sig); err != nil {
+				t.Fatal(err)
+			}
+			var said []string
+			for lines.Scan() {
+				said = append(said, lines.Text())
+			}
+			err = cmd.Wait()
+			if want := []string{"wg-quick ended", "quit", stopOp.Name + " interrupted"}; !slices.Equal(said, want) {
+				t.Errorf("the app said %q, want %q", strings.Join(said, ", "), strings.Join(want, ", "))
+			}
+			if code := cmd.ProcessState.ExitCode(); code != 128+int(sig) {
+				t.Errorf("exit status %d (%v), want %d", code, err, 128+int(sig))
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"tui-wireguard-vpn/internal/settings"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// fakeMonitor delivers the sleep events a test sends and counts the
+// inhibitor locks taken and released.
+type fakeMonitor struct {
+	events   chan bool
+	held     int
+	released int
+}
+
+func newFakeMonitor() *fakeMonitor {
+	return &fakeMonitor{events: make(chan bool, 4)}
+}
+
+func (f *fakeMonitor) Watch(context.Context) (<-chan bool, error) { return f.events, nil }
+
+func (f *fakeMonitor) Inhibit(string) (func(), error) {
+	f.held++
+	return func() { f.released++ }, nil
+}
+
+// sleepService is a tunnel that Stop and Start bring down and up.
+type sleepService struct {
+	vpn.Service
+	status *vpn.ConnectionStatus
+	calls  []string
+}
+
+func (s *sleepService) GetStatus() (*vpn.ConnectionStatus, error) { return s.status, nil }
+
+func (s *sleepService) Stop() error {
+	s.calls = append(s.calls, "stop")
+	s.status = &vpn.ConnectionStatus{}
+	return nil
+}
+
+func (s *sleepService) Start(env vpn.Environment) error {
+	s.calls = append(s.calls, "start "+string(env))
+	s.status = &vpn.ConnectionStatus{Connected: true, Environment: env}
+	return nil
+}
+
+// pendingOps is a service whose Starts and Stops never end, so the ones the
+// TUI runs stay in flight and nothing is run for real.
+type pendingOps struct{ vpn.Service }
+
+func (pendingOps) SnapshotDNS(context.Context) (*vpn.DNSSnapshot, error) { select {} }
+func (pendingOps) StopWithOutput(context.Context, vpn.OutputFunc) error  { select {} }
+func (pendingOps) StartWithOutput(context.Context, vpn.Environment, vpn.OutputFunc) error {
+	select {}
+}
+
+func TestRunSleepWatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		disconnect bool
+		mfa        string
+		want       []string
+	}{
+		{name: "reconnects on resume", disconnect: true, want: []string{"stop", "start " + string(vpn.Production)}},
+		{name: "needs a one-time code", disconnect: true, mfa: "totp", want: []string{"stop"}},
+		{name: "disconnect_on_sleep off"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, dir := range []string{"XDG_STATE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_CACHE_HOME"} {
+				t.Setenv(dir, t.TempDir())
+			}
+			appSettings := settings.Default()
+			appSettings.DisconnectOnSleep = tt.disconnect
+			if tt.mfa != "" {
+				appSettings.Profiles[string(vpn.Production)] = &settings.Profile{Name: string(vpn.Production), MFA: tt.mfa}
+			}
+			monitor := newFakeMonitor()
+			svc := &sleepService{status: &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production}}
+			monitor.events <- true
+			monitor.events <- false
+			close(monitor.events)
+
+			runSleepWatch(context.Background(), monitor, svc, appSettings)
+			if !slices.Equal(svc.calls, tt.want) {
+				t.Errorf("calls = %q, want %q", svc.calls, tt.want)
+			}
+			// One lock before the suspend, one after the resume, none left
+			wantHeld := 0
+			if tt.disconnect {
+				wantHeld = 2
+			}
+			if monitor.held != wantHeld || monitor.released != monitor.held {
+				t.Errorf("took %d inhibitor locks and released %d, want %d", monitor.held, monitor.released, wantHeld)
+			}
+		})
+	}
+}
+
+// TestSleepThenResume runs a suspend and resume through the TUI: the Stop
+// holds the suspend back until it is over, and the tunnel it took down is
+// started again on resume.
+func TestSleepThenResume(t *testing.T) {
+	monitor := newFakeMonitor()
+	m := testModel(t)
+	m.vpnSvc = pendingOps{}
+	m.sleepMonitor = monitor
+	m.settings.DisconnectOnSleep = true
+	m.status = &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production, Interface: "julo-prod"}
+
+	m = update(m, sleepWatchMsg{events: monitor.events})
+	if monitor.held != 1 {
+		t.Fatalf("took %d inhibitor locks while watching, want 1", monitor.held)
+	}
+
+	m = update(m, sleepMsg{sleeping: true})
+	if running, _, ok := m.opQueue.Running(); !ok || running.Key != stopOp.Key {
+		t.Fatalf("running %+v before the suspend, want a Stop", running)
+	}
+	if m.sleepStopped != vpn.Production || monitor.released != 0 {
+		t.Fatalf("sleepStopped %q with %d locks released, want production and the lock held", m.sleepStopped, monitor.released)
+	}
+
+	m = update(m, vpnOperationMsg{operation: stopOp.Key, success: true})
+	if monitor.released != 1 {
+		t.Fatalf("released %d inhibitor locks after the Stop, want 1", monitor.released)
+	}
+	m = update(m, vpnStatusMsg{status: &vpn.ConnectionStatus{}})
+
+	m = update(m, sleepMsg{sleeping: false})
+	if running, _, ok := m.opQueue.Running(); !ok || running.Key != startOp(vpn.Production).Key {
+		t.Errorf("running %+v after the resume, want a Start of production", running)
+	}
+	if m.sleepStopped != "" || monitor.held != 2 {
+		t.Errorf("sleepStopped %q with %d locks taken, want none left and the lock taken again", m.sleepStopped, monitor.held)
+	}
+}
+
+// A resume while the Stop still runs queues the Start after it.
+func TestResumeDuringTheStop(t *testing.T) {
+	monitor := newFakeMonitor()
+	m := testModel(t)
+	m.vpnSvc = pendingOps{}
+	m.sleepMonitor = monitor
+	m.settings.DisconnectOnSleep = true
+	m.status = &vpn.ConnectionStatus{Connected: true, Environment: vpn.Production, Interface: "julo-prod"}
+
+	m = update(m, sleepWatchMsg{events: monitor.events})
+	m = update(m, sleepMsg{sleeping: true})
+	m = update(m, sleepMsg{sleeping: false})
+	if queued, ok := m.opQueue.Queued(); !ok || queued.Key != startOp(vpn.Production).Key {
+		t.Errorf("queued %+v after the resume, want a Start of production", queued)
+	}
+}
@@ -0,0 +1,63 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/sudo"
+)
+
+// recordCommands puts stand-ins for the tools the app runs first on PATH,
+// and returns a function reading the command lines they were run with.
+func recordCommands(t *testing.T) func() []string {
+	t.Helper()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "commands.log")
+	script := "#!/bin/sh\necho \"${0##*/} $*\" >> " + log + "\nexit 1\n"
+	for _, name := range []string{
+		"sudo", "wg", "wg-quick", "ip", "route", "resolvectl", "cat", "ss", "test",
+		"ufw", "firewall-cmd", "nft", "gdbus", "systemd-inhibit", "nmcli", "wslpath", "secret-tool", "sh",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	return func() []string {
+		content, err := os.ReadFile(log)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Split(strings.TrimSpace(string(content)), "\n")
+	}
+}
+
+// The dashboard shows before anything is run: the setup check and the
+// status come later, from Init's commands.
+func TestFirstFrameRunsNoCommands(t *testing.T) {
+	commands := recordCommands(t)
+	m := testModel(t)
+	if m.Init() == nil {
+		t.Fatal("Init() has nothing to run")
+	}
+	if view := m.View(); view == "" {
+		t.Fatal("no first frame")
+	}
+	if ran := commands(); len(ran) > 0 {
+		t.Fatalf("ran %q before the first frame", ran)
+	}
+
+	// The stand-ins do record what runs
+	sudo.Refresh(context.Background(), sudo.Runner)
+	if ran := commands(); !slices.Equal(ran, []string{"sudo -n -v"}) {
+		t.Errorf("recorded %q for a sudo refresh", ran)
+	}
+}
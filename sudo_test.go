@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"tui-wireguard-vpn/internal/sudo"
+	"tui-wireguard-vpn/pkg/wgvpn"
+)
+
+// sudoRunner answers the refreshes in turn: true for still cached.
+type sudoRunner struct {
+	wgvpn.CommandRunner
+	cached []bool
+	runs   int
+}
+
+func (r *sudoRunner) CombinedOutput(_ context.Context, name string, args ...string) ([]byte, error) {
+	if command := strings.Join(append([]string{name}, args...), " "); command != "sudo -n -v" {
+		return nil, errors.New("unexpected command: " + command)
+	}
+	cached := r.cached[r.runs]
+	r.runs++
+	if !cached {
+		return nil, errors.New("exit status 1")
+	}
+	return nil, nil
+}
+
+func useSudoRunner(t *testing.T, cached ...bool) *sudoRunner {
+	runner := &sudoRunner{cached: cached}
+	saved := sudo.Runner
+	sudo.Runner = runner
+	t.Cleanup(func() { sudo.Runner = saved })
+	return runner
+}
+
+func TestSudoRefreshLoop(t *testing.T) {
+	runner := useSudoRunner(t, true, true, false)
+	m := testModel(t)
+
+	m = update(m, sudoMsg{action: "validate"})
+	if !m.sudoKept {
+		t.Fatalf("credentials not kept: %q", m.message)
+	}
+	// Each tick refreshes; a refresh that still finds them cached waits for
+	// the next tick
+	for i := range 3 {
+		next, cmd := m.Update(sudoTickMsg{seq: m.sudoSeq})
+		m = next.(model)
+		if cmd == nil {
+			t.Fatalf("tick %d: no refresh", i+1)
+		}
+		next, cmd = m.Update(cmd())
+		m = next.(model)
+		if kept := i < 2; m.sudoKept != kept || (cmd != nil) != kept {
+			t.Fatalf("refresh %d: kept %v, next tick scheduled %v", i+1, m.sudoKept, cmd != nil)
+		}
+	}
+	if runner.runs != 3 {
+		t.Errorf("%d refreshes, want 3", runner.runs)
+	}
+	if errors := m.activityLog.Last("⚠️", 1); len(errors) != 1 || !strings.Contains(errors[0], "no longer cached") {
+		t.Errorf("log %q", errors)
+	}
+	// Lost credentials stop the loop
+	if _, cmd := m.Update(sudoTickMsg{seq: m.sudoSeq}); cmd != nil {
+		t.Error("a tick refreshed credentials no longer kept")
+	}
+}
+
+func TestSudoRefreshLoopEndsOnDrop(t *testing.T) {
+	useSudoRunner(t, true)
+	m := testModel(t)
+	m = update(m, sudoMsg{action: "validate"})
+	seq := m.sudoSeq
+
+	// Dropping leaves the scheduled tick and any refresh in flight stale
+	m.sudoKept = false
+	m.sudoSeq++
+	if _, cmd := m.Update(sudoTickMsg{seq: seq}); cmd != nil {
+		t.Error("a stale tick refreshed")
+	}
+	if _, cmd := m.Update(sudoMsg{action: "refresh", seq: seq}); cmd != nil {
+		t.Error("a stale refresh scheduled another tick")
+	}
+}
+
+func TestSudoNotCached(t *testing.T) {
+	m := testModel(t)
+	m = update(m, sudoMsg{action: "validate", err: sudo.ErrNoCaching})
+	if m.sudoKept || !strings.Contains(m.message, "timestamp_timeout=0") {
+		t.Errorf("kept %v, message %q", m.sudoKept, m.message)
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"tui-wireguard-vpn/internal/core"
+	"tui-wireguard-vpn/internal/mfa"
+	"tui-wireguard-vpn/internal/ui/render"
+	"tui-wireguard-vpn/internal/vpn"
+)
+
+// View draws the dashboard, in plain ASCII on terminals that need it.
+func (m model) View() string {
+	if render.ASCII() {
+		return render.ToASCII(m.view())
+	}
+	return m.view()
+}
+
+func (m model) view() string {
+	if m.miniMode {
+		return m.buildMiniView()
+	}
+
+	// Simplified 4-panel layout with better proportions
+	leftWidth, rightWidth, bottomLeftWidth, bottomRightWidth := m.panelWidths()
+
+	topHeight := (m.terminalHeight * 2 / 3) - 6
+	bottomHeight := m.logPanelHeight()
+
+	if m.showInputPanel && (m.inputModel != nil || m.generateModel != nil) {
+		// Layout with input panel: Menu + Status | Input | Activity Log | Controls
+		leftPanel := m.buildMainStatusPanel(leftWidth, topHeight)
+		inputPanel := m.buildInputPanel(rightWidth, topHeight)
+		activityPanel := m.buildOutputPanel(bottomLeftWidth, bottomHeight)
+		controlsPanel := m.buildControlsPanel(bottomRightWidth, bottomHeight)
+
+		// Top row: Combined Menu+Status | Input
+		topRow := lipgloss.JoinHorizontal(lipgloss.Top, leftPanel, inputPanel)
+
+		// Bottom row: Activity Log | Controls
+		bottomRow := lipgloss.JoinHorizontal(lipgloss.Top, activityPanel, controlsPanel)
+
+		layout := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(m.titleLine()),
+			"",
+			topRow,
+			"",
+			bottomRow)
+
+		return render.Clip(layout, m.terminalWidth)
+	} else {
+		// Standard layout: Menu + Status | Help | Activity Log | Controls
+		leftPanel := m.buildMainStatusPanel(leftWidth, topHeight)
+		helpPanel := m.buildHelpPanel(rightWidth, topHeight)
+		if m.overviewOpen {
+			helpPanel = m.buildOverviewPanel(rightWidth, topHeight)
+		} else if m.connectionsOpen {
+			helpPanel = m.buildConnectionsPanel(rightWidth, topHeight)
+		} else if m.routesOpen {
+			helpPanel = m.buildRoutesPanel(rightWidth, topHeight)
+		} else if m.configOpen {
+			helpPanel = m.buildConfigPanel(rightWidth, topHeight)
+		} else if m.preflightOpen {
+			helpPanel = m.buildPreflightPanel(rightWidth, topHeight)
+		} else if m.historyOpen {
+			helpPanel = m.buildHistoryPanel(rightWidth, topHeight)
+		}
+		activityPanel := m.buildOutputPanel(bottomLeftWidth, bottomHeight)
+		controlsPanel := m.buildControlsPanel(bottomRightWidth, bottomHeight)
+
+		// Top row: Combined Menu+Status | Help
+		topRow := lipgloss.JoinHorizontal(lipgloss.Top, leftPanel, helpPanel)
+		if m.switcher != nil {
+			// The switcher is modal: it takes the place of the top row
+			popup := render.RenderSwitcher(m.switcherItems(), m.switcher.cursor, m.switcher.message, 48)
+			topRow = lipgloss.Place(lipgloss.Width(topRow), lipgloss.Height(topRow), lipgloss.Center, lipgloss.Center, popup)
+		} else if m.conflict != nil && m.conflict.tunnels != nil {
+			// So is the conflict screen, once the tunnels are listed
+			popup := render.RenderConflict(m.conflict.tunnels, m.conflict.cursor, m.conflict.message, 72)
+			topRow = lipgloss.Place(lipgloss.Width(topRow), lipgloss.Height(topRow), lipgloss.Center, lipgloss.Center, popup)
+		}
+
+		// Bottom row: Activity Log | Controls
+		bottomRow := lipgloss.JoinHorizontal(lipgloss.Top, activityPanel, controlsPanel)
+
+		layout := lipgloss.JoinVertical(lipgloss.Left,
+			titleStyle.Render(m.titleLine()),
+			"",
+			topRow,
+			"",
+			bottomRow)
+
+		return render.Clip(layout, m.terminalWidth)
+	}
+}
+
+// titleLine is the title, with a lock while sudo credentials are kept.
+func (m model) titleLine() string {
+	if m.sudoKept {
+		return m.title + " 🔒"
+	}
+	return m.title
+}
+
+// buildMiniView renders the collapsed layout: the title and one status line,
+// with no endpoints, addresses, log or config details.
+func (m model) buildMiniView() string {
+	line := render.RenderMiniStatus(m.status, m.loading || !m.statusChecked)
+	if m.statusErr != nil && !m.loading {
+		line = "? Status unknown — m to expand for details"
+	}
+	if m.confirm != nil {
+		line += "  " + warningStyle.Render(m.confirm.question+" "+m.confirm.choices())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		titleStyle.Render(m.titleLine()),
+		"",
+		render.Truncate(line, m.terminalWidth),
+		"",
+		helpStyle.Render("mini mode · m to expand · q to quit"))
+}
+
+func (m model) buildMainStatusPanel(width, height int) string {
+	var content strings.Builder
+	textWidth := render.ContentWidth(mainPanelStyle, width)
+
+	// VPN Status section first
+	if m.statusErr != nil {
+		content.WriteString(render.RenderStatusError(m.statusErr, textWidth))
+	} else if m.statusChecked {
+		content.WriteString(render.RenderStatus(m.status, m.rate.Rate(), textWidth))
+	} else {
+		content.WriteString(render.RenderStatusChecking(textWidth))
+	}
+	if len(remoteSources(m.settings)) > 0 {
+		content.WriteString(render.RenderSyncStatus(m.lastSync, m.syncFailed, textWidth) + "\n")
+		if len(m.pendingUpdates) > 0 {
+			content.WriteString(warningStyle.Render(render.Truncate(fmt.Sprintf("⬇️ %d update(s) pending — press U to apply", len(m.pendingUpdates)), textWidth)) + "\n")
+		}
+	}
+	if n := len(m.deferredConfigs); n > 0 {
+		line := fmt.Sprintf("⏳ %d pending config update(s) — installed on disconnect (A now, D to discard)", n)
+		for _, pending := range m.deferredConfigs {
+			if pending.Error != "" {
+				line = fmt.Sprintf("⏳ %d pending config update(s) — install failed: %s (A to retry, D to discard)", n, pending.Error)
+			}
+		}
+		content.WriteString(warningStyle.Render(render.Truncate(line, textWidth)) + "\n")
+	}
+	content.WriteString(render.RenderReachableHosts(m.sshHosts, textWidth))
+	if m.status != nil && len(m.status.ConflictingInterfaces) > 0 && m.conflict == nil {
+		content.WriteString(warningStyle.Render(render.Truncate(fmt.Sprintf("⚠️ Also up: %s — press i to resolve", strings.Join(m.status.ConflictingInterfaces, ", ")), textWidth)) + "\n")
+	}
+	if m.readOnly {
+		content.WriteString(warningStyle.Render(render.Truncate("🔒 Read-only: setup incomplete (s to set up)", textWidth)) + "\n")
+	}
+	if banner := m.platform.Banner(); banner != "" {
+		content.WriteString(warningStyle.Width(textWidth).Render("⚠️ "+banner) + "\n")
+	}
+	if m.location != nil && m.location.VPN == "skip" && (m.status == nil || !m.status.Connected) {
+		content.WriteString(render.Truncate(fmt.Sprintf("🏢 %s network detected — VPN not needed", m.location.Name), textWidth) + "\n")
+	}
+
+	if m.reconnect != nil {
+		line := fmt.Sprintf("🔁 Reconnect to %s? (last connected %s) [Enter/esc]", vpn.Environment(m.reconnect.Environment).DisplayName(), render.Ago(time.Since(m.reconnect.ConnectedAt)))
+		content.WriteString(selectedStyle.Render(render.Truncate(line, textWidth)) + "\n")
+	}
+
+	content.WriteString("\n🎛️  Main Menu\n")
+	content.WriteString("─────────────────────\n")
+
+	// Menu
+	// The spinner goes on the item a queued operation started from; other
+	// operations block the menu and keep the cursor where it was
+	loadingItem := m.cursor
+	if running, _, ok := m.opQueue.Running(); ok {
+		loadingItem = m.opItem(running)
+	}
+	items := make([]render.MenuItem, len(m.menu))
+	for i, entry := range m.menu {
+		items[i] = render.MenuItem{
+			Label:    entry.label(),
+			Disabled: m.menuDisabled(i),
+			Loading:  m.loading && loadingItem == i,
+		}
+		if entry.action != menuStart {
+			continue
+		}
+		items[i].Note, items[i].NoteWarn = m.configAge(entry.env)
+		if m.unconfigured(entry.env) {
+			items[i].DisabledNote = "not configured — press Enter to set up"
+		}
+	}
+	if queued, ok := m.opQueue.Queued(); ok {
+		items[m.opItem(queued)].Queued = true
+	}
+	content.WriteString(render.RenderMenu(items, m.cursor, m.activePanel == 0, textWidth))
+
+	// Message area
+	if m.mfa != nil {
+		entered := m.mfa.code + strings.Repeat("_", mfa.Digits-len(m.mfa.code))
+		content.WriteString("\n" + warningStyle.Render(fmt.Sprintf("🔐 One-time code for %s: %s (Enter to check, Esc to cancel)",
+			vpn.Environment(m.mfa.gate.Profile).DisplayName(), entered)) + "\n")
+		if m.message != "" {
+			content.WriteString(m.message + "\n")
+		}
+	} else if m.routeCheck != nil {
+		prompt := fmt.Sprintf("🧭 Route check through %s — destination (IP or hostname): %s_", m.routeCheck.env.DisplayName(), m.routeCheck.target)
+		if m.routeCheck.checking {
+			prompt = fmt.Sprintf("🧭 Checking %s...", m.routeCheck.target)
+		}
+		content.WriteString("\n" + warningStyle.Render(prompt) + "\n")
+	} else if m.confirm != nil {
+		content.WriteString("\n" + warningStyle.Render(m.confirm.question+" "+m.confirm.choices()) + "\n")
+	} else if m.steps != nil {
+		content.WriteString("\n" + render.RenderSteps(m.steps, textWidth))
+	} else if m.message != "" {
+		content.WriteString("\n" + m.message + "\n")
+	}
+
+	if m.gatewayMigration != nil {
+		content.WriteString("\n" + warningStyle.Render(fmt.Sprintf("⚠️ %s %s — press g to update",
+			m.gatewayMigration.Environment.DisplayName(), m.gatewayMigration)) + "\n")
+	}
+
+	panelStyle := mainPanelStyle.Width(width).Height(height)
+	if m.activePanel == 0 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue for active panel
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White for inactive panel
+	}
+
+	return panelStyle.Render(content.String())
+}
+
+// panelWidths splits the terminal width between the panels: halves for the
+// top row, two thirds and one third for the bottom row. The values are what
+// each panel's style gets as Width, so every row fits the terminal exactly.
+func (m model) panelWidths() (topLeft, topRight, bottomLeft, bottomRight int) {
+	top := render.Columns(m.terminalWidth,
+		render.Panel{Style: mainPanelStyle, Weight: 1},
+		render.Panel{Style: inputPanelStyle, Weight: 1})
+	bottom := render.Columns(m.terminalWidth,
+		render.Panel{Style: outputPanelStyle, Weight: 2},
+		render.Panel{Style: controlsPanelStyle, Weight: 1})
+	return top[0], top[1], bottom[0], bottom[1]
+}
+
+// logPanelHeight is the Height of the bottom row's panels.
+func (m model) logPanelHeight() int {
+	return (m.terminalHeight / 3) - 3
+}
+
+// inputPanelSize is the space available to the input panel's content, sent
+// to the input model in place of the terminal size.
+func (m model) inputPanelSize() tea.WindowSizeMsg {
+	_, topRight, _, _ := m.panelWidths()
+	return tea.WindowSizeMsg{
+		Width:  render.ContentWidth(inputPanelStyle, topRight),
+		Height: (m.terminalHeight * 2 / 3) - 6,
+	}
+}
+
+func (m model) buildInputPanel(width, height int) string {
+	var inputView string
+	switch {
+	case m.generateModel != nil:
+		inputView = m.generateModel.View()
+	case m.inputModel != nil:
+		// Get the input model view without panel styling first
+		inputView = m.inputModel.View()
+	default:
+		return m.buildHelpPanel(width, height)
+	}
+
+	// Pin the width so the row adds up, but let the height follow the content
+	panelStyle := inputPanelStyle.Width(width)
+
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue for active panel
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White for inactive panel
+	}
+
+	return panelStyle.Render(inputView)
+}
+
+func (m model) buildHelpPanel(width, height int) string {
+	helpText := `🔧 Configuration Panel
+
+File picker for config selection:
+• Use ↑/↓ to navigate files
+• Enter to select/enter directories  
+• h = Home directory
+• Ctrl+H = Toggle hidden files
+• Select .conf files to proceed
+
+Tab to switch between panels
+Esc to close panels`
+
+	panelStyle := inputPanelStyle.Width(width).Height(height).BorderForeground(normalPanelBorder)
+	return panelStyle.Render(helpText)
+}
+
+func (m model) buildOverviewPanel(width, height int) string {
+	content := render.RenderOverview(m.overview, m.overviewProbing, render.ContentWidth(inputPanelStyle, width))
+	panelStyle := inputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder)
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder)
+	}
+	return panelStyle.Render(content)
+}
+
+func (m model) buildConnectionsPanel(width, height int) string {
+	env := vpn.Environment("")
+	if m.status != nil {
+		env = m.status.Environment
+	}
+	content := render.RenderConnections(env, m.connections, m.connectionsErr, m.connectionsBusy, render.ContentWidth(inputPanelStyle, width))
+	panelStyle := inputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder)
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder)
+	}
+	return panelStyle.Render(content)
+}
+
+func (m model) buildPreflightPanel(width, height int) string {
+	content := render.RenderPreflight(m.preflightEnv, m.preflight, m.preflightBusy, render.ContentWidth(inputPanelStyle, width))
+	panelStyle := inputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder)
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder)
+	}
+	return panelStyle.Render(content)
+}
+
+func (m model) buildHistoryPanel(width, height int) string {
+	content := render.RenderTimeline(m.history, m.historyErr, render.ContentWidth(inputPanelStyle, width))
+	panelStyle := inputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder)
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder)
+	}
+	return panelStyle.Render(content)
+}
+
+func (m model) buildRoutesPanel(width, height int) string {
+	env := vpn.Environment("")
+	if m.status != nil {
+		env = m.status.Environment
+	}
+	editor := ""
+	if m.labelEdit != nil {
+		editor = m.labelEdit.input.View()
+	}
+	cursor := -1
+	if m.activePanel == 1 {
+		cursor = m.routesCursor
+	}
+	content := render.RenderRoutes(env, m.routes, m.routeLabels, cursor, editor, m.routesErr, m.routesBusy, render.ContentWidth(inputPanelStyle, width))
+	panelStyle := inputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder)
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder)
+	}
+	return panelStyle.Render(content)
+}
+
+// openConfigView shows a sanitized config in the panel on the right.
+func (m *model) openConfigView(content string) {
+	m.closeSidePanels()
+	m.configOpen = true
+	m.activePanel = 1
+	width, height := m.configViewSize()
+	m.configView = viewport.New(width, height)
+	m.configView.SetContent(content)
+}
+
+// configViewSize is the size of the config viewport: the help panel less
+// its padding, title and rule, and the keys line of a review.
+func (m model) configViewSize() (int, int) {
+	_, right, _, _ := m.panelWidths()
+	height := (m.terminalHeight * 2 / 3) - 6 - inputPanelStyle.GetVerticalPadding() - 2
+	if m.review != nil {
+		height--
+	}
+	return render.ContentWidth(inputPanelStyle, right), max(height, 1)
+}
+
+func (m model) buildConfigPanel(width, height int) string {
+	var content strings.Builder
+	textWidth := render.ContentWidth(inputPanelStyle, width)
+	title := fmt.Sprintf("📄 %s config (%s)", m.viewedConfig.DisplayName(), core.ConfigFile(m.viewedConfig))
+	if m.review != nil {
+		preview := m.review.preview
+		title = fmt.Sprintf("📝 Changes to %s (+%d -%d)", filepath.Base(preview.Path), preview.Added, preview.Removed)
+		if !preview.Exists {
+			title = fmt.Sprintf("📝 New %s (+%d)", filepath.Base(preview.Path), preview.Added)
+		}
+	}
+	total, visible := m.configView.TotalLineCount(), m.configView.VisibleLineCount()
+	if position := render.ScrollPosition(m.configView.YOffset+visible, total, m.configView.Height); position != "" {
+		title += " " + position
+	}
+	content.WriteString(selectedStyle.Render(render.Truncate(title, textWidth)) + "\n")
+	content.WriteString(render.Rule(textWidth) + "\n")
+	// The viewport pads its rows to its width; the scrollbar takes two cells
+	rows := strings.Split(m.configView.View(), "\n")
+	for i, row := range rows {
+		rows[i] = strings.TrimRight(row, " ")
+	}
+	content.WriteString(strings.Join(render.WithScrollbar(rows, total, m.configView.YOffset, textWidth), "\n"))
+	if m.review != nil {
+		content.WriteString("\n" + warningStyle.Render(render.Truncate("Apply (y) / Cancel (esc)", textWidth)))
+	}
+
+	panelStyle := inputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 1 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder)
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder)
+	}
+	return panelStyle.Render(content.String())
+}
+
+func (m model) buildOutputPanel(width, height int) string {
+	var content strings.Builder
+	textWidth := render.ContentWidth(outputPanelStyle, width)
+
+	// Panel title with focus indicator
+	title := "📊 Activity Log"
+	if position := m.activityLog.Position(); position != "" {
+		title += " " + position
+	}
+	if !m.activityLog.Following() {
+		title += " (paused, F to follow)"
+	}
+	if m.activePanel == 2 {
+		if m.activityLog.Following() {
+			title += " (Press ↑/↓ to select, Tab to switch panels)"
+		}
+		content.WriteString(selectedStyle.Render(render.Truncate(title, textWidth)) + "\n")
+	} else {
+		content.WriteString(render.Truncate(title, textWidth) + "\n")
+	}
+	content.WriteString(render.Rule(textWidth) + "\n")
+
+	content.WriteString(m.activityLog.View(textWidth, m.activePanel == 2))
+
+	// Apply focus styling to panel border
+	panelStyle := outputPanelStyle.Width(width).Height(height)
+	if m.activePanel == 2 {
+		panelStyle = panelStyle.BorderForeground(activePanelBorder) // Blue when focused
+	} else {
+		panelStyle = panelStyle.BorderForeground(normalPanelBorder) // White when not focused
+	}
+
+	return panelStyle.Render(content.String())
+}